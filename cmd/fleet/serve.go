@@ -23,12 +23,16 @@ import (
 	"github.com/fleetdm/fleet/v4/server/datastore/s3"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/health"
+	"github.com/fleetdm/fleet/v4/server/jira"
 	"github.com/fleetdm/fleet/v4/server/launcher"
 	"github.com/fleetdm/fleet/v4/server/live_query"
 	"github.com/fleetdm/fleet/v4/server/mail"
 	"github.com/fleetdm/fleet/v4/server/pubsub"
 	"github.com/fleetdm/fleet/v4/server/service"
+	"github.com/fleetdm/fleet/v4/server/software_queue"
 	"github.com/fleetdm/fleet/v4/server/sso"
+	"github.com/fleetdm/fleet/v4/server/vulnerabilities"
+	"github.com/fleetdm/fleet/v4/server/webhooks"
 	kitlog "github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
@@ -88,6 +92,8 @@ the way that the Fleet server works.
 				)
 			}
 
+			// The last errors logged are served by the debug endpoints.
+			recentErrors := service.NewRecentErrors(100)
 			var logger kitlog.Logger
 			{
 				output := os.Stderr
@@ -101,6 +107,7 @@ the way that the Fleet server works.
 				} else {
 					logger = level.NewFilter(logger, level.AllowInfo())
 				}
+				logger = recentErrors.Logger(logger)
 				logger = kitlog.With(logger, "ts", kitlog.DefaultTimestampUTC)
 			}
 
@@ -187,7 +194,15 @@ the way that the Fleet server works.
 			liveQueryStore := live_query.NewRedisLiveQuery(redisPool)
 			ssoSessionStore := sso.NewSessionStore(redisPool)
 
-			svc, err := service.NewService(ds, resultStore, logger, config, mailService, clock.C, ssoSessionStore, liveQueryStore, carveStore, *license)
+			var softwareQueue fleet.HostSoftwareQueue
+			if config.Osquery.AsyncSoftwareIngestion {
+				if config.Osquery.AsyncSoftwareIngestionInterval <= 0 || config.Osquery.AsyncSoftwareIngestionBatchSize <= 0 {
+					initFatal(errors.New("osquery.async_software_ingestion_interval and osquery.async_software_ingestion_batch_size must be positive"), "configuring async software ingestion")
+				}
+				softwareQueue = software_queue.NewRedisSoftwareQueue(redisPool)
+			}
+
+			svc, err := service.NewService(ds, resultStore, logger, config, mailService, clock.C, ssoSessionStore, liveQueryStore, carveStore, softwareQueue, *license)
 			if err != nil {
 				initFatal(err, "initializing service")
 			}
@@ -205,10 +220,35 @@ the way that the Fleet server works.
 					ds.CleanupDistributedQueryCampaigns(time.Now())
 					ds.CleanupIncomingHosts(time.Now())
 					ds.CleanupCarves(time.Now())
+					ds.CleanupOrphanedSoftware(config.App.SoftwareCleanupBatchSize)
+					ds.CalculateHostsPerSoftware(time.Now())
+					ds.CalculateSoftwareTitles(time.Now())
 					<-ticker.C
 				}
 			}()
 
+			if config.Vulnerabilities.DatabasesPath != "" {
+				if config.Vulnerabilities.Periodicity <= 0 {
+					initFatal(errors.New("vulnerabilities.periodicity must be positive"), "configuring vulnerability processing")
+				}
+				go cronVulnerabilities(ds, logger, config.Vulnerabilities)
+			}
+
+			if softwareQueue != nil {
+				go func() {
+					ticker := time.NewTicker(config.Osquery.AsyncSoftwareIngestionInterval)
+					for {
+						<-ticker.C
+						if err := svc.FlushHostSoftware(context.Background()); err != nil {
+							level.Error(logger).Log(
+								"err", err,
+								"msg", "failed to save buffered host software",
+							)
+						}
+					}
+				}()
+			}
+
 			// Flush seen hosts every second
 			go func() {
 				ticker := time.NewTicker(1 * time.Second)
@@ -297,7 +337,7 @@ the way that the Fleet server works.
 			rootMux.Handle("/metrics", prometheus.InstrumentHandler("metrics", promhttp.Handler()))
 			rootMux.Handle("/api/", apiHandler)
 			rootMux.Handle("/", frontendHandler)
-			rootMux.Handle("/debug/", service.MakeDebugHandler(svc, config, logger))
+			rootMux.Handle("/debug/", service.MakeDebugHandler(svc, config, logger, ds, recentErrors))
 
 			if path, ok := os.LookupEnv("FLEET_TEST_PAGE_PATH"); ok {
 				// test that we can load this
@@ -384,6 +424,87 @@ the way that the Fleet server works.
 // value supplied to server_tls_compatibility command line flag. The default
 // profile is 'modern'.
 // See https://wiki.mozilla.org/index.php?title=Security/Server_Side_TLS&oldid=1229478
+// cronVulnerabilities periodically syncs the CVE data and matches the
+// software inventory against it.
+func cronVulnerabilities(ds fleet.Datastore, logger kitlog.Logger, config config.VulnerabilitiesConfig) {
+	logger = kitlog.With(logger, "component", "vulnerabilities")
+	client := &http.Client{Timeout: 10 * time.Minute}
+
+	ticker := time.NewTicker(config.Periodicity)
+	for {
+		if err := processVulnerabilities(ds, client, config); err != nil {
+			level.Error(logger).Log("err", err, "msg", "failed to process vulnerabilities")
+		}
+		<-ticker.C
+	}
+}
+
+func processVulnerabilities(ds fleet.Datastore, client *http.Client, config config.VulnerabilitiesConfig) error {
+	// The CVEs found by this run are those created since it started. The
+	// timestamps of the CVEs found have a precision of one second.
+	start := time.Now().Truncate(time.Second)
+
+	if !config.DisableDataSync {
+		err := vulnerabilities.SyncCVEData(context.Background(), client, config.CVEFeedPrefixURL, config.DatabasesPath, time.Now())
+		if err != nil {
+			return errors.Wrap(err, "sync cve data")
+		}
+		if err := vulnerabilities.SyncEPSSData(context.Background(), client, config.EPSSFeedURL, config.DatabasesPath); err != nil {
+			return errors.Wrap(err, "sync epss data")
+		}
+		if err := vulnerabilities.SyncKEVData(context.Background(), client, config.CISAKEVFeedURL, config.DatabasesPath); err != nil {
+			return errors.Wrap(err, "sync cisa kev data")
+		}
+
+		versions, err := ds.ListHostOSVersions()
+		if err != nil {
+			return errors.Wrap(err, "list host os versions")
+		}
+		if err := vulnerabilities.SyncOVALData(context.Background(), client, config.DatabasesPath, versions); err != nil {
+			return errors.Wrap(err, "sync oval data")
+		}
+		if err := vulnerabilities.SyncMSRCData(context.Background(), client, config.MSRCFeedURL, config.DatabasesPath, versions); err != nil {
+			return errors.Wrap(err, "sync msrc data")
+		}
+	}
+
+	if err := vulnerabilities.TranslateSoftwareToCPE(ds); err != nil {
+		return errors.Wrap(err, "translate software to cpe")
+	}
+
+	db, err := vulnerabilities.LoadCVEDatabase(config.DatabasesPath)
+	if err != nil {
+		return errors.Wrap(err, "load cve database")
+	}
+	if err := vulnerabilities.TranslateCPEToCVE(ds, db); err != nil {
+		return errors.Wrap(err, "translate cpe to cve")
+	}
+	epss, err := vulnerabilities.LoadEPSSScores(config.DatabasesPath)
+	if err != nil {
+		return errors.Wrap(err, "load epss scores")
+	}
+	knownExploits, err := vulnerabilities.LoadKnownExploits(config.DatabasesPath)
+	if err != nil {
+		return errors.Wrap(err, "load cisa known exploits")
+	}
+	if err := vulnerabilities.SaveCVEMeta(ds, db, epss, knownExploits); err != nil {
+		return errors.Wrap(err, "save cve meta")
+	}
+	if err := vulnerabilities.TranslateOVALToCVE(ds, config.DatabasesPath); err != nil {
+		return errors.Wrap(err, "translate oval to cve")
+	}
+	if err := vulnerabilities.TranslateMSRCToOSVulnerabilities(ds, config.DatabasesPath); err != nil {
+		return errors.Wrap(err, "translate msrc to os vulnerabilities")
+	}
+	if err := webhooks.TriggerVulnerabilitiesWebhook(context.Background(), ds, start); err != nil {
+		return errors.Wrap(err, "trigger vulnerabilities webhook")
+	}
+	if err := jira.TriggerVulnerabilitiesIssues(context.Background(), ds, start); err != nil {
+		return errors.Wrap(err, "create jira vulnerabilities issues")
+	}
+	return nil
+}
+
 func getTLSConfig(profile string) *tls.Config {
 	cfg := tls.Config{
 		PreferServerCipherSuites: true,
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/fleetdm/fleet/v4/server/service"
 	"github.com/pkg/errors"
@@ -87,3 +91,102 @@ func clientFromCLI(c *cli.Context) (*service.Client, error) {
 
 	return fleet, nil
 }
+
+// apiRequestBody returns the body of the request given with --data: the JSON
+// itself, @ followed by the path of a file, or - to read it from stdin.
+func apiRequestBody(c *cli.Context, data string) ([]byte, error) {
+	var body []byte
+	var err error
+	switch {
+	case data == "-":
+		body, err = ioutil.ReadAll(c.App.Reader)
+	case strings.HasPrefix(data, "@"):
+		body, err = ioutil.ReadFile(strings.TrimPrefix(data, "@"))
+	default:
+		body = []byte(data)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read --data")
+	}
+	if !json.Valid(body) {
+		return nil, errors.New("--data must be valid JSON")
+	}
+	return body, nil
+}
+
+func apiCommand() *cli.Command {
+	var flData string
+	return &cli.Command{
+		Name:      "api",
+		Usage:     "Send an authenticated request to the Fleet API and print the response",
+		UsageText: `fleetctl api [options] <method> <path>, for example: fleetctl api GET "/api/v1/fleet/hosts?query=foo"`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "data",
+				Aliases:     []string{"d"},
+				Destination: &flData,
+				Usage:       "JSON body of the request, @ followed by the path of a file, or - to read it from stdin",
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 2 {
+				return errors.New("a method and a path must be specified, for example: fleetctl api GET /api/v1/fleet/hosts")
+			}
+			verb, path := strings.ToUpper(c.Args().Get(0)), c.Args().Get(1)
+			switch verb {
+			case "GET", "POST", "PUT", "PATCH", "DELETE":
+			default:
+				return errors.Errorf("unsupported method %s", verb)
+			}
+			if !strings.HasPrefix(path, "/") {
+				return errors.Errorf("the path must start with /, got %s", path)
+			}
+			rawQuery := ""
+			if i := strings.Index(path, "?"); i >= 0 {
+				path, rawQuery = path[:i], path[i+1:]
+			}
+
+			var params interface{}
+			if flData != "" {
+				body, err := apiRequestBody(c, flData)
+				if err != nil {
+					return err
+				}
+				params = json.RawMessage(body)
+			}
+
+			fleet, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			response, err := fleet.AuthenticatedDo(verb, path, rawQuery, params)
+			if err != nil {
+				return errors.Wrapf(err, "%s %s", verb, path)
+			}
+			defer response.Body.Close()
+
+			body, err := ioutil.ReadAll(response.Body)
+			if err != nil {
+				return errors.Wrap(err, "read response body")
+			}
+
+			// The body is printed even for errors, as it has their reasons.
+			var indented bytes.Buffer
+			if err := json.Indent(&indented, body, "", "  "); err == nil {
+				body = append(indented.Bytes(), '\n')
+			}
+			if _, err := c.App.Writer.Write(body); err != nil {
+				return err
+			}
+
+			if response.StatusCode < 200 || response.StatusCode >= 300 {
+				return errors.Errorf("%s %s received status %d", verb, path, response.StatusCode)
+			}
+			return nil
+		},
+	}
+}
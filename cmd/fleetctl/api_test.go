@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	var gotOpt fleet.HostListOptions
+	ds.ListHostsFunc = func(filter fleet.TeamFilter, opt fleet.HostListOptions) ([]*fleet.Host, error) {
+		gotOpt = opt
+		return []*fleet.Host{{ID: 1, Hostname: "foo.local"}}, nil
+	}
+	ds.AppConfigFunc = func() (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	var gotQueries []*fleet.Query
+	ds.ApplyQueriesFunc = func(authorID uint, queries []*fleet.Query) error {
+		gotQueries = queries
+		return nil
+	}
+	ds.NewActivityFunc = func(user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	ds.HostFunc = func(id uint) (*fleet.Host, error) {
+		return nil, &mock.Error{Message: "host not found"}
+	}
+
+	out := runAppForTest(t, []string{"api", "get", "/api/v1/fleet/hosts?query=foo"})
+	assert.Equal(t, "foo", gotOpt.MatchQuery)
+	assert.Contains(t, out, "\n  \"hosts\": [\n")
+	assert.Contains(t, out, `"hostname": "foo.local"`)
+
+	runAppForTest(t, []string{"api", "-d", `{"specs": [{"name": "q1", "query": "select 1"}]}`, "POST", "/api/v1/fleet/spec/queries"})
+	require.Len(t, gotQueries, 1)
+	assert.Equal(t, "q1", gotQueries[0].Name)
+
+	// The body of the errors is printed along with the status.
+	out, _, err := runConvertForTest(t, []string{"api", "GET", "/api/v1/fleet/hosts/42"})
+	assert.EqualError(t, err, "GET /api/v1/fleet/hosts/42 received status 404")
+	assert.Contains(t, out, `"message": "Resource Not Found"`)
+
+	_, _, err = runConvertForTest(t, []string{"api", "GET"})
+	assert.EqualError(t, err, "a method and a path must be specified, for example: fleetctl api GET /api/v1/fleet/hosts")
+	_, _, err = runConvertForTest(t, []string{"api", "HEAD", "/api/v1/fleet/hosts"})
+	assert.EqualError(t, err, "unsupported method HEAD")
+	_, _, err = runConvertForTest(t, []string{"api", "-d", "{", "POST", "/api/v1/fleet/spec/queries"})
+	assert.EqualError(t, err, "--data must be valid JSON")
+}
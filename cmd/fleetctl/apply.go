@@ -2,11 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/service"
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
@@ -20,6 +24,9 @@ type specMetadata struct {
 	Kind    string          `json:"kind"`
 	Version string          `json:"apiVersion"`
 	Spec    json.RawMessage `json:"spec"`
+	// Metadata holds information about the spec for external tooling. It is
+	// not applied.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type specGroup struct {
@@ -27,6 +34,7 @@ type specGroup struct {
 	Packs        []*fleet.PackSpec
 	Labels       []*fleet.LabelSpec
 	AppConfig    *fleet.AppConfigPayload
+	Decorators   *fleet.DecoratorConfig
 	EnrollSecret *fleet.EnrollSecretSpec
 	UsersRoles   *fleet.UsersRoleSpec
 }
@@ -83,6 +91,17 @@ func specGroupFromBytes(b []byte) (*specGroup, error) {
 			}
 			specs.AppConfig = appConfigSpec
 
+		case fleet.DecoratorsKind:
+			if specs.Decorators != nil {
+				return nil, errors.New("decorators defined twice in the same file")
+			}
+
+			var decoratorsSpec *fleet.DecoratorConfig
+			if err := yaml.Unmarshal(s.Spec, &decoratorsSpec); err != nil {
+				return nil, errors.Wrap(err, "unmarshaling "+kind+" spec")
+			}
+			specs.Decorators = decoratorsSpec
+
 		case fleet.EnrollSecretKind:
 			if specs.AppConfig != nil {
 				return nil, errors.New("enroll_secret defined twice in the same file")
@@ -109,9 +128,159 @@ func specGroupFromBytes(b []byte) (*specGroup, error) {
 	return specs, nil
 }
 
+// specInputs returns the Fleet spec files for the -f values, replacing
+// directories by the YAML files they contain, recursively so that a tree like
+// the queries/ and packs/ of fleetctl convert --output-dir is found.
+func specInputs(values []string) ([]string, error) {
+	var filenames []string
+	for _, value := range values {
+		err := filepath.Walk(value, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if path != value {
+				switch strings.ToLower(filepath.Ext(path)) {
+				case ".yml", ".yaml":
+				default:
+					return nil
+				}
+			}
+			filenames = append(filenames, path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filenames, nil
+}
+
+// expandEnv replaces the $VAR and ${VAR} references in the spec file with the
+// values of the environment variables, and $$ with a literal $. Undefined
+// variables are an error rather than silently expanded to empty strings.
+func expandEnv(b []byte) ([]byte, error) {
+	var missing []string
+	expanded := os.Expand(string(b), func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return nil, errors.Errorf("undefined environment variables: %s", strings.Join(missing, ", "))
+	}
+	return []byte(expanded), nil
+}
+
+// readSpecFile reads the spec file, expanding the environment variables it
+// references if expand is set.
+func readSpecFile(filename string, expand bool) ([]byte, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if expand {
+		b, err = expandEnv(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "expand %s", filename)
+		}
+	}
+	return b, nil
+}
+
+// specGroupFromFiles parses the spec files into a single group. A spec defined
+// in several files is an error, as which one would be applied would depend on
+// the order of the files.
+func specGroupFromFiles(filenames []string, expand bool) (*specGroup, error) {
+	specs := &specGroup{
+		Queries: []*fleet.QuerySpec{},
+		Packs:   []*fleet.PackSpec{},
+		Labels:  []*fleet.LabelSpec{},
+	}
+	defined := make(map[string]string)
+	define := func(kind, name, filename string) error {
+		key := kind + " " + name
+		if other, ok := defined[key]; ok {
+			return errors.Errorf("%s %q is defined in both %s and %s", kind, name, other, filename)
+		}
+		defined[key] = filename
+		return nil
+	}
+
+	for _, filename := range filenames {
+		b, err := readSpecFile(filename, expand)
+		if err != nil {
+			return nil, err
+		}
+		group, err := specGroupFromBytes(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s", filename)
+		}
+
+		for _, query := range group.Queries {
+			if err := define(fleet.QueryKind, query.Name, filename); err != nil {
+				return nil, err
+			}
+		}
+		for _, label := range group.Labels {
+			if err := define(fleet.LabelKind, label.Name, filename); err != nil {
+				return nil, err
+			}
+		}
+		for _, pack := range group.Packs {
+			if err := define(fleet.PackKind, pack.Name, filename); err != nil {
+				return nil, err
+			}
+		}
+		if group.AppConfig != nil {
+			if err := define(fleet.AppConfigKind, "", filename); err != nil {
+				return nil, err
+			}
+			specs.AppConfig = group.AppConfig
+		}
+		if group.Decorators != nil {
+			if err := define(fleet.DecoratorsKind, "", filename); err != nil {
+				return nil, err
+			}
+			specs.Decorators = group.Decorators
+		}
+		if group.EnrollSecret != nil {
+			if err := define(fleet.EnrollSecretKind, "", filename); err != nil {
+				return nil, err
+			}
+			specs.EnrollSecret = group.EnrollSecret
+		}
+		if group.UsersRoles != nil {
+			if specs.UsersRoles == nil {
+				specs.UsersRoles = &fleet.UsersRoleSpec{Roles: make(map[string]*fleet.UserRoleSpec)}
+			}
+			for email, roles := range group.UsersRoles.Roles {
+				if err := define(fleet.UserRolesKind, email, filename); err != nil {
+					return nil, err
+				}
+				specs.UsersRoles.Roles[email] = roles
+			}
+		}
+
+		specs.Queries = append(specs.Queries, group.Queries...)
+		specs.Labels = append(specs.Labels, group.Labels...)
+		specs.Packs = append(specs.Packs, group.Packs...)
+	}
+	return specs, nil
+}
+
 func applyCommand() *cli.Command {
 	var (
-		flFilename string
+		flFilename  string
+		flDryRun    bool
+		flExpandEnv bool
 	)
 	return &cli.Command{
 		Name:      "apply",
@@ -123,7 +292,17 @@ func applyCommand() *cli.Command {
 				EnvVars:     []string{"FILENAME"},
 				Value:       "",
 				Destination: &flFilename,
-				Usage:       "A file to apply",
+				Usage:       "A file, or a directory of YAML files, to apply",
+			},
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Destination: &flDryRun,
+				Usage:       "Validate the specs on the server and print the changes applying them would make, without applying them",
+			},
+			&cli.BoolFlag{
+				Name:        "expand-env",
+				Destination: &flExpandEnv,
+				Usage:       "Replace $VAR and ${VAR} in the files with the values of the environment variables",
 			},
 			configFlag(),
 			contextFlag(),
@@ -134,65 +313,244 @@ func applyCommand() *cli.Command {
 				return errors.New("-f must be specified")
 			}
 
-			b, err := ioutil.ReadFile(flFilename)
+			info, err := os.Stat(flFilename)
 			if err != nil {
 				return err
 			}
 
-			fleetClient, err := clientFromCLI(c)
-			if err != nil {
-				return err
+			var specs *specGroup
+			if info.IsDir() {
+				filenames, err := specInputs([]string{flFilename})
+				if err != nil {
+					return err
+				}
+				if len(filenames) == 0 {
+					return errors.Errorf("no YAML files found in %s", flFilename)
+				}
+				specs, err = specGroupFromFiles(filenames, flExpandEnv)
+				if err != nil {
+					return err
+				}
+			} else {
+				b, err := readSpecFile(flFilename, flExpandEnv)
+				if err != nil {
+					return err
+				}
+				specs, err = specGroupFromBytes(b)
+				if err != nil {
+					return err
+				}
 			}
 
-			specs, err := specGroupFromBytes(b)
+			warnUnstoredFields(c, specs)
+
+			fleetClient, err := clientFromCLI(c)
 			if err != nil {
 				return err
 			}
 
-			if len(specs.Queries) > 0 {
-				if err := fleetClient.ApplyQueries(specs.Queries); err != nil {
-					return errors.Wrap(err, "applying queries")
+			if flDryRun {
+				changes, err := validateSpecs(c, fleetClient, specs)
+				if err != nil {
+					return err
 				}
-				logf(c, "[+] applied %d queries\n", len(specs.Queries))
+				printSpecChanges(c, changes)
+				return nil
 			}
 
-			if len(specs.Labels) > 0 {
-				if err := fleetClient.ApplyLabels(specs.Labels); err != nil {
-					return errors.Wrap(err, "applying labels")
+			// A directory is validated as a whole before anything is
+			// applied, so that an invalid file doesn't leave the server
+			// with only part of the specs applied.
+			var changes []*fleet.SpecChange
+			if info.IsDir() {
+				changes, err = validateSpecs(c, fleetClient, specs)
+				if err != nil {
+					return errors.Wrap(err, "nothing was applied")
 				}
-				logf(c, "[+] applied %d labels\n", len(specs.Labels))
 			}
 
-			if len(specs.Packs) > 0 {
-				if err := fleetClient.ApplyPacks(specs.Packs); err != nil {
-					return errors.Wrap(err, "applying packs")
-				}
-				logf(c, "[+] applied %d packs\n", len(specs.Packs))
+			if err := applySpecs(c, fleetClient, specs); err != nil {
+				return err
 			}
 
-			if specs.AppConfig != nil {
-				if err := fleetClient.ApplyAppConfig(specs.AppConfig); err != nil {
-					return errors.Wrap(err, "applying fleet config")
-				}
-				log(c, "[+] applied fleet config\n")
-
+			if info.IsDir() {
+				log(c, "\nChanges:\n")
+				printSpecChanges(c, changes)
 			}
 
-			if specs.EnrollSecret != nil {
-				if err := fleetClient.ApplyEnrollSecretSpec(specs.EnrollSecret); err != nil {
-					return errors.Wrap(err, "applying enroll secrets")
-				}
-				log(c, "[+] applied enroll secrets\n")
-			}
+			return nil
+		},
+	}
+}
 
-			if specs.UsersRoles != nil {
-				if err := fleetClient.ApplyUsersRoleSecretSpec(specs.UsersRoles); err != nil {
-					return errors.Wrap(err, "applying user roles")
-				}
-				log(c, "[+] applied user roles\n")
+// applySpecs applies the specs in dependency order: queries and labels before
+// the packs using them.
+func applySpecs(c *cli.Context, fleetClient *service.Client, specs *specGroup) error {
+	if len(specs.Queries) > 0 {
+		if err := fleetClient.ApplyQueries(specs.Queries); err != nil {
+			return errors.Wrap(err, "applying queries")
+		}
+		logf(c, "[+] applied %d queries\n", len(specs.Queries))
+	}
+
+	if len(specs.Labels) > 0 {
+		if err := fleetClient.ApplyLabels(specs.Labels); err != nil {
+			return errors.Wrap(err, "applying labels")
+		}
+		logf(c, "[+] applied %d labels\n", len(specs.Labels))
+	}
+
+	if len(specs.Packs) > 0 {
+		if err := fleetClient.ApplyPacks(specs.Packs); err != nil {
+			return errors.Wrap(err, "applying packs")
+		}
+		logf(c, "[+] applied %d packs\n", len(specs.Packs))
+	}
+
+	if specs.Decorators != nil {
+		if err := applyDecorators(fleetClient, specs); err != nil {
+			return err
+		}
+	}
+
+	if specs.AppConfig != nil {
+		if err := fleetClient.ApplyAppConfig(specs.AppConfig); err != nil {
+			return errors.Wrap(err, "applying fleet config")
+		}
+		log(c, "[+] applied fleet config\n")
+	}
+
+	if specs.EnrollSecret != nil {
+		if err := fleetClient.ApplyEnrollSecretSpec(specs.EnrollSecret); err != nil {
+			return errors.Wrap(err, "applying enroll secrets")
+		}
+		log(c, "[+] applied enroll secrets\n")
+	}
+
+	if specs.UsersRoles != nil {
+		if err := fleetClient.ApplyUsersRoleSecretSpec(specs.UsersRoles); err != nil {
+			return errors.Wrap(err, "applying user roles")
+		}
+		log(c, "[+] applied user roles\n")
+	}
+
+	return nil
+}
+
+// applyDecorators sets the decorators spec as the decorators of the agent
+// options applied with the config. Without a config spec, the decorators
+// replace those of the agent options on the server.
+func applyDecorators(fleetClient *service.Client, specs *specGroup) error {
+	if specs.AppConfig == nil {
+		current, err := fleetClient.GetAppConfig()
+		if err != nil {
+			return errors.Wrap(err, "getting agent options for decorators")
+		}
+		specs.AppConfig = &fleet.AppConfigPayload{AgentOptions: current.AgentOptions}
+	}
+	return setAgentOptionsDecorators(specs.AppConfig, specs.Decorators)
+}
+
+// setAgentOptionsDecorators replaces the decorators of the agent options of
+// the config.
+func setAgentOptionsDecorators(appConfig *fleet.AppConfigPayload, decorators *fleet.DecoratorConfig) error {
+	var agentOptions map[string]json.RawMessage
+	if appConfig.AgentOptions != nil {
+		if err := json.Unmarshal(*appConfig.AgentOptions, &agentOptions); err != nil {
+			return errors.Wrap(err, "unmarshaling agent options")
+		}
+	}
+	if agentOptions == nil {
+		agentOptions = make(map[string]json.RawMessage)
+	}
+
+	var config map[string]json.RawMessage
+	if raw, ok := agentOptions["config"]; ok {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return errors.Wrap(err, "unmarshaling agent options config")
+		}
+	}
+	if config == nil {
+		config = make(map[string]json.RawMessage)
+	}
+
+	b, err := json.Marshal(decorators)
+	if err != nil {
+		return errors.Wrap(err, "marshaling decorators")
+	}
+	config["decorators"] = b
+	if agentOptions["config"], err = json.Marshal(config); err != nil {
+		return errors.Wrap(err, "marshaling agent options config")
+	}
+
+	b, err = json.Marshal(agentOptions)
+	if err != nil {
+		return errors.Wrap(err, "marshaling agent options")
+	}
+	raw := json.RawMessage(b)
+	appConfig.AgentOptions = &raw
+	return nil
+}
+
+// validateSpecs validates the specs on the server, returning the changes
+// applying them would make.
+func validateSpecs(c *cli.Context, fleetClient *service.Client, specs *specGroup) ([]*fleet.SpecChange, error) {
+	if specs.AppConfig != nil || specs.Decorators != nil {
+		fmt.Fprint(c.App.ErrWriter, "[!] the config is not validated before being applied\n")
+	}
+
+	return fleetClient.ValidateSpecs(&fleet.Specs{
+		Queries:      specs.Queries,
+		Labels:       specs.Labels,
+		Packs:        specs.Packs,
+		EnrollSecret: specs.EnrollSecret,
+		UsersRoles:   specs.UsersRoles,
+	})
+}
+
+// warnUnstoredFields warns about the fields of the pack queries that are not
+// stored by the server, such as the ones output by fleetctl convert for the
+// consumers of the specs. They are ignored when the specs are applied.
+func warnUnstoredFields(c *cli.Context, specs *specGroup) {
+	for _, pack := range specs.Packs {
+		for _, query := range pack.Queries {
+			var fields []string
+			if query.LoggingDestination != nil {
+				fields = append(fields, "logging_destination")
 			}
+			if query.Value != nil {
+				fields = append(fields, "value")
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			name := query.Name
+			if name == "" {
+				name = query.QueryName
+			}
+			fmt.Fprintf(c.App.ErrWriter, "[!] pack %q query %q: %s ignored, not stored by Fleet\n", pack.Name, name, strings.Join(fields, " and "))
+		}
+	}
+}
 
-			return nil
-		},
+// printSpecChanges prints the changes in the format of fleetctl convert
+// --diff.
+func printSpecChanges(c *cli.Context, changes []*fleet.SpecChange) {
+	if len(changes) == 0 {
+		log(c, "No changes\n")
+		return
+	}
+
+	ops := map[string]string{
+		fleet.SpecChangeCreate: "+",
+		fleet.SpecChangeUpdate: "~",
+		fleet.SpecChangeDelete: "-",
+	}
+	var diff specDiff
+	for _, change := range changes {
+		diff.add("", ops[change.Action], change.Kind, change.Name)
+	}
+	for _, line := range diff {
+		log(c, line+"\n")
 	}
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -95,3 +97,351 @@ spec:
 	require.Len(t, userRoleSpecList[1].Teams, 1)
 	assert.Equal(t, fleet.RoleMaintainer, userRoleSpecList[1].Teams[0].Role)
 }
+
+func TestApplyDryRun(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+
+	ds.ListQueriesFunc = func(opt fleet.ListOptions) ([]*fleet.Query, error) {
+		return []*fleet.Query{
+			{Name: "time", Query: "select * from time;"},
+			{Name: "uptime", Query: "select * from uptime;"},
+		}, nil
+	}
+	ds.GetLabelSpecsFunc = func() ([]*fleet.LabelSpec, error) {
+		return []*fleet.LabelSpec{{Name: "All Hosts", Query: "select 1;", LabelType: fleet.LabelTypeBuiltIn}}, nil
+	}
+	ds.GetPackSpecsFunc = func() ([]*fleet.PackSpec, error) {
+		return []*fleet.PackSpec{{
+			Name: "pack",
+			Queries: []fleet.PackSpecQuery{
+				{Name: "time", QueryName: "time", Interval: 60},
+				{Name: "uptime", QueryName: "uptime", Interval: 60},
+			},
+		}}, nil
+	}
+	ds.GetEnrollSecretsFunc = func(teamID *uint) ([]*fleet.EnrollSecret, error) {
+		return []*fleet.EnrollSecret{{Secret: "old"}}, nil
+	}
+	ds.ApplyQueriesFunc = func(authorID uint, queries []*fleet.Query) error {
+		t.Fatal("dry run applied the queries")
+		return nil
+	}
+
+	tmpFile, err := ioutil.TempFile(t.TempDir(), "*.yml")
+	require.NoError(t, err)
+	_, err = tmpFile.WriteString(`
+---
+apiVersion: v1
+kind: query
+spec:
+  name: time
+  query: select * from time;
+---
+apiVersion: v1
+kind: query
+spec:
+  name: processes
+  query: select * from processes;
+---
+apiVersion: v1
+kind: label
+spec:
+  name: macOS
+  query: select 1 from os_version where platform = 'darwin';
+---
+apiVersion: v1
+kind: pack
+spec:
+  name: pack
+  targets:
+    labels:
+    - macOS
+  queries:
+  - query: time
+    interval: 3600
+  - query: processes
+    interval: 60
+---
+apiVersion: v1
+kind: enroll_secret
+spec:
+  secrets:
+  - secret: new
+`)
+	require.NoError(t, err)
+
+	assert.Equal(t, `+ query "processes"
++ label "macOS"
+~ pack "pack"
+~ scheduled_query "pack/time"
++ scheduled_query "pack/processes"
+- scheduled_query "pack/uptime"
++ enroll_secret "global"
+- enroll_secret "global"
+`, runAppForTest(t, []string{"apply", "-f", tmpFile.Name(), "--dry-run"}))
+
+	// All the validation errors are reported.
+	require.NoError(t, ioutil.WriteFile(tmpFile.Name(), []byte(`
+---
+apiVersion: v1
+kind: pack
+spec:
+  name: pack
+  targets:
+    labels:
+    - Windows
+  queries:
+  - query: processes
+    interval: 60
+`), 0600))
+	_, _, err = runConvertForTest(t, []string{"apply", "-f", tmpFile.Name(), "--dry-run"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pack pack cannot schedule unknown query 'processes'")
+	assert.Contains(t, err.Error(), "pack pack cannot target unknown label 'Windows'")
+
+	// Unchanged specs make no changes.
+	require.NoError(t, ioutil.WriteFile(tmpFile.Name(), []byte(`
+---
+apiVersion: v1
+kind: query
+spec:
+  name: uptime
+  query: select * from uptime;
+`), 0600))
+	assert.Equal(t, "No changes\n", runAppForTest(t, []string{"apply", "-f", tmpFile.Name(), "--dry-run"}))
+}
+
+func TestApplyDirectory(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.ListQueriesFunc = func(opt fleet.ListOptions) ([]*fleet.Query, error) {
+		return nil, nil
+	}
+	ds.GetLabelSpecsFunc = func() ([]*fleet.LabelSpec, error) {
+		return nil, nil
+	}
+	ds.GetPackSpecsFunc = func() ([]*fleet.PackSpec, error) {
+		return nil, nil
+	}
+	ds.NewActivityFunc = func(user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	var applied []string
+	ds.ApplyQueriesFunc = func(authorID uint, queries []*fleet.Query) error {
+		applied = append(applied, fleet.QueryKind)
+		return nil
+	}
+	ds.ApplyLabelSpecsFunc = func(specs []*fleet.LabelSpec) error {
+		applied = append(applied, fleet.LabelKind)
+		return nil
+	}
+	ds.ApplyPackSpecsFunc = func(specs []*fleet.PackSpec) error {
+		applied = append(applied, fleet.PackKind)
+		return nil
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		// The pack is applied after the query and label it uses, whatever
+		// the order of the files.
+		"a/pack.yml": `
+apiVersion: v1
+kind: pack
+spec:
+  name: pack
+  targets:
+    labels:
+    - macOS
+  queries:
+  - query: time
+    interval: 60
+`,
+		"b/queries/time.yaml": `
+apiVersion: v1
+kind: query
+spec:
+  name: time
+  query: select * from time;
+`,
+		"c/labels.yml": `
+apiVersion: v1
+kind: label
+spec:
+  name: macOS
+  query: select 1 from os_version where platform = 'darwin';
+`,
+		"README.md": `Fleet configuration`,
+	}
+	for name, contents := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(t, ioutil.WriteFile(filename, []byte(contents), 0600))
+	}
+
+	assert.Equal(t, `[+] applied 1 queries
+[+] applied 1 labels
+[+] applied 1 packs
+
+Changes:
++ query "time"
++ label "macOS"
++ pack "pack"
+`, runAppForTest(t, []string{"apply", "-f", dir}))
+	assert.Equal(t, []string{fleet.QueryKind, fleet.LabelKind, fleet.PackKind}, applied)
+
+	// Nothing is applied when a file is invalid.
+	applied = nil
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "pack.yml"), []byte(`
+apiVersion: v1
+kind: pack
+spec:
+  name: pack
+  queries:
+  - query: uptime
+    interval: 60
+`), 0600))
+	_, _, err := runConvertForTest(t, []string{"apply", "-f", dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nothing was applied")
+	assert.Empty(t, applied)
+
+	// A spec can only be defined once.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c", "time.yml"), []byte(files["b/queries/time.yaml"]), 0600))
+	_, _, err = runConvertForTest(t, []string{"apply", "-f", dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `query "time" is defined in both`)
+}
+
+func TestApplyUnstoredFields(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.GetPackSpecsFunc = func() ([]*fleet.PackSpec, error) {
+		return nil, nil
+	}
+	ds.NewActivityFunc = func(user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	var applied []*fleet.PackSpec
+	ds.ApplyPackSpecsFunc = func(specs []*fleet.PackSpec) error {
+		applied = specs
+		return nil
+	}
+
+	filename := filepath.Join(t.TempDir(), "pack.yml")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`
+apiVersion: v1
+kind: pack
+spec:
+  name: pack
+  queries:
+  - query: time
+    interval: 60
+    logging_destination: kinesis
+    value: "5"
+  - query: uptime
+    interval: 60
+    value: "10"
+  - query: processes
+    interval: 60
+`), 0600))
+
+	stdout, stderr, err := runConvertForTest(t, []string{"apply", "-f", filename})
+	require.NoError(t, err)
+	assert.Equal(t, "[+] applied 1 packs\n", stdout)
+	assert.Equal(t, `[!] pack "pack" query "time": logging_destination and value ignored, not stored by Fleet
+[!] pack "pack" query "uptime": value ignored, not stored by Fleet
+`, stderr)
+	require.Len(t, applied, 1)
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("FLEET_TEST_SECRET", "abc")
+	defer os.Unsetenv("FLEET_TEST_SECRET")
+
+	b, err := expandEnv([]byte("secret: $FLEET_TEST_SECRET\nurl: https://${FLEET_TEST_SECRET}.example.com\nquery: select '$$1';"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret: abc\nurl: https://abc.example.com\nquery: select '$1';", string(b))
+
+	_, err = expandEnv([]byte("secret: $FLEET_TEST_UNDEFINED ${FLEET_TEST_SECRET}"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "undefined environment variables: FLEET_TEST_UNDEFINED")
+}
+
+func TestApplyExpandEnv(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	var secrets []*fleet.EnrollSecret
+	ds.ApplyEnrollSecretsFunc = func(teamID *uint, s []*fleet.EnrollSecret) error {
+		secrets = s
+		return nil
+	}
+
+	os.Setenv("FLEET_TEST_ENROLL_SECRET", "s3cr3t")
+	defer os.Unsetenv("FLEET_TEST_ENROLL_SECRET")
+	filename := filepath.Join(t.TempDir(), "secrets.yml")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`
+apiVersion: v1
+kind: enroll_secret
+spec:
+  secrets:
+  - secret: ${FLEET_TEST_ENROLL_SECRET}
+`), 0600))
+
+	assert.Equal(t, "[+] applied enroll secrets\n", runAppForTest(t, []string{"apply", "-f", filename, "--expand-env"}))
+	require.Len(t, secrets, 1)
+	assert.Equal(t, "s3cr3t", secrets[0].Secret)
+
+	// Without --expand-env, the references are applied as is.
+	runAppForTest(t, []string{"apply", "-f", filename})
+	assert.Equal(t, "${FLEET_TEST_ENROLL_SECRET}", secrets[0].Secret)
+}
+
+func TestApplyDecorators(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	current := json.RawMessage(`{"config": {"options": {"host_identifier": "uuid"}, "decorators": {"load": ["SELECT 1;"]}}}`)
+	ds.AppConfigFunc = func() (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{AgentOptions: &current}, nil
+	}
+	var saved *fleet.AppConfig
+	ds.SaveAppConfigFunc = func(config *fleet.AppConfig) error {
+		saved = config
+		return nil
+	}
+
+	filename := writeTempPack(t, `---
+apiVersion: v1
+kind: decorators
+spec:
+  always:
+  - SELECT user AS username FROM logged_in_users LIMIT 1;
+`)
+	assert.Equal(t, "[+] applied fleet config\n", runAppForTest(t, []string{"apply", "-f", filename}))
+	require.NotNil(t, saved)
+	require.NotNil(t, saved.AgentOptions)
+	assert.JSONEq(t, `{"config": {
+		"options": {"host_identifier": "uuid"},
+		"decorators": {"always": ["SELECT user AS username FROM logged_in_users LIMIT 1;"]}
+	}}`, string(*saved.AgentOptions))
+}
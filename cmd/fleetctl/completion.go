@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fleetdm/fleet/v4/server/service"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// bashCompletion and zshCompletion are the urfave/cli autocomplete scripts
+// for fleetctl. They complete the words by running fleetctl with the
+// --generate-bash-completion flag.
+const bashCompletion = `#!/bin/bash
+
+_fleetctl_bash_autocomplete() {
+  if [[ "${COMP_WORDS[0]}" != "source" ]]; then
+    local cur opts
+    COMPREPLY=()
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    if [[ "$cur" == "-"* ]]; then
+      opts=$( ${COMP_WORDS[@]:0:$COMP_CWORD} ${cur} --generate-bash-completion 2>/dev/null )
+    else
+      opts=$( ${COMP_WORDS[@]:0:$COMP_CWORD} --generate-bash-completion 2>/dev/null )
+    fi
+    COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+    return 0
+  fi
+}
+
+complete -o bashdefault -o default -o nospace -F _fleetctl_bash_autocomplete fleetctl
+`
+
+const zshCompletion = `#compdef fleetctl
+
+_fleetctl_zsh_autocomplete() {
+  local -a opts
+  local cur
+  cur=${words[-1]}
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} ${cur} --generate-bash-completion 2>/dev/null)}")
+  else
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  fi
+
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+
+  return
+}
+
+compdef _fleetctl_zsh_autocomplete fleetctl
+`
+
+// fishNameCompletion completes the names fetched from the server, which the
+// static completions generated by urfave/cli can't know about.
+const fishNameCompletion = `
+function __fish_fleetctl_names
+    eval (commandline -opc) --generate-bash-completion 2>/dev/null
+end
+complete -c fleetctl -n '__fish_seen_subcommand_from get; and __fish_seen_subcommand_from queries query q packs pack p' -f -a '(__fish_fleetctl_names)'
+complete -c fleetctl -n '__fish_seen_subcommand_from query' -l query-name -r -f -a '(__fish_fleetctl_names)'
+`
+
+// nameCompletion returns the completion of a command whose argument, or the
+// value of flagName if it is not empty, is a name fetched with names. Other
+// flags are completed as usual.
+func nameCompletion(flagName string, names func(*service.Client) ([]string, error)) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		// The shell passes the words before the one being completed,
+		// followed by the completion flag.
+		lastArg := ""
+		if len(os.Args) > 2 {
+			lastArg = os.Args[len(os.Args)-2]
+		}
+
+		switch {
+		case flagName != "" && (lastArg == "--"+flagName || lastArg == "-"+flagName):
+		case flagName == "" && !strings.HasPrefix(lastArg, "-") && c.NArg() == 0:
+		default:
+			cli.DefaultCompleteWithFlags(c.Command)(c)
+			return
+		}
+
+		// Completions can't report errors, the shell would show them as
+		// candidates.
+		client, err := clientFromCLI(c)
+		if err != nil {
+			return
+		}
+		list, err := names(client)
+		if err != nil {
+			return
+		}
+		for _, name := range list {
+			fmt.Fprintln(c.App.Writer, name)
+		}
+	}
+}
+
+func queryNames(client *service.Client) ([]string, error) {
+	queries, err := client.GetQueries()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(queries))
+	for _, query := range queries {
+		names = append(names, query.Name)
+	}
+	return names, nil
+}
+
+func packNames(client *service.Client) ([]string, error) {
+	packs, err := client.GetPacks()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(packs))
+	for _, pack := range packs {
+		names = append(names, pack.Name)
+	}
+	return names, nil
+}
+
+func completionCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "completion",
+		Usage: "Output the shell completion script for bash, zsh or fish",
+		UsageText: `fleetctl completion <bash|zsh|fish>
+
+   Load the completions in the current shell with:
+
+     bash: source <(fleetctl completion bash)
+     zsh:  source <(fleetctl completion zsh)
+     fish: fleetctl completion fish | source`,
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return errors.New("expected exactly one shell: bash, zsh or fish")
+			}
+			switch shell := c.Args().First(); shell {
+			case "bash":
+				log(c, bashCompletion)
+			case "zsh":
+				log(c, zshCompletion)
+			case "fish":
+				script, err := c.App.ToFishCompletion()
+				if err != nil {
+					return errors.Wrap(err, "generate fish completion")
+				}
+				log(c, script+fishNameCompletion)
+			default:
+				return errors.Errorf("unsupported shell %q, expected bash, zsh or fish", shell)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runCompletionForTest runs fleetctl like the shell scripts do, the names
+// being completed are found in os.Args.
+func runCompletionForTest(t *testing.T, args []string) string {
+	args = append(args, "--generate-bash-completion")
+	oldArgs := os.Args
+	os.Args = append([]string{"fleetctl"}, args...)
+	defer func() { os.Args = oldArgs }()
+	return runAppForTest(t, args)
+}
+
+func TestCompletionScripts(t *testing.T) {
+	assert.Contains(t, runAppForTest(t, []string{"completion", "bash"}), "complete -o bashdefault -o default -o nospace -F _fleetctl_bash_autocomplete fleetctl\n")
+	assert.Contains(t, runAppForTest(t, []string{"completion", "zsh"}), "compdef _fleetctl_zsh_autocomplete fleetctl\n")
+
+	fish := runAppForTest(t, []string{"completion", "fish"})
+	assert.Contains(t, fish, "-a 'query' -d 'Run a live query'")
+	assert.Contains(t, fish, "-l query-name -r -f -a '(__fish_fleetctl_names)'")
+
+	_, _, err := runConvertForTest(t, []string{"completion", "powershell"})
+	assert.EqualError(t, err, `unsupported shell "powershell", expected bash, zsh or fish`)
+	_, _, err = runConvertForTest(t, []string{"completion"})
+	assert.EqualError(t, err, "expected exactly one shell: bash, zsh or fish")
+}
+
+func TestCompletionNames(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.ListQueriesFunc = func(opt fleet.ListOptions) ([]*fleet.Query, error) {
+		return []*fleet.Query{{Name: "processes"}, {Name: "users"}}, nil
+	}
+	ds.GetPackSpecsFunc = func() ([]*fleet.PackSpec, error) {
+		return []*fleet.PackSpec{{Name: "compliance"}}, nil
+	}
+
+	assert.Equal(t, "processes\nusers\n", runCompletionForTest(t, []string{"get", "queries"}))
+	assert.Equal(t, "compliance\n", runCompletionForTest(t, []string{"get", "p"}))
+	assert.Equal(t, "processes\nusers\n", runCompletionForTest(t, []string{"query", "--hosts", "foo", "--query-name"}))
+
+	// Only the first argument is a name.
+	assert.Equal(t, "", runCompletionForTest(t, []string{"get", "queries", "processes"}))
+
+	// Flags are still completed.
+	out := runCompletionForTest(t, []string{"query", "--query-"})
+	assert.Equal(t, "--query-name\n", out)
+
+	// Without a server, there is nothing to complete rather than an error.
+	require.NoError(t, os.Setenv("FLEET_SERVER_ADDRESS", "https://127.0.0.1:1"))
+	defer os.Setenv("FLEET_SERVER_ADDRESS", server.URL)
+	assert.Equal(t, "", runCompletionForTest(t, []string{"get", "queries"}))
+}
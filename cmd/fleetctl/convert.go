@@ -1,13 +1,25 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/ghodss/yaml"
@@ -15,7 +27,7 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-func specGroupFromPack(name string, inputPack fleet.PermissivePackContent) (*specGroup, error) {
+func specGroupFromPack(report *convertReport, name string, inputPack fleet.PermissivePackContent) (*specGroup, error) {
 	specs := &specGroup{
 		Queries: []*fleet.QuerySpec{},
 		Packs:   []*fleet.PackSpec{},
@@ -30,135 +42,2107 @@ func specGroupFromPack(name string, inputPack fleet.PermissivePackContent) (*spe
 		spec := &fleet.QuerySpec{
 			Name:        name,
 			Description: query.Description,
+			Query:       formatQuery(query.Query),
+		}
+		if query.ObserverCanRun != nil {
+			spec.ObserverCanRun = *query.ObserverCanRun
+		}
+
+		interval := uint(0)
+		switch i := query.Interval.(type) {
+		case string:
+			u64, err := strconv.ParseUint(i, 10, 32)
+			if err != nil {
+				return nil, errors.Wrap(err, "converting interval from string to uint")
+			}
+			interval = uint(u64)
+		case uint:
+			interval = i
+		case float64:
+			interval = uint(i)
+		}
+
+		var value *string
+		switch v := query.Value.(type) {
+		case string:
+			value = &v
+		case float64:
+			s := strconv.FormatFloat(v, 'f', -1, 64)
+			value = &s
+		case nil:
+		default:
+			return nil, errors.Errorf("value of query %s must be a string or a number", name)
+		}
+
+		specs.Queries = append(specs.Queries, spec)
+		pack.Queries = append(pack.Queries, fleet.PackSpecQuery{
+			Name:               name,
+			QueryName:          name,
+			Interval:           interval,
+			Description:        query.Description,
+			Snapshot:           query.Snapshot,
+			Removed:            query.Removed,
+			Shard:              query.Shard,
+			Platform:           query.Platform,
+			Version:            query.Version,
+			LoggingDestination: query.LoggingDestination,
+			Value:              value,
+		})
+	}
+
+	// Map iteration order is random, sort so that output is stable between
+	// runs.
+	sort.Slice(specs.Queries, func(i, j int) bool {
+		return specs.Queries[i].Name < specs.Queries[j].Name
+	})
+	sort.Slice(pack.Queries, func(i, j int) bool {
+		return pack.Queries[i].Name < pack.Queries[j].Name
+	})
+
+	// Fleet has no discovery queries, target the pack at a label of the
+	// hosts where they return results instead.
+	if len(inputPack.Discovery) > 0 {
+		label := discoveryLabel(name, inputPack.Discovery)
+		report.warnf("pack %q: discovery queries converted to label %q, the pack targets the hosts of the label\n", name, label.Name)
+		specs.Labels = append(specs.Labels, label)
+		pack.Targets.Labels = []string{label.Name}
+	}
+
+	specs.Packs = append(specs.Packs, pack)
+
+	return specs, nil
+}
+
+// discoveryLabel returns the label of the hosts where all the discovery
+// queries of the pack return results, like osquery requires to run the pack.
+func discoveryLabel(pack string, discovery []string) *fleet.LabelSpec {
+	label := &fleet.LabelSpec{
+		Name:                pack + " discovery",
+		Description:         fmt.Sprintf("Hosts matching the discovery queries of the %s osquery pack", pack),
+		LabelMembershipType: fleet.LabelMembershipTypeDynamic,
+	}
+	if len(discovery) == 1 {
+		label.Query = formatQuery(discovery[0])
+		return label
+	}
+
+	conditions := make([]string, 0, len(discovery))
+	for _, query := range discovery {
+		query = strings.TrimSuffix(formatQuery(query), ";")
+		conditions = append(conditions, "EXISTS ("+query+")")
+	}
+	label.Query = "SELECT 1 WHERE " + strings.Join(conditions, " AND ") + ";"
+	return label
+}
+
+// formatQuery strips the trailing whitespace of every line of the query so
+// that multi-line queries are written as YAML block scalars, keeping their
+// indentation and comments readable instead of collapsing them into a single
+// quoted line.
+func formatQuery(query string) string {
+	lines := strings.Split(strings.TrimSpace(query), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// osqueryConfigSections holds the sections of a full osquery configuration
+// file that convert translates in addition to the pack format.
+type osqueryConfigSections struct {
+	Decorators *fleet.DecoratorConfig     `json:"decorators"`
+	Options    map[string]interface{}     `json:"options"`
+	FilePaths  map[string][]string        `json:"file_paths"`
+	Schedule   fleet.PermissiveQueries    `json:"schedule"`
+	Packs      map[string]json.RawMessage `json:"packs"`
+}
+
+// isConfig returns whether the sections come from an osquery configuration
+// rather than a pack.
+func (c osqueryConfigSections) isConfig() bool {
+	return c.Decorators != nil || c.Options != nil || c.FilePaths != nil || c.Schedule != nil || c.Packs != nil
+}
+
+// agentOptionsFromConfig builds a config spec holding the agent options
+// (options and file paths) found in the osquery configuration. Nil is returned
+// if the configuration has no agent options. The decorators are emitted as
+// their own spec.
+func agentOptionsFromConfig(config osqueryConfigSections) (*fleet.AppConfigPayload, error) {
+	agentConfig := make(map[string]interface{})
+	if config.Options != nil {
+		agentConfig["options"] = config.Options
+	}
+	if config.FilePaths != nil {
+		agentConfig["file_paths"] = config.FilePaths
+	}
+	if len(agentConfig) == 0 {
+		return nil, nil
+	}
+
+	agentOptions, err := json.Marshal(map[string]interface{}{
+		"config": agentConfig,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal agent options")
+	}
+	raw := json.RawMessage(agentOptions)
+	return &fleet.AppConfigPayload{AgentOptions: &raw}, nil
+}
+
+// osqueryPlatforms are the platform values understood by osquery in the
+// platform field of scheduled queries.
+var osqueryPlatforms = map[string]bool{
+	"darwin":  true,
+	"linux":   true,
+	"windows": true,
+	"freebsd": true,
+	"posix":   true,
+	"any":     true,
+	"all":     true,
+}
+
+// osqueryPlatformShorthands are the platform values standing for several
+// explicit platforms. any and all are not expanded, as they don't restrict the
+// platform at all.
+var osqueryPlatformShorthands = map[string][]string{
+	"posix": {"darwin", "linux", "freebsd"},
+}
+
+// expandPlatform rewrites the shorthand tokens of the comma separated platform
+// string into their explicit platforms, removing duplicates.
+func expandPlatform(platform string) string {
+	var expanded []string
+	seen := make(map[string]bool)
+	for _, p := range strings.Split(platform, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		platforms, ok := osqueryPlatformShorthands[p]
+		if !ok {
+			platforms = []string{p}
+		}
+		for _, p := range platforms {
+			if !seen[p] {
+				seen[p] = true
+				expanded = append(expanded, p)
+			}
+		}
+	}
+	return strings.Join(expanded, ",")
+}
+
+// expandPlatforms expands the shorthand platforms of every pack query.
+func expandPlatforms(specs *specGroup) {
+	for _, pack := range specs.Packs {
+		for i, query := range pack.Queries {
+			if query.Platform == nil {
+				continue
+			}
+			platform := expandPlatform(*query.Platform)
+			pack.Queries[i].Platform = &platform
+		}
+	}
+}
+
+// platformIncludes returns whether a query with the comma separated platform
+// string runs on the target platform. Queries without a platform, or with the
+// any and all platforms, run everywhere.
+func platformIncludes(platform, target string) bool {
+	expanded := expandPlatform(platform)
+	if expanded == "" {
+		return true
+	}
+	for _, p := range strings.Split(expanded, ",") {
+		if p == target || p == "any" || p == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTargetPlatform removes the pack queries that don't run on the target
+// platform, along with the query specs no remaining pack query schedules.
+// Packs are kept even if none of their queries remain.
+func filterTargetPlatform(specs *specGroup, target string) {
+	dropped := make(map[string]bool)
+	for _, pack := range specs.Packs {
+		queries := pack.Queries[:0]
+		for _, query := range pack.Queries {
+			if query.Platform != nil && !platformIncludes(*query.Platform, target) {
+				dropped[query.QueryName] = true
+				continue
+			}
+			queries = append(queries, query)
+		}
+		pack.Queries = queries
+	}
+
+	for _, pack := range specs.Packs {
+		for _, query := range pack.Queries {
+			delete(dropped, query.QueryName)
+		}
+	}
+	queries := specs.Queries[:0]
+	for _, query := range specs.Queries {
+		if !dropped[query.Name] {
+			queries = append(queries, query)
+		}
+	}
+	specs.Queries = queries
+}
+
+// unknownPlatforms returns the tokens of the comma separated platform string
+// that are not valid osquery platforms.
+func unknownPlatforms(platform string) []string {
+	var unknown []string
+	for _, p := range strings.Split(platform, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !osqueryPlatforms[p] {
+			unknown = append(unknown, p)
+		}
+	}
+	return unknown
+}
+
+// validatePlatforms warns about (or in strict mode, fails on) pack queries
+// whose platform osquery doesn't know about, as those queries never run.
+func validatePlatforms(report *convertReport, specs *specGroup, strict bool) error {
+	for _, pack := range specs.Packs {
+		for _, query := range pack.Queries {
+			if query.Platform == nil {
+				continue
+			}
+			unknown := unknownPlatforms(*query.Platform)
+			if len(unknown) == 0 {
+				continue
+			}
+			if strict {
+				return errors.Errorf("query %q in pack %q has unknown platform %q", query.Name, pack.Name, strings.Join(unknown, ","))
+			}
+			report.warnf("query %q in pack %q has unknown platform %q\n", query.Name, pack.Name, strings.Join(unknown, ","))
+		}
+	}
+	return nil
+}
+
+// warnDuplicateSQL warns about differently named queries that have identical
+// SQL.
+func warnDuplicateSQL(report *convertReport, specs *specGroup) {
+	names := make(map[[sha256.Size]byte][]string)
+	var hashes [][sha256.Size]byte
+	for _, query := range specs.Queries {
+		hash := sha256.Sum256([]byte(query.Query))
+		if _, ok := names[hash]; !ok {
+			hashes = append(hashes, hash)
+		}
+		names[hash] = append(names[hash], query.Name)
+	}
+
+	for _, hash := range hashes {
+		if len(names[hash]) < 2 {
+			continue
+		}
+		quoted := make([]string, 0, len(names[hash]))
+		for _, name := range names[hash] {
+			quoted = append(quoted, strconv.Quote(name))
+		}
+		report.warnf("queries %s have identical SQL\n", strings.Join(quoted, ", "))
+	}
+}
+
+// convertReport collects what happened during a conversion, for the
+// statistics file.
+type convertReport struct {
+	w io.Writer
+
+	Packs         int                   `json:"packs"`
+	Queries       int                   `json:"queries"`
+	Labels        int                   `json:"labels"`
+	Warnings      []string              `json:"warnings"`
+	DroppedFields []convertDroppedField `json:"dropped_fields"`
+	Renames       []convertRename       `json:"renames"`
+}
+
+// convertDroppedField is a field removed from a spec for compatibility.
+type convertDroppedField struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+}
+
+// convertRename is a query renamed to avoid a name collision.
+type convertRename struct {
+	Pack string `json:"pack"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func newConvertReport(w io.Writer) *convertReport {
+	return &convertReport{
+		w:             w,
+		Warnings:      []string{},
+		DroppedFields: []convertDroppedField{},
+		Renames:       []convertRename{},
+	}
+}
+
+// warnf writes a conversion warning to stderr so that it doesn't mix with the
+// converted specs written to stdout.
+func (r *convertReport) warnf(format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	fmt.Fprint(r.w, "[!] "+msg)
+	r.Warnings = append(r.Warnings, strings.TrimSuffix(msg, "\n"))
+}
+
+// writeStats writes the report with the counts of the converted specs as
+// JSON to the file.
+func (r *convertReport) writeStats(filename string, specs *specGroup) error {
+	r.Packs = len(specs.Packs)
+	r.Queries = len(specs.Queries)
+	r.Labels = len(specs.Labels)
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshal stats")
+	}
+	if err := ioutil.WriteFile(filename, append(b, '\n'), defaultFileMode); err != nil {
+		return errors.Wrap(err, "write stats")
+	}
+	return nil
+}
+
+// mitreTechniques returns the MITRE ATT&CK technique IDs of the tag value,
+// either a list of IDs or a comma separated string of IDs.
+func mitreTechniques(tag interface{}) []string {
+	var values []string
+	switch v := tag.(type) {
+	case string:
+		values = strings.Split(v, ",")
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var techniques []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			techniques = append(techniques, value)
+		}
+	}
+	return techniques
+}
+
+// writeMitreReport writes the number of queries tagged with each MITRE
+// ATT&CK technique in the annotation field, followed by the number of
+// queries without technique.
+func writeMitreReport(w io.Writer, specs *specGroup, field string) {
+	counts := make(map[string]int)
+	untagged := 0
+	for _, query := range specs.Queries {
+		techniques := mitreTechniques(query.Annotations[field])
+		if len(techniques) == 0 {
+			untagged++
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, technique := range techniques {
+			if !seen[technique] {
+				seen[technique] = true
+				counts[technique]++
+			}
+		}
+	}
+
+	techniques := make([]string, 0, len(counts))
+	for technique := range counts {
+		techniques = append(techniques, technique)
+	}
+	sort.Strings(techniques)
+
+	queries := func(n int) string {
+		if n == 1 {
+			return "1 query"
+		}
+		return fmt.Sprintf("%d queries", n)
+	}
+	fmt.Fprintln(w, "MITRE ATT&CK coverage:")
+	for _, technique := range techniques {
+		fmt.Fprintf(w, "  %s: %s\n", technique, queries(counts[technique]))
+	}
+	fmt.Fprintf(w, "  untagged: %s\n", queries(untagged))
+}
+
+// removeAnnotation removes the annotation field from every query spec.
+func removeAnnotation(specs *specGroup, field string) {
+	for _, query := range specs.Queries {
+		delete(query.Annotations, field)
+		if len(query.Annotations) == 0 {
+			query.Annotations = nil
+		}
+	}
+}
+
+// annotateProvenance records who converted the query specs and when in
+// their annotations.
+func annotateProvenance(specs *specGroup, author string, convertedAt time.Time) {
+	for _, query := range specs.Queries {
+		if query.Annotations == nil {
+			query.Annotations = make(map[string]interface{})
+		}
+		query.Annotations["author"] = author
+		query.Annotations["created_at"] = convertedAt.UTC().Format(time.RFC3339)
+	}
+}
+
+// currentUsername returns the name of the user running fleetctl.
+func currentUsername() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// intervalUnits are the units in which bare numeric intervals can be
+// interpreted, as their number of seconds.
+var intervalUnits = map[string]uint{
+	"seconds": 1,
+	"minutes": 60,
+}
+
+// scaleIntervals multiplies every pack query interval by the number of
+// seconds in the unit the intervals were written in.
+func scaleIntervals(specs *specGroup, seconds uint) {
+	if seconds == 1 {
+		return
+	}
+	for _, pack := range specs.Packs {
+		for i := range pack.Queries {
+			pack.Queries[i].Interval *= seconds
+		}
+	}
+}
+
+// roundIntervals rounds every nonzero pack query interval up to the nearest
+// multiple of the provided value. Zero intervals are left untouched.
+func roundIntervals(specs *specGroup, multiple uint) {
+	if multiple == 0 {
+		return
+	}
+	for _, pack := range specs.Packs {
+		for i, query := range pack.Queries {
+			if query.Interval == 0 || query.Interval%multiple == 0 {
+				continue
+			}
+			pack.Queries[i].Interval = (query.Interval/multiple + 1) * multiple
+		}
+	}
+}
+
+// specDiff lists the changes applying the converted specs would make to the
+// specs currently on the server, as human-readable lines.
+type specDiff []string
+
+func (d *specDiff) add(indent, op, kind, name string) {
+	*d = append(*d, fmt.Sprintf("%s%s %s %q", indent, op, kind, name))
+}
+
+// normalizePackSpec returns a copy of the pack without the fields that are
+// assigned by the server, and with empty target lists as nil, so that specs
+// from the server compare equal to the converted ones.
+func normalizePackSpec(pack fleet.PackSpec) fleet.PackSpec {
+	pack.ID = 0
+	pack.Queries = nil
+	if len(pack.Targets.Labels) == 0 {
+		pack.Targets.Labels = nil
+	}
+	if len(pack.Targets.Teams) == 0 {
+		pack.Targets.Teams = nil
+	}
+	return pack
+}
+
+// normalizeQuerySpec returns a copy of the query without the annotations,
+// which are not stored by the server.
+func normalizeQuerySpec(query fleet.QuerySpec) fleet.QuerySpec {
+	query.Annotations = nil
+	return query
+}
+
+// diffSpecs compares the converted specs with the existing ones. Existing
+// specs that are not part of the conversion are not reported, as applying
+// doesn't remove them. Queries removed from a converted pack are.
+func diffSpecs(existing, converted *specGroup) specDiff {
+	var diff specDiff
+
+	existingQueries := make(map[string]*fleet.QuerySpec)
+	for _, query := range existing.Queries {
+		existingQueries[query.Name] = query
+	}
+	for _, query := range converted.Queries {
+		current, ok := existingQueries[query.Name]
+		switch {
+		case !ok:
+			diff.add("", "+", "query", query.Name)
+		case !reflect.DeepEqual(*current, normalizeQuerySpec(*query)):
+			diff.add("", "~", "query", query.Name)
+		}
+	}
+
+	existingPacks := make(map[string]*fleet.PackSpec)
+	for _, pack := range existing.Packs {
+		existingPacks[pack.Name] = pack
+	}
+	for _, pack := range converted.Packs {
+		current, ok := existingPacks[pack.Name]
+		if !ok {
+			diff.add("", "+", "pack", pack.Name)
+			continue
+		}
+
+		var queriesDiff specDiff
+		currentQueries := make(map[string]fleet.PackSpecQuery)
+		for _, query := range current.Queries {
+			currentQueries[query.Name] = query
+		}
+		for _, query := range pack.Queries {
+			currentQuery, ok := currentQueries[query.Name]
+			switch {
+			case !ok:
+				queriesDiff.add("    ", "+", "query", query.Name)
+			case !reflect.DeepEqual(fleet.NormalizedPackSpecQuery(currentQuery), fleet.NormalizedPackSpecQuery(query)):
+				queriesDiff.add("    ", "~", "query", query.Name)
+			}
+			delete(currentQueries, query.Name)
+		}
+		var removed []string
+		for name := range currentQueries {
+			removed = append(removed, name)
+		}
+		sort.Strings(removed)
+		for _, name := range removed {
+			queriesDiff.add("    ", "-", "query", name)
+		}
+
+		if len(queriesDiff) > 0 || !reflect.DeepEqual(normalizePackSpec(*current), normalizePackSpec(*pack)) {
+			diff.add("", "~", "pack", pack.Name)
+			diff = append(diff, queriesDiff...)
+		}
+	}
+
+	return diff
+}
+
+// existingSpecs fetches the queries and packs currently on the server.
+func existingSpecs(c *cli.Context) (*specGroup, error) {
+	fleet, err := clientFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+
+	queries, err := fleet.GetQueries()
+	if err != nil {
+		return nil, err
+	}
+	packs, err := fleet.GetPacks()
+	if err != nil {
+		return nil, err
+	}
+
+	return &specGroup{Queries: queries, Packs: packs}, nil
+}
+
+// packNameFromPath returns the name of the pack stored at the path, which is
+// the file name without extension.
+func packNameFromPath(name string) string {
+	base := path.Base(filepath.ToSlash(name))
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// specGroupFromFile converts the contents of an osquery pack or configuration
+// file, using name as the name of the pack. The scheduled queries of a
+// configuration are converted as the pack named after the file, and each of
+// its inline packs as its own pack. The custom query fields listed in
+// preserve are kept as annotations of the query specs.
+func specGroupFromFile(report *convertReport, name string, b []byte, preserve []string) (*specGroup, error) {
+	// Remove any literal newlines (because they are not
+	// valid JSON but osquery accepts them) and replace
+	// with \n so that we get them in the YAML output where
+	// they are allowed. Only the whitespace ending the line
+	// is dropped, the indentation of the next line is kept.
+	re := regexp.MustCompile(`[ \t]*\\\r?\n`)
+	b = re.ReplaceAll(b, []byte(`\n`))
+
+	var pack fleet.PermissivePackContent
+	if err := json.Unmarshal(b, &pack); err != nil {
+		return nil, err
+	}
+
+	var config osqueryConfigSections
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, err
+	}
+
+	for queryName, query := range config.Schedule {
+		if _, ok := pack.Queries[queryName]; ok {
+			return nil, errors.Errorf("query %s is both in queries and schedule", queryName)
+		}
+		if pack.Queries == nil {
+			pack.Queries = make(fleet.PermissiveQueries)
+		}
+		pack.Queries[queryName] = query
+	}
+
+	var specs *specGroup
+	if len(pack.Queries) == 0 && config.isConfig() {
+		// A configuration without scheduled queries, don't emit an empty
+		// pack.
+		specs = &specGroup{}
+	} else {
+		var err error
+		specs, err = specGroupFromPack(report, name, pack)
+		if err != nil {
+			return nil, err
+		}
+		if err := preserveQueryFields(specs, b, preserve); err != nil {
+			return nil, err
+		}
+	}
+
+	groups := []*specGroup{specs}
+	packNames := make([]string, 0, len(config.Packs))
+	for packName := range config.Packs {
+		packNames = append(packNames, packName)
+	}
+	sort.Strings(packNames)
+	for _, packName := range packNames {
+		raw := config.Packs[packName]
+		var packPath string
+		if err := json.Unmarshal(raw, &packPath); err == nil {
+			report.warnf("skipping pack %q, its queries are in %s, convert that file too\n", packName, packPath)
+			continue
+		}
+
+		var content fleet.PermissivePackContent
+		if err := json.Unmarshal(raw, &content); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal pack %s", packName)
+		}
+		packSpecs, err := specGroupFromPack(report, packName, content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "convert pack %s", packName)
+		}
+		if err := preserveQueryFields(packSpecs, raw, preserve); err != nil {
+			return nil, err
+		}
+		groups = append(groups, packSpecs)
+	}
+
+	specs, err := mergeSpecGroups(report, groups)
+	if err != nil {
+		return nil, err
+	}
+	specs.AppConfig, err = agentOptionsFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	specs.Decorators = config.Decorators
+
+	return specs, nil
+}
+
+// preserveQueryFields copies the listed fields of the queries (or scheduled
+// queries) in the pack contents b into the annotations of the matching query
+// specs. Other custom fields are dropped.
+func preserveQueryFields(specs *specGroup, b []byte, preserve []string) error {
+	if len(preserve) == 0 {
+		return nil
+	}
+
+	var raw struct {
+		Queries  map[string]map[string]json.RawMessage `json:"queries"`
+		Schedule map[string]map[string]json.RawMessage `json:"schedule"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	for _, query := range specs.Queries {
+		fields, ok := raw.Queries[query.Name]
+		if !ok {
+			fields = raw.Schedule[query.Name]
+		}
+		for _, key := range preserve {
+			value, ok := fields[key]
+			if !ok {
+				continue
+			}
+			var v interface{}
+			if err := json.Unmarshal(value, &v); err != nil {
+				return errors.Wrapf(err, "unmarshal field %s of query %s", key, query.Name)
+			}
+			if query.Annotations == nil {
+				query.Annotations = make(map[string]interface{})
+			}
+			query.Annotations[key] = v
+		}
+	}
+	return nil
+}
+
+// specGroupFromZip converts every JSON file in the ZIP archive as a pack
+// named after the file. Other files are skipped with a warning.
+func specGroupFromZip(report *convertReport, filename string, preserve []string) (*specGroup, error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "open zip archive")
+	}
+	defer r.Close()
+
+	var groups []*specGroup
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !strings.EqualFold(path.Ext(f.Name), ".json") {
+			report.warnf("skipping %q in archive, not a JSON file\n", f.Name)
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "open %s in archive", f.Name)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s in archive", f.Name)
+		}
+
+		fileSpecs, err := specGroupFromFile(report, packNameFromPath(f.Name), b, preserve)
+		if err != nil {
+			return nil, errors.Wrapf(err, "convert %s in archive", f.Name)
+		}
+		groups = append(groups, fileSpecs)
+	}
+
+	return mergeSpecGroups(report, groups)
+}
+
+// convertInputs returns the files to convert for the -f values, expanding
+// glob patterns and replacing directories by the JSON and ZIP files they
+// contain. Following the layout of the osquery packs directory, the .conf
+// files of a directory are converted as packs too, except for the main
+// osquery.conf configuration. Files matched several times are converted once.
+func convertInputs(values []string) ([]string, error) {
+	var filenames []string
+	seen := make(map[string]bool)
+	add := func(filename string) {
+		key := filepath.Clean(filename)
+		if !seen[key] {
+			seen[key] = true
+			filenames = append(filenames, filename)
+		}
+	}
+
+	for _, value := range values {
+		matches := []string{value}
+		if strings.ContainsAny(value, "*?[") {
+			var err error
+			matches, err = filepath.Glob(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid pattern %s", value)
+			}
+			if len(matches) == 0 {
+				return nil, errors.Errorf("no files match %s", value)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			entries, err := ioutil.ReadDir(match)
+			if err != nil {
+				return nil, errors.Wrapf(err, "read directory %s", match)
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || strings.EqualFold(entry.Name(), "osquery.conf") {
+					continue
+				}
+				switch strings.ToLower(filepath.Ext(entry.Name())) {
+				case ".json", ".zip", ".conf":
+				default:
+					continue
+				}
+				add(filepath.Join(match, entry.Name()))
+			}
+		}
+	}
+	return filenames, nil
+}
+
+// mergeSpecGroups combines the specs converted from several files, keeping
+// one pack per file. Queries are global, so a query with the same name and
+// SQL in several packs is kept once, and a query with the same name but a
+// different SQL is renamed after its pack to not replace the other one.
+func mergeSpecGroups(report *convertReport, groups []*specGroup) (*specGroup, error) {
+	specs := &specGroup{
+		Queries: []*fleet.QuerySpec{},
+		Packs:   []*fleet.PackSpec{},
+		Labels:  []*fleet.LabelSpec{},
+	}
+
+	queries := make(map[string]*fleet.QuerySpec)
+	taken := make(map[string]bool)
+	packs := make(map[string]bool)
+	for _, group := range groups {
+		var source string
+		if len(group.Packs) > 0 {
+			source = group.Packs[0].Name
+		}
+
+		renames := make(map[string]string)
+		for _, query := range group.Queries {
+			if existing, ok := queries[query.Name]; ok {
+				if existing.Query == query.Query {
+					continue
+				}
+				renamed := disambiguateQueryName(query.Name, source, taken)
+				report.warnf("renaming query %q from %q to %q, a query with the same name and a different SQL is already defined\n", query.Name, source, renamed)
+				report.Renames = append(report.Renames, convertRename{Pack: source, From: query.Name, To: renamed})
+				renames[query.Name] = renamed
+				query.Name = renamed
+			}
+			queries[query.Name] = query
+			taken[query.Name] = true
+			specs.Queries = append(specs.Queries, query)
+		}
+
+		specs.Labels = append(specs.Labels, group.Labels...)
+
+		for _, pack := range group.Packs {
+			if packs[pack.Name] {
+				return nil, errors.Errorf("pack %q is defined twice, rename one of its files or use --merge-as", pack.Name)
+			}
+			packs[pack.Name] = true
+			for i, query := range pack.Queries {
+				if renamed, ok := renames[query.QueryName]; ok {
+					pack.Queries[i].QueryName = renamed
+				}
+			}
+			specs.Packs = append(specs.Packs, pack)
+		}
+
+		mergeAppConfig(report, specs, group)
+		mergeDecorators(report, specs, group)
+	}
+	return specs, nil
+}
+
+// mergeSpecGroupsAsPack combines the specs converted from several files into
+// a single pack with the provided name. Queries with the same name in
+// different files are disambiguated.
+func mergeSpecGroupsAsPack(report *convertReport, name string, groups []*specGroup) *specGroup {
+	pack := &fleet.PackSpec{Name: name}
+	specs := &specGroup{
+		Queries: []*fleet.QuerySpec{},
+		Packs:   []*fleet.PackSpec{pack},
+		Labels:  []*fleet.LabelSpec{},
+	}
+
+	taken := make(map[string]bool)
+	for _, group := range groups {
+		var source string
+		if len(group.Packs) > 0 {
+			source = group.Packs[0].Name
+		}
+
+		renames := make(map[string]string)
+		for _, query := range group.Queries {
+			if taken[query.Name] {
+				renamed := disambiguateQueryName(query.Name, source, taken)
+				report.warnf("renaming query %q from %q to %q, the name is already used\n", query.Name, source, renamed)
+				report.Renames = append(report.Renames, convertRename{Pack: source, From: query.Name, To: renamed})
+				renames[query.Name] = renamed
+				query.Name = renamed
+			}
+			taken[query.Name] = true
+			specs.Queries = append(specs.Queries, query)
+		}
+
+		specs.Labels = append(specs.Labels, group.Labels...)
+
+		for _, groupPack := range group.Packs {
+			if len(groupPack.Targets.Labels) > 0 {
+				report.warnf("pack %q targets labels, the merged pack targets the hosts of any of them\n", groupPack.Name)
+				pack.Targets.Labels = append(pack.Targets.Labels, groupPack.Targets.Labels...)
+			}
+			for _, query := range groupPack.Queries {
+				if renamed, ok := renames[query.QueryName]; ok {
+					query.QueryName = renamed
+					query.Name = renamed
+				}
+				pack.Queries = append(pack.Queries, query)
+			}
+		}
+
+		mergeAppConfig(report, specs, group)
+		mergeDecorators(report, specs, group)
+	}
+	return specs
+}
+
+// disambiguateQueryName returns a name for the query that is not taken, based
+// on the name of the pack it comes from.
+func disambiguateQueryName(name, pack string, taken map[string]bool) string {
+	candidate := name + "_" + pack
+	for i := 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%s_%d", name, pack, i)
+	}
+	return candidate
+}
+
+// mergeAppConfig sets the agent options of the specs to those of the group,
+// if any, warning when they replace existing ones.
+func mergeAppConfig(report *convertReport, specs, group *specGroup) {
+	if group.AppConfig == nil {
+		return
+	}
+	if specs.AppConfig != nil {
+		report.warnf("replacing agent options with the ones from a later file\n")
+	}
+	specs.AppConfig = group.AppConfig
+}
+
+// mergeDecorators sets the decorators of the specs to those of the group, if
+// any, warning when they replace existing ones.
+func mergeDecorators(report *convertReport, specs, group *specGroup) {
+	if group.Decorators == nil {
+		return
+	}
+	if specs.Decorators != nil {
+		report.warnf("replacing decorators with the ones from a later file\n")
+	}
+	specs.Decorators = group.Decorators
+}
+
+// fleetVersion is a parsed major.minor.patch Fleet version.
+type fleetVersion [3]int
+
+// parseFleetVersion parses versions like 4.0.1, omitted components are zero
+// and a leading v is allowed.
+func parseFleetVersion(s string) (fleetVersion, error) {
+	var v fleetVersion
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) > len(v) {
+		return v, errors.Errorf("invalid version %q", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, errors.Errorf("invalid version %q", s)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+func (v fleetVersion) less(other fleetVersion) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return false
+}
+
+func (v fleetVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// compatOutput is the output of convert downgraded for an older Fleet
+// version: the specs and the options changing how their documents are
+// written.
+type compatOutput struct {
+	specs       *specGroup
+	contentHash bool
+}
+
+// compatField is a spec field emitted by convert that is not understood by
+// Fleet versions older than the one introducing it.
+type compatField struct {
+	name    string
+	version fleetVersion
+	// strip removes the field from the output, returning a description of
+	// each spec it was removed from.
+	strip func(out *compatOutput) []string
+}
+
+var compatFields = []compatField{
+	{
+		name:    "logging_destination",
+		version: fleetVersion{4, 1, 0},
+		strip: func(out *compatOutput) []string {
+			var removed []string
+			for _, pack := range out.specs.Packs {
+				for i, query := range pack.Queries {
+					if query.LoggingDestination != nil {
+						pack.Queries[i].LoggingDestination = nil
+						removed = append(removed, fmt.Sprintf("query %q in pack %q", query.Name, pack.Name))
+					}
+				}
+			}
+			return removed
+		},
+	},
+	{
+		name:    "value",
+		version: fleetVersion{4, 1, 0},
+		strip: func(out *compatOutput) []string {
+			var removed []string
+			for _, pack := range out.specs.Packs {
+				for i, query := range pack.Queries {
+					if query.Value != nil {
+						pack.Queries[i].Value = nil
+						removed = append(removed, fmt.Sprintf("query %q in pack %q", query.Name, pack.Name))
+					}
+				}
+			}
+			return removed
+		},
+	},
+	{
+		name:    "targets.teams",
+		version: fleetVersion{4, 1, 0},
+		strip: func(out *compatOutput) []string {
+			var removed []string
+			for _, pack := range out.specs.Packs {
+				if len(pack.Targets.Teams) > 0 {
+					pack.Targets.Teams = nil
+					removed = append(removed, fmt.Sprintf("pack %q", pack.Name))
+				}
+			}
+			return removed
+		},
+	},
+	{
+		name:    "observer_can_run",
+		version: fleetVersion{4, 1, 0},
+		strip: func(out *compatOutput) []string {
+			var removed []string
+			for _, query := range out.specs.Queries {
+				if query.ObserverCanRun {
+					query.ObserverCanRun = false
+					removed = append(removed, fmt.Sprintf("query %q", query.Name))
+				}
+			}
+			return removed
+		},
+	},
+	{
+		name:    "annotations",
+		version: fleetVersion{4, 1, 0},
+		strip: func(out *compatOutput) []string {
+			var removed []string
+			for _, query := range out.specs.Queries {
+				if len(query.Annotations) > 0 {
+					query.Annotations = nil
+					removed = append(removed, fmt.Sprintf("query %q", query.Name))
+				}
+			}
+			return removed
+		},
+	},
+	{
+		name:    "metadata.content_hash",
+		version: fleetVersion{4, 1, 0},
+		strip: func(out *compatOutput) []string {
+			if !out.contentHash {
+				return nil
+			}
+			out.contentHash = false
+			return []string{"every spec"}
+		},
+	},
+	{
+		// Older versions only read the decorators from the agent options
+		// of the config.
+		name:    "kind decorators",
+		version: fleetVersion{4, 1, 0},
+		strip: func(out *compatOutput) []string {
+			if out.specs.Decorators == nil {
+				return nil
+			}
+			decorators := out.specs.Decorators
+			out.specs.Decorators = nil
+			if out.specs.AppConfig == nil {
+				return []string{"the decorators spec"}
+			}
+			if err := setAgentOptionsDecorators(out.specs.AppConfig, decorators); err != nil {
+				return []string{"the decorators spec"}
+			}
+			return []string{"the decorators spec, moved to the agent options of the config spec"}
+		},
+	},
+	{
+		// The labels are converted from the discovery queries of the packs,
+		// which target them.
+		name:    "kind label",
+		version: fleetVersion{4, 1, 0},
+		strip: func(out *compatOutput) []string {
+			var removed []string
+			labels := make(map[string]bool)
+			for _, label := range out.specs.Labels {
+				labels[label.Name] = true
+				removed = append(removed, fmt.Sprintf("label %q", label.Name))
+			}
+			out.specs.Labels = nil
+			for _, pack := range out.specs.Packs {
+				var targets []string
+				for _, label := range pack.Targets.Labels {
+					if labels[label] {
+						removed = append(removed, fmt.Sprintf("the targets of pack %q", pack.Name))
+						continue
+					}
+					targets = append(targets, label)
+				}
+				pack.Targets.Labels = targets
+			}
+			return removed
+		},
+	},
+}
+
+// downgradeSpecs strips the fields that the target Fleet version doesn't
+// understand from the output, warning about each removal.
+func downgradeSpecs(report *convertReport, out *compatOutput, target fleetVersion) {
+	for _, field := range compatFields {
+		if !target.less(field.version) {
+			continue
+		}
+		for _, removed := range field.strip(out) {
+			report.warnf("removing %s from %s, it requires Fleet %s\n", field.name, removed, field.version)
+			report.DroppedFields = append(report.DroppedFields, convertDroppedField{Field: field.name, From: removed})
+		}
+	}
+}
+
+// convertedFile is a single spec document produced by convert, along with the
+// relative path it is written to when the output is decomposed into files.
+type convertedFile struct {
+	Path     string
+	Contents []byte
+}
+
+// terraformQuery is the fleet_query resource of the Terraform provider.
+type terraformQuery struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Query       string `json:"query"`
+}
+
+// terraformPackQuery is a query block of the fleet_pack resource.
+type terraformPackQuery struct {
+	Query    string  `json:"query"`
+	Name     string  `json:"name"`
+	Interval uint    `json:"interval"`
+	Platform *string `json:"platform,omitempty"`
+	Version  *string `json:"version,omitempty"`
+	Snapshot *bool   `json:"snapshot,omitempty"`
+	Removed  *bool   `json:"removed,omitempty"`
+	Shard    *uint   `json:"shard,omitempty"`
+}
+
+// terraformPack is the fleet_pack resource of the Terraform provider.
+type terraformPack struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Disabled    bool                 `json:"disabled,omitempty"`
+	Labels      []string             `json:"labels,omitempty"`
+	Teams       []string             `json:"teams,omitempty"`
+	Queries     []terraformPackQuery `json:"query,omitempty"`
+}
+
+var terraformNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// terraformResourceName returns a unique Terraform resource name for the
+// spec name, recording it in taken.
+func terraformResourceName(name string, taken map[string]bool) string {
+	base := terraformNameInvalid.ReplaceAllString(name, "_")
+	if base == "" || (base[0] >= '0' && base[0] <= '9') || base[0] == '-' {
+		base = "_" + base
+	}
+	resourceName := base
+	for i := 2; taken[resourceName]; i++ {
+		resourceName = fmt.Sprintf("%s_%d", base, i)
+	}
+	taken[resourceName] = true
+	return resourceName
+}
+
+// terraformJSON renders the queries and packs as resources of the Terraform
+// provider, in the JSON configuration syntax. Pack queries reference the
+// query resources so that Terraform creates the queries first.
+func terraformJSON(specs *specGroup) ([]byte, error) {
+	queryNames := make(map[string]string)
+	taken := make(map[string]bool)
+	queries := make(map[string]terraformQuery)
+	for _, query := range specs.Queries {
+		resourceName := terraformResourceName(query.Name, taken)
+		queryNames[query.Name] = resourceName
+		queries[resourceName] = terraformQuery{
+			Name:        query.Name,
+			Description: query.Description,
 			Query:       query.Query,
 		}
+	}
 
-		interval := uint(0)
-		switch i := query.Interval.(type) {
-		case string:
-			u64, err := strconv.ParseUint(i, 10, 32)
-			if err != nil {
-				return nil, errors.Wrap(err, "converting interval from string to uint")
+	taken = make(map[string]bool)
+	packs := make(map[string]terraformPack)
+	for _, pack := range specs.Packs {
+		resource := terraformPack{
+			Name:        pack.Name,
+			Description: pack.Description,
+			Disabled:    pack.Disabled,
+			Labels:      pack.Targets.Labels,
+			Teams:       pack.Targets.Teams,
+		}
+		for _, query := range pack.Queries {
+			queryRef := query.QueryName
+			if resourceName, ok := queryNames[query.QueryName]; ok {
+				queryRef = fmt.Sprintf("${fleet_query.%s.name}", resourceName)
 			}
-			interval = uint(u64)
-		case uint:
-			interval = i
-		case float64:
-			interval = uint(i)
+			resource.Queries = append(resource.Queries, terraformPackQuery{
+				Query:    queryRef,
+				Name:     query.Name,
+				Interval: query.Interval,
+				Platform: query.Platform,
+				Version:  query.Version,
+				Snapshot: query.Snapshot,
+				Removed:  query.Removed,
+				Shard:    query.Shard,
+			})
 		}
+		packs[terraformResourceName(pack.Name, taken)] = resource
+	}
 
-		specs.Queries = append(specs.Queries, spec)
-		pack.Queries = append(pack.Queries, fleet.PackSpecQuery{
-			Name:        name,
-			QueryName:   name,
-			Interval:    interval,
-			Description: query.Description,
-			Snapshot:    query.Snapshot,
-			Removed:     query.Removed,
-			Shard:       query.Shard,
-			Platform:    query.Platform,
-			Version:     query.Version,
+	resources := make(map[string]interface{})
+	if len(queries) > 0 {
+		resources["fleet_query"] = queries
+	}
+	if len(packs) > 0 {
+		resources["fleet_pack"] = packs
+	}
+	b, err := json.MarshalIndent(map[string]interface{}{"resource": resources}, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal terraform resources")
+	}
+	return append(b, '\n'), nil
+}
+
+// specFileName returns a name that is safe to use as a single path element.
+func specFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\':
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "_"
+	}
+	return name + ".yml"
+}
+
+// specContentHash returns the hex encoded SHA-256 of the JSON encoding of the
+// spec, which is stable as long as the spec fields don't change.
+func specContentHash(spec interface{}) (string, error) {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+}
+
+func marshalSpecDocument(kind string, spec interface{}, metadata map[string]string) ([]byte, error) {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := specMetadata{
+		Kind:     kind,
+		Version:  fleet.ApiVersion,
+		Spec:     b,
+		Metadata: metadata,
+	}
+
+	out, err := yaml.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte("---\n"), out...), nil
+}
+
+// convertedFiles renders every spec in the group as its own YAML document.
+// The config and decorators come first, then labels, queries and finally
+// packs, so that the labels targeted and the queries scheduled by a pack exist
+// by the time it is applied when the documents are applied in order.
+func convertedFiles(specs *specGroup, contentHash bool) ([]convertedFile, error) {
+	// The content hash is computed over the spec fields that don't vary
+	// between conversions of the same input.
+	metadata := func(hashed interface{}) (map[string]string, error) {
+		if !contentHash {
+			return nil, nil
+		}
+		hash, err := specContentHash(hashed)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"content_hash": hash}, nil
+	}
+
+	var files []convertedFile
+	if specs.AppConfig != nil {
+		// Only the agent options are set by convert, avoid emitting the
+		// rest of the (null) config fields.
+		config := struct {
+			AgentOptions *json.RawMessage `json:"agent_options"`
+		}{specs.AppConfig.AgentOptions}
+		meta, err := metadata(config)
+		if err != nil {
+			return nil, err
+		}
+		out, err := marshalSpecDocument(fleet.AppConfigKind, config, meta)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, convertedFile{
+			Path:     "config.yml",
+			Contents: out,
 		})
 	}
 
-	specs.Packs = append(specs.Packs, pack)
+	if specs.Decorators != nil {
+		meta, err := metadata(specs.Decorators)
+		if err != nil {
+			return nil, err
+		}
+		out, err := marshalSpecDocument(fleet.DecoratorsKind, specs.Decorators, meta)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, convertedFile{
+			Path:     "decorators.yml",
+			Contents: out,
+		})
+	}
 
-	return specs, nil
+	for _, label := range specs.Labels {
+		hashed := *label
+		hashed.ID = 0
+		meta, err := metadata(hashed)
+		if err != nil {
+			return nil, err
+		}
+		out, err := marshalSpecDocument(fleet.LabelKind, label, meta)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, convertedFile{
+			Path:     path.Join("labels", specFileName(label.Name)),
+			Contents: out,
+		})
+	}
+
+	for _, query := range specs.Queries {
+		// Annotations, such as the provenance, are not part of the
+		// content.
+		hashed := *query
+		hashed.Annotations = nil
+		meta, err := metadata(hashed)
+		if err != nil {
+			return nil, err
+		}
+		out, err := marshalSpecDocument(fleet.QueryKind, query, meta)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, convertedFile{
+			Path:     path.Join("queries", specFileName(query.Name)),
+			Contents: out,
+		})
+	}
+
+	for _, pack := range specs.Packs {
+		hashed := *pack
+		hashed.ID = 0
+		meta, err := metadata(hashed)
+		if err != nil {
+			return nil, err
+		}
+		out, err := marshalSpecDocument(fleet.PackKind, pack, meta)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, convertedFile{
+			Path:     path.Join("packs", specFileName(pack.Name)),
+			Contents: out,
+		})
+	}
+
+	return files, nil
+}
+
+// chunkFiles merges the files of each kind (directory) into numbered files of
+// at most perFile documents, eg. queries-001.yml, keeping their order. Files
+// at the root, such as the config, are kept as is.
+func chunkFiles(files []convertedFile, perFile uint) []convertedFile {
+	var chunked []convertedFile
+	counts := make(map[string]uint)
+	indexes := make(map[string]int)
+	for _, file := range files {
+		kind := path.Dir(file.Path)
+		if kind == "." {
+			chunked = append(chunked, file)
+			continue
+		}
+
+		if counts[kind]%perFile == 0 {
+			indexes[kind] = len(chunked)
+			chunked = append(chunked, convertedFile{
+				Path: fmt.Sprintf("%s-%03d.yml", kind, counts[kind]/perFile+1),
+			})
+		}
+		i := indexes[kind]
+		chunked[i].Contents = append(chunked[i].Contents, file.Contents...)
+		counts[kind]++
+	}
+	return chunked
+}
+
+// checksumsFile returns a SHA256SUMS file listing the hashes of the files, in
+// the format read by sha256sum -c.
+func checksumsFile(files []convertedFile) convertedFile {
+	var sums bytes.Buffer
+	for _, file := range files {
+		fmt.Fprintf(&sums, "%x  %s\n", sha256.Sum256(file.Contents), file.Path)
+	}
+	return convertedFile{
+		Path:     "SHA256SUMS",
+		Contents: sums.Bytes(),
+	}
+}
+
+// bundleManifest lists the files of a bundle in the order they must be
+// applied, so that the queries and labels exist before the packs using them.
+type bundleManifest struct {
+	Files []bundleManifestFile `json:"files"`
+}
+
+type bundleManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// manifestFile returns the manifest.json file of the bundle holding the
+// files, listing them in order with their SHA256 hashes.
+func manifestFile(files []convertedFile) (convertedFile, error) {
+	manifest := bundleManifest{Files: []bundleManifestFile{}}
+	for _, file := range files {
+		manifest.Files = append(manifest.Files, bundleManifestFile{
+			Path:   file.Path,
+			SHA256: fmt.Sprintf("%x", sha256.Sum256(file.Contents)),
+		})
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return convertedFile{}, errors.Wrap(err, "marshal manifest")
+	}
+	return convertedFile{
+		Path:     "manifest.json",
+		Contents: append(b, '\n'),
+	}, nil
+}
+
+// writeBundle writes the converted files into a gzip compressed tarball.
+func writeBundle(filename string, files []convertedFile) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, defaultFileMode)
+	if err != nil {
+		return errors.Wrap(err, "create bundle")
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	now := time.Now()
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		dir := path.Dir(file.Path)
+		if dir != "." && !dirs[dir] {
+			dirs[dir] = true
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     dir + "/",
+				Mode:     0755,
+				ModTime:  now,
+			}); err != nil {
+				return errors.Wrap(err, "write bundle directory")
+			}
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Path,
+			Mode:     0644,
+			Size:     int64(len(file.Contents)),
+			ModTime:  now,
+		}); err != nil {
+			return errors.Wrap(err, "write bundle header")
+		}
+		if _, err := tw.Write(file.Contents); err != nil {
+			return errors.Wrap(err, "write bundle file")
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "close bundle tar")
+	}
+	if err := gw.Close(); err != nil {
+		return errors.Wrap(err, "close bundle gzip")
+	}
+	return f.Close()
+}
+
+// writeOutputDir writes the converted files under the directory, creating
+// the directories of their paths. Existing files with the same paths are
+// overwritten, other files are left untouched.
+func writeOutputDir(dir string, files []convertedFile) error {
+	for _, file := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return errors.Wrap(err, "create output directory")
+		}
+		if err := ioutil.WriteFile(filename, file.Contents, defaultFileMode); err != nil {
+			return errors.Wrapf(err, "write %s", file.Path)
+		}
+	}
+	return nil
+}
+
+// osqueryPacks converts the Fleet pack specs back into osquery packs, looking
+// up the SQL of the scheduled queries in the query specs. The targets and the
+// Fleet specific query fields have no osquery equivalent and are dropped with
+// a warning.
+func osqueryPacks(report *convertReport, specs *specGroup) (map[string]fleet.PackContent, error) {
+	queries := make(map[string]*fleet.QuerySpec)
+	for _, query := range specs.Queries {
+		if existing, ok := queries[query.Name]; ok && existing.Query != query.Query {
+			return nil, errors.Errorf("query %q is defined twice with a different SQL", query.Name)
+		}
+		queries[query.Name] = query
+	}
+
+	packs := make(map[string]fleet.PackContent)
+	for _, pack := range specs.Packs {
+		if _, ok := packs[pack.Name]; ok {
+			return nil, errors.Errorf("pack %q is defined twice", pack.Name)
+		}
+		if len(pack.Targets.Labels) > 0 || len(pack.Targets.Teams) > 0 {
+			report.warnf("pack %q: targets are not supported by osquery packs and were dropped\n", pack.Name)
+		}
+
+		content := fleet.PackContent{
+			Platform: pack.Platform,
+			Queries:  make(fleet.Queries),
+		}
+		for _, scheduled := range pack.Queries {
+			query, ok := queries[scheduled.QueryName]
+			if !ok {
+				return nil, errors.Errorf("pack %q schedules query %q, which is not in the input", pack.Name, scheduled.QueryName)
+			}
+			if scheduled.LoggingDestination != nil || scheduled.Value != nil {
+				report.warnf("pack %q: logging_destination and value of query %q are not supported by osquery packs and were dropped\n", pack.Name, scheduled.Name)
+			}
+			content.Queries[scheduled.Name] = fleet.QueryContent{
+				Query:       query.Query,
+				Description: scheduled.Description,
+				Interval:    scheduled.Interval,
+				Platform:    scheduled.Platform,
+				Version:     scheduled.Version,
+				Snapshot:    scheduled.Snapshot,
+				Removed:     scheduled.Removed,
+				Shard:       scheduled.Shard,
+				Denylist:    scheduled.Denylist,
+			}
+		}
+		packs[pack.Name] = content
+	}
+	return packs, nil
+}
+
+// convertToPacks writes the Fleet specs of the files as osquery pack JSON, to
+// stdout for a single pack or one <name>.json file per pack in the output
+// directory.
+func convertToPacks(c *cli.Context, report *convertReport, filenames []string, outputDir string) error {
+	specs := &specGroup{}
+	for _, filename := range filenames {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		group, err := specGroupFromBytes(b)
+		if err != nil {
+			return errors.Wrapf(err, "convert %s", filename)
+		}
+		specs.Queries = append(specs.Queries, group.Queries...)
+		specs.Packs = append(specs.Packs, group.Packs...)
+	}
+
+	packs, err := osqueryPacks(report, specs)
+	if err != nil {
+		return err
+	}
+	if len(packs) == 0 {
+		return errors.New("no pack spec found in the input")
+	}
+	if outputDir == "" && len(packs) > 1 {
+		return errors.New("the input has several packs, use --output-dir to write one file per pack")
+	}
+
+	var files []convertedFile
+	for name, pack := range packs {
+		b, err := json.MarshalIndent(pack, "", "  ")
+		if err != nil {
+			return errors.Wrapf(err, "marshal pack %s", name)
+		}
+		files = append(files, convertedFile{
+			Path:     strings.TrimSuffix(specFileName(name), ".yml") + ".json",
+			Contents: append(b, '\n'),
+		})
+	}
+	if outputDir != "" {
+		return writeOutputDir(outputDir, files)
+	}
+	_, err = c.App.Writer.Write(files[0].Contents)
+	return err
 }
 
 func convertCommand() *cli.Command {
 	var (
-		flFilename string
+		flRoundInterval uint
+		flBundle        string
+		flOutputDir     string
+		flStrict        bool
+		flTeam          string
+		flDiff          bool
+		flCompat        string
+		flChecksums     bool
+		flMaxPerFile    uint
+		flWarnDupSQL    bool
+		flExpandPlats   bool
+		flMergeAs       string
+		flStatsFile     string
+		flTargetPlat    string
+		flIntervalUnit  string
+		flFormat        string
+		flMitreReport   bool
+		flMitreField    string
+		flProvenance    bool
+		flAuthor        string
+		flContentHash   bool
+		flToPack        bool
 	)
 	return &cli.Command{
 		Name:      "convert",
-		Usage:     "Convert osquery packs into decomposed fleet configs",
+		Usage:     "Convert osquery packs and configuration files into decomposed fleet configs",
 		UsageText: `fleetctl convert [options]`,
 		Flags: []cli.Flag{
 			configFlag(),
 			contextFlag(),
+			&cli.StringSliceFlag{
+				Name:    "f",
+				EnvVars: []string{"FILENAME"},
+				Usage:   "A file, directory or glob pattern of files to convert (multiple may be specified)",
+			},
+			&cli.UintFlag{
+				Name:        "round-interval",
+				Value:       0,
+				Destination: &flRoundInterval,
+				Usage:       "Round nonzero query intervals up to a multiple of this many seconds",
+			},
+			&cli.StringFlag{
+				Name:        "bundle",
+				Value:       "",
+				Destination: &flBundle,
+				Usage:       "Write the converted specs to a .tar.gz bundle instead of stdout, with a manifest.json listing the files in apply order",
+			},
+			&cli.StringFlag{
+				Name:        "output-dir",
+				Aliases:     []string{"o"},
+				Value:       "",
+				Destination: &flOutputDir,
+				Usage:       "Write each converted spec to its own file in this directory instead of stdout",
+			},
+			&cli.BoolFlag{
+				Name:        "strict",
+				Destination: &flStrict,
+				Usage:       "Fail instead of warning when the input has invalid values",
+			},
+			&cli.StringFlag{
+				Name:        "team",
+				Value:       "",
+				Destination: &flTeam,
+				Usage:       "Target the converted packs at the named team instead of all hosts (the queries stay global)",
+			},
+			&cli.BoolFlag{
+				Name:        "diff",
+				Destination: &flDiff,
+				Usage:       "Print the changes applying the converted specs would make on the server instead of the specs",
+			},
+			&cli.StringFlag{
+				Name:        "compat",
+				Value:       "",
+				Destination: &flCompat,
+				Usage:       "Strip the fields not supported by this Fleet version (eg. 4.0.1)",
+			},
+			&cli.BoolFlag{
+				Name:        "checksums",
+				Destination: &flChecksums,
+				Usage:       "Include a SHA256SUMS file with the hashes of the specs in the bundle",
+			},
+			&cli.UintFlag{
+				Name:        "max-per-file",
+				Value:       0,
+				Destination: &flMaxPerFile,
+				Usage:       "Group the specs of each kind in the bundle into files of at most this many documents",
+			},
+			&cli.BoolFlag{
+				Name:        "warn-duplicate-sql",
+				Destination: &flWarnDupSQL,
+				Usage:       "Warn about differently named queries with identical SQL",
+			},
+			&cli.BoolFlag{
+				Name:        "expand-platforms",
+				Destination: &flExpandPlats,
+				Usage:       "Rewrite shorthand query platforms (eg. posix) into the explicit platforms",
+			},
+			&cli.StringFlag{
+				Name:        "merge-as",
+				Value:       "",
+				Destination: &flMergeAs,
+				Usage:       "Merge the queries of all the files into a single pack with this name",
+			},
+			&cli.StringFlag{
+				Name:        "stats-file",
+				Value:       "",
+				Destination: &flStatsFile,
+				Usage:       "Write statistics about the conversion as JSON to this file",
+			},
+			&cli.StringSliceFlag{
+				Name:  "preserve-field",
+				Usage: "Keep this custom query field as an annotation of the query spec (multiple may be specified)",
+			},
+			&cli.StringFlag{
+				Name:        "target-platform",
+				Value:       "",
+				Destination: &flTargetPlat,
+				Usage:       "Drop the queries that don't run on this platform (darwin, linux, windows or freebsd)",
+			},
+			&cli.StringFlag{
+				Name:        "assume-interval-unit",
+				Value:       "seconds",
+				Destination: &flIntervalUnit,
+				Usage:       "Interpret the query intervals in this unit (seconds or minutes)",
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Value:       "yaml",
+				Destination: &flFormat,
+				Usage:       "Output format, yaml for Fleet specs or hcl-json for Terraform provider resources",
+			},
+			&cli.BoolFlag{
+				Name:        "mitre-report",
+				Destination: &flMitreReport,
+				Usage:       "Print the number of queries covering each MITRE ATT&CK technique to stderr",
+			},
+			&cli.StringFlag{
+				Name:        "mitre-field",
+				Value:       "mitre",
+				Destination: &flMitreField,
+				Usage:       "Custom query field holding the MITRE ATT&CK technique IDs for --mitre-report",
+			},
+			&cli.BoolFlag{
+				Name:        "provenance",
+				Destination: &flProvenance,
+				Usage:       "Record who converted the queries and when in the query spec annotations",
+			},
 			&cli.StringFlag{
-				Name:        "f",
-				EnvVars:     []string{"FILENAME"},
+				Name:        "author",
 				Value:       "",
-				Destination: &flFilename,
-				Usage:       "A file to apply",
+				Destination: &flAuthor,
+				Usage:       "Author recorded by --provenance instead of the current user",
+			},
+			&cli.BoolFlag{
+				Name:        "content-hash",
+				Destination: &flContentHash,
+				Usage:       "Record a hash of the contents of each spec in the document metadata",
+			},
+			&cli.BoolFlag{
+				Name:        "to-pack",
+				Destination: &flToPack,
+				Usage:       "Convert Fleet query and pack specs back into osquery pack JSON",
 			},
 		},
-		Action: func(c *cli.Context) error {
-			if flFilename == "" {
-				return errors.New("-f must be specified")
+		Action: func(c *cli.Context) (err error) {
+			report := newConvertReport(c.App.ErrWriter)
+
+			if flToPack {
+				if flBundle != "" || flDiff || flFormat != "yaml" {
+					return errors.New("--to-pack cannot be used with --bundle, --diff or --format")
+				}
+				filenames, err := specInputs(c.StringSlice("f"))
+				if err != nil {
+					return err
+				}
+				if len(filenames) == 0 {
+					return errors.New("-f must be specified")
+				}
+				return convertToPacks(c, report, filenames, flOutputDir)
 			}
 
-			b, err := ioutil.ReadFile(flFilename)
+			filenames, err := convertInputs(c.StringSlice("f"))
 			if err != nil {
 				return err
 			}
+			if len(filenames) == 0 {
+				return errors.New("-f must be specified")
+			}
+			if flBundle != "" && flOutputDir != "" {
+				return errors.New("--bundle and --output-dir cannot be used together")
+			}
+			if flChecksums && flBundle == "" && flOutputDir == "" {
+				return errors.New("--checksums requires --bundle or --output-dir")
+			}
+			if flAuthor != "" && !flProvenance {
+				return errors.New("--author requires --provenance")
+			}
+			if flMaxPerFile != 0 && flBundle == "" && flOutputDir == "" {
+				return errors.New("--max-per-file requires --bundle or --output-dir")
+			}
+			switch flFormat {
+			case "yaml":
+			case "hcl-json":
+				if flBundle != "" || flOutputDir != "" {
+					return errors.New("--format hcl-json cannot be used with --bundle or --output-dir")
+				}
+			default:
+				return errors.Errorf("--format must be yaml or hcl-json, got %q", flFormat)
+			}
+
+			var compatVersion *fleetVersion
+			if flCompat != "" {
+				v, err := parseFleetVersion(flCompat)
+				if err != nil {
+					return errors.Wrap(err, "--compat")
+				}
+				compatVersion = &v
+			}
+			switch flTargetPlat {
+			case "", "darwin", "linux", "windows", "freebsd":
+			default:
+				return errors.Errorf("--target-platform must be one of darwin, linux, windows or freebsd, got %q", flTargetPlat)
+			}
+			intervalUnit, ok := intervalUnits[flIntervalUnit]
+			if !ok {
+				return errors.Errorf("--assume-interval-unit must be seconds or minutes, got %q", flIntervalUnit)
+			}
+
+			// The MITRE tags are read like preserved fields, and removed
+			// from the output afterwards unless they were asked for.
+			preserve := c.StringSlice("preserve-field")
+			keepMitreField := false
+			for _, field := range preserve {
+				if field == flMitreField {
+					keepMitreField = true
+				}
+			}
+			if flMitreReport && !keepMitreField {
+				preserve = append(preserve, flMitreField)
+			}
 
-			// Remove any literal newlines (because they are not
-			// valid JSON but osquery accepts them) and replace
-			// with \n so that we get them in the YAML output where
-			// they are allowed.
-			re := regexp.MustCompile(`\s*\\\n`)
-			b = re.ReplaceAll(b, []byte(`\n`))
+			var groups []*specGroup
+			for _, filename := range filenames {
+				var fileSpecs *specGroup
+				if strings.EqualFold(filepath.Ext(filename), ".zip") {
+					fileSpecs, err = specGroupFromZip(report, filename, preserve)
+				} else {
+					var b []byte
+					b, err = ioutil.ReadFile(filename)
+					if err != nil {
+						return err
+					}
+					fileSpecs, err = specGroupFromFile(report, packNameFromPath(filename), b, preserve)
+				}
+				if err != nil {
+					return errors.Wrapf(err, "convert %s", filename)
+				}
+				groups = append(groups, fileSpecs)
+			}
 
 			var specs *specGroup
+			if flMergeAs != "" {
+				specs = mergeSpecGroupsAsPack(report, flMergeAs, groups)
+			} else {
+				specs, err = mergeSpecGroups(report, groups)
+				if err != nil {
+					return err
+				}
+			}
 
-			var pack fleet.PermissivePackContent
-			if err := json.Unmarshal(b, &pack); err != nil {
+			if err := validatePlatforms(report, specs, flStrict); err != nil {
 				return err
 			}
 
-			base := filepath.Base(flFilename)
-			specs, err = specGroupFromPack(strings.TrimSuffix(base, filepath.Ext(base)), pack)
-			if err != nil {
-				return err
+			if flWarnDupSQL {
+				warnDuplicateSQL(report, specs)
 			}
 
-			if specs == nil {
-				return errors.New("could not parse files")
+			if flTargetPlat != "" {
+				filterTargetPlatform(specs, flTargetPlat)
 			}
 
-			for _, pack := range specs.Packs {
-				spec, err := json.Marshal(pack)
-				if err != nil {
-					return err
+			scaleIntervals(specs, intervalUnit)
+			roundIntervals(specs, flRoundInterval)
+
+			if flExpandPlats {
+				expandPlatforms(specs)
+			}
+
+			// Query specs have no team scope in Fleet, only packs can be
+			// targeted at a team. The queries are still output as global
+			// specs for the packs to schedule them.
+			if flTeam != "" {
+				if len(specs.Queries) > 0 {
+					report.warnf("queries are global in Fleet and can't be scoped to team %q, only the packs are targeted at the team\n", flTeam)
 				}
+				for _, pack := range specs.Packs {
+					pack.Targets.Teams = []string{flTeam}
+				}
+			}
 
-				meta := specMetadata{
-					Kind:    fleet.PackKind,
-					Version: fleet.ApiVersion,
-					Spec:    spec,
+			if flMitreReport {
+				writeMitreReport(c.App.ErrWriter, specs, flMitreField)
+				if !keepMitreField {
+					removeAnnotation(specs, flMitreField)
 				}
+			}
 
-				out, err := yaml.Marshal(meta)
-				if err != nil {
-					return err
+			// The provenance changes with every run, it is opt-in for the
+			// output to be reproducible.
+			if flProvenance {
+				author := flAuthor
+				if author == "" {
+					author = currentUsername()
 				}
+				annotateProvenance(specs, author, time.Now())
+			}
+
+			// The output is downgraded last, once every field it holds is
+			// set.
+			if compatVersion != nil {
+				out := &compatOutput{specs: specs, contentHash: flContentHash}
+				downgradeSpecs(report, out, *compatVersion)
+				flContentHash = out.contentHash
+			}
 
-				fmt.Println("---")
-				fmt.Print(string(out))
+			if flStatsFile != "" {
+				// The stats are written once the specs are output, so that
+				// they hold the warnings of every step.
+				defer func() {
+					if err == nil {
+						err = report.writeStats(flStatsFile, specs)
+					}
+				}()
 			}
 
-			for _, query := range specs.Queries {
-				spec, err := json.Marshal(query)
+			if flDiff {
+				existing, err := existingSpecs(c)
 				if err != nil {
 					return err
 				}
-
-				meta := specMetadata{
-					Kind:    fleet.QueryKind,
-					Version: fleet.ApiVersion,
-					Spec:    spec,
+				diff := diffSpecs(existing, specs)
+				if len(diff) == 0 {
+					fmt.Fprintln(c.App.Writer, "No changes")
+					return nil
+				}
+				for _, line := range diff {
+					fmt.Fprintln(c.App.Writer, line)
 				}
+				return nil
+			}
 
-				out, err := yaml.Marshal(meta)
+			if flFormat == "hcl-json" {
+				if specs.AppConfig != nil {
+					report.warnf("agent options are not supported by --format hcl-json and were skipped\n")
+				}
+				if specs.Decorators != nil {
+					report.warnf("decorators are not supported by --format hcl-json and were skipped\n")
+				}
+				if len(specs.Labels) > 0 {
+					report.warnf("labels are not supported by --format hcl-json and were skipped\n")
+				}
+				out, err := terraformJSON(specs)
 				if err != nil {
 					return err
 				}
+				_, err = c.App.Writer.Write(out)
+				return err
+			}
+
+			files, err := convertedFiles(specs, flContentHash)
+			if err != nil {
+				return err
+			}
+
+			if flBundle != "" || flOutputDir != "" {
+				if flMaxPerFile != 0 {
+					files = chunkFiles(files, flMaxPerFile)
+				}
+				if flBundle != "" {
+					manifest, err := manifestFile(files)
+					if err != nil {
+						return err
+					}
+					files = append([]convertedFile{manifest}, files...)
+				}
+				if flChecksums {
+					files = append(files, checksumsFile(files))
+				}
+				if flOutputDir != "" {
+					return writeOutputDir(flOutputDir, files)
+				}
+				return writeBundle(flBundle, files)
+			}
 
-				fmt.Println("---")
-				fmt.Print(string(out))
+			for _, file := range files {
+				if _, err := c.App.Writer.Write(file.Contents); err != nil {
+					return err
+				}
 			}
 
 			return nil
@@ -0,0 +1,1529 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/ghodss/yaml"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func writeTempPack(t *testing.T, contents string) string {
+	tmpFile, err := ioutil.TempFile(os.TempDir(), "*.json")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(tmpFile.Name()) })
+
+	_, err = tmpFile.WriteString(contents)
+	require.NoError(t, err)
+	require.NoError(t, tmpFile.Close())
+	return tmpFile.Name()
+}
+
+// runConvertForTest runs the app with the provided arguments, returning the
+// standard output and error output separately.
+func runConvertForTest(t *testing.T, args []string) (string, string, error) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	r, _, _ := os.Pipe()
+	var exitErr error
+	app := createApp(r, stdout, func(context *cli.Context, err error) {
+		exitErr = err
+	})
+	app.ErrWriter = stderr
+	err := app.Run(append([]string{""}, args...))
+	if err == nil {
+		err = exitErr
+	}
+	return stdout.String(), stderr.String(), err
+}
+
+// readBundleForTest returns the names of the regular files in the bundle, in
+// order, and their contents.
+func readBundleForTest(t *testing.T, bundle string) ([]string, map[string][]byte) {
+	f, err := os.Open(bundle)
+	require.NoError(t, err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+
+	var names []string
+	contents := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		contents[hdr.Name] = b
+	}
+	return names, contents
+}
+
+func TestConvertRoundInterval(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "seventy": {"query": "select 1;", "interval": 70},
+    "one_twenty_five": {"query": "select 2;", "interval": "125"},
+    "sixty": {"query": "select 3;", "interval": 60},
+    "zero": {"query": "select 4;", "interval": 0}
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename, "--round-interval", "60"})
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 1)
+
+	intervals := make(map[string]uint)
+	for _, query := range specs.Packs[0].Queries {
+		intervals[query.Name] = query.Interval
+	}
+	assert.Equal(t, map[string]uint{
+		"seventy":         120,
+		"one_twenty_five": 180,
+		"sixty":           60,
+		"zero":            0,
+	}, intervals)
+}
+
+func TestConvertAssumeIntervalUnit(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "five": {"query": "select 1;", "interval": 5},
+    "ten": {"query": "select 2;", "interval": "10"},
+    "zero": {"query": "select 3;", "interval": 0}
+  }
+}`)
+
+	intervals := func(args []string) map[string]uint {
+		out := runAppForTest(t, args)
+		specs, err := specGroupFromBytes([]byte(out))
+		require.NoError(t, err)
+		require.Len(t, specs.Packs, 1)
+		result := make(map[string]uint)
+		for _, query := range specs.Packs[0].Queries {
+			result[query.Name] = query.Interval
+		}
+		return result
+	}
+
+	assert.Equal(t, map[string]uint{"five": 5, "ten": 10, "zero": 0}, intervals([]string{"convert", "-f", filename}))
+	assert.Equal(t, map[string]uint{"five": 300, "ten": 600, "zero": 0}, intervals([]string{"convert", "-f", filename, "--assume-interval-unit", "minutes"}))
+
+	_, _, err := runConvertForTest(t, []string{"convert", "-f", filename, "--assume-interval-unit", "hours"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--assume-interval-unit")
+}
+
+func TestConvertBundle(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60},
+    "uptime": {"query": "select * from uptime;", "interval": 3600}
+  }
+}`)
+	bundle := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	out := runAppForTest(t, []string{"convert", "-f", filename, "--bundle", bundle})
+	assert.Empty(t, out)
+
+	f, err := os.Open(bundle)
+	require.NoError(t, err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+
+	packName := strings.TrimSuffix(filepath.Base(filename), ".json")
+	contents := make(map[string]*specGroup)
+	sums := make(map[string]string)
+	var manifest []byte
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		if hdr.Name == "manifest.json" {
+			require.Nil(t, manifest, "manifest.json is bundled once")
+			manifest = b
+			continue
+		}
+		specs, err := specGroupFromBytes(b)
+		require.NoError(t, err, hdr.Name)
+		contents[hdr.Name] = specs
+		sums[hdr.Name] = fmt.Sprintf("%x", sha256.Sum256(b))
+	}
+
+	// The manifest lists the files in apply order, queries before the pack
+	// scheduling them.
+	require.NotNil(t, manifest)
+	assert.JSONEq(t, fmt.Sprintf(`{"files": [
+		{"path": "queries/time.yml", "sha256": %q},
+		{"path": "queries/uptime.yml", "sha256": %q},
+		{"path": %q, "sha256": %q}
+	]}`,
+		sums["queries/time.yml"], sums["queries/uptime.yml"],
+		"packs/"+packName+".yml", sums["packs/"+packName+".yml"],
+	), string(manifest))
+
+	require.Len(t, contents, 3)
+	require.Contains(t, contents, "packs/"+packName+".yml")
+	require.Len(t, contents["packs/"+packName+".yml"].Packs, 1)
+	assert.Len(t, contents["packs/"+packName+".yml"].Packs[0].Queries, 2)
+	require.Contains(t, contents, "queries/time.yml")
+	assert.Equal(t, []*fleet.QuerySpec{{Name: "time", Query: "select * from time;"}}, contents["queries/time.yml"].Queries)
+	require.Contains(t, contents, "queries/uptime.yml")
+	assert.Equal(t, []*fleet.QuerySpec{{Name: "uptime", Query: "select * from uptime;"}}, contents["queries/uptime.yml"].Queries)
+}
+
+func TestConvertOutputDir(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60},
+    "uptime": {"query": "select * from uptime;", "interval": 3600}
+  }
+}`)
+	dir := filepath.Join(t.TempDir(), "fleet")
+
+	out := runAppForTest(t, []string{"convert", "-f", filename, "-o", dir})
+	assert.Empty(t, out)
+
+	readSpecs := func(path string) *specGroup {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		require.NoError(t, err, path)
+		specs, err := specGroupFromBytes(b)
+		require.NoError(t, err, path)
+		return specs
+	}
+	packName := strings.TrimSuffix(filepath.Base(filename), ".json")
+	pack := readSpecs("packs/" + packName + ".yml")
+	require.Len(t, pack.Packs, 1)
+	assert.Len(t, pack.Packs[0].Queries, 2)
+	assert.Equal(t, []*fleet.QuerySpec{{Name: "time", Query: "select * from time;"}}, readSpecs("queries/time.yml").Queries)
+	assert.Equal(t, []*fleet.QuerySpec{{Name: "uptime", Query: "select * from uptime;"}}, readSpecs("queries/uptime.yml").Queries)
+
+	// Converting again overwrites the files.
+	runAppForTest(t, []string{"convert", "-f", filename, "--output-dir", dir})
+	assert.Len(t, readSpecs("queries/time.yml").Queries, 1)
+
+	_, _, err := runConvertForTest(t, []string{"convert", "-f", filename, "-o", dir, "--bundle", filepath.Join(dir, "out.tar.gz")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be used together")
+}
+
+func TestConvertToPack(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "platform": "linux",
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60, "snapshot": true},
+    "uptime": {"query": "select * from uptime;", "interval": 3600, "logging_destination": "security_lake"}
+  }
+}`)
+	dir := filepath.Join(t.TempDir(), "fleet")
+	runAppForTest(t, []string{"convert", "-f", filename, "-o", dir})
+
+	out, stderr, err := runConvertForTest(t, []string{"convert", "--to-pack", "-f", dir})
+	require.NoError(t, err)
+	assert.Contains(t, stderr, `logging_destination and value of query "uptime"`)
+
+	var pack fleet.PackContent
+	require.NoError(t, json.Unmarshal([]byte(out), &pack))
+	assert.Equal(t, fleet.PackContent{
+		Queries: fleet.Queries{
+			"time":   {Query: "select * from time;", Interval: 60, Snapshot: ptr.Bool(true)},
+			"uptime": {Query: "select * from uptime;", Interval: 3600},
+		},
+	}, pack)
+
+	// The queries of the pack must be in the input.
+	packName := strings.TrimSuffix(filepath.Base(filename), ".json")
+	_, _, err = runConvertForTest(t, []string{"convert", "--to-pack", "-f", filepath.Join(dir, "packs", packName+".yml")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `schedules query "time", which is not in the input`)
+
+	// Several packs are written to their own files.
+	other := writeTempPack(t, `{"queries": {"osquery_info": {"query": "select * from osquery_info;", "interval": 86400}}}`)
+	runAppForTest(t, []string{"convert", "-f", other, "-o", dir})
+	_, _, err = runConvertForTest(t, []string{"convert", "--to-pack", "-f", dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "use --output-dir")
+
+	packsDir := filepath.Join(t.TempDir(), "packs")
+	runAppForTest(t, []string{"convert", "--to-pack", "-f", dir, "-o", packsDir})
+	b, err := ioutil.ReadFile(filepath.Join(packsDir, strings.TrimSuffix(filepath.Base(other), ".json")+".json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &pack))
+	assert.Equal(t, uint(86400), pack.Queries["osquery_info"].Interval)
+}
+
+func TestConvertDiscovery(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "discovery": [
+    "select pid from processes where name = 'mysqld';",
+    "select 1 from file where path = '/etc/mysql/my.cnf';"
+  ],
+  "queries": {
+    "mysql_users": {"query": "select * from users;", "interval": 60}
+  }
+}`)
+	packName := strings.TrimSuffix(filepath.Base(filename), ".json")
+
+	out, stderr, err := runConvertForTest(t, []string{"convert", "-f", filename})
+	require.NoError(t, err)
+	assert.Contains(t, stderr, "discovery queries converted to label")
+	// The label is applied before the pack targeting it.
+	assert.Less(t, strings.Index(out, "kind: label"), strings.Index(out, "kind: pack"))
+
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Labels, 1)
+	assert.Equal(t, &fleet.LabelSpec{
+		Name:        packName + " discovery",
+		Description: "Hosts matching the discovery queries of the " + packName + " osquery pack",
+		Query:       "SELECT 1 WHERE EXISTS (select pid from processes where name = 'mysqld') AND EXISTS (select 1 from file where path = '/etc/mysql/my.cnf');",
+	}, specs.Labels[0])
+	require.Len(t, specs.Packs, 1)
+	assert.Equal(t, []string{packName + " discovery"}, specs.Packs[0].Targets.Labels)
+
+	// A single discovery query is the query of the label.
+	label := discoveryLabel("mysql", []string{"select pid from processes where name = 'mysqld';"})
+	assert.Equal(t, "select pid from processes where name = 'mysqld';", label.Query)
+}
+
+func TestConvertLoggingDestination(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "with_destination": {"query": "select 1;", "interval": 60, "logging_destination": "security_lake"},
+    "without_destination": {"query": "select 2;", "interval": 60}
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename})
+	assert.Contains(t, out, "logging_destination: security_lake")
+
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 1)
+	require.Len(t, specs.Packs[0].Queries, 2)
+	assert.Equal(t, "with_destination", specs.Packs[0].Queries[0].Name)
+	assert.Equal(t, ptr.String("security_lake"), specs.Packs[0].Queries[0].LoggingDestination)
+	assert.Equal(t, "without_destination", specs.Packs[0].Queries[1].Name)
+	assert.Nil(t, specs.Packs[0].Queries[1].LoggingDestination)
+}
+
+func TestConvertValue(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "failed_logins": {"query": "select 1;", "interval": 60, "value": 5},
+    "open_ports": {"query": "select 2;", "interval": 60, "value": "Alert on new listening ports"},
+    "uptime": {"query": "select 3;", "interval": 60}
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename})
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 1)
+
+	values := make(map[string]*string)
+	for _, query := range specs.Packs[0].Queries {
+		values[query.Name] = query.Value
+	}
+	assert.Equal(t, map[string]*string{
+		"failed_logins": ptr.String("5"),
+		"open_ports":    ptr.String("Alert on new listening ports"),
+		"uptime":        nil,
+	}, values)
+}
+
+func TestConvertObserverCanRun(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "observable": {"query": "select 1;", "interval": 60, "observer_can_run": true},
+    "restricted": {"query": "select 2;", "interval": 60, "observer_can_run": false},
+    "default": {"query": "select 3;", "interval": 60}
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename})
+	assert.Equal(t, 1, strings.Count(out, "observer_can_run: true"))
+
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	observerCanRun := make(map[string]bool)
+	for _, query := range specs.Queries {
+		observerCanRun[query.Name] = query.ObserverCanRun
+	}
+	assert.Equal(t, map[string]bool{
+		"observable": true,
+		"restricted": false,
+		"default":    false,
+	}, observerCanRun)
+}
+
+func TestConvertDecorators(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "decorators": {
+    "load": ["SELECT uuid AS host_uuid FROM system_info;"],
+    "always": ["SELECT user AS username FROM logged_in_users LIMIT 1;"],
+    "interval": {
+      "3600": ["SELECT total_seconds AS uptime FROM uptime;"]
+    }
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename})
+	assert.Equal(t, `---
+apiVersion: v1
+kind: decorators
+spec:
+  always:
+  - SELECT user AS username FROM logged_in_users LIMIT 1;
+  interval:
+    "3600":
+    - SELECT total_seconds AS uptime FROM uptime;
+  load:
+  - SELECT uuid AS host_uuid FROM system_info;
+`, out)
+
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	assert.Empty(t, specs.Packs)
+	assert.Empty(t, specs.Queries)
+	assert.Nil(t, specs.AppConfig)
+	assert.Equal(t, &fleet.DecoratorConfig{
+		Load:   []string{"SELECT uuid AS host_uuid FROM system_info;"},
+		Always: []string{"SELECT user AS username FROM logged_in_users LIMIT 1;"},
+		Interval: map[string][]string{
+			"3600": {"SELECT total_seconds AS uptime FROM uptime;"},
+		},
+	}, specs.Decorators)
+
+	// Decorators alongside queries and options are still emitted as their
+	// own spec, the config only holds the other agent options.
+	filename = writeTempPack(t, `{
+  "options": {"host_identifier": "uuid"},
+  "decorators": {"load": ["SELECT uuid AS host_uuid FROM system_info;"]},
+  "queries": {"time": {"query": "select * from time;", "interval": 60}}
+}`)
+
+	out = runAppForTest(t, []string{"convert", "-f", filename})
+	specs, err = specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 1)
+	require.Len(t, specs.Packs[0].Queries, 1)
+	require.NotNil(t, specs.AppConfig)
+	assert.JSONEq(t, `{"config": {"options": {"host_identifier": "uuid"}}}`, string(*specs.AppConfig.AgentOptions))
+	assert.Equal(t, &fleet.DecoratorConfig{Load: []string{"SELECT uuid AS host_uuid FROM system_info;"}}, specs.Decorators)
+}
+
+func TestConvertValidatePlatforms(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "valid": {"query": "select 1;", "interval": 60, "platform": "darwin,linux"},
+    "misspelled": {"query": "select 2;", "interval": 60, "platform": "darwn"}
+  }
+}`)
+
+	stdout, stderr, err := runConvertForTest(t, []string{"convert", "-f", filename})
+	require.NoError(t, err)
+	assert.Contains(t, stderr, `query "misspelled"`)
+	assert.Contains(t, stderr, `unknown platform "darwn"`)
+	assert.NotContains(t, stderr, `query "valid"`)
+
+	// The warning doesn't prevent the conversion.
+	specs, err := specGroupFromBytes([]byte(stdout))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 1)
+	assert.Len(t, specs.Packs[0].Queries, 2)
+
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", filename, "--strict"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown platform "darwn"`)
+}
+
+func TestConvertTeam(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "platform": "linux",
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60},
+    "processes": {"query": "select * from processes;", "interval": 3600, "snapshot": true}
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename})
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 1)
+	assert.Empty(t, specs.Packs[0].Targets.Teams)
+	assert.NotContains(t, out, "teams:")
+
+	// The pack is targeted at the team, the queries stay global.
+	out, errOut, err := runConvertForTest(t, []string{"convert", "-f", filename, "--team", "team1"})
+	require.NoError(t, err)
+	assert.Contains(t, errOut, `queries are global in Fleet and can't be scoped to team "team1", only the packs are targeted at the team`)
+	specs, err = specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 1)
+	assert.Equal(t, []string{"team1"}, specs.Packs[0].Targets.Teams)
+	assert.Len(t, specs.Packs[0].Queries, 2)
+	assert.Len(t, specs.Queries, 2)
+
+	// Without queries, there is nothing to warn about.
+	filename = writeTempPack(t, `{"queries": {}}`)
+	out, errOut, err = runConvertForTest(t, []string{"convert", "-f", filename, "--team", "team1"})
+	require.NoError(t, err)
+	assert.NotContains(t, errOut, "queries are global")
+	specs, err = specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 1)
+	assert.Equal(t, []string{"team1"}, specs.Packs[0].Targets.Teams)
+	assert.Empty(t, specs.Queries)
+}
+
+func TestConvertPreservesQueryFormatting(t *testing.T) {
+	filename := writeTempPack(t, "{\n"+
+		`  "queries": {`+"\n"+
+		`    "formatted": {"query": "SELECT p.name, \`+"\n"+
+		`    -- the binary path  \`+"\n"+
+		`    p.path \`+"\r\n"+
+		`  FROM processes p;  ", "interval": 60}`+"\n"+
+		"  }\n"+
+		"}")
+
+	out := runAppForTest(t, []string{"convert", "-f", filename})
+
+	expected := "SELECT p.name,\n    -- the binary path\n    p.path\n  FROM processes p;"
+	assert.Contains(t, out, "  query: |-\n"+
+		"    SELECT p.name,\n"+
+		"        -- the binary path\n"+
+		"        p.path\n"+
+		"      FROM processes p;\n")
+
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Queries, 1)
+	assert.Equal(t, expected, specs.Queries[0].Query)
+}
+
+func TestConvertDiff(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	// Reading packs requires more than the observer role.
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.ListQueriesFunc = func(opt fleet.ListOptions) ([]*fleet.Query, error) {
+		return []*fleet.Query{
+			{Name: "time", Query: "select * from time;"},
+			{Name: "uptime", Query: "select * from uptime;"},
+			{Name: "unrelated", Query: "select 1;"},
+		}, nil
+	}
+	ds.GetPackSpecsFunc = func() ([]*fleet.PackSpec, error) {
+		return []*fleet.PackSpec{
+			{
+				ID:   1,
+				Name: "pack",
+				Queries: []fleet.PackSpecQuery{
+					{Name: "time", QueryName: "time", Interval: 60},
+					{Name: "uptime", QueryName: "uptime", Interval: 60},
+					{Name: "old", QueryName: "old", Interval: 60},
+				},
+			},
+		}, nil
+	}
+
+	filename := filepath.Join(t.TempDir(), "pack.json")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60},
+    "uptime": {"query": "select * from uptime where days > 0;", "interval": 120},
+    "new": {"query": "select * from processes;", "interval": 60}
+  }
+}`), 0644))
+
+	expected := `+ query "new"
+~ query "uptime"
+~ pack "pack"
+    + query "new"
+    ~ query "uptime"
+    - query "old"
+`
+	assert.Equal(t, expected, runAppForTest(t, []string{"convert", "-f", filename, "--diff"}))
+}
+
+func TestConvertDiffNoChanges(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	// Reading packs requires more than the observer role.
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.ListQueriesFunc = func(opt fleet.ListOptions) ([]*fleet.Query, error) {
+		return []*fleet.Query{{Name: "time", Query: "select * from time;"}}, nil
+	}
+	ds.GetPackSpecsFunc = func() ([]*fleet.PackSpec, error) {
+		return []*fleet.PackSpec{
+			{
+				ID:      1,
+				Name:    "pack",
+				Targets: fleet.PackSpecTargets{Labels: []string{}},
+				Queries: []fleet.PackSpecQuery{{Name: "time", QueryName: "time", Interval: 60}},
+			},
+		}, nil
+	}
+
+	filename := filepath.Join(t.TempDir(), "pack.json")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60}
+  }
+}`), 0644))
+
+	assert.Equal(t, "No changes\n", runAppForTest(t, []string{"convert", "-f", filename, "--diff"}))
+}
+
+func TestConvertDiffUnstoredFields(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.ListQueriesFunc = func(opt fleet.ListOptions) ([]*fleet.Query, error) {
+		return []*fleet.Query{{Name: "time", Query: "select * from time;"}}, nil
+	}
+	// The pack as stored once the converted specs are applied, without the
+	// logging destination and value of the query.
+	ds.GetPackSpecsFunc = func() ([]*fleet.PackSpec, error) {
+		return []*fleet.PackSpec{
+			{
+				ID:      1,
+				Name:    "pack",
+				Queries: []fleet.PackSpecQuery{{Name: "time", QueryName: "time", Interval: 60}},
+			},
+		}, nil
+	}
+
+	filename := filepath.Join(t.TempDir(), "pack.json")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60, "logging_destination": "kinesis", "value": 5}
+  }
+}`), 0644))
+
+	assert.Equal(t, "No changes\n", runAppForTest(t, []string{"convert", "-f", filename, "--diff"}))
+}
+
+func TestConvertZip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "packs.zip")
+	f, err := os.Create(filename)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for name, contents := range map[string]string{
+		"packs/first.json":  `{"queries": {"time": {"query": "select * from time;", "interval": 60}}}`,
+		"packs/second.JSON": `{"queries": {"uptime": {"query": "select * from uptime;", "interval": 120}}}`,
+		"packs/README.md":   "# Packs",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	stdout, stderr, err := runConvertForTest(t, []string{"convert", "-f", filename})
+	require.NoError(t, err)
+	assert.Equal(t, "[!] skipping \"packs/README.md\" in archive, not a JSON file\n", stderr)
+
+	specs, err := specGroupFromBytes([]byte(stdout))
+	require.NoError(t, err)
+
+	packs := make(map[string][]string)
+	for _, pack := range specs.Packs {
+		for _, query := range pack.Queries {
+			packs[pack.Name] = append(packs[pack.Name], query.Name)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"first":  {"time"},
+		"second": {"uptime"},
+	}, packs)
+
+	var queries []string
+	for _, query := range specs.Queries {
+		queries = append(queries, query.Name)
+	}
+	assert.ElementsMatch(t, []string{"time", "uptime"}, queries)
+}
+
+func TestConvertCompat(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60, "logging_destination": "kinesis"},
+    "uptime": {"query": "select * from uptime;", "interval": 60}
+  }
+}`)
+
+	stdout, stderr, err := runConvertForTest(t, []string{"convert", "-f", filename, "--compat", "4.0.1"})
+	require.NoError(t, err)
+	pack := filepath.Base(strings.TrimSuffix(filename, filepath.Ext(filename)))
+	assert.Equal(t, "[!] removing logging_destination from query \"time\" in pack \""+pack+"\", it requires Fleet 4.1.0\n", stderr)
+	assert.NotContains(t, stdout, "logging_destination")
+
+	// Newer targets keep the fields.
+	stdout, stderr, err = runConvertForTest(t, []string{"convert", "-f", filename, "--compat", "v4.1"})
+	require.NoError(t, err)
+	assert.Empty(t, stderr)
+	assert.Contains(t, stdout, "logging_destination: kinesis")
+
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", filename, "--compat", "latest"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid version "latest"`)
+}
+
+func TestConvertCompatFields(t *testing.T) {
+	packFile := func(queries string) (string, string) {
+		filename := writeTempPack(t, `{"queries": {`+queries+`}}`)
+		return filename, filepath.Base(strings.TrimSuffix(filename, filepath.Ext(filename)))
+	}
+
+	testCases := []struct {
+		field string
+		args  func() ([]string, string)
+		// kept is in the output of the newer versions only.
+		kept string
+	}{
+		{
+			field: "value",
+			args: func() ([]string, string) {
+				filename, pack := packFile(`"time": {"query": "select * from time;", "interval": 60, "value": "1 row per host"}`)
+				return []string{"-f", filename}, `query "time" in pack "` + pack + `"`
+			},
+			kept: "value: 1 row per host",
+		},
+		{
+			field: "targets.teams",
+			args: func() ([]string, string) {
+				filename, pack := packFile(`"time": {"query": "select * from time;", "interval": 60}`)
+				return []string{"-f", filename, "--team", "team1"}, `pack "` + pack + `"`
+			},
+			kept: "teams:",
+		},
+		{
+			field: "observer_can_run",
+			args: func() ([]string, string) {
+				filename, _ := packFile(`"time": {"query": "select * from time;", "interval": 60, "observer_can_run": true}`)
+				return []string{"-f", filename}, `query "time"`
+			},
+			kept: "observer_can_run: true",
+		},
+		{
+			field: "annotations",
+			args: func() ([]string, string) {
+				filename, _ := packFile(`"time": {"query": "select * from time;", "interval": 60, "oncall": "sre"}`)
+				return []string{"-f", filename, "--preserve-field", "oncall"}, `query "time"`
+			},
+			kept: "oncall: sre",
+		},
+		{
+			field: "metadata.content_hash",
+			args: func() ([]string, string) {
+				filename, _ := packFile(`"time": {"query": "select * from time;", "interval": 60}`)
+				return []string{"-f", filename, "--content-hash"}, "every spec"
+			},
+			kept: "content_hash:",
+		},
+		{
+			field: "kind decorators",
+			args: func() ([]string, string) {
+				filename := writeTempPack(t, `{"decorators": {"load": ["select uuid from system_info;"]}}`)
+				return []string{"-f", filename}, "the decorators spec"
+			},
+			kept: "kind: decorators",
+		},
+		{
+			field: "kind label",
+			args: func() ([]string, string) {
+				filename := writeTempPack(t, `{"discovery": ["select 1;"], "queries": {"time": {"query": "select * from time;", "interval": 60}}}`)
+				pack := filepath.Base(strings.TrimSuffix(filename, filepath.Ext(filename)))
+				return []string{"-f", filename}, `label "` + pack + ` discovery"`
+			},
+			kept: "kind: label",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.field, func(t *testing.T) {
+			args, from := tt.args()
+			args = append([]string{"convert"}, args...)
+
+			stdout, stderr, err := runConvertForTest(t, append(args, "--compat", "4.0.1"))
+			require.NoError(t, err)
+			assert.Contains(t, stderr, "[!] removing "+tt.field+" from "+from+", it requires Fleet 4.1.0\n")
+			assert.NotContains(t, stdout, tt.kept)
+
+			stdout, stderr, err = runConvertForTest(t, append(args, "--compat", "4.1.0"))
+			require.NoError(t, err)
+			assert.NotContains(t, stderr, "removing")
+			assert.Contains(t, stdout, tt.kept)
+		})
+	}
+}
+
+func TestConvertCompatDecoratorsAgentOptions(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "options": {"host_identifier": "uuid"},
+  "decorators": {"load": ["select uuid from system_info;"]}
+}`)
+
+	// Older versions read the decorators from the agent options.
+	stdout, stderr, err := runConvertForTest(t, []string{"convert", "-f", filename, "--compat", "4.0.1"})
+	require.NoError(t, err)
+	assert.Contains(t, stderr, "[!] removing kind decorators from the decorators spec, moved to the agent options of the config spec, it requires Fleet 4.1.0\n")
+	specs, err := specGroupFromBytes([]byte(stdout))
+	require.NoError(t, err)
+	assert.Nil(t, specs.Decorators)
+	require.NotNil(t, specs.AppConfig)
+	require.NotNil(t, specs.AppConfig.AgentOptions)
+	var agentOptions struct {
+		Config struct {
+			Options    map[string]interface{} `json:"options"`
+			Decorators fleet.DecoratorConfig  `json:"decorators"`
+		} `json:"config"`
+	}
+	require.NoError(t, json.Unmarshal(*specs.AppConfig.AgentOptions, &agentOptions))
+	assert.Equal(t, "uuid", agentOptions.Config.Options["host_identifier"])
+	assert.Equal(t, []string{"select uuid from system_info;"}, agentOptions.Config.Decorators.Load)
+
+}
+
+func TestParseFleetVersion(t *testing.T) {
+	v, err := parseFleetVersion("4.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, fleetVersion{4, 0, 1}, v)
+
+	v, err = parseFleetVersion("v3.7")
+	require.NoError(t, err)
+	assert.Equal(t, fleetVersion{3, 7, 0}, v)
+	assert.True(t, v.less(fleetVersion{4, 0, 0}))
+	assert.False(t, v.less(v))
+
+	for _, invalid := range []string{"", "4.0.1.2", "4.x", "-1"} {
+		_, err = parseFleetVersion(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestConvertBundleChecksums(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60},
+    "uptime": {"query": "select * from uptime;", "interval": 3600}
+  }
+}`)
+	bundle := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	out := runAppForTest(t, []string{"convert", "-f", filename, "--bundle", bundle, "--checksums"})
+	assert.Empty(t, out)
+
+	_, contents := readBundleForTest(t, bundle)
+
+	require.Contains(t, contents, "SHA256SUMS")
+	lines := strings.Split(strings.TrimSuffix(string(contents["SHA256SUMS"]), "\n"), "\n")
+	require.Len(t, lines, len(contents)-1)
+
+	for _, line := range lines {
+		parts := strings.SplitN(line, "  ", 2)
+		require.Len(t, parts, 2, line)
+		require.Contains(t, contents, parts[1])
+		assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(contents[parts[1]])), parts[0], parts[1])
+	}
+
+	_, _, err := runConvertForTest(t, []string{"convert", "-f", filename, "--checksums"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--checksums requires --bundle")
+}
+
+func TestConvertBundleMaxPerFile(t *testing.T) {
+	var queries []string
+	for i := 0; i < 5; i++ {
+		queries = append(queries, fmt.Sprintf(`"query%d": {"query": "select %d;", "interval": 60}`, i, i))
+	}
+	filename := writeTempPack(t, `{"queries": {`+strings.Join(queries, ",")+`}}`)
+	bundle := filepath.Join(t.TempDir(), "out.tar.gz")
+
+	out := runAppForTest(t, []string{"convert", "-f", filename, "--bundle", bundle, "--max-per-file", "2"})
+	assert.Empty(t, out)
+
+	names, contents := readBundleForTest(t, bundle)
+	assert.Equal(t, []string{"manifest.json", "queries-001.yml", "queries-002.yml", "queries-003.yml", "packs-001.yml"}, names)
+
+	specs, err := specGroupFromBytes(contents["packs-001.yml"])
+	require.NoError(t, err)
+	assert.Len(t, specs.Packs, 1)
+
+	var chunks [][]string
+	for _, name := range names[1:4] {
+		specs, err := specGroupFromBytes(contents[name])
+		require.NoError(t, err, name)
+		var chunk []string
+		for _, query := range specs.Queries {
+			chunk = append(chunk, query.Name)
+		}
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, [][]string{{"query0", "query1"}, {"query2", "query3"}, {"query4"}}, chunks)
+
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", filename, "--max-per-file", "2"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--max-per-file requires --bundle")
+}
+
+func TestConvertWarnDuplicateSQL(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "processes": {"query": "select * from processes;", "interval": 60},
+    "running_processes": {"query": "select * from processes;", "interval": 3600},
+    "time": {"query": "select * from time;", "interval": 60}
+  }
+}`)
+
+	_, stderr, err := runConvertForTest(t, []string{"convert", "-f", filename})
+	require.NoError(t, err)
+	assert.Empty(t, stderr)
+
+	_, stderr, err = runConvertForTest(t, []string{"convert", "-f", filename, "--warn-duplicate-sql"})
+	require.NoError(t, err)
+	assert.Equal(t, "[!] queries \"processes\", \"running_processes\" have identical SQL\n", stderr)
+}
+
+func TestConvertExpandPlatforms(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "posix": {"query": "select 1;", "interval": 60, "platform": "posix"},
+    "mixed": {"query": "select 2;", "interval": 60, "platform": "linux, posix,windows"},
+    "windows": {"query": "select 3;", "interval": 60, "platform": "windows"},
+    "any": {"query": "select 4;", "interval": 60, "platform": "any"},
+    "none": {"query": "select 5;", "interval": 60}
+  }
+}`)
+
+	platforms := func(args []string) map[string]*string {
+		out := runAppForTest(t, args)
+		specs, err := specGroupFromBytes([]byte(out))
+		require.NoError(t, err)
+		require.Len(t, specs.Packs, 1)
+		result := make(map[string]*string)
+		for _, query := range specs.Packs[0].Queries {
+			result[query.Name] = query.Platform
+		}
+		return result
+	}
+
+	assert.Equal(t, map[string]*string{
+		"posix":   ptr.String("posix"),
+		"mixed":   ptr.String("linux, posix,windows"),
+		"windows": ptr.String("windows"),
+		"any":     ptr.String("any"),
+		"none":    nil,
+	}, platforms([]string{"convert", "-f", filename}))
+
+	assert.Equal(t, map[string]*string{
+		"posix":   ptr.String("darwin,linux,freebsd"),
+		"mixed":   ptr.String("linux,darwin,freebsd,windows"),
+		"windows": ptr.String("windows"),
+		"any":     ptr.String("any"),
+		"none":    nil,
+	}, platforms([]string{"convert", "-f", filename, "--expand-platforms"}))
+}
+
+func TestConvertMergeAs(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.json")
+	require.NoError(t, ioutil.WriteFile(first, []byte(`{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60},
+    "uptime": {"query": "select * from uptime;", "interval": 60}
+  }
+}`), 0644))
+	second := filepath.Join(dir, "second.json")
+	require.NoError(t, ioutil.WriteFile(second, []byte(`{
+  "queries": {
+    "processes": {"query": "select * from processes;", "interval": 120},
+    "time": {"query": "select unix_time from time;", "interval": 120}
+  }
+}`), 0644))
+
+	stdout, stderr, err := runConvertForTest(t, []string{"convert", "-f", first, "-f", second, "--merge-as", "merged"})
+	require.NoError(t, err)
+	assert.Equal(t, "[!] renaming query \"time\" from \"second\" to \"time_second\", the name is already used\n", stderr)
+
+	specs, err := specGroupFromBytes([]byte(stdout))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 1)
+	assert.Equal(t, "merged", specs.Packs[0].Name)
+
+	packQueries := make(map[string]uint)
+	for _, query := range specs.Packs[0].Queries {
+		assert.Equal(t, query.Name, query.QueryName)
+		packQueries[query.Name] = query.Interval
+	}
+	assert.Equal(t, map[string]uint{
+		"time":        60,
+		"uptime":      60,
+		"processes":   120,
+		"time_second": 120,
+	}, packQueries)
+
+	queries := make(map[string]string)
+	for _, query := range specs.Queries {
+		queries[query.Name] = query.Query
+	}
+	assert.Equal(t, map[string]string{
+		"time":        "select * from time;",
+		"uptime":      "select * from uptime;",
+		"processes":   "select * from processes;",
+		"time_second": "select unix_time from time;",
+	}, queries)
+
+	// A directory is expanded into its files, which are converted into a
+	// pack each without --merge-as.
+	stdout, _, err = runConvertForTest(t, []string{"convert", "-f", dir})
+	require.NoError(t, err)
+	specs, err = specGroupFromBytes([]byte(stdout))
+	require.NoError(t, err)
+	var packs []string
+	for _, pack := range specs.Packs {
+		packs = append(packs, pack.Name)
+	}
+	assert.Equal(t, []string{"first", "second"}, packs)
+}
+
+func TestDisambiguateQueryName(t *testing.T) {
+	taken := map[string]bool{"time": true}
+	assert.Equal(t, "time_pack", disambiguateQueryName("time", "pack", taken))
+	taken["time_pack"] = true
+	assert.Equal(t, "time_pack_2", disambiguateQueryName("time", "pack", taken))
+	taken["time_pack_2"] = true
+	assert.Equal(t, "time_pack_3", disambiguateQueryName("time", "pack", taken))
+}
+
+func TestConvertStatsFile(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.json")
+	require.NoError(t, ioutil.WriteFile(first, []byte(`{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60, "logging_destination": "kinesis"}
+  }
+}`), 0644))
+	second := filepath.Join(dir, "second.json")
+	require.NoError(t, ioutil.WriteFile(second, []byte(`{
+  "queries": {
+    "time": {"query": "select unix_time from time;", "interval": 120, "platform": "plan9"}
+  }
+}`), 0644))
+	statsFile := filepath.Join(dir, "stats.json")
+
+	_, _, err := runConvertForTest(t, []string{
+		"convert", "-f", first, "-f", second,
+		"--merge-as", "merged", "--compat", "4.0.1", "--stats-file", statsFile,
+	})
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadFile(statsFile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "packs": 1,
+  "queries": 2,
+  "labels": 0,
+  "warnings": [
+    "renaming query \"time\" from \"second\" to \"time_second\", the name is already used",
+    "query \"time_second\" in pack \"merged\" has unknown platform \"plan9\"",
+    "removing logging_destination from query \"time\" in pack \"merged\", it requires Fleet 4.1.0"
+  ],
+  "dropped_fields": [
+    {"field": "logging_destination", "from": "query \"time\" in pack \"merged\""}
+  ],
+  "renames": [
+    {"pack": "second", "from": "time", "to": "time_second"}
+  ]
+}`, string(b))
+
+	// Without warnings the lists are empty rather than null.
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", first, "--stats-file", statsFile})
+	require.NoError(t, err)
+	b, err = ioutil.ReadFile(statsFile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"packs": 1, "queries": 1, "labels": 0, "warnings": [], "dropped_fields": [], "renames": []}`, string(b))
+
+	// The warnings of the output format are included.
+	config := filepath.Join(dir, "osquery.conf")
+	require.NoError(t, ioutil.WriteFile(config, []byte(`{
+  "decorators": {"load": ["select uuid as host_uuid from system_info;"]},
+  "schedule": {"time": {"query": "select * from time;", "interval": 60}}
+}`), 0644))
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", config, "--format", "hcl-json", "--stats-file", statsFile})
+	require.NoError(t, err)
+	b, err = ioutil.ReadFile(statsFile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "packs": 1,
+  "queries": 1,
+  "labels": 0,
+  "warnings": ["decorators are not supported by --format hcl-json and were skipped"],
+  "dropped_fields": [],
+  "renames": []
+}`, string(b))
+}
+
+func TestConvertQueriesBeforePacks(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "decorators": {"load": ["select uuid as host_uuid from system_info;"]},
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60},
+    "uptime": {"query": "select * from uptime;", "interval": 60}
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename})
+
+	var kinds []string
+	var queries []string
+	for _, doc := range splitYaml(out) {
+		var meta specMetadata
+		require.NoError(t, yaml.Unmarshal([]byte(doc), &meta))
+		kinds = append(kinds, meta.Kind)
+		if meta.Kind == fleet.QueryKind {
+			var query fleet.QuerySpec
+			require.NoError(t, yaml.Unmarshal(meta.Spec, &query))
+			queries = append(queries, query.Name)
+		}
+		if meta.Kind == fleet.PackKind {
+			var pack fleet.PackSpec
+			require.NoError(t, yaml.Unmarshal(meta.Spec, &pack))
+			// Every query the pack references was emitted before it.
+			for _, query := range pack.Queries {
+				assert.Contains(t, queries, query.QueryName)
+			}
+		}
+	}
+	assert.Equal(t, []string{fleet.DecoratorsKind, fleet.QueryKind, fleet.QueryKind, fleet.PackKind}, kinds)
+}
+
+func TestConvertPreserveField(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "time": {
+      "query": "select * from time;",
+      "interval": 60,
+      "oncall": "platform-team",
+      "team": "sre"
+    },
+    "uptime": {"query": "select * from uptime;", "interval": 60}
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename, "--preserve-field", "oncall"})
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Queries, 2)
+	assert.Equal(t, map[string]interface{}{"oncall": "platform-team"}, specs.Queries[0].Annotations)
+	assert.Nil(t, specs.Queries[1].Annotations)
+
+	// Custom fields are dropped unless preserved.
+	out = runAppForTest(t, []string{"convert", "-f", filename})
+	specs, err = specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Queries, 2)
+	assert.Nil(t, specs.Queries[0].Annotations)
+}
+
+func TestConvertTargetPlatform(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "posix": {"query": "select 1;", "interval": 60, "platform": "posix"},
+    "mixed": {"query": "select 2;", "interval": 60, "platform": "linux,windows"},
+    "windows": {"query": "select 3;", "interval": 60, "platform": "windows"},
+    "darwin": {"query": "select 4;", "interval": 60, "platform": "darwin"},
+    "any": {"query": "select 5;", "interval": 60, "platform": "any"},
+    "none": {"query": "select 6;", "interval": 60}
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename, "--target-platform", "linux"})
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+
+	var queries []string
+	for _, query := range specs.Queries {
+		queries = append(queries, query.Name)
+	}
+	assert.Equal(t, []string{"any", "mixed", "none", "posix"}, queries)
+
+	require.Len(t, specs.Packs, 1)
+	var packQueries []string
+	for _, query := range specs.Packs[0].Queries {
+		packQueries = append(packQueries, query.Name)
+	}
+	assert.Equal(t, []string{"any", "mixed", "none", "posix"}, packQueries)
+
+	// A pack without any query for the platform is still emitted.
+	filename = writeTempPack(t, `{
+  "queries": {
+    "windows": {"query": "select 3;", "interval": 60, "platform": "windows"}
+  }
+}`)
+	out = runAppForTest(t, []string{"convert", "-f", filename, "--target-platform", "darwin"})
+	specs, err = specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	assert.Empty(t, specs.Queries)
+	require.Len(t, specs.Packs, 1)
+	assert.Empty(t, specs.Packs[0].Queries)
+
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", filename, "--target-platform", "posix"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--target-platform")
+}
+
+func TestConvertFormatHCLJSON(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60, "platform": "linux"},
+    "1-uptime": {"query": "select * from uptime;", "interval": "120"}
+  }
+}`)
+
+	out := runAppForTest(t, []string{"convert", "-f", filename, "--format", "hcl-json"})
+
+	var config struct {
+		Resource struct {
+			Query map[string]terraformQuery `json:"fleet_query"`
+			Pack  map[string]terraformPack  `json:"fleet_pack"`
+		} `json:"resource"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &config))
+
+	assert.Equal(t, map[string]terraformQuery{
+		"time":      {Name: "time", Query: "select * from time;"},
+		"_1-uptime": {Name: "1-uptime", Query: "select * from uptime;"},
+	}, config.Resource.Query)
+
+	require.Len(t, config.Resource.Pack, 1)
+	pack, ok := config.Resource.Pack[terraformResourceName(packNameFromPath(filename), map[string]bool{})]
+	require.True(t, ok)
+	assert.Equal(t, packNameFromPath(filename), pack.Name)
+	assert.Equal(t, []terraformPackQuery{
+		{Query: "${fleet_query._1-uptime.name}", Name: "1-uptime", Interval: 120},
+		{Query: "${fleet_query.time.name}", Name: "time", Interval: 60, Platform: ptr.String("linux")},
+	}, pack.Queries)
+
+	_, _, err := runConvertForTest(t, []string{"convert", "-f", filename, "--format", "xml"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--format")
+}
+
+func TestTerraformResourceName(t *testing.T) {
+	taken := make(map[string]bool)
+	assert.Equal(t, "osquery_monitoring", terraformResourceName("osquery monitoring", taken))
+	assert.Equal(t, "osquery_monitoring_2", terraformResourceName("osquery/monitoring", taken))
+	assert.Equal(t, "_3d", terraformResourceName("3d", taken))
+	assert.Equal(t, "_", terraformResourceName("", taken))
+}
+
+func TestConvertMitreReport(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "shell_history": {"query": "select 1;", "interval": 60, "mitre": "T1059, T1552"},
+    "suid_bin": {"query": "select 2;", "interval": 60, "mitre": ["T1548"]},
+    "bash_processes": {"query": "select 3;", "interval": 60, "mitre": "T1059"},
+    "uptime": {"query": "select 4;", "interval": 60}
+  }
+}`)
+
+	stdout, stderr, err := runConvertForTest(t, []string{"convert", "-f", filename, "--mitre-report"})
+	require.NoError(t, err)
+	assert.Equal(t, `MITRE ATT&CK coverage:
+  T1059: 2 queries
+  T1548: 1 query
+  T1552: 1 query
+  untagged: 1 query
+`, stderr)
+
+	// The conversion output is unchanged, without the tags.
+	specs, err := specGroupFromBytes([]byte(stdout))
+	require.NoError(t, err)
+	require.Len(t, specs.Queries, 4)
+	for _, query := range specs.Queries {
+		assert.Nil(t, query.Annotations)
+	}
+
+	// The tag field is configurable, and kept when preserved.
+	filename = writeTempPack(t, `{
+  "queries": {
+    "shell_history": {"query": "select 1;", "interval": 60, "attack": "T1059"}
+  }
+}`)
+	stdout, stderr, err = runConvertForTest(t, []string{
+		"convert", "-f", filename, "--mitre-report", "--mitre-field", "attack", "--preserve-field", "attack",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, stderr, "  T1059: 1 query\n  untagged: 0 queries\n")
+	specs, err = specGroupFromBytes([]byte(stdout))
+	require.NoError(t, err)
+	require.Len(t, specs.Queries, 1)
+	assert.Equal(t, map[string]interface{}{"attack": "T1059"}, specs.Queries[0].Annotations)
+}
+
+func TestConvertProvenance(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "queries": {
+    "time": {"query": "select * from time;", "interval": 60, "oncall": "sre"}
+  }
+}`)
+
+	before := time.Now().Add(-time.Second)
+	out := runAppForTest(t, []string{"convert", "-f", filename, "--provenance", "--author", "jane", "--preserve-field", "oncall"})
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Queries, 1)
+
+	annotations := specs.Queries[0].Annotations
+	assert.Equal(t, "jane", annotations["author"])
+	assert.Equal(t, "sre", annotations["oncall"])
+	createdAt, err := time.Parse(time.RFC3339, annotations["created_at"].(string))
+	require.NoError(t, err)
+	assert.True(t, createdAt.After(before))
+	assert.True(t, createdAt.Before(time.Now().Add(time.Second)))
+
+	// Without --author the current user is recorded.
+	out = runAppForTest(t, []string{"convert", "-f", filename, "--provenance"})
+	specs, err = specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Queries, 1)
+	assert.Equal(t, currentUsername(), specs.Queries[0].Annotations["author"])
+	assert.NotEmpty(t, specs.Queries[0].Annotations["created_at"])
+
+	// Without --provenance the output is reproducible.
+	out = runAppForTest(t, []string{"convert", "-f", filename})
+	specs, err = specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Queries, 1)
+	assert.Nil(t, specs.Queries[0].Annotations)
+	assert.Equal(t, out, runAppForTest(t, []string{"convert", "-f", filename}))
+
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", filename, "--author", "jane"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--author requires --provenance")
+}
+
+func TestConvertOsqueryPacksDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"osquery.conf":           `{"options": {"host_identifier": "uuid"}, "decorators": {"load": ["select 1;"]}}`,
+		"incident-response.conf": `{"queries": {"crontab": {"query": "select * from crontab;", "interval": 3600}}}`,
+		"it-compliance.conf":     `{"queries": {"os_version": {"query": "select * from os_version;", "interval": 86400}}}`,
+		"README.md":              `Packs shipped with osquery`,
+	}
+	for name, contents := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600))
+	}
+
+	out := runAppForTest(t, []string{"convert", "-f", dir})
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	assert.Nil(t, specs.AppConfig)
+
+	packs := make(map[string][]string)
+	for _, pack := range specs.Packs {
+		for _, query := range pack.Queries {
+			packs[pack.Name] = append(packs[pack.Name], query.Name)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"incident-response": {"crontab"},
+		"it-compliance":     {"os_version"},
+	}, packs)
+}
+
+func TestConvertGlobs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.json": `{"queries": {"time": {"query": "select * from time;", "interval": 60}}}`,
+		"b.json": `{"queries": {"time": {"query": "select * from time;", "interval": 3600}}}`,
+		"c.json": `{"queries": {"time": {"query": "select unix_time from time;", "interval": 60}}}`,
+		"d.txt":  `not a pack`,
+	}
+	for name, contents := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600))
+	}
+
+	// The files matched by both the pattern and the directory are converted
+	// once.
+	out, stderr, err := runConvertForTest(t, []string{"convert", "-f", filepath.Join(dir, "*.json"), "-f", dir})
+	require.NoError(t, err)
+	specs, err := specGroupFromBytes([]byte(out))
+	require.NoError(t, err)
+	require.Len(t, specs.Packs, 3)
+
+	// The same query in two packs is kept once, a different query with the
+	// same name is renamed.
+	assert.Equal(t, []*fleet.QuerySpec{
+		{Name: "time", Query: "select * from time;"},
+		{Name: "time_c", Query: "select unix_time from time;"},
+	}, specs.Queries)
+	assert.Equal(t, "time", specs.Packs[1].Queries[0].QueryName)
+	assert.Equal(t, "time", specs.Packs[2].Queries[0].Name)
+	assert.Equal(t, "time_c", specs.Packs[2].Queries[0].QueryName)
+	assert.Contains(t, stderr, `renaming query "time" from "c" to "time_c"`)
+
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", filepath.Join(dir, "*.yml")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no files match")
+
+	// Packs are named after their files, which must be unique.
+	other := filepath.Join(t.TempDir(), "a.json")
+	require.NoError(t, ioutil.WriteFile(other, []byte(files["a.json"]), 0600))
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", dir, "-f", other})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `pack "a" is defined twice`)
+}
+
+func TestConvertOsqueryConfig(t *testing.T) {
+	filename := writeTempPack(t, `{
+  "options": {"host_identifier": "uuid", "schedule_splay_percent": 10},
+  "decorators": {"load": ["SELECT uuid AS host_uuid FROM system_info;"]},
+  "file_paths": {"etc": ["/etc/%%"]},
+  "schedule": {
+    "uptime": {"query": "select * from uptime;", "interval": 3600, "mitre": "T1082"},
+    "file_events": {"query": "select * from file_events;", "interval": 300, "removed": false}
+  },
+  "packs": {
+    "inline": {
+      "queries": {"crontab": {"query": "select * from crontab;", "interval": 86400, "platform": "linux", "mitre": "T1053"}}
+    },
+    "osquery-monitoring": "/usr/share/osquery/packs/osquery-monitoring.conf"
+  }
+}`)
+	name := packNameFromPath(filename)
+
+	stdout, stderr, err := runConvertForTest(t, []string{"convert", "-f", filename, "--preserve-field", "mitre"})
+	require.NoError(t, err)
+	assert.Contains(t, stderr, `skipping pack "osquery-monitoring", its queries are in /usr/share/osquery/packs/osquery-monitoring.conf`)
+
+	specs, err := specGroupFromBytes([]byte(stdout))
+	require.NoError(t, err)
+	require.NotNil(t, specs.AppConfig)
+	assert.JSONEq(t, `{"config": {
+		"options": {"host_identifier": "uuid", "schedule_splay_percent": 10},
+		"file_paths": {"etc": ["/etc/%%"]}
+	}}`, string(*specs.AppConfig.AgentOptions))
+	assert.Equal(t, &fleet.DecoratorConfig{Load: []string{"SELECT uuid AS host_uuid FROM system_info;"}}, specs.Decorators)
+
+	packs := make(map[string][]string)
+	for _, pack := range specs.Packs {
+		for _, query := range pack.Queries {
+			packs[pack.Name] = append(packs[pack.Name], query.Name)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		name:     {"file_events", "uptime"},
+		"inline": {"crontab"},
+	}, packs)
+
+	annotations := make(map[string]interface{})
+	for _, query := range specs.Queries {
+		annotations[query.Name] = query.Annotations["mitre"]
+	}
+	assert.Equal(t, map[string]interface{}{"file_events": nil, "uptime": "T1082", "crontab": "T1053"}, annotations)
+
+	// A query can't be both a pack query and a scheduled query.
+	filename = writeTempPack(t, `{
+  "queries": {"uptime": {"query": "select * from uptime;", "interval": 60}},
+  "schedule": {"uptime": {"query": "select * from uptime;", "interval": 3600}}
+}`)
+	_, _, err = runConvertForTest(t, []string{"convert", "-f", filename})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "both in queries and schedule")
+}
+
+func TestConvertContentHash(t *testing.T) {
+	pack := `{
+  "queries": {
+    "time": {"query": "%s", "interval": 60},
+    "uptime": {"query": "select * from uptime;", "interval": 60}
+  }
+}`
+	filename := writeTempPack(t, fmt.Sprintf(pack, "select * from time;"))
+
+	hashes := func(args []string) map[string]string {
+		out := runAppForTest(t, args)
+		result := make(map[string]string)
+		for _, doc := range splitYaml(out) {
+			var meta specMetadata
+			require.NoError(t, yaml.Unmarshal([]byte(doc), &meta))
+			var spec struct {
+				Name string `json:"name"`
+			}
+			require.NoError(t, yaml.Unmarshal(meta.Spec, &spec))
+			require.Len(t, meta.Metadata["content_hash"], 64)
+			result[meta.Kind+"/"+spec.Name] = meta.Metadata["content_hash"]
+		}
+		return result
+	}
+
+	first := hashes([]string{"convert", "-f", filename, "--content-hash"})
+	require.Len(t, first, 3)
+
+	// The hashes are stable, and don't depend on the annotations.
+	assert.Equal(t, first, hashes([]string{"convert", "-f", filename, "--content-hash"}))
+	assert.Equal(t, first, hashes([]string{"convert", "-f", filename, "--content-hash", "--provenance", "--author", "jane"}))
+
+	// Changing the SQL of a query only changes its hash.
+	require.NoError(t, ioutil.WriteFile(filename, []byte(fmt.Sprintf(pack, "select unix_time from time;")), 0600))
+	changed := hashes([]string{"convert", "-f", filename, "--content-hash"})
+	for name, hash := range first {
+		if name == fleet.QueryKind+"/time" {
+			assert.NotEqual(t, hash, changed[name])
+		} else {
+			assert.Equal(t, hash, changed[name], name)
+		}
+	}
+
+	// Without the flag no metadata is emitted.
+	out := runAppForTest(t, []string{"convert", "-f", filename})
+	assert.NotContains(t, out, "metadata")
+}
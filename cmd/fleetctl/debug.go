@@ -28,6 +28,7 @@ func debugCommand() *cli.Command {
 			debugHeapCommand(),
 			debugGoroutineCommand(),
 			debugTraceCommand(),
+			debugErrorsCommand(),
 			debugArchiveCommand(),
 		},
 	}
@@ -238,10 +239,45 @@ func debugTraceCommand() *cli.Command {
 	}
 }
 
+func debugErrorsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "errors",
+		Usage: "Get the errors recently logged by the Fleet server.",
+		Flags: []cli.Flag{
+			outfileFlag(),
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			fleet, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			errs, err := fleet.DebugErrors()
+			if err != nil {
+				return err
+			}
+
+			if outfile := getOutfile(c); outfile != "" {
+				if err := writeFile(outfile, errs, defaultFileMode); err != nil {
+					return errors.Wrap(err, "write errors to file")
+				}
+				return nil
+			}
+
+			fmt.Print(string(errs))
+
+			return nil
+		},
+	}
+}
+
 func debugArchiveCommand() *cli.Command {
 	return &cli.Command{
 		Name:  "archive",
-		Usage: "Create an archive with the entire suite of debug profiles.",
+		Usage: "Create an archive with the entire suite of debug profiles, the database statistics and the recent errors.",
 		Flags: []cli.Flag{
 			outfileFlag(),
 			configFlag(),
@@ -265,6 +301,20 @@ func debugArchiveCommand() *cli.Command {
 				"threadcreate",
 				"trace",
 			}
+			type archiveEntry struct {
+				name string
+				get  func() ([]byte, error)
+			}
+			var entries []archiveEntry
+			for _, profile := range profiles {
+				profile := profile
+				entries = append(entries, archiveEntry{profile, func() ([]byte, error) { return fleet.DebugPprof(profile) }})
+			}
+			entries = append(entries,
+				archiveEntry{"db-stats.json", fleet.DebugDBStats},
+				archiveEntry{"migrations.json", fleet.DebugMigrations},
+				archiveEntry{"errors.json", fleet.DebugErrors},
+			)
 
 			outpath := getOutfile(c)
 			if outpath == "" {
@@ -282,29 +332,29 @@ func debugArchiveCommand() *cli.Command {
 			tarwriter := tar.NewWriter(gzwriter)
 			defer tarwriter.Close()
 
-			for _, profile := range profiles {
-				res, err := fleet.DebugPprof(profile)
+			for _, entry := range entries {
+				res, err := entry.get()
 				if err != nil {
 					// Don't fail the entire process on errors. We'll take what
 					// we can get if the servers are in a bad state and not
 					// responding to all requests.
-					fmt.Fprintf(os.Stderr, "Failed %s: %v\n", profile, err)
+					fmt.Fprintf(os.Stderr, "Failed %s: %v\n", entry.name, err)
 					continue
 				}
-				fmt.Fprintf(os.Stderr, "Ran %s\n", profile)
+				fmt.Fprintf(os.Stderr, "Ran %s\n", entry.name)
 
 				if err := tarwriter.WriteHeader(
 					&tar.Header{
-						Name: outpath + "/" + profile,
+						Name: outpath + "/" + entry.name,
 						Size: int64(len(res)),
 						Mode: defaultFileMode,
 					},
 				); err != nil {
-					return errors.Wrapf(err, "write %s header", profile)
+					return errors.Wrapf(err, "write %s header", entry.name)
 				}
 
 				if _, err := tarwriter.Write(res); err != nil {
-					return errors.Wrapf(err, "write %s contents", profile)
+					return errors.Wrapf(err, "write %s contents", entry.name)
 				}
 			}
 
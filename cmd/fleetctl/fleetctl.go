@@ -37,15 +37,18 @@ func createApp(reader io.Reader, writer io.Writer, exitErrHandler cli.ExitErrHan
 	app.Reader = reader
 	app.Writer = writer
 	app.ErrWriter = writer
+	app.EnableBashCompletion = true
 
 	app.Commands = []*cli.Command{
 		applyCommand(),
+		gitopsCommand(),
 		deleteCommand(),
 		setupCommand(),
 		loginCommand(),
 		logoutCommand(),
 		queryCommand(),
 		getCommand(),
+		apiCommand(),
 		&cli.Command{
 			Name:  "config",
 			Usage: "Modify Fleet server connection settings",
@@ -59,6 +62,8 @@ func createApp(reader io.Reader, writer io.Writer, exitErrHandler cli.ExitErrHan
 		userCommand(),
 		debugCommand(),
 		previewCommand(),
+		packageCommand(),
+		completionCommand(),
 		eefleetctl.UpdatesCommand(),
 	}
 	return app
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"strconv"
+	"time"
 
 	"gopkg.in/guregu/null.v3"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/service"
 	"github.com/ghodss/yaml"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
@@ -22,6 +26,9 @@ const (
 	withQueriesFlagName = "with-queries"
 	expiredFlagName     = "expired"
 	stdoutFlagName      = "stdout"
+	csvFlagName         = "csv"
+	statusFlagName      = "status"
+	labelFlagName       = "label"
 )
 
 type specGeneric struct {
@@ -207,15 +214,17 @@ func getCommand() *cli.Command {
 			getCarveCommand(),
 			getCarvesCommand(),
 			getUserRolesCommand(),
+			getSoftwareCommand(),
 		},
 	}
 }
 
 func getQueriesCommand() *cli.Command {
 	return &cli.Command{
-		Name:    "queries",
-		Aliases: []string{"query", "q"},
-		Usage:   "List information about one or more queries",
+		Name:         "queries",
+		Aliases:      []string{"query", "q"},
+		Usage:        "List information about one or more queries",
+		BashComplete: nameCompletion("", queryNames),
 		Flags: []cli.Flag{
 			jsonFlag(),
 			yamlFlag(),
@@ -284,9 +293,10 @@ func getQueriesCommand() *cli.Command {
 
 func getPacksCommand() *cli.Command {
 	return &cli.Command{
-		Name:    "packs",
-		Aliases: []string{"pack", "p"},
-		Usage:   "List information about one or more packs",
+		Name:         "packs",
+		Aliases:      []string{"pack", "p"},
+		Usage:        "List information about one or more packs",
+		BashComplete: nameCompletion("", packNames),
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:  withQueriesFlagName,
@@ -535,12 +545,69 @@ func getAppConfigCommand() *cli.Command {
 	}
 }
 
+// hostsQuery returns the query of the list hosts request filtering the hosts
+// by status and label name.
+func hostsQuery(client *service.Client, status, label string) (string, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	if label != "" {
+		labels, err := client.ListLabels()
+		if err != nil {
+			return "", errors.Wrap(err, "could not list labels")
+		}
+		var labelID *uint
+		for _, l := range labels {
+			if l.Name == label {
+				labelID = &l.ID
+				break
+			}
+		}
+		if labelID == nil {
+			return "", errors.Errorf("label %q not found", label)
+		}
+		query.Set("label_id", strconv.FormatUint(uint64(*labelID), 10))
+	}
+	return query.Encode(), nil
+}
+
+var hostColumns = []string{"uuid", "hostname", "platform", "osquery_version", "status", "last_seen", "team"}
+
+func hostRow(host service.HostResponse) []string {
+	lastSeen := ""
+	if !host.Host.SeenTime.IsZero() {
+		lastSeen = host.Host.SeenTime.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		host.Host.UUID,
+		host.DisplayText,
+		host.Host.Platform,
+		host.OsqueryVersion,
+		string(host.Status),
+		lastSeen,
+		null.StringFromPtr(host.Host.TeamName).ValueOrZero(),
+	}
+}
+
 func getHostsCommand() *cli.Command {
 	return &cli.Command{
 		Name:    "hosts",
 		Aliases: []string{"host", "h"},
 		Usage:   "List information about one or more hosts",
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  statusFlagName,
+				Usage: "Only list the hosts with this status: new, online, offline or mia",
+			},
+			&cli.StringFlag{
+				Name:  labelFlagName,
+				Usage: "Only list the hosts that are members of the label with this name",
+			},
+			&cli.BoolFlag{
+				Name:  csvFlagName,
+				Usage: "Output in CSV format",
+			},
 			jsonFlag(),
 			yamlFlag(),
 			configFlag(),
@@ -556,14 +623,13 @@ func getHostsCommand() *cli.Command {
 			identifier := c.Args().First()
 
 			if identifier == "" {
-				hosts, err := client.GetHosts()
+				query, err := hostsQuery(client, c.String(statusFlagName), c.String(labelFlagName))
 				if err != nil {
-					return errors.Wrap(err, "could not list hosts")
+					return err
 				}
-
-				if len(hosts) == 0 {
-					fmt.Println("No hosts found")
-					return nil
+				hosts, err := client.GetHosts(query)
+				if err != nil {
+					return errors.Wrap(err, "could not list hosts")
 				}
 
 				if c.Bool(jsonFlagName) || c.Bool(yamlFlagName) {
@@ -576,21 +642,35 @@ func getHostsCommand() *cli.Command {
 					return nil
 				}
 
+				// The header is written even without hosts, for the scripts
+				// reading the columns.
+				if c.Bool(csvFlagName) {
+					w := csv.NewWriter(c.App.Writer)
+					if err := w.Write(hostColumns); err != nil {
+						return err
+					}
+					for _, host := range hosts {
+						if err := w.Write(hostRow(host)); err != nil {
+							return err
+						}
+					}
+					w.Flush()
+					return w.Error()
+				}
+
+				if len(hosts) == 0 {
+					log(c, "No hosts found\n")
+					return nil
+				}
+
 				// Default to printing as table
 				data := [][]string{}
 
 				for _, host := range hosts {
-					data = append(data, []string{
-						host.Host.UUID,
-						host.DisplayText,
-						host.Host.Platform,
-						host.OsqueryVersion,
-						string(host.Status),
-					})
+					data = append(data, hostRow(host))
 				}
 
-				columns := []string{"uuid", "hostname", "platform", "osquery_version", "status"}
-				printTable(c, columns, data)
+				printTable(c, hostColumns, data)
 			} else {
 				host, err := client.HostByIdentifier(identifier)
 				if err != nil {
@@ -802,6 +882,62 @@ func getUserRolesCommand() *cli.Command {
 	}
 }
 
+func getSoftwareCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "software",
+		Aliases: []string{"s"},
+		Usage:   "List the software installed on the hosts",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  csvFlagName,
+				Usage: "Output the full software inventory, with vulnerabilities, in CSV format",
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			if c.Bool(csvFlagName) {
+				if err := client.ExportSoftware(c.App.Writer); err != nil {
+					return errors.Wrap(err, "could not export software")
+				}
+				return nil
+			}
+
+			software, err := client.ListSoftware()
+			if err != nil {
+				return errors.Wrap(err, "could not list software")
+			}
+
+			if len(software) == 0 {
+				log(c, "No software found")
+				return nil
+			}
+
+			// Default to printing as table
+			data := [][]string{}
+
+			for _, s := range software {
+				data = append(data, []string{
+					s.Name,
+					s.Version,
+					s.Source,
+					strconv.Itoa(s.HostsCount),
+				})
+			}
+			columns := []string{"Name", "Version", "Source", "Hosts"}
+			printTable(c, columns, data)
+
+			return nil
+		},
+	}
+}
+
 func printTable(c *cli.Context, columns []string, data [][]string) {
 	table := defaultTable(c.App.Writer)
 	table.SetHeader(columns)
@@ -7,6 +7,7 @@ import (
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var userRoleList = []*fleet.User{
@@ -81,3 +82,87 @@ spec:
 	assert.Equal(t, expectedYaml, runAppForTest(t, []string{"get", "user_roles", "--yaml"}))
 	assert.Equal(t, expectedJson, runAppForTest(t, []string{"get", "user_roles", "--json"}))
 }
+
+func TestGetSoftware(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	software := []fleet.Software{
+		{
+			ID: 1, Name: "openssl", Version: "1.1.1f", Source: "deb_packages", HostsCount: 2,
+			Vulnerabilities: fleet.VulnerabilitiesSlice{{CVE: "CVE-2021-3449"}, {CVE: "CVE-2021-3450"}},
+		},
+		{ID: 2, Name: "Notes, by Example.app", Version: "4.9", Source: "apps", HostsCount: 1},
+	}
+	ds.ListSoftwareFunc = func(opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+		return software, nil
+	}
+	ds.LoadSoftwareVulnerabilitiesFunc = func(software []fleet.Software) error {
+		return nil
+	}
+	ds.ExportSoftwareFunc = func(fn func(fleet.Software) error) error {
+		for _, s := range software {
+			if err := fn(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	expectedText := `+-----------------------+---------+--------------+-------+
+|         NAME          | VERSION |    SOURCE    | HOSTS |
++-----------------------+---------+--------------+-------+
+| openssl               | 1.1.1f  | deb_packages |     2 |
++-----------------------+---------+--------------+-------+
+| Notes, by Example.app |     4.9 | apps         |     1 |
++-----------------------+---------+--------------+-------+
+`
+	expectedCSV := `name,version,source,hosts_count,vulnerabilities
+openssl,1.1.1f,deb_packages,2,"CVE-2021-3449, CVE-2021-3450"
+"Notes, by Example.app",4.9,apps,1,
+`
+
+	assert.Equal(t, expectedText, runAppForTest(t, []string{"get", "software"}))
+	assert.Equal(t, expectedCSV, runAppForTest(t, []string{"get", "software", "--csv"}))
+}
+
+func TestGetHosts(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	seen := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	var gotOpt fleet.HostListOptions
+	ds.ListHostsFunc = func(filter fleet.TeamFilter, opt fleet.HostListOptions) ([]*fleet.Host, error) {
+		gotOpt = opt
+		return []*fleet.Host{
+			{
+				UUID: "uuid-1", Hostname: "host1", Platform: "ubuntu", OsqueryVersion: "4.9.0",
+				SeenTime: seen, TeamName: ptr.String("servers"),
+			},
+			{UUID: "uuid-2", Hostname: "host2", Platform: "darwin", OsqueryVersion: "4.8.0"},
+		}, nil
+	}
+	ds.ListLabelsFunc = func(filter fleet.TeamFilter, opt fleet.ListOptions) ([]*fleet.Label, error) {
+		return []*fleet.Label{{ID: 7, Name: "Ubuntu"}}, nil
+	}
+	ds.AppConfigFunc = func() (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+
+	expectedCSV := `uuid,hostname,platform,osquery_version,status,last_seen,team
+uuid-1,host1,ubuntu,4.9.0,mia,2021-06-01T12:00:00Z,servers
+uuid-2,host2,darwin,4.8.0,mia,,
+`
+	assert.Equal(t, expectedCSV, runAppForTest(t, []string{"get", "hosts", "--status", "online", "--label", "Ubuntu", "--csv"}))
+	assert.Equal(t, fleet.StatusOnline, gotOpt.StatusFilter)
+	require.NotNil(t, gotOpt.LabelFilter)
+	assert.Equal(t, uint(7), *gotOpt.LabelFilter)
+
+	table := runAppForTest(t, []string{"get", "hosts"})
+	assert.Contains(t, table, "LAST SEEN")
+	assert.Contains(t, table, "servers")
+	assert.Nil(t, gotOpt.LabelFilter)
+
+	_, _, err := runConvertForTest(t, []string{"get", "hosts", "--label", "Windows"})
+	assert.EqualError(t, err, `label "Windows" not found`)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/service"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// prunedSpecs returns the queries, packs and labels of the server that are
+// not in the desired specs. Built-in labels are never pruned.
+func prunedSpecs(existing, desired *specGroup) *specGroup {
+	pruned := &specGroup{}
+
+	queries := make(map[string]bool)
+	for _, query := range desired.Queries {
+		queries[query.Name] = true
+	}
+	for _, query := range existing.Queries {
+		if !queries[query.Name] {
+			pruned.Queries = append(pruned.Queries, query)
+		}
+	}
+
+	packs := make(map[string]bool)
+	for _, pack := range desired.Packs {
+		packs[pack.Name] = true
+	}
+	for _, pack := range existing.Packs {
+		if !packs[pack.Name] {
+			pruned.Packs = append(pruned.Packs, pack)
+		}
+	}
+
+	labels := make(map[string]bool)
+	for _, label := range desired.Labels {
+		labels[label.Name] = true
+	}
+	for _, label := range existing.Labels {
+		if !labels[label.Name] && label.LabelType != fleet.LabelTypeBuiltIn {
+			pruned.Labels = append(pruned.Labels, label)
+		}
+	}
+
+	return pruned
+}
+
+// serverSpecs fetches the queries, packs and labels currently on the server.
+func serverSpecs(fleetClient *service.Client) (*specGroup, error) {
+	queries, err := fleetClient.GetQueries()
+	if err != nil {
+		return nil, errors.Wrap(err, "get queries")
+	}
+	packs, err := fleetClient.GetPacks()
+	if err != nil {
+		return nil, errors.Wrap(err, "get packs")
+	}
+	labels, err := fleetClient.GetLabels()
+	if err != nil {
+		return nil, errors.Wrap(err, "get labels")
+	}
+	return &specGroup{Queries: queries, Packs: packs, Labels: labels}, nil
+}
+
+// deleteSpecs deletes the specs, packs first as they schedule the queries and
+// target the labels.
+func deleteSpecs(c *cli.Context, fleetClient *service.Client, specs *specGroup) error {
+	for _, pack := range specs.Packs {
+		if err := fleetClient.DeletePack(pack.Name); err != nil {
+			return errors.Wrapf(err, "deleting pack %s", pack.Name)
+		}
+		logf(c, "[-] deleted pack %q\n", pack.Name)
+	}
+	for _, query := range specs.Queries {
+		if err := fleetClient.DeleteQuery(query.Name); err != nil {
+			return errors.Wrapf(err, "deleting query %s", query.Name)
+		}
+		logf(c, "[-] deleted query %q\n", query.Name)
+	}
+	for _, label := range specs.Labels {
+		if err := fleetClient.DeleteLabel(label.Name); err != nil {
+			return errors.Wrapf(err, "deleting label %s", label.Name)
+		}
+		logf(c, "[-] deleted label %q\n", label.Name)
+	}
+	return nil
+}
+
+func gitopsCommand() *cli.Command {
+	var (
+		flDryRun    bool
+		flExpandEnv bool
+	)
+	return &cli.Command{
+		Name:      "gitops",
+		Usage:     "Apply files as the complete desired state, deleting the queries, packs and labels they don't define",
+		UsageText: `fleetctl gitops [options]`,
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:    "f",
+				EnvVars: []string{"FILENAME"},
+				Usage:   "A file, or a directory of YAML files, defining the desired state (multiple may be specified)",
+			},
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Destination: &flDryRun,
+				Usage:       "Validate the specs on the server and print the changes, including the deletions, without applying them",
+			},
+			&cli.BoolFlag{
+				Name:        "expand-env",
+				Destination: &flExpandEnv,
+				Usage:       "Replace $VAR and ${VAR} in the files with the values of the environment variables",
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			if len(c.StringSlice("f")) == 0 {
+				return errors.New("-f must be specified")
+			}
+			filenames, err := specInputs(c.StringSlice("f"))
+			if err != nil {
+				return err
+			}
+			if len(filenames) == 0 {
+				return errors.New("no YAML files found")
+			}
+			specs, err := specGroupFromFiles(filenames, flExpandEnv)
+			if err != nil {
+				return err
+			}
+			// Files without any of the pruned kinds are more likely a
+			// mistake than a request to delete everything.
+			if len(specs.Queries) == 0 && len(specs.Packs) == 0 && len(specs.Labels) == 0 {
+				return errors.New("the files define no queries, packs or labels, refusing to delete all of them")
+			}
+
+			warnUnstoredFields(c, specs)
+
+			fleetClient, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			changes, err := validateSpecs(c, fleetClient, specs)
+			if err != nil {
+				return errors.Wrap(err, "nothing was applied")
+			}
+			existing, err := serverSpecs(fleetClient)
+			if err != nil {
+				return err
+			}
+			pruned := prunedSpecs(existing, specs)
+
+			if !flDryRun {
+				if err := applySpecs(c, fleetClient, specs); err != nil {
+					return err
+				}
+				if err := deleteSpecs(c, fleetClient, pruned); err != nil {
+					return err
+				}
+				log(c, "\nChanges:\n")
+			}
+
+			for _, pack := range pruned.Packs {
+				changes = append(changes, &fleet.SpecChange{Kind: fleet.PackKind, Name: pack.Name, Action: fleet.SpecChangeDelete})
+			}
+			for _, query := range pruned.Queries {
+				changes = append(changes, &fleet.SpecChange{Kind: fleet.QueryKind, Name: query.Name, Action: fleet.SpecChangeDelete})
+			}
+			for _, label := range pruned.Labels {
+				changes = append(changes, &fleet.SpecChange{Kind: fleet.LabelKind, Name: label.Name, Action: fleet.SpecChangeDelete})
+			}
+			printSpecChanges(c, changes)
+			return nil
+		},
+	}
+}
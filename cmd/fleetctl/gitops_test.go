@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrunedSpecs(t *testing.T) {
+	existing := &specGroup{
+		Queries: []*fleet.QuerySpec{{Name: "time"}, {Name: "uptime"}},
+		Packs:   []*fleet.PackSpec{{Name: "pack"}, {Name: "old"}},
+		Labels: []*fleet.LabelSpec{
+			{Name: "All Hosts", LabelType: fleet.LabelTypeBuiltIn},
+			{Name: "macOS"},
+			{Name: "old"},
+		},
+	}
+	desired := &specGroup{
+		Queries: []*fleet.QuerySpec{{Name: "time"}, {Name: "processes"}},
+		Packs:   []*fleet.PackSpec{{Name: "pack"}},
+		Labels:  []*fleet.LabelSpec{{Name: "macOS"}},
+	}
+
+	pruned := prunedSpecs(existing, desired)
+	assert.Equal(t, []*fleet.QuerySpec{{Name: "uptime"}}, pruned.Queries)
+	assert.Equal(t, []*fleet.PackSpec{{Name: "old"}}, pruned.Packs)
+	assert.Equal(t, []*fleet.LabelSpec{{Name: "old"}}, pruned.Labels)
+}
+
+func TestGitops(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.ListQueriesFunc = func(opt fleet.ListOptions) ([]*fleet.Query, error) {
+		return []*fleet.Query{
+			{Name: "time", Query: "select * from time;"},
+			{Name: "uptime", Query: "select * from uptime;"},
+		}, nil
+	}
+	ds.GetLabelSpecsFunc = func() ([]*fleet.LabelSpec, error) {
+		return []*fleet.LabelSpec{
+			{Name: "All Hosts", Query: "select 1;", LabelType: fleet.LabelTypeBuiltIn},
+			{Name: "old", Query: "select 1;"},
+		}, nil
+	}
+	ds.GetPackSpecsFunc = func() ([]*fleet.PackSpec, error) {
+		return []*fleet.PackSpec{{Name: "old"}}, nil
+	}
+	ds.NewActivityFunc = func(user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	ds.ApplyQueriesFunc = func(authorID uint, queries []*fleet.Query) error {
+		return nil
+	}
+	var deleted []string
+	ds.DeletePackFunc = func(name string) error {
+		deleted = append(deleted, "pack "+name)
+		return nil
+	}
+	ds.DeleteQueryFunc = func(name string) error {
+		deleted = append(deleted, "query "+name)
+		return nil
+	}
+	ds.DeleteLabelFunc = func(name string) error {
+		deleted = append(deleted, "label "+name)
+		return nil
+	}
+
+	filename := filepath.Join(t.TempDir(), "queries.yml")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`
+apiVersion: v1
+kind: query
+spec:
+  name: time
+  query: select * from time;
+`), 0600))
+
+	assert.Equal(t, `- pack "old"
+- query "uptime"
+- label "old"
+`, runAppForTest(t, []string{"gitops", "-f", filename, "--dry-run"}))
+	assert.Empty(t, deleted)
+
+	assert.Equal(t, `[+] applied 1 queries
+[-] deleted pack "old"
+[-] deleted query "uptime"
+[-] deleted label "old"
+
+Changes:
+- pack "old"
+- query "uptime"
+- label "old"
+`, runAppForTest(t, []string{"gitops", "-f", filename}))
+	assert.Equal(t, []string{"pack old", "query uptime", "label old"}, deleted)
+
+	// Files defining none of the pruned kinds don't delete everything.
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`
+apiVersion: v1
+kind: enroll_secret
+spec:
+  secrets:
+  - secret: abc
+`), 0600))
+	_, _, err := runConvertForTest(t, []string{"gitops", "-f", filename})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing to delete all of them")
+}
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
+	"runtime"
+	"time"
 
 	"github.com/fleetdm/fleet/v4/server/service"
 	"github.com/pkg/errors"
@@ -10,10 +18,87 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 )
 
+// ssoLoginTimeout is how long fleetctl login --sso waits for the user to log
+// in with the identity provider.
+const ssoLoginTimeout = 5 * time.Minute
+
+// ssoCallbackPath is the path of the relay URL that fleetctl login --sso
+// listens on for the token.
+const ssoCallbackPath = "/callback"
+
+// openBrowser opens the URL in the default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+// ssoLogin logs in with the identity provider in the browser and returns the
+// token of the session. Fleet redirects the browser to a local listener with
+// the token once the user is logged in.
+func ssoLogin(c *cli.Context, fleet *service.Client, open func(string) error, timeout time.Duration) (string, error) {
+	// The state ties the callback to this login, other local processes or
+	// pages can't make fleetctl use a token of their choice without it.
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.Wrap(err, "generate the SSO state")
+	}
+	state := hex.EncodeToString(nonce)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", errors.Wrap(err, "listen for the SSO callback")
+	}
+	tokens := make(chan string, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != ssoCallbackPath || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+			http.Error(w, "Fleet login failed: the SSO callback is not for this login.", http.StatusBadRequest)
+			return
+		}
+		token := query.Get("token")
+		if token == "" {
+			http.Error(w, "Fleet login failed: the SSO callback has no token.", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Fleet login successful, you may close this window and return to fleetctl.")
+		select {
+		case tokens <- token:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	idpURL, err := fleet.InitiateSSO("http://" + listener.Addr().String() + ssoCallbackPath + "?state=" + state)
+	if err != nil {
+		return "", err
+	}
+
+	logf(c, "Log in with SSO in the browser. If it doesn't open, visit:\n\n  %s\n\n", idpURL)
+	// The URL is printed for when there's no browser to open.
+	_ = open(idpURL)
+
+	select {
+	case token := <-tokens:
+		return token, nil
+	case <-time.After(timeout):
+		return "", errors.Errorf("timed out after %s waiting for the SSO login", timeout)
+	}
+}
+
 func loginCommand() *cli.Command {
 	var (
 		flEmail    string
 		flPassword string
+		flSSO      bool
 	)
 	return &cli.Command{
 		Name:  "login",
@@ -22,6 +107,8 @@ func loginCommand() *cli.Command {
 fleetctl login [options]
 
 Interactively prompts for email and password if not specified in the flags or environment variables.
+
+With --sso, opens the login page of the identity provider in the browser instead.
 `,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
@@ -38,6 +125,11 @@ Interactively prompts for email and password if not specified in the flags or en
 				Destination: &flPassword,
 				Usage:       "Password to use to log in (recommended to use interactive entry)",
 			},
+			&cli.BoolFlag{
+				Name:        "sso",
+				Destination: &flSSO,
+				Usage:       "Log in with SSO in the browser",
+			},
 			configFlag(),
 			contextFlag(),
 			debugFlag(),
@@ -48,6 +140,13 @@ Interactively prompts for email and password if not specified in the flags or en
 				return err
 			}
 
+			if flSSO {
+				if flEmail != "" || flPassword != "" {
+					return errors.New("--email and --password can't be used with --sso")
+				}
+				return loginSSO(c, fleet)
+			}
+
 			// Allow interactive entry to discourage passwords in
 			// CLI history.
 			if flEmail == "" {
@@ -93,3 +192,32 @@ Interactively prompts for email and password if not specified in the flags or en
 		},
 	}
 }
+
+// loginSSO logs in with SSO and configures the context with the token and
+// the email of the user.
+func loginSSO(c *cli.Context, fleet *service.Client) error {
+	token, err := ssoLogin(c, fleet, openBrowser, ssoLoginTimeout)
+	if err != nil {
+		return errors.Wrap(err, "Login failed")
+	}
+
+	fleet.SetToken(token)
+	user, err := fleet.Me()
+	if err != nil {
+		return errors.Wrap(err, "get logged in user")
+	}
+
+	configPath, context := c.String("config"), c.String("context")
+
+	if err := setConfigValue(configPath, context, "email", user.Email); err != nil {
+		return errors.Wrap(err, "error setting email for the current context")
+	}
+
+	if err := setConfigValue(configPath, context, "token", token); err != nil {
+		return errors.Wrap(err, "error setting token for the current context")
+	}
+
+	logf(c, "[+] Fleet login successful and context configured!\n")
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// ssoClientForTest returns a client of a Fleet server whose identity provider
+// redirects to the relay URL, like the Fleet SSO callback does once the user
+// is logged in.
+func ssoClientForTest(t *testing.T) *service.Client {
+	fleetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/fleet/sso", r.URL.Path)
+		var req struct {
+			RelayURL string `json:"relay_url"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		idpURL := "https://idp.example.com/sso?RelayState=" + url.QueryEscape(req.RelayURL)
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]string{"url": idpURL}))
+	}))
+	t.Cleanup(fleetServer.Close)
+	client, err := service.NewClient(fleetServer.URL, false, "", "")
+	require.NoError(t, err)
+	return client
+}
+
+func TestSSOLogin(t *testing.T) {
+	client := ssoClientForTest(t)
+
+	var opened string
+	browser := func(idpURL string) error {
+		opened = idpURL
+		u, err := url.Parse(idpURL)
+		require.NoError(t, err)
+		relayURL, err := url.Parse(u.Query().Get("RelayState"))
+		require.NoError(t, err)
+		state := relayURL.Query().Get("state")
+		require.Len(t, state, 32)
+		callback := func(path string, query url.Values) int {
+			resp, err := http.Get("http://" + relayURL.Host + path + "?" + query.Encode())
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		// Requests without a token, like the favicon, don't end the login.
+		assert.Equal(t, http.StatusBadRequest, callback("/favicon.ico", nil))
+		assert.Equal(t, http.StatusBadRequest, callback("/callback", url.Values{"state": {state}}))
+		assert.Equal(t, http.StatusOK, callback("/callback", url.Values{"state": {state}, "token": {"abc"}}))
+		return nil
+	}
+
+	w := new(bytes.Buffer)
+	app := cli.NewApp()
+	app.Writer = w
+	token, err := ssoLogin(cli.NewContext(app, nil, nil), client, browser, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token)
+	assert.Regexp(t, `^https://idp.example.com/sso\?RelayState=http%3A%2F%2F127.0.0.1%3A\d+%2Fcallback%3Fstate%3D[0-9a-f]{32}$`, opened)
+	assert.Contains(t, w.String(), opened)
+
+	// The login times out when the browser never reaches the listener.
+	_, err = ssoLogin(cli.NewContext(app, nil, nil), client, func(string) error { return nil }, 10*time.Millisecond)
+	assert.EqualError(t, err, "timed out after 10ms waiting for the SSO login")
+}
+
+// TestSSOLoginWrongState checks that callbacks with another state or path are
+// rejected, their token is never used.
+func TestSSOLoginWrongState(t *testing.T) {
+	client := ssoClientForTest(t)
+
+	browser := func(idpURL string) error {
+		u, err := url.Parse(idpURL)
+		require.NoError(t, err)
+		relayURL, err := url.Parse(u.Query().Get("RelayState"))
+		require.NoError(t, err)
+		state := relayURL.Query().Get("state")
+		for _, callback := range []string{
+			"/callback?state=" + strings.Repeat("0", 32) + "&token=evil",
+			"/callback?token=evil",
+			"/other?state=" + state + "&token=evil",
+		} {
+			resp, err := http.Get("http://" + relayURL.Host + callback)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, callback)
+		}
+		return nil
+	}
+	app := cli.NewApp()
+	app.Writer = new(bytes.Buffer)
+	_, err := ssoLogin(cli.NewContext(app, nil, nil), client, browser, 100*time.Millisecond)
+	assert.EqualError(t, err, "timed out after 100ms waiting for the SSO login")
+}
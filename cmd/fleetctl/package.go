@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// packageName is the name of the installed package on the platforms that
+// have one.
+const packageName = "fleet-osquery"
+
+// msiUpgradeCode is the same for all the msi packages, so that installing a
+// new package replaces the previous one.
+const msiUpgradeCode = "B8F5E0E4-6E4B-4A5E-9D0C-2F2E4C1B7A31"
+
+var packageVersionRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,2}$`)
+
+type packageOptions struct {
+	Type             string
+	FleetURL         string
+	EnrollSecret     string
+	FleetCertificate string
+	Insecure         bool
+	Identifier       string
+	Version          string
+	SignIdentity     string
+	Outfile          string
+}
+
+// packageLayout is where osquery reads its files on the platform of a package
+// type.
+type packageLayout struct {
+	Dir         string
+	Separator   string
+	Secret      string
+	Certificate string
+}
+
+func (l packageLayout) path(name string) string {
+	return l.Dir + l.Separator + name
+}
+
+var packageLayouts = map[string]packageLayout{
+	"deb": {Dir: "/etc/osquery", Separator: "/", Secret: "enroll_secret", Certificate: "fleet.crt"},
+	"rpm": {Dir: "/etc/osquery", Separator: "/", Secret: "enroll_secret", Certificate: "fleet.crt"},
+	"pkg": {Dir: "/private/var/osquery", Separator: "/", Secret: "enroll_secret", Certificate: "fleet.crt"},
+	"msi": {Dir: `C:\Program Files\osquery`, Separator: `\`, Secret: "secret.txt", Certificate: "fleet.pem"},
+}
+
+// packageFile is a file installed by the package, in the osquery directory
+// of the platform.
+type packageFile struct {
+	Name     string
+	Contents []byte
+	Mode     os.FileMode
+}
+
+// osqueryFlags returns the osquery flagfile enrolling the host in the Fleet
+// server at the URL, with the files installed at the paths of the layout.
+func osqueryFlags(opt packageOptions, layout packageLayout) (string, error) {
+	u, err := url.Parse(opt.FleetURL)
+	if err != nil {
+		return "", errors.Wrap(err, "parse --fleet-url")
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return "", errors.Errorf("--fleet-url must be an https URL, got %q", opt.FleetURL)
+	}
+	prefix := strings.TrimSuffix(u.Path, "/")
+
+	flags := []string{
+		"--tls_hostname=" + u.Host,
+		"--enroll_secret_path=" + layout.path(layout.Secret),
+	}
+	if opt.FleetCertificate != "" {
+		flags = append(flags, "--tls_server_certs="+layout.path(layout.Certificate))
+	}
+	if opt.Insecure {
+		flags = append(flags, "--insecure")
+	}
+	flags = append(flags,
+		"--host_identifier=instance",
+		"--enroll_tls_endpoint="+prefix+"/api/v1/osquery/enroll",
+		"--config_plugin=tls",
+		"--config_tls_endpoint="+prefix+"/api/v1/osquery/config",
+		"--config_refresh=10",
+		"--disable_distributed=false",
+		"--distributed_plugin=tls",
+		"--distributed_interval=10",
+		"--distributed_tls_max_attempts=3",
+		"--distributed_tls_read_endpoint="+prefix+"/api/v1/osquery/distributed/read",
+		"--distributed_tls_write_endpoint="+prefix+"/api/v1/osquery/distributed/write",
+		"--logger_plugin=tls",
+		"--logger_tls_endpoint="+prefix+"/api/v1/osquery/log",
+		"--logger_tls_period=10",
+	)
+	return strings.Join(flags, "\n") + "\n", nil
+}
+
+// packageFiles returns the flagfile, enroll secret and server certificate
+// installed by the package.
+func packageFiles(opt packageOptions, layout packageLayout) ([]packageFile, error) {
+	flags, err := osqueryFlags(opt, layout)
+	if err != nil {
+		return nil, err
+	}
+	files := []packageFile{
+		{Name: "osquery.flags", Contents: []byte(flags), Mode: 0644},
+		{Name: layout.Secret, Contents: []byte(opt.EnrollSecret), Mode: 0600},
+	}
+	if opt.FleetCertificate != "" {
+		cert, err := ioutil.ReadFile(opt.FleetCertificate)
+		if err != nil {
+			return nil, errors.Wrap(err, "read --fleet-certificate")
+		}
+		files = append(files, packageFile{Name: layout.Certificate, Contents: cert, Mode: 0644})
+	}
+	return files, nil
+}
+
+// writePackageFiles writes the files in dir, which is created if needed.
+func writePackageFiles(dir string, files []packageFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "create package directory")
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name)
+		if err := ioutil.WriteFile(path, f.Contents, f.Mode); err != nil {
+			return errors.Wrapf(err, "write %s", f.Name)
+		}
+		// Not restricted by the umask.
+		if err := os.Chmod(path, f.Mode); err != nil {
+			return errors.Wrapf(err, "chmod %s", f.Name)
+		}
+	}
+	return nil
+}
+
+func writeTemplate(path string, mode os.FileMode, tmpl *template.Template, data interface{}) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return errors.Wrapf(err, "execute %s template", tmpl.Name())
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "create directory of %s", path)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), mode); err != nil {
+		return errors.Wrapf(err, "write %s", path)
+	}
+	return os.Chmod(path, mode)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// runPackageTool runs a tool of the platform used to build or sign the
+// packages, including its output in the error if it fails.
+func runPackageTool(typ, name string, args ...string) error {
+	if _, err := exec.LookPath(name); err != nil {
+		return errors.Errorf("building %s packages requires %s in the PATH", typ, name)
+	}
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "%s failed:\n%s", name, out)
+	}
+	return nil
+}
+
+const linuxPostInstall = `if command -v systemctl >/dev/null 2>&1; then
+	systemctl enable osqueryd
+	systemctl restart osqueryd
+fi
+`
+
+var debControlTemplate = template.Must(template.New("control").Parse(`Package: ` + packageName + `
+Version: {{.Version}}
+Architecture: all
+Maintainer: Fleet
+Depends: osquery
+Description: osquery configuration enrolling the host in Fleet
+`))
+
+var debPostInstTemplate = template.Must(template.New("postinst").Parse("#!/bin/sh\nset -e\n" + linuxPostInstall))
+
+func buildDeb(opt packageOptions, files []packageFile, tmpDir string) error {
+	root := filepath.Join(tmpDir, "root")
+	layout := packageLayouts["deb"]
+	if err := writePackageFiles(filepath.Join(root, filepath.FromSlash(layout.Dir)), files); err != nil {
+		return err
+	}
+	if err := writeTemplate(filepath.Join(root, "DEBIAN", "control"), 0644, debControlTemplate, opt); err != nil {
+		return err
+	}
+	if err := writeTemplate(filepath.Join(root, "DEBIAN", "postinst"), 0755, debPostInstTemplate, opt); err != nil {
+		return err
+	}
+	if err := runPackageTool("deb", "dpkg-deb", "--build", "--root-owner-group", root, opt.Outfile); err != nil {
+		return err
+	}
+	if opt.SignIdentity != "" {
+		return runPackageTool("deb", "dpkg-sig", "-k", opt.SignIdentity, "--sign", "builder", opt.Outfile)
+	}
+	return nil
+}
+
+var rpmSpecTemplate = template.Must(template.New("spec").Parse(`Name: ` + packageName + `
+Version: {{.Opt.Version}}
+Release: 1
+Summary: osquery configuration enrolling the host in Fleet
+License: Proprietary
+BuildArch: noarch
+Requires: osquery
+
+%description
+osquery configuration enrolling the host in Fleet.
+
+%install
+mkdir -p %{buildroot}{{.Dir}}
+cp -a {{.Staged}}/. %{buildroot}{{.Dir}}/
+
+%files
+{{range .Files}}%attr({{printf "%04o" .Mode}}, root, root) {{$.Dir}}/{{.Name}}
+{{end}}
+%post
+` + linuxPostInstall))
+
+func buildRPM(opt packageOptions, files []packageFile, tmpDir string) error {
+	staged := filepath.Join(tmpDir, "files")
+	if err := writePackageFiles(staged, files); err != nil {
+		return err
+	}
+	spec := filepath.Join(tmpDir, packageName+".spec")
+	data := struct {
+		Opt    packageOptions
+		Dir    string
+		Staged string
+		Files  []packageFile
+	}{opt, packageLayouts["rpm"].Dir, staged, files}
+	if err := writeTemplate(spec, 0644, rpmSpecTemplate, data); err != nil {
+		return err
+	}
+	err := runPackageTool("rpm", "rpmbuild", "-bb",
+		"--define", "_topdir "+filepath.Join(tmpDir, "rpmbuild"),
+		"--define", "_rpmdir "+tmpDir,
+		"--define", "_build_name_fmt "+packageName+".rpm",
+		spec,
+	)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(filepath.Join(tmpDir, packageName+".rpm"), opt.Outfile); err != nil {
+		return errors.Wrap(err, "copy rpm")
+	}
+	if opt.SignIdentity != "" {
+		return runPackageTool("rpm", "rpmsign", "--addsign", "--define", "_gpg_name "+opt.SignIdentity, opt.Outfile)
+	}
+	return nil
+}
+
+var launchdPlistTemplate = template.Must(template.New("plist").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  <string>io.osquery.agent</string>
+  <key>ProgramArguments</key>
+  <array>
+    <string>/usr/local/bin/osqueryd</string>
+    <string>--flagfile={{.Dir}}/osquery.flags</string>
+  </array>
+  <key>RunAtLoad</key>
+  <true/>
+  <key>KeepAlive</key>
+  <true/>
+  <key>ThrottleInterval</key>
+  <integer>60</integer>
+</dict>
+</plist>
+`))
+
+var pkgPostInstallTemplate = template.Must(template.New("postinstall").Parse(`#!/bin/sh
+launchctl unload /Library/LaunchDaemons/io.osquery.agent.plist 2>/dev/null
+launchctl load /Library/LaunchDaemons/io.osquery.agent.plist
+`))
+
+func buildPkg(opt packageOptions, files []packageFile, tmpDir string) error {
+	root := filepath.Join(tmpDir, "root")
+	layout := packageLayouts["pkg"]
+	if err := writePackageFiles(filepath.Join(root, filepath.FromSlash(layout.Dir)), files); err != nil {
+		return err
+	}
+	plist := filepath.Join(root, "Library", "LaunchDaemons", "io.osquery.agent.plist")
+	if err := writeTemplate(plist, 0644, launchdPlistTemplate, layout); err != nil {
+		return err
+	}
+	scripts := filepath.Join(tmpDir, "scripts")
+	if err := writeTemplate(filepath.Join(scripts, "postinstall"), 0755, pkgPostInstallTemplate, opt); err != nil {
+		return err
+	}
+	args := []string{
+		"--root", root,
+		"--scripts", scripts,
+		"--identifier", opt.Identifier,
+		"--version", opt.Version,
+		"--install-location", "/",
+	}
+	if opt.SignIdentity != "" {
+		args = append(args, "--sign", opt.SignIdentity)
+	}
+	return runPackageTool("pkg", "pkgbuild", append(args, opt.Outfile)...)
+}
+
+var wixTemplate = template.Must(template.New("wxs").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<Wix xmlns="http://schemas.microsoft.com/wix/2006/wi">
+  <Product Id="*" Name="Fleet osquery" Language="1033" Version="{{.Opt.Version}}" Manufacturer="Fleet" UpgradeCode="` + msiUpgradeCode + `">
+    <Package InstallerVersion="500" Compressed="yes" InstallScope="perMachine" />
+    <MajorUpgrade DowngradeErrorMessage="A newer version of [ProductName] is already installed." />
+    <MediaTemplate EmbedCab="yes" />
+    <Directory Id="TARGETDIR" Name="SourceDir">
+      <Directory Id="ProgramFiles64Folder">
+        <Directory Id="OSQUERYDIR" Name="osquery">
+          <Component Id="FleetConfiguration" Guid="*" Win64="yes">
+{{- range $i, $f := .Files}}
+            <File Id="File{{$i}}" Name="{{$f.Name}}" Source="{{$.Staged}}\{{$f.Name}}" {{if eq $i 0}}KeyPath="yes" {{end}}/>
+{{- end}}
+            <ServiceControl Id="RestartOsqueryd" Name="osqueryd" Stop="both" Start="install" Wait="yes" />
+          </Component>
+        </Directory>
+      </Directory>
+    </Directory>
+    <Feature Id="Main" Level="1">
+      <ComponentRef Id="FleetConfiguration" />
+    </Feature>
+  </Product>
+</Wix>
+`))
+
+func buildMSI(opt packageOptions, files []packageFile, tmpDir string) error {
+	staged := filepath.Join(tmpDir, "files")
+	if err := writePackageFiles(staged, files); err != nil {
+		return err
+	}
+	wxs := filepath.Join(tmpDir, "main.wxs")
+	data := struct {
+		Opt    packageOptions
+		Staged string
+		Files  []packageFile
+	}{opt, staged, files}
+	if err := writeTemplate(wxs, 0644, wixTemplate, data); err != nil {
+		return err
+	}
+	obj := filepath.Join(tmpDir, "main.wixobj")
+	if err := runPackageTool("msi", "candle", "-nologo", "-arch", "x64", "-out", obj, wxs); err != nil {
+		return err
+	}
+	if err := runPackageTool("msi", "light", "-nologo", "-out", opt.Outfile, obj); err != nil {
+		return err
+	}
+	if opt.SignIdentity != "" {
+		return runPackageTool("msi", "signtool", "sign", "/n", opt.SignIdentity, "/fd", "sha256", opt.Outfile)
+	}
+	return nil
+}
+
+var packageBuilders = map[string]func(opt packageOptions, files []packageFile, tmpDir string) error{
+	"deb": buildDeb,
+	"rpm": buildRPM,
+	"pkg": buildPkg,
+	"msi": buildMSI,
+}
+
+// buildPackage builds the installer of the type configuring osquery to enroll
+// in Fleet.
+func buildPackage(opt packageOptions) error {
+	build, ok := packageBuilders[opt.Type]
+	if !ok {
+		return errors.Errorf("--type must be one of pkg, msi, deb or rpm, got %q", opt.Type)
+	}
+	if !packageVersionRegexp.MatchString(opt.Version) {
+		return errors.Errorf("--version must be numeric, like 1.2.3, got %q", opt.Version)
+	}
+	files, err := packageFiles(opt, packageLayouts[opt.Type])
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "fleetctl-package")
+	if err != nil {
+		return errors.Wrap(err, "create temporary directory")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	outfile, err := filepath.Abs(opt.Outfile)
+	if err != nil {
+		return errors.Wrap(err, "resolve --outfile")
+	}
+	opt.Outfile = outfile
+	return build(opt, files, tmpDir)
+}
+
+func packageCommand() *cli.Command {
+	var opt packageOptions
+	return &cli.Command{
+		Name:      "package",
+		Usage:     "Build an installer configuring osquery to enroll in Fleet",
+		UsageText: `fleetctl package --type=deb --fleet-url=https://fleet.example.com --enroll-secret=secret`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "type",
+				Destination: &opt.Type,
+				Usage:       "Type of the installer: pkg, msi, deb or rpm",
+			},
+			&cli.StringFlag{
+				Name:        "fleet-url",
+				EnvVars:     []string{"FLEET_URL"},
+				Destination: &opt.FleetURL,
+				Usage:       "URL of the Fleet server the hosts enroll in",
+			},
+			&cli.StringFlag{
+				Name:        "enroll-secret",
+				EnvVars:     []string{"ENROLL_SECRET"},
+				Destination: &opt.EnrollSecret,
+				Usage:       "Enroll secret of the hosts",
+			},
+			&cli.StringFlag{
+				Name:        "fleet-certificate",
+				Destination: &opt.FleetCertificate,
+				Usage:       "Path to the certificate of the Fleet server, if it is not signed by a trusted authority",
+			},
+			&cli.BoolFlag{
+				Name:        "insecure",
+				Destination: &opt.Insecure,
+				Usage:       "Disable the TLS verification of the Fleet server by osquery",
+			},
+			&cli.StringFlag{
+				Name:        "identifier",
+				Value:       "com.fleetdm.osquery",
+				Destination: &opt.Identifier,
+				Usage:       "Identifier of the pkg installer",
+			},
+			&cli.StringFlag{
+				Name:        "version",
+				Value:       "1.0.0",
+				Destination: &opt.Version,
+				Usage:       "Version of the installer",
+			},
+			&cli.StringFlag{
+				Name:        "sign-identity",
+				Destination: &opt.SignIdentity,
+				Usage:       "Sign the installer with this Developer ID Installer identity (pkg), certificate subject (msi) or GPG key (deb, rpm)",
+			},
+			&cli.StringFlag{
+				Name:        "outfile",
+				Destination: &opt.Outfile,
+				Usage:       "Path of the installer (default fleet-osquery.<type>)",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if opt.Type == "" {
+				return errors.New("--type must be specified")
+			}
+			if opt.FleetURL == "" {
+				return errors.New("--fleet-url must be specified")
+			}
+			if opt.EnrollSecret == "" {
+				return errors.New("--enroll-secret must be specified")
+			}
+			if opt.Outfile == "" {
+				opt.Outfile = fmt.Sprintf("%s.%s", packageName, opt.Type)
+			}
+			if err := buildPackage(opt); err != nil {
+				return err
+			}
+			logf(c, "[+] Built %s\n", opt.Outfile)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOsqueryFlags(t *testing.T) {
+	opt := packageOptions{FleetURL: "https://fleet.example.com:8080/fleet/", FleetCertificate: "fleet.pem"}
+	flags, err := osqueryFlags(opt, packageLayouts["deb"])
+	require.NoError(t, err)
+	assert.Contains(t, flags, "--tls_hostname=fleet.example.com:8080\n")
+	assert.Contains(t, flags, "--enroll_secret_path=/etc/osquery/enroll_secret\n")
+	assert.Contains(t, flags, "--tls_server_certs=/etc/osquery/fleet.crt\n")
+	assert.Contains(t, flags, "--enroll_tls_endpoint=/fleet/api/v1/osquery/enroll\n")
+	assert.Contains(t, flags, "--logger_tls_endpoint=/fleet/api/v1/osquery/log\n")
+	assert.NotContains(t, flags, "--insecure")
+
+	// Windows paths are absolute and not quoted.
+	opt = packageOptions{FleetURL: "https://fleet.example.com", Insecure: true}
+	flags, err = osqueryFlags(opt, packageLayouts["msi"])
+	require.NoError(t, err)
+	assert.Contains(t, flags, `--enroll_secret_path=C:\Program Files\osquery\secret.txt`+"\n")
+	assert.Contains(t, flags, "--insecure\n")
+	assert.Contains(t, flags, "--config_tls_endpoint=/api/v1/osquery/config\n")
+	assert.NotContains(t, flags, "--tls_server_certs")
+
+	_, err = osqueryFlags(packageOptions{FleetURL: "http://fleet.example.com"}, packageLayouts["deb"])
+	assert.EqualError(t, err, `--fleet-url must be an https URL, got "http://fleet.example.com"`)
+}
+
+func TestPackageInvalid(t *testing.T) {
+	_, _, err := runConvertForTest(t, []string{"package", "--fleet-url", "https://fleet.example.com", "--enroll-secret", "secret"})
+	assert.EqualError(t, err, "--type must be specified")
+
+	_, _, err = runConvertForTest(t, []string{"package", "--type", "deb", "--enroll-secret", "secret"})
+	assert.EqualError(t, err, "--fleet-url must be specified")
+
+	_, _, err = runConvertForTest(t, []string{"package", "--type", "exe", "--fleet-url", "https://fleet.example.com", "--enroll-secret", "secret"})
+	assert.EqualError(t, err, `--type must be one of pkg, msi, deb or rpm, got "exe"`)
+
+	_, _, err = runConvertForTest(t, []string{"package", "--type", "msi", "--version", "1.0-beta", "--fleet-url", "https://fleet.example.com", "--enroll-secret", "secret"})
+	assert.EqualError(t, err, `--version must be numeric, like 1.2.3, got "1.0-beta"`)
+}
+
+func TestPackageDeb(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb is not available")
+	}
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "fleet.pem")
+	require.NoError(t, ioutil.WriteFile(cert, []byte("CERTIFICATE"), 0644))
+	outfile := filepath.Join(dir, "fleet.deb")
+
+	stdout, _, err := runConvertForTest(t, []string{
+		"package", "--type", "deb", "--fleet-url", "https://fleet.example.com",
+		"--enroll-secret", "secret", "--fleet-certificate", cert, "--version", "1.2.3", "--outfile", outfile,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "[+] Built "+outfile+"\n", stdout)
+
+	out, err := exec.Command("dpkg-deb", "--field", outfile, "Package", "Version", "Depends").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "Package: fleet-osquery\nVersion: 1.2.3\nDepends: osquery\n", string(out))
+
+	out, err = exec.Command("dpkg-deb", "--contents", outfile).Output()
+	require.NoError(t, err)
+	contents := string(out)
+	assert.Regexp(t, `-rw------- root/root .* ./etc/osquery/enroll_secret\n`, contents)
+	assert.Regexp(t, `-rw-r--r-- root/root .* ./etc/osquery/osquery.flags\n`, contents)
+	assert.Regexp(t, `-rw-r--r-- root/root .* ./etc/osquery/fleet.crt\n`, contents)
+}
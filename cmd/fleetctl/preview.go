@@ -21,10 +21,70 @@ import (
 )
 
 const (
-	downloadUrl        = "https://github.com/fleetdm/osquery-in-a-box/archive/master.zip"
-	licenseKeyFlagName = "license-key"
+	downloadUrl             = "https://github.com/fleetdm/osquery-in-a-box/archive/master.zip"
+	licenseKeyFlagName      = "license-key"
+	noSampleQueriesFlagName = "no-sample-queries"
 )
 
+// previewSampleSpecs are applied to a new preview deployment, so that there
+// are queries to run and a pack scheduling some of them on the simulated
+// hosts.
+const previewSampleSpecs = `---
+apiVersion: v1
+kind: query
+spec:
+  name: osquery_info
+  description: The version and configuration of osquery on the host.
+  query: SELECT * FROM osquery_info;
+---
+apiVersion: v1
+kind: query
+spec:
+  name: os_version
+  description: The version of the operating system.
+  query: SELECT * FROM os_version;
+---
+apiVersion: v1
+kind: query
+spec:
+  name: users
+  description: The local user accounts.
+  query: SELECT uid, gid, username, description, directory, shell FROM users;
+---
+apiVersion: v1
+kind: query
+spec:
+  name: listening_ports
+  description: The processes listening on network ports.
+  query: SELECT p.pid, p.name, lp.port, lp.protocol, lp.address FROM listening_ports lp JOIN processes p USING (pid);
+---
+apiVersion: v1
+kind: query
+spec:
+  name: installed_packages
+  description: The deb and rpm packages installed on the host.
+  query: SELECT name, version, 'deb' AS source FROM deb_packages UNION SELECT name, version, 'rpm' AS source FROM rpm_packages;
+---
+apiVersion: v1
+kind: pack
+spec:
+  name: Preview
+  description: Queries scheduled on all the hosts of the preview deployment.
+  targets:
+    labels:
+      - All Hosts
+  queries:
+    - query: os_version
+      name: os_version
+      interval: 3600
+    - query: users
+      name: users
+      interval: 600
+    - query: listening_ports
+      name: listening_ports
+      interval: 300
+`
+
 func previewCommand() *cli.Command {
 	return &cli.Command{
 		Name:  "preview",
@@ -44,6 +104,10 @@ Use the stop and reset subcommands to manage the server and dependencies once st
 				Name:  licenseKeyFlagName,
 				Usage: "License key to enable Fleet Basic (optional)",
 			},
+			&cli.BoolFlag{
+				Name:  noSampleQueriesFlagName,
+				Usage: "Do not add the sample queries and pack to a new preview deployment",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			if err := checkDocker(); err != nil {
@@ -72,14 +136,14 @@ Use the stop and reset subcommands to manage the server and dependencies once st
 			}
 
 			fmt.Println("Pulling Docker dependencies...")
-			out, err := exec.Command("docker-compose", "pull").CombinedOutput()
+			out, err := dockerCompose("pull").CombinedOutput()
 			if err != nil {
 				fmt.Println(string(out))
 				return errors.Errorf("Failed to run docker-compose")
 			}
 
 			fmt.Println("Starting Docker containers...")
-			cmd := exec.Command("docker-compose", "up", "-d", "--remove-orphans", "mysql01", "redis01", "fleet01")
+			cmd := dockerCompose("up", "-d", "--remove-orphans", "mysql01", "redis01", "fleet01")
 			cmd.Env = append(os.Environ(), "FLEET_LICENSE_KEY="+c.String(licenseKeyFlagName))
 			out, err = cmd.CombinedOutput()
 			if err != nil {
@@ -95,7 +159,7 @@ Use the stop and reset subcommands to manage the server and dependencies once st
 			// Start fleet02 (UI server) after fleet01 (agent/fleetctl server)
 			// has finished starting up so that there is no conflict with
 			// running database migrations.
-			cmd = exec.Command("docker-compose", "up", "-d", "--remove-orphans", "fleet02")
+			cmd = dockerCompose("up", "-d", "--remove-orphans", "fleet02")
 			cmd.Env = append(os.Environ(), "FLEET_LICENSE_KEY="+c.String(licenseKeyFlagName))
 			out, err = cmd.CombinedOutput()
 			if err != nil {
@@ -180,8 +244,14 @@ Use the stop and reset subcommands to manage the server and dependencies once st
 				return errors.New("Expected 1 active enroll secret")
 			}
 
+			if !c.Bool(noSampleQueriesFlagName) {
+				if err := applySampleSpecs(c, client); err != nil {
+					return errors.Wrap(err, "Error applying sample queries")
+				}
+			}
+
 			fmt.Println("Starting simulated hosts...")
-			cmd = exec.Command("docker-compose", "up", "-d", "--remove-orphans")
+			cmd = dockerCompose("up", "-d", "--remove-orphans")
 			cmd.Dir = filepath.Join(previewDir, "osquery")
 			cmd.Env = append(os.Environ(),
 				"ENROLL_SECRET="+secrets.Secrets[0].Secret,
@@ -317,12 +387,40 @@ func waitStartup() error {
 	return nil
 }
 
+// applySampleSpecs applies the sample queries and pack, unless the server
+// already has queries, so that running preview again does not overwrite
+// changes made to them.
+func applySampleSpecs(c *cli.Context, client *service.Client) error {
+	queries, err := client.GetQueries()
+	if err != nil {
+		return errors.Wrap(err, "get queries")
+	}
+	if len(queries) > 0 {
+		return nil
+	}
+	specs, err := specGroupFromBytes([]byte(previewSampleSpecs))
+	if err != nil {
+		return err
+	}
+	fmt.Println("Adding sample queries...")
+	return applySpecs(c, client, specs)
+}
+
+// dockerCompose returns the command running docker-compose, or the compose
+// plugin of docker when docker-compose is not installed.
+func dockerCompose(args ...string) *exec.Cmd {
+	if _, err := exec.LookPath("docker-compose"); err == nil {
+		return exec.Command("docker-compose", args...)
+	}
+	return exec.Command("docker", append([]string{"compose"}, args...)...)
+}
+
 func checkDocker() error {
 	// Check installed
 	if _, err := exec.LookPath("docker"); err != nil {
 		return errors.New("Docker is required for the fleetctl preview experience.\n\nPlease install Docker (https://docs.docker.com/get-docker/).")
 	}
-	if _, err := exec.LookPath("docker-compose"); err != nil {
+	if err := dockerCompose("version").Run(); err != nil {
 		return errors.New("Docker Compose is required for the fleetctl preview experience.\n\nPlease install Docker Compose (https://docs.docker.com/compose/install/).")
 	}
 
@@ -356,13 +454,13 @@ func previewStopCommand() *cli.Command {
 				return errors.Wrap(err, "docker-compose file not found in preview directory")
 			}
 
-			out, err := exec.Command("docker-compose", "stop").CombinedOutput()
+			out, err := dockerCompose("stop").CombinedOutput()
 			if err != nil {
 				fmt.Println(string(out))
 				return errors.Errorf("Failed to run docker-compose stop for Fleet server and dependencies")
 			}
 
-			cmd := exec.Command("docker-compose", "stop")
+			cmd := dockerCompose("stop")
 			cmd.Dir = filepath.Join(previewDir, "osquery")
 			cmd.Env = append(os.Environ(),
 				// Note that these must be set even though they are unused while
@@ -405,13 +503,13 @@ func previewResetCommand() *cli.Command {
 				return errors.Wrap(err, "docker-compose file not found in preview directory")
 			}
 
-			out, err := exec.Command("docker-compose", "rm", "-sf").CombinedOutput()
+			out, err := dockerCompose("rm", "-sf").CombinedOutput()
 			if err != nil {
 				fmt.Println(string(out))
 				return errors.Errorf("Failed to run docker-compose rm -sf for Fleet server and dependencies.")
 			}
 
-			cmd := exec.Command("docker-compose", "rm", "-sf")
+			cmd := dockerCompose("rm", "-sf")
 			cmd.Dir = filepath.Join(previewDir, "osquery")
 			cmd.Env = append(os.Environ(),
 				// Note that these must be set even though they are unused while
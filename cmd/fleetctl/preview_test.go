@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewSampleSpecs(t *testing.T) {
+	specs, err := specGroupFromBytes([]byte(previewSampleSpecs))
+	require.NoError(t, err)
+	require.NotEmpty(t, specs.Queries)
+	require.Len(t, specs.Packs, 1)
+
+	queries := make(map[string]bool)
+	for _, query := range specs.Queries {
+		assert.NotEmpty(t, query.Query, query.Name)
+		queries[query.Name] = true
+	}
+	for _, scheduled := range specs.Packs[0].Queries {
+		assert.True(t, queries[scheduled.QueryName], scheduled.QueryName)
+	}
+	assert.Equal(t, []string{"All Hosts"}, specs.Packs[0].Targets.Labels)
+}
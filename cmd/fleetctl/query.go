@@ -3,8 +3,10 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"strings"
 	"time"
 
@@ -14,14 +16,16 @@ import (
 
 func queryCommand() *cli.Command {
 	var (
-		flHosts, flLabels, flQuery, flQueryName string
-		flQuiet, flExit, flPretty               bool
-		flTimeout                               time.Duration
+		flHosts, flLabels, flTeams, flQuery, flQueryName string
+		flOutput, flOutfile                              string
+		flQuiet, flExit, flPretty                        bool
+		flTimeout                                        time.Duration
 	)
 	return &cli.Command{
-		Name:      "query",
-		Usage:     "Run a live query",
-		UsageText: `fleetctl query [options]`,
+		Name:         "query",
+		Usage:        "Run a live query",
+		UsageText:    `fleetctl query [options]`,
+		BashComplete: nameCompletion("query-name", queryNames),
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:        "hosts",
@@ -37,6 +41,13 @@ func queryCommand() *cli.Command {
 				Destination: &flLabels,
 				Usage:       "Comma separated label names to target",
 			},
+			&cli.StringFlag{
+				Name:        "teams",
+				EnvVars:     []string{"TEAMS"},
+				Value:       "",
+				Destination: &flTeams,
+				Usage:       "Comma separated team names to target",
+			},
 			&cli.BoolFlag{
 				Name:        "quiet",
 				EnvVars:     []string{"QUIET"},
@@ -69,6 +80,20 @@ func queryCommand() *cli.Command {
 				Destination: &flPretty,
 				Usage:       "Enable pretty-printing",
 			},
+			&cli.StringFlag{
+				Name:        "output",
+				EnvVars:     []string{"OUTPUT"},
+				Value:       "",
+				Destination: &flOutput,
+				Usage:       "Output format of the results: json, ndjson, csv or table",
+			},
+			&cli.StringFlag{
+				Name:        "outfile",
+				EnvVars:     []string{"OUTFILE"},
+				Value:       "",
+				Destination: &flOutfile,
+				Usage:       "Write the results to this file instead of stdout",
+			},
 			&cli.DurationFlag{
 				Name:        "timeout",
 				EnvVars:     []string{"TIMEOUT"},
@@ -85,8 +110,8 @@ func queryCommand() *cli.Command {
 				return err
 			}
 
-			if flHosts == "" && flLabels == "" {
-				return errors.New("No hosts or labels targeted")
+			if flHosts == "" && flLabels == "" && flTeams == "" {
+				return errors.New("No hosts, labels or teams targeted")
 			}
 
 			if flQuery != "" && flQueryName != "" {
@@ -105,17 +130,40 @@ func queryCommand() *cli.Command {
 				return fmt.Errorf("Query must be specified with --query or --query-name")
 			}
 
-			var output outputWriter
 			if flPretty {
-				output = newPrettyWriter()
-			} else {
-				output = newJsonWriter()
+				if flOutput != "" && flOutput != "table" {
+					return fmt.Errorf("--pretty and --output %s must not be provided together", flOutput)
+				}
+				flOutput = "table"
+			}
+			if err := validateOutputFormat(flOutput); err != nil {
+				return err
+			}
+
+			var out io.Writer = os.Stdout
+			if flOutfile != "" {
+				f, err := os.Create(flOutfile)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+			// The table is redrawn as results are received on a terminal,
+			// in a file it is written once at the end.
+			output, err := newOutputWriter(flOutput, out, flOutfile == "")
+			if err != nil {
+				return err
 			}
 
 			hosts := strings.Split(flHosts, ",")
 			labels := strings.Split(flLabels, ",")
+			var teams []string
+			if flTeams != "" {
+				teams = strings.Split(flTeams, ",")
+			}
 
-			res, err := fleet.LiveQuery(flQuery, labels, hosts)
+			res, err := fleet.LiveQuery(flQuery, labels, hosts, teams)
 			if err != nil {
 				return err
 			}
@@ -142,6 +190,12 @@ func queryCommand() *cli.Command {
 				timeoutChan = make(chan time.Time)
 			}
 
+			// Stopping the query with CTRL-C still writes the results
+			// buffered by the output format.
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			defer signal.Stop(interrupt)
+
 			for {
 				select {
 				// Print a result
@@ -177,7 +231,7 @@ func queryCommand() *cli.Command {
 					}
 
 					if responded >= online && flExit {
-						return nil
+						return output.Close()
 					}
 
 					msg := fmt.Sprintf(" %.f%% responded (%.f%% online) | %d/%d targeted hosts (%d/%d online)", percentTotal, percentOnline, responded, total, responded, online)
@@ -187,16 +241,20 @@ func queryCommand() *cli.Command {
 						if !flQuiet {
 							fmt.Fprintln(os.Stderr, msg)
 						}
-						return nil
+						return output.Close()
 					}
 
+				case <-interrupt:
+					s.Stop()
+					return output.Close()
+
 				// Check for timeout expiring
 				case <-timeoutChan:
 					s.Stop()
 					if !flQuiet {
 						fmt.Fprintln(os.Stderr, s.Suffix+"\nStopped by timeout")
 					}
-					return nil
+					return output.Close()
 				}
 			}
 		},
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"sort"
 
@@ -12,6 +15,59 @@ import (
 
 type outputWriter interface {
 	WriteResult(res fleet.DistributedQueryResult) error
+	// Close writes the results buffered until the end of the query, if any.
+	Close() error
+}
+
+// validateOutputFormat checks that the format is one of json, ndjson, csv or
+// table. The empty format is the historical stream of one JSON object per
+// host.
+func validateOutputFormat(format string) error {
+	switch format {
+	case "", "json", "ndjson", "csv", "table":
+		return nil
+	default:
+		return fmt.Errorf("--output must be one of json, ndjson, csv or table, got %q", format)
+	}
+}
+
+// newOutputWriter returns the writer of the live query results in the format.
+func newOutputWriter(format string, w io.Writer, live bool) (outputWriter, error) {
+	if err := validateOutputFormat(format); err != nil {
+		return nil, err
+	}
+	switch format {
+	case "":
+		return newJsonWriter(w), nil
+	case "json":
+		return &jsonArrayWriter{w: w, rows: []map[string]string{}}, nil
+	case "ndjson":
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case "csv":
+		return &csvWriter{w: w, columns: make(map[string]bool)}, nil
+	default:
+		return newPrettyWriter(w, live), nil
+	}
+}
+
+// resultRows returns the rows of the result with the hostname of the host in
+// the host column, instead of the host_hostname column added by the server.
+func resultRows(res fleet.DistributedQueryResult) []map[string]string {
+	if res.Error != nil {
+		return []map[string]string{{"host": res.Host.Hostname, "error": *res.Error}}
+	}
+	rows := make([]map[string]string, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		out := make(map[string]string, len(row))
+		for col, value := range row {
+			if col != "host_hostname" {
+				out[col] = value
+			}
+		}
+		out["host"] = res.Host.Hostname
+		rows = append(rows, out)
+	}
+	return rows
 }
 
 type resultOutput struct {
@@ -20,10 +76,12 @@ type resultOutput struct {
 	Error          *string             `json:"error,omitempty"`
 }
 
-type jsonWriter struct{}
+type jsonWriter struct {
+	w io.Writer
+}
 
-func newJsonWriter() *jsonWriter {
-	return &jsonWriter{}
+func newJsonWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{w: w}
 }
 
 func (w *jsonWriter) WriteResult(res fleet.DistributedQueryResult) error {
@@ -32,19 +90,111 @@ func (w *jsonWriter) WriteResult(res fleet.DistributedQueryResult) error {
 		Rows:           res.Rows,
 		Error:          res.Error,
 	}
-	return json.NewEncoder(os.Stdout).Encode(out)
+	return json.NewEncoder(w.w).Encode(out)
+}
+
+func (w *jsonWriter) Close() error { return nil }
+
+// jsonArrayWriter writes all the rows as a single JSON array at the end of
+// the query.
+type jsonArrayWriter struct {
+	w    io.Writer
+	rows []map[string]string
+}
+
+func (w *jsonArrayWriter) WriteResult(res fleet.DistributedQueryResult) error {
+	w.rows = append(w.rows, resultRows(res)...)
+	return nil
+}
+
+func (w *jsonArrayWriter) Close() error {
+	encoder := json.NewEncoder(w.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(w.rows)
+}
+
+// ndjsonWriter writes each row as a JSON object on its own line as soon as
+// it is received.
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) WriteResult(res fleet.DistributedQueryResult) error {
+	for _, row := range resultRows(res) {
+		if err := w.encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonWriter) Close() error { return nil }
+
+// csvWriter writes the rows as CSV at the end of the query, once the columns
+// of all the rows are known. Errors are written to stderr.
+type csvWriter struct {
+	w       io.Writer
+	rows    []map[string]string
+	columns map[string]bool
+}
+
+func (w *csvWriter) WriteResult(res fleet.DistributedQueryResult) error {
+	if res.Error != nil {
+		fmt.Fprintf(os.Stderr, "Error from host %s: %s\n", res.Host.Hostname, *res.Error)
+		return nil
+	}
+	for _, row := range resultRows(res) {
+		for col := range row {
+			if col != "host" {
+				w.columns[col] = true
+			}
+		}
+		w.rows = append(w.rows, row)
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	columns := []string{}
+	for col := range w.columns {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	columns = append([]string{"host"}, columns...)
+
+	cw := csv.NewWriter(w.w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range w.rows {
+		record := make([]string, 0, len(columns))
+		for _, col := range columns {
+			record = append(record, row[col])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
 }
 
 type prettyWriter struct {
 	results []fleet.DistributedQueryResult
 	columns map[string]bool
 	writer  *uilive.Writer
+	// live redraws the table as results are received, otherwise the table is
+	// only rendered once at the end of the query.
+	live bool
 }
 
-func newPrettyWriter() *prettyWriter {
+func newPrettyWriter(w io.Writer, live bool) *prettyWriter {
+	writer := uilive.New()
+	writer.Out = w
 	return &prettyWriter{
 		columns: make(map[string]bool),
-		writer:  uilive.New(),
+		writer:  writer,
+		live:    live,
 	}
 }
 
@@ -59,6 +209,20 @@ func (w *prettyWriter) WriteResult(res fleet.DistributedQueryResult) error {
 		}
 	}
 
+	if !w.live {
+		return nil
+	}
+	return w.render()
+}
+
+func (w *prettyWriter) Close() error {
+	if w.live {
+		return nil
+	}
+	return w.render()
+}
+
+func (w *prettyWriter) render() error {
 	columns := []string{}
 	for col := range w.columns {
 		columns = append(columns, col)
@@ -82,7 +246,5 @@ func (w *prettyWriter) WriteResult(res fleet.DistributedQueryResult) error {
 	table.Render()
 
 	// Actually write the output
-	w.writer.Flush()
-
-	return nil
+	return w.writer.Flush()
 }
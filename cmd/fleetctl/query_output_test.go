@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testQueryResults() []fleet.DistributedQueryResult {
+	return []fleet.DistributedQueryResult{
+		{
+			Host: fleet.Host{Hostname: "host1"},
+			Rows: []map[string]string{
+				{"host_hostname": "host1", "name": "osqueryd", "pid": "1"},
+				{"host_hostname": "host1", "name": "launchd", "pid": "2"},
+			},
+		},
+		{
+			Host: fleet.Host{Hostname: "host2"},
+			Rows: []map[string]string{{"host_hostname": "host2", "name": "init", "pid": "1", "path": "/sbin/init"}},
+		},
+		{
+			Host:  fleet.Host{Hostname: "host3"},
+			Error: ptr.String("no such table: processes"),
+		},
+	}
+}
+
+func writeQueryResultsForTest(t *testing.T, format string) string {
+	var buf bytes.Buffer
+	output, err := newOutputWriter(format, &buf, false)
+	require.NoError(t, err)
+	for _, res := range testQueryResults() {
+		require.NoError(t, output.WriteResult(res))
+	}
+	require.NoError(t, output.Close())
+	return buf.String()
+}
+
+func TestQueryOutputJSON(t *testing.T) {
+	var rows []map[string]string
+	require.NoError(t, json.Unmarshal([]byte(writeQueryResultsForTest(t, "json")), &rows))
+	assert.Equal(t, []map[string]string{
+		{"host": "host1", "name": "osqueryd", "pid": "1"},
+		{"host": "host1", "name": "launchd", "pid": "2"},
+		{"host": "host2", "name": "init", "pid": "1", "path": "/sbin/init"},
+		{"host": "host3", "error": "no such table: processes"},
+	}, rows)
+}
+
+func TestQueryOutputNDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(writeQueryResultsForTest(t, "ndjson")), "\n")
+	require.Len(t, lines, 4)
+	assert.JSONEq(t, `{"host": "host1", "name": "osqueryd", "pid": "1"}`, lines[0])
+	assert.JSONEq(t, `{"host": "host3", "error": "no such table: processes"}`, lines[3])
+}
+
+func TestQueryOutputCSV(t *testing.T) {
+	assert.Equal(t, `host,name,path,pid
+host1,osqueryd,,1
+host1,launchd,,2
+host2,init,/sbin/init,1
+`, writeQueryResultsForTest(t, "csv"))
+}
+
+func TestQueryOutputTable(t *testing.T) {
+	out := writeQueryResultsForTest(t, "table")
+	assert.Contains(t, out, "HOSTNAME")
+	assert.Contains(t, out, "/sbin/init")
+	// Written once at the end, not redrawn after every result.
+	assert.Equal(t, 1, strings.Count(out, "HOSTNAME"))
+}
+
+func TestQueryOutputInvalid(t *testing.T) {
+	_, err := newOutputWriter("xml", &bytes.Buffer{}, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--output must be one of json, ndjson, csv or table")
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/guregu/null.v3"
 )
 
 const (
@@ -31,6 +32,8 @@ func userCommand() *cli.Command {
 		Usage: "Manage Fleet users",
 		Subcommands: []*cli.Command{
 			createUserCommand(),
+			deleteUserCommand(),
+			listUsersCommand(),
 		},
 	}
 }
@@ -167,6 +170,127 @@ func createUserCommand() *cli.Command {
 				return errors.Wrap(err, "Failed to create user")
 			}
 
+			logf(c, "[+] created user %s\n", email)
+			return nil
+		},
+	}
+}
+
+func deleteUserCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "delete",
+		Usage:     "Delete a user",
+		UsageText: `fleetctl user delete --email <email>`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     emailFlagName,
+				Usage:    "Email of the user to delete (required)",
+				Required: true,
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			// The API deletes users by ID, find it from the email.
+			email := c.String(emailFlagName)
+			users, err := client.ListUsers()
+			if err != nil {
+				return errors.Wrap(err, "could not list users")
+			}
+			var user *fleet.User
+			for i := range users {
+				if strings.EqualFold(users[i].Email, email) {
+					user = &users[i]
+					break
+				}
+			}
+			if user == nil {
+				return errors.Errorf("user %q not found", email)
+			}
+
+			if err := client.DeleteUser(user.ID); err != nil {
+				return errors.Wrap(err, "Failed to delete user")
+			}
+
+			logf(c, "[-] deleted user %s\n", user.Email)
+			return nil
+		},
+	}
+}
+
+// userTeams returns the team_name:role pairs of the user, the format of the
+// --team flag of user create with names instead of IDs.
+func userTeams(user fleet.User) string {
+	teams := make([]string, 0, len(user.Teams))
+	for _, team := range user.Teams {
+		teams = append(teams, team.Name+":"+team.Role)
+	}
+	return strings.Join(teams, ", ")
+}
+
+func listUsersCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "list",
+		Aliases:   []string{"ls"},
+		Usage:     "List the users",
+		UsageText: `fleetctl user list [options]`,
+		Flags: []cli.Flag{
+			jsonFlag(),
+			yamlFlag(),
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			users, err := client.ListUsers()
+			if err != nil {
+				return errors.Wrap(err, "could not list users")
+			}
+
+			if c.Bool(jsonFlagName) || c.Bool(yamlFlagName) {
+				for _, user := range users {
+					if c.Bool(jsonFlagName) {
+						err = printJSON(user, c.App.Writer)
+					} else {
+						err = printYaml(user, c.App.Writer)
+					}
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			if len(users) == 0 {
+				log(c, "No users found\n")
+				return nil
+			}
+
+			data := [][]string{}
+			for _, user := range users {
+				data = append(data, []string{
+					user.Name,
+					user.Email,
+					null.StringFromPtr(user.GlobalRole).ValueOrZero(),
+					userTeams(user),
+					strconv.FormatBool(user.SSOEnabled),
+					strconv.FormatBool(user.APIOnly),
+				})
+			}
+			columns := []string{"name", "email", "global role", "teams", "sso", "api only"}
+			printTable(c, columns, data)
+
 			return nil
 		},
 	}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserCreate(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.InviteByEmailFunc = func(email string) (*fleet.Invite, error) {
+		return nil, &mock.Error{Message: "invite not found"}
+	}
+	var created *fleet.User
+	ds.NewUserFunc = func(user *fleet.User) (*fleet.User, error) {
+		created = user
+		return user, nil
+	}
+
+	assert.Equal(t, "[+] created user bot@example.com\n", runAppForTest(t, []string{
+		"user", "create", "--email", "bot@example.com", "--name", "Bot",
+		"--password", "p4ssw0rd.", "--api-only", "--global-role", "maintainer",
+	}))
+	require.NotNil(t, created)
+	assert.Equal(t, "bot@example.com", created.Email)
+	assert.True(t, created.APIOnly)
+	assert.False(t, created.SSOEnabled)
+	assert.Equal(t, ptr.String(fleet.RoleMaintainer), created.GlobalRole)
+
+	_, _, err := runConvertForTest(t, []string{
+		"user", "create", "--email", "sso@example.com", "--name", "SSO",
+		"--password", "p4ssw0rd.", "--sso",
+	})
+	assert.EqualError(t, err, "Password may not be provided for SSO users.")
+}
+
+func TestUserList(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.ListUsersFunc = func(opt fleet.UserListOptions) ([]*fleet.User, error) {
+		return []*fleet.User{
+			{ID: 1, Name: "Admin", Email: "admin@example.com", GlobalRole: ptr.String(fleet.RoleAdmin)},
+			{
+				ID: 2, Name: "Bot", Email: "bot@example.com", SSOEnabled: true, APIOnly: true,
+				Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 1, Name: "team1"}, Role: fleet.RoleObserver}},
+			},
+		}, nil
+	}
+
+	expected := `+-------+-------------------+-------------+----------------+-------+----------+
+| NAME  |       EMAIL       | GLOBAL ROLE |     TEAMS      |  SSO  | API ONLY |
++-------+-------------------+-------------+----------------+-------+----------+
+| Admin | admin@example.com | admin       |                | false | false    |
++-------+-------------------+-------------+----------------+-------+----------+
+| Bot   | bot@example.com   |             | team1:observer | true  | true     |
++-------+-------------------+-------------+----------------+-------+----------+
+`
+	assert.Equal(t, expected, runAppForTest(t, []string{"user", "list"}))
+
+	out := runAppForTest(t, []string{"user", "list", "--json"})
+	assert.Contains(t, out, `"email":"bot@example.com"`)
+	assert.Contains(t, out, `"api_only":true`)
+}
+
+func TestUserDelete(t *testing.T) {
+	server, ds := runServerWithMockedDS(t)
+	defer server.Close()
+
+	ds.UserByIDFunc = func(id uint) (*fleet.User, error) {
+		return &fleet.User{ID: id, GlobalRole: ptr.String(fleet.RoleAdmin)}, nil
+	}
+	ds.ListUsersFunc = func(opt fleet.UserListOptions) ([]*fleet.User, error) {
+		return []*fleet.User{{ID: 42, Name: "Bot", Email: "bot@example.com"}}, nil
+	}
+	var deleted uint
+	ds.DeleteUserFunc = func(id uint) error {
+		deleted = id
+		return nil
+	}
+
+	assert.Equal(t, "[-] deleted user bot@example.com\n", runAppForTest(t, []string{"user", "delete", "--email", "Bot@example.com"}))
+	assert.Equal(t, uint(42), deleted)
+
+	_, _, err := runConvertForTest(t, []string{"user", "delete", "--email", "nobody@example.com"})
+	assert.EqualError(t, err, `user "nobody@example.com" not found`)
+}
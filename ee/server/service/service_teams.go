@@ -37,6 +37,10 @@ func (svc *Service) NewTeam(ctx context.Context, p fleet.TeamPayload) (*fleet.Te
 		team.Description = *p.Description
 	}
 
+	if p.Features != nil {
+		team.TeamFeatures = *p.Features
+	}
+
 	if p.Secrets != nil {
 		team.Secrets = p.Secrets
 	} else {
@@ -85,6 +89,9 @@ func (svc *Service) ModifyTeam(ctx context.Context, teamID uint, payload fleet.T
 	if payload.Secrets != nil {
 		team.Secrets = payload.Secrets
 	}
+	if payload.Features != nil {
+		team.TeamFeatures = *payload.Features
+	}
 
 	return svc.ds.SaveTeam(team)
 }
@@ -121,6 +121,25 @@ allow {
   action == read
 }
 
+##
+# Software
+##
+
+# All users can read software
+allow {
+  not is_null(subject)
+  object.type == "software"
+  action == read
+}
+
+# Global admins can write software (eg. suppress the vulnerabilities of
+# software as false positives)
+allow {
+  object.type == "software"
+  subject.global_role == admin
+  action == write
+}
+
 ##
 # Sessions
 ##
@@ -438,6 +438,28 @@ func TestAuthorizePacks(t *testing.T) {
 	})
 }
 
+func TestAuthorizeSoftware(t *testing.T) {
+	t.Parallel()
+
+	software := &fleet.Software{}
+	runTestCases(t, []authTestCase{
+		{user: nil, object: software, action: read, allow: false},
+		{user: nil, object: software, action: write, allow: false},
+
+		// Everyone logged in can read software
+		{user: test.UserNoRoles, object: software, action: read, allow: true},
+		{user: test.UserAdmin, object: software, action: read, allow: true},
+		{user: test.UserMaintainer, object: software, action: read, allow: true},
+		{user: test.UserObserver, object: software, action: read, allow: true},
+
+		// Only global admins can write software
+		{user: test.UserAdmin, object: software, action: write, allow: true},
+		{user: test.UserNoRoles, object: software, action: write, allow: false},
+		{user: test.UserMaintainer, object: software, action: write, allow: false},
+		{user: test.UserObserver, object: software, action: write, allow: false},
+	})
+}
+
 func TestAuthorizeCarves(t *testing.T) {
 	t.Parallel()
 
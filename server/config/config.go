@@ -69,6 +69,7 @@ type AuthConfig struct {
 type AppConfig struct {
 	TokenKeySize              int           `yaml:"token_key_size"`
 	InviteTokenValidityPeriod time.Duration `yaml:"invite_token_validity_period"`
+	SoftwareCleanupBatchSize  int           `yaml:"software_cleanup_batch_size"`
 }
 
 // SessionConfig defines configs related to user sessions
@@ -89,6 +90,10 @@ type OsqueryConfig struct {
 	StatusLogFile        string        `yaml:"status_log_file"`
 	ResultLogFile        string        `yaml:"result_log_file"`
 	EnableLogRotation    bool          `yaml:"enable_log_rotation"`
+
+	AsyncSoftwareIngestion          bool          `yaml:"async_software_ingestion"`
+	AsyncSoftwareIngestionInterval  time.Duration `yaml:"async_software_ingestion_interval"`
+	AsyncSoftwareIngestionBatchSize int           `yaml:"async_software_ingestion_batch_size"`
 }
 
 // LoggingConfig defines configs related to logging
@@ -158,26 +163,39 @@ type LicenseConfig struct {
 	Key string `yaml:"key"`
 }
 
+// VulnerabilitiesConfig defines configs related to matching the software
+// inventory against vulnerability data.
+type VulnerabilitiesConfig struct {
+	DatabasesPath    string        `yaml:"databases_path"`
+	Periodicity      time.Duration `yaml:"periodicity"`
+	CVEFeedPrefixURL string        `yaml:"cve_feed_prefix_url"`
+	EPSSFeedURL      string        `yaml:"epss_feed_url"`
+	CISAKEVFeedURL   string        `yaml:"cisa_kev_feed_url"`
+	MSRCFeedURL      string        `yaml:"msrc_feed_url"`
+	DisableDataSync  bool          `yaml:"disable_data_sync"`
+}
+
 // FleetConfig stores the application configuration. Each subcategory is
 // broken up into it's own struct, defined above. When editing any of these
 // structs, Manager.addConfigs and Manager.LoadConfig should be
 // updated to set and retrieve the configurations as appropriate.
 type FleetConfig struct {
-	Mysql      MysqlConfig
-	Redis      RedisConfig
-	Server     ServerConfig
-	Auth       AuthConfig
-	App        AppConfig
-	Session    SessionConfig
-	Osquery    OsqueryConfig
-	Logging    LoggingConfig
-	Firehose   FirehoseConfig
-	Kinesis    KinesisConfig
-	Lambda     LambdaConfig
-	S3         S3Config
-	PubSub     PubSubConfig
-	Filesystem FilesystemConfig
-	License    LicenseConfig
+	Mysql           MysqlConfig
+	Redis           RedisConfig
+	Server          ServerConfig
+	Auth            AuthConfig
+	App             AppConfig
+	Session         SessionConfig
+	Osquery         OsqueryConfig
+	Logging         LoggingConfig
+	Firehose        FirehoseConfig
+	Kinesis         KinesisConfig
+	Lambda          LambdaConfig
+	S3              S3Config
+	PubSub          PubSubConfig
+	Filesystem      FilesystemConfig
+	License         LicenseConfig
+	Vulnerabilities VulnerabilitiesConfig
 }
 
 // addConfigs adds the configuration keys and default values that will be
@@ -250,6 +268,8 @@ func (man Manager) addConfigs() {
 		"Duration invite tokens remain valid (i.e. 1h)")
 	man.addConfigInt("app.token_key_size", 24,
 		"Size of generated tokens")
+	man.addConfigInt("app.software_cleanup_batch_size", 1000,
+		"Number of software no longer installed on any host deleted per statement")
 
 	// Session
 	man.addConfigInt("session.key_size", 64,
@@ -278,6 +298,12 @@ func (man Manager) addConfigs() {
 		"(DEPRECATED: Use filesystem.result_log_file) Path for osqueryd result logs")
 	man.addConfigBool("osquery.enable_log_rotation", false,
 		"(DEPRECATED: Use filesystem.enable_log_rotation) Enable automatic rotation for osquery log files")
+	man.addConfigBool("osquery.async_software_ingestion", false,
+		"Buffer the software reported by the hosts in Redis and save it asynchronously")
+	man.addConfigDuration("osquery.async_software_ingestion_interval", 10*time.Second,
+		"Interval to save the software buffered by async_software_ingestion (i.e. 10s)")
+	man.addConfigInt("osquery.async_software_ingestion_batch_size", 1000,
+		"Number of hosts whose buffered software is dequeued at once")
 
 	// Logging
 	man.addConfigBool("logging.debug", false,
@@ -345,6 +371,22 @@ func (man Manager) addConfigs() {
 
 	// License
 	man.addConfigString("license.key", "", "Fleet license key (to enable Fleet Basic features)")
+
+	// Vulnerability processing
+	man.addConfigString("vulnerabilities.databases_path", "",
+		"Path where to store the vulnerability databases, vulnerability processing is disabled if empty")
+	man.addConfigDuration("vulnerabilities.periodicity", 1*time.Hour,
+		"How often vulnerabilities are processed")
+	man.addConfigString("vulnerabilities.cve_feed_prefix_url", "",
+		"Prefix URL of the NVD CVE feeds, defaults to the NVD")
+	man.addConfigString("vulnerabilities.epss_feed_url", "",
+		"URL of the EPSS scores, defaults to the current EPSS scores")
+	man.addConfigString("vulnerabilities.cisa_kev_feed_url", "",
+		"URL of the CISA Known Exploited Vulnerabilities catalog, defaults to the CISA")
+	man.addConfigString("vulnerabilities.msrc_feed_url", "",
+		"URL of the MSRC security updates API, defaults to the MSRC")
+	man.addConfigBool("vulnerabilities.disable_data_sync", false,
+		"Skip downloading the vulnerability databases, they must then be provided in databases_path")
 }
 
 // LoadConfig will load the config variables into a fully initialized
@@ -392,6 +434,7 @@ func (man Manager) LoadConfig() FleetConfig {
 		App: AppConfig{
 			TokenKeySize:              man.getConfigInt("app.token_key_size"),
 			InviteTokenValidityPeriod: man.getConfigDuration("app.invite_token_validity_period"),
+			SoftwareCleanupBatchSize:  man.getConfigInt("app.software_cleanup_batch_size"),
 		},
 		Session: SessionConfig{
 			KeySize:  man.getConfigInt("session.key_size"),
@@ -408,6 +451,10 @@ func (man Manager) LoadConfig() FleetConfig {
 			LabelUpdateInterval:  man.getConfigDuration("osquery.label_update_interval"),
 			DetailUpdateInterval: man.getConfigDuration("osquery.detail_update_interval"),
 			EnableLogRotation:    man.getConfigBool("osquery.enable_log_rotation"),
+
+			AsyncSoftwareIngestion:          man.getConfigBool("osquery.async_software_ingestion"),
+			AsyncSoftwareIngestionInterval:  man.getConfigDuration("osquery.async_software_ingestion_interval"),
+			AsyncSoftwareIngestionBatchSize: man.getConfigInt("osquery.async_software_ingestion_batch_size"),
 		},
 		Logging: LoggingConfig{
 			Debug:         man.getConfigBool("logging.debug"),
@@ -460,6 +507,15 @@ func (man Manager) LoadConfig() FleetConfig {
 		License: LicenseConfig{
 			Key: man.getConfigString("license.key"),
 		},
+		Vulnerabilities: VulnerabilitiesConfig{
+			DatabasesPath:    man.getConfigString("vulnerabilities.databases_path"),
+			Periodicity:      man.getConfigDuration("vulnerabilities.periodicity"),
+			CVEFeedPrefixURL: man.getConfigString("vulnerabilities.cve_feed_prefix_url"),
+			EPSSFeedURL:      man.getConfigString("vulnerabilities.epss_feed_url"),
+			CISAKEVFeedURL:   man.getConfigString("vulnerabilities.cisa_kev_feed_url"),
+			MSRCFeedURL:      man.getConfigString("vulnerabilities.msrc_feed_url"),
+			DisableDataSync:  man.getConfigBool("vulnerabilities.disable_data_sync"),
+		},
 	}
 }
 
@@ -83,6 +83,7 @@ var TestFunctions = []func(*testing.T, fleet.Datastore){
 	testApplyPackSpecRoundtrip,
 	testApplyPackSpecMissingQueries,
 	testApplyPackSpecMissingName,
+	testApplyPackSpecTeamTargets,
 	testGetPackSpec,
 	testApplyLabelSpecsRoundtrip,
 	testGetLabelSpec,
@@ -100,6 +101,44 @@ var TestFunctions = []func(*testing.T, fleet.Datastore){
 	testUserTeams,
 	testUserCreateWithTeams,
 	testSaveHostSoftware,
+	testSimilarHostsBySoftware,
+	testSaveHostSoftwareSignatureStatus,
+	testReplaceAllHostSoftware,
+	testMarkHostSoftwareUpdates,
+	testLoadHostSoftwareGrouped,
+	testListHostSoftwareManaged,
+	testMostCommonSoftwareVersions,
+	testListHostSoftwareCollapseSources,
+	testTagSoftware,
+	testListSoftwareForLabel,
+	testHostSoftwareBaselineViolations,
+	testHostSoftwareArchMismatches,
+	testHostSoftwareCounts,
+	testLatestObservedSoftwareVersions,
+	testSoftwareNotes,
+	testSoftwareDriftFromReference,
+	testHostSoftwareDowngrades,
+	testSoftwareSources,
+	testHostsWithConflictingSoftwareVersions,
+	testListSoftwareForHostFilter,
+	testCleanupOrphanedSoftware,
+	testCleanupOrphanedSoftwareKeepsAdminData,
+	testListSoftware,
+	testCalculateHostsPerSoftware,
+	testListSoftwareByTeam,
+	testExportSoftware,
+	testListSoftwareTitles,
+	testListHostSoftwareVersionsByName,
+	testSoftwareInventoryEnabled,
+	testListHostSoftwareChanges,
+	testSaveHostSoftwareInstalled,
+	testSoftwareBrowserExtensions,
+	testSoftwareNormalization,
+	testSoftwareOVALResults,
+	testCVEMeta,
+	testSoftwareVulnerabilitiesSince,
+	testSoftwareCVESuppressions,
+	testOperatingSystemVulnerabilities,
 	testSaveUsers,
 	testNewActivity,
 	testEnsureGlobalPack,
@@ -44,6 +44,23 @@ func testOrgInfo(t *testing.T, ds fleet.Datastore) {
 	info2.MetadataURL = "https://idp.com/metadata.xml"
 	info2.IssuerURI = "https://idp.issuer.com"
 	info2.IDPName = "My IDP"
+	info2.SoftwareInstalledWebhookEnabled = true
+	info2.SoftwareInstalledWebhookURL = "https://example.com/webhook"
+	info2.SoftwareInstalledWebhookWatchlist = fleet.SoftwareWatchlist{
+		NamePatterns: []string{"^utorrent"},
+		CPEs:         []string{"cpe:2.3:a:*:netcat:*:*:*:*:*:*:*:*"},
+	}
+	info2.VulnerabilitiesWebhookEnabled = true
+	info2.VulnerabilitiesWebhookURL = "https://example.com/vulnerabilities"
+	info2.VulnerabilitiesWebhookHostBatchSize = 1000
+	info2.VulnerabilitiesWebhookMinCVSSScore = 7.5
+	info2.JiraURL = "https://example.atlassian.net"
+	info2.JiraUsername = "fleet@example.com"
+	info2.JiraAPIToken = "token"
+	info2.JiraProjectKey = "SEC"
+	info2.SoftwareNormalizationRules = fleet.SoftwareNormalizationRules{
+		{Pattern: "msedge", Source: "programs", CanonicalName: "Microsoft Edge"},
+	}
 
 	err = ds.SaveAppConfig(info2)
 	require.Nil(t, err)
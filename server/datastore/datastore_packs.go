@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"testing"
+	"time"
 
 	"github.com/WatchBeam/clock"
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -250,6 +251,51 @@ func testApplyPackSpecMissingQueries(t *testing.T, ds fleet.Datastore) {
 	}
 }
 
+func testApplyPackSpecTeamTargets(t *testing.T, ds fleet.Datastore) {
+	setupPackSpecsTest(t, ds)
+
+	team, err := ds.NewTeam(&fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+
+	specs := []*fleet.PackSpec{
+		{
+			Name: "team_pack",
+			Targets: fleet.PackSpecTargets{
+				Labels: []string{"foo"},
+				Teams:  []string{team.Name},
+			},
+			Queries: []fleet.PackSpecQuery{
+				{
+					QueryName: "foo",
+					Interval:  600,
+				},
+			},
+		},
+	}
+	require.NoError(t, ds.ApplyPackSpecs(specs))
+
+	spec, err := ds.GetPackSpec("team_pack")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, spec.Targets.Labels)
+	assert.Equal(t, []string{"team1"}, spec.Targets.Teams)
+
+	// Pack targets are used to determine which hosts run the pack.
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	require.NoError(t, ds.AddHostsToTeam(&team.ID, []uint{host.ID}))
+	packs, err := ds.ListPacksForHost(host.ID)
+	require.NoError(t, err)
+	var names []string
+	for _, p := range packs {
+		names = append(names, p.Name)
+	}
+	assert.Contains(t, names, "team_pack")
+
+	specs[0].Targets.Teams = []string{"missing"}
+	err = ds.ApplyPackSpecs(specs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown team 'missing'")
+}
+
 func testApplyPackSpecMissingName(t *testing.T, ds fleet.Datastore) {
 	setupPackSpecsTest(t, ds)
 
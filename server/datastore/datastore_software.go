@@ -1,10 +1,13 @@
 package datastore
 
 import (
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/fleetdm/fleet/v4/server/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -94,3 +97,1960 @@ func testSaveHostSoftware(t *testing.T, ds fleet.Datastore) {
 	assert.False(t, host1.HostSoftware.Modified)
 	test.ElementsMatchSkipID(t, soft1.Software, host1.HostSoftware.Software)
 }
+
+func testSimilarHostsBySoftware(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host3 := test.NewHost(t, ds, "host3", "", "host3key", "host3uuid", time.Now())
+	host4 := test.NewHost(t, ds, "host4", "", "host4key", "host4uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "chrome_extensions"},
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+			{Name: "baz", Version: "0.0.3", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "chrome_extensions"},
+		},
+	}
+	host3.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "chrome_extensions"},
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+			{Name: "baz", Version: "0.0.3", Source: "deb_packages"},
+			{Name: "towel", Version: "42.0.0", Source: "apps"},
+		},
+	}
+	host4.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "towel", Version: "42.0.0", Source: "apps"},
+		},
+	}
+	for _, host := range []*fleet.Host{host1, host2, host3, host4} {
+		require.NoError(t, ds.SaveHostSoftware(host))
+	}
+
+	similar, err := ds.SimilarHostsBySoftware(host1.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, similar, 2)
+	assert.Equal(t, host3.ID, similar[0].HostID)
+	assert.Equal(t, "host3", similar[0].Hostname)
+	assert.Equal(t, uint(3), similar[0].SharedSoftwareCount)
+	assert.Equal(t, host2.ID, similar[1].HostID)
+	assert.Equal(t, uint(1), similar[1].SharedSoftwareCount)
+
+	similar, err = ds.SimilarHostsBySoftware(host1.ID, 1)
+	require.NoError(t, err)
+	require.Len(t, similar, 1)
+	assert.Equal(t, host3.ID, similar[0].HostID)
+
+	similar, err = ds.SimilarHostsBySoftware(host4.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, similar, 1)
+	assert.Equal(t, host3.ID, similar[0].HostID)
+	assert.Equal(t, uint(1), similar[0].SharedSoftwareCount)
+}
+
+func testSaveHostSoftwareSignatureStatus(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+
+	soft := fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Signed.app", Version: "1.0", Source: "apps", SignatureStatus: ptr.String(fleet.SoftwareSigned)},
+			{Name: "Unsigned.app", Version: "2.0", Source: "apps", SignatureStatus: ptr.String(fleet.SoftwareUnsigned)},
+			{Name: "Unknown.app", Version: "3.0", Source: "apps"},
+		},
+	}
+	host.HostSoftware = soft
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	require.NoError(t, ds.LoadHostSoftware(host))
+	test.ElementsMatchSkipID(t, soft.Software, host.HostSoftware.Software)
+
+	// Changing only the signature status of existing software is persisted.
+	soft = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Signed.app", Version: "1.0", Source: "apps"},
+			{Name: "Unsigned.app", Version: "2.0", Source: "apps", SignatureStatus: ptr.String(fleet.SoftwareSigned)},
+			{Name: "Unknown.app", Version: "3.0", Source: "apps", SignatureStatus: ptr.String(fleet.SoftwareUnsigned)},
+		},
+	}
+	host.HostSoftware = soft
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	require.NoError(t, ds.LoadHostSoftware(host))
+	test.ElementsMatchSkipID(t, soft.Software, host.HostSoftware.Software)
+}
+
+func testReplaceAllHostSoftware(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "chrome_extensions"},
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	replacement := []fleet.Software{
+		{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+		{Name: "baz", Version: "1.0.0", Source: "deb_packages"},
+		{Name: "Signed.app", Version: "1.0", Source: "apps", SignatureStatus: ptr.String(fleet.SoftwareSigned)},
+		// Duplicates are collapsed.
+		{Name: "baz", Version: "1.0.0", Source: "deb_packages"},
+	}
+	since := time.Now().Add(-time.Minute)
+	require.NoError(t, ds.ReplaceAllHostSoftware(host1, replacement))
+	var installed []string
+	for _, s := range host1.HostSoftware.Installed {
+		installed = append(installed, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"baz", "Signed.app"}, installed)
+
+	require.NoError(t, ds.LoadHostSoftware(host1))
+	test.ElementsMatchSkipID(t, replacement[:3], host1.HostSoftware.Software)
+
+	// The replacement is recorded in the history and the changes, like the
+	// reported software.
+	added, err := ds.HostSoftwareAddedSince(host1.ID, since)
+	require.NoError(t, err)
+	var addedNames []string
+	for _, s := range added {
+		addedNames = append(addedNames, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"foo", "bar", "baz", "Signed.app"}, addedNames)
+	changes, err := ds.ListHostSoftwareChanges(host1.ID, fleet.ListOptions{})
+	require.NoError(t, err)
+	var actions []string
+	for _, c := range changes {
+		actions = append(actions, c.Action+" "+c.Name)
+	}
+	assert.ElementsMatch(t, []string{
+		"installed foo", "installed bar", // saved
+		"removed foo", "installed baz", "installed Signed.app", // replaced
+	}, actions)
+
+	// Other hosts are not affected.
+	require.NoError(t, ds.LoadHostSoftware(host2))
+	test.ElementsMatchSkipID(t, []fleet.Software{
+		{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+	}, host2.HostSoftware.Software)
+
+	// Software IDs are shared with the existing rows.
+	for _, s := range host1.HostSoftware.Software {
+		if s.Name == "bar" {
+			assert.Equal(t, host2.HostSoftware.Software[0].ID, s.ID)
+		}
+	}
+
+	require.NoError(t, ds.ReplaceAllHostSoftware(host1, nil))
+	assert.Empty(t, host1.HostSoftware.Installed)
+	require.NoError(t, ds.LoadHostSoftware(host1))
+	assert.Empty(t, host1.HostSoftware.Software)
+	changes, err = ds.ListHostSoftwareChanges(host1.ID, fleet.ListOptions{MatchQuery: "baz"})
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+}
+
+func testMarkHostSoftwareUpdates(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	soft := fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "deb_packages"},
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+		},
+	}
+	host1.HostSoftware = soft
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	host2.HostSoftware = soft
+	host2.HostSoftware.Modified = true
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	updatesAvailable := func(host *fleet.Host) map[string]bool {
+		require.NoError(t, ds.LoadHostSoftware(host))
+		result := make(map[string]bool)
+		for _, s := range host.Software {
+			result[s.Name+"@"+s.Version] = s.UpdateAvailable
+		}
+		return result
+	}
+	softwareID := func(host *fleet.Host, name string) uint {
+		require.NoError(t, ds.LoadHostSoftware(host))
+		for _, s := range host.Software {
+			if s.Name == name {
+				return s.ID
+			}
+		}
+		t.Fatalf("software %s not found", name)
+		return 0
+	}
+
+	assert.Equal(t, map[string]bool{"foo@0.0.1": false, "bar@0.0.2": false}, updatesAvailable(host1))
+
+	require.NoError(t, ds.MarkHostSoftwareUpdates(host1.ID, []uint{softwareID(host1, "foo")}))
+	assert.Equal(t, map[string]bool{"foo@0.0.1": true, "bar@0.0.2": false}, updatesAvailable(host1))
+	// Only the marked host is affected.
+	assert.Equal(t, map[string]bool{"foo@0.0.1": false, "bar@0.0.2": false}, updatesAvailable(host2))
+
+	// Upgrading the software clears the flag.
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.2", Source: "deb_packages"},
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	assert.Equal(t, map[string]bool{"foo@0.0.2": false, "bar@0.0.2": false}, updatesAvailable(host1))
+
+	// Marking again replaces the previous flags, and an empty list clears
+	// all of them.
+	require.NoError(t, ds.MarkHostSoftwareUpdates(host1.ID, []uint{softwareID(host1, "bar")}))
+	assert.Equal(t, map[string]bool{"foo@0.0.2": false, "bar@0.0.2": true}, updatesAvailable(host1))
+	require.NoError(t, ds.MarkHostSoftwareUpdates(host1.ID, nil))
+	assert.Equal(t, map[string]bool{"foo@0.0.2": false, "bar@0.0.2": false}, updatesAvailable(host1))
+}
+
+func testLoadHostSoftwareGrouped(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "zoo", Version: "1.0", Source: "deb_packages"},
+			{Name: "foo", Version: "0.0.3", Source: "chrome_extensions"},
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+			{Name: "foo", Version: "0.0.1", Source: "chrome_extensions"},
+			{Name: "towel", Version: "42.0.0", Source: "apps"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+
+	grouped, err := ds.LoadHostSoftwareGrouped(host1)
+	require.NoError(t, err)
+	require.Len(t, grouped, 3)
+
+	names := func(software []fleet.Software) []string {
+		var result []string
+		for _, s := range software {
+			result = append(result, s.Name+"@"+s.Version)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"bar@0.0.2", "zoo@1.0"}, names(grouped["deb_packages"]))
+	assert.Equal(t, []string{"foo@0.0.1", "foo@0.0.3"}, names(grouped["chrome_extensions"]))
+	assert.Equal(t, []string{"towel@42.0.0"}, names(grouped["apps"]))
+
+	grouped, err = ds.LoadHostSoftwareGrouped(host2)
+	require.NoError(t, err)
+	assert.NotNil(t, grouped)
+	assert.Empty(t, grouped)
+}
+
+func testListHostSoftwareManaged(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "curl", Version: "7.68.0", Source: "deb_packages", Managed: true},
+			{Name: "vim", Version: "8.1", Source: "deb_packages", Managed: true},
+			{Name: "requests", Version: "2.25.1", Source: "python_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	names := func(software []fleet.Software) []string {
+		var result []string
+		for _, s := range software {
+			result = append(result, s.Name)
+		}
+		return result
+	}
+	listOpts := fleet.ListOptions{OrderKey: "name"}
+
+	software, err := ds.ListHostSoftware(host.ID, fleet.SoftwareListOptions{ListOptions: listOpts})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"curl", "requests", "vim"}, names(software))
+
+	software, err = ds.ListHostSoftware(host.ID, fleet.SoftwareListOptions{ListOptions: listOpts, Managed: ptr.Bool(true)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"curl", "vim"}, names(software))
+	for _, s := range software {
+		assert.True(t, s.Managed)
+	}
+
+	software, err = ds.ListHostSoftware(host.ID, fleet.SoftwareListOptions{ListOptions: listOpts, Managed: ptr.Bool(false)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"requests"}, names(software))
+	assert.False(t, software[0].Managed)
+
+	// Changing the managed flag of installed software is persisted.
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "curl", Version: "7.68.0", Source: "deb_packages", Managed: true},
+			{Name: "vim", Version: "8.1", Source: "deb_packages"},
+			{Name: "requests", Version: "2.25.1", Source: "python_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	software, err = ds.ListHostSoftware(host.ID, fleet.SoftwareListOptions{ListOptions: listOpts, Managed: ptr.Bool(true)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"curl"}, names(software))
+
+	require.NoError(t, ds.LoadHostSoftware(host))
+	managed := make(map[string]bool)
+	for _, s := range host.Software {
+		managed[s.Name] = s.Managed
+	}
+	assert.Equal(t, map[string]bool{"curl": true, "vim": false, "requests": false}, managed)
+}
+
+func testMostCommonSoftwareVersions(t *testing.T, ds fleet.Datastore) {
+	var hosts []*fleet.Host
+	for i := 0; i < 5; i++ {
+		id := strconv.Itoa(i)
+		hosts = append(hosts, test.NewHost(t, ds, "host"+id, "", "host"+id+"key", "host"+id+"uuid", time.Now()))
+	}
+
+	installed := [][]fleet.Software{
+		{{Name: "foo", Version: "1.0", Source: "deb_packages"}, {Name: "bar", Version: "0.1", Source: "apps"}, {Name: "baz", Version: "10.0", Source: "apps"}},
+		{{Name: "foo", Version: "2.0", Source: "deb_packages"}, {Name: "bar", Version: "0.2", Source: "apps"}, {Name: "baz", Version: "9.0", Source: "apps"}},
+		{{Name: "foo", Version: "2.0", Source: "deb_packages"}, {Name: "foo", Version: "1.0", Source: "python_packages"}},
+		{{Name: "foo", Version: "2.0", Source: "deb_packages"}},
+		{{Name: "foo", Version: "1.0", Source: "deb_packages"}},
+	}
+	for i, software := range installed {
+		hosts[i].HostSoftware = fleet.HostSoftware{Modified: true, Software: software}
+		require.NoError(t, ds.SaveHostSoftware(hosts[i]))
+	}
+
+	software, err := ds.MostCommonSoftwareVersions(fleet.ListOptions{})
+	require.NoError(t, err)
+
+	type modal struct {
+		Version    string
+		HostsCount int
+	}
+	result := make(map[string]modal)
+	for _, s := range software {
+		key := s.Name + "/" + s.Source
+		_, duplicate := result[key]
+		require.False(t, duplicate, key)
+		result[key] = modal{s.Version, s.HostsCount}
+	}
+	assert.Equal(t, map[string]modal{
+		// 2.0 is installed on 3 hosts, 1.0 on 2.
+		"foo/deb_packages": {"2.0", 3},
+		// Same name but a different title.
+		"foo/python_packages": {"1.0", 1},
+		// Tied, the highest version wins.
+		"bar/apps": {"0.2", 1},
+		// Versions are compared numerically, not as strings.
+		"baz/apps": {"10.0", 1},
+	}, result)
+
+	software, err = ds.MostCommonSoftwareVersions(fleet.ListOptions{PerPage: 1})
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "bar", software[0].Name)
+}
+
+func testListHostSoftwareCollapseSources(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "python3", Version: "3.9.5", Source: "python_packages"},
+			{Name: "python3", Version: "3.9.2-3", Source: "deb_packages"},
+			{Name: "requests", Version: "2.25.1", Source: "python_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	listOpts := fleet.ListOptions{OrderKey: "name"}
+
+	// The raw rows are all returned without collapsing.
+	software, err := ds.ListHostSoftware(host.ID, fleet.SoftwareListOptions{ListOptions: listOpts})
+	require.NoError(t, err)
+	assert.Len(t, software, 3)
+
+	software, err = ds.ListHostSoftware(host.ID, fleet.SoftwareListOptions{ListOptions: listOpts, CollapseSources: true})
+	require.NoError(t, err)
+	var versions []string
+	for _, s := range software {
+		versions = append(versions, s.Name+"@"+s.Version+"/"+s.Source)
+	}
+	assert.Equal(t, []string{"python3@3.9.2-3/deb_packages", "requests@2.25.1/python_packages"}, versions)
+
+	// Pages are made of collapsed entries, python3 is not on both pages.
+	var pages []string
+	for page := uint(0); page < 3; page++ {
+		opts := fleet.ListOptions{OrderKey: "name", Page: page, PerPage: 1}
+		software, err = ds.ListHostSoftware(host.ID, fleet.SoftwareListOptions{ListOptions: opts, CollapseSources: true})
+		require.NoError(t, err)
+		for _, s := range software {
+			pages = append(pages, strconv.Itoa(int(page))+":"+s.Name+"/"+s.Source)
+		}
+	}
+	assert.Equal(t, []string{"0:python3/deb_packages", "1:requests/python_packages"}, pages)
+}
+
+func testTagSoftware(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Google Chrome", Version: "91.0", Source: "apps"},
+			{Name: "Visual Studio Code", Version: "1.58", Source: "apps"},
+			{Name: "osquery", Version: "4.9.0", Source: "apps"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+	require.NoError(t, ds.LoadHostSoftware(host))
+
+	ids := make(map[string]uint)
+	for _, s := range host.Software {
+		ids[s.Name] = s.ID
+	}
+
+	require.NoError(t, ds.TagSoftware(ids["Google Chrome"], []string{"browser", "browser"}))
+	require.NoError(t, ds.TagSoftware(ids["Visual Studio Code"], []string{"dev tool"}))
+	require.NoError(t, ds.TagSoftware(ids["osquery"], []string{"security", "dev tool"}))
+	// Tagging again with an existing category is a no-op.
+	require.NoError(t, ds.TagSoftware(ids["osquery"], []string{"security"}))
+	require.NoError(t, ds.TagSoftware(ids["osquery"], nil))
+	require.Error(t, ds.TagSoftware(ids["osquery"], []string{""}))
+
+	names := func(category string) []string {
+		software, err := ds.ListSoftwareByCategory(category, fleet.ListOptions{OrderKey: "name"})
+		require.NoError(t, err)
+		var result []string
+		for _, s := range software {
+			result = append(result, s.Name)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"Google Chrome"}, names("browser"))
+	assert.Equal(t, []string{"Visual Studio Code", "osquery"}, names("dev tool"))
+	assert.Equal(t, []string{"osquery"}, names("security"))
+	assert.Empty(t, names("unknown"))
+}
+
+func testListSoftwareForLabel(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host3 := test.NewHost(t, ds, "host3", "", "host3key", "host3uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	host3.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "baz", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	for _, host := range []*fleet.Host{host1, host2, host3} {
+		require.NoError(t, ds.SaveHostSoftware(host))
+	}
+
+	require.NoError(t, ds.ApplyLabelSpecs([]*fleet.LabelSpec{{Name: "label1", Query: "select 1"}}))
+	labelIDs, err := ds.LabelIDsByName([]string{"label1"})
+	require.NoError(t, err)
+	require.Len(t, labelIDs, 1)
+	labelID := labelIDs[0]
+
+	software, err := ds.ListSoftwareForLabel(labelID, fleet.SoftwareListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, software)
+
+	for _, host := range []*fleet.Host{host1, host2} {
+		require.NoError(t, ds.RecordLabelQueryExecutions(host, map[uint]bool{labelID: true}, time.Now()))
+	}
+	require.NoError(t, ds.RecordLabelQueryExecutions(host3, map[uint]bool{labelID: false}, time.Now()))
+
+	software, err = ds.ListSoftwareForLabel(labelID, fleet.SoftwareListOptions{ListOptions: fleet.ListOptions{OrderKey: "name"}})
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	var names []string
+	for _, s := range software {
+		names = append(names, s.Name)
+		counts[s.Name] = s.HostsCount
+	}
+	// baz is only installed on host3, which is not in the label, and host3
+	// isn't counted for foo.
+	assert.Equal(t, []string{"bar", "foo"}, names)
+	assert.Equal(t, map[string]int{"bar": 1, "foo": 2}, counts)
+}
+
+func testHostSoftwareBaselineViolations(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "curl", Version: "7.68.0", Source: "deb_packages"},
+			{Name: "vim", Version: "8.1", Source: "deb_packages"},
+			{Name: "nmap", Version: "7.80", Source: "deb_packages"},
+			{Name: "curl", Version: "1.0", Source: "python_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	names := func(software []fleet.Software) []string {
+		var result []string
+		for _, s := range software {
+			result = append(result, s.Name+"/"+s.Source)
+		}
+		return result
+	}
+
+	baseline := []fleet.Software{
+		// Versions are ignored when matching.
+		{Name: "curl", Version: "7.74.0", Source: "deb_packages"},
+		{Name: "vim", Source: "deb_packages"},
+		{Name: "htop", Source: "deb_packages"},
+	}
+	violations, err := ds.HostSoftwareBaselineViolations(host.ID, baseline)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"curl/python_packages", "nmap/deb_packages"}, names(violations))
+
+	violations, err = ds.HostSoftwareBaselineViolations(host.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"curl/deb_packages", "curl/python_packages", "nmap/deb_packages", "vim/deb_packages"}, names(violations))
+}
+
+func testHostSoftwareArchMismatches(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "curl", Version: "7.68.0", Source: "deb_packages", Arch: "amd64"},
+			{Name: "libc6", Version: "2.31", Source: "deb_packages", Arch: "i386"},
+			{Name: "tzdata", Version: "2021a", Source: "deb_packages", Arch: "all"},
+			{Name: "bash", Version: "5.1", Source: "rpm_packages", Arch: "noarch"},
+			{Name: "requests", Version: "2.25.1", Source: "python_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	mismatches, err := ds.HostSoftwareArchMismatches(host.ID, "amd64")
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, "libc6", mismatches[0].Name)
+	assert.Equal(t, "i386", mismatches[0].Arch)
+
+	// The arch of installed software is updated.
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "curl", Version: "7.68.0", Source: "deb_packages", Arch: "amd64"},
+			{Name: "libc6", Version: "2.31", Source: "deb_packages", Arch: "amd64"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	mismatches, err = ds.HostSoftwareArchMismatches(host.ID, "amd64")
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	mismatches, err = ds.HostSoftwareArchMismatches(host.ID, "arm64")
+	require.NoError(t, err)
+	assert.Len(t, mismatches, 2)
+}
+
+func testHostSoftwareCounts(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host3 := test.NewHost(t, ds, "host3", "", "host3key", "host3uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "chrome_extensions"},
+			{Name: "bar", Version: "0.0.3", Source: "deb_packages"},
+			{Name: "baz", Version: "0.0.4", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "chrome_extensions"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	counts, err := ds.HostSoftwareCounts(fleet.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint]int{host1.ID: 3, host2.ID: 1}, counts)
+	assert.NotContains(t, counts, host3.ID)
+
+	counts, err = ds.HostSoftwareCounts(fleet.ListOptions{PerPage: 1, Page: 1})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint]int{host2.ID: 1}, counts)
+}
+
+func testLatestObservedSoftwareVersions(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host3 := test.NewHost(t, ds, "host3", "", "host3key", "host3uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Chrome", Version: "94.0.4606.9", Source: "apps"},
+			{Name: "curl", Version: "1:7.68.0", Source: "deb_packages"},
+			// A pre-release is lower than its release for dpkg.
+			{Name: "zsh", Version: "5.8~rc1", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "chrome", Version: "94.0.4606.81", Source: "apps"},
+			{Name: "curl", Version: "7.74.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host2))
+	host3.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Chrome", Version: "94.0.4606.71", Source: "apps"},
+			{Name: "zsh", Version: "5.8", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host3))
+
+	latest, err := ds.LatestObservedSoftwareVersions(fleet.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"chrome": "94.0.4606.81",
+		"curl":   "1:7.68.0",
+		"zsh":    "5.8",
+	}, latest)
+
+	latest, err = ds.LatestObservedSoftwareVersions(fleet.ListOptions{PerPage: 2, Page: 1})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"zsh": "5.8"}, latest)
+}
+
+func testSoftwareNotes(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "chrome_extensions"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+	require.NoError(t, ds.LoadHostSoftware(host))
+	require.Len(t, host.Software, 1)
+	id := host.Software[0].ID
+
+	software, err := ds.SoftwareByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", software.Name)
+	assert.Empty(t, software.Note)
+
+	require.NoError(t, ds.SetSoftwareNote(id, "approved exception, ticket #123"))
+	software, err = ds.SoftwareByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "approved exception, ticket #123", software.Note)
+
+	require.NoError(t, ds.SetSoftwareNote(id, "exception expired"))
+	software, err = ds.SoftwareByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, "exception expired", software.Note)
+
+	require.NoError(t, ds.SetSoftwareNote(id, ""))
+	software, err = ds.SoftwareByID(id)
+	require.NoError(t, err)
+	assert.Empty(t, software.Note)
+
+	_, err = ds.SoftwareByID(id + 1000)
+	require.Error(t, err)
+	assert.Error(t, ds.SetSoftwareNote(id+1000, "note"))
+}
+
+func testSoftwareDriftFromReference(t *testing.T, ds fleet.Datastore) {
+	reference := test.NewHost(t, ds, "gold", "", "goldkey", "golduuid", time.Now())
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+
+	reference.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "curl", Version: "7.68.0", Source: "deb_packages"},
+			{Name: "openssl", Version: "1.1.1f", Source: "deb_packages"},
+			{Name: "osquery", Version: "4.9.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(reference))
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "curl", Version: "7.68.0", Source: "deb_packages"},
+			{Name: "openssl", Version: "1.1.1k", Source: "deb_packages"},
+			{Name: "nmap", Version: "7.80", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	names := func(software []fleet.Software) []string {
+		var result []string
+		for _, s := range software {
+			result = append(result, s.Name+"@"+s.Version)
+		}
+		return result
+	}
+
+	extra, missing, err := ds.SoftwareDriftFromReference(host.ID, reference.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nmap@7.80", "openssl@1.1.1k"}, names(extra))
+	assert.Equal(t, []string{"openssl@1.1.1f", "osquery@4.9.0"}, names(missing))
+
+	extra, missing, err = ds.SoftwareDriftFromReference(reference.ID, reference.ID)
+	require.NoError(t, err)
+	assert.Empty(t, extra)
+	assert.Empty(t, missing)
+}
+
+func testHostSoftwareDowngrades(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+
+	saveSoftware := func(software ...fleet.Software) {
+		host.HostSoftware = fleet.HostSoftware{Modified: true, Software: software}
+		require.NoError(t, ds.SaveHostSoftware(host))
+	}
+	saveSoftware(
+		fleet.Software{Name: "openssl", Version: "1.1.1f", Source: "deb_packages"},
+		fleet.Software{Name: "curl", Version: "7.68.0", Source: "deb_packages"},
+		fleet.Software{Name: "zsh", Version: "5.8~rc1", Source: "deb_packages"},
+	)
+	// Upgrade openssl, and zsh from a pre-release to its release.
+	saveSoftware(
+		fleet.Software{Name: "openssl", Version: "1.1.1k", Source: "deb_packages"},
+		fleet.Software{Name: "curl", Version: "7.68.0", Source: "deb_packages"},
+		fleet.Software{Name: "zsh", Version: "5.8", Source: "deb_packages"},
+	)
+	// Downgrade curl.
+	saveSoftware(
+		fleet.Software{Name: "openssl", Version: "1.1.1k", Source: "deb_packages"},
+		fleet.Software{Name: "curl", Version: "7.58.0", Source: "deb_packages"},
+		fleet.Software{Name: "zsh", Version: "5.8", Source: "deb_packages"},
+	)
+
+	downgrades, err := ds.HostSoftwareDowngrades(host.ID, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, downgrades, 1)
+	assert.Equal(t, "curl", downgrades[0].Name)
+	assert.Equal(t, "deb_packages", downgrades[0].Source)
+	assert.Equal(t, "7.68.0", downgrades[0].OldVersion)
+	assert.Equal(t, "7.58.0", downgrades[0].NewVersion)
+
+	// The downgrades are kept after the software is removed from all hosts
+	// and cleaned up.
+	saveSoftware()
+	_, err = ds.CleanupOrphanedSoftware(100)
+	require.NoError(t, err)
+	downgrades, err = ds.HostSoftwareDowngrades(host.ID, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, downgrades, 1)
+	assert.Equal(t, "curl", downgrades[0].Name)
+
+	downgrades, err = ds.HostSoftwareDowngrades(host.ID, time.Now().Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, downgrades)
+}
+
+func testSoftwareSources(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "deb_packages"},
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+			{Name: "baz", Version: "0.0.3", Source: "chrome_extensions"},
+			{Name: "qux", Version: "0.0.4", Source: "apps"},
+			{Name: "quux", Version: "0.0.5", Source: ""},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	sources, err := ds.SoftwareSources()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"apps", "chrome_extensions", "deb_packages"}, sources)
+}
+
+func testHostsWithConflictingSoftwareVersions(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "python", Version: "3.8.10", Source: "apps"},
+			{Name: "python", Version: "3.9.6", Source: "apps"},
+			{Name: "curl", Version: "7.68.0", Source: "apps"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "python", Version: "3.9.6", Source: "apps"},
+			{Name: "python", Version: "3.8.10", Source: "homebrew_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	hosts, err := ds.HostsWithConflictingSoftwareVersions("python", "apps")
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+	assert.Equal(t, host1.ID, hosts[0].ID)
+	assert.Equal(t, "host1", hosts[0].Hostname)
+
+	hosts, err = ds.HostsWithConflictingSoftwareVersions("curl", "apps")
+	require.NoError(t, err)
+	assert.Empty(t, hosts)
+}
+
+func testListSoftwareForHostFilter(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host3 := test.NewHost(t, ds, "host3", "", "host3key", "host3uuid", time.Now())
+
+	team1, err := ds.NewTeam(&fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+	require.NoError(t, ds.AddHostsToTeam(&team1.ID, []uint{host1.ID, host2.ID}))
+
+	for host, platform := range map[*fleet.Host]string{host1: "ubuntu", host2: "darwin", host3: "ubuntu"} {
+		host.Platform = platform
+		require.NoError(t, ds.SaveHost(host))
+	}
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "baz", Version: "1.0", Source: "apps"},
+		},
+	}
+	host3.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "qux", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	for _, host := range []*fleet.Host{host1, host2, host3} {
+		require.NoError(t, ds.SaveHostSoftware(host))
+	}
+
+	counts := func(filter fleet.HostListOptions) map[string]int {
+		software, err := ds.ListSoftwareForHostFilter(filter, fleet.SoftwareListOptions{})
+		require.NoError(t, err)
+		result := make(map[string]int)
+		for _, s := range software {
+			result[s.Name] = s.HostsCount
+		}
+		return result
+	}
+
+	assert.Equal(t, map[string]int{"foo": 3, "bar": 1, "baz": 1, "qux": 1}, counts(fleet.HostListOptions{}))
+	assert.Equal(t, map[string]int{"foo": 2, "bar": 1, "baz": 1}, counts(fleet.HostListOptions{TeamFilter: &team1.ID}))
+	assert.Equal(t, map[string]int{"foo": 2, "bar": 1, "qux": 1}, counts(fleet.HostListOptions{PlatformFilter: "ubuntu"}))
+	assert.Equal(t, map[string]int{"foo": 1, "bar": 1}, counts(fleet.HostListOptions{TeamFilter: &team1.ID, PlatformFilter: "ubuntu"}))
+	assert.Empty(t, counts(fleet.HostListOptions{TeamFilter: &team1.ID, PlatformFilter: "windows"}))
+}
+
+func testCleanupOrphanedSoftware(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "deb_packages"},
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+			{Name: "baz", Version: "0.0.3", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host2))
+	require.NoError(t, ds.LoadHostSoftware(host1))
+	ids := make(map[string]uint)
+	for _, s := range host1.Software {
+		ids[s.Name] = s.ID
+	}
+
+	deleted, err := ds.CleanupOrphanedSoftware(1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+
+	// foo is still installed on host2, bar and baz aren't installed anywhere.
+	host1.HostSoftware = fleet.HostSoftware{Modified: true, Software: []fleet.Software{}}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+
+	deleted, err = ds.CleanupOrphanedSoftware(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	_, err = ds.SoftwareByID(ids["foo"])
+	require.NoError(t, err)
+	for _, name := range []string{"bar", "baz"} {
+		_, err = ds.SoftwareByID(ids[name])
+		assert.Error(t, err, name)
+	}
+
+	// The history of the deleted software is kept.
+	changes, err := ds.ListHostSoftwareChanges(host1.ID, fleet.ListOptions{MatchQuery: "bar"})
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+}
+
+func testCleanupOrphanedSoftwareKeepsAdminData(t *testing.T, ds fleet.Datastore) {
+	user := test.NewUser(t, ds, "Zach", "zwinnerman@fleet.co", true)
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "noted", Version: "1.0", Source: "deb_packages"},
+			{Name: "tagged", Version: "1.0", Source: "deb_packages"},
+			{Name: "suppressed", Version: "1.0", Source: "deb_packages"},
+			{Name: "plain", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+	require.NoError(t, ds.LoadHostSoftware(host))
+	ids := make(map[string]uint)
+	for _, s := range host.Software {
+		ids[s.Name] = s.ID
+	}
+
+	require.NoError(t, ds.SetSoftwareNote(ids["noted"], "approved by IT"))
+	require.NoError(t, ds.TagSoftware(ids["tagged"], []string{"browsers"}))
+	_, err := ds.NewSoftwareCVESuppression(&fleet.SoftwareCVESuppression{
+		SoftwareID: ids["suppressed"],
+		CVE:        "CVE-2021-3449",
+		Reason:     "not exploitable",
+		AuthorID:   &user.ID,
+	})
+	require.NoError(t, err)
+
+	host.HostSoftware = fleet.HostSoftware{Modified: true, Software: []fleet.Software{}}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	deleted, err := ds.CleanupOrphanedSoftware(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, err = ds.SoftwareByID(ids["plain"])
+	assert.Error(t, err)
+	noted, err := ds.SoftwareByID(ids["noted"])
+	require.NoError(t, err)
+	assert.Equal(t, "approved by IT", noted.Note)
+	tagged, err := ds.ListSoftwareByCategory("browsers", fleet.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+	assert.Equal(t, ids["tagged"], tagged[0].ID)
+	suppressions, err := ds.ListSoftwareCVESuppressions(fleet.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, suppressions, 1)
+	assert.Equal(t, ids["suppressed"], suppressions[0].SoftwareID)
+
+	// The history of all the software is kept.
+	changes, err := ds.ListHostSoftwareChanges(host.ID, fleet.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, changes, 8)
+
+	_, err = ds.CleanupOrphanedSoftware(0)
+	require.Error(t, err)
+}
+
+func testListSoftware(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "2.0", Source: "deb_packages"},
+			{Name: "foobar", Version: "0.1", Source: "apps"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "2.0", Source: "deb_packages"},
+			{Name: "baz", Version: "3.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+	require.NoError(t, ds.CalculateHostsPerSoftware(time.Now()))
+
+	names := func(opt fleet.SoftwareListOptions) []string {
+		software, err := ds.ListSoftware(opt)
+		require.NoError(t, err)
+		var result []string
+		for _, s := range software {
+			result = append(result, s.Name)
+		}
+		return result
+	}
+
+	software, err := ds.ListSoftware(fleet.SoftwareListOptions{})
+	require.NoError(t, err)
+	require.Len(t, software, 4)
+	assert.Equal(t, "bar", software[0].Name)
+	assert.Equal(t, 2, software[0].HostsCount)
+
+	assert.Equal(t, []string{"bar", "baz", "foo", "foobar"}, names(fleet.SoftwareListOptions{}))
+	assert.Equal(t, []string{"bar", "baz"}, names(fleet.SoftwareListOptions{ListOptions: fleet.ListOptions{PerPage: 2}}))
+	assert.Equal(t, []string{"foo", "foobar"}, names(fleet.SoftwareListOptions{ListOptions: fleet.ListOptions{PerPage: 2, Page: 1}}))
+	assert.Equal(t, []string{"foo", "foobar"}, names(fleet.SoftwareListOptions{ListOptions: fleet.ListOptions{MatchQuery: "foo"}}))
+	assert.Equal(t, []string{"baz", "bar", "foo", "foobar"}, names(fleet.SoftwareListOptions{ListOptions: fleet.ListOptions{
+		OrderKey:       "version",
+		OrderDirection: fleet.OrderDescending,
+	}}))
+	assert.ElementsMatch(t, []string{"bar", "foo"}, names(fleet.SoftwareListOptions{ListOptions: fleet.ListOptions{
+		OrderKey:       "hosts_count",
+		OrderDirection: fleet.OrderDescending,
+		PerPage:        2,
+	}}))
+}
+
+func testCalculateHostsPerSoftware(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	counts := func() map[string]int {
+		software, err := ds.ListSoftware(fleet.SoftwareListOptions{})
+		require.NoError(t, err)
+		result := make(map[string]int)
+		for _, s := range software {
+			result[s.Name] = s.HostsCount
+		}
+		return result
+	}
+
+	// Nothing is listed before the first calculation.
+	assert.Empty(t, counts())
+
+	now := time.Now()
+	require.NoError(t, ds.CalculateHostsPerSoftware(now))
+	assert.Equal(t, map[string]int{"foo": 2, "bar": 1}, counts())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "baz", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+
+	// The counts are only refreshed by the next calculation.
+	assert.Equal(t, map[string]int{"foo": 2, "bar": 1}, counts())
+
+	require.NoError(t, ds.CalculateHostsPerSoftware(now.Add(time.Minute)))
+	assert.Equal(t, map[string]int{"foo": 2, "baz": 1}, counts())
+}
+
+func testListSoftwareByTeam(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host3 := test.NewHost(t, ds, "host3", "", "host3key", "host3uuid", time.Now())
+
+	team1, err := ds.NewTeam(&fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+	team2, err := ds.NewTeam(&fleet.Team{Name: "team2"})
+	require.NoError(t, err)
+	require.NoError(t, ds.AddHostsToTeam(&team1.ID, []uint{host1.ID, host2.ID}))
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	host3.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "baz", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	for _, host := range []*fleet.Host{host1, host2, host3} {
+		require.NoError(t, ds.SaveHostSoftware(host))
+	}
+
+	software, err := ds.ListSoftwareByTeam(team1.ID, fleet.SoftwareListOptions{})
+	require.NoError(t, err)
+	require.Len(t, software, 2)
+	assert.Equal(t, "bar", software[0].Name)
+	assert.Equal(t, 1, software[0].HostsCount)
+	assert.Equal(t, "foo", software[1].Name)
+	assert.Equal(t, 2, software[1].HostsCount)
+
+	software, err = ds.ListSoftwareByTeam(team1.ID, fleet.SoftwareListOptions{ListOptions: fleet.ListOptions{MatchQuery: "fo"}})
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "foo", software[0].Name)
+
+	software, err = ds.ListSoftwareByTeam(team2.ID, fleet.SoftwareListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, software)
+}
+
+func testExportSoftware(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+	require.NoError(t, ds.CalculateHostsPerSoftware(time.Now()))
+
+	software, err := ds.ListSoftware(fleet.SoftwareListOptions{})
+	require.NoError(t, err)
+	ids := make(map[string]uint)
+	for _, s := range software {
+		ids[s.Name] = s.ID
+	}
+	require.NoError(t, ds.ReplaceSoftwareCVEs(ids["foo"], []string{"CVE-2021-0002", "CVE-2021-0001"}))
+
+	exported := make(map[string]fleet.Software)
+	require.NoError(t, ds.ExportSoftware(func(s fleet.Software) error {
+		exported[s.Name] = s
+		return nil
+	}))
+	require.Len(t, exported, 2)
+	assert.Equal(t, 2, exported["foo"].HostsCount)
+	assert.Equal(t, fleet.VulnerabilitiesSlice{{CVE: "CVE-2021-0001"}, {CVE: "CVE-2021-0002"}}, exported["foo"].Vulnerabilities)
+	assert.Equal(t, 1, exported["bar"].HostsCount)
+	assert.Equal(t, fleet.VulnerabilitiesSlice{}, exported["bar"].Vulnerabilities)
+
+	// The export stops at the first error.
+	calls := 0
+	err = ds.ExportSoftware(func(s fleet.Software) error {
+		calls++
+		return errors.New("stop")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func testListSoftwareTitles(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host3 := test.NewHost(t, ds, "host3", "", "host3key", "host3uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "chrome", Version: "91.0", Source: "programs"},
+			{Name: "chrome", Version: "92.0", Source: "programs"},
+			{Name: "zsh", Version: "5.8", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "chrome", Version: "92.0", Source: "programs"},
+			{Name: "chrome", Version: "92.0", Source: "chrome_extensions"},
+		},
+	}
+	host3.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "chrome", Version: "100.0", Source: "programs"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+	require.NoError(t, ds.SaveHostSoftware(host3))
+
+	// Nothing is listed before the first calculation.
+	titles, err := ds.ListSoftwareTitles(fleet.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, titles)
+
+	now := time.Now()
+	require.NoError(t, ds.CalculateHostsPerSoftware(now))
+	require.NoError(t, ds.CalculateSoftwareTitles(now))
+
+	titles, err = ds.ListSoftwareTitles(fleet.ListOptions{OrderKey: "hosts_count", OrderDirection: fleet.OrderDescending})
+	require.NoError(t, err)
+	require.Len(t, titles, 3)
+
+	chrome := titles[0]
+	assert.Equal(t, "chrome", chrome.Name)
+	assert.Equal(t, "programs", chrome.Source)
+	// host1 has two versions installed and is counted once.
+	assert.Equal(t, 3, chrome.HostsCount)
+	assert.Equal(t, 3, chrome.VersionsCount)
+	var versions []string
+	var counts []int
+	for _, v := range chrome.Versions {
+		versions = append(versions, v.Version)
+		counts = append(counts, v.HostsCount)
+	}
+	assert.Equal(t, []string{"100.0", "92.0", "91.0"}, versions)
+	assert.Equal(t, []int{1, 2, 1}, counts)
+
+	for _, title := range titles[1:] {
+		assert.Equal(t, 1, title.HostsCount)
+		require.Len(t, title.Versions, 1)
+		assert.Equal(t, title.Source, title.Versions[0].Source)
+	}
+
+	titles, err = ds.ListSoftwareTitles(fleet.ListOptions{MatchQuery: "zs"})
+	require.NoError(t, err)
+	require.Len(t, titles, 1)
+	assert.Equal(t, "zsh", titles[0].Name)
+
+	// Titles no longer installed are removed by the next calculation.
+	host1.HostSoftware = fleet.HostSoftware{Modified: true}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.CalculateHostsPerSoftware(now.Add(time.Minute)))
+	require.NoError(t, ds.CalculateSoftwareTitles(now.Add(time.Minute)))
+
+	titles, err = ds.ListSoftwareTitles(fleet.ListOptions{OrderKey: "name"})
+	require.NoError(t, err)
+	require.Len(t, titles, 2)
+	assert.Equal(t, "chrome", titles[0].Name)
+	assert.Equal(t, 2, titles[0].HostsCount)
+	assert.Equal(t, 2, titles[0].VersionsCount)
+	assert.Len(t, titles[0].Versions, 2)
+}
+
+func testListHostSoftwareVersionsByName(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "openssl", Version: "1.1.1f", Source: "deb_packages"},
+			{Name: "zsh", Version: "5.8", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "openssl", Version: "1.1.1k", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	versions, err := ds.ListHostSoftwareVersionsByName("openssl")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, host1.ID, versions[0].HostID)
+	assert.Equal(t, "host1", versions[0].Hostname)
+	assert.Equal(t, "1.1.1f", versions[0].Version)
+	assert.Equal(t, host2.ID, versions[1].HostID)
+	assert.Equal(t, "1.1.1k", versions[1].Version)
+
+	versions, err = ds.ListHostSoftwareVersionsByName("bash")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
+func testSoftwareInventoryEnabled(t *testing.T, ds fleet.Datastore) {
+	// Enabled by default.
+	enabled, err := ds.SoftwareInventoryEnabled(nil)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	_, err = ds.NewAppConfig(&fleet.AppConfig{EnableSoftwareInventory: false})
+	require.NoError(t, err)
+	enabled, err = ds.SoftwareInventoryEnabled(nil)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	team1, err := ds.NewTeam(&fleet.Team{Name: "team1", TeamFeatures: fleet.TeamFeatures{EnableSoftwareInventory: ptr.Bool(true)}})
+	require.NoError(t, err)
+	team2, err := ds.NewTeam(&fleet.Team{Name: "team2"})
+	require.NoError(t, err)
+
+	team1, err = ds.Team(team1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ptr.Bool(true), team1.EnableSoftwareInventory)
+
+	enabled, err = ds.SoftwareInventoryEnabled(&team1.ID)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	// Teams without an override use the app config.
+	enabled, err = ds.SoftwareInventoryEnabled(&team2.ID)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	require.NoError(t, ds.AddHostsToTeam(&team1.ID, []uint{host2.ID}))
+	host2.TeamID = &team1.ID
+
+	soft := fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "chrome_extensions"},
+		},
+	}
+	host1.HostSoftware = soft
+	host2.HostSoftware = soft
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	require.NoError(t, ds.LoadHostSoftware(host1))
+	assert.Empty(t, host1.HostSoftware.Software)
+	require.NoError(t, ds.LoadHostSoftware(host2))
+	assert.Len(t, host2.HostSoftware.Software, 1)
+}
+
+func testListHostSoftwareChanges(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+
+	// The changes are kept after the software is removed from all hosts.
+	_, err := ds.CleanupOrphanedSoftware(100)
+	require.NoError(t, err)
+
+	actions := func(changes []fleet.HostSoftwareChange) []string {
+		var result []string
+		for _, c := range changes {
+			result = append(result, c.Action+" "+c.Name+" "+c.Version)
+		}
+		return result
+	}
+
+	// The changes are the ones recorded in the history.
+	changes, err := ds.ListHostSoftwareChanges(host1.ID, fleet.ListOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"installed foo 1.0", "installed bar 1.0", "removed bar 1.0"}, actions(changes))
+	added, err := ds.HostSoftwareAddedSince(host1.ID, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, added, 1)
+	assert.Equal(t, "foo", added[0].Name)
+	for _, c := range changes {
+		assert.Equal(t, "deb_packages", c.Source)
+		assert.False(t, c.CreatedAt.IsZero())
+	}
+
+	changes, err = ds.ListHostSoftwareChanges(host1.ID, fleet.ListOptions{MatchQuery: "bar"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"installed bar 1.0", "removed bar 1.0"}, actions(changes))
+
+	// Removing all the software of a host is recorded too.
+	host2.HostSoftware = fleet.HostSoftware{Modified: true}
+	require.NoError(t, ds.SaveHostSoftware(host2))
+	changes, err = ds.ListHostSoftwareChanges(host2.ID, fleet.ListOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"installed foo 1.0", "removed foo 1.0"}, actions(changes))
+
+	changes, err = ds.ListHostSoftwareChanges(host2.ID+100, fleet.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func testSaveHostSoftwareInstalled(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+
+	names := func(software []fleet.Software) []string {
+		var result []string
+		for _, s := range software {
+			assert.NotZero(t, s.ID)
+			result = append(result, s.Name)
+		}
+		return result
+	}
+
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+	assert.ElementsMatch(t, []string{"foo", "bar"}, names(host.HostSoftware.Installed))
+
+	host.HostSoftware.Modified = true
+	host.HostSoftware.Software = []fleet.Software{
+		{Name: "foo", Version: "1.0", Source: "deb_packages"},
+		{Name: "baz", Version: "1.0", Source: "deb_packages"},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+	assert.ElementsMatch(t, []string{"baz"}, names(host.HostSoftware.Installed))
+
+	// Nothing is installed when the software doesn't change.
+	host.HostSoftware.Modified = true
+	require.NoError(t, ds.SaveHostSoftware(host))
+	assert.Empty(t, host.HostSoftware.Installed)
+}
+
+func testSoftwareBrowserExtensions(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+
+	// The same extension installed in several browsers is different
+	// software.
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "uBlock Origin", Version: "1.37.2", Source: "chrome_extensions", ExtensionID: "cjpalhdlnbpafiamejdnhcphjbkeiagm", Browser: "chrome"},
+			{Name: "uBlock Origin", Version: "1.37.2", Source: "chrome_extensions", ExtensionID: "cjpalhdlnbpafiamejdnhcphjbkeiagm", Browser: "brave"},
+			{Name: "uBlock Origin", Version: "1.37.2", Source: "firefox_addons", ExtensionID: "uBlock0@raymondhill.net", Browser: "firefox"},
+			{Name: "curl", Version: "7.68.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	require.NoError(t, ds.LoadHostSoftware(host))
+	require.Len(t, host.Software, 4)
+	browsers := make(map[string]string)
+	for _, s := range host.Software {
+		browsers[s.Browser] = s.ExtensionID
+	}
+	assert.Equal(t, map[string]string{
+		"chrome":  "cjpalhdlnbpafiamejdnhcphjbkeiagm",
+		"brave":   "cjpalhdlnbpafiamejdnhcphjbkeiagm",
+		"firefox": "uBlock0@raymondhill.net",
+		"":        "",
+	}, browsers)
+
+	// Saving the same software again changes nothing.
+	host.HostSoftware.Modified = true
+	require.NoError(t, ds.SaveHostSoftware(host))
+	assert.Empty(t, host.HostSoftware.Installed)
+
+	software, err := ds.ListHostSoftware(host.ID, fleet.SoftwareListOptions{Browser: ptr.String("firefox")})
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "firefox_addons", software[0].Source)
+
+	software, err = ds.ListHostSoftware(host.ID, fleet.SoftwareListOptions{Browser: ptr.String("")})
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "curl", software[0].Name)
+
+	require.NoError(t, ds.CalculateHostsPerSoftware(time.Now()))
+	software, err = ds.ListSoftware(fleet.SoftwareListOptions{Browser: ptr.String("chrome")})
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "cjpalhdlnbpafiamejdnhcphjbkeiagm", software[0].ExtensionID)
+}
+
+func testSoftwareNormalization(t *testing.T, ds fleet.Datastore) {
+	_, err := ds.NewAppConfig(&fleet.AppConfig{
+		EnableSoftwareInventory: true,
+		SoftwareNormalizationRules: fleet.SoftwareNormalizationRules{
+			{Pattern: "msedge|microsoft edge", CanonicalName: "Microsoft Edge"},
+		},
+	})
+	require.NoError(t, err)
+
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "msedge", Version: "92.0.902.67", Source: "programs"},
+			{Name: "curl", Version: "7.68.0", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Microsoft Edge", Version: "92.0.902.67", Source: "programs"},
+			// Reported twice under different names, stored once.
+			{Name: "MSEdge", Version: "92.0.902.67", Source: "programs"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	require.NoError(t, ds.LoadHostSoftware(host1))
+	require.NoError(t, ds.LoadHostSoftware(host2))
+	require.Len(t, host1.Software, 2)
+	require.Len(t, host2.Software, 1)
+	assert.Equal(t, "Microsoft Edge", host2.Software[0].Name)
+	for _, s := range host1.Software {
+		if s.Source == "programs" {
+			assert.Equal(t, "Microsoft Edge", s.Name)
+			// Both hosts have the same software.
+			assert.Equal(t, host2.Software[0].ID, s.ID)
+		}
+	}
+
+	// Saving the software as reported again changes nothing.
+	host1.HostSoftware.Modified = true
+	host1.HostSoftware.Software = []fleet.Software{
+		{Name: "msedge", Version: "92.0.902.67", Source: "programs"},
+		{Name: "curl", Version: "7.68.0", Source: "deb_packages"},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	assert.Empty(t, host1.HostSoftware.Installed)
+}
+
+func testSoftwareOVALResults(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host3 := test.NewHost(t, ds, "host3", "", "host3key", "host3uuid", time.Now())
+
+	for host, os := range map[*fleet.Host]fleet.HostOSVersion{
+		host1: {Platform: "ubuntu", OSVersion: "Ubuntu 20.04.2 LTS"},
+		host2: {Platform: "ubuntu", OSVersion: "Ubuntu 20.04.2 LTS"},
+		host3: {Platform: "ubuntu", OSVersion: "Ubuntu 18.04.5 LTS"},
+	} {
+		host.Platform = os.Platform
+		host.OSVersion = os.OSVersion
+		require.NoError(t, ds.SaveHost(host))
+	}
+
+	versions, err := ds.ListHostOSVersions()
+	require.NoError(t, err)
+	assert.Equal(t, []fleet.HostOSVersion{
+		{Platform: "ubuntu", OSVersion: "Ubuntu 18.04.5 LTS"},
+		{Platform: "ubuntu", OSVersion: "Ubuntu 20.04.2 LTS"},
+	}, versions)
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+			{Name: "zsh", Version: "5.8-3ubuntu1", Source: "deb_packages"},
+			{Name: "requests", Version: "2.22.0", Source: "python_packages"},
+			{Name: "curl", Version: "7.61.1", Source: "rpm_packages", Epoch: ptr.Uint(1), Release: "18.el8"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+		},
+	}
+	host3.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "openssl", Version: "1.1.1-1ubuntu2.1~18.04.9", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+	require.NoError(t, ds.SaveHostSoftware(host3))
+
+	software, err := ds.ListSoftwareByHostOS(fleet.HostOSVersion{Platform: "ubuntu", OSVersion: "Ubuntu 20.04.2 LTS"}, "deb_packages")
+	require.NoError(t, err)
+	require.Len(t, software, 2)
+	ids := make(map[string]uint)
+	for _, s := range software {
+		ids[s.Name] = s.ID
+	}
+	require.Contains(t, ids, "openssl")
+	require.Contains(t, ids, "zsh")
+
+	// The epoch and release of RPM packages are loaded for the evaluation.
+	rpms, err := ds.ListSoftwareByHostOS(fleet.HostOSVersion{Platform: "ubuntu", OSVersion: "Ubuntu 20.04.2 LTS"}, "rpm_packages")
+	require.NoError(t, err)
+	require.Len(t, rpms, 1)
+	assert.Equal(t, "7.61.1", rpms[0].Version)
+	assert.Equal(t, ptr.Uint(1), rpms[0].Epoch)
+	assert.Equal(t, "18.el8", rpms[0].Release)
+
+	for name, id := range ids {
+		require.NoError(t, ds.AddCPEForSoftware(id, "cpe:2.3:a:*:"+name+":1.0:*:*:*:*:*:*:*"))
+	}
+	results := []fleet.SoftwareOVALResult{
+		{CVE: "CVE-2021-3449", DefinitionID: "oval:com.ubuntu.focal:def:2021344900000000", Vulnerable: true},
+		{CVE: "CVE-2021-3450", DefinitionID: "oval:com.ubuntu.focal:def:2021345000000000", Vulnerable: false},
+	}
+	require.NoError(t, ds.ReplaceSoftwareOVALResults(ids["openssl"], results))
+	require.NoError(t, ds.ReplaceSoftwareOVALResults(ids["openssl"], results[:1]))
+
+	// The software with OVAL results isn't matched against the NVD.
+	cpes, err := ds.ListSoftwareCPEs()
+	require.NoError(t, err)
+	require.Len(t, cpes, 1)
+	assert.Equal(t, ids["zsh"], cpes[0].SoftwareID)
+
+	require.NoError(t, ds.ReplaceSoftwareOVALResults(ids["openssl"], nil))
+	cpes, err = ds.ListSoftwareCPEs()
+	require.NoError(t, err)
+	assert.Len(t, cpes, 2)
+}
+
+func testCVEMeta(t *testing.T, ds fleet.Datastore) {
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+			{Name: "zsh", Version: "5.8-3ubuntu1", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+	require.NoError(t, ds.LoadHostSoftware(host))
+	ids := make(map[string]uint)
+	for _, s := range host.Software {
+		ids[s.Name] = s.ID
+	}
+	require.NoError(t, ds.ReplaceSoftwareCVEs(ids["openssl"], []string{"CVE-2021-3449", "CVE-2021-3450"}))
+
+	require.NoError(t, ds.InsertCVEMeta([]fleet.CVEMeta{
+		{CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9), EPSSProbability: ptr.Float64(0.01)},
+		{CVE: "CVE-2021-9999", CVSSScore: ptr.Float64(9.8)},
+	}))
+	// Existing meta is replaced.
+	require.NoError(t, ds.InsertCVEMeta([]fleet.CVEMeta{
+		{CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9), EPSSProbability: ptr.Float64(0.02), CISAKnownExploit: true},
+	}))
+
+	expected := map[string]fleet.VulnerabilitiesSlice{
+		"openssl": {
+			{CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9), EPSSProbability: ptr.Float64(0.02), CISAKnownExploit: true},
+			{CVE: "CVE-2021-3450"},
+		},
+		"zsh": {},
+	}
+
+	require.NoError(t, ds.LoadHostSoftwareWithVulnerabilities(host))
+	require.Len(t, host.Software, 2)
+	for _, s := range host.Software {
+		assert.Equal(t, expected[s.Name], s.Vulnerabilities)
+	}
+
+	loaded := []fleet.Software{{ID: ids["openssl"]}, {ID: ids["zsh"]}}
+	require.NoError(t, ds.LoadSoftwareVulnerabilities(loaded))
+	assert.Equal(t, expected["openssl"], loaded[0].Vulnerabilities)
+	assert.Equal(t, expected["zsh"], loaded[1].Vulnerabilities)
+
+	require.NoError(t, ds.CalculateHostsPerSoftware(time.Now()))
+	exported := make(map[string]fleet.VulnerabilitiesSlice)
+	require.NoError(t, ds.ExportSoftware(func(s fleet.Software) error {
+		exported[s.Name] = s.Vulnerabilities
+		return nil
+	}))
+	assert.Equal(t, expected, exported)
+
+	// Only the software with a known exploited vulnerability is listed.
+	software, err := ds.ListSoftware(fleet.SoftwareListOptions{KnownExploit: true})
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "openssl", software[0].Name)
+
+	team, err := ds.NewTeam(&fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+	require.NoError(t, ds.AddHostsToTeam(&team.ID, []uint{host.ID}))
+	software, err = ds.ListSoftwareByTeam(team.ID, fleet.SoftwareListOptions{KnownExploit: true})
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "openssl", software[0].Name)
+}
+
+func testSoftwareVulnerabilitiesSince(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+			{Name: "zsh", Version: "5.8-3ubuntu1", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+	require.NoError(t, ds.LoadHostSoftware(host1))
+	ids := make(map[string]uint)
+	for _, s := range host1.Software {
+		ids[s.Name] = s.ID
+	}
+
+	require.NoError(t, ds.ReplaceSoftwareCVEs(ids["zsh"], []string{"CVE-2021-0001"}))
+	// The created_at of software_cve has a precision of one second.
+	time.Sleep(time.Second)
+	since := time.Now().Truncate(time.Second)
+	require.NoError(t, ds.ReplaceSoftwareCVEs(ids["zsh"], []string{"CVE-2021-0001"}))
+	require.NoError(t, ds.ReplaceSoftwareCVEs(ids["openssl"], []string{"CVE-2021-3449"}))
+	require.NoError(t, ds.InsertCVEMeta([]fleet.CVEMeta{{CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9)}}))
+
+	// CVEs that were already found aren't found again.
+	vulnerabilities, err := ds.ListSoftwareVulnerabilitiesSince(since)
+	require.NoError(t, err)
+	assert.Equal(t, []fleet.SoftwareVulnerability{
+		{SoftwareID: ids["openssl"], Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages", CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9)},
+	}, vulnerabilities)
+
+	versions, err := ds.ListHostSoftwareVersionsBySoftwareID([]uint{ids["openssl"]})
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, host1.ID, versions[0].HostID)
+	assert.Equal(t, "host1", versions[0].Hostname)
+	assert.Equal(t, host2.ID, versions[1].HostID)
+	assert.Equal(t, "openssl", versions[1].Name)
+
+	versions, err = ds.ListHostSoftwareVersionsBySoftwareID(nil)
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
+func testSoftwareCVESuppressions(t *testing.T, ds fleet.Datastore) {
+	user := test.NewUser(t, ds, "Zach", "zwinnerman@fleet.co", true)
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+	require.NoError(t, ds.LoadHostSoftware(host))
+	software := host.Software[0]
+
+	since := time.Now().Truncate(time.Second)
+	require.NoError(t, ds.ReplaceSoftwareCVEs(software.ID, []string{"CVE-2021-3449", "CVE-2021-3450"}))
+
+	suppression, err := ds.NewSoftwareCVESuppression(&fleet.SoftwareCVESuppression{
+		SoftwareID: software.ID,
+		CVE:        "CVE-2021-3450",
+		Reason:     "not exploitable",
+		AuthorID:   &user.ID,
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, suppression.ID)
+
+	_, err = ds.NewSoftwareCVESuppression(&fleet.SoftwareCVESuppression{SoftwareID: software.ID, CVE: "CVE-2021-3450", Reason: "again"})
+	require.Error(t, err)
+
+	suppression, err = ds.SoftwareCVESuppression(suppression.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Zach", suppression.AuthorName)
+	assert.Equal(t, "openssl", suppression.SoftwareName)
+	assert.Equal(t, "1.1.1f-1ubuntu2", suppression.SoftwareVersion)
+
+	suppressions, err := ds.ListSoftwareCVESuppressions(fleet.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, suppressions, 1)
+	assert.Equal(t, "CVE-2021-3450", suppressions[0].CVE)
+
+	// Suppressed vulnerabilities are excluded from the listings.
+	require.NoError(t, ds.LoadHostSoftwareWithVulnerabilities(host))
+	require.Len(t, host.Software[0].Vulnerabilities, 1)
+	assert.Equal(t, "CVE-2021-3449", host.Software[0].Vulnerabilities[0].CVE)
+
+	vulnerabilities, err := ds.ListSoftwareVulnerabilitiesSince(since)
+	require.NoError(t, err)
+	require.Len(t, vulnerabilities, 1)
+	assert.Equal(t, "CVE-2021-3449", vulnerabilities[0].CVE)
+
+	// The suppressions remain when the software is removed from all hosts
+	// and cleaned up, and apply again when it is reinstalled.
+	saved := host.HostSoftware
+	host.HostSoftware = fleet.HostSoftware{Modified: true, Software: []fleet.Software{}}
+	require.NoError(t, ds.SaveHostSoftware(host))
+	_, err = ds.CleanupOrphanedSoftware(100)
+	require.NoError(t, err)
+	suppressions, err = ds.ListSoftwareCVESuppressions(fleet.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, suppressions, 1)
+	assert.Equal(t, software.ID, suppressions[0].SoftwareID)
+	assert.Equal(t, "openssl", suppressions[0].SoftwareName)
+	assert.Equal(t, "1.1.1f-1ubuntu2", suppressions[0].SoftwareVersion)
+	assert.Equal(t, "deb_packages", suppressions[0].SoftwareSource)
+
+	host.HostSoftware = saved
+	host.HostSoftware.Modified = true
+	require.NoError(t, ds.SaveHostSoftware(host))
+	require.NoError(t, ds.LoadHostSoftwareWithVulnerabilities(host))
+	require.Len(t, host.Software, 1)
+	assert.Equal(t, software.ID, host.Software[0].ID)
+	require.Len(t, host.Software[0].Vulnerabilities, 1)
+	assert.Equal(t, "CVE-2021-3449", host.Software[0].Vulnerabilities[0].CVE)
+
+	_, err = ds.NewSoftwareCVESuppression(&fleet.SoftwareCVESuppression{SoftwareID: software.ID + 1000, CVE: "CVE-2021-3449", Reason: "missing"})
+	assert.True(t, fleet.IsNotFound(err))
+
+	require.NoError(t, ds.DeleteSoftwareCVESuppression(suppression.ID))
+	require.Error(t, ds.DeleteSoftwareCVESuppression(suppression.ID))
+	require.NoError(t, ds.LoadHostSoftwareWithVulnerabilities(host))
+	assert.Len(t, host.Software[0].Vulnerabilities, 2)
+}
+
+func testOperatingSystemVulnerabilities(t *testing.T, ds fleet.Datastore) {
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host1.Platform = "windows"
+	host1.Build = "19043"
+	host1.WindowsUpdates = []string{"KB5004237", "KB5003690"}
+	require.NoError(t, ds.SaveHost(host1))
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+	host2.Platform = "windows"
+	host2.Build = "17763"
+	require.NoError(t, ds.SaveHost(host2))
+	test.NewHost(t, ds, "host3", "", "host3key", "host3uuid", time.Now())
+
+	hosts, err := ds.ListWindowsHostUpdates()
+	require.NoError(t, err)
+	assert.Equal(t, []fleet.WindowsHostUpdates{
+		{HostID: host1.ID, Build: "19043", KBIDs: []string{"KB5003690", "KB5004237"}},
+		{HostID: host2.ID, Build: "17763"},
+	}, hosts)
+
+	// Updates are replaced when reported again, and kept otherwise.
+	host1.WindowsUpdates = []string{"KB5005033"}
+	require.NoError(t, ds.SaveHost(host1))
+	host1.WindowsUpdates = nil
+	require.NoError(t, ds.SaveHost(host1))
+	hosts, err = ds.ListWindowsHostUpdates()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"KB5005033"}, hosts[0].KBIDs)
+
+	require.NoError(t, ds.ReplaceHostOperatingSystemVulnerabilities(host1.ID, []fleet.OperatingSystemVulnerability{
+		{CVE: "CVE-2021-36940", ResolvedIn: "KB5005033"},
+		{CVE: "CVE-2021-33757", ResolvedIn: "KB5004237"},
+	}))
+	vulnerabilities, err := ds.ListHostOperatingSystemVulnerabilities(host1.ID)
+	require.NoError(t, err)
+	require.Len(t, vulnerabilities, 2)
+	assert.Equal(t, "CVE-2021-33757", vulnerabilities[0].CVE)
+	assert.Equal(t, "KB5004237", vulnerabilities[0].ResolvedIn)
+	assert.NotZero(t, vulnerabilities[0].CreatedAt)
+
+	require.NoError(t, ds.ReplaceHostOperatingSystemVulnerabilities(host1.ID, []fleet.OperatingSystemVulnerability{
+		{CVE: "CVE-2021-36940", ResolvedIn: "KB5005033"},
+	}))
+	vulnerabilities, err = ds.ListHostOperatingSystemVulnerabilities(host1.ID)
+	require.NoError(t, err)
+	require.Len(t, vulnerabilities, 1)
+	assert.Equal(t, "CVE-2021-36940", vulnerabilities[0].CVE)
+
+	require.NoError(t, ds.ReplaceHostOperatingSystemVulnerabilities(host1.ID, nil))
+	vulnerabilities, err = ds.ListHostOperatingSystemVulnerabilities(host1.ID)
+	require.NoError(t, err)
+	assert.Empty(t, vulnerabilities)
+
+	vulnerabilities, err = ds.ListHostOperatingSystemVulnerabilities(host2.ID)
+	require.NoError(t, err)
+	assert.Empty(t, vulnerabilities)
+}
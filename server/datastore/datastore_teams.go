@@ -44,6 +44,7 @@ func testTeamGetSetDelete(t *testing.T, ds fleet.Datastore) {
 
 			team, err = ds.TeamByName(tt.name)
 			require.Error(t, err)
+			assert.True(t, fleet.IsNotFound(err))
 		})
 	}
 }
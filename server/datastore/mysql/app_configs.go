@@ -122,9 +122,23 @@ func (d *Datastore) SaveAppConfig(info *fleet.AppConfig) error {
 			live_query_disabled,
 			additional_queries,
 			agent_options,
-			enable_analytics
+			enable_analytics,
+			enable_software_inventory,
+			software_installed_webhook_enabled,
+			software_installed_webhook_url,
+			software_installed_webhook_watchlist,
+			software_normalization_rules,
+			vulnerabilities_webhook_enabled,
+			vulnerabilities_webhook_url,
+			vulnerabilities_webhook_host_batch_size,
+			vulnerabilities_webhook_min_cvss_score,
+			jira_integration_enabled,
+			jira_url,
+			jira_username,
+			jira_api_token,
+			jira_project_key
 		)
-		VALUES( 1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )
+		VALUES( 1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )
 		ON DUPLICATE KEY UPDATE
 			org_name = VALUES(org_name),
 			org_logo_url = VALUES(org_logo_url),
@@ -156,7 +170,21 @@ func (d *Datastore) SaveAppConfig(info *fleet.AppConfig) error {
 			live_query_disabled = VALUES(live_query_disabled),
 			additional_queries = VALUES(additional_queries),
 			agent_options = VALUES(agent_options),
-			enable_analytics = VALUES(enable_analytics)
+			enable_analytics = VALUES(enable_analytics),
+			enable_software_inventory = VALUES(enable_software_inventory),
+			software_installed_webhook_enabled = VALUES(software_installed_webhook_enabled),
+			software_installed_webhook_url = VALUES(software_installed_webhook_url),
+			software_installed_webhook_watchlist = VALUES(software_installed_webhook_watchlist),
+			software_normalization_rules = VALUES(software_normalization_rules),
+			vulnerabilities_webhook_enabled = VALUES(vulnerabilities_webhook_enabled),
+			vulnerabilities_webhook_url = VALUES(vulnerabilities_webhook_url),
+			vulnerabilities_webhook_host_batch_size = VALUES(vulnerabilities_webhook_host_batch_size),
+			vulnerabilities_webhook_min_cvss_score = VALUES(vulnerabilities_webhook_min_cvss_score),
+			jira_integration_enabled = VALUES(jira_integration_enabled),
+			jira_url = VALUES(jira_url),
+			jira_username = VALUES(jira_username),
+			jira_api_token = VALUES(jira_api_token),
+			jira_project_key = VALUES(jira_project_key)
     `
 
 		_, err = tx.Exec(insertStatement,
@@ -191,6 +219,20 @@ func (d *Datastore) SaveAppConfig(info *fleet.AppConfig) error {
 			info.AdditionalQueries,
 			info.AgentOptions,
 			info.EnableAnalytics,
+			info.EnableSoftwareInventory,
+			info.SoftwareInstalledWebhookEnabled,
+			info.SoftwareInstalledWebhookURL,
+			info.SoftwareInstalledWebhookWatchlist,
+			info.SoftwareNormalizationRules,
+			info.VulnerabilitiesWebhookEnabled,
+			info.VulnerabilitiesWebhookURL,
+			info.VulnerabilitiesWebhookHostBatchSize,
+			info.VulnerabilitiesWebhookMinCVSSScore,
+			info.JiraIntegrationEnabled,
+			info.JiraURL,
+			info.JiraUsername,
+			info.JiraAPIToken,
+			info.JiraProjectKey,
 		)
 		if err != nil {
 			return err
@@ -139,6 +139,14 @@ func (d *Datastore) SaveHost(host *fleet.Host) error {
 		}
 	}
 
+	// Save the Windows updates only if they are non-nil, like the pack
+	// stats.
+	if host.WindowsUpdates != nil {
+		if err := d.saveHostWindowsUpdates(host); err != nil {
+			return err
+		}
+	}
+
 	if host.HostSoftware.Modified {
 		if err := d.SaveHostSoftware(host); err != nil {
 			return errors.Wrap(err, "failed to save host software")
@@ -226,6 +234,31 @@ func (d *Datastore) saveHostPackStats(host *fleet.Host) error {
 	return nil
 }
 
+func (d *Datastore) saveHostWindowsUpdates(host *fleet.Host) error {
+	if err := d.withRetryTxx(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM host_windows_updates WHERE host_id = ?`, host.ID); err != nil {
+			return errors.Wrap(err, "delete old windows updates")
+		}
+		if len(host.WindowsUpdates) == 0 {
+			return nil
+		}
+
+		args := make([]interface{}, 0, 2*len(host.WindowsUpdates))
+		for _, kbID := range host.WindowsUpdates {
+			args = append(args, host.ID, kbID)
+		}
+		values := strings.TrimSuffix(strings.Repeat("(?, ?),", len(host.WindowsUpdates)), ",")
+		sql := fmt.Sprintf(`INSERT IGNORE INTO host_windows_updates (host_id, kb_id) VALUES %s`, values)
+		if _, err := tx.Exec(sql, args...); err != nil {
+			return errors.Wrap(err, "insert windows updates")
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "save windows updates")
+	}
+	return nil
+}
+
 func (d *Datastore) loadHostPackStats(host *fleet.Host) error {
 	sql := `
 SELECT
@@ -342,6 +375,24 @@ func (d *Datastore) ListHosts(filter fleet.TeamFilter, opt fleet.HostListOptions
 		WHERE TRUE AND %s
     `, d.whereFilterHostsByTeams(filter, "h"),
 	)
+	sql, params = filterHostsByListOptions(sql, params, opt)
+
+	sql, params = searchLike(sql, params, opt.MatchQuery, hostSearchColumns...)
+
+	sql = appendListOptionsToSQL(sql, opt.ListOptions)
+
+	hosts := []*fleet.Host{}
+	if err := d.db.Select(&hosts, sql, params...); err != nil {
+		return nil, errors.Wrap(err, "list hosts")
+	}
+
+	return hosts, nil
+}
+
+// filterHostsByListOptions appends the conditions selecting the hosts (as h)
+// matching the status, team, platform and label filters of the options.
+// Unset filters don't select.
+func filterHostsByListOptions(sql string, params []interface{}, opt fleet.HostListOptions) (string, []interface{}) {
 	switch opt.StatusFilter {
 	case "new":
 		sql += "AND DATE_ADD(h.created_at, INTERVAL 1 DAY) >= ?"
@@ -357,16 +408,19 @@ func (d *Datastore) ListHosts(filter fleet.TeamFilter, opt fleet.HostListOptions
 		params = append(params, time.Now())
 	}
 
-	sql, params = searchLike(sql, params, opt.MatchQuery, hostSearchColumns...)
-
-	sql = appendListOptionsToSQL(sql, opt.ListOptions)
-
-	hosts := []*fleet.Host{}
-	if err := d.db.Select(&hosts, sql, params...); err != nil {
-		return nil, errors.Wrap(err, "list hosts")
+	if opt.TeamFilter != nil {
+		sql += " AND h.team_id = ?"
+		params = append(params, *opt.TeamFilter)
 	}
-
-	return hosts, nil
+	if opt.PlatformFilter != "" {
+		sql += " AND h.platform = ?"
+		params = append(params, opt.PlatformFilter)
+	}
+	if opt.LabelFilter != nil {
+		sql += " AND h.id IN (SELECT host_id FROM label_membership WHERE label_id = ?)"
+		params = append(params, *opt.LabelFilter)
+	}
+	return sql, params
 }
 
 func (d *Datastore) CleanupIncomingHosts(now time.Time) error {
@@ -0,0 +1,26 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210719153709, Down_20210719153709)
+}
+
+func Up_20210719153709(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE host_software
+		ADD COLUMN signature_status varchar(32) DEFAULT NULL
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add signature_status")
+	}
+	return nil
+}
+
+func Down_20210719153709(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,34 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210720120514, Down_20210720120514)
+}
+
+func Up_20210720120514(tx *sql.Tx) error {
+	// Add the column without a default first so that software that existed
+	// before this migration keeps an unknown (NULL) first seen time, then
+	// default newly inserted software to the time of insertion.
+	if _, err := tx.Exec(`
+		ALTER TABLE software
+		ADD COLUMN first_seen_at timestamp NULL DEFAULT NULL
+	`); err != nil {
+		return errors.Wrap(err, "add first_seen_at")
+	}
+	if _, err := tx.Exec(`
+		ALTER TABLE software
+		MODIFY COLUMN first_seen_at timestamp NULL DEFAULT CURRENT_TIMESTAMP
+	`); err != nil {
+		return errors.Wrap(err, "set first_seen_at default")
+	}
+	return nil
+}
+
+func Down_20210720120514(tx *sql.Tx) error {
+	return nil
+}
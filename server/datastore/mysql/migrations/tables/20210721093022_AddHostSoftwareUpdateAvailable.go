@@ -0,0 +1,26 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210721093022, Down_20210721093022)
+}
+
+func Up_20210721093022(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE host_software
+		ADD COLUMN update_available tinyint(1) NOT NULL DEFAULT 0
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add update_available")
+	}
+	return nil
+}
+
+func Down_20210721093022(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,31 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210722143051, Down_20210722143051)
+}
+
+func Up_20210722143051(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS software_categories (
+			software_id bigint unsigned NOT NULL,
+			category varchar(255) NOT NULL,
+			PRIMARY KEY (software_id, category),
+			KEY idx_software_categories_category (category),
+			FOREIGN KEY (software_id) REFERENCES software (id) ON DELETE CASCADE
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table software_categories")
+	}
+	return nil
+}
+
+func Down_20210722143051(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,32 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210723094512, Down_20210723094512)
+}
+
+func Up_20210723094512(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS software_cve (
+			id int unsigned PRIMARY KEY AUTO_INCREMENT,
+			software_id bigint unsigned NOT NULL,
+			cve varchar(255) NOT NULL,
+			created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY unique_software_cve (software_id, cve),
+			FOREIGN KEY (software_id) REFERENCES software (id) ON DELETE CASCADE
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table software_cve")
+	}
+	return nil
+}
+
+func Down_20210723094512(tx *sql.Tx) error {
+	return nil
+}
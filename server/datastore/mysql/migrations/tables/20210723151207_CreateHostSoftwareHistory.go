@@ -0,0 +1,38 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210723151207, Down_20210723151207)
+}
+
+func Up_20210723151207(tx *sql.Tx) error {
+	// The software is stored by value so that the history is kept after the
+	// software is removed from all hosts and cleaned up.
+	sql := `
+		CREATE TABLE IF NOT EXISTS host_software_history (
+			id bigint unsigned PRIMARY KEY AUTO_INCREMENT,
+			host_id int unsigned NOT NULL,
+			software_id bigint unsigned NOT NULL,
+			name varchar(255) NOT NULL,
+			version varchar(255) NOT NULL,
+			source varchar(64) NOT NULL,
+			action varchar(16) NOT NULL,
+			created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			KEY idx_host_software_history_created_at (created_at),
+			KEY idx_host_software_history_host_id (host_id)
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table host_software_history")
+	}
+	return nil
+}
+
+func Down_20210723151207(tx *sql.Tx) error {
+	return nil
+}
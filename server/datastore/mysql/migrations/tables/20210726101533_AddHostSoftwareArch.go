@@ -0,0 +1,26 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210726101533, Down_20210726101533)
+}
+
+func Up_20210726101533(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE host_software
+		ADD COLUMN arch varchar(16) NOT NULL DEFAULT ''
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add arch")
+	}
+	return nil
+}
+
+func Down_20210726101533(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210727093014, Down_20210727093014)
+}
+
+func Up_20210727093014(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS software_notes (
+			software_id bigint unsigned NOT NULL PRIMARY KEY,
+			note text NOT NULL,
+			updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (software_id) REFERENCES software (id) ON DELETE CASCADE
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table software_notes")
+	}
+	return nil
+}
+
+func Down_20210727093014(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210727101546, Down_20210727101546)
+}
+
+func Up_20210727101546(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE software
+		ADD COLUMN checksum binary(16) NULL
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add checksum")
+	}
+
+	// The checksum is the MD5 of the name, version, source, bundle
+	// identifier, extension ID and browser separated by NUL bytes. The
+	// identifier columns are added by later migrations, empty for the
+	// existing software.
+	sql = `
+		UPDATE software
+		SET checksum = UNHEX(MD5(CONCAT(name, CHAR(0), version, CHAR(0), source, CHAR(0), '', CHAR(0), '', CHAR(0), '')))
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "backfill checksum")
+	}
+	return nil
+}
+
+func Down_20210727101546(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,32 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210728093541, Down_20210728093541)
+}
+
+func Up_20210728093541(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS software_host_counts (
+			software_id bigint unsigned NOT NULL PRIMARY KEY,
+			hosts_count int unsigned NOT NULL,
+			updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			INDEX idx_software_host_counts_hosts_count (hosts_count),
+			INDEX idx_software_host_counts_updated_at (updated_at),
+			FOREIGN KEY (software_id) REFERENCES software (id) ON DELETE CASCADE
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table software_host_counts")
+	}
+	return nil
+}
+
+func Down_20210728093541(tx *sql.Tx) error {
+	return nil
+}
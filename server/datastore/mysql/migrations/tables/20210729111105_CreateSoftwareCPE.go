@@ -0,0 +1,33 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210729111105, Down_20210729111105)
+}
+
+func Up_20210729111105(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS software_cpe (
+			id int unsigned PRIMARY KEY AUTO_INCREMENT,
+			software_id bigint unsigned NOT NULL,
+			cpe varchar(255) NOT NULL,
+			created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			UNIQUE KEY unique_software_cpe_software_id (software_id),
+			FOREIGN KEY (software_id) REFERENCES software (id) ON DELETE CASCADE
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table software_cpe")
+	}
+	return nil
+}
+
+func Down_20210729111105(tx *sql.Tx) error {
+	return nil
+}
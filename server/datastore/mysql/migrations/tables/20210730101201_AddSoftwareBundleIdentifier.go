@@ -0,0 +1,30 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210730101201, Down_20210730101201)
+}
+
+func Up_20210730101201(tx *sql.Tx) error {
+	// The bundle identifier is limited to 190 characters so that the unique
+	// key stays within the 3072 bytes InnoDB allows with utf8mb4.
+	sql := `
+		ALTER TABLE software
+		ADD COLUMN bundle_identifier varchar(190) NOT NULL DEFAULT '',
+		DROP INDEX idx_name_version,
+		ADD UNIQUE KEY idx_name_version_source_bundle (name, version, source, bundle_identifier)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add bundle_identifier")
+	}
+	return nil
+}
+
+func Down_20210730101201(tx *sql.Tx) error {
+	return nil
+}
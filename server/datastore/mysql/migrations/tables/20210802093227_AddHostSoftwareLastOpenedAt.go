@@ -0,0 +1,26 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210802093227, Down_20210802093227)
+}
+
+func Up_20210802093227(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE host_software
+		ADD COLUMN last_opened_at timestamp NULL
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add last_opened_at")
+	}
+	return nil
+}
+
+func Down_20210802093227(tx *sql.Tx) error {
+	return nil
+}
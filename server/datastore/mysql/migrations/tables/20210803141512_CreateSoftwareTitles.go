@@ -0,0 +1,35 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210803141512, Down_20210803141512)
+}
+
+func Up_20210803141512(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS software_titles (
+			id int unsigned PRIMARY KEY AUTO_INCREMENT,
+			name varchar(255) NOT NULL,
+			source varchar(64) NOT NULL,
+			hosts_count int unsigned NOT NULL,
+			versions_count int unsigned NOT NULL,
+			updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			UNIQUE KEY idx_software_titles_name_source (name, source),
+			INDEX idx_software_titles_hosts_count (hosts_count),
+			INDEX idx_software_titles_updated_at (updated_at)
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table software_titles")
+	}
+	return nil
+}
+
+func Down_20210803141512(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,35 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210804101815, Down_20210804101815)
+}
+
+func Up_20210804101815(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE app_configs
+		ADD COLUMN enable_software_inventory TINYINT(1) NOT NULL DEFAULT TRUE
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add app_configs.enable_software_inventory")
+	}
+
+	// NULL uses the setting of the app config.
+	sql = `
+		ALTER TABLE teams
+		ADD COLUMN enable_software_inventory TINYINT(1) NULL
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add teams.enable_software_inventory")
+	}
+	return nil
+}
+
+func Down_20210804101815(tx *sql.Tx) error {
+	return nil
+}
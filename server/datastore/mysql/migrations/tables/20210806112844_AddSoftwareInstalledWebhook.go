@@ -0,0 +1,28 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210806112844, Down_20210806112844)
+}
+
+func Up_20210806112844(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE app_configs
+		ADD COLUMN software_installed_webhook_enabled TINYINT(1) NOT NULL DEFAULT FALSE,
+		ADD COLUMN software_installed_webhook_url VARCHAR(255) NOT NULL DEFAULT '',
+		ADD COLUMN software_installed_webhook_watchlist JSON NULL
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add app_configs software installed webhook columns")
+	}
+	return nil
+}
+
+func Down_20210806112844(tx *sql.Tx) error {
+	return nil
+}
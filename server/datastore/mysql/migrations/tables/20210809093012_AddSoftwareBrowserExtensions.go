@@ -0,0 +1,33 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210809093012, Down_20210809093012)
+}
+
+func Up_20210809093012(tx *sql.Tx) error {
+	// The unique key can't include the extension ID and browser on top of
+	// the bundle identifier within the 3072 bytes InnoDB allows with utf8mb4,
+	// so it includes the MD5 of the three identifiers instead.
+	sql := `
+		ALTER TABLE software
+		ADD COLUMN extension_id varchar(255) NOT NULL DEFAULT '',
+		ADD COLUMN browser varchar(32) NOT NULL DEFAULT '',
+		ADD COLUMN identifiers_checksum binary(16) AS (UNHEX(MD5(CONCAT(bundle_identifier, CHAR(0), extension_id, CHAR(0), browser)))) STORED,
+		DROP INDEX idx_name_version_source_bundle,
+		ADD UNIQUE KEY idx_name_version_source_identifiers (name, version, source, identifiers_checksum)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add software extension_id and browser")
+	}
+	return nil
+}
+
+func Down_20210809093012(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210810141733, Down_20210810141733)
+}
+
+func Up_20210810141733(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE app_configs
+		ADD COLUMN software_normalization_rules JSON NULL
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add app_configs.software_normalization_rules")
+	}
+	return nil
+}
+
+func Down_20210810141733(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,34 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210811103652, Down_20210811103652)
+}
+
+func Up_20210811103652(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS software_oval_results (
+			id int unsigned PRIMARY KEY AUTO_INCREMENT,
+			software_id bigint unsigned NOT NULL,
+			cve varchar(255) NOT NULL,
+			definition_id varchar(255) NOT NULL,
+			vulnerable tinyint(1) NOT NULL,
+			updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			UNIQUE KEY unique_software_oval_result (software_id, cve),
+			FOREIGN KEY (software_id) REFERENCES software (id) ON DELETE CASCADE
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table software_oval_results")
+	}
+	return nil
+}
+
+func Down_20210811103652(tx *sql.Tx) error {
+	return nil
+}
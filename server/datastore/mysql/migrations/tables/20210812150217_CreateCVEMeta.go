@@ -0,0 +1,30 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210812150217, Down_20210812150217)
+}
+
+func Up_20210812150217(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS cve_meta (
+			cve varchar(255) PRIMARY KEY,
+			cvss_score double DEFAULT NULL,
+			epss_probability double DEFAULT NULL,
+			updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table cve_meta")
+	}
+	return nil
+}
+
+func Down_20210812150217(tx *sql.Tx) error {
+	return nil
+}
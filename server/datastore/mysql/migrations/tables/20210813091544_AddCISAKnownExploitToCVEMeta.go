@@ -0,0 +1,23 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210813091544, Down_20210813091544)
+}
+
+func Up_20210813091544(tx *sql.Tx) error {
+	sql := `ALTER TABLE cve_meta ADD COLUMN cisa_known_exploit tinyint(1) NOT NULL DEFAULT 0`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add cisa_known_exploit to cve_meta")
+	}
+	return nil
+}
+
+func Down_20210813091544(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,29 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210816104210, Down_20210816104210)
+}
+
+func Up_20210816104210(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE app_configs
+		ADD COLUMN vulnerabilities_webhook_enabled TINYINT(1) NOT NULL DEFAULT FALSE,
+		ADD COLUMN vulnerabilities_webhook_url VARCHAR(255) NOT NULL DEFAULT '',
+		ADD COLUMN vulnerabilities_webhook_host_batch_size INT NOT NULL DEFAULT 0,
+		ADD COLUMN vulnerabilities_webhook_min_cvss_score DOUBLE NOT NULL DEFAULT 0
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add app_configs vulnerabilities webhook columns")
+	}
+	return nil
+}
+
+func Down_20210816104210(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210817094302, Down_20210817094302)
+}
+
+func Up_20210817094302(tx *sql.Tx) error {
+	sql := `
+		ALTER TABLE app_configs
+		ADD COLUMN jira_integration_enabled TINYINT(1) NOT NULL DEFAULT FALSE,
+		ADD COLUMN jira_url VARCHAR(255) NOT NULL DEFAULT '',
+		ADD COLUMN jira_username VARCHAR(255) NOT NULL DEFAULT '',
+		ADD COLUMN jira_api_token VARCHAR(255) NOT NULL DEFAULT '',
+		ADD COLUMN jira_project_key VARCHAR(255) NOT NULL DEFAULT ''
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add app_configs jira integration columns")
+	}
+	return nil
+}
+
+func Down_20210817094302(tx *sql.Tx) error {
+	return nil
+}
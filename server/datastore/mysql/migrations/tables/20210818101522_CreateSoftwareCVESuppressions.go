@@ -0,0 +1,40 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210818101522, Down_20210818101522)
+}
+
+func Up_20210818101522(tx *sql.Tx) error {
+	// The software is stored by value and isn't a foreign key so that the
+	// suppressions remain auditable, the software with suppressions isn't
+	// cleaned up either.
+	sql := `
+		CREATE TABLE IF NOT EXISTS software_cve_suppressions (
+			id int unsigned PRIMARY KEY AUTO_INCREMENT,
+			software_id bigint unsigned NOT NULL,
+			software_name varchar(255) NOT NULL,
+			software_version varchar(255) NOT NULL,
+			software_source varchar(64) NOT NULL,
+			cve varchar(255) NOT NULL,
+			reason text NOT NULL,
+			author_id int unsigned DEFAULT NULL,
+			created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY unique_software_cve_suppression (software_id, cve),
+			FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE SET NULL
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table software_cve_suppressions")
+	}
+	return nil
+}
+
+func Down_20210818101522(tx *sql.Tx) error {
+	return nil
+}
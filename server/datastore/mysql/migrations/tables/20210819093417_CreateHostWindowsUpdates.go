@@ -0,0 +1,30 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210819093417, Down_20210819093417)
+}
+
+func Up_20210819093417(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS host_windows_updates (
+			host_id int unsigned NOT NULL,
+			kb_id varchar(32) NOT NULL,
+			PRIMARY KEY (host_id, kb_id),
+			FOREIGN KEY (host_id) REFERENCES hosts (id) ON DELETE CASCADE ON UPDATE CASCADE
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table host_windows_updates")
+	}
+	return nil
+}
+
+func Down_20210819093417(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,33 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210819104521, Down_20210819104521)
+}
+
+func Up_20210819104521(tx *sql.Tx) error {
+	sql := `
+		CREATE TABLE IF NOT EXISTS operating_system_vulnerabilities (
+			id bigint unsigned PRIMARY KEY AUTO_INCREMENT,
+			host_id int unsigned NOT NULL,
+			cve varchar(255) NOT NULL,
+			resolved_in varchar(32) NOT NULL,
+			created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY unique_host_cve (host_id, cve),
+			FOREIGN KEY (host_id) REFERENCES hosts (id) ON DELETE CASCADE ON UPDATE CASCADE
+		)
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "create table operating_system_vulnerabilities")
+	}
+	return nil
+}
+
+func Down_20210819104521(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20210820110512, Down_20210820110512)
+}
+
+func Up_20210820110512(tx *sql.Tx) error {
+	// The epoch and release of RPM packages are used to evaluate the OVAL
+	// definitions, they are not part of the software identity. The release
+	// column is prefixed as RELEASE is a reserved word.
+	sql := `
+		ALTER TABLE software
+		ADD COLUMN epoch INT UNSIGNED NULL,
+		ADD COLUMN package_release VARCHAR(64) NOT NULL DEFAULT ''
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return errors.Wrap(err, "add software epoch and release columns")
+	}
+	return nil
+}
+
+func Down_20210820110512(tx *sql.Tx) error {
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/VividCortex/mysqlerr"
@@ -44,6 +45,9 @@ type Datastore struct {
 	logger log.Logger
 	clock  clock.Clock
 	config config.MysqlConfig
+
+	stmtsMu sync.Mutex
+	stmts   map[string]*sqlx.Stmt
 }
 
 type txFn func(*sqlx.Tx) error
@@ -271,6 +275,11 @@ func (d *Datastore) MigrationStatus() (fleet.MigrationStatus, error) {
 	}
 }
 
+// DBStats returns the statistics of the connections to the database.
+func (d *Datastore) DBStats() sql.DBStats {
+	return d.db.Stats()
+}
+
 // Drop removes database
 func (d *Datastore) Drop() error {
 	tables := []struct {
@@ -316,8 +325,38 @@ func (d *Datastore) HealthCheck() error {
 	return err
 }
 
+// preparex returns a statement prepared against the connection pool for the
+// query, preparing it on first use. database/sql prepares the statement
+// lazily on each connection it runs on and reuses it afterwards, so repeated
+// queries are only parsed once per connection. The statements are closed by
+// Close.
+func (d *Datastore) preparex(query string) (*sqlx.Stmt, error) {
+	d.stmtsMu.Lock()
+	defer d.stmtsMu.Unlock()
+
+	if stmt, ok := d.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := d.db.Preparex(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "prepare statement")
+	}
+	if d.stmts == nil {
+		d.stmts = make(map[string]*sqlx.Stmt)
+	}
+	d.stmts[query] = stmt
+	return stmt, nil
+}
+
 // Close frees resources associated with underlying mysql connection
 func (d *Datastore) Close() error {
+	d.stmtsMu.Lock()
+	for query, stmt := range d.stmts {
+		stmt.Close()
+		delete(d.stmts, query)
+	}
+	d.stmtsMu.Unlock()
+
 	return d.db.Close()
 }
 
@@ -326,15 +365,7 @@ func sanitizeColumn(col string) string {
 }
 
 func appendListOptionsToSQL(sql string, opts fleet.ListOptions) string {
-	if opts.OrderKey != "" {
-		direction := "ASC"
-		if opts.OrderDirection == fleet.OrderDescending {
-			direction = "DESC"
-		}
-		orderKey := sanitizeColumn(opts.OrderKey)
-
-		sql = fmt.Sprintf("%s ORDER BY %s %s", sql, orderKey, direction)
-	}
+	sql = appendListOrderToSQL(sql, opts)
 	// REVIEW: If caller doesn't supply a limit apply a default limit of 1000
 	// to insure that an unbounded query with many results doesn't consume too
 	// much memory or hang
@@ -353,6 +384,21 @@ func appendListOptionsToSQL(sql string, opts fleet.ListOptions) string {
 	return sql
 }
 
+// appendListOrderToSQL appends only the ORDER BY clause of the list options,
+// for the queries that are paginated after post-processing the rows.
+func appendListOrderToSQL(sql string, opts fleet.ListOptions) string {
+	if opts.OrderKey != "" {
+		direction := "ASC"
+		if opts.OrderDirection == fleet.OrderDescending {
+			direction = "DESC"
+		}
+		orderKey := sanitizeColumn(opts.OrderKey)
+
+		sql = fmt.Sprintf("%s ORDER BY %s %s", sql, orderKey, direction)
+	}
+	return sql
+}
+
 // whereFilterHostsByTeams returns the appropriate condition to use in the WHERE
 // clause to render only the appropriate teams.
 //
@@ -101,7 +101,36 @@ func applyPackSpec(tx *sqlx.Tx, spec *fleet.PackSpec) error {
 			return errors.Wrap(err, "adding label to pack")
 		}
 	}
+	for _, t := range spec.Targets.Teams {
+		var teamID uint
+		query = "SELECT id FROM teams WHERE name = ?"
+		if err := tx.Get(&teamID, query, t); err != nil {
+			if err == sql.ErrNoRows {
+				return errors.Errorf("cannot target unknown team '%s'", t)
+			}
+			return errors.Wrap(err, "getting team ID")
+		}
+		query = `
+			INSERT INTO pack_targets (pack_id, type, target_id)
+			VALUES (?, ?, ?)
+		`
+		if _, err := tx.Exec(query, packID, fleet.TargetTeam, teamID); err != nil {
+			return errors.Wrap(err, "adding team to pack")
+		}
+	}
+
+	return nil
+}
 
+func loadPackSpecTeamTargets(tx *sqlx.Tx, spec *fleet.PackSpec) error {
+	query := `
+SELECT t.name
+FROM teams t JOIN pack_targets pt
+WHERE pack_id = ? AND pt.type = ? AND pt.target_id = t.id
+`
+	if err := tx.Select(&spec.Targets.Teams, query, spec.ID, fleet.TargetTeam); err != nil {
+		return errors.Wrap(err, "get pack team targets")
+	}
 	return nil
 }
 
@@ -123,6 +152,9 @@ WHERE pack_id = ? AND pt.type = ? AND pt.target_id = l.id
 			if err := tx.Select(&spec.Targets.Labels, query, spec.ID, fleet.TargetLabel); err != nil {
 				return errors.Wrap(err, "get pack targets")
 			}
+			if err := loadPackSpecTeamTargets(tx, spec); err != nil {
+				return err
+			}
 		}
 
 		// Load queries
@@ -175,6 +207,9 @@ WHERE pack_id = ? AND pt.type = ? AND pt.target_id = l.id
 		if err := tx.Select(&spec.Targets.Labels, query, spec.ID, fleet.TargetLabel); err != nil {
 			return errors.Wrap(err, "get pack targets")
 		}
+		if err := loadPackSpecTeamTargets(tx, spec); err != nil {
+			return err
+		}
 
 		// Load queries
 		query = `
@@ -1,8 +1,13 @@
 package mysql
 
 import (
+	"bytes"
+	"crypto/md5"
+	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/jmoiron/sqlx"
@@ -13,6 +18,17 @@ const (
 	maxSoftwareNameLen    = 255
 	maxSoftwareVersionLen = 255
 	maxSoftwareSourceLen  = 64
+	// maxSoftwareBundleIdentifierLen keeps the software unique key within
+	// the InnoDB key size limit.
+	maxSoftwareBundleIdentifierLen = 190
+	maxSoftwareExtensionIDLen      = 255
+	maxSoftwareBrowserLen          = 32
+	maxSoftwareReleaseLen          = 64
+
+	// softwareBatchSize is the number of software rows resolved or inserted
+	// per statement, keeping the number of placeholders well below MySQL's
+	// limit.
+	softwareBatchSize = 500
 )
 
 func truncateString(str string, length int) string {
@@ -23,32 +39,50 @@ func truncateString(str string, length int) string {
 }
 
 func softwareToUniqueString(s fleet.Software) string {
-	return strings.Join([]string{s.Name, s.Version, s.Source}, "\u0000")
+	return strings.Join([]string{s.Name, s.Version, s.Source, s.BundleIdentifier, s.ExtensionID, s.Browser}, "\u0000")
 }
 
 func uniqueStringToSoftware(s string) fleet.Software {
 	parts := strings.Split(s, "\u0000")
 	return fleet.Software{
-		Name:    truncateString(parts[0], maxSoftwareNameLen),
-		Version: truncateString(parts[1], maxSoftwareVersionLen),
-		Source:  truncateString(parts[2], maxSoftwareSourceLen),
+		Name:             truncateString(parts[0], maxSoftwareNameLen),
+		Version:          truncateString(parts[1], maxSoftwareVersionLen),
+		Source:           truncateString(parts[2], maxSoftwareSourceLen),
+		BundleIdentifier: truncateString(parts[3], maxSoftwareBundleIdentifierLen),
+		ExtensionID:      truncateString(parts[4], maxSoftwareExtensionIDLen),
+		Browser:          truncateString(parts[5], maxSoftwareBrowserLen),
 	}
 }
 
-func softwareSliceToSet(softwares []fleet.Software) map[string]bool {
-	result := make(map[string]bool)
+func softwareSliceToMap(softwares []fleet.Software) map[string]fleet.Software {
+	result := make(map[string]fleet.Software)
 	for _, s := range softwares {
-		result[softwareToUniqueString(s)] = true
+		result[softwareToUniqueString(s)] = s
 	}
 	return result
 }
 
-func softwareSliceToIdMap(softwareSlice []fleet.Software) map[string]uint {
-	result := make(map[string]uint)
-	for _, s := range softwareSlice {
-		result[softwareToUniqueString(s)] = s.ID
+func stringPtrEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
 	}
-	return result
+	return *a == *b
+}
+
+func timePtrEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// hostSoftwareDetailsChanged returns true if the host specific details stored
+// in host_software differ between the two versions of the same software.
+func hostSoftwareDetailsChanged(current, incoming fleet.Software) bool {
+	return !stringPtrEqual(current.SignatureStatus, incoming.SignatureStatus) ||
+		current.Managed != incoming.Managed ||
+		current.Arch != incoming.Arch ||
+		!timePtrEqual(current.LastOpenedAt, incoming.LastOpenedAt)
 }
 
 func (d *Datastore) SaveHostSoftware(host *fleet.Host) error {
@@ -56,10 +90,31 @@ func (d *Datastore) SaveHostSoftware(host *fleet.Host) error {
 		return nil
 	}
 
+	enabled, err := d.SoftwareInventoryEnabled(host.TeamID)
+	if err != nil {
+		return errors.Wrap(err, "save host software")
+	}
+	if !enabled {
+		return nil
+	}
+
+	var installed []fleet.Software
 	if err := d.withRetryTxx(func(tx *sqlx.Tx) error {
+		installed = nil
 		if len(host.HostSoftware.Software) == 0 {
+			sql := `
+				INSERT INTO host_software_history (host_id, software_id, name, version, source, action)
+				SELECT hs.host_id, hs.software_id, s.name, s.version, s.source, ?
+				FROM host_software hs
+				JOIN software s ON s.id = hs.software_id
+				WHERE hs.host_id = ?
+			`
+			if _, err := tx.Exec(sql, fleet.SoftwareHistoryRemoved, host.ID); err != nil {
+				return errors.Wrap(err, "record host software history")
+			}
+
 			// Clear join table for this host
-			sql := "DELETE FROM host_software WHERE host_id = ?"
+			sql = "DELETE FROM host_software WHERE host_id = ?"
 			if _, err := tx.Exec(sql, host.ID); err != nil {
 				return errors.Wrap(err, "clear join table entries")
 			}
@@ -67,16 +122,15 @@ func (d *Datastore) SaveHostSoftware(host *fleet.Host) error {
 			return nil
 		}
 
-		if err := d.applyChangesForNewSoftware(tx, host); err != nil {
-			return err
-		}
-
-		return nil
+		var err error
+		installed, err = d.applyChangesForNewSoftware(tx, host.ID, host.Software)
+		return err
 	}); err != nil {
 		return errors.Wrap(err, "save host software")
 	}
 
 	host.HostSoftware.Modified = false
+	host.HostSoftware.Installed = installed
 	return nil
 }
 
@@ -85,12 +139,10 @@ func nothingChanged(current []fleet.Software, incoming []fleet.Software) bool {
 		return false
 	}
 
-	currentBitmap := make(map[string]bool)
-	for _, s := range current {
-		currentBitmap[softwareToUniqueString(s)] = true
-	}
+	currentMap := softwareSliceToMap(current)
 	for _, s := range incoming {
-		if _, ok := currentBitmap[softwareToUniqueString(s)]; !ok {
+		c, ok := currentMap[softwareToUniqueString(s)]
+		if !ok || hostSoftwareDetailsChanged(c, s) {
 			return false
 		}
 	}
@@ -98,43 +150,71 @@ func nothingChanged(current []fleet.Software, incoming []fleet.Software) bool {
 	return true
 }
 
-func (d *Datastore) applyChangesForNewSoftware(tx *sqlx.Tx, host *fleet.Host) error {
-	storedCurrentSoftware, err := d.hostSoftwareFromHostID(tx, host.ID)
+// softwareNormalizationRules returns the software normalization rules of the
+// app config.
+func softwareNormalizationRules(tx *sqlx.Tx) (fleet.SoftwareNormalizationRules, error) {
+	var rules []fleet.SoftwareNormalizationRules
+	if err := tx.Select(&rules, `SELECT software_normalization_rules FROM app_configs LIMIT 1`); err != nil {
+		return nil, errors.Wrap(err, "select software normalization rules")
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return rules[0], nil
+}
+
+// applyChangesForNewSoftware stores the changes between the stored and the
+// reported software of the host, once normalized, and returns the newly
+// installed software.
+func (d *Datastore) applyChangesForNewSoftware(tx *sqlx.Tx, hostID uint, software []fleet.Software) ([]fleet.Software, error) {
+	storedCurrentSoftware, err := d.hostSoftwareFromHostID(tx, hostID)
 	if err != nil {
-		return errors.Wrap(err, "loading current software for host")
+		return nil, errors.Wrap(err, "loading current software for host")
 	}
 
-	if nothingChanged(storedCurrentSoftware, host.Software) {
-		return nil
+	rules, err := softwareNormalizationRules(tx)
+	if err != nil {
+		return nil, err
 	}
+	reported := rules.Normalize(software)
 
-	current := softwareSliceToIdMap(storedCurrentSoftware)
-	incoming := softwareSliceToSet(host.Software)
+	if nothingChanged(storedCurrentSoftware, reported) {
+		return nil, nil
+	}
 
-	if err = d.deleteUninstalledHostSoftware(tx, host.ID, current, incoming); err != nil {
-		return err
+	current := softwareSliceToMap(storedCurrentSoftware)
+	incoming := softwareSliceToMap(reported)
+
+	if err = d.deleteUninstalledHostSoftware(tx, hostID, current, incoming); err != nil {
+		return nil, err
 	}
 
-	if err = d.insertNewInstalledHostSoftware(tx, host.ID, current, incoming); err != nil {
-		return err
+	installed, err := d.insertNewInstalledHostSoftware(tx, hostID, current, incoming)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err = d.updateModifiedHostSoftware(tx, hostID, current, incoming); err != nil {
+		return nil, err
+	}
+
+	return installed, nil
 }
 
 func (d *Datastore) deleteUninstalledHostSoftware(
 	tx *sqlx.Tx,
 	hostID uint,
-	currentIdmap map[string]uint,
-	incomingBitmap map[string]bool,
+	currentMap map[string]fleet.Software,
+	incomingMap map[string]fleet.Software,
 ) error {
 	var deletesHostSoftware []interface{}
 	deletesHostSoftware = append(deletesHostSoftware, hostID)
 
-	for currentKey := range currentIdmap {
-		if _, ok := incomingBitmap[currentKey]; !ok {
-			deletesHostSoftware = append(deletesHostSoftware, currentIdmap[currentKey])
-			// TODO: delete from software if no host has it
+	var deleted []fleet.Software
+	for currentKey, curSoftware := range currentMap {
+		if _, ok := incomingMap[currentKey]; !ok {
+			deletesHostSoftware = append(deletesHostSoftware, curSoftware.ID)
+			deleted = append(deleted, curSoftware)
 		}
 	}
 	if len(deletesHostSoftware) <= 1 {
@@ -148,26 +228,66 @@ func (d *Datastore) deleteUninstalledHostSoftware(
 		return errors.Wrap(err, "delete host software")
 	}
 
+	return recordHostSoftwareHistory(tx, hostID, fleet.SoftwareHistoryRemoved, deleted)
+}
+
+// recordHostSoftwareHistory records that the software, with its ID set, was
+// installed on or removed from the host. The software is recorded by value so
+// that the history outlives the cleanup of orphaned software.
+func recordHostSoftwareHistory(tx *sqlx.Tx, hostID uint, action string, software []fleet.Software) error {
+	if len(software) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(software)*6)
+	for _, s := range software {
+		args = append(args, hostID, s.ID, s.Name, s.Version, s.Source, action)
+	}
+	sql := fmt.Sprintf(
+		`INSERT INTO host_software_history (host_id, software_id, name, version, source, action) VALUES %s`,
+		strings.TrimSuffix(strings.Repeat("(?,?,?,?,?,?),", len(software)), ","),
+	)
+	if _, err := tx.Exec(sql, args...); err != nil {
+		return errors.Wrap(err, "record host software history")
+	}
 	return nil
 }
 
+const (
+	selectSoftwareIDStmt = `SELECT id FROM software WHERE name = ? and version = ? and source = ? and bundle_identifier = ? and extension_id = ? and browser = ?`
+	insertSoftwareStmt   = `INSERT IGNORE INTO software (name, version, source, bundle_identifier, extension_id, browser, epoch, package_release, checksum) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+)
+
+// softwareChecksum returns the checksum stored with the software, the MD5 of
+// its name, version, source, bundle identifier, extension ID and browser
+// separated by NUL bytes.
+func softwareChecksum(s fleet.Software) []byte {
+	sum := md5.Sum([]byte(strings.Join([]string{
+		s.Name, s.Version, s.Source, s.BundleIdentifier, s.ExtensionID, s.Browser,
+	}, "\x00")))
+	return sum[:]
+}
+
+// getOrGenerateSoftwareId returns the ID of the software, inserting it if it
+// does not exist yet. The lookup and insert use cached prepared statements as
+// this runs for every new software reported by every host.
 func (d *Datastore) getOrGenerateSoftwareId(tx *sqlx.Tx, s fleet.Software) (uint, error) {
+	selectStmt, err := d.preparex(selectSoftwareIDStmt)
+	if err != nil {
+		return 0, err
+	}
 	var existingId []int64
-	if err := tx.Select(
-		&existingId,
-		`SELECT id FROM software WHERE name = ? and version = ? and source = ?`,
-		s.Name, s.Version, s.Source,
-	); err != nil {
+	if err := tx.Stmtx(selectStmt).Select(&existingId, s.Name, s.Version, s.Source, s.BundleIdentifier, s.ExtensionID, s.Browser); err != nil {
 		return 0, err
 	}
 	if len(existingId) > 0 {
 		return uint(existingId[0]), nil
 	}
 
-	result, err := tx.Exec(
-		`INSERT IGNORE INTO software (name, version, source) VALUES (?, ?, ?)`,
-		s.Name, s.Version, s.Source,
-	)
+	insertStmt, err := d.preparex(insertSoftwareStmt)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.Stmtx(insertStmt).Exec(s.Name, s.Version, s.Source, s.BundleIdentifier, s.ExtensionID, s.Browser, s.Epoch, s.Release, softwareChecksum(s))
 	if err != nil {
 		return 0, errors.Wrap(err, "insert software")
 	}
@@ -175,33 +295,204 @@ func (d *Datastore) getOrGenerateSoftwareId(tx *sqlx.Tx, s fleet.Software) (uint
 	if err != nil {
 		return 0, errors.Wrap(err, "last id from software")
 	}
+	if id == 0 {
+		// The insert was ignored because the software was inserted
+		// concurrently since the lookup, read its ID.
+		if err := tx.Stmtx(selectStmt).Select(&existingId, s.Name, s.Version, s.Source, s.BundleIdentifier, s.ExtensionID, s.Browser); err != nil {
+			return 0, err
+		}
+		if len(existingId) == 0 {
+			return 0, errors.New("software insert ignored but not found")
+		}
+		id = existingId[0]
+	}
 	return uint(id), nil
 }
 
+// getOrGenerateSoftwareIDs returns the IDs of the provided software keyed by
+// softwareToUniqueString, inserting the software that does not exist yet. The
+// software is expected to be already truncated to the column sizes.
+func (d *Datastore) getOrGenerateSoftwareIDs(tx *sqlx.Tx, software []fleet.Software) (map[string]uint, error) {
+	ids := make(map[string]uint, len(software))
+	for start := 0; start < len(software); start += softwareBatchSize {
+		end := start + softwareBatchSize
+		if end > len(software) {
+			end = len(software)
+		}
+		batch := software[start:end]
+
+		args := make([]interface{}, 0, len(batch)*6)
+		insertArgs := make([]interface{}, 0, len(batch)*9)
+		for _, s := range batch {
+			args = append(args, s.Name, s.Version, s.Source, s.BundleIdentifier, s.ExtensionID, s.Browser)
+			insertArgs = append(insertArgs, s.Name, s.Version, s.Source, s.BundleIdentifier, s.ExtensionID, s.Browser, s.Epoch, s.Release, softwareChecksum(s))
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("(?,?,?,?,?,?),", len(batch)), ",")
+
+		sql := fmt.Sprintf(
+			`INSERT IGNORE INTO software (name, version, source, bundle_identifier, extension_id, browser, epoch, package_release, checksum) VALUES %s`,
+			strings.TrimSuffix(strings.Repeat("(?,?,?,?,?,?,?,?,?),", len(batch)), ","),
+		)
+		if _, err := tx.Exec(sql, insertArgs...); err != nil {
+			return nil, errors.Wrap(err, "insert software")
+		}
+
+		var stored []fleet.Software
+		sql = fmt.Sprintf(
+			`SELECT id, name, version, source, bundle_identifier, extension_id, browser FROM software WHERE (name, version, source, bundle_identifier, extension_id, browser) IN (%s)`,
+			placeholders,
+		)
+		if err := tx.Select(&stored, sql, args...); err != nil {
+			return nil, errors.Wrap(err, "select software ids")
+		}
+		for _, s := range stored {
+			ids[softwareToUniqueString(s)] = s.ID
+		}
+
+		// The column collation may consider values equal that differ
+		// byte-wise (eg. by case), in which case the stored row does not
+		// match the key exactly. Resolve those individually.
+		for _, s := range batch {
+			key := softwareToUniqueString(s)
+			if _, ok := ids[key]; ok {
+				continue
+			}
+			id, err := d.getOrGenerateSoftwareId(tx, s)
+			if err != nil {
+				return nil, err
+			}
+			ids[key] = id
+		}
+	}
+
+	return ids, nil
+}
+
+// insertNewInstalledHostSoftware inserts the software reported by the host
+// that is not stored yet. The software IDs are resolved and the host software
+// inserted with a few multi-row statements rather than per software, as new
+// hosts report thousands of packages. The inserted software is returned with
+// its ID set.
 func (d *Datastore) insertNewInstalledHostSoftware(
 	tx *sqlx.Tx,
 	hostID uint,
-	currentIdmap map[string]uint,
-	incomingBitmap map[string]bool,
-) error {
-	var insertsHostSoftware []interface{}
-	for s := range incomingBitmap {
-		if _, ok := currentIdmap[s]; !ok {
-			id, err := d.getOrGenerateSoftwareId(tx, uniqueStringToSoftware(s))
-			if err != nil {
-				return err
-			}
-			insertsHostSoftware = append(insertsHostSoftware, hostID, id)
+	currentMap map[string]fleet.Software,
+	incomingMap map[string]fleet.Software,
+) ([]fleet.Software, error) {
+	// Truncate and dedupe the new software, sorted so that concurrent
+	// inserts lock the software rows in the same order.
+	newSoftware := make(map[string]fleet.Software)
+	for key, incomingSoftware := range incomingMap {
+		if _, ok := currentMap[key]; ok {
+			continue
+		}
+		truncated := uniqueStringToSoftware(key)
+		truncated.SignatureStatus = incomingSoftware.SignatureStatus
+		truncated.Managed = incomingSoftware.Managed
+		truncated.Arch = incomingSoftware.Arch
+		truncated.Epoch = incomingSoftware.Epoch
+		truncated.Release = truncateString(incomingSoftware.Release, maxSoftwareReleaseLen)
+		truncated.LastOpenedAt = incomingSoftware.LastOpenedAt
+		newSoftware[softwareToUniqueString(truncated)] = truncated
+	}
+	if len(newSoftware) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(newSoftware))
+	for key := range newSoftware {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	software := make([]fleet.Software, 0, len(keys))
+	for _, key := range keys {
+		software = append(software, newSoftware[key])
+	}
+
+	ids, err := d.getOrGenerateSoftwareIDs(tx, software)
+	if err != nil {
+		return nil, err
+	}
+
+	inserted := make([]fleet.Software, 0, len(software))
+	for start := 0; start < len(software); start += softwareBatchSize {
+		end := start + softwareBatchSize
+		if end > len(software) {
+			end = len(software)
 		}
+		batch := software[start:end]
+
+		args := make([]interface{}, 0, len(batch)*6)
+		for _, s := range batch {
+			id := ids[softwareToUniqueString(s)]
+			args = append(args, hostID, id, s.SignatureStatus, s.Managed, s.Arch, s.LastOpenedAt)
+			s.ID = id
+			inserted = append(inserted, s)
+		}
+		sql := fmt.Sprintf(
+			`INSERT INTO host_software (host_id, software_id, signature_status, managed, arch, last_opened_at) VALUES %s`,
+			strings.TrimSuffix(strings.Repeat("(?,?,?,?,?,?),", len(batch)), ","),
+		)
+		if _, err := tx.Exec(sql, args...); err != nil {
+			return nil, errors.Wrap(err, "insert host software")
+		}
+	}
+
+	if err := recordHostSoftwareHistory(tx, hostID, fleet.SoftwareHistoryInstalled, inserted); err != nil {
+		return nil, err
 	}
-	if len(insertsHostSoftware) > 0 {
-		values := strings.TrimSuffix(strings.Repeat("(?,?),", len(insertsHostSoftware)/2), ",")
-		sql := fmt.Sprintf(`INSERT INTO host_software (host_id, software_id) VALUES %s`, values)
-		if _, err := tx.Exec(sql, insertsHostSoftware...); err != nil {
-			return errors.Wrap(err, "insert host software")
+	return inserted, nil
+}
+
+// updateModifiedHostSoftware updates the host specific details of software
+// that is both currently stored and still reported by the host.
+func (d *Datastore) updateModifiedHostSoftware(
+	tx *sqlx.Tx,
+	hostID uint,
+	currentMap map[string]fleet.Software,
+	incomingMap map[string]fleet.Software,
+) error {
+	for key, curSoftware := range currentMap {
+		incomingSoftware, ok := incomingMap[key]
+		if !ok || !hostSoftwareDetailsChanged(curSoftware, incomingSoftware) {
+			continue
 		}
+		sql := `UPDATE host_software SET signature_status = ?, managed = ?, arch = ?, last_opened_at = ? WHERE host_id = ? AND software_id = ?`
+		if _, err := tx.Exec(
+			sql,
+			incomingSoftware.SignatureStatus, incomingSoftware.Managed, incomingSoftware.Arch, incomingSoftware.LastOpenedAt,
+			hostID, curSoftware.ID,
+		); err != nil {
+			return errors.Wrap(err, "update host software")
+		}
+	}
+
+	return nil
+}
+
+// ReplaceAllHostSoftware replaces the software of the host with the provided
+// software in a single transaction. The installed and removed software are
+// computed within the transaction and recorded like SaveHostSoftware does, so
+// that the history and the software installed webhook see the snapshots too.
+func (d *Datastore) ReplaceAllHostSoftware(host *fleet.Host, software []fleet.Software) error {
+	enabled, err := d.SoftwareInventoryEnabled(host.TeamID)
+	if err != nil {
+		return errors.Wrap(err, "replace host software")
+	}
+	if !enabled {
+		return nil
+	}
+
+	var installed []fleet.Software
+	if err := d.withRetryTxx(func(tx *sqlx.Tx) error {
+		var err error
+		installed, err = d.applyChangesForNewSoftware(tx, host.ID, software)
+		return err
+	}); err != nil {
+		return errors.Wrap(err, "replace host software")
 	}
 
+	host.HostSoftware.Modified = false
+	host.HostSoftware.Installed = installed
 	return nil
 }
 
@@ -211,9 +502,10 @@ func (d *Datastore) hostSoftwareFromHostID(tx *sqlx.Tx, id uint) ([]fleet.Softwa
 		selectFunc = tx.Select
 	}
 	sql := `
-		SELECT * FROM software
-		WHERE id IN
-			(SELECT software_id FROM host_software WHERE host_id = ?)
+		SELECT s.id, s.name, s.version, s.source, s.bundle_identifier, s.extension_id, s.browser, hs.signature_status, hs.update_available, hs.managed, hs.arch, hs.last_opened_at
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		WHERE hs.host_id = ?
 	`
 	var result []fleet.Software
 	if err := selectFunc(&result, sql, id); err != nil {
@@ -222,6 +514,88 @@ func (d *Datastore) hostSoftwareFromHostID(tx *sqlx.Tx, id uint) ([]fleet.Softwa
 	return result, nil
 }
 
+func (d *Datastore) ListHostSoftware(hostID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	sql := `
+		SELECT s.id, s.name, s.version, s.source, s.bundle_identifier, s.extension_id, s.browser, hs.signature_status, hs.update_available, hs.managed, hs.arch, hs.last_opened_at
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		WHERE hs.host_id = ?
+	`
+	args := []interface{}{hostID}
+	if opt.Managed != nil {
+		sql += ` AND hs.managed = ?`
+		args = append(args, *opt.Managed)
+	}
+	if opt.Browser != nil {
+		sql += ` AND s.browser = ?`
+		args = append(args, *opt.Browser)
+	}
+	sql = appendSoftwareListOptionsToSQL(sql, opt)
+
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "list host software")
+	}
+	result = collapseSoftwareList(result, opt)
+	return result, nil
+}
+
+// appendSoftwareListOptionsToSQL appends the list options to the software
+// query. When collapsing sources, only the order is appended: the rows are
+// paginated by collapseSoftwareList once collapsed, so that a page holds the
+// requested number of software names.
+func appendSoftwareListOptionsToSQL(sql string, opt fleet.SoftwareListOptions) string {
+	if opt.CollapseSources {
+		return appendListOrderToSQL(sql, opt.ListOptions)
+	}
+	return appendListOptionsToSQL(sql, opt.ListOptions)
+}
+
+// collapseSoftwareList collapses the sources of the software selected with
+// appendSoftwareListOptionsToSQL and returns the requested page, with the
+// same default page size as appendListOptionsToSQL. The software is returned
+// as is when not collapsing sources.
+func collapseSoftwareList(software []fleet.Software, opt fleet.SoftwareListOptions) []fleet.Software {
+	if !opt.CollapseSources {
+		return software
+	}
+	software = collapseSoftwareSources(software)
+
+	perPage := int(opt.PerPage)
+	if perPage == 0 {
+		perPage = defaultSelectLimit
+	}
+	offset := perPage * int(opt.Page)
+	if offset >= len(software) {
+		return nil
+	}
+	end := offset + perPage
+	if end > len(software) {
+		end = len(software)
+	}
+	return software[offset:end]
+}
+
+// collapseSoftwareSources keeps a single entry per software name, from the
+// source with the highest priority. The order of the kept entries is
+// preserved.
+func collapseSoftwareSources(software []fleet.Software) []fleet.Software {
+	kept := make(map[string]int, len(software))
+	result := software[:0:0]
+	for _, s := range software {
+		i, ok := kept[s.Name]
+		if !ok {
+			kept[s.Name] = len(result)
+			result = append(result, s)
+			continue
+		}
+		if fleet.SoftwareSourcePriority(s.Source) > fleet.SoftwareSourcePriority(result[i].Source) {
+			result[i] = s
+		}
+	}
+	return result
+}
+
 func (d *Datastore) LoadHostSoftware(host *fleet.Host) error {
 	host.HostSoftware = fleet.HostSoftware{Modified: false}
 	software, err := d.hostSoftwareFromHostID(nil, host.ID)
@@ -231,3 +605,1424 @@ func (d *Datastore) LoadHostSoftware(host *fleet.Host) error {
 	host.Software = software
 	return nil
 }
+
+func (d *Datastore) LoadHostSoftwareGrouped(host *fleet.Host) (map[string][]fleet.Software, error) {
+	if err := d.LoadHostSoftware(host); err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[string][]fleet.Software)
+	for _, s := range host.Software {
+		grouped[s.Source] = append(grouped[s.Source], s)
+	}
+	for _, group := range grouped {
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].Name != group[j].Name {
+				return group[i].Name < group[j].Name
+			}
+			return group[i].Version < group[j].Version
+		})
+	}
+	return grouped, nil
+}
+
+func (d *Datastore) SimilarHostsBySoftware(hostID uint, limit int) ([]fleet.HostSoftwareSimilarity, error) {
+	sql := `
+		SELECT hs.host_id, h.hostname, COUNT(*) AS shared_software_count
+		FROM host_software hs
+		JOIN host_software ref ON ref.software_id = hs.software_id AND ref.host_id = ?
+		JOIN hosts h ON h.id = hs.host_id
+		WHERE hs.host_id != ?
+		GROUP BY hs.host_id, h.hostname
+		ORDER BY shared_software_count DESC, hs.host_id ASC
+		LIMIT ?
+	`
+	var result []fleet.HostSoftwareSimilarity
+	if err := d.db.Select(&result, sql, hostID, hostID, limit); err != nil {
+		return nil, errors.Wrap(err, "select similar hosts by software")
+	}
+	return result, nil
+}
+
+func (d *Datastore) SoftwareCatalogStats() (fleet.SoftwareCatalogStats, error) {
+	var stats fleet.SoftwareCatalogStats
+
+	// first_seen_at is NULL for software that predates tracking, which the
+	// comparisons below treat as not recently added.
+	sql := `
+		SELECT
+			COUNT(*) AS software_count,
+			COALESCE(SUM(first_seen_at >= NOW() - INTERVAL 1 DAY), 0) AS added_last_day,
+			COALESCE(SUM(first_seen_at >= NOW() - INTERVAL 7 DAY), 0) AS added_last_week
+		FROM software
+	`
+	if err := d.db.Get(&stats, sql); err != nil {
+		return stats, errors.Wrap(err, "count software")
+	}
+
+	sql = `SELECT COUNT(*) FROM host_software`
+	if err := d.db.Get(&stats.HostSoftwareCount, sql); err != nil {
+		return stats, errors.Wrap(err, "count host software")
+	}
+
+	return stats, nil
+}
+
+func (d *Datastore) MarkHostSoftwareUpdates(hostID uint, outdatedSoftwareIDs []uint) error {
+	if len(outdatedSoftwareIDs) == 0 {
+		sql := `UPDATE host_software SET update_available = 0 WHERE host_id = ?`
+		if _, err := d.db.Exec(sql, hostID); err != nil {
+			return errors.Wrap(err, "clear host software updates")
+		}
+		return nil
+	}
+
+	sql := `UPDATE host_software SET update_available = software_id IN (?) WHERE host_id = ?`
+	sql, args, err := sqlx.In(sql, outdatedSoftwareIDs, hostID)
+	if err != nil {
+		return errors.Wrap(err, "building host software updates query")
+	}
+	if _, err := d.db.Exec(sql, args...); err != nil {
+		return errors.Wrap(err, "mark host software updates")
+	}
+	return nil
+}
+
+func (d *Datastore) RapidlySpreadingSoftware(window time.Duration, minHosts int) ([]fleet.Software, error) {
+	sql := `
+		SELECT s.id, s.name, s.version, s.source
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		WHERE s.first_seen_at >= NOW() - INTERVAL ? SECOND
+		GROUP BY s.id, s.name, s.version, s.source
+		HAVING COUNT(*) >= ?
+		ORDER BY COUNT(*) DESC, s.id ASC
+	`
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, int64(window.Seconds()), minHosts); err != nil {
+		return nil, errors.Wrap(err, "select rapidly spreading software")
+	}
+	return result, nil
+}
+
+func (d *Datastore) MostCommonSoftwareVersions(opt fleet.ListOptions) ([]fleet.Software, error) {
+	// MySQL 5.7 has no window functions, keep the version counts for which
+	// no other version of the same title is more popular.
+	counts := `
+		SELECT s.id, s.name, s.version, s.source, COUNT(*) AS hosts_count
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		GROUP BY s.id, s.name, s.version, s.source
+	`
+	sql := fmt.Sprintf(`
+		SELECT c.id, c.name, c.version, c.source, c.hosts_count
+		FROM (%s) c
+		WHERE NOT EXISTS (
+			SELECT 1 FROM (%s) o
+			WHERE o.name = c.name AND o.source = c.source AND o.hosts_count > c.hosts_count
+		)
+	`, counts, counts)
+	var candidates []fleet.Software
+	if err := d.db.Select(&candidates, sql); err != nil {
+		return nil, errors.Wrap(err, "select most common software version candidates")
+	}
+
+	// Ties are won by the highest version, which can't be compared in SQL
+	// ("10.0" sorts before "9.0" as a string). Equal versions fall back to
+	// the lowest id to stay stable.
+	modal := make(map[string]fleet.Software)
+	for _, s := range candidates {
+		key := s.Name + "\x00" + s.Source
+		other, ok := modal[key]
+		if ok {
+			c := fleet.CompareSoftwareVersions(s.Source, s.Version, other.Version)
+			if c < 0 || (c == 0 && s.ID > other.ID) {
+				continue
+			}
+		}
+		modal[key] = s
+	}
+	if len(modal) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, 0, len(modal))
+	for _, s := range modal {
+		ids = append(ids, s.ID)
+	}
+
+	if opt.OrderKey == "" {
+		opt.OrderKey = "name"
+	}
+	sql, args, err := sqlx.In(fmt.Sprintf(`
+		SELECT c.id, c.name, c.version, c.source, c.hosts_count
+		FROM (%s) c
+		WHERE c.id IN (?)
+	`, counts), ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "building most common software versions query")
+	}
+	sql = appendListOptionsToSQL(sql, opt)
+
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "select most common software versions")
+	}
+	return result, nil
+}
+
+func (d *Datastore) RepairZeroSoftwareIDs() (fleet.ZeroSoftwareIDsRepair, error) {
+	var report fleet.ZeroSoftwareIDsRepair
+	err := d.withRetryTxx(func(tx *sqlx.Tx) error {
+		report = fleet.ZeroSoftwareIDsRepair{}
+
+		var hostIDs []uint
+		sql := `SELECT DISTINCT host_id FROM host_software WHERE software_id = 0 FOR UPDATE`
+		if err := tx.Select(&hostIDs, sql); err != nil {
+			return errors.Wrap(err, "select hosts with zero software ids")
+		}
+		if len(hostIDs) == 0 {
+			return nil
+		}
+
+		res, err := tx.Exec(`DELETE FROM host_software WHERE software_id = 0`)
+		if err != nil {
+			return errors.Wrap(err, "delete zero software ids")
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "rows affected by zero software ids delete")
+		}
+		report.DeletedCount = uint(deleted)
+
+		// The rows don't say which software they were meant to reference,
+		// have the hosts report their software again to resolve it.
+		sql, args, err := sqlx.In(`UPDATE hosts SET refetch_requested = 1 WHERE id IN (?)`, hostIDs)
+		if err != nil {
+			return errors.Wrap(err, "building refetch hosts query")
+		}
+		if _, err := tx.Exec(sql, args...); err != nil {
+			return errors.Wrap(err, "refetch hosts with zero software ids")
+		}
+		report.RefetchHostIDs = hostIDs
+
+		return nil
+	})
+	if err != nil {
+		return fleet.ZeroSoftwareIDsRepair{}, errors.Wrap(err, "repair zero software ids")
+	}
+	return report, nil
+}
+
+func (d *Datastore) TagSoftware(id uint, categories []string) error {
+	var args []interface{}
+	seen := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		if category == "" {
+			return errors.New("software category cannot be empty")
+		}
+		if seen[category] {
+			continue
+		}
+		seen[category] = true
+		args = append(args, id, category)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	sql := fmt.Sprintf(
+		`INSERT IGNORE INTO software_categories (software_id, category) VALUES %s`,
+		strings.TrimSuffix(strings.Repeat("(?,?),", len(args)/2), ","),
+	)
+	if _, err := d.db.Exec(sql, args...); err != nil {
+		return errors.Wrap(err, "tag software")
+	}
+	return nil
+}
+
+func (d *Datastore) ListSoftwareByCategory(category string, opt fleet.ListOptions) ([]fleet.Software, error) {
+	sql := `
+		SELECT s.id, s.name, s.version, s.source
+		FROM software s
+		JOIN software_categories sc ON sc.software_id = s.id
+		WHERE sc.category = ?
+	`
+	sql = appendListOptionsToSQL(sql, opt)
+
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, category); err != nil {
+		return nil, errors.Wrap(err, "list software by category")
+	}
+	return result, nil
+}
+
+func (d *Datastore) ListSoftwareForLabel(labelID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	sql := `
+		SELECT s.id, s.name, s.version, s.source, COUNT(DISTINCT hs.host_id) AS hosts_count
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		JOIN label_membership lm ON lm.host_id = hs.host_id
+		WHERE lm.label_id = ?
+	`
+	args := []interface{}{labelID}
+	if opt.Managed != nil {
+		sql += ` AND hs.managed = ?`
+		args = append(args, *opt.Managed)
+	}
+	sql += ` GROUP BY s.id, s.name, s.version, s.source`
+	sql = appendSoftwareListOptionsToSQL(sql, opt)
+
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "list software for label")
+	}
+	result = collapseSoftwareList(result, opt)
+	return result, nil
+}
+
+// notSuppressedCVE is the condition excluding the CVEs of software_cve sc
+// suppressed as false positives.
+const notSuppressedCVE = `NOT EXISTS (
+	SELECT 1 FROM software_cve_suppressions scs WHERE scs.software_id = sc.software_id AND scs.cve = sc.cve
+)`
+
+func (d *Datastore) LoadHostSoftwareWithVulnerabilities(host *fleet.Host) error {
+	if err := d.LoadHostSoftware(host); err != nil {
+		return err
+	}
+	return d.LoadSoftwareVulnerabilities(host.Software)
+}
+
+func (d *Datastore) LoadSoftwareVulnerabilities(software []fleet.Software) error {
+	if len(software) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(software))
+	for _, s := range software {
+		ids = append(ids, s.ID)
+	}
+	sql, args, err := sqlx.In(`
+		SELECT sc.software_id, sc.cve, cm.cvss_score, cm.epss_probability, COALESCE(cm.cisa_known_exploit, 0) AS cisa_known_exploit
+		FROM software_cve sc
+		LEFT JOIN cve_meta cm ON cm.cve = sc.cve
+		WHERE sc.software_id IN (?) AND `+notSuppressedCVE+`
+		ORDER BY sc.software_id, sc.cve`,
+		ids,
+	)
+	if err != nil {
+		return errors.Wrap(err, "building software cve query")
+	}
+	var rows []struct {
+		SoftwareID uint `db:"software_id"`
+		fleet.SoftwareCVE
+	}
+	if err := d.db.Select(&rows, sql, args...); err != nil {
+		return errors.Wrap(err, "load software cves")
+	}
+
+	vulnerabilities := make(map[uint]fleet.VulnerabilitiesSlice)
+	for _, row := range rows {
+		vulnerabilities[row.SoftwareID] = append(vulnerabilities[row.SoftwareID], row.SoftwareCVE)
+	}
+	for i, s := range software {
+		software[i].Vulnerabilities = vulnerabilities[s.ID]
+		if software[i].Vulnerabilities == nil {
+			software[i].Vulnerabilities = fleet.VulnerabilitiesSlice{}
+		}
+	}
+	return nil
+}
+
+func (d *Datastore) SoftwareActivityTimeline(bucket time.Duration, since time.Time) ([]fleet.SoftwareActivityBucket, error) {
+	seconds := int64(bucket / time.Second)
+	if seconds <= 0 {
+		return nil, errors.New("software activity bucket must be at least one second")
+	}
+
+	// Buckets are aligned to multiples of their size since the Unix epoch.
+	sql := `
+		SELECT
+			FLOOR(UNIX_TIMESTAMP(created_at) / ?) * ? AS bucket_start,
+			COALESCE(SUM(action = ?), 0) AS installs,
+			COALESCE(SUM(action = ?), 0) AS removals
+		FROM host_software_history
+		WHERE created_at >= ?
+		GROUP BY bucket_start
+		ORDER BY bucket_start
+	`
+	var rows []struct {
+		BucketStart int64 `db:"bucket_start"`
+		Installs    uint  `db:"installs"`
+		Removals    uint  `db:"removals"`
+	}
+	if err := d.db.Select(
+		&rows, sql,
+		seconds, seconds, fleet.SoftwareHistoryInstalled, fleet.SoftwareHistoryRemoved, since,
+	); err != nil {
+		return nil, errors.Wrap(err, "select software activity timeline")
+	}
+
+	result := make([]fleet.SoftwareActivityBucket, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, fleet.SoftwareActivityBucket{
+			Start:    time.Unix(row.BucketStart, 0).UTC(),
+			Installs: row.Installs,
+			Removals: row.Removals,
+		})
+	}
+	return result, nil
+}
+
+func (d *Datastore) HostSoftwareBaselineViolations(hostID uint, baseline []fleet.Software) ([]fleet.Software, error) {
+	software, err := d.hostSoftwareFromHostID(nil, hostID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[[2]string]bool, len(baseline))
+	for _, s := range baseline {
+		allowed[[2]string{s.Name, s.Source}] = true
+	}
+	violations := []fleet.Software{}
+	for _, s := range software {
+		if !allowed[[2]string{s.Name, s.Source}] {
+			violations = append(violations, s)
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Name != violations[j].Name {
+			return violations[i].Name < violations[j].Name
+		}
+		return violations[i].Source < violations[j].Source
+	})
+	return violations, nil
+}
+
+func (d *Datastore) HostSoftwareArchMismatches(hostID uint, hostArch string) ([]fleet.Software, error) {
+	sql := `
+		SELECT s.id, s.name, s.version, s.source, s.bundle_identifier, s.extension_id, s.browser, hs.signature_status, hs.update_available, hs.managed, hs.arch, hs.last_opened_at
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		WHERE hs.host_id = ? AND hs.arch NOT IN ('', 'noarch', 'all') AND hs.arch != ?
+		ORDER BY s.name, s.version
+	`
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, hostID, hostArch); err != nil {
+		return nil, errors.Wrap(err, "select host software arch mismatches")
+	}
+	return result, nil
+}
+
+func (d *Datastore) MergeHostSoftware(oldHostID, newHostID uint, clearOld bool) error {
+	if oldHostID == newHostID {
+		return errors.New("cannot merge the software of a host into itself")
+	}
+
+	err := d.withRetryTxx(func(tx *sqlx.Tx) error {
+		sql := `
+			INSERT IGNORE INTO host_software
+				(host_id, software_id, signature_status, update_available, managed, arch, last_opened_at)
+			SELECT ?, software_id, signature_status, update_available, managed, arch, last_opened_at
+			FROM host_software
+			WHERE host_id = ?
+		`
+		if _, err := tx.Exec(sql, newHostID, oldHostID); err != nil {
+			return errors.Wrap(err, "merge host software")
+		}
+
+		sql = `
+			INSERT INTO host_software_history (host_id, software_id, name, version, source, action, created_at)
+			SELECT ?, software_id, name, version, source, action, created_at
+			FROM host_software_history
+			WHERE host_id = ?
+		`
+		if _, err := tx.Exec(sql, newHostID, oldHostID); err != nil {
+			return errors.Wrap(err, "merge host software history")
+		}
+
+		if !clearOld {
+			return nil
+		}
+		if _, err := tx.Exec(`DELETE FROM host_software WHERE host_id = ?`, oldHostID); err != nil {
+			return errors.Wrap(err, "clear old host software")
+		}
+		if _, err := tx.Exec(`DELETE FROM host_software_history WHERE host_id = ?`, oldHostID); err != nil {
+			return errors.Wrap(err, "clear old host software history")
+		}
+		return nil
+	})
+	return errors.Wrap(err, "merge host software")
+}
+
+func (d *Datastore) HostSoftwareCounts(opt fleet.ListOptions) (map[uint]int, error) {
+	sql := `
+		SELECT host_id, COUNT(DISTINCT software_id) AS software_count
+		FROM host_software
+		GROUP BY host_id
+	`
+	if opt.OrderKey == "" {
+		opt.OrderKey = "host_id"
+	}
+	sql = appendListOptionsToSQL(sql, opt)
+
+	var rows []struct {
+		HostID        uint `db:"host_id"`
+		SoftwareCount int  `db:"software_count"`
+	}
+	if err := d.db.Select(&rows, sql); err != nil {
+		return nil, errors.Wrap(err, "select host software counts")
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.HostID] = row.SoftwareCount
+	}
+	return counts, nil
+}
+
+func (d *Datastore) LatestObservedSoftwareVersions(opt fleet.ListOptions) (map[string]string, error) {
+	// Paginate over the titles, then compare the versions of each title in
+	// Go as versions don't sort correctly as strings.
+	opt.OrderKey = "title"
+	opt.OrderDirection = fleet.OrderAscending
+	titles := appendListOptionsToSQL(`
+		SELECT DISTINCT LOWER(TRIM(s.name)) AS title
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+	`, opt)
+	sql := fmt.Sprintf(`
+		SELECT DISTINCT s.name, s.version, s.source
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		JOIN (%s) t ON t.title = LOWER(TRIM(s.name))
+	`, titles)
+
+	var software []fleet.Software
+	if err := d.db.Select(&software, sql); err != nil {
+		return nil, errors.Wrap(err, "select observed software versions")
+	}
+
+	latest := make(map[string]string)
+	for _, s := range software {
+		title := fleet.SoftwareTitle(s.Name)
+		current, ok := latest[title]
+		if !ok || fleet.CompareSoftwareVersions(s.Source, s.Version, current) > 0 {
+			latest[title] = s.Version
+		}
+	}
+	return latest, nil
+}
+
+func (d *Datastore) SoftwareByID(id uint) (*fleet.Software, error) {
+	sqlStatement := `
+		SELECT s.id, s.name, s.version, s.source, COALESCE(sn.note, '') AS note
+		FROM software s
+		LEFT JOIN software_notes sn ON sn.software_id = s.id
+		WHERE s.id = ?
+	`
+	var software fleet.Software
+	if err := d.db.Get(&software, sqlStatement, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFound("Software").WithID(id)
+		}
+		return nil, errors.Wrap(err, "select software by id")
+	}
+	return &software, nil
+}
+
+func (d *Datastore) SetSoftwareNote(id uint, note string) error {
+	if _, err := d.SoftwareByID(id); err != nil {
+		return err
+	}
+
+	if note == "" {
+		if _, err := d.db.Exec(`DELETE FROM software_notes WHERE software_id = ?`, id); err != nil {
+			return errors.Wrap(err, "clear software note")
+		}
+		return nil
+	}
+
+	sqlStatement := `
+		INSERT INTO software_notes (software_id, note) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE note = VALUES(note)
+	`
+	if _, err := d.db.Exec(sqlStatement, id, note); err != nil {
+		return errors.Wrap(err, "set software note")
+	}
+	return nil
+}
+
+func (d *Datastore) SoftwareDriftFromReference(hostID, referenceHostID uint) (extra, missing []fleet.Software, err error) {
+	extra, err = d.softwareNotOnHost(hostID, referenceHostID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "select extra software")
+	}
+	missing, err = d.softwareNotOnHost(referenceHostID, hostID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "select missing software")
+	}
+	return extra, missing, nil
+}
+
+// softwareNotOnHost returns the software installed on the host that isn't
+// installed on the other host.
+func (d *Datastore) softwareNotOnHost(hostID, otherHostID uint) ([]fleet.Software, error) {
+	sql := `
+		SELECT s.id, s.name, s.version, s.source, s.bundle_identifier, s.extension_id, s.browser, hs.signature_status, hs.update_available, hs.managed, hs.arch, hs.last_opened_at
+		FROM host_software hs
+		JOIN software s ON s.id = hs.software_id
+		LEFT JOIN host_software other ON other.software_id = hs.software_id AND other.host_id = ?
+		WHERE hs.host_id = ? AND other.host_id IS NULL
+		ORDER BY s.name, s.version
+	`
+	result := []fleet.Software{}
+	if err := d.db.Select(&result, sql, otherHostID, hostID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (d *Datastore) HostSoftwareDowngrades(hostID uint, since time.Time) ([]fleet.SoftwareChange, error) {
+	sql := `
+		SELECT name, version, source, action, created_at
+		FROM host_software_history
+		WHERE host_id = ? AND created_at >= ?
+		ORDER BY id
+	`
+	var rows []struct {
+		Name      string    `db:"name"`
+		Version   string    `db:"version"`
+		Source    string    `db:"source"`
+		Action    string    `db:"action"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+	if err := d.db.Select(&rows, sql, hostID, since); err != nil {
+		return nil, errors.Wrap(err, "select host software history")
+	}
+
+	// A version change is recorded as the removal of the old version
+	// followed by the install of the new one.
+	removed := make(map[[2]string]string)
+	downgrades := []fleet.SoftwareChange{}
+	for _, row := range rows {
+		key := [2]string{row.Name, row.Source}
+		switch row.Action {
+		case fleet.SoftwareHistoryRemoved:
+			removed[key] = row.Version
+		case fleet.SoftwareHistoryInstalled:
+			oldVersion, ok := removed[key]
+			if !ok {
+				continue
+			}
+			delete(removed, key)
+			if fleet.CompareSoftwareVersions(row.Source, row.Version, oldVersion) < 0 {
+				downgrades = append(downgrades, fleet.SoftwareChange{
+					Name:       row.Name,
+					Source:     row.Source,
+					OldVersion: oldVersion,
+					NewVersion: row.Version,
+					ChangedAt:  row.CreatedAt,
+				})
+			}
+		}
+	}
+	return downgrades, nil
+}
+
+func (d *Datastore) SoftwareSources() ([]string, error) {
+	sql := `
+		SELECT DISTINCT source
+		FROM software
+		WHERE TRIM(source) != ''
+		ORDER BY source
+	`
+	sources := []string{}
+	if err := d.db.Select(&sources, sql); err != nil {
+		return nil, errors.Wrap(err, "select software sources")
+	}
+	return sources, nil
+}
+
+func (d *Datastore) HostsWithConflictingSoftwareVersions(name, source string) ([]fleet.Host, error) {
+	sql := `
+		SELECT h.*, (SELECT name FROM teams t WHERE t.id = h.team_id) AS team_name
+		FROM hosts h
+		JOIN (
+			SELECT hs.host_id
+			FROM host_software hs
+			JOIN software s ON s.id = hs.software_id
+			WHERE s.name = ? AND s.source = ?
+			GROUP BY hs.host_id
+			HAVING COUNT(DISTINCT s.version) > 1
+		) c ON c.host_id = h.id
+		ORDER BY h.id
+	`
+	hosts := []fleet.Host{}
+	if err := d.db.Select(&hosts, sql, name, source); err != nil {
+		return nil, errors.Wrap(err, "select hosts with conflicting software versions")
+	}
+	return hosts, nil
+}
+
+func (d *Datastore) VerifySoftwareChecksums() (int, error) {
+	// Read the software in batches by ID to bound the memory used for large
+	// catalogs.
+	sql := `
+		SELECT id, name, version, source, bundle_identifier, extension_id, browser, checksum
+		FROM software
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`
+	mismatched := 0
+	var lastID uint
+	for {
+		var rows []struct {
+			fleet.Software
+			Checksum []byte `db:"checksum"`
+		}
+		if err := d.db.Select(&rows, sql, lastID, softwareBatchSize); err != nil {
+			return 0, errors.Wrap(err, "select software checksums")
+		}
+		for _, row := range rows {
+			if !bytes.Equal(row.Checksum, softwareChecksum(row.Software)) {
+				mismatched++
+			}
+		}
+		if len(rows) < softwareBatchSize {
+			return mismatched, nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+func (d *Datastore) HostSoftwareAddedSince(hostID uint, since time.Time) ([]fleet.Software, error) {
+	sql := `
+		SELECT s.id, s.name, s.version, s.source, s.bundle_identifier, s.extension_id, s.browser, hs.signature_status, hs.update_available, hs.managed, hs.arch, hs.last_opened_at
+		FROM host_software hs
+		JOIN software s ON s.id = hs.software_id
+		WHERE hs.host_id = ? AND EXISTS (
+			SELECT 1 FROM host_software_history h
+			WHERE h.host_id = hs.host_id AND h.software_id = hs.software_id AND h.action = ? AND h.created_at > ?
+		)
+		ORDER BY s.name, s.version
+	`
+	result := []fleet.Software{}
+	if err := d.db.Select(&result, sql, hostID, fleet.SoftwareHistoryInstalled, since); err != nil {
+		return nil, errors.Wrap(err, "select host software added since")
+	}
+	return result, nil
+}
+
+func (d *Datastore) ListSoftwareForHostFilter(filter fleet.HostListOptions, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	hostsSQL, args := filterHostsByListOptions(`SELECT h.id FROM hosts h WHERE TRUE `, nil, filter)
+	sql := fmt.Sprintf(`
+		SELECT s.id, s.name, s.version, s.source, COUNT(DISTINCT hs.host_id) AS hosts_count
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		WHERE hs.host_id IN (%s)
+	`, hostsSQL)
+	if opt.Managed != nil {
+		sql += ` AND hs.managed = ?`
+		args = append(args, *opt.Managed)
+	}
+	sql += ` GROUP BY s.id, s.name, s.version, s.source`
+	sql = appendSoftwareListOptionsToSQL(sql, opt)
+
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "list software for host filter")
+	}
+	result = collapseSoftwareList(result, opt)
+	return result, nil
+}
+
+func (d *Datastore) CleanupOrphanedSoftware(batchSize int) (int, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("software cleanup batch size must be positive")
+	}
+
+	// Delete in batches, each in its own statement, so that the software
+	// table isn't locked for the whole cleanup. MySQL doesn't allow LIMIT
+	// with a multi-table DELETE, hence the derived table.
+	// The software with notes, categories or CVE suppressions entered by the
+	// admins is kept so that they still apply if the software is installed
+	// again. The history records the software by value and doesn't need it.
+	sql := `
+		DELETE FROM software WHERE id IN (
+			SELECT id FROM (
+				SELECT s.id
+				FROM software s
+				LEFT JOIN host_software hs ON hs.software_id = s.id
+				WHERE hs.software_id IS NULL
+				AND NOT EXISTS (SELECT 1 FROM software_notes sn WHERE sn.software_id = s.id)
+				AND NOT EXISTS (SELECT 1 FROM software_categories sc WHERE sc.software_id = s.id)
+				AND NOT EXISTS (SELECT 1 FROM software_cve_suppressions scs WHERE scs.software_id = s.id)
+				LIMIT ?
+			) orphaned
+		)
+	`
+	deleted := 0
+	for {
+		result, err := d.db.Exec(sql, batchSize)
+		if err != nil {
+			return deleted, errors.Wrap(err, "delete orphaned software")
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, errors.Wrap(err, "rows affected by orphaned software delete")
+		}
+		deleted += int(affected)
+		if affected < int64(batchSize) {
+			return deleted, nil
+		}
+	}
+}
+
+func (d *Datastore) ListSoftware(opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	sql := `
+		SELECT s.id, s.name, s.version, s.source, s.extension_id, s.browser, shc.hosts_count
+		FROM software s
+		JOIN software_host_counts shc ON shc.software_id = s.id
+		WHERE shc.hosts_count > 0
+	`
+	var args []interface{}
+	if opt.MatchQuery != "" {
+		sql, args = searchLike(sql, args, opt.MatchQuery, "s.name")
+	}
+	if opt.Managed != nil {
+		sql += ` AND EXISTS (SELECT 1 FROM host_software hs WHERE hs.software_id = s.id AND hs.managed = ?)`
+		args = append(args, *opt.Managed)
+	}
+	if opt.Browser != nil {
+		sql += ` AND s.browser = ?`
+		args = append(args, *opt.Browser)
+	}
+	if opt.KnownExploit {
+		sql += ` AND EXISTS (
+			SELECT 1 FROM software_cve sc
+			JOIN cve_meta cm ON cm.cve = sc.cve
+			WHERE sc.software_id = s.id AND cm.cisa_known_exploit = 1 AND ` + notSuppressedCVE + `
+		)`
+	}
+	if opt.OrderKey == "" {
+		opt.OrderKey = "name"
+	}
+	sql = appendSoftwareListOptionsToSQL(sql, opt)
+
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "list software")
+	}
+	result = collapseSoftwareList(result, opt)
+	return result, nil
+}
+
+func (d *Datastore) CalculateHostsPerSoftware(updatedAt time.Time) error {
+	// updated_at has a precision of a second, truncate so that the counts
+	// just stored are never considered stale.
+	updatedAt = updatedAt.Truncate(time.Second)
+
+	sql := `
+		INSERT INTO software_host_counts (software_id, hosts_count, updated_at)
+		SELECT software_id, COUNT(DISTINCT host_id), ?
+		FROM host_software
+		GROUP BY software_id
+		ON DUPLICATE KEY UPDATE
+			hosts_count = VALUES(hosts_count),
+			updated_at = VALUES(updated_at)
+	`
+	if _, err := d.db.Exec(sql, updatedAt); err != nil {
+		return errors.Wrap(err, "insert software host counts")
+	}
+
+	// Software not updated above is no longer installed on any host.
+	if _, err := d.db.Exec(`DELETE FROM software_host_counts WHERE updated_at < ?`, updatedAt); err != nil {
+		return errors.Wrap(err, "delete stale software host counts")
+	}
+	return nil
+}
+
+func (d *Datastore) ListSoftwareWithoutCPE() ([]fleet.Software, error) {
+	sql := `
+		SELECT s.id, s.name, s.version, s.source
+		FROM software s
+		LEFT JOIN software_cpe cpe ON cpe.software_id = s.id
+		WHERE cpe.id IS NULL
+	`
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql); err != nil {
+		return nil, errors.Wrap(err, "select software without cpe")
+	}
+	return result, nil
+}
+
+func (d *Datastore) AddCPEForSoftware(softwareID uint, cpe string) error {
+	sql := `
+		INSERT INTO software_cpe (software_id, cpe) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE cpe = VALUES(cpe)
+	`
+	if _, err := d.db.Exec(sql, softwareID, cpe); err != nil {
+		return errors.Wrap(err, "insert software cpe")
+	}
+	return nil
+}
+
+func (d *Datastore) ListSoftwareCPEs() ([]fleet.SoftwareCPE, error) {
+	var result []fleet.SoftwareCPE
+	sql := `
+		SELECT cpe.software_id, cpe.cpe
+		FROM software_cpe cpe
+		WHERE NOT EXISTS (SELECT 1 FROM software_oval_results r WHERE r.software_id = cpe.software_id)
+		ORDER BY cpe.software_id
+	`
+	if err := d.db.Select(&result, sql); err != nil {
+		return nil, errors.Wrap(err, "select software cpes")
+	}
+	return result, nil
+}
+
+func (d *Datastore) ReplaceSoftwareCVEs(softwareID uint, cves []string) error {
+	err := d.withRetryTxx(func(tx *sqlx.Tx) error {
+		if len(cves) == 0 {
+			if _, err := tx.Exec(`DELETE FROM software_cve WHERE software_id = ?`, softwareID); err != nil {
+				return errors.Wrap(err, "delete software cves")
+			}
+			return nil
+		}
+
+		sql, args, err := sqlx.In(`DELETE FROM software_cve WHERE software_id = ? AND cve NOT IN (?)`, softwareID, cves)
+		if err != nil {
+			return errors.Wrap(err, "build software cve delete")
+		}
+		if _, err := tx.Exec(sql, args...); err != nil {
+			return errors.Wrap(err, "delete software cves")
+		}
+
+		values := strings.TrimSuffix(strings.Repeat("(?, ?),", len(cves)), ",")
+		args = make([]interface{}, 0, 2*len(cves))
+		for _, cve := range cves {
+			args = append(args, softwareID, cve)
+		}
+		sql = fmt.Sprintf(`INSERT IGNORE INTO software_cve (software_id, cve) VALUES %s`, values)
+		if _, err := tx.Exec(sql, args...); err != nil {
+			return errors.Wrap(err, "insert software cves")
+		}
+		return nil
+	})
+	return errors.Wrap(err, "replace software cves")
+}
+
+func (d *Datastore) ListSoftwareByTeam(teamID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	// The stored host counts are for all hosts, count the hosts of the team.
+	sql := `
+		SELECT s.id, s.name, s.version, s.source, s.extension_id, s.browser, COUNT(DISTINCT hs.host_id) AS hosts_count
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		JOIN hosts h ON h.id = hs.host_id
+		WHERE h.team_id = ?
+	`
+	args := []interface{}{teamID}
+	if opt.MatchQuery != "" {
+		sql, args = searchLike(sql, args, opt.MatchQuery, "s.name")
+	}
+	if opt.Managed != nil {
+		sql += ` AND hs.managed = ?`
+		args = append(args, *opt.Managed)
+	}
+	if opt.Browser != nil {
+		sql += ` AND s.browser = ?`
+		args = append(args, *opt.Browser)
+	}
+	if opt.KnownExploit {
+		sql += ` AND EXISTS (
+			SELECT 1 FROM software_cve sc
+			JOIN cve_meta cm ON cm.cve = sc.cve
+			WHERE sc.software_id = s.id AND cm.cisa_known_exploit = 1 AND ` + notSuppressedCVE + `
+		)`
+	}
+	sql += ` GROUP BY s.id, s.name, s.version, s.source, s.extension_id, s.browser`
+	if opt.OrderKey == "" {
+		opt.OrderKey = "name"
+	}
+	sql = appendSoftwareListOptionsToSQL(sql, opt)
+
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "list software by team")
+	}
+	result = collapseSoftwareList(result, opt)
+	return result, nil
+}
+
+func (d *Datastore) ExportSoftware(fn func(fleet.Software) error) error {
+	// One row per software and CVE, the CVEs of a software are grouped as the
+	// rows are read.
+	sql := `
+		SELECT
+			s.id, s.name, s.version, s.source, shc.hosts_count,
+			COALESCE(sc.cve, '') AS cve, cm.cvss_score, cm.epss_probability,
+			COALESCE(cm.cisa_known_exploit, 0) AS cisa_known_exploit
+		FROM software s
+		JOIN software_host_counts shc ON shc.software_id = s.id
+		LEFT JOIN software_cve sc ON sc.software_id = s.id AND ` + notSuppressedCVE + `
+		LEFT JOIN cve_meta cm ON cm.cve = sc.cve
+		WHERE shc.hosts_count > 0
+		ORDER BY s.id, sc.cve
+	`
+	rows, err := d.db.Queryx(sql)
+	if err != nil {
+		return errors.Wrap(err, "select software to export")
+	}
+	defer rows.Close()
+
+	var current *fleet.Software
+	for rows.Next() {
+		var row struct {
+			fleet.Software
+			CVE              string   `db:"cve"`
+			CVSSScore        *float64 `db:"cvss_score"`
+			EPSSProbability  *float64 `db:"epss_probability"`
+			CISAKnownExploit bool     `db:"cisa_known_exploit"`
+		}
+		if err := rows.StructScan(&row); err != nil {
+			return errors.Wrap(err, "scan software to export")
+		}
+		if current != nil && current.ID != row.ID {
+			if err := fn(*current); err != nil {
+				return err
+			}
+			current = nil
+		}
+		if current == nil {
+			current = &row.Software
+			current.Vulnerabilities = fleet.VulnerabilitiesSlice{}
+		}
+		if row.CVE != "" {
+			current.Vulnerabilities = append(current.Vulnerabilities, fleet.SoftwareCVE{
+				CVE:              row.CVE,
+				CVSSScore:        row.CVSSScore,
+				EPSSProbability:  row.EPSSProbability,
+				CISAKnownExploit: row.CISAKnownExploit,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "read software to export")
+	}
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
+
+func (d *Datastore) CalculateSoftwareTitles(updatedAt time.Time) error {
+	// updated_at has a precision of a second, truncate so that the titles
+	// just stored are never considered stale.
+	updatedAt = updatedAt.Truncate(time.Second)
+
+	sql := `
+		INSERT INTO software_titles (name, source, hosts_count, versions_count, updated_at)
+		SELECT s.name, s.source, COUNT(DISTINCT hs.host_id), COUNT(DISTINCT s.version), ?
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		GROUP BY s.name, s.source
+		ON DUPLICATE KEY UPDATE
+			hosts_count = VALUES(hosts_count),
+			versions_count = VALUES(versions_count),
+			updated_at = VALUES(updated_at)
+	`
+	if _, err := d.db.Exec(sql, updatedAt); err != nil {
+		return errors.Wrap(err, "insert software titles")
+	}
+
+	// Titles not updated above are no longer installed on any host.
+	if _, err := d.db.Exec(`DELETE FROM software_titles WHERE updated_at < ?`, updatedAt); err != nil {
+		return errors.Wrap(err, "delete stale software titles")
+	}
+	return nil
+}
+
+func (d *Datastore) ListSoftwareTitles(opt fleet.ListOptions) ([]fleet.SoftwareTitleSummary, error) {
+	sql := `
+		SELECT id, name, source, hosts_count, versions_count
+		FROM software_titles
+		WHERE hosts_count > 0
+	`
+	var args []interface{}
+	if opt.MatchQuery != "" {
+		sql, args = searchLike(sql, args, opt.MatchQuery, "name")
+	}
+	if opt.OrderKey == "" {
+		opt.OrderKey = "name"
+	}
+	sql = appendListOptionsToSQL(sql, opt)
+
+	var titles []fleet.SoftwareTitleSummary
+	if err := d.db.Select(&titles, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "list software titles")
+	}
+	if len(titles) == 0 {
+		return titles, nil
+	}
+
+	names := make([]string, 0, len(titles))
+	for _, t := range titles {
+		names = append(names, t.Name)
+	}
+	sql, args, err := sqlx.In(`
+		SELECT s.id, s.name, s.version, s.source, shc.hosts_count
+		FROM software s
+		JOIN software_host_counts shc ON shc.software_id = s.id
+		WHERE shc.hosts_count > 0 AND s.name IN (?)
+	`, names)
+	if err != nil {
+		return nil, errors.Wrap(err, "build software title versions query")
+	}
+	var versions []fleet.Software
+	if err := d.db.Select(&versions, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "list software title versions")
+	}
+
+	// Titles are grouped with the collation of the table, which ignores
+	// case.
+	titleKey := func(name, source string) string {
+		return strings.ToLower(name) + "\x00" + strings.ToLower(source)
+	}
+	byKey := make(map[string]*fleet.SoftwareTitleSummary, len(titles))
+	for i := range titles {
+		byKey[titleKey(titles[i].Name, titles[i].Source)] = &titles[i]
+	}
+	for _, s := range versions {
+		if t, ok := byKey[titleKey(s.Name, s.Source)]; ok {
+			t.Versions = append(t.Versions, s)
+		}
+	}
+	for i := range titles {
+		versions := titles[i].Versions
+		sort.SliceStable(versions, func(a, b int) bool {
+			return fleet.CompareSoftwareVersions(versions[a].Source, versions[a].Version, versions[b].Version) > 0
+		})
+	}
+	return titles, nil
+}
+
+func (d *Datastore) ListHostSoftwareVersionsByName(name string) ([]fleet.HostSoftwareVersion, error) {
+	sql := `
+		SELECT h.id AS host_id, h.hostname, s.id AS software_id, s.name, s.version, s.source
+		FROM host_software hs
+		JOIN hosts h ON h.id = hs.host_id
+		JOIN software s ON s.id = hs.software_id
+		WHERE s.name = ?
+		ORDER BY h.id, s.id
+	`
+	var result []fleet.HostSoftwareVersion
+	if err := d.db.Select(&result, sql, name); err != nil {
+		return nil, errors.Wrap(err, "select host software versions by name")
+	}
+	return result, nil
+}
+
+func (d *Datastore) SoftwareInventoryEnabled(teamID *uint) (bool, error) {
+	sql := `
+		SELECT COALESCE(
+			(SELECT enable_software_inventory FROM teams WHERE id = ?),
+			(SELECT enable_software_inventory FROM app_configs LIMIT 1),
+			TRUE
+		)
+	`
+	var enabled bool
+	if err := d.db.Get(&enabled, sql, teamID); err != nil {
+		return false, errors.Wrap(err, "select software inventory enabled")
+	}
+	return enabled, nil
+}
+
+func (d *Datastore) ListHostSoftwareChanges(hostID uint, opt fleet.ListOptions) ([]fleet.HostSoftwareChange, error) {
+	sql := `
+		SELECT name, version, source, action, created_at
+		FROM host_software_history
+		WHERE host_id = ?
+	`
+	args := []interface{}{hostID}
+	if opt.MatchQuery != "" {
+		sql, args = searchLike(sql, args, opt.MatchQuery, "name")
+	}
+	if opt.OrderKey == "" {
+		// Most recent changes first.
+		opt.OrderKey = "created_at"
+		opt.OrderDirection = fleet.OrderDescending
+	}
+	sql = appendListOptionsToSQL(sql, opt)
+
+	result := []fleet.HostSoftwareChange{}
+	if err := d.db.Select(&result, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "list host software changes")
+	}
+	return result, nil
+}
+
+func (d *Datastore) ListHostOSVersions() ([]fleet.HostOSVersion, error) {
+	var result []fleet.HostOSVersion
+	sql := `SELECT DISTINCT platform, os_version FROM hosts ORDER BY platform, os_version`
+	if err := d.db.Select(&result, sql); err != nil {
+		return nil, errors.Wrap(err, "select host os versions")
+	}
+	return result, nil
+}
+
+func (d *Datastore) ListSoftwareByHostOS(os fleet.HostOSVersion, source string) ([]fleet.Software, error) {
+	sql := `
+		SELECT DISTINCT s.id, s.name, s.version, s.source, s.epoch, s.package_release
+		FROM software s
+		JOIN host_software hs ON hs.software_id = s.id
+		JOIN hosts h ON h.id = hs.host_id
+		WHERE h.platform = ? AND h.os_version = ? AND s.source = ?
+		ORDER BY s.id
+	`
+	var result []fleet.Software
+	if err := d.db.Select(&result, sql, os.Platform, os.OSVersion, source); err != nil {
+		return nil, errors.Wrap(err, "select software by host os")
+	}
+	return result, nil
+}
+
+func (d *Datastore) ReplaceSoftwareOVALResults(softwareID uint, results []fleet.SoftwareOVALResult) error {
+	err := d.withRetryTxx(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM software_oval_results WHERE software_id = ?`, softwareID); err != nil {
+			return errors.Wrap(err, "delete software oval results")
+		}
+		if len(results) == 0 {
+			return nil
+		}
+
+		values := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?),", len(results)), ",")
+		args := make([]interface{}, 0, 4*len(results))
+		for _, r := range results {
+			args = append(args, softwareID, r.CVE, r.DefinitionID, r.Vulnerable)
+		}
+		sql := fmt.Sprintf(`INSERT INTO software_oval_results (software_id, cve, definition_id, vulnerable) VALUES %s`, values)
+		if _, err := tx.Exec(sql, args...); err != nil {
+			return errors.Wrap(err, "insert software oval results")
+		}
+		return nil
+	})
+	return errors.Wrap(err, "replace software oval results")
+}
+
+// cveMetaBatchSize is the number of CVE meta rows inserted per statement.
+const cveMetaBatchSize = 1000
+
+func (d *Datastore) InsertCVEMeta(meta []fleet.CVEMeta) error {
+	for len(meta) > 0 {
+		batch := meta
+		if len(batch) > cveMetaBatchSize {
+			batch = batch[:cveMetaBatchSize]
+		}
+		meta = meta[len(batch):]
+
+		values := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?),", len(batch)), ",")
+		args := make([]interface{}, 0, 4*len(batch))
+		for _, m := range batch {
+			args = append(args, m.CVE, m.CVSSScore, m.EPSSProbability, m.CISAKnownExploit)
+		}
+		sql := fmt.Sprintf(`
+			INSERT INTO cve_meta (cve, cvss_score, epss_probability, cisa_known_exploit) VALUES %s
+			ON DUPLICATE KEY UPDATE
+				cvss_score = VALUES(cvss_score),
+				epss_probability = VALUES(epss_probability),
+				cisa_known_exploit = VALUES(cisa_known_exploit)
+		`, values)
+		if _, err := d.db.Exec(sql, args...); err != nil {
+			return errors.Wrap(err, "insert cve meta")
+		}
+	}
+	return nil
+}
+
+func (d *Datastore) ListSoftwareVulnerabilitiesSince(since time.Time) ([]fleet.SoftwareVulnerability, error) {
+	sql := `
+		SELECT s.id AS software_id, s.name, s.version, s.source, sc.cve, cm.cvss_score
+		FROM software_cve sc
+		JOIN software s ON s.id = sc.software_id
+		LEFT JOIN cve_meta cm ON cm.cve = sc.cve
+		WHERE sc.created_at >= ? AND ` + notSuppressedCVE + `
+		ORDER BY sc.cve, s.id
+	`
+	var result []fleet.SoftwareVulnerability
+	if err := d.db.Select(&result, sql, since); err != nil {
+		return nil, errors.Wrap(err, "select software vulnerabilities since")
+	}
+	return result, nil
+}
+
+func (d *Datastore) ListHostSoftwareVersionsBySoftwareID(softwareIDs []uint) ([]fleet.HostSoftwareVersion, error) {
+	if len(softwareIDs) == 0 {
+		return nil, nil
+	}
+	sql, args, err := sqlx.In(`
+		SELECT h.id AS host_id, h.hostname, s.id AS software_id, s.name, s.version, s.source
+		FROM host_software hs
+		JOIN hosts h ON h.id = hs.host_id
+		JOIN software s ON s.id = hs.software_id
+		WHERE s.id IN (?)
+		ORDER BY h.id, s.id`,
+		softwareIDs,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "building host software versions query")
+	}
+	var result []fleet.HostSoftwareVersion
+	if err := d.db.Select(&result, sql, args...); err != nil {
+		return nil, errors.Wrap(err, "select host software versions by software id")
+	}
+	return result, nil
+}
+
+func (d *Datastore) NewSoftwareCVESuppression(suppression *fleet.SoftwareCVESuppression) (*fleet.SoftwareCVESuppression, error) {
+	sql := `
+		INSERT INTO software_cve_suppressions (software_id, software_name, software_version, software_source, cve, reason, author_id)
+		SELECT id, name, version, source, ?, ?, ? FROM software WHERE id = ?
+	`
+	result, err := d.db.Exec(sql, suppression.CVE, suppression.Reason, suppression.AuthorID, suppression.SoftwareID)
+	if err != nil && isDuplicate(err) {
+		return nil, alreadyExists("SoftwareCVESuppression", suppression.CVE)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "insert software cve suppression")
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, notFound("Software").WithID(suppression.SoftwareID)
+	}
+	id, _ := result.LastInsertId()
+	return d.SoftwareCVESuppression(uint(id))
+}
+
+// selectSoftwareCVESuppressions selects the suppressions with their author
+// name.
+const selectSoftwareCVESuppressions = `
+	SELECT
+		scs.id, scs.software_id, scs.software_name, scs.software_version, scs.software_source,
+		scs.cve, scs.reason, scs.author_id, scs.created_at,
+		COALESCE(u.name, '<deleted>') AS author_name
+	FROM software_cve_suppressions scs
+	LEFT JOIN users u ON u.id = scs.author_id
+`
+
+func (d *Datastore) SoftwareCVESuppression(id uint) (*fleet.SoftwareCVESuppression, error) {
+	var suppression fleet.SoftwareCVESuppression
+	err := d.db.Get(&suppression, selectSoftwareCVESuppressions+` WHERE scs.id = ?`, id)
+	if err == sql.ErrNoRows {
+		return nil, notFound("SoftwareCVESuppression").WithID(id)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "select software cve suppression")
+	}
+	return &suppression, nil
+}
+
+func (d *Datastore) ListSoftwareCVESuppressions(opt fleet.ListOptions) ([]fleet.SoftwareCVESuppression, error) {
+	sql := selectSoftwareCVESuppressions + ` ORDER BY scs.created_at DESC, scs.id DESC`
+	opt.OrderKey = ""
+	sql = appendListOptionsToSQL(sql, opt)
+
+	var result []fleet.SoftwareCVESuppression
+	if err := d.db.Select(&result, sql); err != nil {
+		return nil, errors.Wrap(err, "list software cve suppressions")
+	}
+	return result, nil
+}
+
+func (d *Datastore) DeleteSoftwareCVESuppression(id uint) error {
+	result, err := d.db.Exec(`DELETE FROM software_cve_suppressions WHERE id = ?`, id)
+	if err != nil {
+		return errors.Wrap(err, "delete software cve suppression")
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return notFound("SoftwareCVESuppression").WithID(id)
+	}
+	return nil
+}
+
+func (d *Datastore) ListWindowsHostUpdates() ([]fleet.WindowsHostUpdates, error) {
+	var hosts []struct {
+		ID    uint   `db:"id"`
+		Build string `db:"build"`
+	}
+	if err := d.db.Select(&hosts, `SELECT id, build FROM hosts WHERE platform = 'windows' ORDER BY id`); err != nil {
+		return nil, errors.Wrap(err, "select windows hosts")
+	}
+	var updates []struct {
+		HostID uint   `db:"host_id"`
+		KBID   string `db:"kb_id"`
+	}
+	sql := `
+		SELECT hwu.host_id, hwu.kb_id
+		FROM host_windows_updates hwu
+		JOIN hosts h ON h.id = hwu.host_id
+		WHERE h.platform = 'windows'
+		ORDER BY hwu.host_id, hwu.kb_id
+	`
+	if err := d.db.Select(&updates, sql); err != nil {
+		return nil, errors.Wrap(err, "select host windows updates")
+	}
+
+	kbIDs := make(map[uint][]string)
+	for _, u := range updates {
+		kbIDs[u.HostID] = append(kbIDs[u.HostID], u.KBID)
+	}
+	result := make([]fleet.WindowsHostUpdates, 0, len(hosts))
+	for _, h := range hosts {
+		result = append(result, fleet.WindowsHostUpdates{HostID: h.ID, Build: h.Build, KBIDs: kbIDs[h.ID]})
+	}
+	return result, nil
+}
+
+func (d *Datastore) ReplaceHostOperatingSystemVulnerabilities(hostID uint, vulnerabilities []fleet.OperatingSystemVulnerability) error {
+	err := d.withRetryTxx(func(tx *sqlx.Tx) error {
+		if len(vulnerabilities) == 0 {
+			if _, err := tx.Exec(`DELETE FROM operating_system_vulnerabilities WHERE host_id = ?`, hostID); err != nil {
+				return errors.Wrap(err, "delete operating system vulnerabilities")
+			}
+			return nil
+		}
+
+		cves := make([]string, 0, len(vulnerabilities))
+		args := make([]interface{}, 0, 3*len(vulnerabilities))
+		for _, v := range vulnerabilities {
+			cves = append(cves, v.CVE)
+			args = append(args, hostID, v.CVE, v.ResolvedIn)
+		}
+		sql, deleteArgs, err := sqlx.In(`DELETE FROM operating_system_vulnerabilities WHERE host_id = ? AND cve NOT IN (?)`, hostID, cves)
+		if err != nil {
+			return errors.Wrap(err, "build operating system vulnerabilities delete")
+		}
+		if _, err := tx.Exec(sql, deleteArgs...); err != nil {
+			return errors.Wrap(err, "delete operating system vulnerabilities")
+		}
+
+		values := strings.TrimSuffix(strings.Repeat("(?, ?, ?),", len(vulnerabilities)), ",")
+		sql = fmt.Sprintf(`
+			INSERT INTO operating_system_vulnerabilities (host_id, cve, resolved_in) VALUES %s
+			ON DUPLICATE KEY UPDATE resolved_in = VALUES(resolved_in)
+		`, values)
+		if _, err := tx.Exec(sql, args...); err != nil {
+			return errors.Wrap(err, "insert operating system vulnerabilities")
+		}
+		return nil
+	})
+	return errors.Wrap(err, "replace operating system vulnerabilities")
+}
+
+func (d *Datastore) ListHostOperatingSystemVulnerabilities(hostID uint) ([]fleet.OperatingSystemVulnerability, error) {
+	sql := `
+		SELECT cve, resolved_in, created_at
+		FROM operating_system_vulnerabilities
+		WHERE host_id = ?
+		ORDER BY cve
+	`
+	var vulnerabilities []fleet.OperatingSystemVulnerability
+	if err := d.db.Select(&vulnerabilities, sql, hostID); err != nil {
+		return nil, errors.Wrap(err, "select operating system vulnerabilities")
+	}
+	return vulnerabilities, nil
+}
@@ -0,0 +1,665 @@
+package mysql
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/test"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftwareCatalogStats(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "today", Version: "1.0", Source: "deb_packages"},
+			{Name: "this_week", Version: "1.0", Source: "deb_packages"},
+			{Name: "last_month", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "today", Version: "1.0", Source: "deb_packages"},
+			{Name: "unknown", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	_, err := ds.db.Exec(`UPDATE software SET first_seen_at = NOW() - INTERVAL 3 DAY WHERE name = 'this_week'`)
+	require.NoError(t, err)
+	_, err = ds.db.Exec(`UPDATE software SET first_seen_at = NOW() - INTERVAL 30 DAY WHERE name = 'last_month'`)
+	require.NoError(t, err)
+	_, err = ds.db.Exec(`UPDATE software SET first_seen_at = NULL WHERE name = 'unknown'`)
+	require.NoError(t, err)
+
+	stats, err := ds.SoftwareCatalogStats()
+	require.NoError(t, err)
+	assert.Equal(t, fleet.SoftwareCatalogStats{
+		SoftwareCount:     4,
+		HostSoftwareCount: 5,
+		AddedLastDay:      1,
+		AddedLastWeek:     2,
+	}, stats)
+}
+
+func TestRapidlySpreadingSoftware(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	var hosts []*fleet.Host
+	for i := 0; i < 3; i++ {
+		id := strconv.Itoa(i)
+		hosts = append(hosts, test.NewHost(t, ds, "host"+id, "", "key"+id, "uuid"+id, time.Now()))
+	}
+
+	for i, host := range hosts {
+		host.HostSoftware = fleet.HostSoftware{
+			Modified: true,
+			Software: []fleet.Software{
+				{Name: "fast", Version: "1.0", Source: "apps"},
+				{Name: "slow", Version: "1.0", Source: "apps"},
+			},
+		}
+		if i == 0 {
+			host.HostSoftware.Software = append(host.HostSoftware.Software, fleet.Software{Name: "single", Version: "1.0", Source: "apps"})
+		}
+		require.NoError(t, ds.SaveHostSoftware(host))
+	}
+
+	// The slow package was first seen a month ago and only reached the
+	// hosts over time.
+	_, err := ds.db.Exec(`UPDATE software SET first_seen_at = NOW() - INTERVAL 30 DAY WHERE name = 'slow'`)
+	require.NoError(t, err)
+	_, err = ds.db.Exec(`UPDATE software SET first_seen_at = NOW() - INTERVAL 1 HOUR WHERE name = 'fast'`)
+	require.NoError(t, err)
+
+	software, err := ds.RapidlySpreadingSoftware(24*time.Hour, 2)
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "fast", software[0].Name)
+
+	software, err = ds.RapidlySpreadingSoftware(24*time.Hour, 1)
+	require.NoError(t, err)
+	require.Len(t, software, 2)
+	assert.Equal(t, "fast", software[0].Name)
+	assert.Equal(t, "single", software[1].Name)
+
+	software, err = ds.RapidlySpreadingSoftware(time.Minute, 1)
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "single", software[0].Name)
+}
+
+func TestGetOrGenerateSoftwareIdPrepared(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	var software []fleet.Software
+	for i := 0; i < 2*softwareBatchSize+10; i++ {
+		software = append(software, fleet.Software{
+			Name:    fmt.Sprintf("software%d", i),
+			Version: "1.0",
+			Source:  "deb_packages",
+		})
+	}
+	// Half of the software already exists before either path runs.
+	for _, s := range software[:len(software)/2] {
+		_, err := ds.db.Exec(`INSERT INTO software (name, version, source) VALUES (?, ?, ?)`, s.Name, s.Version, s.Source)
+		require.NoError(t, err)
+	}
+
+	start := time.Now()
+	prepared := make(map[string]uint, len(software))
+	require.NoError(t, ds.withRetryTxx(func(tx *sqlx.Tx) error {
+		for _, s := range software {
+			id, err := ds.getOrGenerateSoftwareId(tx, s)
+			if err != nil {
+				return err
+			}
+			prepared[softwareToUniqueString(s)] = id
+		}
+		return nil
+	}))
+	t.Logf("prepared: resolved %d software in %s", len(software), time.Since(start))
+
+	start = time.Now()
+	var batched map[string]uint
+	require.NoError(t, ds.withRetryTxx(func(tx *sqlx.Tx) error {
+		var err error
+		batched, err = ds.getOrGenerateSoftwareIDs(tx, software)
+		return err
+	}))
+	t.Logf("batched: resolved %d software in %s", len(software), time.Since(start))
+
+	assert.Equal(t, prepared, batched)
+
+	var count int
+	require.NoError(t, ds.db.Get(&count, `SELECT COUNT(*) FROM software`))
+	assert.Equal(t, len(software), count)
+}
+
+func TestPreparexConcurrent(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	var wg sync.WaitGroup
+	stmts := make([]*sqlx.Stmt, 10)
+	for i := range stmts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stmt, err := ds.preparex(selectSoftwareIDStmt)
+			assert.NoError(t, err)
+			stmts[i] = stmt
+		}(i)
+	}
+	wg.Wait()
+
+	for _, stmt := range stmts {
+		assert.Same(t, stmts[0], stmt)
+	}
+	assert.Len(t, ds.stmts, 1)
+}
+
+func TestRepairZeroSoftwareIDs(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	host1.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{{Name: "foo", Version: "1.0", Source: "deb_packages"}},
+	}
+	host2.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{{Name: "bar", Version: "1.0", Source: "deb_packages"}},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	report, err := ds.RepairZeroSoftwareIDs()
+	require.NoError(t, err)
+	assert.Equal(t, fleet.ZeroSoftwareIDsRepair{}, report)
+
+	_, err = ds.db.Exec(`INSERT INTO host_software (host_id, software_id) VALUES (?, 0)`, host1.ID)
+	require.NoError(t, err)
+
+	report, err = ds.RepairZeroSoftwareIDs()
+	require.NoError(t, err)
+	assert.Equal(t, fleet.ZeroSoftwareIDsRepair{DeletedCount: 1, RefetchHostIDs: []uint{host1.ID}}, report)
+
+	var count int
+	require.NoError(t, ds.db.Get(&count, `SELECT COUNT(*) FROM host_software WHERE software_id = 0`))
+	assert.Zero(t, count)
+	require.NoError(t, ds.db.Get(&count, `SELECT COUNT(*) FROM host_software`))
+	assert.Equal(t, 2, count)
+
+	h1, err := ds.Host(host1.ID)
+	require.NoError(t, err)
+	assert.True(t, h1.RefetchRequested)
+	h2, err := ds.Host(host2.ID)
+	require.NoError(t, err)
+	assert.False(t, h2.RefetchRequested)
+}
+
+func TestLoadHostSoftwareWithVulnerabilities(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "sudo", Version: "1.8.31", Source: "deb_packages"},
+			{Name: "curl", Version: "7.74.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	var sudoID uint
+	require.NoError(t, ds.db.Get(&sudoID, `SELECT id FROM software WHERE name = 'sudo'`))
+	_, err := ds.db.Exec(
+		`INSERT INTO software_cve (software_id, cve) VALUES (?, 'CVE-2021-3156'), (?, 'CVE-2019-18634')`,
+		sudoID, sudoID,
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, ds.LoadHostSoftwareWithVulnerabilities(host))
+	require.Len(t, host.Software, 2)
+
+	vulnerabilities := make(map[string]fleet.VulnerabilitiesSlice)
+	for _, s := range host.Software {
+		vulnerabilities[s.Name] = s.Vulnerabilities
+	}
+	assert.Equal(t, fleet.VulnerabilitiesSlice{{CVE: "CVE-2019-18634"}, {CVE: "CVE-2021-3156"}}, vulnerabilities["sudo"])
+	assert.NotNil(t, vulnerabilities["curl"])
+	assert.Empty(t, vulnerabilities["curl"])
+}
+
+func TestSoftwareActivityTimeline(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+
+	// Saving software records history events.
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	var actions []string
+	require.NoError(t, ds.db.Select(&actions, `SELECT action FROM host_software_history WHERE host_id = ? ORDER BY id`, host.ID))
+	assert.Equal(t, []string{
+		fleet.SoftwareHistoryInstalled,
+		fleet.SoftwareHistoryInstalled,
+		fleet.SoftwareHistoryRemoved,
+	}, actions)
+	_, err := ds.db.Exec(`DELETE FROM host_software_history`)
+	require.NoError(t, err)
+
+	base := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	events := []struct {
+		at     time.Time
+		action string
+	}{
+		{base.Add(-time.Minute), fleet.SoftwareHistoryInstalled}, // before since
+		{base, fleet.SoftwareHistoryInstalled},
+		{base.Add(10 * time.Minute), fleet.SoftwareHistoryInstalled},
+		{base.Add(59 * time.Minute), fleet.SoftwareHistoryRemoved},
+		{base.Add(2*time.Hour + 30*time.Minute), fleet.SoftwareHistoryRemoved},
+	}
+	for _, e := range events {
+		_, err := ds.db.Exec(
+			`INSERT INTO host_software_history (host_id, software_id, name, version, source, action, created_at) VALUES (?, 1, 'foo', '1.0', 'deb_packages', ?, ?)`,
+			host.ID, e.action, e.at,
+		)
+		require.NoError(t, err)
+	}
+
+	buckets, err := ds.SoftwareActivityTimeline(time.Hour, base)
+	require.NoError(t, err)
+	assert.Equal(t, []fleet.SoftwareActivityBucket{
+		{Start: base, Installs: 2, Removals: 1},
+		{Start: base.Add(2 * time.Hour), Installs: 0, Removals: 1},
+	}, buckets)
+
+	_, err = ds.SoftwareActivityTimeline(0, base)
+	require.Error(t, err)
+}
+
+func TestMergeHostSoftware(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	oldHost := test.NewHost(t, ds, "old", "", "oldkey", "olduuid", time.Now())
+	newHost := test.NewHost(t, ds, "new", "", "newkey", "newuuid", time.Now())
+	otherHost := test.NewHost(t, ds, "other", "", "otherkey", "otheruuid", time.Now())
+
+	oldHost.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages", Arch: "i386"},
+			{Name: "bar", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	newHost.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages", Arch: "amd64"},
+			{Name: "baz", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	otherHost.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "qux", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	for _, host := range []*fleet.Host{oldHost, newHost, otherHost} {
+		require.NoError(t, ds.SaveHostSoftware(host))
+	}
+
+	require.Error(t, ds.MergeHostSoftware(newHost.ID, newHost.ID, false))
+
+	software := func(host *fleet.Host) map[string]string {
+		require.NoError(t, ds.LoadHostSoftware(host))
+		result := make(map[string]string)
+		for _, s := range host.Software {
+			result[s.Name] = s.Arch
+		}
+		return result
+	}
+	historyCount := func(host *fleet.Host) int {
+		var count int
+		require.NoError(t, ds.db.Get(&count, `SELECT COUNT(*) FROM host_software_history WHERE host_id = ?`, host.ID))
+		return count
+	}
+
+	require.NoError(t, ds.MergeHostSoftware(oldHost.ID, newHost.ID, false))
+	// foo is deduped, keeping the details of the new host.
+	assert.Equal(t, map[string]string{"foo": "amd64", "bar": "", "baz": ""}, software(newHost))
+	assert.Equal(t, 4, historyCount(newHost))
+	assert.Equal(t, map[string]string{"foo": "i386", "bar": ""}, software(oldHost))
+	assert.Equal(t, 2, historyCount(oldHost))
+
+	// Merging again doesn't duplicate the software.
+	require.NoError(t, ds.MergeHostSoftware(oldHost.ID, newHost.ID, true))
+	assert.Equal(t, map[string]string{"foo": "amd64", "bar": "", "baz": ""}, software(newHost))
+	assert.Empty(t, software(oldHost))
+	assert.Zero(t, historyCount(oldHost))
+
+	assert.Equal(t, map[string]string{"qux": ""}, software(otherHost))
+}
+
+func TestVerifySoftwareChecksums(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "0.0.1", Source: "deb_packages"},
+			{Name: "bar", Version: "0.0.2", Source: "deb_packages"},
+			{Name: "baz", Version: "0.0.3", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	mismatched, err := ds.VerifySoftwareChecksums()
+	require.NoError(t, err)
+	assert.Equal(t, 0, mismatched)
+
+	// The checksum backfilled by the migration, before the software had
+	// identifiers, matches the computed one.
+	_, err = ds.db.Exec(`
+		UPDATE software
+		SET checksum = UNHEX(MD5(CONCAT(name, CHAR(0), version, CHAR(0), source, CHAR(0), '', CHAR(0), '', CHAR(0), '')))
+		WHERE name = 'foo'
+	`)
+	require.NoError(t, err)
+	_, err = ds.db.Exec(`UPDATE software SET checksum = UNHEX(MD5('wrong')) WHERE name = 'bar'`)
+	require.NoError(t, err)
+
+	mismatched, err = ds.VerifySoftwareChecksums()
+	require.NoError(t, err)
+	assert.Equal(t, 1, mismatched)
+
+	// Verifying doesn't repair the checksums.
+	mismatched, err = ds.VerifySoftwareChecksums()
+	require.NoError(t, err)
+	assert.Equal(t, 1, mismatched)
+}
+
+func TestVerifySoftwareChecksumsIdentifiers(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	// Two extensions sharing the name and version are told apart by their
+	// extension IDs.
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Adblock", Version: "1.0", Source: "chrome_extensions", ExtensionID: "aaaa", Browser: "chrome"},
+			{Name: "Adblock", Version: "1.0", Source: "chrome_extensions", ExtensionID: "bbbb", Browser: "chrome"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	var checksums [][]byte
+	require.NoError(t, ds.db.Select(&checksums, `SELECT checksum FROM software WHERE name = 'Adblock' ORDER BY extension_id`))
+	require.Len(t, checksums, 2)
+	assert.NotEqual(t, checksums[0], checksums[1])
+
+	mismatched, err := ds.VerifySoftwareChecksums()
+	require.NoError(t, err)
+	assert.Equal(t, 0, mismatched)
+
+	// Swapping the checksums of the extensions is detected.
+	_, err = ds.db.Exec(`UPDATE software SET checksum = ? WHERE extension_id = 'aaaa'`, checksums[1])
+	require.NoError(t, err)
+	_, err = ds.db.Exec(`UPDATE software SET checksum = ? WHERE extension_id = 'bbbb'`, checksums[0])
+	require.NoError(t, err)
+
+	mismatched, err = ds.VerifySoftwareChecksums()
+	require.NoError(t, err)
+	assert.Equal(t, 2, mismatched)
+}
+
+func TestHostSoftwareAddedSince(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	// Move the first install back in time.
+	installedAt := time.Now().Add(-48 * time.Hour)
+	_, err := ds.db.Exec(`UPDATE host_software_history SET created_at = ? WHERE host_id = ?`, installedAt, host.ID)
+	require.NoError(t, err)
+
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "foo", Version: "1.0", Source: "deb_packages"},
+			{Name: "bar", Version: "2.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	software, err := ds.HostSoftwareAddedSince(host.ID, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "bar", software[0].Name)
+
+	software, err = ds.HostSoftwareAddedSince(host.ID, installedAt.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, software, 2)
+
+	software, err = ds.HostSoftwareAddedSince(host.ID, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, software)
+}
+
+func TestSaveHostSoftwareBatched(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host1 := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host2 := test.NewHost(t, ds, "host2", "", "host2key", "host2uuid", time.Now())
+
+	// More software than fits in a single batch, half of it already
+	// stored for another host.
+	var software []fleet.Software
+	for i := 0; i < 2*softwareBatchSize+10; i++ {
+		software = append(software, fleet.Software{
+			Name:    fmt.Sprintf("software%d", i),
+			Version: "1.0",
+			Source:  "deb_packages",
+			Arch:    "amd64",
+		})
+	}
+	host2.HostSoftware = fleet.HostSoftware{Modified: true, Software: software[:len(software)/2]}
+	require.NoError(t, ds.SaveHostSoftware(host2))
+
+	host1.HostSoftware = fleet.HostSoftware{Modified: true, Software: software}
+	require.NoError(t, ds.SaveHostSoftware(host1))
+
+	require.NoError(t, ds.LoadHostSoftware(host1))
+	require.Len(t, host1.Software, len(software))
+	for _, s := range host1.Software {
+		assert.NotZero(t, s.ID)
+		assert.Equal(t, "amd64", s.Arch)
+	}
+
+	var count int
+	require.NoError(t, ds.db.Get(&count, `SELECT COUNT(*) FROM software`))
+	assert.Equal(t, len(software), count)
+	require.NoError(t, ds.db.Get(&count, `SELECT COUNT(*) FROM host_software_history WHERE host_id = ? AND action = ?`, host1.ID, fleet.SoftwareHistoryInstalled))
+	assert.Equal(t, len(software), count)
+}
+
+func TestSoftwareCPEAndCVEs(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "sudo", Version: "1.8.31", Source: "deb_packages"},
+			{Name: "curl", Version: "7.74.0", Source: "deb_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	software, err := ds.ListSoftwareWithoutCPE()
+	require.NoError(t, err)
+	require.Len(t, software, 2)
+	ids := make(map[string]uint)
+	for _, s := range software {
+		ids[s.Name] = s.ID
+	}
+
+	require.NoError(t, ds.AddCPEForSoftware(ids["sudo"], "cpe:2.3:a:*:sudo:1.8.31:*:*:*:*:*:*:*"))
+	require.NoError(t, ds.AddCPEForSoftware(ids["sudo"], "cpe:2.3:a:sudo_project:sudo:1.8.31:*:*:*:*:*:*:*"))
+
+	software, err = ds.ListSoftwareWithoutCPE()
+	require.NoError(t, err)
+	require.Len(t, software, 1)
+	assert.Equal(t, "curl", software[0].Name)
+
+	cpes, err := ds.ListSoftwareCPEs()
+	require.NoError(t, err)
+	assert.Equal(t, []fleet.SoftwareCPE{{SoftwareID: ids["sudo"], CPE: "cpe:2.3:a:sudo_project:sudo:1.8.31:*:*:*:*:*:*:*"}}, cpes)
+
+	cves := func() fleet.VulnerabilitiesSlice {
+		require.NoError(t, ds.LoadHostSoftwareWithVulnerabilities(host))
+		for _, s := range host.Software {
+			if s.Name == "sudo" {
+				return s.Vulnerabilities
+			}
+		}
+		return nil
+	}
+
+	require.NoError(t, ds.ReplaceSoftwareCVEs(ids["sudo"], []string{"CVE-2021-3156", "CVE-2019-18634"}))
+	assert.Equal(t, fleet.VulnerabilitiesSlice{{CVE: "CVE-2019-18634"}, {CVE: "CVE-2021-3156"}}, cves())
+
+	require.NoError(t, ds.ReplaceSoftwareCVEs(ids["sudo"], []string{"CVE-2021-3156"}))
+	assert.Equal(t, fleet.VulnerabilitiesSlice{{CVE: "CVE-2021-3156"}}, cves())
+
+	require.NoError(t, ds.ReplaceSoftwareCVEs(ids["sudo"], nil))
+	assert.Empty(t, cves())
+}
+
+func TestSaveHostSoftwareBundleIdentifier(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Notes.app", Version: "4.9", Source: "apps", BundleIdentifier: "com.apple.Notes"},
+			{Name: "Notes.app", Version: "4.9", Source: "apps", BundleIdentifier: "com.example.notes"},
+			{Name: "requests", Version: "2.25.1", Source: "python_packages"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	var count int
+	require.NoError(t, ds.db.Get(&count, `SELECT COUNT(*) FROM software`))
+	assert.Equal(t, 3, count)
+
+	require.NoError(t, ds.LoadHostSoftware(host))
+	bundles := make(map[string]bool)
+	for _, s := range host.Software {
+		bundles[s.BundleIdentifier] = true
+	}
+	assert.Equal(t, map[string]bool{"com.apple.Notes": true, "com.example.notes": true, "": true}, bundles)
+
+	// Saving the same software again changes nothing.
+	require.NoError(t, ds.SaveHostSoftware(host))
+	require.NoError(t, ds.db.Get(&count, `SELECT COUNT(*) FROM host_software WHERE host_id = ?`, host.ID))
+	assert.Equal(t, 3, count)
+}
+
+func TestSaveHostSoftwareLastOpenedAt(t *testing.T) {
+	ds := CreateMySQLDS(t)
+	defer ds.Close()
+
+	host := test.NewHost(t, ds, "host1", "", "host1key", "host1uuid", time.Now())
+	opened := time.Now().Add(-100 * 24 * time.Hour).UTC().Truncate(time.Second)
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Pages.app", Version: "11.1", Source: "apps", LastOpenedAt: &opened},
+			{Name: "Numbers.app", Version: "11.1", Source: "apps"},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	lastOpened := func() map[string]*time.Time {
+		require.NoError(t, ds.LoadHostSoftware(host))
+		result := make(map[string]*time.Time)
+		for _, s := range host.Software {
+			result[s.Name] = s.LastOpenedAt
+		}
+		return result
+	}
+
+	loaded := lastOpened()
+	require.NotNil(t, loaded["Pages.app"])
+	assert.True(t, opened.Equal(*loaded["Pages.app"]))
+	assert.Nil(t, loaded["Numbers.app"])
+
+	reopened := time.Now().UTC().Truncate(time.Second)
+	host.HostSoftware = fleet.HostSoftware{
+		Modified: true,
+		Software: []fleet.Software{
+			{Name: "Pages.app", Version: "11.1", Source: "apps", LastOpenedAt: &opened},
+			{Name: "Numbers.app", Version: "11.1", Source: "apps", LastOpenedAt: &reopened},
+		},
+	}
+	require.NoError(t, ds.SaveHostSoftware(host))
+
+	loaded = lastOpened()
+	require.NotNil(t, loaded["Numbers.app"])
+	assert.True(t, reopened.Equal(*loaded["Numbers.app"]))
+}
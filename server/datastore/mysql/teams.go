@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 
@@ -16,14 +17,16 @@ func (d *Datastore) NewTeam(team *fleet.Team) (*fleet.Team, error) {
 	INSERT INTO teams (
 		name,
 		agent_options,
-		description
-	) VALUES ( ?, ?, ? )
+		description,
+		enable_software_inventory
+	) VALUES ( ?, ?, ?, ? )
 	`
 	result, err := d.db.Exec(
 		query,
 		team.Name,
 		team.AgentOptions,
 		team.Description,
+		team.EnableSoftwareInventory,
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "insert team")
@@ -77,13 +80,16 @@ func (d *Datastore) DeleteTeam(tid uint) error {
 }
 
 func (d *Datastore) TeamByName(name string) (*fleet.Team, error) {
-	sql := `
+	query := `
 		SELECT * FROM teams
 			WHERE name = ?
 	`
 	team := &fleet.Team{}
 
-	if err := d.db.Get(team, sql, name); err != nil {
+	if err := d.db.Get(team, query, name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFound("Team").WithName(name)
+		}
 		return nil, errors.Wrap(err, "select team")
 	}
 
@@ -149,10 +155,11 @@ func (d *Datastore) SaveTeam(team *fleet.Team) (*fleet.Team, error) {
 		UPDATE teams SET
 			name = ?,
 			agent_options = ?,
-			description = ?
+			description = ?,
+			enable_software_inventory = ?
 		WHERE id = ?
 	`
-	_, err := d.db.Exec(query, team.Name, team.AgentOptions, team.Description, team.ID)
+	_, err := d.db.Exec(query, team.Name, team.AgentOptions, team.Description, team.EnableSoftwareInventory, team.ID)
 	if err != nil {
 		return nil, errors.Wrap(err, "saving team")
 	}
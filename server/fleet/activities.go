@@ -30,6 +30,12 @@ const (
 	ActivityTypeDeletedTeam = "deleted_team"
 	// ActivityTypeLiveQuery is the activity type for live queries
 	ActivityTypeLiveQuery = "live_query"
+	// ActivityTypeSuppressedVulnerability is the activity type for CVEs of
+	// software suppressed as false positives
+	ActivityTypeSuppressedVulnerability = "suppressed_vulnerability"
+	// ActivityTypeDeletedVulnerabilitySuppression is the activity type for
+	// deleted suppressions of CVEs
+	ActivityTypeDeletedVulnerabilitySuppression = "deleted_vulnerability_suppression"
 )
 
 type ActivitiesStore interface {
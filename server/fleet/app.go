@@ -178,6 +178,49 @@ type AppConfig struct {
 
 	// AgentOptions is the global agent options, including overrides.
 	AgentOptions *json.RawMessage `db:"agent_options"`
+
+	// EnableSoftwareInventory defines whether the software installed on the
+	// hosts is collected. Teams can override it.
+	EnableSoftwareInventory bool `db:"enable_software_inventory"`
+
+	// SoftwareInstalledWebhookEnabled defines whether a webhook is fired when
+	// software matching the watchlist is installed on a host.
+	SoftwareInstalledWebhookEnabled bool `db:"software_installed_webhook_enabled"`
+	// SoftwareInstalledWebhookURL is the URL the webhook is posted to.
+	SoftwareInstalledWebhookURL string `db:"software_installed_webhook_url"`
+	// SoftwareInstalledWebhookWatchlist is the software firing the webhook.
+	SoftwareInstalledWebhookWatchlist SoftwareWatchlist `db:"software_installed_webhook_watchlist"`
+
+	// SoftwareNormalizationRules are the rules renaming the software reported
+	// by the hosts to canonical names.
+	SoftwareNormalizationRules SoftwareNormalizationRules `db:"software_normalization_rules"`
+
+	// VulnerabilitiesWebhookEnabled defines whether a webhook is fired when
+	// vulnerabilities affecting hosts are detected.
+	VulnerabilitiesWebhookEnabled bool `db:"vulnerabilities_webhook_enabled"`
+	// VulnerabilitiesWebhookURL is the URL the webhook is posted to.
+	VulnerabilitiesWebhookURL string `db:"vulnerabilities_webhook_url"`
+	// VulnerabilitiesWebhookHostBatchSize is the maximum number of hosts
+	// listed per request, 0 lists all the hosts in a single request.
+	VulnerabilitiesWebhookHostBatchSize int `db:"vulnerabilities_webhook_host_batch_size"`
+	// VulnerabilitiesWebhookMinCVSSScore is the CVSS base score from which
+	// vulnerabilities fire the webhook. Vulnerabilities without a score only
+	// fire it if it is 0.
+	VulnerabilitiesWebhookMinCVSSScore float64 `db:"vulnerabilities_webhook_min_cvss_score"`
+
+	// JiraIntegrationEnabled defines whether a Jira issue is created when
+	// vulnerabilities affecting hosts are detected.
+	JiraIntegrationEnabled bool `db:"jira_integration_enabled"`
+	// JiraURL is the URL of the Jira instance, eg. https://example.atlassian.net.
+	JiraURL string `db:"jira_url"`
+	// JiraUsername is the user the issues are created as. Jira Cloud
+	// authenticates with the username and API token, Jira Server and Data
+	// Center with a personal access token as API token and no username.
+	JiraUsername string `db:"jira_username"`
+	// JiraAPIToken is the API token of the user.
+	JiraAPIToken string `db:"jira_api_token"`
+	// JiraProjectKey is the key of the project the issues are created in.
+	JiraProjectKey string `db:"jira_project_key"`
 }
 
 func (c AppConfig) AuthzType() string {
@@ -269,6 +312,14 @@ type AppConfigPayload struct {
 	SMTPTest *bool `json:"smtp_test,omitempty"`
 	// SSOSettings is single sign on settings
 	SSOSettings *SSOSettingsPayload `json:"sso_settings"`
+	// Features is the settings of the optional features.
+	Features *Features `json:"features"`
+	// WebhookSettings is the settings of the webhooks fired by Fleet.
+	WebhookSettings *WebhookSettings `json:"webhook_settings"`
+	// SoftwareSettings is the settings of the software inventory.
+	SoftwareSettings *SoftwareSettings `json:"software_settings"`
+	// Integrations is the settings of the third party integrations.
+	Integrations *Integrations `json:"integrations"`
 }
 
 // OrgInfo contains general info about the organization using Fleet.
@@ -290,6 +341,54 @@ type HostExpirySettings struct {
 	HostExpiryWindow  *int  `json:"host_expiry_window,omitempty"`
 }
 
+// Features contains the settings of the optional features.
+type Features struct {
+	EnableSoftwareInventory *bool `json:"enable_software_inventory,omitempty"`
+}
+
+// SoftwareSettings contains the settings of the software inventory.
+type SoftwareSettings struct {
+	NormalizationRules *SoftwareNormalizationRules `json:"normalization_rules,omitempty"`
+}
+
+// WebhookSettings contains the settings of the webhooks fired by Fleet.
+type WebhookSettings struct {
+	SoftwareInstalledWebhook *SoftwareInstalledWebhookSettings `json:"software_installed_webhook,omitempty"`
+	VulnerabilitiesWebhook   *VulnerabilitiesWebhookSettings   `json:"vulnerabilities_webhook,omitempty"`
+}
+
+// SoftwareInstalledWebhookSettings contains the settings of the webhook fired
+// when software matching the watchlist is installed on a host.
+type SoftwareInstalledWebhookSettings struct {
+	Enable         *bool              `json:"enable_software_installed_webhook,omitempty"`
+	DestinationURL *string            `json:"destination_url,omitempty"`
+	Watchlist      *SoftwareWatchlist `json:"watchlist,omitempty"`
+}
+
+// VulnerabilitiesWebhookSettings contains the settings of the webhook fired
+// when vulnerabilities affecting hosts are detected.
+type VulnerabilitiesWebhookSettings struct {
+	Enable         *bool    `json:"enable_vulnerabilities_webhook,omitempty"`
+	DestinationURL *string  `json:"destination_url,omitempty"`
+	HostBatchSize  *int     `json:"host_batch_size,omitempty"`
+	MinCVSSScore   *float64 `json:"min_cvss_score,omitempty"`
+}
+
+// Integrations contains the settings of the third party integrations.
+type Integrations struct {
+	Jira *JiraIntegration `json:"jira,omitempty"`
+}
+
+// JiraIntegration contains the settings of the Jira integration, creating an
+// issue when vulnerabilities affecting hosts are detected.
+type JiraIntegration struct {
+	Enable     *bool   `json:"enable_jira_integration,omitempty"`
+	URL        *string `json:"url,omitempty"`
+	Username   *string `json:"username,omitempty"`
+	APIToken   *string `json:"api_token,omitempty"`
+	ProjectKey *string `json:"project_key,omitempty"`
+}
+
 type HostSettings struct {
 	AdditionalQueries *json.RawMessage `json:"additional_queries"`
 }
@@ -38,9 +38,9 @@ type CampaignStore interface {
 // methods
 type CampaignService interface {
 	// NewDistributedQueryCampaign creates a new distributed query campaign with
-	// the provided query (or the query referenced by ID) and host/label targets
-	// (specified by name).
-	NewDistributedQueryCampaignByNames(ctx context.Context, queryString string, queryID *uint, hosts []string, labels []string) (*DistributedQueryCampaign, error)
+	// the provided query (or the query referenced by ID) and host/label/team
+	// targets (specified by name).
+	NewDistributedQueryCampaignByNames(ctx context.Context, queryString string, queryID *uint, hosts []string, labels []string, teams []string) (*DistributedQueryCampaign, error)
 
 	// NewDistributedQueryCampaign creates a new distributed query campaign
 	// with the provided query (or the query referenced by ID) and host/label targets
@@ -111,6 +111,12 @@ type HostListOptions struct {
 	AdditionalFilters []string
 	// StatusFilter selects the online status of the hosts.
 	StatusFilter HostStatus
+	// TeamFilter selects the hosts of the team.
+	TeamFilter *uint
+	// PlatformFilter selects the hosts with the platform.
+	PlatformFilter string
+	// LabelFilter selects the hosts that are members of the label.
+	LabelFilter *uint
 }
 
 type HostUser struct {
@@ -178,6 +184,10 @@ type Host struct {
 	// Users currently in the host
 	Users []HostUser `json:"users,omitempty"`
 
+	// WindowsUpdates are the updates installed on Windows hosts, eg.
+	// KB5005033. They are saved only if non-nil.
+	WindowsUpdates []string `json:"-" db:"-"`
+
 	Modified bool `json:"-"`
 }
 
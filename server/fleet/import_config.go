@@ -129,11 +129,15 @@ type YARAConfig struct {
 	FilePaths  map[string][]string `json:"file_paths"`
 }
 
+const (
+	DecoratorsKind = "decorators"
+)
+
 // Decorator section of osquery config each section contains rows of decorator
 // queries.
 type DecoratorConfig struct {
-	Load   []string `json:"load"`
-	Always []string `json:"always"`
+	Load   []string `json:"load,omitempty"`
+	Always []string `json:"always,omitempty"`
 	/*
 		Interval maps a string representation of a numeric interval to a set
 		of decorator queries.
@@ -145,7 +149,7 @@ type DecoratorConfig struct {
 				}
 			}
 	*/
-	Interval map[string][]string `json:"interval"`
+	Interval map[string][]string `json:"interval,omitempty"`
 }
 
 type OptionNameToValueMap map[string]interface{}
@@ -52,6 +52,15 @@ type QueryContent struct {
 type PermissiveQueryContent struct {
 	QueryContent
 	Interval interface{} `json:"interval"`
+	// LoggingDestination is a Fleet specific extension naming the logging
+	// destination that should receive the results of this query.
+	LoggingDestination *string `json:"logging_destination,omitempty"`
+	// Value is a Fleet specific extension holding the alerting threshold or
+	// value attached to the query. Numbers and strings are accepted.
+	Value interface{} `json:"value,omitempty"`
+	// ObserverCanRun is a Fleet specific extension allowing users with the
+	// observer role to run the query live.
+	ObserverCanRun *bool `json:"observer_can_run,omitempty"`
 }
 
 // Queries is a helper which represents the format of a set of queries in a pack.
@@ -117,6 +117,7 @@ type PackSpec struct {
 
 type PackSpecTargets struct {
 	Labels []string `json:"labels"`
+	Teams  []string `json:"teams,omitempty"`
 }
 
 type PackSpecQuery struct {
@@ -130,6 +131,21 @@ type PackSpecQuery struct {
 	Platform    *string `json:"platform,omitempty"`
 	Version     *string `json:"version,omitempty"`
 	Denylist    *bool   `json:"denylist,omitempty"`
+	// LoggingDestination names the logging destination that should receive
+	// the results of this query. When nil the default destination is used.
+	LoggingDestination *string `json:"logging_destination,omitempty"`
+	// Value is the alerting threshold or value attached to the query, used by
+	// the consumers of its results. It is not interpreted by Fleet.
+	Value *string `json:"value,omitempty"`
+}
+
+// NormalizedPackSpecQuery returns the fields of the scheduled query stored by
+// applying a pack, to compare queries with the ones returned by the server.
+// The logging destination and value are not stored.
+func NormalizedPackSpecQuery(query PackSpecQuery) PackSpecQuery {
+	query.LoggingDestination = nil
+	query.Value = nil
+	return query
 }
 
 // PackTarget targets a pack to a host, label, or team.
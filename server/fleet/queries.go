@@ -116,6 +116,12 @@ type QuerySpec struct {
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
 	Query       string `json:"query"`
+	// ObserverCanRun allows users with the observer role to run the query
+	// live.
+	ObserverCanRun bool `json:"observer_can_run,omitempty"`
+	// Annotations holds custom metadata about the query that is kept in the
+	// spec files but not stored by Fleet.
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
 }
 
 func LoadQueriesFromYaml(yml string) ([]*Query, error) {
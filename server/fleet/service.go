@@ -19,6 +19,8 @@ type Service interface {
 	CarveService
 	TeamService
 	ActivitiesService
+	SoftwareService
 	UserRolesService
 	GlobalScheduleService
+	SpecsService
 }
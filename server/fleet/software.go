@@ -1,8 +1,239 @@
 package fleet
 
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet/version"
+)
+
 type SoftwareStore interface {
 	SaveHostSoftware(host *Host) error
 	LoadHostSoftware(host *Host) error
+	// LoadHostSoftwareGrouped loads the software of the host and returns it
+	// keyed by source, each group sorted by name.
+	LoadHostSoftwareGrouped(host *Host) (map[string][]Software, error)
+	// ReplaceAllHostSoftware replaces the full software inventory of the host
+	// with the provided software in a single transaction. Unlike
+	// SaveHostSoftware, which is only called with the software reported by
+	// delta agents, this is meant for agents that report authoritative
+	// snapshots. The changes are recorded the same way, and the newly
+	// installed software is set in host.HostSoftware.Installed.
+	ReplaceAllHostSoftware(host *Host, software []Software) error
+	// SimilarHostsBySoftware returns up to limit hosts ordered by the number
+	// of software items they share with the provided host. The provided host
+	// is never included in the results.
+	SimilarHostsBySoftware(hostID uint, limit int) ([]HostSoftwareSimilarity, error)
+	// SoftwareCatalogStats returns the size of the software catalog and how
+	// much of it was first seen recently.
+	SoftwareCatalogStats() (SoftwareCatalogStats, error)
+	// MarkHostSoftwareUpdates flags the provided software IDs as having an
+	// update available on the host, and clears the flag on all of the host's
+	// other software.
+	MarkHostSoftwareUpdates(hostID uint, outdatedSoftwareIDs []uint) error
+	// RapidlySpreadingSoftware returns the software first seen within the
+	// provided window that is already installed on at least minHosts hosts,
+	// ordered by number of hosts.
+	RapidlySpreadingSoftware(window time.Duration, minHosts int) ([]Software, error)
+	// ListHostSoftware returns the software installed on the host, filtered
+	// by the provided options.
+	ListHostSoftware(hostID uint, opt SoftwareListOptions) ([]Software, error)
+	// MostCommonSoftwareVersions returns, for each software title (name and
+	// source), the version installed on the most hosts along with its host
+	// count. Ties are resolved in favor of the highest version.
+	MostCommonSoftwareVersions(opt ListOptions) ([]Software, error)
+	// RepairZeroSoftwareIDs removes the host_software rows referencing
+	// software ID 0, left by a past race when inserting software, and
+	// requests a refetch of the affected hosts so that their software is
+	// stored again with the correct IDs.
+	RepairZeroSoftwareIDs() (ZeroSoftwareIDsRepair, error)
+	// TagSoftware adds the categories to the software. Categories are
+	// freeform, those the software already has are ignored.
+	TagSoftware(id uint, categories []string) error
+	// ListSoftwareByCategory returns the software tagged with the category.
+	ListSoftwareByCategory(category string, opt ListOptions) ([]Software, error)
+	// ListSoftwareForLabel returns the distinct software installed on the
+	// hosts that are members of the label, with HostsCount set to the number
+	// of those hosts that have it installed.
+	ListSoftwareForLabel(labelID uint, opt SoftwareListOptions) ([]Software, error)
+	// LoadHostSoftwareWithVulnerabilities loads the software of the host like
+	// LoadHostSoftware, with the Vulnerabilities of each software set.
+	LoadHostSoftwareWithVulnerabilities(host *Host) error
+	// LoadSoftwareVulnerabilities sets the Vulnerabilities of each software,
+	// with their CVE meta. CVEs suppressed as false positives are excluded.
+	LoadSoftwareVulnerabilities(software []Software) error
+	// SoftwareActivityTimeline returns the number of software installs and
+	// removals recorded since the provided time, grouped in buckets of the
+	// provided duration. Buckets without activity are omitted.
+	SoftwareActivityTimeline(bucket time.Duration, since time.Time) ([]SoftwareActivityBucket, error)
+	// HostSoftwareBaselineViolations returns the software installed on the
+	// host that is not in the baseline, matching on name and source. The
+	// baseline versions are ignored.
+	HostSoftwareBaselineViolations(hostID uint, baseline []Software) ([]Software, error)
+	// HostSoftwareArchMismatches returns the software installed on the host
+	// whose architecture differs from the host architecture. Software with
+	// an unknown or architecture independent (noarch, all) architecture is
+	// never returned.
+	HostSoftwareArchMismatches(hostID uint, hostArch string) ([]Software, error)
+	// MergeHostSoftware adds the software and software history of the old
+	// host to the new host, for hosts that enrolled again with a new ID.
+	// Software both hosts have keeps the details of the new host. If
+	// clearOld is true the software and history of the old host are
+	// removed.
+	MergeHostSoftware(oldHostID, newHostID uint, clearOld bool) error
+	// LatestObservedSoftwareVersions returns the highest version reported by
+	// any host for each software title, keyed by SoftwareTitle and compared
+	// with CompareSoftwareVersions. The list options paginate over the
+	// titles.
+	LatestObservedSoftwareVersions(opt ListOptions) (map[string]string, error)
+	// SoftwareByID returns the software with the provided ID, including its
+	// note.
+	SoftwareByID(id uint) (*Software, error)
+	// SetSoftwareNote sets the note of the software, replacing any previous
+	// note. An empty note clears it.
+	SetSoftwareNote(id uint, note string) error
+	// SoftwareDriftFromReference compares the software of the host with the
+	// software of the reference host. Extra is the software the host has
+	// and the reference host doesn't, missing is the software the reference
+	// host has and the host doesn't. Different versions of the same software
+	// are in both.
+	SoftwareDriftFromReference(hostID, referenceHostID uint) (extra, missing []Software, err error)
+	// HostSoftwareDowngrades returns the software of the host that was
+	// replaced by a lower version, according to CompareSoftwareVersions,
+	// since the provided time. A version change is a removal followed by an
+	// install of the same software name and source in the host software
+	// history.
+	HostSoftwareDowngrades(hostID uint, since time.Time) ([]SoftwareChange, error)
+	// SoftwareSources returns the distinct sources of the software, sorted.
+	// Blank sources are not included.
+	SoftwareSources() ([]string, error)
+	// HostsWithConflictingSoftwareVersions returns the hosts that have more
+	// than one version of the software with the provided name and source
+	// installed, ordered by ID.
+	HostsWithConflictingSoftwareVersions(name, source string) ([]Host, error)
+	// VerifySoftwareChecksums recomputes the checksum of every software from
+	// its name, version, source and identifiers (bundle identifier, extension
+	// ID and browser) and returns the number of software whose stored
+	// checksum differs or is missing. No data is modified.
+	VerifySoftwareChecksums() (mismatched int, err error)
+	// HostSoftwareAddedSince returns the software installed on the host that
+	// was recorded as installed in the host software history after the
+	// provided time.
+	HostSoftwareAddedSince(hostID uint, since time.Time) ([]Software, error)
+	// ListSoftwareForHostFilter returns the software installed on the hosts
+	// matching the status, team, platform and label filters of the host
+	// options, with the number of those hosts that have it installed.
+	ListSoftwareForHostFilter(filter HostListOptions, opt SoftwareListOptions) ([]Software, error)
+	// ListSoftware returns the software installed on at least one host, with
+	// HostsCount set. The MatchQuery of the options filters on the software
+	// name, and the results can be ordered by name, version or hosts_count.
+	// The host counts are those stored by the last CalculateHostsPerSoftware,
+	// software installed since is only listed after the next calculation.
+	ListSoftware(opt SoftwareListOptions) ([]Software, error)
+	// CalculateHostsPerSoftware stores the number of hosts each software is
+	// installed on, so that it doesn't need to be counted when listing
+	// software. Counts not updated by the calculation, for software no
+	// longer installed on any host, are removed.
+	CalculateHostsPerSoftware(updatedAt time.Time) error
+	// ListSoftwareByTeam returns the software installed on at least one host
+	// of the team, with HostsCount set to the number of hosts of the team it
+	// is installed on. The options are applied like in ListSoftware.
+	ListSoftwareByTeam(teamID uint, opt SoftwareListOptions) ([]Software, error)
+	// ExportSoftware calls fn for each software listed by ListSoftware, in ID
+	// order, with HostsCount and Vulnerabilities set. The software is read
+	// from the database as fn is called rather than loaded at once, and the
+	// export stops at the first error returned by fn.
+	ExportSoftware(fn func(Software) error) error
+	// CalculateSoftwareTitles stores the software titles, the software
+	// grouped by name and source, with the number of hosts and versions
+	// installed. Titles not updated by the calculation, no longer installed
+	// on any host, are removed.
+	CalculateSoftwareTitles(updatedAt time.Time) error
+	// ListSoftwareTitles returns the software titles stored by the last
+	// CalculateSoftwareTitles with their versions. The MatchQuery of the
+	// options filters on the title name, and the results can be ordered by
+	// name, hosts_count or versions_count.
+	ListSoftwareTitles(opt ListOptions) ([]SoftwareTitleSummary, error)
+	// ListHostSoftwareVersionsByName returns, for every host with software
+	// of the name installed, the installed versions, ordered by host ID.
+	ListHostSoftwareVersionsByName(name string) ([]HostSoftwareVersion, error)
+	// SoftwareInventoryEnabled returns whether the software inventory is
+	// enabled for the hosts of the team, or for the hosts with no team if
+	// teamID is nil.
+	SoftwareInventoryEnabled(teamID *uint) (bool, error)
+	// ListHostSoftwareChanges returns the software installed on and removed
+	// from the host recorded in the host software history, by default from
+	// the most recent change. The MatchQuery of the options filters on the
+	// software name.
+	ListHostSoftwareChanges(hostID uint, opt ListOptions) ([]HostSoftwareChange, error)
+	// ListSoftwareWithoutCPE returns the software that has no CPE yet.
+	ListSoftwareWithoutCPE() ([]Software, error)
+	// AddCPEForSoftware stores the CPE of the software, replacing the
+	// existing one.
+	AddCPEForSoftware(softwareID uint, cpe string) error
+	// ListSoftwareCPEs returns the CPEs of all the software that has one,
+	// except the software with OVAL results, whose vulnerabilities come
+	// from the OVAL definitions of its distribution.
+	ListSoftwareCPEs() ([]SoftwareCPE, error)
+	// ReplaceSoftwareCVEs sets the vulnerabilities of the software to the
+	// provided CVEs. Existing CVEs that are still provided are kept along
+	// with the time they were first found.
+	ReplaceSoftwareCVEs(softwareID uint, cves []string) error
+	// ListHostOSVersions returns the distinct platforms and OS versions of
+	// the hosts.
+	ListHostOSVersions() ([]HostOSVersion, error)
+	// ListSoftwareByHostOS returns the software of the source installed on
+	// the hosts of the platform and OS version.
+	ListSoftwareByHostOS(os HostOSVersion, source string) ([]Software, error)
+	// ReplaceSoftwareOVALResults sets the results of the evaluation of the
+	// software against the OVAL definitions of its distribution, replacing
+	// the existing ones.
+	ReplaceSoftwareOVALResults(softwareID uint, results []SoftwareOVALResult) error
+	// InsertCVEMeta stores the scores of the CVEs, replacing the existing
+	// ones.
+	InsertCVEMeta(meta []CVEMeta) error
+	// ListSoftwareVulnerabilitiesSince returns the vulnerabilities found in
+	// software since the provided time, with their CVSS score, ordered by
+	// CVE and software ID. CVEs suppressed as false positives are excluded.
+	ListSoftwareVulnerabilitiesSince(since time.Time) ([]SoftwareVulnerability, error)
+	// ListHostSoftwareVersionsBySoftwareID returns the hosts that have any of
+	// the software installed, with the installed software, ordered by host
+	// ID.
+	ListHostSoftwareVersionsBySoftwareID(softwareIDs []uint) ([]HostSoftwareVersion, error)
+	// NewSoftwareCVESuppression suppresses the CVE of the software as a false
+	// positive. Suppressed CVEs are not listed in the vulnerabilities of the
+	// software, nor automated.
+	NewSoftwareCVESuppression(suppression *SoftwareCVESuppression) (*SoftwareCVESuppression, error)
+	// SoftwareCVESuppression returns the suppression with the provided ID.
+	SoftwareCVESuppression(id uint) (*SoftwareCVESuppression, error)
+	// ListSoftwareCVESuppressions returns the suppressed CVEs, with the
+	// software and the name of their author, by default from the newest.
+	ListSoftwareCVESuppressions(opt ListOptions) ([]SoftwareCVESuppression, error)
+	// DeleteSoftwareCVESuppression removes the suppression, the CVE is listed
+	// in the vulnerabilities of the software again.
+	DeleteSoftwareCVESuppression(id uint) error
+	// CleanupOrphanedSoftware deletes the software no longer installed on
+	// any host, batchSize rows per statement, and returns the number of
+	// software deleted. The software with notes, categories or CVE
+	// suppressions is kept.
+	CleanupOrphanedSoftware(batchSize int) (deleted int, err error)
+	// HostSoftwareCounts returns the number of distinct software installed
+	// on each host, keyed by host ID. Hosts without software are omitted.
+	// The list options paginate over the hosts, ordered by host ID by
+	// default.
+	HostSoftwareCounts(opt ListOptions) (map[uint]int, error)
+	// ListWindowsHostUpdates returns the Windows hosts with their build
+	// number and the updates installed on them, ordered by host ID.
+	ListWindowsHostUpdates() ([]WindowsHostUpdates, error)
+	// ReplaceHostOperatingSystemVulnerabilities sets the vulnerabilities of
+	// the operating system of the host, replacing the existing ones.
+	// Existing CVEs that are still provided are kept along with the time
+	// they were first found.
+	ReplaceHostOperatingSystemVulnerabilities(hostID uint, vulnerabilities []OperatingSystemVulnerability) error
+	// ListHostOperatingSystemVulnerabilities returns the vulnerabilities of
+	// the operating system of the host, ordered by CVE.
+	ListHostOperatingSystemVulnerabilities(hostID uint) ([]OperatingSystemVulnerability, error)
 }
 
 // Software is a named and versioned piece of software installed on a device.
@@ -14,6 +245,348 @@ type Software struct {
 	Version string `json:"version" db:"version"`
 	// Source is the source of the data (osquery table name).
 	Source string `json:"source" db:"source"`
+	// BundleIdentifier is the bundle identifier of macOS apps, empty for
+	// other software. Apps with the same name and version but different
+	// bundle identifiers are different software.
+	BundleIdentifier string `json:"bundle_identifier,omitempty" db:"bundle_identifier"`
+	// ExtensionID is the identifier of browser extensions in their browser,
+	// empty for other software.
+	ExtensionID string `json:"extension_id,omitempty" db:"extension_id"`
+	// Browser is the browser of browser extensions (eg. chrome, edge,
+	// firefox, safari), empty for other software. Extensions with the same
+	// name and version installed in different browsers are different
+	// software.
+	Browser string `json:"browser,omitempty" db:"browser"`
+	// Epoch is the epoch of RPM packages, nil for packages without one and
+	// for other software. It is not part of the version so that the
+	// software is identified the same as by other package managers.
+	Epoch *uint `json:"epoch,omitempty" db:"epoch"`
+	// Release is the release of RPM packages, empty for other software. As
+	// the epoch, it is kept apart from the version and is stored as first
+	// reported for the software.
+	Release string `json:"release,omitempty" db:"package_release"`
+
+	// SignatureStatus is the code signing status of the software as reported
+	// by the host, or nil if unknown. Since signing is verified on each host,
+	// this is stored per host rather than per software.
+	SignatureStatus *string `json:"signature_status,omitempty" db:"signature_status"`
+	// UpdateAvailable is true if a newer version of the software is known to
+	// exist. It is set per host with MarkHostSoftwareUpdates and is cleared
+	// when the host reports a different version.
+	UpdateAvailable bool `json:"update_available" db:"update_available"`
+	// Managed is true if the software was installed through a package
+	// manager or MDM rather than by the user. It is stored per host.
+	Managed bool `json:"managed" db:"managed"`
+	// Arch is the architecture the software was built for as reported by
+	// the package manager of the host (eg. amd64, i386, noarch), empty if
+	// unknown. It is stored per host.
+	Arch string `json:"arch,omitempty" db:"arch"`
+	// LastOpenedAt is the last time the software was opened on the host, as
+	// reported for macOS apps, nil if unknown. It is stored per host.
+	LastOpenedAt *time.Time `json:"last_opened_at,omitempty" db:"last_opened_at"`
+	// HostsCount is the number of hosts with the software installed. It is
+	// only set by the methods aggregating software across hosts.
+	HostsCount int `json:"hosts_count,omitempty" db:"hosts_count"`
+	// Vulnerabilities are the CVEs known to affect the software. It is only
+	// loaded by LoadHostSoftwareWithVulnerabilities, which sets it to an
+	// empty slice for software without known vulnerabilities.
+	Vulnerabilities VulnerabilitiesSlice `json:"vulnerabilities" db:"-"`
+	// Note is the note left by an admin about the software. It is only
+	// loaded by SoftwareByID.
+	Note string `json:"note,omitempty" db:"note"`
+}
+
+// SoftwareTitleSummary is the software sharing a name and source, across all
+// of its versions.
+type SoftwareTitleSummary struct {
+	ID     uint   `json:"id" db:"id"`
+	Name   string `json:"name" db:"name"`
+	Source string `json:"source" db:"source"`
+	// HostsCount is the number of hosts with any version installed.
+	HostsCount int `json:"hosts_count" db:"hosts_count"`
+	// VersionsCount is the number of versions installed on the hosts.
+	VersionsCount int `json:"versions_count" db:"versions_count"`
+	// Versions are the software of the title, with their version and host
+	// count, from the highest version to the lowest.
+	Versions []Software `json:"versions" db:"-"`
+}
+
+// HostSoftwareVersion is a version of software installed on a host.
+type HostSoftwareVersion struct {
+	HostID     uint   `json:"host_id" db:"host_id"`
+	Hostname   string `json:"hostname" db:"hostname"`
+	SoftwareID uint   `json:"software_id" db:"software_id"`
+	Name       string `json:"name" db:"name"`
+	Version    string `json:"version" db:"version"`
+	Source     string `json:"source" db:"source"`
+}
+
+// HostSoftwareChange is software installed on or removed from a host, as
+// recorded in the host software history. The software is recorded by value,
+// changes are kept after the software is removed from all hosts.
+type HostSoftwareChange struct {
+	Name    string `json:"name" db:"name"`
+	Version string `json:"version" db:"version"`
+	Source  string `json:"source" db:"source"`
+	// Action is SoftwareHistoryInstalled or SoftwareHistoryRemoved.
+	Action    string    `json:"action" db:"action"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// SoftwareIterator calls fn for each software, stopping at the first error
+// returned by fn.
+type SoftwareIterator func(fn func(Software) error) error
+
+// AuthzType implement AuthzTyper to be able to verify access to software
+func (*Software) AuthzType() string {
+	return "software"
+}
+
+type SoftwareService interface {
+	// ListSoftware returns the software installed on at least one host, with
+	// the number of hosts it is installed on. If teamID is set, only the
+	// hosts of that team are considered.
+	ListSoftware(ctx context.Context, teamID *uint, opt SoftwareListOptions) ([]Software, error)
+	// ExportSoftware returns an iterator over the software installed on at
+	// least one host of the organization, with its host count and
+	// vulnerabilities. Authorization is checked when called, the software is
+	// only read when iterating.
+	ExportSoftware(ctx context.Context) (SoftwareIterator, error)
+	// ListSoftwareTitles returns the software installed on the hosts grouped
+	// by name and source, with the breakdown of the installed versions.
+	ListSoftwareTitles(ctx context.Context, opt ListOptions) ([]SoftwareTitleSummary, error)
+	// ListOutdatedSoftwareHosts returns the hosts running a version of the
+	// software with the name lower than minVersion, compared with
+	// version.Compare.
+	ListOutdatedSoftwareHosts(ctx context.Context, name, minVersion string) ([]HostSoftwareVersion, error)
+	// FlushHostSoftware saves the software buffered by the asynchronous
+	// software ingestion, if enabled.
+	FlushHostSoftware(ctx context.Context) error
+	// ListHostSoftwareChanges returns the timeline of the software installed
+	// on and removed from the host.
+	ListHostSoftwareChanges(ctx context.Context, hostID uint, opt ListOptions) ([]HostSoftwareChange, error)
+	// HostSBOM returns the software inventory of the host as a software bill
+	// of materials JSON document in the format.
+	HostSBOM(ctx context.Context, hostID uint, format SBOMFormat) ([]byte, error)
+	// SuppressSoftwareCVE suppresses the CVE of the software as a false
+	// positive, authored by the user of the context.
+	SuppressSoftwareCVE(ctx context.Context, softwareID uint, cve, reason string) (*SoftwareCVESuppression, error)
+	// ListSoftwareCVESuppressions returns the CVEs suppressed as false
+	// positives.
+	ListSoftwareCVESuppressions(ctx context.Context, opt ListOptions) ([]SoftwareCVESuppression, error)
+	// DeleteSoftwareCVESuppression removes the suppression of a CVE.
+	DeleteSoftwareCVESuppression(ctx context.Context, id uint) error
+	// ListHostOperatingSystemVulnerabilities returns the vulnerabilities of
+	// the operating system of the host.
+	ListHostOperatingSystemVulnerabilities(ctx context.Context, hostID uint) ([]OperatingSystemVulnerability, error)
+}
+
+// SBOMFormat is the format of a software bill of materials.
+type SBOMFormat string
+
+const (
+	SBOMFormatCycloneDX SBOMFormat = "cyclonedx"
+	SBOMFormatSPDX      SBOMFormat = "spdx"
+)
+
+// SoftwareCVE is a vulnerability affecting a software.
+type SoftwareCVE struct {
+	// CVE is the identifier of the vulnerability, eg. CVE-2021-3156.
+	CVE string `json:"cve" db:"cve"`
+	// CVSSScore is the CVSS base score of the vulnerability, nil if unknown.
+	CVSSScore *float64 `json:"cvss_score" db:"cvss_score"`
+	// EPSSProbability is the probability of the vulnerability being
+	// exploited in the next 30 days according to the EPSS, nil if unknown.
+	EPSSProbability *float64 `json:"epss_probability" db:"epss_probability"`
+	// CISAKnownExploit is true if the vulnerability is in the CISA Known
+	// Exploited Vulnerabilities catalog.
+	CISAKnownExploit bool `json:"cisa_known_exploit" db:"cisa_known_exploit"`
+}
+
+// CVEMeta is the scores of a CVE used to prioritize its remediation, and
+// whether it is known to be exploited.
+type CVEMeta struct {
+	CVE string `db:"cve"`
+	// CVSSScore is the CVSS v3 base score, or the v2 one for the CVEs
+	// without a v3 score.
+	CVSSScore        *float64 `db:"cvss_score"`
+	EPSSProbability  *float64 `db:"epss_probability"`
+	CISAKnownExploit bool     `db:"cisa_known_exploit"`
+}
+
+// SoftwareVulnerability is a vulnerability found in a software.
+type SoftwareVulnerability struct {
+	SoftwareID uint   `db:"software_id"`
+	Name       string `db:"name"`
+	Version    string `db:"version"`
+	Source     string `db:"source"`
+	CVE        string `db:"cve"`
+	// CVSSScore is the CVSS base score of the vulnerability, nil if unknown.
+	CVSSScore *float64 `db:"cvss_score"`
+}
+
+// SoftwareCVESuppression is a CVE of a software suppressed as a false
+// positive.
+type SoftwareCVESuppression struct {
+	ID         uint   `json:"id" db:"id"`
+	SoftwareID uint   `json:"software_id" db:"software_id"`
+	CVE        string `json:"cve" db:"cve"`
+	// Reason is why the CVE doesn't affect the software.
+	Reason string `json:"reason" db:"reason"`
+	// AuthorID is the user who suppressed the CVE, nil if the user was
+	// deleted.
+	AuthorID *uint `json:"author_id" db:"author_id"`
+	// AuthorName is retrieved with a join when listing the suppressions.
+	AuthorName string `json:"author_name,omitempty" db:"author_name"`
+	// SoftwareName, SoftwareVersion and SoftwareSource are recorded when the
+	// CVE is suppressed, they are kept if the software is deleted.
+	SoftwareName    string    `json:"software_name,omitempty" db:"software_name"`
+	SoftwareVersion string    `json:"software_version,omitempty" db:"software_version"`
+	SoftwareSource  string    `json:"software_source,omitempty" db:"software_source"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// SoftwareCPE is the Common Platform Enumeration name of a software, used
+// to match it against vulnerability databases.
+type SoftwareCPE struct {
+	SoftwareID uint   `db:"software_id"`
+	CPE        string `db:"cpe"`
+}
+
+// HostOSVersion is an operating system version installed on hosts.
+type HostOSVersion struct {
+	Platform  string `json:"platform" db:"platform"`
+	OSVersion string `json:"os_version" db:"os_version"`
+}
+
+// WindowsHostUpdates is a Windows host with the updates installed on it.
+type WindowsHostUpdates struct {
+	HostID uint
+	// Build is the build number of the OS, eg. 19043 for Windows 10 21H1.
+	Build string
+	// KBIDs are the installed updates, eg. KB5005033.
+	KBIDs []string
+}
+
+// OperatingSystemVulnerability is a vulnerability of the operating system of
+// a host, fixed by a security update missing on the host.
+type OperatingSystemVulnerability struct {
+	CVE string `json:"cve" db:"cve"`
+	// ResolvedIn is the update fixing the vulnerability, eg. KB5005033.
+	ResolvedIn string    `json:"resolved_in" db:"resolved_in"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// SoftwareOVALResult is the result of the evaluation of software against an
+// OVAL definition for a CVE.
+type SoftwareOVALResult struct {
+	CVE          string `json:"cve" db:"cve"`
+	DefinitionID string `json:"definition_id" db:"definition_id"`
+	// Vulnerable is true if the installed version is affected by the CVE,
+	// false if it has the fix.
+	Vulnerable bool `json:"vulnerable" db:"vulnerable"`
+}
+
+// VulnerabilitiesSlice is the list of vulnerabilities of a software.
+type VulnerabilitiesSlice []SoftwareCVE
+
+const (
+	// SoftwareSigned is the SignatureStatus of software with a valid code
+	// signature.
+	SoftwareSigned = "signed"
+	// SoftwareUnsigned is the SignatureStatus of software that is unsigned or
+	// has an invalid code signature.
+	SoftwareUnsigned = "unsigned"
+)
+
+// SoftwareListOptions are the options for listing software.
+type SoftwareListOptions struct {
+	ListOptions
+
+	// Managed, if set, only returns the software whose managed flag matches
+	// the value.
+	Managed *bool
+	// Browser, if set, only returns the extensions of the browser, or the
+	// software that isn't a browser extension if empty.
+	Browser *string
+	// KnownExploit, if true, only returns the software with a vulnerability
+	// in the CISA Known Exploited Vulnerabilities catalog.
+	KnownExploit bool
+	// CollapseSources, if true, returns a single entry for software reported
+	// by multiple sources under the same name, from the source with the
+	// highest SoftwareSourcePriority. The entries are paginated once
+	// collapsed.
+	CollapseSources bool
+}
+
+// softwareSourcePriorities ranks the sources by how authoritative their
+// version information is. Unlisted sources have the lowest priority.
+var softwareSourcePriorities = map[string]int{
+	"deb_packages":        3,
+	"rpm_packages":        3,
+	"portage_packages":    3,
+	"homebrew_packages":   3,
+	"chocolatey_packages": 3,
+	"apps":                2,
+	"programs":            2,
+	"python_packages":     1,
+	"npm_packages":        1,
+	"atom_packages":       1,
+}
+
+// SoftwareSourcePriority returns the priority of the source when the same
+// software is reported by several sources, higher priorities win.
+func SoftwareSourcePriority(source string) int {
+	return softwareSourcePriorities[source]
+}
+
+// SoftwareTitle returns the normalized title of the software name, so that
+// the same software reported with different casing or padding is grouped.
+func SoftwareTitle(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// CompareSoftwareVersions compares two versions reported by the source,
+// returning -1, 0 or 1 if a is lower, equal or higher than b. The deb and rpm
+// package versions are compared the way dpkg and rpm do, other versions with
+// version.CompareLoose.
+func CompareSoftwareVersions(source, a, b string) int {
+	switch source {
+	case "deb_packages":
+		return version.CompareDeb(a, b)
+	case "rpm_packages":
+		return version.CompareRPM(a, b)
+	}
+	return version.CompareLoose(a, b)
+}
+
+const (
+	// SoftwareHistoryInstalled is the history action recorded when software
+	// is first reported on a host.
+	SoftwareHistoryInstalled = "installed"
+	// SoftwareHistoryRemoved is the history action recorded when software is
+	// no longer reported on a host.
+	SoftwareHistoryRemoved = "removed"
+)
+
+// SoftwareActivityBucket is the software activity across the fleet during a
+// time bucket.
+type SoftwareActivityBucket struct {
+	// Start is the start of the bucket.
+	Start time.Time `json:"start"`
+	// Installs is the number of times software was installed on a host.
+	Installs uint `json:"installs"`
+	// Removals is the number of times software was removed from a host.
+	Removals uint `json:"removals"`
+}
+
+// SoftwareChange is a change of the installed version of software on a host.
+type SoftwareChange struct {
+	Name       string    `json:"name"`
+	Source     string    `json:"source"`
+	OldVersion string    `json:"old_version"`
+	NewVersion string    `json:"new_version"`
+	ChangedAt  time.Time `json:"changed_at"`
 }
 
 // HostSoftware is the set of software installed on a specific host
@@ -25,4 +598,41 @@ type HostSoftware struct {
 	// data. We track this here because saving the software set is likely to be
 	// an expensive operation.
 	Modified bool `json:"-"`
+	// Installed is the software newly installed on the host, set by the
+	// datastore implementations when the software is saved.
+	Installed []Software `json:"-"`
+}
+
+// HostSoftwareSimilarity is the number of software items a host shares with
+// another host.
+type HostSoftwareSimilarity struct {
+	HostID   uint   `json:"host_id" db:"host_id"`
+	Hostname string `json:"hostname" db:"hostname"`
+	// SharedSoftwareCount is the number of software items both hosts have
+	// installed.
+	SharedSoftwareCount uint `json:"shared_software_count" db:"shared_software_count"`
+}
+
+// SoftwareCatalogStats summarizes the size and growth of the software catalog.
+type SoftwareCatalogStats struct {
+	// SoftwareCount is the number of distinct software items.
+	SoftwareCount uint `json:"software_count" db:"software_count"`
+	// HostSoftwareCount is the number of software installations across all
+	// hosts.
+	HostSoftwareCount uint `json:"host_software_count" db:"host_software_count"`
+	// AddedLastDay is the number of software items first seen in the last 24
+	// hours.
+	AddedLastDay uint `json:"added_last_day" db:"added_last_day"`
+	// AddedLastWeek is the number of software items first seen in the last 7
+	// days.
+	AddedLastWeek uint `json:"added_last_week" db:"added_last_week"`
+}
+
+// ZeroSoftwareIDsRepair reports the changes made by RepairZeroSoftwareIDs.
+type ZeroSoftwareIDsRepair struct {
+	// DeletedCount is the number of host_software rows deleted.
+	DeletedCount uint `json:"deleted_count"`
+	// RefetchHostIDs are the IDs of the hosts that had rows deleted and were
+	// requested to refetch their details.
+	RefetchHostIDs []uint `json:"refetch_host_ids"`
 }
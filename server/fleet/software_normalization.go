@@ -0,0 +1,82 @@
+package fleet
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// SoftwareNormalizationRule renames the software reported under a name
+// matching the pattern to the canonical name, so that the software reported
+// under different names by different hosts or vendors is merged.
+type SoftwareNormalizationRule struct {
+	// Pattern is a regular expression matched against the whole reported
+	// name, case insensitively.
+	Pattern string `json:"pattern"`
+	// Source, if set, restricts the rule to the software of the source.
+	Source string `json:"source,omitempty"`
+	// CanonicalName is the name the software is stored under.
+	CanonicalName string `json:"canonical_name"`
+}
+
+// Compile returns the regular expression of the rule pattern.
+func (r SoftwareNormalizationRule) Compile() (*regexp.Regexp, error) {
+	return regexp.Compile(`(?i)^(?:` + r.Pattern + `)$`)
+}
+
+// SoftwareNormalizationRules is the ordered list of normalization rules, the
+// first matching rule applies.
+type SoftwareNormalizationRules []SoftwareNormalizationRule
+
+// Normalize returns the software with the names matching a rule replaced by
+// the canonical names. Rules with an invalid pattern, rejected when the rules
+// are modified, are ignored.
+func (rules SoftwareNormalizationRules) Normalize(software []Software) []Software {
+	if len(rules) == 0 {
+		return software
+	}
+	patterns := make([]*regexp.Regexp, len(rules))
+	for i, rule := range rules {
+		patterns[i], _ = rule.Compile()
+	}
+
+	result := make([]Software, 0, len(software))
+	for _, s := range software {
+		for i, rule := range rules {
+			if patterns[i] == nil || (rule.Source != "" && rule.Source != s.Source) {
+				continue
+			}
+			if patterns[i].MatchString(s.Name) {
+				s.Name = rule.CanonicalName
+				break
+			}
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// Value implements driver.Valuer, the rules are stored as JSON.
+func (rules SoftwareNormalizationRules) Value() (driver.Value, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(rules)
+}
+
+// Scan implements sql.Scanner.
+func (rules *SoftwareNormalizationRules) Scan(src interface{}) error {
+	*rules = nil
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, rules)
+	case string:
+		return json.Unmarshal([]byte(v), rules)
+	default:
+		return errors.Errorf("unsupported software normalization rules type %T", src)
+	}
+}
@@ -0,0 +1,37 @@
+package fleet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftwareNormalizationRules(t *testing.T) {
+	rules := SoftwareNormalizationRules{
+		{Pattern: "msedge|microsoft edge", CanonicalName: "Microsoft Edge"},
+		{Pattern: "python3?", Source: "deb_packages", CanonicalName: "python"},
+		{Pattern: "(", CanonicalName: "invalid"},
+		{Pattern: "edge.*", CanonicalName: "Edge"},
+	}
+	software := []Software{
+		{Name: "msedge", Version: "92.0", Source: "programs"},
+		{Name: "MICROSOFT EDGE", Version: "92.0", Source: "programs"},
+		{Name: "Microsoft Edge Update", Version: "1.3", Source: "programs"},
+		{Name: "python3", Version: "3.8.2", Source: "deb_packages"},
+		{Name: "python3", Version: "3.8.2", Source: "rpm_packages"},
+		{Name: "edgedriver", Version: "1.0", Source: "npm_packages"},
+	}
+
+	normalized := rules.Normalize(software)
+	require.Len(t, normalized, len(software))
+	var names []string
+	for _, s := range normalized {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"Microsoft Edge", "Microsoft Edge", "Microsoft Edge Update", "python", "python3", "Edge"}, names)
+	// The reported software is not modified.
+	assert.Equal(t, "msedge", software[0].Name)
+
+	assert.Equal(t, software, SoftwareNormalizationRules(nil).Normalize(software))
+}
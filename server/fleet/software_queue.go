@@ -0,0 +1,14 @@
+package fleet
+
+// HostSoftwareQueue buffers the software reported by the hosts so that it is
+// saved asynchronously, in batches, instead of while handling the osquery
+// results.
+type HostSoftwareQueue interface {
+	// Enqueue buffers the software of the host. Software of the same host
+	// buffered and not yet dequeued is replaced, only the latest software
+	// reported by a host is saved.
+	Enqueue(host *Host) error
+	// Dequeue removes and returns about n buffered hosts, with their ID,
+	// TeamID and HostSoftware set.
+	Dequeue(n int) ([]*Host, error)
+}
@@ -0,0 +1,42 @@
+package fleet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareSoftwareVersions(t *testing.T) {
+	var testCases = []struct {
+		source string
+		a, b   string
+		result int
+	}{
+		{"apps", "1.0.0", "1.0.0", 0},
+		{"apps", "1.2.0", "1.10.0", -1},
+		{"apps", "2.0", "1.99.99", 1},
+		{"apps", "1.0", "1.0.1", -1},
+		{"apps", "1.0.rc1", "1.0.1", -1},
+		{"apps", "94.0.4606.81", "94.0.4606.71", 1},
+		{"deb_packages", "1:2.0-1", "3.0-1", 1},
+		{"deb_packages", "2.31-0ubuntu9.2", "2.31-0ubuntu9.10", -1},
+		{"deb_packages", "1.0~rc1", "1.0", -1},
+		{"deb_packages", "1.0", "1.0a", -1},
+		{"deb_packages", "1:1.0~rc1-1", "1:1.0-1", -1},
+		{"rpm_packages", "2:1.0", "1:9.0", 1},
+		{"rpm_packages", "1.0~rc1", "1.0", -1},
+		{"rpm_packages", "1.0^git1", "1.0", 1},
+		{"rpm_packages", "1:1.1.1g-12.el8_3", "1:1.1.1g-15.el8_3", -1},
+		// The tilde is only a pre-release marker for the package sources.
+		{"apps", "1.0~rc1", "1.0", 1},
+		// The epoch is only understood for the package sources.
+		{"python_packages", "1:2.0", "3.0", -1},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.result, CompareSoftwareVersions(tt.source, tt.a, tt.b))
+			assert.Equal(t, -tt.result, CompareSoftwareVersions(tt.source, tt.b, tt.a))
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package fleet
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// SoftwareWatchlist is the software to watch for on the hosts, either by name
+// or by CPE.
+type SoftwareWatchlist struct {
+	// NamePatterns are regular expressions matched against the software
+	// names.
+	NamePatterns []string `json:"name_patterns"`
+	// CPEs are CPE 2.3 names matched against the CPE of the software. Any
+	// component may be the any value "*".
+	CPEs []string `json:"cpes"`
+}
+
+// Empty returns whether nothing is watched.
+func (w SoftwareWatchlist) Empty() bool {
+	return len(w.NamePatterns) == 0 && len(w.CPEs) == 0
+}
+
+// Value implements driver.Valuer, the watchlist is stored as JSON.
+func (w SoftwareWatchlist) Value() (driver.Value, error) {
+	if w.Empty() {
+		return nil, nil
+	}
+	return json.Marshal(w)
+}
+
+// Scan implements sql.Scanner.
+func (w *SoftwareWatchlist) Scan(src interface{}) error {
+	*w = SoftwareWatchlist{}
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, w)
+	case string:
+		return json.Unmarshal([]byte(v), w)
+	default:
+		return errors.Errorf("unsupported software watchlist type %T", src)
+	}
+}
@@ -0,0 +1,36 @@
+package fleet
+
+import "context"
+
+// Specs are the specs applied together by fleetctl apply.
+type Specs struct {
+	Queries      []*QuerySpec      `json:"queries,omitempty"`
+	Labels       []*LabelSpec      `json:"labels,omitempty"`
+	Packs        []*PackSpec       `json:"packs,omitempty"`
+	EnrollSecret *EnrollSecretSpec `json:"enroll_secret,omitempty"`
+	UsersRoles   *UsersRoleSpec    `json:"user_roles,omitempty"`
+}
+
+const (
+	SpecChangeCreate = "create"
+	SpecChangeUpdate = "update"
+	SpecChangeDelete = "delete"
+)
+
+// SpecChange is a change applying specs would make.
+type SpecChange struct {
+	// Kind is the kind of the changed spec, or scheduled_query for the
+	// queries of a pack.
+	Kind string `json:"kind"`
+	// Name is the name of the spec. Scheduled queries are named
+	// <pack>/<query> and the enroll secrets by their team, or global.
+	Name string `json:"name"`
+	// Action is one of create, update or delete.
+	Action string `json:"action"`
+}
+
+type SpecsService interface {
+	// ValidateSpecs validates the specs like applying them would, and returns
+	// the changes applying them would make without persisting anything.
+	ValidateSpecs(ctx context.Context, specs *Specs) ([]*SpecChange, error)
+}
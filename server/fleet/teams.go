@@ -59,9 +59,16 @@ type TeamPayload struct {
 	Name        *string         `json:"name"`
 	Description *string         `json:"description"`
 	Secrets     []*EnrollSecret `json:"secrets"`
+	Features    *TeamFeatures   `json:"features"`
 	// Note AgentOptions must be set by a separate endpoint.
 }
 
+// TeamFeatures contains the settings of the optional features overridden by
+// a team. Unset settings use the app config.
+type TeamFeatures struct {
+	EnableSoftwareInventory *bool `json:"enable_software_inventory" db:"enable_software_inventory"`
+}
+
 // Team is the data representation for the "Team" concept (group of hosts and
 // group of users that can perform operations on those hosts).
 type Team struct {
@@ -77,6 +84,8 @@ type Team struct {
 	Description string `json:"description" db:"description"`
 	// AgentOptions is the options for osquery and Orbit.
 	AgentOptions *json.RawMessage `json:"agent_options" db:"agent_options"`
+	// TeamFeatures is the feature settings overridden by the team.
+	TeamFeatures `json:"features"`
 
 	// Derived from JOINs
 
@@ -0,0 +1,225 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SplitPackageVersion splits a package version in its epoch, upstream
+// version and release (or Debian revision). Versions without an epoch have
+// epoch 0.
+func SplitPackageVersion(v string) (epoch uint64, version, release string) {
+	version = v
+	if i := strings.Index(version, ":"); i >= 0 {
+		if e, err := strconv.ParseUint(version[:i], 10, 64); err == nil {
+			epoch = e
+			version = version[i+1:]
+		}
+	}
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		version, release = version[:i], version[i+1:]
+	}
+	return epoch, version, release
+}
+
+// CompareDeb compares two Debian package versions the way dpkg does,
+// returning -1, 0 or 1 if a is lower, equal or higher than b.
+func CompareDeb(a, b string) int {
+	epochA, versionA, revisionA := SplitPackageVersion(a)
+	epochB, versionB, revisionB := SplitPackageVersion(b)
+	if c := compareUint(epochA, epochB); c != 0 {
+		return c
+	}
+	if c := compareDebParts(versionA, versionB); c != 0 {
+		return c
+	}
+	return compareDebParts(revisionA, revisionB)
+}
+
+// debOrder is the sort weight of a character of a Debian version: the tilde
+// sorts before anything, even the end of the version, and letters sort
+// before the other characters.
+func debOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case isDigit(c):
+		return 0
+	case isLetter(c):
+		return int(c)
+	case c == '~':
+		return -1
+	}
+	return int(c) + 256
+}
+
+func compareDebParts(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			oa, ob := debOrder(a, i), debOrder(b, j)
+			if oa != ob {
+				return sign(oa - ob)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+// CompareRPM compares two RPM package versions the way rpm does, returning
+// -1, 0 or 1 if a is lower, equal or higher than b. The releases are only
+// compared if both versions have one.
+func CompareRPM(a, b string) int {
+	epochA, versionA, releaseA := SplitPackageVersion(a)
+	epochB, versionB, releaseB := SplitPackageVersion(b)
+	if c := compareUint(epochA, epochB); c != 0 {
+		return c
+	}
+	if c := rpmvercmp(versionA, versionB); c != 0 {
+		return c
+	}
+	if releaseA == "" || releaseB == "" {
+		return 0
+	}
+	return rpmvercmp(releaseA, releaseB)
+}
+
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isDigit(a[i]) && !isLetter(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isDigit(b[j]) && !isLetter(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+
+		// The tilde sorts before anything, the caret after the end of the
+		// version but before anything else.
+		tildeA, tildeB := i < len(a) && a[i] == '~', j < len(b) && b[j] == '~'
+		if tildeA || tildeB {
+			if !tildeA {
+				return 1
+			}
+			if !tildeB {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		caretA, caretB := i < len(a) && a[i] == '^', j < len(b) && b[j] == '^'
+		if caretA || caretB {
+			if i >= len(a) {
+				return -1
+			}
+			if j >= len(b) {
+				return 1
+			}
+			if !caretA {
+				return 1
+			}
+			if !caretB {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+
+		startA, startB := i, j
+		numeric := isDigit(a[i])
+		if numeric {
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+		} else {
+			for i < len(a) && isLetter(a[i]) {
+				i++
+			}
+			for j < len(b) && isLetter(b[j]) {
+				j++
+			}
+		}
+		segA, segB := a[startA:i], b[startB:j]
+		if segB == "" {
+			// Numeric segments are newer than alphabetic ones.
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+		if numeric {
+			segA, segB = strings.TrimLeft(segA, "0"), strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				return sign(len(segA) - len(segB))
+			}
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+
+	// The version with characters left is newer.
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i < len(a):
+		return 1
+	}
+	return -1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareDeb(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.1.1f-1ubuntu2", "1.1.1f-1ubuntu2", 0},
+		{"1.1.1f-1ubuntu2", "0:1.1.1f-1ubuntu2", 0},
+		{"1.1.1f-1ubuntu2", "1.1.1f-1ubuntu2.3", -1},
+		{"1.1.1f-1ubuntu2.10", "1.1.1f-1ubuntu2.3", 1},
+		{"1:1.0", "2.0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0", "1.0a", -1},
+		{"1.0a", "1.0+", -1},
+		{"1.01", "1.1", 0},
+		{"7.68.0-1ubuntu2.5", "7.68.0-1ubuntu2.6", -1},
+		{"2.30-0ubuntu2", "2.30-0ubuntu2.1", -1},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CompareDeb(tt.a, tt.b))
+			assert.Equal(t, -tt.expected, CompareDeb(tt.b, tt.a))
+		})
+	}
+}
+
+func TestCompareRPM(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.1.1g-15.el8_3", "1.1.1g-15.el8_3", 0},
+		{"1:1.1.1g-12.el8_3", "1:1.1.1g-15.el8_3", -1},
+		{"1.1.1g-15.el8_3", "1:1.1.1g-15.el8_3", -1},
+		{"1.1.1k-4.el8", "1.1.1g-15.el8_3", 1},
+		{"1.0", "1.0.1", -1},
+		{"1.0a", "1.0", 1},
+		{"1.0a", "1.0.1", -1},
+		{"1.010", "1.9", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0.1", -1},
+		{"1.0_1", "1.0.1", 0},
+		// Releases are only compared if both versions have one.
+		{"1.1.1g", "1.1.1g-15.el8_3", 0},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CompareRPM(tt.a, tt.b))
+			assert.Equal(t, -tt.expected, CompareRPM(tt.b, tt.a))
+		})
+	}
+}
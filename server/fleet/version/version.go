@@ -0,0 +1,175 @@
+// Package version compares the versions of the software installed on the
+// hosts.
+package version
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Compare compares two versions, returning -1, 0 or 1 if a is lower, equal
+// or higher than b. When both versions are semantic versions they are
+// compared following https://semver.org: missing minor and patch numbers
+// are 0, a pre-release is lower than its release and build metadata is
+// ignored. Other versions are compared with CompareLoose.
+func Compare(a, b string) int {
+	semA, okA := parseSemver(a)
+	semB, okB := parseSemver(b)
+	if okA && okB {
+		return semA.compare(semB)
+	}
+	return CompareLoose(a, b)
+}
+
+// CompareLoose compares two versions by runs of digits, numerically, and
+// runs of other characters, lexically, returning -1, 0 or 1 if a is lower,
+// equal or higher than b. Separators are ignored, and a version that is a
+// prefix of the other is lower.
+func CompareLoose(a, b string) int {
+	partsA, partsB := parts(a), parts(b)
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		if c := comparePart(partsA[i], partsB[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(partsA) < len(partsB):
+		return -1
+	case len(partsA) > len(partsB):
+		return 1
+	}
+	return 0
+}
+
+// parts splits the version into runs of digits and runs of letters,
+// dropping the separators between them.
+func parts(version string) []string {
+	var parts []string
+	start := -1
+	for i, r := range version {
+		if start >= 0 && unicode.IsDigit(r) != unicode.IsDigit(rune(version[start])) {
+			parts = append(parts, version[start:i])
+			start = -1
+		}
+		if !unicode.IsDigit(r) && !unicode.IsLetter(r) {
+			if start >= 0 {
+				parts = append(parts, version[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, version[start:])
+	}
+	return parts
+}
+
+func comparePart(a, b string) int {
+	numA, errA := strconv.ParseUint(a, 10, 64)
+	numB, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return compareUint(numA, numB)
+	case errA == nil:
+		// Numbers are higher than letters, so that 1.0.1 > 1.0.rc1.
+		return 1
+	case errB == nil:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// semver is a parsed semantic version.
+type semver struct {
+	core       [3]uint64
+	prerelease []string
+}
+
+// parseSemver parses a semantic version, optionally prefixed with "v" and
+// with the minor and patch numbers omitted.
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	var v semver
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.prerelease = strings.Split(s[i+1:], ".")
+		for _, id := range v.prerelease {
+			if id == "" {
+				return semver{}, false
+			}
+		}
+		s = s[:i]
+	}
+
+	core := strings.Split(s, ".")
+	if len(core) > 3 {
+		return semver{}, false
+	}
+	for i, n := range core {
+		if n == "" || strings.TrimFunc(n, unicode.IsDigit) != "" {
+			return semver{}, false
+		}
+		num, err := strconv.ParseUint(n, 10, 64)
+		if err != nil {
+			return semver{}, false
+		}
+		v.core[i] = num
+	}
+	return v, true
+}
+
+func (v semver) compare(o semver) int {
+	for i := range v.core {
+		if c := compareUint(v.core[i], o.core[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		if c := comparePrereleaseIdentifier(v.prerelease[i], o.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(v.prerelease)), uint64(len(o.prerelease)))
+}
+
+// comparePrereleaseIdentifier compares numeric identifiers numerically and
+// others lexically, numeric identifiers being lower.
+func comparePrereleaseIdentifier(a, b string) int {
+	numA, errA := strconv.ParseUint(a, 10, 64)
+	numB, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return compareUint(numA, numB)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,45 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"2", "1.9.9", 1},
+		{"1.2.3+build.5", "1.2.3", 0},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta.11", 1},
+		// Not semantic versions.
+		{"92.0.4515.107", "92.0.4515.131", -1},
+		{"1.1.1k", "1.1.1f", 1},
+		{"1.1.1f-1ubuntu2", "1.1.1f-1ubuntu10", -1},
+		{"1.0", "1.0.1.2", -1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			assert.Equal(t, tc.want, Compare(tc.a, tc.b))
+			assert.Equal(t, -tc.want, Compare(tc.b, tc.a))
+		})
+	}
+}
+
+func TestCompareLoose(t *testing.T) {
+	assert.Equal(t, 0, CompareLoose("1.0", "1-0"))
+	assert.Equal(t, 1, CompareLoose("1.0.1", "1.0.rc1"))
+	assert.Equal(t, 1, CompareLoose("1.0-rc1", "1.0"))
+	assert.Equal(t, -1, CompareLoose("1.0a", "1.0b"))
+}
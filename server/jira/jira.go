@@ -0,0 +1,104 @@
+// Package jira creates Jira issues for the vulnerabilities found by Fleet.
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Client creates issues in a project of a Jira instance with the version 2 of
+// the Jira REST API.
+type Client struct {
+	// URL is the URL of the Jira instance.
+	URL string
+	// Username is the user authenticated with the API token. Without a
+	// username, the API token is sent as a personal access token.
+	Username   string
+	APIToken   string
+	ProjectKey string
+
+	HTTPClient *http.Client
+}
+
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+type createIssueRequest struct {
+	Fields issueFields `json:"fields"`
+}
+
+type issueFields struct {
+	Project     projectField   `json:"project"`
+	Summary     string         `json:"summary"`
+	Description string         `json:"description"`
+	IssueType   issueTypeField `json:"issuetype"`
+}
+
+type projectField struct {
+	Key string `json:"key"`
+}
+
+type issueTypeField struct {
+	Name string `json:"name"`
+}
+
+type createIssueResponse struct {
+	Key string `json:"key"`
+}
+
+// CreateIssue creates a task in the project and returns its key. The
+// description is formatted with the Jira wiki markup.
+func (c *Client) CreateIssue(ctx context.Context, summary, description string) (string, error) {
+	body, err := json.Marshal(createIssueRequest{
+		Fields: issueFields{
+			Project:     projectField{Key: c.ProjectKey},
+			Summary:     summary,
+			Description: description,
+			IssueType:   issueTypeField{Name: "Task"},
+		},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "marshal jira issue")
+	}
+
+	url := strings.TrimSuffix(c.URL, "/") + "/rest/api/2/issue"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", errors.Wrap(err, "create jira request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.APIToken)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.APIToken)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "post jira issue %s", url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		// The errors of the Jira API are described in the body.
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", errors.Errorf("post jira issue %s: unexpected status %s: %s", url, resp.Status, bytes.TrimSpace(b))
+	}
+	var created createIssueResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", errors.Wrap(err, "decode jira issue")
+	}
+	return created.Key, nil
+}
@@ -0,0 +1,47 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateIssue(t *testing.T) {
+	var received createIssueRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/2/issue", r.URL.Path)
+		username, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "fleet@example.com", username)
+		assert.Equal(t, "token", password)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"10000","key":"SEC-1","self":"https://example.atlassian.net/rest/api/2/issue/10000"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{URL: server.URL + "/", Username: "fleet@example.com", APIToken: "token", ProjectKey: "SEC"}
+	key, err := client.CreateIssue(context.Background(), "summary", "description")
+	require.NoError(t, err)
+	assert.Equal(t, "SEC-1", key)
+	assert.Equal(t, "SEC", received.Fields.Project.Key)
+	assert.Equal(t, "summary", received.Fields.Summary)
+	assert.Equal(t, "description", received.Fields.Description)
+	assert.Equal(t, "Task", received.Fields.IssueType.Name)
+
+	// Without a username, the API token is a personal access token.
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errorMessages":[],"errors":{"project":"project is required"}}`))
+	})
+	client.Username = ""
+	_, err = client.CreateIssue(context.Background(), "summary", "description")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "project is required")
+}
@@ -0,0 +1,74 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/vulnerabilities"
+	"github.com/pkg/errors"
+)
+
+// maxListedHosts is the maximum number of affected hosts listed in the
+// description of an issue, so that it stays below the size limit of Jira.
+const maxListedHosts = 50
+
+// TriggerVulnerabilitiesIssues creates an issue for each vulnerability found
+// in the software of the hosts since the provided time, if the Jira
+// integration is enabled.
+func TriggerVulnerabilitiesIssues(ctx context.Context, ds fleet.Datastore, since time.Time) error {
+	config, err := ds.AppConfig()
+	if err != nil {
+		return errors.Wrap(err, "load app config")
+	}
+	if !config.JiraIntegrationEnabled || config.JiraURL == "" {
+		return nil
+	}
+
+	found, err := vulnerabilities.ListNewVulnerabilities(ds, since, 0)
+	if err != nil {
+		return errors.Wrap(err, "list new vulnerabilities")
+	}
+
+	client := &Client{
+		URL:        config.JiraURL,
+		Username:   config.JiraUsername,
+		APIToken:   config.JiraAPIToken,
+		ProjectKey: config.JiraProjectKey,
+	}
+	for _, v := range found {
+		summary := fmt.Sprintf("Vulnerability %s detected on %d hosts", v.CVE, len(v.Hosts))
+		if _, err := client.CreateIssue(ctx, summary, issueDescription(config.ServerURL, v)); err != nil {
+			return errors.Wrapf(err, "create jira issue for %s", v.CVE)
+		}
+	}
+	return nil
+}
+
+// issueDescription describes the vulnerability in Jira wiki markup.
+func issueDescription(serverURL string, v vulnerabilities.NewVulnerability) string {
+	serverURL = strings.TrimSuffix(serverURL, "/")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "See vulnerability (CVE) details in the National Vulnerability Database: https://nvd.nist.gov/vuln/detail/%s\n\n", v.CVE)
+	if v.CVSSScore != nil {
+		fmt.Fprintf(&b, "CVSS score: %.1f\n\n", *v.CVSSScore)
+	}
+
+	b.WriteString("h3. Affected software\n\n")
+	for _, s := range v.Software {
+		fmt.Fprintf(&b, "* %s %s (%s)\n", s.Name, s.Version, s.Source)
+	}
+
+	fmt.Fprintf(&b, "\nh3. Affected hosts (%d)\n\n", len(v.Hosts))
+	for i, h := range v.Hosts {
+		if i == maxListedHosts {
+			fmt.Fprintf(&b, "* and %d more\n", len(v.Hosts)-maxListedHosts)
+			break
+		}
+		fmt.Fprintf(&b, "* [%s|%s/hosts/%d]\n", h.Hostname, serverURL, h.ID)
+	}
+	return b.String()
+}
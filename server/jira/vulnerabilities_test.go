@@ -0,0 +1,89 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/fleetdm/fleet/v4/server/vulnerabilities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueDescription(t *testing.T) {
+	v := vulnerabilities.NewVulnerability{
+		CVE:       "CVE-2021-3449",
+		CVSSScore: ptr.Float64(5.9),
+		Software: []fleet.SoftwareVulnerability{
+			{Name: "openssl", Version: "1.1.1f", Source: "deb_packages"},
+		},
+	}
+	for i := 1; i <= maxListedHosts+2; i++ {
+		v.Hosts = append(v.Hosts, vulnerabilities.AffectedHost{ID: uint(i), Hostname: fmt.Sprintf("host%d", i)})
+	}
+
+	description := issueDescription("https://fleet.example.com/", v)
+	assert.Contains(t, description, "https://nvd.nist.gov/vuln/detail/CVE-2021-3449")
+	assert.Contains(t, description, "CVSS score: 5.9")
+	assert.Contains(t, description, "* openssl 1.1.1f (deb_packages)\n")
+	assert.Contains(t, description, "h3. Affected hosts (52)")
+	assert.Contains(t, description, "* [host1|https://fleet.example.com/hosts/1]\n")
+	assert.NotContains(t, description, "host51")
+	assert.True(t, strings.HasSuffix(description, "* and 2 more\n"))
+}
+
+func TestTriggerVulnerabilitiesIssues(t *testing.T) {
+	var summaries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req createIssueRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		summaries = append(summaries, req.Fields.Summary)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"key":"SEC-1"}`))
+	}))
+	defer server.Close()
+
+	config := &fleet.AppConfig{
+		ServerURL:              "https://fleet.example.com",
+		JiraIntegrationEnabled: true,
+		JiraURL:                server.URL,
+		JiraAPIToken:           "token",
+		JiraProjectKey:         "SEC",
+	}
+	ds := new(mock.Store)
+	ds.AppConfigFunc = func() (*fleet.AppConfig, error) {
+		return config, nil
+	}
+	ds.ListSoftwareVulnerabilitiesSinceFunc = func(since time.Time) ([]fleet.SoftwareVulnerability, error) {
+		return []fleet.SoftwareVulnerability{
+			{SoftwareID: 1, Name: "curl", CVE: "CVE-2021-0001"},
+			{SoftwareID: 2, Name: "openssl", CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9)},
+		}, nil
+	}
+	ds.ListHostSoftwareVersionsBySoftwareIDFunc = func(softwareIDs []uint) ([]fleet.HostSoftwareVersion, error) {
+		return []fleet.HostSoftwareVersion{
+			{HostID: 1, Hostname: "host1", SoftwareID: softwareIDs[0]},
+			{HostID: 2, Hostname: "host2", SoftwareID: softwareIDs[0]},
+		}, nil
+	}
+
+	require.NoError(t, TriggerVulnerabilitiesIssues(context.Background(), ds, time.Now()))
+	assert.Equal(t, []string{
+		"Vulnerability CVE-2021-0001 detected on 2 hosts",
+		"Vulnerability CVE-2021-3449 detected on 2 hosts",
+	}, summaries)
+
+	// Nothing is created when the integration is disabled.
+	summaries = nil
+	config.JiraIntegrationEnabled = false
+	require.NoError(t, TriggerVulnerabilitiesIssues(context.Background(), ds, time.Now()))
+	assert.Empty(t, summaries)
+}
@@ -2,7 +2,11 @@
 
 package mock
 
-import "github.com/fleetdm/fleet/v4/server/fleet"
+import (
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
 
 var _ fleet.SoftwareStore = (*SoftwareStore)(nil)
 
@@ -10,12 +14,292 @@ type SaveHostSoftwareFunc func(host *fleet.Host) error
 
 type LoadHostSoftwareFunc func(host *fleet.Host) error
 
+type SimilarHostsBySoftwareFunc func(hostID uint, limit int) ([]fleet.HostSoftwareSimilarity, error)
+
+type SoftwareCatalogStatsFunc func() (fleet.SoftwareCatalogStats, error)
+
+type ReplaceAllHostSoftwareFunc func(host *fleet.Host, software []fleet.Software) error
+
+type MarkHostSoftwareUpdatesFunc func(hostID uint, outdatedSoftwareIDs []uint) error
+
+type RapidlySpreadingSoftwareFunc func(window time.Duration, minHosts int) ([]fleet.Software, error)
+
+type LoadHostSoftwareGroupedFunc func(host *fleet.Host) (map[string][]fleet.Software, error)
+
+type ListHostSoftwareFunc func(hostID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error)
+
+type MostCommonSoftwareVersionsFunc func(opt fleet.ListOptions) ([]fleet.Software, error)
+
+type RepairZeroSoftwareIDsFunc func() (fleet.ZeroSoftwareIDsRepair, error)
+
+type TagSoftwareFunc func(id uint, categories []string) error
+
+type ListSoftwareByCategoryFunc func(category string, opt fleet.ListOptions) ([]fleet.Software, error)
+
+type ListSoftwareForLabelFunc func(labelID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error)
+
+type LoadHostSoftwareWithVulnerabilitiesFunc func(host *fleet.Host) error
+
+type SoftwareActivityTimelineFunc func(bucket time.Duration, since time.Time) ([]fleet.SoftwareActivityBucket, error)
+
+type HostSoftwareBaselineViolationsFunc func(hostID uint, baseline []fleet.Software) ([]fleet.Software, error)
+
+type HostSoftwareArchMismatchesFunc func(hostID uint, hostArch string) ([]fleet.Software, error)
+
+type MergeHostSoftwareFunc func(oldHostID, newHostID uint, clearOld bool) error
+
+type HostSoftwareCountsFunc func(opt fleet.ListOptions) (map[uint]int, error)
+
+type LatestObservedSoftwareVersionsFunc func(opt fleet.ListOptions) (map[string]string, error)
+
+type SoftwareByIDFunc func(id uint) (*fleet.Software, error)
+
+type SetSoftwareNoteFunc func(id uint, note string) error
+
+type SoftwareDriftFromReferenceFunc func(hostID, referenceHostID uint) (extra, missing []fleet.Software, err error)
+
+type HostSoftwareDowngradesFunc func(hostID uint, since time.Time) ([]fleet.SoftwareChange, error)
+
+type SoftwareSourcesFunc func() ([]string, error)
+
+type HostsWithConflictingSoftwareVersionsFunc func(name, source string) ([]fleet.Host, error)
+
+type VerifySoftwareChecksumsFunc func() (mismatched int, err error)
+
+type HostSoftwareAddedSinceFunc func(hostID uint, since time.Time) ([]fleet.Software, error)
+
+type ListSoftwareForHostFilterFunc func(filter fleet.HostListOptions, opt fleet.SoftwareListOptions) ([]fleet.Software, error)
+
+type CleanupOrphanedSoftwareFunc func(batchSize int) (deleted int, err error)
+
+type ListSoftwareFunc func(opt fleet.SoftwareListOptions) ([]fleet.Software, error)
+
+type CalculateHostsPerSoftwareFunc func(updatedAt time.Time) error
+
+type ListSoftwareWithoutCPEFunc func() ([]fleet.Software, error)
+
+type AddCPEForSoftwareFunc func(softwareID uint, cpe string) error
+
+type ListSoftwareCPEsFunc func() ([]fleet.SoftwareCPE, error)
+
+type ReplaceSoftwareCVEsFunc func(softwareID uint, cves []string) error
+
+type ListSoftwareByTeamFunc func(teamID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error)
+
+type ExportSoftwareFunc func(fn func(fleet.Software) error) error
+
+type CalculateSoftwareTitlesFunc func(updatedAt time.Time) error
+
+type ListSoftwareTitlesFunc func(opt fleet.ListOptions) ([]fleet.SoftwareTitleSummary, error)
+
+type ListHostSoftwareVersionsByNameFunc func(name string) ([]fleet.HostSoftwareVersion, error)
+
+type SoftwareInventoryEnabledFunc func(teamID *uint) (bool, error)
+
+type ListHostSoftwareChangesFunc func(hostID uint, opt fleet.ListOptions) ([]fleet.HostSoftwareChange, error)
+
+type ListHostOSVersionsFunc func() ([]fleet.HostOSVersion, error)
+
+type ListSoftwareByHostOSFunc func(os fleet.HostOSVersion, source string) ([]fleet.Software, error)
+
+type ReplaceSoftwareOVALResultsFunc func(softwareID uint, results []fleet.SoftwareOVALResult) error
+
+type LoadSoftwareVulnerabilitiesFunc func(software []fleet.Software) error
+
+type InsertCVEMetaFunc func(meta []fleet.CVEMeta) error
+
+type ListSoftwareVulnerabilitiesSinceFunc func(since time.Time) ([]fleet.SoftwareVulnerability, error)
+
+type ListHostSoftwareVersionsBySoftwareIDFunc func(softwareIDs []uint) ([]fleet.HostSoftwareVersion, error)
+
+type NewSoftwareCVESuppressionFunc func(suppression *fleet.SoftwareCVESuppression) (*fleet.SoftwareCVESuppression, error)
+
+type SoftwareCVESuppressionFunc func(id uint) (*fleet.SoftwareCVESuppression, error)
+
+type ListSoftwareCVESuppressionsFunc func(opt fleet.ListOptions) ([]fleet.SoftwareCVESuppression, error)
+
+type DeleteSoftwareCVESuppressionFunc func(id uint) error
+
+type ListWindowsHostUpdatesFunc func() ([]fleet.WindowsHostUpdates, error)
+
+type ReplaceHostOperatingSystemVulnerabilitiesFunc func(hostID uint, vulnerabilities []fleet.OperatingSystemVulnerability) error
+
+type ListHostOperatingSystemVulnerabilitiesFunc func(hostID uint) ([]fleet.OperatingSystemVulnerability, error)
+
 type SoftwareStore struct {
 	SaveHostSoftwareFunc        SaveHostSoftwareFunc
 	SaveHostSoftwareFuncInvoked bool
 
 	LoadHostSoftwareFunc        LoadHostSoftwareFunc
 	LoadHostSoftwareFuncInvoked bool
+
+	SimilarHostsBySoftwareFunc        SimilarHostsBySoftwareFunc
+	SimilarHostsBySoftwareFuncInvoked bool
+
+	SoftwareCatalogStatsFunc        SoftwareCatalogStatsFunc
+	SoftwareCatalogStatsFuncInvoked bool
+
+	ReplaceAllHostSoftwareFunc        ReplaceAllHostSoftwareFunc
+	ReplaceAllHostSoftwareFuncInvoked bool
+
+	MarkHostSoftwareUpdatesFunc        MarkHostSoftwareUpdatesFunc
+	MarkHostSoftwareUpdatesFuncInvoked bool
+
+	RapidlySpreadingSoftwareFunc        RapidlySpreadingSoftwareFunc
+	RapidlySpreadingSoftwareFuncInvoked bool
+
+	LoadHostSoftwareGroupedFunc        LoadHostSoftwareGroupedFunc
+	LoadHostSoftwareGroupedFuncInvoked bool
+
+	ListHostSoftwareFunc        ListHostSoftwareFunc
+	ListHostSoftwareFuncInvoked bool
+
+	MostCommonSoftwareVersionsFunc        MostCommonSoftwareVersionsFunc
+	MostCommonSoftwareVersionsFuncInvoked bool
+
+	RepairZeroSoftwareIDsFunc        RepairZeroSoftwareIDsFunc
+	RepairZeroSoftwareIDsFuncInvoked bool
+
+	TagSoftwareFunc        TagSoftwareFunc
+	TagSoftwareFuncInvoked bool
+
+	ListSoftwareByCategoryFunc        ListSoftwareByCategoryFunc
+	ListSoftwareByCategoryFuncInvoked bool
+
+	ListSoftwareForLabelFunc        ListSoftwareForLabelFunc
+	ListSoftwareForLabelFuncInvoked bool
+
+	LoadHostSoftwareWithVulnerabilitiesFunc        LoadHostSoftwareWithVulnerabilitiesFunc
+	LoadHostSoftwareWithVulnerabilitiesFuncInvoked bool
+
+	SoftwareActivityTimelineFunc        SoftwareActivityTimelineFunc
+	SoftwareActivityTimelineFuncInvoked bool
+
+	HostSoftwareBaselineViolationsFunc        HostSoftwareBaselineViolationsFunc
+	HostSoftwareBaselineViolationsFuncInvoked bool
+
+	HostSoftwareArchMismatchesFunc        HostSoftwareArchMismatchesFunc
+	HostSoftwareArchMismatchesFuncInvoked bool
+
+	MergeHostSoftwareFunc        MergeHostSoftwareFunc
+	MergeHostSoftwareFuncInvoked bool
+
+	HostSoftwareCountsFunc        HostSoftwareCountsFunc
+	HostSoftwareCountsFuncInvoked bool
+
+	LatestObservedSoftwareVersionsFunc        LatestObservedSoftwareVersionsFunc
+	LatestObservedSoftwareVersionsFuncInvoked bool
+
+	SoftwareByIDFunc        SoftwareByIDFunc
+	SoftwareByIDFuncInvoked bool
+
+	SetSoftwareNoteFunc        SetSoftwareNoteFunc
+	SetSoftwareNoteFuncInvoked bool
+
+	SoftwareDriftFromReferenceFunc        SoftwareDriftFromReferenceFunc
+	SoftwareDriftFromReferenceFuncInvoked bool
+
+	HostSoftwareDowngradesFunc        HostSoftwareDowngradesFunc
+	HostSoftwareDowngradesFuncInvoked bool
+
+	SoftwareSourcesFunc        SoftwareSourcesFunc
+	SoftwareSourcesFuncInvoked bool
+
+	HostsWithConflictingSoftwareVersionsFunc        HostsWithConflictingSoftwareVersionsFunc
+	HostsWithConflictingSoftwareVersionsFuncInvoked bool
+
+	VerifySoftwareChecksumsFunc        VerifySoftwareChecksumsFunc
+	VerifySoftwareChecksumsFuncInvoked bool
+
+	HostSoftwareAddedSinceFunc        HostSoftwareAddedSinceFunc
+	HostSoftwareAddedSinceFuncInvoked bool
+
+	ListSoftwareForHostFilterFunc        ListSoftwareForHostFilterFunc
+	ListSoftwareForHostFilterFuncInvoked bool
+
+	CleanupOrphanedSoftwareFunc        CleanupOrphanedSoftwareFunc
+	CleanupOrphanedSoftwareFuncInvoked bool
+
+	ListSoftwareFunc        ListSoftwareFunc
+	ListSoftwareFuncInvoked bool
+
+	CalculateHostsPerSoftwareFunc        CalculateHostsPerSoftwareFunc
+	CalculateHostsPerSoftwareFuncInvoked bool
+
+	ListSoftwareWithoutCPEFunc        ListSoftwareWithoutCPEFunc
+	ListSoftwareWithoutCPEFuncInvoked bool
+
+	AddCPEForSoftwareFunc        AddCPEForSoftwareFunc
+	AddCPEForSoftwareFuncInvoked bool
+
+	ListSoftwareCPEsFunc        ListSoftwareCPEsFunc
+	ListSoftwareCPEsFuncInvoked bool
+
+	ReplaceSoftwareCVEsFunc        ReplaceSoftwareCVEsFunc
+	ReplaceSoftwareCVEsFuncInvoked bool
+
+	ListSoftwareByTeamFunc        ListSoftwareByTeamFunc
+	ListSoftwareByTeamFuncInvoked bool
+
+	ExportSoftwareFunc        ExportSoftwareFunc
+	ExportSoftwareFuncInvoked bool
+
+	CalculateSoftwareTitlesFunc        CalculateSoftwareTitlesFunc
+	CalculateSoftwareTitlesFuncInvoked bool
+
+	ListSoftwareTitlesFunc        ListSoftwareTitlesFunc
+	ListSoftwareTitlesFuncInvoked bool
+
+	ListHostSoftwareVersionsByNameFunc        ListHostSoftwareVersionsByNameFunc
+	ListHostSoftwareVersionsByNameFuncInvoked bool
+
+	SoftwareInventoryEnabledFunc        SoftwareInventoryEnabledFunc
+	SoftwareInventoryEnabledFuncInvoked bool
+
+	ListHostSoftwareChangesFunc        ListHostSoftwareChangesFunc
+	ListHostSoftwareChangesFuncInvoked bool
+
+	ListHostOSVersionsFunc        ListHostOSVersionsFunc
+	ListHostOSVersionsFuncInvoked bool
+
+	ListSoftwareByHostOSFunc        ListSoftwareByHostOSFunc
+	ListSoftwareByHostOSFuncInvoked bool
+
+	ReplaceSoftwareOVALResultsFunc        ReplaceSoftwareOVALResultsFunc
+	ReplaceSoftwareOVALResultsFuncInvoked bool
+
+	LoadSoftwareVulnerabilitiesFunc        LoadSoftwareVulnerabilitiesFunc
+	LoadSoftwareVulnerabilitiesFuncInvoked bool
+
+	InsertCVEMetaFunc        InsertCVEMetaFunc
+	InsertCVEMetaFuncInvoked bool
+
+	ListSoftwareVulnerabilitiesSinceFunc        ListSoftwareVulnerabilitiesSinceFunc
+	ListSoftwareVulnerabilitiesSinceFuncInvoked bool
+
+	ListHostSoftwareVersionsBySoftwareIDFunc        ListHostSoftwareVersionsBySoftwareIDFunc
+	ListHostSoftwareVersionsBySoftwareIDFuncInvoked bool
+
+	NewSoftwareCVESuppressionFunc        NewSoftwareCVESuppressionFunc
+	NewSoftwareCVESuppressionFuncInvoked bool
+
+	SoftwareCVESuppressionFunc        SoftwareCVESuppressionFunc
+	SoftwareCVESuppressionFuncInvoked bool
+
+	ListSoftwareCVESuppressionsFunc        ListSoftwareCVESuppressionsFunc
+	ListSoftwareCVESuppressionsFuncInvoked bool
+
+	DeleteSoftwareCVESuppressionFunc        DeleteSoftwareCVESuppressionFunc
+	DeleteSoftwareCVESuppressionFuncInvoked bool
+
+	ListWindowsHostUpdatesFunc        ListWindowsHostUpdatesFunc
+	ListWindowsHostUpdatesFuncInvoked bool
+
+	ReplaceHostOperatingSystemVulnerabilitiesFunc        ReplaceHostOperatingSystemVulnerabilitiesFunc
+	ReplaceHostOperatingSystemVulnerabilitiesFuncInvoked bool
+
+	ListHostOperatingSystemVulnerabilitiesFunc        ListHostOperatingSystemVulnerabilitiesFunc
+	ListHostOperatingSystemVulnerabilitiesFuncInvoked bool
 }
 
 func (s *SoftwareStore) SaveHostSoftware(host *fleet.Host) error {
@@ -27,3 +311,283 @@ func (s *SoftwareStore) LoadHostSoftware(host *fleet.Host) error {
 	s.LoadHostSoftwareFuncInvoked = true
 	return s.LoadHostSoftwareFunc(host)
 }
+
+func (s *SoftwareStore) SimilarHostsBySoftware(hostID uint, limit int) ([]fleet.HostSoftwareSimilarity, error) {
+	s.SimilarHostsBySoftwareFuncInvoked = true
+	return s.SimilarHostsBySoftwareFunc(hostID, limit)
+}
+
+func (s *SoftwareStore) SoftwareCatalogStats() (fleet.SoftwareCatalogStats, error) {
+	s.SoftwareCatalogStatsFuncInvoked = true
+	return s.SoftwareCatalogStatsFunc()
+}
+
+func (s *SoftwareStore) ReplaceAllHostSoftware(host *fleet.Host, software []fleet.Software) error {
+	s.ReplaceAllHostSoftwareFuncInvoked = true
+	return s.ReplaceAllHostSoftwareFunc(host, software)
+}
+
+func (s *SoftwareStore) MarkHostSoftwareUpdates(hostID uint, outdatedSoftwareIDs []uint) error {
+	s.MarkHostSoftwareUpdatesFuncInvoked = true
+	return s.MarkHostSoftwareUpdatesFunc(hostID, outdatedSoftwareIDs)
+}
+
+func (s *SoftwareStore) RapidlySpreadingSoftware(window time.Duration, minHosts int) ([]fleet.Software, error) {
+	s.RapidlySpreadingSoftwareFuncInvoked = true
+	return s.RapidlySpreadingSoftwareFunc(window, minHosts)
+}
+
+func (s *SoftwareStore) LoadHostSoftwareGrouped(host *fleet.Host) (map[string][]fleet.Software, error) {
+	s.LoadHostSoftwareGroupedFuncInvoked = true
+	return s.LoadHostSoftwareGroupedFunc(host)
+}
+
+func (s *SoftwareStore) ListHostSoftware(hostID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	s.ListHostSoftwareFuncInvoked = true
+	return s.ListHostSoftwareFunc(hostID, opt)
+}
+
+func (s *SoftwareStore) MostCommonSoftwareVersions(opt fleet.ListOptions) ([]fleet.Software, error) {
+	s.MostCommonSoftwareVersionsFuncInvoked = true
+	return s.MostCommonSoftwareVersionsFunc(opt)
+}
+
+func (s *SoftwareStore) RepairZeroSoftwareIDs() (fleet.ZeroSoftwareIDsRepair, error) {
+	s.RepairZeroSoftwareIDsFuncInvoked = true
+	return s.RepairZeroSoftwareIDsFunc()
+}
+
+func (s *SoftwareStore) TagSoftware(id uint, categories []string) error {
+	s.TagSoftwareFuncInvoked = true
+	return s.TagSoftwareFunc(id, categories)
+}
+
+func (s *SoftwareStore) ListSoftwareByCategory(category string, opt fleet.ListOptions) ([]fleet.Software, error) {
+	s.ListSoftwareByCategoryFuncInvoked = true
+	return s.ListSoftwareByCategoryFunc(category, opt)
+}
+
+func (s *SoftwareStore) ListSoftwareForLabel(labelID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	s.ListSoftwareForLabelFuncInvoked = true
+	return s.ListSoftwareForLabelFunc(labelID, opt)
+}
+
+func (s *SoftwareStore) LoadHostSoftwareWithVulnerabilities(host *fleet.Host) error {
+	s.LoadHostSoftwareWithVulnerabilitiesFuncInvoked = true
+	return s.LoadHostSoftwareWithVulnerabilitiesFunc(host)
+}
+
+func (s *SoftwareStore) SoftwareActivityTimeline(bucket time.Duration, since time.Time) ([]fleet.SoftwareActivityBucket, error) {
+	s.SoftwareActivityTimelineFuncInvoked = true
+	return s.SoftwareActivityTimelineFunc(bucket, since)
+}
+
+func (s *SoftwareStore) HostSoftwareBaselineViolations(hostID uint, baseline []fleet.Software) ([]fleet.Software, error) {
+	s.HostSoftwareBaselineViolationsFuncInvoked = true
+	return s.HostSoftwareBaselineViolationsFunc(hostID, baseline)
+}
+
+func (s *SoftwareStore) HostSoftwareArchMismatches(hostID uint, hostArch string) ([]fleet.Software, error) {
+	s.HostSoftwareArchMismatchesFuncInvoked = true
+	return s.HostSoftwareArchMismatchesFunc(hostID, hostArch)
+}
+
+func (s *SoftwareStore) MergeHostSoftware(oldHostID, newHostID uint, clearOld bool) error {
+	s.MergeHostSoftwareFuncInvoked = true
+	return s.MergeHostSoftwareFunc(oldHostID, newHostID, clearOld)
+}
+
+func (s *SoftwareStore) HostSoftwareCounts(opt fleet.ListOptions) (map[uint]int, error) {
+	s.HostSoftwareCountsFuncInvoked = true
+	return s.HostSoftwareCountsFunc(opt)
+}
+
+func (s *SoftwareStore) LatestObservedSoftwareVersions(opt fleet.ListOptions) (map[string]string, error) {
+	s.LatestObservedSoftwareVersionsFuncInvoked = true
+	return s.LatestObservedSoftwareVersionsFunc(opt)
+}
+
+func (s *SoftwareStore) SoftwareByID(id uint) (*fleet.Software, error) {
+	s.SoftwareByIDFuncInvoked = true
+	return s.SoftwareByIDFunc(id)
+}
+
+func (s *SoftwareStore) SetSoftwareNote(id uint, note string) error {
+	s.SetSoftwareNoteFuncInvoked = true
+	return s.SetSoftwareNoteFunc(id, note)
+}
+
+func (s *SoftwareStore) SoftwareDriftFromReference(hostID, referenceHostID uint) (extra, missing []fleet.Software, err error) {
+	s.SoftwareDriftFromReferenceFuncInvoked = true
+	return s.SoftwareDriftFromReferenceFunc(hostID, referenceHostID)
+}
+
+func (s *SoftwareStore) HostSoftwareDowngrades(hostID uint, since time.Time) ([]fleet.SoftwareChange, error) {
+	s.HostSoftwareDowngradesFuncInvoked = true
+	return s.HostSoftwareDowngradesFunc(hostID, since)
+}
+
+func (s *SoftwareStore) SoftwareSources() ([]string, error) {
+	s.SoftwareSourcesFuncInvoked = true
+	return s.SoftwareSourcesFunc()
+}
+
+func (s *SoftwareStore) HostsWithConflictingSoftwareVersions(name, source string) ([]fleet.Host, error) {
+	s.HostsWithConflictingSoftwareVersionsFuncInvoked = true
+	return s.HostsWithConflictingSoftwareVersionsFunc(name, source)
+}
+
+func (s *SoftwareStore) VerifySoftwareChecksums() (mismatched int, err error) {
+	s.VerifySoftwareChecksumsFuncInvoked = true
+	return s.VerifySoftwareChecksumsFunc()
+}
+
+func (s *SoftwareStore) HostSoftwareAddedSince(hostID uint, since time.Time) ([]fleet.Software, error) {
+	s.HostSoftwareAddedSinceFuncInvoked = true
+	return s.HostSoftwareAddedSinceFunc(hostID, since)
+}
+
+func (s *SoftwareStore) ListSoftwareForHostFilter(filter fleet.HostListOptions, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	s.ListSoftwareForHostFilterFuncInvoked = true
+	return s.ListSoftwareForHostFilterFunc(filter, opt)
+}
+
+func (s *SoftwareStore) CleanupOrphanedSoftware(batchSize int) (deleted int, err error) {
+	s.CleanupOrphanedSoftwareFuncInvoked = true
+	return s.CleanupOrphanedSoftwareFunc(batchSize)
+}
+
+func (s *SoftwareStore) ListSoftware(opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	s.ListSoftwareFuncInvoked = true
+	return s.ListSoftwareFunc(opt)
+}
+
+func (s *SoftwareStore) CalculateHostsPerSoftware(updatedAt time.Time) error {
+	s.CalculateHostsPerSoftwareFuncInvoked = true
+	return s.CalculateHostsPerSoftwareFunc(updatedAt)
+}
+
+func (s *SoftwareStore) ListSoftwareWithoutCPE() ([]fleet.Software, error) {
+	s.ListSoftwareWithoutCPEFuncInvoked = true
+	return s.ListSoftwareWithoutCPEFunc()
+}
+
+func (s *SoftwareStore) AddCPEForSoftware(softwareID uint, cpe string) error {
+	s.AddCPEForSoftwareFuncInvoked = true
+	return s.AddCPEForSoftwareFunc(softwareID, cpe)
+}
+
+func (s *SoftwareStore) ListSoftwareCPEs() ([]fleet.SoftwareCPE, error) {
+	s.ListSoftwareCPEsFuncInvoked = true
+	return s.ListSoftwareCPEsFunc()
+}
+
+func (s *SoftwareStore) ReplaceSoftwareCVEs(softwareID uint, cves []string) error {
+	s.ReplaceSoftwareCVEsFuncInvoked = true
+	return s.ReplaceSoftwareCVEsFunc(softwareID, cves)
+}
+
+func (s *SoftwareStore) ListSoftwareByTeam(teamID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	s.ListSoftwareByTeamFuncInvoked = true
+	return s.ListSoftwareByTeamFunc(teamID, opt)
+}
+
+func (s *SoftwareStore) ExportSoftware(fn func(fleet.Software) error) error {
+	s.ExportSoftwareFuncInvoked = true
+	return s.ExportSoftwareFunc(fn)
+}
+
+func (s *SoftwareStore) CalculateSoftwareTitles(updatedAt time.Time) error {
+	s.CalculateSoftwareTitlesFuncInvoked = true
+	return s.CalculateSoftwareTitlesFunc(updatedAt)
+}
+
+func (s *SoftwareStore) ListSoftwareTitles(opt fleet.ListOptions) ([]fleet.SoftwareTitleSummary, error) {
+	s.ListSoftwareTitlesFuncInvoked = true
+	return s.ListSoftwareTitlesFunc(opt)
+}
+
+func (s *SoftwareStore) ListHostSoftwareVersionsByName(name string) ([]fleet.HostSoftwareVersion, error) {
+	s.ListHostSoftwareVersionsByNameFuncInvoked = true
+	return s.ListHostSoftwareVersionsByNameFunc(name)
+}
+
+func (s *SoftwareStore) SoftwareInventoryEnabled(teamID *uint) (bool, error) {
+	s.SoftwareInventoryEnabledFuncInvoked = true
+	return s.SoftwareInventoryEnabledFunc(teamID)
+}
+
+func (s *SoftwareStore) ListHostSoftwareChanges(hostID uint, opt fleet.ListOptions) ([]fleet.HostSoftwareChange, error) {
+	s.ListHostSoftwareChangesFuncInvoked = true
+	return s.ListHostSoftwareChangesFunc(hostID, opt)
+}
+
+func (s *SoftwareStore) ListHostOSVersions() ([]fleet.HostOSVersion, error) {
+	s.ListHostOSVersionsFuncInvoked = true
+	return s.ListHostOSVersionsFunc()
+}
+
+func (s *SoftwareStore) ListSoftwareByHostOS(os fleet.HostOSVersion, source string) ([]fleet.Software, error) {
+	s.ListSoftwareByHostOSFuncInvoked = true
+	return s.ListSoftwareByHostOSFunc(os, source)
+}
+
+func (s *SoftwareStore) ReplaceSoftwareOVALResults(softwareID uint, results []fleet.SoftwareOVALResult) error {
+	s.ReplaceSoftwareOVALResultsFuncInvoked = true
+	return s.ReplaceSoftwareOVALResultsFunc(softwareID, results)
+}
+
+func (s *SoftwareStore) LoadSoftwareVulnerabilities(software []fleet.Software) error {
+	s.LoadSoftwareVulnerabilitiesFuncInvoked = true
+	return s.LoadSoftwareVulnerabilitiesFunc(software)
+}
+
+func (s *SoftwareStore) InsertCVEMeta(meta []fleet.CVEMeta) error {
+	s.InsertCVEMetaFuncInvoked = true
+	return s.InsertCVEMetaFunc(meta)
+}
+
+func (s *SoftwareStore) ListSoftwareVulnerabilitiesSince(since time.Time) ([]fleet.SoftwareVulnerability, error) {
+	s.ListSoftwareVulnerabilitiesSinceFuncInvoked = true
+	return s.ListSoftwareVulnerabilitiesSinceFunc(since)
+}
+
+func (s *SoftwareStore) ListHostSoftwareVersionsBySoftwareID(softwareIDs []uint) ([]fleet.HostSoftwareVersion, error) {
+	s.ListHostSoftwareVersionsBySoftwareIDFuncInvoked = true
+	return s.ListHostSoftwareVersionsBySoftwareIDFunc(softwareIDs)
+}
+
+func (s *SoftwareStore) NewSoftwareCVESuppression(suppression *fleet.SoftwareCVESuppression) (*fleet.SoftwareCVESuppression, error) {
+	s.NewSoftwareCVESuppressionFuncInvoked = true
+	return s.NewSoftwareCVESuppressionFunc(suppression)
+}
+
+func (s *SoftwareStore) SoftwareCVESuppression(id uint) (*fleet.SoftwareCVESuppression, error) {
+	s.SoftwareCVESuppressionFuncInvoked = true
+	return s.SoftwareCVESuppressionFunc(id)
+}
+
+func (s *SoftwareStore) ListSoftwareCVESuppressions(opt fleet.ListOptions) ([]fleet.SoftwareCVESuppression, error) {
+	s.ListSoftwareCVESuppressionsFuncInvoked = true
+	return s.ListSoftwareCVESuppressionsFunc(opt)
+}
+
+func (s *SoftwareStore) DeleteSoftwareCVESuppression(id uint) error {
+	s.DeleteSoftwareCVESuppressionFuncInvoked = true
+	return s.DeleteSoftwareCVESuppressionFunc(id)
+}
+
+func (s *SoftwareStore) ListWindowsHostUpdates() ([]fleet.WindowsHostUpdates, error) {
+	s.ListWindowsHostUpdatesFuncInvoked = true
+	return s.ListWindowsHostUpdatesFunc()
+}
+
+func (s *SoftwareStore) ReplaceHostOperatingSystemVulnerabilities(hostID uint, vulnerabilities []fleet.OperatingSystemVulnerability) error {
+	s.ReplaceHostOperatingSystemVulnerabilitiesFuncInvoked = true
+	return s.ReplaceHostOperatingSystemVulnerabilitiesFunc(hostID, vulnerabilities)
+}
+
+func (s *SoftwareStore) ListHostOperatingSystemVulnerabilities(hostID uint) ([]fleet.OperatingSystemVulnerability, error) {
+	s.ListHostOperatingSystemVulnerabilitiesFuncInvoked = true
+	return s.ListHostOperatingSystemVulnerabilitiesFunc(hostID)
+}
@@ -21,6 +21,11 @@ func Uint(x uint) *uint {
 	return &x
 }
 
+// Float64 returns a pointer to the provided float64.
+func Float64(x float64) *float64 {
+	return &x
+}
+
 // Bool returns a pointer to the provided bool.
 func Bool(x bool) *bool {
 	return &x
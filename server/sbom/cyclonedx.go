@@ -0,0 +1,116 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/google/uuid"
+	"github.com/kolide/kit/version"
+	"github.com/pkg/errors"
+)
+
+// CycloneDXContentType is the media type of CycloneDX JSON documents.
+const CycloneDXContentType = "application/vnd.cyclonedx+json"
+
+type cycloneDXBOM struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Tools     []cycloneDXTool    `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cycloneDXLibrarySources are the software sources of libraries rather than
+// applications.
+var cycloneDXLibrarySources = map[string]bool{
+	"npm_packages":    true,
+	"python_packages": true,
+}
+
+// CycloneDX renders the software of the host as a CycloneDX 1.4 JSON
+// document created at the provided time. The host is the component the
+// document describes.
+func CycloneDX(host *fleet.Host, now time.Time) ([]byte, error) {
+	serial, err := uuid.NewRandom()
+	if err != nil {
+		return nil, errors.Wrap(err, "generate serial number")
+	}
+
+	bom := cycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: "urn:uuid:" + serial.String(),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: now.UTC(),
+			Tools:     []cycloneDXTool{{Vendor: "Fleet", Name: "fleet", Version: version.Version().Version}},
+			Component: cycloneDXComponent{
+				Type:    "device",
+				BOMRef:  fmt.Sprintf("host-%d", host.ID),
+				Name:    host.Hostname,
+				Version: host.OSVersion,
+				Properties: []cycloneDXProperty{
+					{Name: "fleet:host:uuid", Value: host.UUID},
+					{Name: "fleet:host:platform", Value: host.Platform},
+				},
+			},
+		},
+		Components: make([]cycloneDXComponent, 0, len(host.Software)),
+	}
+	for _, s := range host.Software {
+		componentType := "application"
+		if cycloneDXLibrarySources[s.Source] {
+			componentType = "library"
+		}
+		properties := []cycloneDXProperty{{Name: "fleet:software:source", Value: s.Source}}
+		if s.BundleIdentifier != "" {
+			properties = append(properties, cycloneDXProperty{Name: "fleet:software:bundle_identifier", Value: s.BundleIdentifier})
+		}
+		if s.Arch != "" {
+			properties = append(properties, cycloneDXProperty{Name: "fleet:software:arch", Value: s.Arch})
+		}
+		bom.Components = append(bom.Components, cycloneDXComponent{
+			Type:       componentType,
+			BOMRef:     softwareRef(s),
+			Name:       s.Name,
+			Version:    s.Version,
+			PURL:       PackageURL(host, s),
+			Properties: properties,
+		})
+	}
+
+	b, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal cyclonedx document")
+	}
+	return b, nil
+}
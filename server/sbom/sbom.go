@@ -0,0 +1,71 @@
+// Package sbom renders the software inventory of hosts as software bills of
+// materials in the CycloneDX and SPDX formats.
+package sbom
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+// purlTypes are the package URL types of the software sources managed by a
+// package manager with a package URL type. The deb and rpm packages are
+// namespaced by the distribution of the host.
+var purlTypes = map[string]string{
+	"deb_packages":    "deb",
+	"rpm_packages":    "rpm",
+	"npm_packages":    "npm",
+	"python_packages": "pypi",
+}
+
+// PackageURL returns the package URL (purl) identifying the software installed
+// on the host, or an empty string if the software source has no package URL
+// type.
+func PackageURL(host *fleet.Host, s fleet.Software) string {
+	purlType, ok := purlTypes[s.Source]
+	if !ok || s.Name == "" {
+		return ""
+	}
+
+	name := s.Name
+	namespace := ""
+	switch purlType {
+	case "deb", "rpm":
+		namespace = strings.ToLower(host.Platform)
+	case "npm":
+		// Scoped packages are namespaced by their scope.
+		if i := strings.Index(name, "/"); strings.HasPrefix(name, "@") && i > 0 {
+			namespace, name = name[:i], name[i+1:]
+		}
+	case "pypi":
+		name = strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	}
+
+	var b strings.Builder
+	b.WriteString("pkg:" + purlType + "/")
+	if namespace != "" {
+		b.WriteString(purlEscape(namespace) + "/")
+	}
+	b.WriteString(purlEscape(name))
+	if s.Version != "" {
+		b.WriteString("@" + purlEscape(s.Version))
+	}
+	if s.Arch != "" && (purlType == "deb" || purlType == "rpm") {
+		b.WriteString("?arch=" + url.QueryEscape(s.Arch))
+	}
+	return b.String()
+}
+
+// purlEscape percent-encodes a segment of a package URL. Unlike in URL paths,
+// the @ separates the version and must be encoded.
+func purlEscape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+}
+
+// softwareRef is the identifier of the software in a document, unique among
+// the software of a host.
+func softwareRef(s fleet.Software) string {
+	return fmt.Sprintf("software-%d", s.ID)
+}
@@ -0,0 +1,124 @@
+package sbom
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHost() *fleet.Host {
+	return &fleet.Host{
+		ID:        7,
+		Hostname:  "host1",
+		UUID:      "host1uuid",
+		Platform:  "ubuntu",
+		OSVersion: "Ubuntu 20.04.2 LTS",
+		HostSoftware: fleet.HostSoftware{
+			Software: []fleet.Software{
+				{ID: 1, Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages", Arch: "amd64"},
+				{ID: 2, Name: "@babel/core", Version: "7.15.0", Source: "npm_packages"},
+				{ID: 3, Name: "Google Chrome.app", Version: "92.0.4515.131", Source: "apps", BundleIdentifier: "com.google.Chrome"},
+			},
+		},
+	}
+}
+
+func TestPackageURL(t *testing.T) {
+	host := &fleet.Host{Platform: "centos"}
+	testCases := []struct {
+		software fleet.Software
+		purl     string
+	}{
+		{fleet.Software{Name: "openssl", Version: "1:1.1.1g-15.el8_3", Source: "rpm_packages", Arch: "x86_64"}, "pkg:rpm/centos/openssl@1:1.1.1g-15.el8_3?arch=x86_64"},
+		{fleet.Software{Name: "zsh", Version: "5.8-3ubuntu1", Source: "deb_packages"}, "pkg:deb/centos/zsh@5.8-3ubuntu1"},
+		{fleet.Software{Name: "@babel/core", Version: "7.15.0", Source: "npm_packages"}, "pkg:npm/%40babel/core@7.15.0"},
+		{fleet.Software{Name: "Django_Extensions", Version: "3.1.3", Source: "python_packages"}, "pkg:pypi/django-extensions@3.1.3"},
+		{fleet.Software{Name: "requests", Source: "python_packages"}, "pkg:pypi/requests"},
+		{fleet.Software{Name: "Slack.app", Version: "4.18.0", Source: "apps"}, ""},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.software.Name, func(t *testing.T) {
+			assert.Equal(t, tt.purl, PackageURL(host, tt.software))
+		})
+	}
+}
+
+func TestCycloneDX(t *testing.T) {
+	now := time.Date(2021, 8, 18, 10, 0, 0, 0, time.UTC)
+	b, err := CycloneDX(testHost(), now)
+	require.NoError(t, err)
+
+	var bom cycloneDXBOM
+	require.NoError(t, json.Unmarshal(b, &bom))
+	assert.Equal(t, "CycloneDX", bom.BOMFormat)
+	assert.Equal(t, "1.4", bom.SpecVersion)
+	assert.True(t, strings.HasPrefix(bom.SerialNumber, "urn:uuid:"))
+	assert.Equal(t, now, bom.Metadata.Timestamp)
+	assert.Equal(t, "device", bom.Metadata.Component.Type)
+	assert.Equal(t, "host1", bom.Metadata.Component.Name)
+
+	require.Len(t, bom.Components, 3)
+	assert.Equal(t, cycloneDXComponent{
+		Type:    "application",
+		BOMRef:  "software-1",
+		Name:    "openssl",
+		Version: "1.1.1f-1ubuntu2",
+		PURL:    "pkg:deb/ubuntu/openssl@1.1.1f-1ubuntu2?arch=amd64",
+		Properties: []cycloneDXProperty{
+			{Name: "fleet:software:source", Value: "deb_packages"},
+			{Name: "fleet:software:arch", Value: "amd64"},
+		},
+	}, bom.Components[0])
+	assert.Equal(t, "library", bom.Components[1].Type)
+	assert.Equal(t, "", bom.Components[2].PURL)
+	assert.Contains(t, bom.Components[2].Properties, cycloneDXProperty{Name: "fleet:software:bundle_identifier", Value: "com.google.Chrome"})
+
+	// Every document has its own serial number.
+	other, err := CycloneDX(testHost(), now)
+	require.NoError(t, err)
+	assert.NotEqual(t, b, other)
+}
+
+func TestSPDX(t *testing.T) {
+	now := time.Date(2021, 8, 18, 10, 0, 0, 0, time.UTC)
+	b, err := SPDX(testHost(), now)
+	require.NoError(t, err)
+
+	var doc spdxDocument
+	require.NoError(t, json.Unmarshal(b, &doc))
+	assert.Equal(t, "SPDX-2.2", doc.SPDXVersion)
+	assert.Equal(t, "CC0-1.0", doc.DataLicense)
+	assert.Equal(t, "SPDXRef-DOCUMENT", doc.SPDXID)
+	assert.True(t, strings.HasPrefix(doc.DocumentNamespace, "https://fleetdm.com/spdxdocs/hosts/7-"))
+	assert.Equal(t, "2021-08-18T10:00:00Z", doc.CreationInfo.Created)
+
+	require.Len(t, doc.Packages, 3)
+	assert.Equal(t, spdxPackage{
+		Name:             "openssl",
+		SPDXID:           "SPDXRef-software-1",
+		VersionInfo:      "1.1.1f-1ubuntu2",
+		DownloadLocation: "NOASSERTION",
+		LicenseConcluded: "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		CopyrightText:    "NOASSERTION",
+		Comment:          "source: deb_packages",
+		ExternalRefs: []spdxExternalRef{{
+			ReferenceCategory: "PACKAGE_MANAGER",
+			ReferenceType:     "purl",
+			ReferenceLocator:  "pkg:deb/ubuntu/openssl@1.1.1f-1ubuntu2?arch=amd64",
+		}},
+	}, doc.Packages[0])
+	assert.Empty(t, doc.Packages[2].ExternalRefs)
+
+	require.Len(t, doc.Relationships, 3)
+	assert.Equal(t, spdxRelationship{
+		SPDXElementID:      "SPDXRef-DOCUMENT",
+		RelationshipType:   "DESCRIBES",
+		RelatedSPDXElement: "SPDXRef-software-3",
+	}, doc.Relationships[2])
+}
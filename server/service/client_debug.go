@@ -7,9 +7,8 @@ import (
 	"github.com/pkg/errors"
 )
 
-// DebugPprof calls the /debug/pprof/ endpoints.
-func (c *Client) DebugPprof(name string) ([]byte, error) {
-	endpoint := "/debug/pprof/" + name
+// debugGet calls a debug endpoint and returns the response body.
+func (c *Client) debugGet(endpoint string) ([]byte, error) {
 	response, err := c.AuthenticatedDo("GET", endpoint, "", nil)
 	if err != nil {
 		return nil, errors.Wrapf(err, "GET %s", endpoint)
@@ -18,15 +17,37 @@ func (c *Client) DebugPprof(name string) ([]byte, error) {
 
 	if response.StatusCode != http.StatusOK {
 		return nil, errors.Errorf(
-			"get pprof received status %d",
+			"get %s received status %d",
+			endpoint,
 			response.StatusCode,
 		)
 	}
 
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return nil, errors.Wrap(err, "read pprof response body")
+		return nil, errors.Wrapf(err, "read %s response body", endpoint)
 	}
 
 	return body, nil
 }
+
+// DebugPprof calls the /debug/pprof/ endpoints.
+func (c *Client) DebugPprof(name string) ([]byte, error) {
+	return c.debugGet("/debug/pprof/" + name)
+}
+
+// DebugDBStats returns the statistics of the connections of the server to
+// the database, as JSON.
+func (c *Client) DebugDBStats() ([]byte, error) {
+	return c.debugGet("/debug/db/stats")
+}
+
+// DebugMigrations returns the status of the database migrations, as JSON.
+func (c *Client) DebugMigrations() ([]byte, error) {
+	return c.debugGet("/debug/migrations")
+}
+
+// DebugErrors returns the errors recently logged by the server, as JSON.
+func (c *Client) DebugErrors() ([]byte, error) {
+	return c.debugGet("/debug/errors")
+}
@@ -8,9 +8,10 @@ import (
 	"github.com/pkg/errors"
 )
 
-// GetHosts retrieves the list of all Hosts
-func (c *Client) GetHosts() ([]HostResponse, error) {
-	response, err := c.AuthenticatedDo("GET", "/api/v1/fleet/hosts", "", nil)
+// GetHosts retrieves the list of all Hosts. The query is the raw query of the
+// list hosts request, filtering the hosts.
+func (c *Client) GetHosts(query string) ([]HostResponse, error) {
+	response, err := c.AuthenticatedDo("GET", "/api/v1/fleet/hosts", query, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "GET /api/v1/fleet/hosts")
 	}
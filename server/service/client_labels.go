@@ -103,6 +103,40 @@ func (c *Client) GetLabels() ([]*fleet.LabelSpec, error) {
 	return responseBody.Specs, nil
 }
 
+// ListLabels retrieves the list of all Labels, with their IDs.
+func (c *Client) ListLabels() ([]*fleet.Label, error) {
+	response, err := c.AuthenticatedDo("GET", "/api/v1/fleet/labels", "", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "GET /api/v1/fleet/labels")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(
+			"list labels received status %d %s",
+			response.StatusCode,
+			extractServerErrorText(response.Body),
+		)
+	}
+
+	var responseBody listLabelsResponse
+	err = json.NewDecoder(response.Body).Decode(&responseBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode list labels response")
+	}
+
+	if responseBody.Err != nil {
+		return nil, errors.Errorf("list labels: %s", responseBody.Err)
+	}
+
+	labels := make([]*fleet.Label, 0, len(responseBody.Labels))
+	for _, label := range responseBody.Labels {
+		label := label.Label
+		labels = append(labels, &label)
+	}
+	return labels, nil
+}
+
 // DeleteLabel deletes the label with the matching name.
 func (c *Client) DeleteLabel(name string) error {
 	verb, path := "DELETE", "/api/v1/fleet/labels/"+url.PathEscape(name)
@@ -58,11 +58,12 @@ func (h *LiveQueryResultsHandler) Status() *campaignStatus {
 	return nil
 }
 
-// LiveQuery creates a new live query and begins streaming results.
-func (c *Client) LiveQuery(query string, labels []string, hosts []string) (*LiveQueryResultsHandler, error) {
+// LiveQuery creates a new live query and begins streaming results. The hosts
+// in the labels and teams are resolved by the server.
+func (c *Client) LiveQuery(query string, labels []string, hosts []string, teams []string) (*LiveQueryResultsHandler, error) {
 	req := createDistributedQueryCampaignByNamesRequest{
 		QuerySQL: query,
-		Selected: distributedQueryCampaignTargetsByNames{Labels: labels, Hosts: hosts},
+		Selected: distributedQueryCampaignTargetsByNames{Labels: labels, Hosts: hosts, Teams: teams},
 	}
 	response, err := c.AuthenticatedDo("POST", "/api/v1/fleet/queries/run_by_names", "", req)
 	if err != nil {
@@ -74,3 +74,34 @@ func (c *Client) Logout() error {
 
 	return nil
 }
+
+// InitiateSSO starts an SSO login and returns the URL of the identity
+// provider to log in with. Once logged in, the user is redirected to
+// relayURL.
+func (c *Client) InitiateSSO(relayURL string) (string, error) {
+	response, err := c.Do("POST", "/api/v1/fleet/sso", "", initiateSSORequest{RelayURL: relayURL})
+	if err != nil {
+		return "", errors.Wrap(err, "POST /api/v1/fleet/sso")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", errors.Errorf(
+			"initiate sso received status %d %s",
+			response.StatusCode,
+			extractServerErrorText(response.Body),
+		)
+	}
+
+	var responseBody initiateSSOResponse
+	err = json.NewDecoder(response.Body).Decode(&responseBody)
+	if err != nil {
+		return "", errors.Wrap(err, "decode initiate sso response")
+	}
+
+	if responseBody.Err != nil {
+		return "", errors.Errorf("initiate sso: %s", responseBody.Err)
+	}
+
+	return responseBody.URL, nil
+}
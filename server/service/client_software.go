@@ -0,0 +1,59 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/pkg/errors"
+)
+
+// ListSoftware retrieves the software installed on the hosts.
+func (c *Client) ListSoftware() ([]fleet.Software, error) {
+	response, err := c.AuthenticatedDo("GET", "/api/v1/fleet/software", "", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "GET /api/v1/fleet/software")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(
+			"list software received status %d %s",
+			response.StatusCode,
+			extractServerErrorText(response.Body),
+		)
+	}
+	var responseBody listSoftwareResponse
+	err = json.NewDecoder(response.Body).Decode(&responseBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode list software response")
+	}
+	if responseBody.Err != nil {
+		return nil, errors.Errorf("list software: %s", responseBody.Err)
+	}
+
+	return responseBody.Software, nil
+}
+
+// ExportSoftware copies the CSV export of the software inventory to w as it
+// is received.
+func (c *Client) ExportSoftware(w io.Writer) error {
+	response, err := c.AuthenticatedDo("GET", "/api/v1/fleet/software/export", "", nil)
+	if err != nil {
+		return errors.Wrap(err, "GET /api/v1/fleet/software/export")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf(
+			"export software received status %d %s",
+			response.StatusCode,
+			extractServerErrorText(response.Body),
+		)
+	}
+	if _, err := io.Copy(w, response.Body); err != nil {
+		return errors.Wrap(err, "read software export")
+	}
+	return nil
+}
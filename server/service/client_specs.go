@@ -0,0 +1,52 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/pkg/errors"
+)
+
+// ValidateSpecs validates the specs on the server without applying them, and
+// returns the changes applying them would make. All the validation errors are
+// reported, one per line.
+func (c *Client) ValidateSpecs(specs *fleet.Specs) ([]*fleet.SpecChange, error) {
+	verb, path := "POST", "/api/v1/fleet/spec/validate"
+	response, err := c.AuthenticatedDo(verb, path, "", validateSpecsRequest{Specs: specs})
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s %s", verb, path)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnprocessableEntity:
+		var serverErr serverError
+		if err := json.NewDecoder(response.Body).Decode(&serverErr); err != nil {
+			return nil, errors.Wrapf(err, "decode %s %s response", verb, path)
+		}
+		reasons := make([]string, 0, len(serverErr.Errors))
+		for _, e := range serverErr.Errors {
+			reasons = append(reasons, e.Reason)
+		}
+		return nil, errors.Errorf("invalid specs:\n%s", strings.Join(reasons, "\n"))
+	default:
+		return nil, errors.Errorf(
+			"%s %s received status %d %s",
+			verb, path,
+			response.StatusCode,
+			extractServerErrorText(response.Body),
+		)
+	}
+
+	var responseBody validateSpecsResponse
+	if err := json.NewDecoder(response.Body).Decode(&responseBody); err != nil {
+		return nil, errors.Wrapf(err, "decode %s %s response", verb, path)
+	}
+	if responseBody.Err != nil {
+		return nil, errors.Errorf("%s %s error: %s", verb, path, responseBody.Err)
+	}
+	return responseBody.Changes, nil
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"fmt"
+
 	"github.com/fleetdm/fleet/v4/server/fleet"
 )
 
@@ -31,3 +33,23 @@ func (c *Client) ApplyUsersRoleSecretSpec(spec *fleet.UsersRoleSpec) error {
 	var responseBody applyUserRoleSpecsResponse
 	return c.authenticatedRequest(req, verb, path, &responseBody)
 }
+
+// DeleteUser deletes the user with the given ID.
+func (c *Client) DeleteUser(id uint) error {
+	verb, path := "DELETE", fmt.Sprintf("/api/v1/fleet/users/%d", id)
+	var responseBody deleteUserResponse
+
+	return c.authenticatedRequest(nil, verb, path, &responseBody)
+}
+
+// Me returns the user the client is logged in as.
+func (c *Client) Me() (*fleet.User, error) {
+	verb, path := "GET", "/api/v1/fleet/me"
+	var responseBody getUserResponse
+
+	err := c.authenticatedRequest(nil, verb, path, &responseBody)
+	if err != nil {
+		return nil, err
+	}
+	return responseBody.User, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	kitlog "github.com/go-kit/kit/log"
+)
+
+// RecentErrors keeps the most recent errors logged by the server, so that
+// they can be retrieved with the debug endpoints.
+type RecentErrors struct {
+	mu     sync.Mutex
+	max    int
+	errors []map[string]string
+}
+
+// NewRecentErrors returns a RecentErrors keeping up to max errors.
+func NewRecentErrors(max int) *RecentErrors {
+	return &RecentErrors{max: max}
+}
+
+// Logger returns a logger recording the log lines with a non-nil err before
+// passing them to next.
+func (r *RecentErrors) Logger(next kitlog.Logger) kitlog.Logger {
+	return kitlog.LoggerFunc(func(keyvals ...interface{}) error {
+		r.record(keyvals)
+		return next.Log(keyvals...)
+	})
+}
+
+func (r *RecentErrors) record(keyvals []interface{}) {
+	isErr := false
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if keyvals[i] == "err" && keyvals[i+1] != nil {
+			isErr = true
+			break
+		}
+	}
+	if !isErr {
+		return
+	}
+
+	entry := make(map[string]string, len(keyvals)/2)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		entry[fmt.Sprint(keyvals[i])] = fmt.Sprint(keyvals[i+1])
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, entry)
+	if len(r.errors) > r.max {
+		r.errors = r.errors[len(r.errors)-r.max:]
+	}
+}
+
+// Errors returns the recorded errors, oldest first.
+func (r *RecentErrors) Errors() []map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errors := make([]map[string]string, len(r.errors))
+	copy(errors, r.errors)
+	return errors
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"net/http"
 	"net/http/pprof"
 
@@ -41,9 +43,50 @@ func (m *debugAuthenticationMiddleware) Middleware(next http.Handler) http.Handl
 	})
 }
 
-// MakeDebugHandler creates an HTTP handler for the Fleet debug endpoints.
-func MakeDebugHandler(svc fleet.Service, config config.FleetConfig, logger kitlog.Logger) http.Handler {
+// dbStatser is implemented by the datastores reporting the statistics of their
+// connections to the database.
+type dbStatser interface {
+	DBStats() sql.DBStats
+}
+
+var migrationStatusNames = map[fleet.MigrationStatus]string{
+	fleet.NoMigrationsCompleted:   "none",
+	fleet.SomeMigrationsCompleted: "incomplete",
+	fleet.AllMigrationsCompleted:  "complete",
+}
+
+func writeDebugJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// MakeDebugHandler creates an HTTP handler for the Fleet debug endpoints. The
+// database endpoints are only served when ds is set, and the recent errors
+// when errs is set.
+func MakeDebugHandler(svc fleet.Service, config config.FleetConfig, logger kitlog.Logger, ds fleet.Datastore, errs *RecentErrors) http.Handler {
 	r := mux.NewRouter()
+	if ds != nil {
+		r.HandleFunc("/debug/migrations", func(rw http.ResponseWriter, req *http.Request) {
+			status, err := ds.MigrationStatus()
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeDebugJSON(rw, map[string]string{"status": migrationStatusNames[status]})
+		})
+		if statser, ok := ds.(dbStatser); ok {
+			r.HandleFunc("/debug/db/stats", func(rw http.ResponseWriter, req *http.Request) {
+				writeDebugJSON(rw, statser.DBStats())
+			})
+		}
+	}
+	if errs != nil {
+		r.HandleFunc("/debug/errors", func(rw http.ResponseWriter, req *http.Request) {
+			writeDebugJSON(rw, errs.Errors())
+		})
+	}
 	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
 	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
 	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
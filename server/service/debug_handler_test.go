@@ -2,15 +2,20 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/fleetdm/fleet/v4/server/config"
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	fleetmock "github.com/fleetdm/fleet/v4/server/mock"
+	kitlog "github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type mockService struct {
@@ -39,7 +44,7 @@ var testConfig = config.FleetConfig{
 }
 
 func TestDebugHandlerAuthenticationTokenMissing(t *testing.T) {
-	handler := MakeDebugHandler(&mockService{}, testConfig, nil)
+	handler := MakeDebugHandler(&mockService{}, testConfig, nil, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "https://fleetdm.com/debug/pprof/profile", nil)
 	res := httptest.NewRecorder()
@@ -56,7 +61,7 @@ func TestDebugHandlerAuthenticationSessionInvalid(t *testing.T) {
 		"fake_session_key",
 	).Return(nil, errors.New("invalid session"))
 
-	handler := MakeDebugHandler(svc, testConfig, nil)
+	handler := MakeDebugHandler(svc, testConfig, nil, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "https://fleetdm.com/debug/pprof/profile", nil)
 	req.Header.Add("Authorization", "BEARER fake_session_key")
@@ -79,7 +84,7 @@ func TestDebugHandlerAuthenticationSuccess(t *testing.T) {
 		uint(42),
 	).Return(&fleet.User{}, nil)
 
-	handler := MakeDebugHandler(svc, testConfig, nil)
+	handler := MakeDebugHandler(svc, testConfig, nil, nil, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "https://fleetdm.com/debug/pprof/cmdline", nil)
 	req.Header.Add("Authorization", "BEARER fake_session_key")
@@ -88,3 +93,59 @@ func TestDebugHandlerAuthenticationSuccess(t *testing.T) {
 	handler.ServeHTTP(res, req)
 	assert.Equal(t, http.StatusOK, res.Code)
 }
+
+type dbStatsStore struct {
+	fleetmock.Store
+}
+
+func (s *dbStatsStore) DBStats() sql.DBStats {
+	return sql.DBStats{OpenConnections: 3, InUse: 1, Idle: 2}
+}
+
+func TestDebugHandlerDatabaseAndErrors(t *testing.T) {
+	svc := &mockService{}
+	svc.On(
+		"GetSessionByKey",
+		mock.Anything,
+		"fake_session_key",
+	).Return(&fleet.Session{UserID: 42, ID: 1}, nil)
+	svc.On(
+		"UserUnauthorized",
+		mock.Anything,
+		uint(42),
+	).Return(&fleet.User{}, nil)
+
+	errs := NewRecentErrors(2)
+	logger := errs.Logger(kitlog.NewNopLogger())
+	require.NoError(t, logger.Log("method", "ListHosts", "err", nil))
+	require.NoError(t, logger.Log("method", "GetHost", "err", errors.New("first")))
+	require.NoError(t, logger.Log("method", "GetQuery", "err", errors.New("second")))
+	require.NoError(t, logger.Log("method", "GetPack", "err", errors.New("third")))
+
+	handler := MakeDebugHandler(svc, testConfig, nil, &dbStatsStore{}, errs)
+	get := func(path string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "https://fleetdm.com"+path, nil)
+		req.Header.Add("Authorization", "BEARER fake_session_key")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code, res.Body.String()
+	}
+
+	code, body := get("/debug/db/stats")
+	assert.Equal(t, http.StatusOK, code)
+	var stats sql.DBStats
+	require.NoError(t, json.Unmarshal([]byte(body), &stats))
+	assert.Equal(t, 3, stats.OpenConnections)
+
+	code, body = get("/debug/migrations")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"status": "none"}`, body)
+
+	// Only the last two errors are kept, oldest first.
+	code, body = get("/debug/errors")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `[
+		{"method": "GetQuery", "err": "second"},
+		{"method": "GetPack", "err": "third"}
+	]`, body)
+}
@@ -22,6 +22,10 @@ type appConfigResponse struct {
 	SSOSettings        *fleet.SSOSettingsPayload  `json:"sso_settings,omitempty"`
 	HostExpirySettings *fleet.HostExpirySettings  `json:"host_expiry_settings,omitempty"`
 	HostSettings       *fleet.HostSettings        `json:"host_settings,omitempty"`
+	Features           *fleet.Features            `json:"features,omitempty"`
+	WebhookSettings    *fleet.WebhookSettings     `json:"webhook_settings,omitempty"`
+	SoftwareSettings   *fleet.SoftwareSettings    `json:"software_settings,omitempty"`
+	Integrations       *fleet.Integrations        `json:"integrations,omitempty"`
 	AgentOptions       *json.RawMessage           `json:"agent_options,omitempty"`
 	License            *fleet.LicenseInfo         `json:"license,omitempty"`
 	Err                error                      `json:"error,omitempty"`
@@ -48,7 +52,9 @@ func makeGetAppConfigEndpoint(svc fleet.Service) endpoint.Endpoint {
 		var ssoSettings *fleet.SSOSettingsPayload
 		var hostExpirySettings *fleet.HostExpirySettings
 		var agentOptions *json.RawMessage
-		// only admin can see smtp, sso, and host expiry settings
+		var webhookSettings *fleet.WebhookSettings
+		var integrations *fleet.Integrations
+		// only admin can see smtp, sso, host expiry, webhook and integration settings
 		if vc.User.GlobalRole != nil && *vc.User.GlobalRole == fleet.RoleAdmin {
 			smtpSettings = smtpSettingsFromAppConfig(config)
 			if smtpSettings.SMTPPassword != nil {
@@ -69,6 +75,8 @@ func makeGetAppConfigEndpoint(svc fleet.Service) endpoint.Endpoint {
 				HostExpiryWindow:  &config.HostExpiryWindow,
 			}
 			agentOptions = config.AgentOptions
+			webhookSettings = webhookSettingsFromAppConfig(config)
+			integrations = integrationsFromAppConfig(config)
 		}
 		hostSettings := &fleet.HostSettings{
 			AdditionalQueries: config.AdditionalQueries,
@@ -92,6 +100,12 @@ func makeGetAppConfigEndpoint(svc fleet.Service) endpoint.Endpoint {
 			HostSettings:       hostSettings,
 			License:            license,
 			AgentOptions:       agentOptions,
+			Features: &fleet.Features{
+				EnableSoftwareInventory: &config.EnableSoftwareInventory,
+			},
+			WebhookSettings:  webhookSettings,
+			SoftwareSettings: softwareSettingsFromAppConfig(config),
+			Integrations:     integrations,
 		}
 		return response, nil
 	}
@@ -135,6 +149,12 @@ func makeModifyAppConfigEndpoint(svc fleet.Service) endpoint.Endpoint {
 			},
 			License:      license,
 			AgentOptions: config.AgentOptions,
+			Features: &fleet.Features{
+				EnableSoftwareInventory: &config.EnableSoftwareInventory,
+			},
+			WebhookSettings:  webhookSettingsFromAppConfig(config),
+			SoftwareSettings: softwareSettingsFromAppConfig(config),
+			Integrations:     integrationsFromAppConfig(config),
 		}
 		if response.SMTPSettings.SMTPPassword != nil {
 			*response.SMTPSettings.SMTPPassword = "********"
@@ -163,6 +183,55 @@ func smtpSettingsFromAppConfig(config *fleet.AppConfig) *fleet.SMTPSettingsPaylo
 	}
 }
 
+func webhookSettingsFromAppConfig(config *fleet.AppConfig) *fleet.WebhookSettings {
+	watchlist := config.SoftwareInstalledWebhookWatchlist
+	if watchlist.NamePatterns == nil {
+		watchlist.NamePatterns = []string{}
+	}
+	if watchlist.CPEs == nil {
+		watchlist.CPEs = []string{}
+	}
+	return &fleet.WebhookSettings{
+		SoftwareInstalledWebhook: &fleet.SoftwareInstalledWebhookSettings{
+			Enable:         &config.SoftwareInstalledWebhookEnabled,
+			DestinationURL: &config.SoftwareInstalledWebhookURL,
+			Watchlist:      &watchlist,
+		},
+		VulnerabilitiesWebhook: &fleet.VulnerabilitiesWebhookSettings{
+			Enable:         &config.VulnerabilitiesWebhookEnabled,
+			DestinationURL: &config.VulnerabilitiesWebhookURL,
+			HostBatchSize:  &config.VulnerabilitiesWebhookHostBatchSize,
+			MinCVSSScore:   &config.VulnerabilitiesWebhookMinCVSSScore,
+		},
+	}
+}
+
+func softwareSettingsFromAppConfig(config *fleet.AppConfig) *fleet.SoftwareSettings {
+	rules := config.SoftwareNormalizationRules
+	if rules == nil {
+		rules = fleet.SoftwareNormalizationRules{}
+	}
+	return &fleet.SoftwareSettings{NormalizationRules: &rules}
+}
+
+// integrationsFromAppConfig returns the integration settings, with the
+// secrets masked.
+func integrationsFromAppConfig(config *fleet.AppConfig) *fleet.Integrations {
+	apiToken := ""
+	if config.JiraAPIToken != "" {
+		apiToken = "********"
+	}
+	return &fleet.Integrations{
+		Jira: &fleet.JiraIntegration{
+			Enable:     &config.JiraIntegrationEnabled,
+			URL:        &config.JiraURL,
+			Username:   &config.JiraUsername,
+			APIToken:   &apiToken,
+			ProjectKey: &config.JiraProjectKey,
+		},
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Apply Enroll Secret Spec
 ////////////////////////////////////////////////////////////////////////////////
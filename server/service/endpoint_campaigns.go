@@ -54,12 +54,13 @@ type createDistributedQueryCampaignByNamesRequest struct {
 type distributedQueryCampaignTargetsByNames struct {
 	Labels []string `json:"labels"`
 	Hosts  []string `json:"hosts"`
+	Teams  []string `json:"teams"`
 }
 
 func makeCreateDistributedQueryCampaignByNamesEndpoint(svc fleet.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(createDistributedQueryCampaignByNamesRequest)
-		campaign, err := svc.NewDistributedQueryCampaignByNames(ctx, req.QuerySQL, req.QueryID, req.Selected.Hosts, req.Selected.Labels)
+		campaign, err := svc.NewDistributedQueryCampaignByNames(ctx, req.QuerySQL, req.QueryID, req.Selected.Hosts, req.Selected.Labels, req.Selected.Teams)
 		if err != nil {
 			return createDistributedQueryCampaignResponse{Err: err}, nil
 		}
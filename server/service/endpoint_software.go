@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/sbom"
+	"github.com/go-kit/kit/endpoint"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// List software
+////////////////////////////////////////////////////////////////////////////////
+
+type listSoftwareRequest struct {
+	TeamID      *uint
+	ListOptions fleet.SoftwareListOptions
+}
+
+type listSoftwareResponse struct {
+	Software []fleet.Software `json:"software"`
+	Err      error            `json:"error,omitempty"`
+}
+
+func (r listSoftwareResponse) error() error { return r.Err }
+
+func makeListSoftwareEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listSoftwareRequest)
+		software, err := svc.ListSoftware(ctx, req.TeamID, req.ListOptions)
+		if err != nil {
+			return listSoftwareResponse{Err: err}, nil
+		}
+
+		return listSoftwareResponse{Software: software}, nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Export software
+////////////////////////////////////////////////////////////////////////////////
+
+// softwareCSVColumns are the columns of the software CSV export.
+var softwareCSVColumns = []string{"name", "version", "source", "hosts_count", "vulnerabilities"}
+
+type exportSoftwareResponse struct {
+	software fleet.SoftwareIterator
+	Err      error `json:"error,omitempty"`
+}
+
+func (r exportSoftwareResponse) error() error { return r.Err }
+
+// stream writes the software as CSV while it is read from the datastore.
+// Nothing is written until the first software is read, so that errors
+// reading the software can still be returned as usual.
+func (r exportSoftwareResponse) stream(w http.ResponseWriter) error {
+	cw := csv.NewWriter(w)
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="software.csv"`)
+		return cw.Write(softwareCSVColumns)
+	}
+
+	err := r.software(func(s fleet.Software) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		cves := make([]string, 0, len(s.Vulnerabilities))
+		for _, v := range s.Vulnerabilities {
+			cves = append(cves, v.CVE)
+		}
+		return cw.Write([]string{
+			s.Name,
+			s.Version,
+			s.Source,
+			strconv.Itoa(s.HostsCount),
+			strings.Join(cves, ", "),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if !started {
+		if err := start(); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func makeExportSoftwareEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		software, err := svc.ExportSoftware(ctx)
+		if err != nil {
+			return exportSoftwareResponse{Err: err}, nil
+		}
+
+		return exportSoftwareResponse{software: software}, nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// List software titles
+////////////////////////////////////////////////////////////////////////////////
+
+type listSoftwareTitlesRequest struct {
+	ListOptions fleet.ListOptions
+}
+
+type listSoftwareTitlesResponse struct {
+	SoftwareTitles []fleet.SoftwareTitleSummary `json:"software_titles"`
+	Err            error                        `json:"error,omitempty"`
+}
+
+func (r listSoftwareTitlesResponse) error() error { return r.Err }
+
+func makeListSoftwareTitlesEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listSoftwareTitlesRequest)
+		titles, err := svc.ListSoftwareTitles(ctx, req.ListOptions)
+		if err != nil {
+			return listSoftwareTitlesResponse{Err: err}, nil
+		}
+
+		return listSoftwareTitlesResponse{SoftwareTitles: titles}, nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// List outdated software hosts
+////////////////////////////////////////////////////////////////////////////////
+
+type listOutdatedSoftwareHostsRequest struct {
+	Name    string
+	Version string
+}
+
+type listOutdatedSoftwareHostsResponse struct {
+	Hosts []fleet.HostSoftwareVersion `json:"hosts"`
+	Err   error                       `json:"error,omitempty"`
+}
+
+func (r listOutdatedSoftwareHostsResponse) error() error { return r.Err }
+
+func makeListOutdatedSoftwareHostsEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listOutdatedSoftwareHostsRequest)
+		hosts, err := svc.ListOutdatedSoftwareHosts(ctx, req.Name, req.Version)
+		if err != nil {
+			return listOutdatedSoftwareHostsResponse{Err: err}, nil
+		}
+
+		return listOutdatedSoftwareHostsResponse{Hosts: hosts}, nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// List host software changes
+////////////////////////////////////////////////////////////////////////////////
+
+type listHostSoftwareChangesRequest struct {
+	HostID      uint
+	ListOptions fleet.ListOptions
+}
+
+type listHostSoftwareChangesResponse struct {
+	Changes []fleet.HostSoftwareChange `json:"changes"`
+	Err     error                      `json:"error,omitempty"`
+}
+
+func (r listHostSoftwareChangesResponse) error() error { return r.Err }
+
+func makeListHostSoftwareChangesEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listHostSoftwareChangesRequest)
+		changes, err := svc.ListHostSoftwareChanges(ctx, req.HostID, req.ListOptions)
+		if err != nil {
+			return listHostSoftwareChangesResponse{Err: err}, nil
+		}
+
+		return listHostSoftwareChangesResponse{Changes: changes}, nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Host SBOM
+////////////////////////////////////////////////////////////////////////////////
+
+type hostSBOMRequest struct {
+	HostID uint
+	Format fleet.SBOMFormat
+}
+
+type hostSBOMResponse struct {
+	hostID   uint
+	format   fleet.SBOMFormat
+	document []byte
+	Err      error `json:"error,omitempty"`
+}
+
+func (r hostSBOMResponse) error() error { return r.Err }
+
+// stream writes the SBOM document as a JSON attachment in the media type of
+// its format.
+func (r hostSBOMResponse) stream(w http.ResponseWriter) error {
+	contentType, extension := sbom.CycloneDXContentType, "cdx.json"
+	if r.format == fleet.SBOMFormatSPDX {
+		contentType, extension = sbom.SPDXContentType, "spdx.json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="host-%d.%s"`, r.hostID, extension))
+	_, err := w.Write(r.document)
+	return err
+}
+
+func makeHostSBOMEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(hostSBOMRequest)
+		document, err := svc.HostSBOM(ctx, req.HostID, req.Format)
+		if err != nil {
+			return hostSBOMResponse{Err: err}, nil
+		}
+
+		return hostSBOMResponse{hostID: req.HostID, format: req.Format, document: document}, nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Software CVE suppressions
+////////////////////////////////////////////////////////////////////////////////
+
+type suppressSoftwareCVERequest struct {
+	SoftwareID uint   `json:"software_id"`
+	CVE        string `json:"cve"`
+	Reason     string `json:"reason"`
+}
+
+type suppressSoftwareCVEResponse struct {
+	Suppression *fleet.SoftwareCVESuppression `json:"suppression,omitempty"`
+	Err         error                         `json:"error,omitempty"`
+}
+
+func (r suppressSoftwareCVEResponse) error() error { return r.Err }
+
+func makeSuppressSoftwareCVEEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(suppressSoftwareCVERequest)
+		suppression, err := svc.SuppressSoftwareCVE(ctx, req.SoftwareID, req.CVE, req.Reason)
+		if err != nil {
+			return suppressSoftwareCVEResponse{Err: err}, nil
+		}
+
+		return suppressSoftwareCVEResponse{Suppression: suppression}, nil
+	}
+}
+
+type listSoftwareCVESuppressionsRequest struct {
+	ListOptions fleet.ListOptions
+}
+
+type listSoftwareCVESuppressionsResponse struct {
+	Suppressions []fleet.SoftwareCVESuppression `json:"suppressions"`
+	Err          error                          `json:"error,omitempty"`
+}
+
+func (r listSoftwareCVESuppressionsResponse) error() error { return r.Err }
+
+func makeListSoftwareCVESuppressionsEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listSoftwareCVESuppressionsRequest)
+		suppressions, err := svc.ListSoftwareCVESuppressions(ctx, req.ListOptions)
+		if err != nil {
+			return listSoftwareCVESuppressionsResponse{Err: err}, nil
+		}
+		if suppressions == nil {
+			suppressions = []fleet.SoftwareCVESuppression{}
+		}
+
+		return listSoftwareCVESuppressionsResponse{Suppressions: suppressions}, nil
+	}
+}
+
+type deleteSoftwareCVESuppressionRequest struct {
+	ID uint
+}
+
+type deleteSoftwareCVESuppressionResponse struct {
+	Err error `json:"error,omitempty"`
+}
+
+func (r deleteSoftwareCVESuppressionResponse) error() error { return r.Err }
+
+func makeDeleteSoftwareCVESuppressionEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(deleteSoftwareCVESuppressionRequest)
+		if err := svc.DeleteSoftwareCVESuppression(ctx, req.ID); err != nil {
+			return deleteSoftwareCVESuppressionResponse{Err: err}, nil
+		}
+
+		return deleteSoftwareCVESuppressionResponse{}, nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Host operating system vulnerabilities
+////////////////////////////////////////////////////////////////////////////////
+
+type listHostOSVulnerabilitiesRequest struct {
+	HostID uint
+}
+
+type listHostOSVulnerabilitiesResponse struct {
+	Vulnerabilities []fleet.OperatingSystemVulnerability `json:"vulnerabilities"`
+	Err             error                                `json:"error,omitempty"`
+}
+
+func (r listHostOSVulnerabilitiesResponse) error() error { return r.Err }
+
+func makeListHostOSVulnerabilitiesEndpoint(svc fleet.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listHostOSVulnerabilitiesRequest)
+		vulnerabilities, err := svc.ListHostOperatingSystemVulnerabilities(ctx, req.HostID)
+		if err != nil {
+			return listHostOSVulnerabilitiesResponse{Err: err}, nil
+		}
+		if vulnerabilities == nil {
+			vulnerabilities = []fleet.OperatingSystemVulnerability{}
+		}
+
+		return listHostOSVulnerabilitiesResponse{Vulnerabilities: vulnerabilities}, nil
+	}
+}
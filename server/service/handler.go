@@ -123,6 +123,16 @@ type FleetEndpoints struct {
 	DeleteTeamUsers                       endpoint.Endpoint
 	TeamEnrollSecrets                     endpoint.Endpoint
 	ListActivities                        endpoint.Endpoint
+	ListSoftware                          endpoint.Endpoint
+	ExportSoftware                        endpoint.Endpoint
+	ListSoftwareTitles                    endpoint.Endpoint
+	ListOutdatedSoftwareHosts             endpoint.Endpoint
+	ListHostSoftwareChanges               endpoint.Endpoint
+	HostSBOM                              endpoint.Endpoint
+	SuppressSoftwareCVE                   endpoint.Endpoint
+	ListSoftwareCVESuppressions           endpoint.Endpoint
+	DeleteSoftwareCVESuppression          endpoint.Endpoint
+	ListHostOSVulnerabilities             endpoint.Endpoint
 }
 
 // MakeFleetServerEndpoints creates the Fleet API endpoints.
@@ -235,6 +245,16 @@ func MakeFleetServerEndpoints(svc fleet.Service, urlPrefix string, limitStore th
 		DeleteTeamUsers:                       authenticatedUser(svc, makeDeleteTeamUsersEndpoint(svc)),
 		TeamEnrollSecrets:                     authenticatedUser(svc, makeTeamEnrollSecretsEndpoint(svc)),
 		ListActivities:                        authenticatedUser(svc, makeListActivitiesEndpoint(svc)),
+		ListSoftware:                          authenticatedUser(svc, makeListSoftwareEndpoint(svc)),
+		ExportSoftware:                        authenticatedUser(svc, makeExportSoftwareEndpoint(svc)),
+		ListSoftwareTitles:                    authenticatedUser(svc, makeListSoftwareTitlesEndpoint(svc)),
+		ListOutdatedSoftwareHosts:             authenticatedUser(svc, makeListOutdatedSoftwareHostsEndpoint(svc)),
+		ListHostSoftwareChanges:               authenticatedUser(svc, makeListHostSoftwareChangesEndpoint(svc)),
+		HostSBOM:                              authenticatedUser(svc, makeHostSBOMEndpoint(svc)),
+		SuppressSoftwareCVE:                   authenticatedUser(svc, makeSuppressSoftwareCVEEndpoint(svc)),
+		ListSoftwareCVESuppressions:           authenticatedUser(svc, makeListSoftwareCVESuppressionsEndpoint(svc)),
+		DeleteSoftwareCVESuppression:          authenticatedUser(svc, makeDeleteSoftwareCVESuppressionEndpoint(svc)),
+		ListHostOSVulnerabilities:             authenticatedUser(svc, makeListHostOSVulnerabilitiesEndpoint(svc)),
 
 		// Authenticated status endpoints
 		StatusResultStore: authenticatedUser(svc, makeStatusResultStoreEndpoint(svc)),
@@ -359,6 +379,16 @@ type fleetHandlers struct {
 	DeleteTeamUsers                       http.Handler
 	TeamEnrollSecrets                     http.Handler
 	ListActivities                        http.Handler
+	ListSoftware                          http.Handler
+	ExportSoftware                        http.Handler
+	ListSoftwareTitles                    http.Handler
+	ListOutdatedSoftwareHosts             http.Handler
+	ListHostSoftwareChanges               http.Handler
+	HostSBOM                              http.Handler
+	SuppressSoftwareCVE                   http.Handler
+	ListSoftwareCVESuppressions           http.Handler
+	DeleteSoftwareCVESuppression          http.Handler
+	ListHostOSVulnerabilities             http.Handler
 }
 
 func makeKitHandlers(e FleetEndpoints, opts []kithttp.ServerOption) *fleetHandlers {
@@ -470,6 +500,16 @@ func makeKitHandlers(e FleetEndpoints, opts []kithttp.ServerOption) *fleetHandle
 		DeleteTeamUsers:                       newServer(e.DeleteTeamUsers, decodeModifyTeamUsersRequest),
 		TeamEnrollSecrets:                     newServer(e.TeamEnrollSecrets, decodeTeamEnrollSecretsRequest),
 		ListActivities:                        newServer(e.ListActivities, decodeListActivitiesRequest),
+		ListSoftware:                          newServer(e.ListSoftware, decodeListSoftwareRequest),
+		ExportSoftware:                        newServer(e.ExportSoftware, decodeNoParamsRequest),
+		ListSoftwareTitles:                    newServer(e.ListSoftwareTitles, decodeListSoftwareTitlesRequest),
+		ListOutdatedSoftwareHosts:             newServer(e.ListOutdatedSoftwareHosts, decodeListOutdatedSoftwareHostsRequest),
+		ListHostSoftwareChanges:               newServer(e.ListHostSoftwareChanges, decodeListHostSoftwareChangesRequest),
+		HostSBOM:                              newServer(e.HostSBOM, decodeHostSBOMRequest),
+		SuppressSoftwareCVE:                   newServer(e.SuppressSoftwareCVE, decodeSuppressSoftwareCVERequest),
+		ListSoftwareCVESuppressions:           newServer(e.ListSoftwareCVESuppressions, decodeListSoftwareCVESuppressionsRequest),
+		DeleteSoftwareCVESuppression:          newServer(e.DeleteSoftwareCVESuppression, decodeDeleteSoftwareCVESuppressionRequest),
+		ListHostOSVulnerabilities:             newServer(e.ListHostOSVulnerabilities, decodeListHostOSVulnerabilitiesRequest),
 	}
 }
 
@@ -633,6 +673,9 @@ func attachFleetAPIRoutes(r *mux.Router, h *fleetHandlers) {
 	r.Handle("/api/v1/fleet/hosts/transfer", h.AddHostsToTeam).Methods("POST").Name("add_hosts_to_team")
 	r.Handle("/api/v1/fleet/hosts/transfer/filter", h.AddHostsToTeamByFilter).Methods("POST").Name("add_hosts_to_team_by_filter")
 	r.Handle("/api/v1/fleet/hosts/{id}/refetch", h.RefetchHost).Methods("POST").Name("refetch_host")
+	r.Handle("/api/v1/fleet/hosts/{id}/software/changes", h.ListHostSoftwareChanges).Methods("GET").Name("list_host_software_changes")
+	r.Handle("/api/v1/fleet/hosts/{id}/sbom", h.HostSBOM).Methods("GET").Name("host_sbom")
+	r.Handle("/api/v1/fleet/hosts/{id}/os_vulnerabilities", h.ListHostOSVulnerabilities).Methods("GET").Name("list_host_os_vulnerabilities")
 
 	r.Handle("/api/v1/fleet/targets", h.SearchTargets).Methods("POST").Name("search_targets")
 
@@ -664,10 +707,19 @@ func attachFleetAPIRoutes(r *mux.Router, h *fleetHandlers) {
 	r.Handle("/api/v1/osquery/carve/block", h.CarveBlock).Methods("POST").Name("carve_block")
 
 	r.Handle("/api/v1/fleet/activities", h.ListActivities).Methods("GET").Name("list_activities")
+
+	r.Handle("/api/v1/fleet/software", h.ListSoftware).Methods("GET").Name("list_software")
+	r.Handle("/api/v1/fleet/software/export", h.ExportSoftware).Methods("GET").Name("export_software")
+	r.Handle("/api/v1/fleet/software/titles", h.ListSoftwareTitles).Methods("GET").Name("list_software_titles")
+	r.Handle("/api/v1/fleet/software/outdated", h.ListOutdatedSoftwareHosts).Methods("GET").Name("list_outdated_software_hosts")
+	r.Handle("/api/v1/fleet/software/vulnerabilities/suppressions", h.SuppressSoftwareCVE).Methods("POST").Name("suppress_software_cve")
+	r.Handle("/api/v1/fleet/software/vulnerabilities/suppressions", h.ListSoftwareCVESuppressions).Methods("GET").Name("list_software_cve_suppressions")
+	r.Handle("/api/v1/fleet/software/vulnerabilities/suppressions/{id}", h.DeleteSoftwareCVESuppression).Methods("DELETE").Name("delete_software_cve_suppression")
 }
 
 func attachNewStyleFleetAPIRoutes(r *mux.Router, svc fleet.Service, opts []kithttp.ServerOption) {
 	handle("POST", "/api/v1/fleet/users/roles/spec", makeApplyUserRoleSpecsEndpoint(svc, opts), "apply_user_roles_spec", r)
+	handle("POST", "/api/v1/fleet/spec/validate", makeValidateSpecsEndpoint(svc, opts), "validate_specs", r)
 }
 
 func handle(verb, path string, handler http.Handler, name string, r *mux.Router) {
@@ -38,7 +38,7 @@ func (mw loggingMiddleware) NewDistributedQueryCampaign(ctx context.Context, que
 	return campaign, err
 }
 
-func (mw loggingMiddleware) NewDistributedQueryCampaignByNames(ctx context.Context, querySQL string, queryID *uint, hostIDs []string, labelIDs []string) (*fleet.DistributedQueryCampaign, error) {
+func (mw loggingMiddleware) NewDistributedQueryCampaignByNames(ctx context.Context, querySQL string, queryID *uint, hosts []string, labels []string, teams []string) (*fleet.DistributedQueryCampaign, error) {
 	var (
 		loggedInUser = "unauthenticated"
 		campaign     *fleet.DistributedQueryCampaign
@@ -62,7 +62,7 @@ func (mw loggingMiddleware) NewDistributedQueryCampaignByNames(ctx context.Conte
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	campaign, err = mw.Service.NewDistributedQueryCampaignByNames(ctx, querySQL, queryID, hostIDs, labelIDs)
+	campaign, err = mw.Service.NewDistributedQueryCampaignByNames(ctx, querySQL, queryID, hosts, labels, teams)
 	return campaign, err
 }
 
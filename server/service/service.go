@@ -35,6 +35,12 @@ type Service struct {
 	ssoSessionStore sso.SessionStore
 
 	seenHostSet *seenHostSet
+	// softwareQueue buffers the software reported by the hosts when it is
+	// saved asynchronously, it is nil otherwise.
+	softwareQueue fleet.HostSoftwareQueue
+	// softwareWatchlist is the compiled watchlist of the software installed
+	// webhook.
+	softwareWatchlist *softwareWatchlistCache
 
 	authz *authz.Authorizer
 }
@@ -43,7 +49,7 @@ type Service struct {
 func NewService(ds fleet.Datastore, resultStore fleet.QueryResultStore,
 	logger kitlog.Logger, config config.FleetConfig, mailService fleet.MailService,
 	c clock.Clock, sso sso.SessionStore, lq fleet.LiveQueryStore, carveStore fleet.CarveStore,
-	license fleet.LicenseInfo) (fleet.Service, error) {
+	softwareQueue fleet.HostSoftwareQueue, license fleet.LicenseInfo) (fleet.Service, error) {
 	var svc fleet.Service
 
 	osqueryLogger, err := logging.New(config, logger)
@@ -57,19 +63,21 @@ func NewService(ds fleet.Datastore, resultStore fleet.QueryResultStore,
 	}
 
 	svc = &Service{
-		ds:               ds,
-		carveStore:       carveStore,
-		resultStore:      resultStore,
-		liveQueryStore:   lq,
-		logger:           logger,
-		config:           config,
-		clock:            c,
-		osqueryLogWriter: osqueryLogger,
-		mailService:      mailService,
-		ssoSessionStore:  sso,
-		seenHostSet:      newSeenHostSet(),
-		license:          license,
-		authz:            authorizer,
+		ds:                ds,
+		carveStore:        carveStore,
+		resultStore:       resultStore,
+		liveQueryStore:    lq,
+		softwareQueue:     softwareQueue,
+		logger:            logger,
+		config:            config,
+		clock:             c,
+		osqueryLogWriter:  osqueryLogger,
+		mailService:       mailService,
+		ssoSessionStore:   sso,
+		seenHostSet:       newSeenHostSet(),
+		softwareWatchlist: &softwareWatchlistCache{},
+		license:           license,
+		authz:             authorizer,
 	}
 	svc = validationMiddleware{svc, ds, sso}
 	return svc, nil
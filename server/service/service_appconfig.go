@@ -121,9 +121,17 @@ func (svc *Service) ModifyAppConfig(ctx context.Context, p fleet.AppConfigPayloa
 		}
 	}
 
+	// The watchlist is compiled once when it is saved, rather than when the
+	// software of the hosts is matched against it.
+	watchlist, err := compileSoftwareWatchlist(config.SoftwareInstalledWebhookWatchlist)
+	if err != nil {
+		return nil, fleet.NewInvalidArgumentError("watchlist", err.Error())
+	}
+
 	if err := svc.ds.SaveAppConfig(config); err != nil {
 		return nil, err
 	}
+	svc.softwareWatchlist.set(watchlist)
 	return config, nil
 }
 
@@ -177,6 +185,64 @@ func appConfigFromAppConfigPayload(p fleet.AppConfigPayload, config fleet.AppCon
 		}
 	}
 
+	if p.Features != nil {
+		if p.Features.EnableSoftwareInventory != nil {
+			config.EnableSoftwareInventory = *p.Features.EnableSoftwareInventory
+		}
+	}
+
+	if p.SoftwareSettings != nil && p.SoftwareSettings.NormalizationRules != nil {
+		config.SoftwareNormalizationRules = *p.SoftwareSettings.NormalizationRules
+	}
+
+	if p.WebhookSettings != nil && p.WebhookSettings.SoftwareInstalledWebhook != nil {
+		settings := p.WebhookSettings.SoftwareInstalledWebhook
+		if settings.Enable != nil {
+			config.SoftwareInstalledWebhookEnabled = *settings.Enable
+		}
+		if settings.DestinationURL != nil {
+			config.SoftwareInstalledWebhookURL = strings.TrimSpace(*settings.DestinationURL)
+		}
+		if settings.Watchlist != nil {
+			config.SoftwareInstalledWebhookWatchlist = *settings.Watchlist
+		}
+	}
+
+	if p.WebhookSettings != nil && p.WebhookSettings.VulnerabilitiesWebhook != nil {
+		settings := p.WebhookSettings.VulnerabilitiesWebhook
+		if settings.Enable != nil {
+			config.VulnerabilitiesWebhookEnabled = *settings.Enable
+		}
+		if settings.DestinationURL != nil {
+			config.VulnerabilitiesWebhookURL = strings.TrimSpace(*settings.DestinationURL)
+		}
+		if settings.HostBatchSize != nil {
+			config.VulnerabilitiesWebhookHostBatchSize = *settings.HostBatchSize
+		}
+		if settings.MinCVSSScore != nil {
+			config.VulnerabilitiesWebhookMinCVSSScore = *settings.MinCVSSScore
+		}
+	}
+
+	if p.Integrations != nil && p.Integrations.Jira != nil {
+		settings := p.Integrations.Jira
+		if settings.Enable != nil {
+			config.JiraIntegrationEnabled = *settings.Enable
+		}
+		if settings.URL != nil {
+			config.JiraURL = strings.TrimSpace(*settings.URL)
+		}
+		if settings.Username != nil {
+			config.JiraUsername = *settings.Username
+		}
+		if settings.APIToken != nil && *settings.APIToken != "********" {
+			config.JiraAPIToken = *settings.APIToken
+		}
+		if settings.ProjectKey != nil {
+			config.JiraProjectKey = strings.TrimSpace(*settings.ProjectKey)
+		}
+	}
+
 	if p.HostExpirySettings != nil {
 		if p.HostExpirySettings.HostExpiryEnabled != nil {
 			config.HostExpiryEnabled = *p.HostExpirySettings.HostExpiryEnabled
@@ -16,7 +16,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (svc Service) NewDistributedQueryCampaignByNames(ctx context.Context, queryString string, queryID *uint, hosts []string, labels []string) (*fleet.DistributedQueryCampaign, error) {
+func (svc Service) NewDistributedQueryCampaignByNames(ctx context.Context, queryString string, queryID *uint, hosts []string, labels []string, teams []string) (*fleet.DistributedQueryCampaign, error) {
 	vc, ok := viewer.FromContext(ctx)
 	if !ok {
 		return nil, fleet.ErrNoContext
@@ -33,7 +33,19 @@ func (svc Service) NewDistributedQueryCampaignByNames(ctx context.Context, query
 		return nil, errors.Wrap(err, "finding label IDs")
 	}
 
-	targets := fleet.HostTargets{HostIDs: hostIDs, LabelIDs: labelIDs}
+	teamIDs := make([]uint, 0, len(teams))
+	for _, name := range teams {
+		team, err := svc.ds.TeamByName(name)
+		if err != nil {
+			if fleet.IsNotFound(err) {
+				return nil, fleet.NewInvalidArgumentError("teams", fmt.Sprintf("unknown team '%s'", name))
+			}
+			return nil, errors.Wrap(err, "finding team IDs")
+		}
+		teamIDs = append(teamIDs, team.ID)
+	}
+
+	targets := fleet.HostTargets{HostIDs: hostIDs, LabelIDs: labelIDs, TeamIDs: teamIDs}
 	return svc.NewDistributedQueryCampaign(ctx, queryString, queryID, targets)
 }
 
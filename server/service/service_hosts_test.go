@@ -85,7 +85,7 @@ func TestHostDetails(t *testing.T) {
 	ds.ListPacksForHostFunc = func(hid uint) ([]*fleet.Pack, error) {
 		return expectedPacks, nil
 	}
-	ds.LoadHostSoftwareFunc = func(host *fleet.Host) error {
+	ds.LoadHostSoftwareWithVulnerabilitiesFunc = func(host *fleet.Host) error {
 		return nil
 	}
 
@@ -597,45 +597,76 @@ var detailQueries = map[string]detailQuery{
 	"software_macos": {
 		Query: `
 SELECT
-  name AS name,
-  bundle_short_version AS version,
+  a.name AS name,
+  a.bundle_short_version AS version,
   'Application (macOS)' AS type,
-  'apps' AS source
-FROM apps
+  'apps' AS source,
+  CASE s.signed WHEN 1 THEN 'signed' WHEN 0 THEN 'unsigned' ELSE '' END AS signature_status,
+  a.bundle_identifier AS bundle_identifier,
+  a.last_opened_time AS last_opened_time,
+  '' AS extension_id,
+  '' AS browser
+FROM apps a
+LEFT JOIN signature s ON s.path = a.path
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (Python)' AS type,
-  'python_packages' AS source
+  'python_packages' AS source,
+  '' AS signature_status,
+  '' AS bundle_identifier,
+  '' AS last_opened_time,
+  '' AS extension_id,
+  '' AS browser
 FROM python_packages
 UNION
 SELECT
   name AS name,
   version AS version,
   'Browser plugin (Chrome)' AS type,
-  'chrome_extensions' AS source
-FROM chrome_extensions
+  'chrome_extensions' AS source,
+  '' AS signature_status,
+  '' AS bundle_identifier,
+  '' AS last_opened_time,
+  identifier AS extension_id,
+  browser_type AS browser
+FROM users CROSS JOIN chrome_extensions USING (uid)
 UNION
 SELECT
   name AS name,
   version AS version,
   'Browser plugin (Firefox)' AS type,
-  'firefox_addons' AS source
-FROM firefox_addons
+  'firefox_addons' AS source,
+  '' AS signature_status,
+  '' AS bundle_identifier,
+  '' AS last_opened_time,
+  identifier AS extension_id,
+  'firefox' AS browser
+FROM users CROSS JOIN firefox_addons USING (uid)
 UNION
 SELECT
   name As name,
   version AS version,
   'Browser plugin (Safari)' AS type,
-  'safari_extensions' AS source
-FROM safari_extensions
+  'safari_extensions' AS source,
+  '' AS signature_status,
+  '' AS bundle_identifier,
+  '' AS last_opened_time,
+  identifier AS extension_id,
+  'safari' AS browser
+FROM users CROSS JOIN safari_extensions USING (uid)
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (Homebrew)' AS type,
-  'homebrew_packages' AS source
+  'homebrew_packages' AS source,
+  '' AS signature_status,
+  '' AS bundle_identifier,
+  '' AS last_opened_time,
+  '' AS extension_id,
+  '' AS browser
 FROM homebrew_packages;
 `,
 		Platforms:  []string{"darwin"},
@@ -647,43 +678,97 @@ SELECT
   name AS name,
   version AS version,
   'Package (deb)' AS type,
-  'deb_packages' AS source
+  'deb_packages' AS source,
+  arch AS arch,
+  '' AS extension_id,
+  '' AS browser,
+  '' AS epoch,
+  '' AS release
 FROM deb_packages
 UNION
 SELECT
   package AS name,
   version AS version,
   'Package (Portage)' AS type,
-  'portage_packages' AS source
+  'portage_packages' AS source,
+  '' AS arch,
+  '' AS extension_id,
+  '' AS browser,
+  '' AS epoch,
+  '' AS release
 FROM portage_packages
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (RPM)' AS type,
-  'rpm_packages' AS source
+  'rpm_packages' AS source,
+  arch AS arch,
+  '' AS extension_id,
+  '' AS browser,
+  epoch AS epoch,
+  release AS release
 FROM rpm_packages
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (NPM)' AS type,
-  'npm_packages' AS source
+  'npm_packages' AS source,
+  '' AS arch,
+  '' AS extension_id,
+  '' AS browser,
+  '' AS epoch,
+  '' AS release
 FROM npm_packages
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (Atom)' AS type,
-  'atom_packages' AS source
+  'atom_packages' AS source,
+  '' AS arch,
+  '' AS extension_id,
+  '' AS browser,
+  '' AS epoch,
+  '' AS release
 FROM atom_packages
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (Python)' AS type,
-  'python_packages' AS source
-FROM python_packages;
+  'python_packages' AS source,
+  '' AS arch,
+  '' AS extension_id,
+  '' AS browser,
+  '' AS epoch,
+  '' AS release
+FROM python_packages
+UNION
+SELECT
+  name AS name,
+  version AS version,
+  'Browser plugin (Chrome)' AS type,
+  'chrome_extensions' AS source,
+  '' AS arch,
+  identifier AS extension_id,
+  browser_type AS browser,
+  '' AS epoch,
+  '' AS release
+FROM users CROSS JOIN chrome_extensions USING (uid)
+UNION
+SELECT
+  name AS name,
+  version AS version,
+  'Browser plugin (Firefox)' AS type,
+  'firefox_addons' AS source,
+  '' AS arch,
+  identifier AS extension_id,
+  'firefox' AS browser,
+  '' AS epoch,
+  '' AS release
+FROM users CROSS JOIN firefox_addons USING (uid);
 `,
 		Platforms:  []string{"linux", "rhel", "ubuntu", "centos"},
 		IngestFunc: ingestSoftware,
@@ -694,61 +779,93 @@ SELECT
   name AS name,
   version AS version,
   'Program (Windows)' AS type,
-  'programs' AS source
+  'programs' AS source,
+  '' AS extension_id,
+  '' AS browser
 FROM programs
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (Python)' AS type,
-  'python_packages' AS source
+  'python_packages' AS source,
+  '' AS extension_id,
+  '' AS browser
 FROM python_packages
 UNION
 SELECT
   name AS name,
   version AS version,
   'Browser plugin (IE)' AS type,
-  'ie_extensions' AS source
+  'ie_extensions' AS source,
+  '' AS extension_id,
+  'ie' AS browser
 FROM ie_extensions
 UNION
 SELECT
   name AS name,
   version AS version,
   'Browser plugin (Chrome)' AS type,
-  'chrome_extensions' AS source
-FROM chrome_extensions
+  'chrome_extensions' AS source,
+  identifier AS extension_id,
+  browser_type AS browser
+FROM users CROSS JOIN chrome_extensions USING (uid)
 UNION
 SELECT
   name AS name,
   version AS version,
   'Browser plugin (Firefox)' AS type,
-  'firefox_addons' AS source
-FROM firefox_addons
+  'firefox_addons' AS source,
+  identifier AS extension_id,
+  'firefox' AS browser
+FROM users CROSS JOIN firefox_addons USING (uid)
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (Chocolatey)' AS type,
-  'chocolatey_packages' AS source
+  'chocolatey_packages' AS source,
+  '' AS extension_id,
+  '' AS browser
 FROM chocolatey_packages
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (Atom)' AS type,
-  'atom_packages' AS source
+  'atom_packages' AS source,
+  '' AS extension_id,
+  '' AS browser
 FROM atom_packages
 UNION
 SELECT
   name AS name,
   version AS version,
   'Package (Python)' AS type,
-  'python_packages' AS source
+  'python_packages' AS source,
+  '' AS extension_id,
+  '' AS browser
 FROM python_packages;
 `,
 		Platforms:  []string{"windows"},
 		IngestFunc: ingestSoftware,
 	},
+	"windows_updates": {
+		Query:     `SELECT hotfix_id FROM patches`,
+		Platforms: []string{"windows"},
+		IngestFunc: func(logger log.Logger, host *fleet.Host, rows []map[string]string) error {
+			// An empty, non-nil slice removes the updates no longer installed.
+			host.WindowsUpdates = []string{}
+			for _, row := range rows {
+				kbID := strings.ToUpper(strings.TrimSpace(row["hotfix_id"]))
+				if kbID == "" {
+					continue
+				}
+				host.WindowsUpdates = append(host.WindowsUpdates, kbID)
+			}
+			return nil
+		},
+	},
 	"scheduled_query_stats": {
 		Query: `
 			SELECT *,
@@ -850,6 +967,16 @@ FROM python_packages;
 	},
 }
 
+// managedSoftwareSources are the software sources populated by system package
+// managers, the software they report is considered managed.
+var managedSoftwareSources = map[string]bool{
+	"homebrew_packages":   true,
+	"deb_packages":        true,
+	"portage_packages":    true,
+	"rpm_packages":        true,
+	"chocolatey_packages": true,
+}
+
 func ingestSoftware(logger log.Logger, host *fleet.Host, rows []map[string]string) error {
 	software := fleet.HostSoftware{Modified: true}
 
@@ -875,7 +1002,53 @@ func ingestSoftware(logger log.Logger, host *fleet.Host, rows []map[string]strin
 			)
 			continue
 		}
-		s := fleet.Software{Name: name, Version: version, Source: source}
+		s := fleet.Software{
+			Name:             name,
+			Version:          version,
+			Source:           source,
+			Managed:          managedSoftwareSources[source],
+			Arch:             row["arch"],
+			BundleIdentifier: row["bundle_identifier"],
+			ExtensionID:      row["extension_id"],
+			Browser:          row["browser"],
+			Release:          row["release"],
+		}
+		if signatureStatus := row["signature_status"]; signatureStatus != "" {
+			s.SignatureStatus = &signatureStatus
+		}
+		if epoch := row["epoch"]; epoch != "" {
+			// The epoch is stored apart from the version so that the
+			// software identity does not change, rpm_packages reports an
+			// empty or zero epoch when the package has none.
+			parsed, err := strconv.ParseUint(epoch, 10, 32)
+			if err != nil {
+				level.Debug(logger).Log(
+					"msg", "host reported software with invalid epoch",
+					"host", host.Hostname,
+					"name", name,
+					"epoch", epoch,
+				)
+			} else if parsed > 0 {
+				e := uint(parsed)
+				s.Epoch = &e
+			}
+		}
+		if lastOpened := row["last_opened_time"]; lastOpened != "" {
+			// osquery reports the time as fractional seconds since the
+			// epoch, negative or zero when the app was never opened.
+			seconds, err := strconv.ParseFloat(lastOpened, 64)
+			if err != nil {
+				level.Debug(logger).Log(
+					"msg", "host reported software with invalid last opened time",
+					"host", host.Hostname,
+					"name", name,
+					"last_opened_time", lastOpened,
+				)
+			} else if seconds > 0 {
+				lastOpenedAt := time.Unix(int64(seconds), 0).UTC()
+				s.LastOpenedAt = &lastOpenedAt
+			}
+		}
 		software.Software = append(software.Software, s)
 	}
 
@@ -893,14 +1066,21 @@ func (svc *Service) hostDetailQueries(host fleet.Host) (map[string]string, error
 		return queries, nil
 	}
 
+	// Feature flag the software inventory because of as-yet-untested
+	// performance considerations.
+	softwareInventory := os.Getenv("FLEET_BETA_SOFTWARE_INVENTORY") != ""
+	if softwareInventory {
+		enabled, err := svc.ds.SoftwareInventoryEnabled(host.TeamID)
+		if err != nil {
+			return nil, osqueryError{message: "get software inventory enabled: " + err.Error()}
+		}
+		softwareInventory = enabled
+	}
+
 	for name, query := range detailQueries {
 		if query.runForPlatform(host.Platform) {
-			if strings.HasPrefix(name, "software_") {
-				// Feature flag this because of as-yet-untested performance
-				// considerations.
-				if os.Getenv("FLEET_BETA_SOFTWARE_INVENTORY") == "" {
-					continue
-				}
+			if strings.HasPrefix(name, "software_") && !softwareInventory {
+				continue
 			}
 			queries[hostDetailQueryPrefix+name] = query.Query
 		}
@@ -1150,10 +1330,18 @@ func (svc *Service) SubmitDistributedQueryResults(ctx context.Context, results f
 	}
 
 	if host.Modified {
+		if host.HostSoftware.Modified && svc.softwareQueue != nil {
+			if err := svc.softwareQueue.Enqueue(&host); err != nil {
+				return osqueryError{message: "failed to enqueue host software: " + err.Error()}
+			}
+			host.HostSoftware.Modified = false
+		}
+
 		err = svc.ds.SaveHost(&host)
 		if err != nil {
 			return osqueryError{message: "failed to update host details: " + err.Error()}
 		}
+		svc.fireSoftwareInstalledWebhook(&host)
 	}
 
 	return nil
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -27,8 +28,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// 3 detail queries are currently feature flagged off by default.
-var expectedDetailQueries = len(detailQueries) - 3
+// 3 detail queries are currently feature flagged off by default, and the
+// Windows updates are only queried on Windows hosts.
+var expectedDetailQueries = len(detailQueries) - 4
 
 func TestEnrollAgent(t *testing.T) {
 	ds := new(mock.Store)
@@ -292,6 +294,41 @@ func TestHostDetailQueries(t *testing.T) {
 	assert.Equal(t, "select foo", queries[hostAdditionalQueryPrefix+"foobar"])
 }
 
+func TestHostDetailQueriesSoftwareInventory(t *testing.T) {
+	os.Setenv("FLEET_BETA_SOFTWARE_INVENTORY", "1")
+	defer os.Unsetenv("FLEET_BETA_SOFTWARE_INVENTORY")
+
+	ds := new(mock.Store)
+	ds.AppConfigFunc = func() (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	enabled := true
+	var calledWithTeam *uint
+	ds.SoftwareInventoryEnabledFunc = func(teamID *uint) (bool, error) {
+		calledWithTeam = teamID
+		return enabled, nil
+	}
+
+	mockClock := clock.NewMockClock()
+	host := fleet.Host{
+		ID:       1,
+		TeamID:   ptr.Uint(2),
+		Platform: "darwin",
+	}
+	svc := &Service{clock: mockClock, config: config.TestConfig(), ds: ds}
+
+	queries, err := svc.hostDetailQueries(host)
+	require.NoError(t, err)
+	assert.Contains(t, queries, hostDetailQueryPrefix+"software_macos")
+	assert.Equal(t, ptr.Uint(2), calledWithTeam)
+
+	enabled = false
+	queries, err = svc.hostDetailQueries(host)
+	require.NoError(t, err)
+	assert.NotContains(t, queries, hostDetailQueryPrefix+"software_macos")
+	assert.Contains(t, queries, hostDetailQueryPrefix+"osquery_info")
+}
+
 func TestGetDistributedQueriesMissingHost(t *testing.T) {
 	svc := newTestService(&mock.Store{}, nil, nil)
 
@@ -531,11 +568,11 @@ func TestDetailQueriesWithEmptyStrings(t *testing.T) {
 
 	lq.On("QueriesForHost", host.ID).Return(map[string]string{}, nil)
 
-	// With a new host, we should get the detail queries (and accelerated
-	// queries)
+	// With a new host, we should get the detail queries, with the Windows
+	// updates (and accelerated queries)
 	queries, acc, err := svc.GetDistributedQueries(ctx)
 	assert.Nil(t, err)
-	assert.Len(t, queries, expectedDetailQueries)
+	assert.Len(t, queries, expectedDetailQueries+1)
 	assert.NotZero(t, acc)
 
 	resultJSON := `
@@ -680,7 +717,7 @@ func TestDetailQueriesWithEmptyStrings(t *testing.T) {
 
 	queries, acc, err = svc.GetDistributedQueries(ctx)
 	assert.Nil(t, err)
-	assert.Len(t, queries, expectedDetailQueries)
+	assert.Len(t, queries, expectedDetailQueries+1)
 	assert.Zero(t, acc)
 }
 
@@ -959,6 +996,106 @@ func TestDetailQueryNetworkInterfaces(t *testing.T) {
 	assert.Equal(t, "00:00:00:00:00:00", host.PrimaryMac)
 }
 
+func TestDetailQuerySoftware(t *testing.T) {
+	var host fleet.Host
+
+	ingest := detailQueries["software_macos"].IngestFunc
+	lastOpenedAt := time.Date(2021, 8, 2, 14, 0, 0, 0, time.UTC)
+
+	var rows []map[string]string
+	require.NoError(t, json.Unmarshal([]byte(`
+[
+  {"name":"Signed.app","version":"1.0","type":"Application (macOS)","source":"apps","signature_status":"signed","bundle_identifier":"com.example.signed","last_opened_time":"1627912800.5"},
+  {"name":"Unsigned.app","version":"2.0","type":"Application (macOS)","source":"apps","signature_status":"unsigned","bundle_identifier":"com.example.unsigned","last_opened_time":"-1.0"},
+  {"name":"Unknown.app","version":"3.0","type":"Application (macOS)","source":"apps","signature_status":"","bundle_identifier":"","last_opened_time":""},
+  {"name":"requests","version":"2.25.1","type":"Package (Python)","source":"python_packages"},
+  {"name":"wget","version":"1.21.1","type":"Package (Homebrew)","source":"homebrew_packages"}
+]`),
+		&rows,
+	))
+
+	assert.NoError(t, ingest(log.NewNopLogger(), &host, rows))
+	assert.True(t, host.HostSoftware.Modified)
+	assert.Equal(t, []fleet.Software{
+		{
+			Name: "Signed.app", Version: "1.0", Source: "apps", SignatureStatus: ptr.String(fleet.SoftwareSigned), BundleIdentifier: "com.example.signed",
+			LastOpenedAt: &lastOpenedAt,
+		},
+		{Name: "Unsigned.app", Version: "2.0", Source: "apps", SignatureStatus: ptr.String(fleet.SoftwareUnsigned), BundleIdentifier: "com.example.unsigned"},
+		{Name: "Unknown.app", Version: "3.0", Source: "apps"},
+		{Name: "requests", Version: "2.25.1", Source: "python_packages"},
+		{Name: "wget", Version: "1.21.1", Source: "homebrew_packages", Managed: true},
+	}, host.HostSoftware.Software)
+}
+
+func TestDetailQuerySoftwareLinux(t *testing.T) {
+	var host fleet.Host
+
+	ingest := detailQueries["software_linux"].IngestFunc
+
+	var rows []map[string]string
+	require.NoError(t, json.Unmarshal([]byte(`
+[
+  {"name":"curl","version":"7.68.0","type":"Package (deb)","source":"deb_packages","arch":"amd64","epoch":"","release":""},
+  {"name":"openssl","version":"1.1.1g","type":"Package (RPM)","source":"rpm_packages","arch":"x86_64","epoch":"1","release":"12.el8_3"},
+  {"name":"bash","version":"4.4.19","type":"Package (RPM)","source":"rpm_packages","arch":"x86_64","epoch":"0","release":"10.el8"},
+  {"name":"requests","version":"2.25.1","type":"Package (Python)","source":"python_packages","arch":"","epoch":"","release":""}
+]`),
+		&rows,
+	))
+
+	assert.NoError(t, ingest(log.NewNopLogger(), &host, rows))
+	assert.Equal(t, []fleet.Software{
+		{Name: "curl", Version: "7.68.0", Source: "deb_packages", Managed: true, Arch: "amd64"},
+		{Name: "openssl", Version: "1.1.1g", Source: "rpm_packages", Managed: true, Arch: "x86_64", Epoch: ptr.Uint(1), Release: "12.el8_3"},
+		{Name: "bash", Version: "4.4.19", Source: "rpm_packages", Managed: true, Arch: "x86_64", Release: "10.el8"},
+		{Name: "requests", Version: "2.25.1", Source: "python_packages"},
+	}, host.HostSoftware.Software)
+}
+
+func TestDetailQuerySoftwareBrowserExtensions(t *testing.T) {
+	var host fleet.Host
+
+	ingest := detailQueries["software_windows"].IngestFunc
+
+	var rows []map[string]string
+	require.NoError(t, json.Unmarshal([]byte(`
+[
+  {"name":"uBlock Origin","version":"1.37.2","type":"Browser plugin (Chrome)","source":"chrome_extensions","extension_id":"cjpalhdlnbpafiamejdnhcphjbkeiagm","browser":"chrome"},
+  {"name":"uBlock Origin","version":"1.37.2","type":"Browser plugin (Chrome)","source":"chrome_extensions","extension_id":"odfafepnkmbhccpbejgmiehpchacaeak","browser":"edge"},
+  {"name":"uBlock Origin","version":"1.37.2","type":"Browser plugin (Firefox)","source":"firefox_addons","extension_id":"uBlock0@raymondhill.net","browser":"firefox"},
+  {"name":"Python 3.9.6","version":"3.9.6150.0","type":"Program (Windows)","source":"programs","extension_id":"","browser":""}
+]`),
+		&rows,
+	))
+
+	assert.NoError(t, ingest(log.NewNopLogger(), &host, rows))
+	assert.Equal(t, []fleet.Software{
+		{Name: "uBlock Origin", Version: "1.37.2", Source: "chrome_extensions", ExtensionID: "cjpalhdlnbpafiamejdnhcphjbkeiagm", Browser: "chrome"},
+		{Name: "uBlock Origin", Version: "1.37.2", Source: "chrome_extensions", ExtensionID: "odfafepnkmbhccpbejgmiehpchacaeak", Browser: "edge"},
+		{Name: "uBlock Origin", Version: "1.37.2", Source: "firefox_addons", ExtensionID: "uBlock0@raymondhill.net", Browser: "firefox"},
+		{Name: "Python 3.9.6", Version: "3.9.6150.0", Source: "programs"},
+	}, host.HostSoftware.Software)
+}
+
+func TestDetailQueryWindowsUpdates(t *testing.T) {
+	var host fleet.Host
+
+	ingest := detailQueries["windows_updates"].IngestFunc
+
+	assert.NoError(t, ingest(log.NewNopLogger(), &host, []map[string]string{
+		{"hotfix_id": "KB5005033"},
+		{"hotfix_id": "kb5004331 "},
+		{"hotfix_id": ""},
+	}))
+	assert.Equal(t, []string{"KB5005033", "KB5004331"}, host.WindowsUpdates)
+
+	// Without updates, the saved updates are removed.
+	assert.NoError(t, ingest(log.NewNopLogger(), &host, nil))
+	assert.NotNil(t, host.WindowsUpdates)
+	assert.Empty(t, host.WindowsUpdates)
+}
+
 func TestDetailQueryScheduledQueryStats(t *testing.T) {
 	host := fleet.Host{}
 
@@ -1210,6 +1347,78 @@ func TestNewDistributedQueryCampaign(t *testing.T) {
 	)
 }
 
+func TestNewDistributedQueryCampaignByNamesTeams(t *testing.T) {
+	ds := &mock.Store{
+		AppConfigStore: mock.AppConfigStore{
+			AppConfigFunc: func() (*fleet.AppConfig, error) {
+				return &fleet.AppConfig{}, nil
+			},
+		},
+	}
+	rs := &mock.QueryResultStore{
+		HealthCheckFunc: func() error {
+			return nil
+		},
+	}
+	lq := &live_query.MockLiveQuery{}
+	svc := newTestService(ds, rs, lq)
+
+	ds.HostIDsByNameFunc = func(filter fleet.TeamFilter, hostnames []string) ([]uint, error) {
+		return []uint{}, nil
+	}
+	ds.LabelIDsByNameFunc = func(labels []string) ([]uint, error) {
+		return []uint{}, nil
+	}
+	ds.TeamByNameFunc = func(name string) (*fleet.Team, error) {
+		if name == "team1" {
+			return &fleet.Team{ID: 7, Name: name}, nil
+		}
+		return nil, notFoundError{}
+	}
+	ds.NewQueryFunc = func(query *fleet.Query, opts ...fleet.OptionalArg) (*fleet.Query, error) {
+		query.ID = 42
+		return query, nil
+	}
+	ds.NewDistributedQueryCampaignFunc = func(camp *fleet.DistributedQueryCampaign) (*fleet.DistributedQueryCampaign, error) {
+		camp.ID = 21
+		return camp, nil
+	}
+	var gotTargets []*fleet.DistributedQueryCampaignTarget
+	ds.NewDistributedQueryCampaignTargetFunc = func(target *fleet.DistributedQueryCampaignTarget) (*fleet.DistributedQueryCampaignTarget, error) {
+		gotTargets = append(gotTargets, target)
+		return target, nil
+	}
+	ds.HostIDsInTargetsFunc = func(filter fleet.TeamFilter, targets fleet.HostTargets) ([]uint, error) {
+		assert.Equal(t, []uint{7}, targets.TeamIDs)
+		return []uint{1, 2}, nil
+	}
+	ds.CountHostsInTargetsFunc = func(filter fleet.TeamFilter, targets fleet.HostTargets, now time.Time) (fleet.TargetMetrics, error) {
+		return fleet.TargetMetrics{}, nil
+	}
+	ds.NewActivityFunc = func(user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	q := "select * from time"
+	lq.On("RunQuery", "21", q, []uint{1, 2}).Return(nil)
+	viewerCtx := viewer.NewContext(context.Background(), viewer.Viewer{
+		User: &fleet.User{GlobalRole: ptr.String(fleet.RoleAdmin)},
+	})
+
+	_, err := svc.NewDistributedQueryCampaignByNames(viewerCtx, q, nil, nil, nil, []string{"team1"})
+	require.NoError(t, err)
+	assert.Equal(t, []*fleet.DistributedQueryCampaignTarget{
+		{
+			Type:                       fleet.TargetTeam,
+			DistributedQueryCampaignID: 21,
+			TargetID:                   7,
+		},
+	}, gotTargets)
+
+	_, err = svc.NewDistributedQueryCampaignByNames(viewerCtx, q, nil, nil, nil, []string{"team1", "nope"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown team 'nope'")
+}
+
 func TestDistributedQueryResults(t *testing.T) {
 	mockClock := clock.NewMockClock()
 	ds := new(mock.Store)
@@ -1246,7 +1455,7 @@ func TestDistributedQueryResults(t *testing.T) {
 	// Now we should get the active distributed query
 	queries, acc, err := svc.GetDistributedQueries(hostCtx)
 	require.Nil(t, err)
-	assert.Len(t, queries, expectedDetailQueries+1)
+	assert.Len(t, queries, expectedDetailQueries+2)
 	queryKey := fmt.Sprintf("%s%d", hostDistributedQueryPrefix, campaign.ID)
 	assert.Equal(t, "select * from time", queries[queryKey])
 	assert.NotZero(t, acc)
@@ -11,17 +11,19 @@ import (
 
 func queryFromSpec(spec *fleet.QuerySpec) *fleet.Query {
 	return &fleet.Query{
-		Name:        spec.Name,
-		Description: spec.Description,
-		Query:       spec.Query,
+		Name:           spec.Name,
+		Description:    spec.Description,
+		Query:          spec.Query,
+		ObserverCanRun: spec.ObserverCanRun,
 	}
 }
 
 func specFromQuery(query *fleet.Query) *fleet.QuerySpec {
 	return &fleet.QuerySpec{
-		Name:        query.Name,
-		Description: query.Description,
-		Query:       query.Query,
+		Name:           query.Name,
+		Description:    query.Description,
+		Query:          query.Query,
+		ObserverCanRun: query.ObserverCanRun,
 	}
 }
 
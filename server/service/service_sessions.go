@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/xml"
+	"net"
 	"net/url"
 	"time"
 
@@ -161,11 +162,32 @@ func (svc *Service) CallbackSSO(ctx context.Context, auth fleet.Auth) (*fleet.SS
 	}
 	result := &fleet.SSOSession{
 		Token:       token,
-		RedirectURL: redirectURL,
+		RedirectURL: ssoRedirectURL(redirectURL, token),
 	}
 	return result, nil
 }
 
+// ssoRedirectURL returns the URL to redirect to after the SSO callback.
+// fleetctl login --sso initiates SSO with the URL of a local listener, which
+// can't read the token from the local storage of the browser, so it is passed
+// in the token query parameter. Only loopback HTTP URLs get the token, it is
+// never sent to another host.
+func ssoRedirectURL(redirectURL, token string) string {
+	u, err := url.Parse(redirectURL)
+	if err != nil || u.Scheme != "http" {
+		return redirectURL
+	}
+	if host := u.Hostname(); host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			return redirectURL
+		}
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
 func (svc *Service) Login(ctx context.Context, email, password string) (*fleet.User, string, error) {
 	// skipauth: No user context available yet to authorize against.
 	svc.authz.SkipAuthorization(ctx)
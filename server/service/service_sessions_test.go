@@ -66,3 +66,23 @@ func (authViewerService) GetSessionByKey(ctx context.Context, key string) (*flee
 func (authViewerService) UserUnauthorized(ctx context.Context, uid uint) (*fleet.User, error) {
 	return &fleet.User{}, nil
 }
+
+func TestSSORedirectURL(t *testing.T) {
+	for _, tt := range []struct {
+		redirectURL string
+		want        string
+	}{
+		{"/dashboard", "/dashboard"},
+		{"https://fleet.example.com/", "https://fleet.example.com/"},
+		{"http://fleet.example.com/", "http://fleet.example.com/"},
+		{"https://127.0.0.1:8080/", "https://127.0.0.1:8080/"},
+		{"http://127.0.0.1:8080/", "http://127.0.0.1:8080/?token=abc%2B"},
+		{"http://localhost:8080/callback?state=1", "http://localhost:8080/callback?state=1&token=abc%2B"},
+		{"http://[::1]:8080/", "http://[::1]:8080/?token=abc%2B"},
+		{"http://127.0.0.1.example.com/", "http://127.0.0.1.example.com/"},
+	} {
+		t.Run(tt.redirectURL, func(t *testing.T) {
+			assert.Equal(t, tt.want, ssoRedirectURL(tt.redirectURL, "abc+"))
+		})
+	}
+}
@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/authz"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/fleet/version"
+	"github.com/fleetdm/fleet/v4/server/sbom"
+	"github.com/fleetdm/fleet/v4/server/vulnerabilities"
+	"github.com/fleetdm/fleet/v4/server/webhooks"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// softwareOrderKeys are the keys the software list can be ordered by.
+var softwareOrderKeys = map[string]bool{
+	"name":        true,
+	"version":     true,
+	"hosts_count": true,
+}
+
+// ListSoftware returns the software installed on the hosts of the whole
+// organization, or of the team if teamID is set
+func (svc *Service) ListSoftware(ctx context.Context, teamID *uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.Software{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	// Software is only visible to the users that can read the hosts it is
+	// installed on, so team users must list the software of their teams.
+	if err := svc.authz.Authorize(ctx, &fleet.Host{TeamID: teamID}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if opt.OrderKey != "" && !softwareOrderKeys[opt.OrderKey] {
+		return nil, fleet.NewInvalidArgumentError("order_key", "must be one of name, version or hosts_count")
+	}
+
+	var software []fleet.Software
+	var err error
+	if teamID != nil {
+		software, err = svc.ds.ListSoftwareByTeam(*teamID, opt)
+	} else {
+		software, err = svc.ds.ListSoftware(opt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.ds.LoadSoftwareVulnerabilities(software); err != nil {
+		return nil, err
+	}
+	return software, nil
+}
+
+// ExportSoftware returns an iterator over the software installed on the
+// hosts of the whole organization
+func (svc *Service) ExportSoftware(ctx context.Context) (fleet.SoftwareIterator, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.Software{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if err := svc.authz.Authorize(ctx, &fleet.Host{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	return svc.ds.ExportSoftware, nil
+}
+
+// softwareTitleOrderKeys are the keys the software titles list can be ordered
+// by.
+var softwareTitleOrderKeys = map[string]bool{
+	"name":           true,
+	"hosts_count":    true,
+	"versions_count": true,
+}
+
+// ListSoftwareTitles returns the software installed on the hosts of the whole
+// organization grouped by name and source
+func (svc *Service) ListSoftwareTitles(ctx context.Context, opt fleet.ListOptions) ([]fleet.SoftwareTitleSummary, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.Software{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if err := svc.authz.Authorize(ctx, &fleet.Host{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if opt.OrderKey != "" && !softwareTitleOrderKeys[opt.OrderKey] {
+		return nil, fleet.NewInvalidArgumentError("order_key", "must be one of name, hosts_count or versions_count")
+	}
+
+	return svc.ds.ListSoftwareTitles(opt)
+}
+
+// ListOutdatedSoftwareHosts returns the hosts of the whole organization
+// running a version of the software older than minVersion
+func (svc *Service) ListOutdatedSoftwareHosts(ctx context.Context, name, minVersion string) ([]fleet.HostSoftwareVersion, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.Software{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if err := svc.authz.Authorize(ctx, &fleet.Host{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, fleet.NewInvalidArgumentError("name", "is required")
+	}
+	if minVersion == "" {
+		return nil, fleet.NewInvalidArgumentError("version", "is required")
+	}
+
+	installed, err := svc.ds.ListHostSoftwareVersionsByName(name)
+	if err != nil {
+		return nil, err
+	}
+	outdated := []fleet.HostSoftwareVersion{}
+	for _, hs := range installed {
+		if version.Compare(hs.Version, minVersion) < 0 {
+			outdated = append(outdated, hs)
+		}
+	}
+	return outdated, nil
+}
+
+// FlushHostSoftware saves the software buffered by the hosts, in batches,
+// until the queue is empty.
+func (svc *Service) FlushHostSoftware(ctx context.Context) error {
+	// No authorization check because this is used only internally.
+	if svc.softwareQueue == nil {
+		return nil
+	}
+
+	batchSize := svc.config.Osquery.AsyncSoftwareIngestionBatchSize
+	for {
+		hosts, err := svc.softwareQueue.Dequeue(batchSize)
+		if err != nil {
+			return errors.Wrap(err, "dequeue host software")
+		}
+		for _, host := range hosts {
+			// The software of the other hosts is saved regardless, the
+			// software of the host will be saved again on its next report.
+			if err := svc.ds.SaveHostSoftware(host); err != nil {
+				level.Error(svc.logger).Log(
+					"err", err,
+					"msg", "failed to save host software",
+					"host_id", host.ID,
+				)
+				continue
+			}
+			svc.fireSoftwareInstalledWebhook(host)
+		}
+		if len(hosts) == 0 || len(hosts) < batchSize {
+			return nil
+		}
+	}
+}
+
+// ListHostSoftwareChanges returns the software installed on and removed from
+// the host
+func (svc *Service) ListHostSoftwareChanges(ctx context.Context, hostID uint, opt fleet.ListOptions) ([]fleet.HostSoftwareChange, error) {
+	// First ensure the user has access to list hosts, then check the specific
+	// host once team_id is loaded.
+	if err := svc.authz.Authorize(ctx, &fleet.Host{}, fleet.ActionList); err != nil {
+		return nil, err
+	}
+	host, err := svc.ds.Host(hostID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get host")
+	}
+	if err := svc.authz.Authorize(ctx, host, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if opt.OrderKey != "" && opt.OrderKey != "created_at" {
+		return nil, fleet.NewInvalidArgumentError("order_key", "must be created_at")
+	}
+
+	return svc.ds.ListHostSoftwareChanges(hostID, opt)
+}
+
+// HostSBOM returns the software inventory of the host as a software bill of
+// materials in the CycloneDX or SPDX format.
+func (svc *Service) HostSBOM(ctx context.Context, hostID uint, format fleet.SBOMFormat) ([]byte, error) {
+	// First ensure the user has access to list hosts, then check the specific
+	// host once team_id is loaded.
+	if err := svc.authz.Authorize(ctx, &fleet.Host{}, fleet.ActionList); err != nil {
+		return nil, err
+	}
+	host, err := svc.ds.Host(hostID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get host")
+	}
+	if err := svc.authz.Authorize(ctx, host, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if format != fleet.SBOMFormatCycloneDX && format != fleet.SBOMFormatSPDX {
+		return nil, fleet.NewInvalidArgumentError("format", "must be cyclonedx or spdx")
+	}
+
+	if err := svc.ds.LoadHostSoftware(host); err != nil {
+		return nil, errors.Wrap(err, "load host software")
+	}
+	if format == fleet.SBOMFormatSPDX {
+		return sbom.SPDX(host, svc.clock.Now())
+	}
+	return sbom.CycloneDX(host, svc.clock.Now())
+}
+
+// cveRegexp matches the identifiers of CVEs, eg. CVE-2021-3156.
+var cveRegexp = regexp.MustCompile(`^CVE-\d{4}-\d{4,}$`)
+
+// SuppressSoftwareCVE suppresses the CVE of the software as a false positive.
+// The suppression is recorded as an activity of the user.
+func (svc *Service) SuppressSoftwareCVE(ctx context.Context, softwareID uint, cve, reason string) (*fleet.SoftwareCVESuppression, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.Software{}, fleet.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	cve = strings.TrimSpace(cve)
+	reason = strings.TrimSpace(reason)
+	invalid := &fleet.InvalidArgumentError{}
+	if !cveRegexp.MatchString(cve) {
+		invalid.Append("cve", "must be a CVE identifier, eg. CVE-2021-3156")
+	}
+	if reason == "" {
+		invalid.Append("reason", "required")
+	}
+	if invalid.HasErrors() {
+		return nil, invalid
+	}
+	if _, err := svc.ds.SoftwareByID(softwareID); err != nil {
+		return nil, errors.Wrap(err, "get software")
+	}
+
+	user := authz.UserFromContext(ctx)
+	suppression, err := svc.ds.NewSoftwareCVESuppression(&fleet.SoftwareCVESuppression{
+		SoftwareID: softwareID,
+		CVE:        cve,
+		Reason:     reason,
+		AuthorID:   &user.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.ds.NewActivity(
+		user,
+		fleet.ActivityTypeSuppressedVulnerability,
+		&map[string]interface{}{"software_id": softwareID, "cve": cve, "reason": reason},
+	); err != nil {
+		return nil, err
+	}
+	return suppression, nil
+}
+
+// ListSoftwareCVESuppressions returns the CVEs suppressed as false positives,
+// from the newest.
+func (svc *Service) ListSoftwareCVESuppressions(ctx context.Context, opt fleet.ListOptions) ([]fleet.SoftwareCVESuppression, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.Software{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if opt.OrderKey != "" {
+		return nil, fleet.NewInvalidArgumentError("order_key", "suppressions are ordered from the newest")
+	}
+	return svc.ds.ListSoftwareCVESuppressions(opt)
+}
+
+// DeleteSoftwareCVESuppression removes the suppression of a CVE, which is
+// listed in the vulnerabilities of the software again. The deletion is
+// recorded as an activity of the user.
+func (svc *Service) DeleteSoftwareCVESuppression(ctx context.Context, id uint) error {
+	if err := svc.authz.Authorize(ctx, &fleet.Software{}, fleet.ActionWrite); err != nil {
+		return err
+	}
+
+	suppression, err := svc.ds.SoftwareCVESuppression(id)
+	if err != nil {
+		return err
+	}
+	if err := svc.ds.DeleteSoftwareCVESuppression(id); err != nil {
+		return err
+	}
+
+	return svc.ds.NewActivity(
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeDeletedVulnerabilitySuppression,
+		&map[string]interface{}{"software_id": suppression.SoftwareID, "cve": suppression.CVE},
+	)
+}
+
+// softwareInstalledWebhookPayload is the payload posted to the software
+// installed webhook.
+type softwareInstalledWebhookPayload struct {
+	Timestamp time.Time        `json:"timestamp"`
+	HostID    uint             `json:"host_id"`
+	Hostname  string           `json:"hostname"`
+	TeamID    *uint            `json:"team_id"`
+	Software  []fleet.Software `json:"software"`
+}
+
+// compiledSoftwareWatchlist is a software watchlist with its name patterns
+// compiled.
+type compiledSoftwareWatchlist struct {
+	watchlist    fleet.SoftwareWatchlist
+	namePatterns []*regexp.Regexp
+}
+
+// compileSoftwareWatchlist compiles the name patterns of the watchlist,
+// returning an error if a name pattern or a CPE is invalid.
+func compileSoftwareWatchlist(watchlist fleet.SoftwareWatchlist) (*compiledSoftwareWatchlist, error) {
+	compiled := &compiledSoftwareWatchlist{watchlist: watchlist}
+	for _, pattern := range watchlist.NamePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compile name pattern %s", pattern)
+		}
+		compiled.namePatterns = append(compiled.namePatterns, re)
+	}
+	for _, cpe := range watchlist.CPEs {
+		if err := vulnerabilities.ValidateCPE(cpe); err != nil {
+			return nil, errors.Wrapf(err, "validate CPE %s", cpe)
+		}
+	}
+	return compiled, nil
+}
+
+// matches returns whether the software is watched.
+func (w *compiledSoftwareWatchlist) matches(s fleet.Software) bool {
+	for _, re := range w.namePatterns {
+		if re.MatchString(s.Name) {
+			return true
+		}
+	}
+	if len(w.watchlist.CPEs) == 0 {
+		return false
+	}
+	cpe := vulnerabilities.CPEFromSoftware(s)
+	if cpe == "" {
+		return false
+	}
+	for _, pattern := range w.watchlist.CPEs {
+		if ok, err := vulnerabilities.MatchCPE(pattern, cpe); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// softwareWatchlistCache holds the software watchlist compiled when it was
+// saved, so that it is not compiled again for the software of every host.
+type softwareWatchlistCache struct {
+	mutex    sync.Mutex
+	compiled *compiledSoftwareWatchlist
+}
+
+// set replaces the cached watchlist.
+func (c *softwareWatchlistCache) set(compiled *compiledSoftwareWatchlist) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.compiled = compiled
+}
+
+// get returns the compiled watchlist. It is only compiled if it differs from
+// the cached one, such as when it was saved by another Fleet server.
+func (c *softwareWatchlistCache) get(watchlist fleet.SoftwareWatchlist) (*compiledSoftwareWatchlist, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.compiled != nil && reflect.DeepEqual(c.compiled.watchlist, watchlist) {
+		return c.compiled, nil
+	}
+	compiled, err := compileSoftwareWatchlist(watchlist)
+	if err != nil {
+		return nil, err
+	}
+	c.compiled = compiled
+	return compiled, nil
+}
+
+// fireSoftwareInstalledWebhook posts the watched software newly installed on
+// the host to the software installed webhook, if it is enabled. The webhook
+// is posted in the background so that the hosts are not slowed down, errors
+// are logged.
+func (svc *Service) fireSoftwareInstalledWebhook(host *fleet.Host) {
+	if len(host.HostSoftware.Installed) == 0 {
+		return
+	}
+	config, err := svc.ds.AppConfig()
+	if err != nil {
+		level.Error(svc.logger).Log("err", err, "msg", "failed to load app config for software installed webhook")
+		return
+	}
+	if !config.SoftwareInstalledWebhookEnabled || config.SoftwareInstalledWebhookURL == "" {
+		return
+	}
+
+	watchlist, err := svc.softwareWatchlist.get(config.SoftwareInstalledWebhookWatchlist)
+	if err != nil {
+		level.Error(svc.logger).Log("err", err, "msg", "invalid software installed webhook watchlist")
+		return
+	}
+
+	var watched []fleet.Software
+	for _, s := range host.HostSoftware.Installed {
+		if watchlist.matches(s) {
+			watched = append(watched, s)
+		}
+	}
+	if len(watched) == 0 {
+		return
+	}
+
+	url := config.SoftwareInstalledWebhookURL
+	payload := softwareInstalledWebhookPayload{
+		Timestamp: svc.clock.Now(),
+		HostID:    host.ID,
+		Hostname:  host.Hostname,
+		TeamID:    host.TeamID,
+		Software:  watched,
+	}
+	go func() {
+		if err := webhooks.PostJSON(context.Background(), url, payload); err != nil {
+			level.Error(svc.logger).Log(
+				"err", err,
+				"msg", "failed to post software installed webhook",
+				"host_id", payload.HostID,
+			)
+		}
+	}()
+}
+
+// ListHostOperatingSystemVulnerabilities returns the vulnerabilities of the
+// operating system of the host, found from the security updates missing on
+// it.
+func (svc *Service) ListHostOperatingSystemVulnerabilities(ctx context.Context, hostID uint) ([]fleet.OperatingSystemVulnerability, error) {
+	// First ensure the user has access to list hosts, then check the specific
+	// host once team_id is loaded.
+	if err := svc.authz.Authorize(ctx, &fleet.Host{}, fleet.ActionList); err != nil {
+		return nil, err
+	}
+	host, err := svc.ds.Host(hostID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get host")
+	}
+	if err := svc.authz.Authorize(ctx, host, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+
+	return svc.ds.ListHostOperatingSystemVulnerabilities(hostID)
+}
@@ -0,0 +1,454 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/clock"
+	"github.com/fleetdm/fleet/v4/server/config"
+	hostctx "github.com/fleetdm/fleet/v4/server/contexts/host"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/fleetdm/fleet/v4/server/test"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListSoftware(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(ds, nil, nil)
+
+	var calledWith fleet.SoftwareListOptions
+	ds.ListSoftwareFunc = func(opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+		calledWith = opt
+		return []fleet.Software{{ID: 1, Name: "foo", Version: "1.0", Source: "apps", HostsCount: 2}}, nil
+	}
+	ds.LoadSoftwareVulnerabilitiesFunc = func(software []fleet.Software) error {
+		for i := range software {
+			software[i].Vulnerabilities = fleet.VulnerabilitiesSlice{{CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9)}}
+		}
+		return nil
+	}
+
+	opt := fleet.SoftwareListOptions{ListOptions: fleet.ListOptions{OrderKey: "hosts_count", MatchQuery: "foo"}}
+	software, err := svc.ListSoftware(test.UserContext(test.UserObserver), nil, opt)
+	require.NoError(t, err)
+	assert.True(t, ds.ListSoftwareFuncInvoked)
+	assert.Equal(t, opt, calledWith)
+	require.Len(t, software, 1)
+	assert.Equal(t, 2, software[0].HostsCount)
+	assert.Equal(t, fleet.VulnerabilitiesSlice{{CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9)}}, software[0].Vulnerabilities)
+
+	ds.ListSoftwareFuncInvoked = false
+	_, err = svc.ListSoftware(test.UserContext(test.UserAdmin), nil, fleet.SoftwareListOptions{ListOptions: fleet.ListOptions{OrderKey: "source"}})
+	require.Error(t, err)
+	assert.False(t, ds.ListSoftwareFuncInvoked)
+
+	_, err = svc.ListSoftware(context.Background(), nil, fleet.SoftwareListOptions{})
+	require.Error(t, err)
+	assert.False(t, ds.ListSoftwareFuncInvoked)
+}
+
+func TestListSoftwareByTeam(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(ds, nil, nil)
+
+	ds.ListSoftwareFunc = func(opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+		return nil, nil
+	}
+	var calledWithTeam uint
+	ds.ListSoftwareByTeamFunc = func(teamID uint, opt fleet.SoftwareListOptions) ([]fleet.Software, error) {
+		calledWithTeam = teamID
+		return nil, nil
+	}
+	ds.LoadSoftwareVulnerabilitiesFunc = func(software []fleet.Software) error {
+		return nil
+	}
+
+	teamMaintainer := &fleet.User{
+		Teams: []fleet.UserTeam{
+			{Team: fleet.Team{ID: 1}, Role: fleet.RoleMaintainer},
+		},
+	}
+	ctx := test.UserContext(teamMaintainer)
+
+	_, err := svc.ListSoftware(ctx, ptr.Uint(1), fleet.SoftwareListOptions{})
+	require.NoError(t, err)
+	assert.True(t, ds.ListSoftwareByTeamFuncInvoked)
+	assert.Equal(t, uint(1), calledWithTeam)
+
+	// Team users can't list the software of other teams or of the whole
+	// organization.
+	ds.ListSoftwareByTeamFuncInvoked = false
+	_, err = svc.ListSoftware(ctx, ptr.Uint(2), fleet.SoftwareListOptions{})
+	require.Error(t, err)
+	assert.False(t, ds.ListSoftwareByTeamFuncInvoked)
+	_, err = svc.ListSoftware(ctx, nil, fleet.SoftwareListOptions{})
+	require.Error(t, err)
+	assert.False(t, ds.ListSoftwareFuncInvoked)
+
+	_, err = svc.ListSoftware(test.UserContext(test.UserAdmin), ptr.Uint(2), fleet.SoftwareListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), calledWithTeam)
+}
+
+func TestListSoftwareTitles(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(ds, nil, nil)
+
+	var calledWith fleet.ListOptions
+	ds.ListSoftwareTitlesFunc = func(opt fleet.ListOptions) ([]fleet.SoftwareTitleSummary, error) {
+		calledWith = opt
+		return []fleet.SoftwareTitleSummary{{ID: 1, Name: "foo", Source: "apps", HostsCount: 3, VersionsCount: 2}}, nil
+	}
+
+	opt := fleet.ListOptions{OrderKey: "versions_count", MatchQuery: "foo"}
+	titles, err := svc.ListSoftwareTitles(test.UserContext(test.UserObserver), opt)
+	require.NoError(t, err)
+	assert.Equal(t, opt, calledWith)
+	require.Len(t, titles, 1)
+	assert.Equal(t, 2, titles[0].VersionsCount)
+
+	ds.ListSoftwareTitlesFuncInvoked = false
+	_, err = svc.ListSoftwareTitles(test.UserContext(test.UserAdmin), fleet.ListOptions{OrderKey: "version"})
+	require.Error(t, err)
+	assert.False(t, ds.ListSoftwareTitlesFuncInvoked)
+
+	teamMaintainer := &fleet.User{
+		Teams: []fleet.UserTeam{
+			{Team: fleet.Team{ID: 1}, Role: fleet.RoleMaintainer},
+		},
+	}
+	_, err = svc.ListSoftwareTitles(test.UserContext(teamMaintainer), fleet.ListOptions{})
+	require.Error(t, err)
+	assert.False(t, ds.ListSoftwareTitlesFuncInvoked)
+}
+
+func TestListOutdatedSoftwareHosts(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(ds, nil, nil)
+
+	ds.ListHostSoftwareVersionsByNameFunc = func(name string) ([]fleet.HostSoftwareVersion, error) {
+		return []fleet.HostSoftwareVersion{
+			{HostID: 1, Hostname: "foo", SoftwareID: 1, Name: name, Version: "1.9.0", Source: "apps"},
+			{HostID: 2, Hostname: "bar", SoftwareID: 2, Name: name, Version: "1.10.0-rc.1", Source: "apps"},
+			{HostID: 3, Hostname: "baz", SoftwareID: 3, Name: name, Version: "1.10.0", Source: "apps"},
+			{HostID: 4, Hostname: "qux", SoftwareID: 4, Name: name, Version: "1.11.2", Source: "apps"},
+		}, nil
+	}
+
+	hosts, err := svc.ListOutdatedSoftwareHosts(test.UserContext(test.UserObserver), "zoom", "1.10.0")
+	require.NoError(t, err)
+	var hostIDs []uint
+	for _, h := range hosts {
+		hostIDs = append(hostIDs, h.HostID)
+	}
+	assert.Equal(t, []uint{1, 2}, hostIDs)
+
+	ds.ListHostSoftwareVersionsByNameFuncInvoked = false
+	_, err = svc.ListOutdatedSoftwareHosts(test.UserContext(test.UserAdmin), "zoom", "")
+	require.Error(t, err)
+	_, err = svc.ListOutdatedSoftwareHosts(test.UserContext(test.UserAdmin), "", "1.0")
+	require.Error(t, err)
+	assert.False(t, ds.ListHostSoftwareVersionsByNameFuncInvoked)
+}
+
+// memSoftwareQueue is an in memory fleet.HostSoftwareQueue.
+type memSoftwareQueue struct {
+	hosts []*fleet.Host
+}
+
+func (q *memSoftwareQueue) Enqueue(host *fleet.Host) error {
+	queued := &fleet.Host{ID: host.ID, TeamID: host.TeamID, HostSoftware: host.HostSoftware}
+	for i, h := range q.hosts {
+		if h.ID == host.ID {
+			q.hosts[i] = queued
+			return nil
+		}
+	}
+	q.hosts = append(q.hosts, queued)
+	return nil
+}
+
+func (q *memSoftwareQueue) Dequeue(n int) ([]*fleet.Host, error) {
+	if n > len(q.hosts) {
+		n = len(q.hosts)
+	}
+	hosts := q.hosts[:n]
+	q.hosts = q.hosts[n:]
+	return hosts, nil
+}
+
+func TestAsyncSoftwareIngestion(t *testing.T) {
+	ds := new(mock.Store)
+	queue := &memSoftwareQueue{}
+	cfg := config.TestConfig()
+	cfg.Osquery.AsyncSoftwareIngestionBatchSize = 1
+	svc, err := NewService(ds, nil, kitlog.NewNopLogger(), cfg, nil, clock.C, nil, nil, ds, queue, fleet.LicenseInfo{Tier: "core"})
+	require.NoError(t, err)
+
+	var saved fleet.Host
+	ds.SaveHostFunc = func(host *fleet.Host) error {
+		saved = *host
+		return nil
+	}
+	results := fleet.OsqueryDistributedQueryResults{
+		hostDetailQueryPrefix + "software_linux": {
+			{"name": "curl", "version": "7.68.0", "source": "deb_packages"},
+		},
+	}
+	for _, id := range []uint{1, 2, 1} {
+		ctx := hostctx.NewContext(context.Background(), fleet.Host{ID: id, Platform: "ubuntu"})
+		require.NoError(t, svc.SubmitDistributedQueryResults(ctx, results, nil, nil))
+		// The software is saved asynchronously.
+		assert.True(t, ds.SaveHostFuncInvoked)
+		assert.False(t, saved.HostSoftware.Modified)
+	}
+	require.Len(t, queue.hosts, 2)
+
+	var savedSoftware []uint
+	ds.SaveHostSoftwareFunc = func(host *fleet.Host) error {
+		assert.True(t, host.HostSoftware.Modified)
+		assert.Equal(t, []fleet.Software{{Name: "curl", Version: "7.68.0", Source: "deb_packages", Managed: true}}, host.HostSoftware.Software)
+		savedSoftware = append(savedSoftware, host.ID)
+		return nil
+	}
+	require.NoError(t, svc.FlushHostSoftware(context.Background()))
+	assert.Equal(t, []uint{1, 2}, savedSoftware)
+	assert.Empty(t, queue.hosts)
+}
+
+func TestListHostSoftwareChanges(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(ds, nil, nil)
+
+	ds.HostFunc = func(id uint) (*fleet.Host, error) {
+		return &fleet.Host{ID: id, TeamID: ptr.Uint(1)}, nil
+	}
+	ds.ListHostSoftwareChangesFunc = func(hostID uint, opt fleet.ListOptions) ([]fleet.HostSoftwareChange, error) {
+		return []fleet.HostSoftwareChange{{Name: "foo", Version: "1.0", Source: "apps", Action: fleet.SoftwareHistoryInstalled}}, nil
+	}
+
+	changes, err := svc.ListHostSoftwareChanges(test.UserContext(test.UserObserver), 3, fleet.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	_, err = svc.ListHostSoftwareChanges(test.UserContext(test.UserAdmin), 3, fleet.ListOptions{OrderKey: "name"})
+	require.Error(t, err)
+
+	// Team users can only read the changes of the hosts of their teams.
+	teamObserver := func(teamID uint) *fleet.User {
+		return &fleet.User{Teams: []fleet.UserTeam{{Team: fleet.Team{ID: teamID}, Role: fleet.RoleObserver}}}
+	}
+	_, err = svc.ListHostSoftwareChanges(test.UserContext(teamObserver(1)), 3, fleet.ListOptions{})
+	require.NoError(t, err)
+	ds.ListHostSoftwareChangesFuncInvoked = false
+	_, err = svc.ListHostSoftwareChanges(test.UserContext(teamObserver(2)), 3, fleet.ListOptions{})
+	require.Error(t, err)
+	assert.False(t, ds.ListHostSoftwareChangesFuncInvoked)
+}
+
+func TestHostSBOM(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(ds, nil, nil)
+
+	ds.HostFunc = func(id uint) (*fleet.Host, error) {
+		return &fleet.Host{ID: id, Hostname: "host1", Platform: "ubuntu", TeamID: ptr.Uint(1)}, nil
+	}
+	ds.LoadHostSoftwareFunc = func(host *fleet.Host) error {
+		host.Software = []fleet.Software{{ID: 1, Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"}}
+		return nil
+	}
+
+	document, err := svc.HostSBOM(test.UserContext(test.UserObserver), 3, fleet.SBOMFormatCycloneDX)
+	require.NoError(t, err)
+	var bom map[string]interface{}
+	require.NoError(t, json.Unmarshal(document, &bom))
+	assert.Equal(t, "CycloneDX", bom["bomFormat"])
+
+	document, err = svc.HostSBOM(test.UserContext(test.UserObserver), 3, fleet.SBOMFormatSPDX)
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(document, &doc))
+	assert.Equal(t, "SPDX-2.2", doc["spdxVersion"])
+
+	ds.LoadHostSoftwareFuncInvoked = false
+	_, err = svc.HostSBOM(test.UserContext(test.UserAdmin), 3, "swid")
+	require.Error(t, err)
+	assert.False(t, ds.LoadHostSoftwareFuncInvoked)
+
+	// Team users can only read the software of the hosts of their teams.
+	teamObserver := &fleet.User{Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 2}, Role: fleet.RoleObserver}}}
+	_, err = svc.HostSBOM(test.UserContext(teamObserver), 3, fleet.SBOMFormatCycloneDX)
+	require.Error(t, err)
+	assert.False(t, ds.LoadHostSoftwareFuncInvoked)
+}
+
+func TestSoftwareInstalledWebhook(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(ds, nil, nil)
+
+	posted := make(chan softwareInstalledWebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload softwareInstalledWebhookPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		posted <- payload
+	}))
+	defer server.Close()
+
+	ds.AppConfigFunc = func() (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{
+			SoftwareInstalledWebhookEnabled: true,
+			SoftwareInstalledWebhookURL:     server.URL,
+			SoftwareInstalledWebhookWatchlist: fleet.SoftwareWatchlist{
+				NamePatterns: []string{"^transmission"},
+				CPEs:         []string{"cpe:2.3:a:*:netcat:*:*:*:*:*:*:*:*"},
+			},
+		}, nil
+	}
+	ds.SaveHostFunc = func(host *fleet.Host) error {
+		// Everything reported is newly installed.
+		host.HostSoftware.Installed = host.HostSoftware.Software
+		return nil
+	}
+
+	results := fleet.OsqueryDistributedQueryResults{
+		hostDetailQueryPrefix + "software_linux": {
+			{"name": "curl", "version": "7.68.0", "source": "deb_packages"},
+			{"name": "transmission-gtk", "version": "3.00", "source": "deb_packages"},
+			{"name": "netcat", "version": "1.206-1ubuntu1", "source": "deb_packages"},
+		},
+	}
+	ctx := hostctx.NewContext(context.Background(), fleet.Host{ID: 1, Hostname: "foo", Platform: "ubuntu"})
+	require.NoError(t, svc.SubmitDistributedQueryResults(ctx, results, nil, nil))
+
+	select {
+	case payload := <-posted:
+		assert.Equal(t, uint(1), payload.HostID)
+		assert.Equal(t, "foo", payload.Hostname)
+		var names []string
+		for _, s := range payload.Software {
+			names = append(names, s.Name)
+		}
+		assert.ElementsMatch(t, []string{"transmission-gtk", "netcat"}, names)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not posted")
+	}
+}
+
+func TestSoftwareWatchlistMatches(t *testing.T) {
+	watchlist, err := compileSoftwareWatchlist(fleet.SoftwareWatchlist{
+		NamePatterns: []string{"(?i)^utorrent"},
+		CPEs:         []string{"cpe:2.3:a:*:google_chrome:91.0.4472.124:*:*:*:*:*:*:*"},
+	})
+	require.NoError(t, err)
+	assert.True(t, watchlist.matches(fleet.Software{Name: "uTorrent.app", Version: "1.8.7", Source: "apps"}))
+	assert.True(t, watchlist.matches(fleet.Software{Name: "Google Chrome.app", Version: "91.0.4472.124", Source: "apps"}))
+	assert.False(t, watchlist.matches(fleet.Software{Name: "Google Chrome.app", Version: "92.0.4515.107", Source: "apps"}))
+
+	empty, err := compileSoftwareWatchlist(fleet.SoftwareWatchlist{})
+	require.NoError(t, err)
+	assert.False(t, empty.matches(fleet.Software{Name: "uTorrent.app", Version: "1.8.7", Source: "apps"}))
+
+	// Invalid patterns and CPEs are rejected when compiled.
+	_, err = compileSoftwareWatchlist(fleet.SoftwareWatchlist{NamePatterns: []string{"(?i)^utorrent", "["}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "compile name pattern [")
+	_, err = compileSoftwareWatchlist(fleet.SoftwareWatchlist{CPEs: []string{"cpe:2.3:a"}})
+	require.Error(t, err)
+}
+
+func TestSoftwareWatchlistCache(t *testing.T) {
+	watchlist := fleet.SoftwareWatchlist{NamePatterns: []string{"^transmission"}}
+	compiled, err := compileSoftwareWatchlist(watchlist)
+	require.NoError(t, err)
+
+	cache := &softwareWatchlistCache{}
+	cache.set(compiled)
+
+	// The saved watchlist is not compiled again.
+	got, err := cache.get(watchlist)
+	require.NoError(t, err)
+	assert.Same(t, compiled, got)
+
+	// A watchlist saved by another server is compiled once.
+	other := fleet.SoftwareWatchlist{NamePatterns: []string{"^netcat"}}
+	got, err = cache.get(other)
+	require.NoError(t, err)
+	assert.NotSame(t, compiled, got)
+	assert.True(t, got.matches(fleet.Software{Name: "netcat"}))
+	again, err := cache.get(other)
+	require.NoError(t, err)
+	assert.Same(t, got, again)
+
+	_, err = cache.get(fleet.SoftwareWatchlist{NamePatterns: []string{"["}})
+	require.Error(t, err)
+}
+
+func TestSuppressSoftwareCVE(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(ds, nil, nil)
+
+	ds.SoftwareByIDFunc = func(id uint) (*fleet.Software, error) {
+		return &fleet.Software{ID: id, Name: "openssl", Version: "1.1.1f", Source: "deb_packages"}, nil
+	}
+	ds.NewSoftwareCVESuppressionFunc = func(suppression *fleet.SoftwareCVESuppression) (*fleet.SoftwareCVESuppression, error) {
+		suppression.ID = 1
+		return suppression, nil
+	}
+	var activity string
+	ds.NewActivityFunc = func(user *fleet.User, activityType string, details *map[string]interface{}) error {
+		activity = activityType
+		return nil
+	}
+
+	suppression, err := svc.SuppressSoftwareCVE(test.UserContext(test.UserAdmin), 3, " CVE-2021-3449 ", "not exploitable, the vulnerable function is not built")
+	require.NoError(t, err)
+	assert.Equal(t, uint(3), suppression.SoftwareID)
+	assert.Equal(t, "CVE-2021-3449", suppression.CVE)
+	assert.Equal(t, test.UserAdmin.ID, *suppression.AuthorID)
+	assert.Equal(t, fleet.ActivityTypeSuppressedVulnerability, activity)
+
+	ds.NewSoftwareCVESuppressionFuncInvoked = false
+	_, err = svc.SuppressSoftwareCVE(test.UserContext(test.UserAdmin), 3, "3449", "")
+	var invalid *fleet.InvalidArgumentError
+	require.True(t, errors.As(err, &invalid))
+	assert.Len(t, *invalid, 2)
+	assert.False(t, ds.NewSoftwareCVESuppressionFuncInvoked)
+
+	// Only admins can suppress vulnerabilities.
+	_, err = svc.SuppressSoftwareCVE(test.UserContext(test.UserMaintainer), 3, "CVE-2021-3449", "reason")
+	require.Error(t, err)
+	assert.False(t, ds.NewSoftwareCVESuppressionFuncInvoked)
+}
+
+func TestListHostOperatingSystemVulnerabilities(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(ds, nil, nil)
+
+	ds.HostFunc = func(id uint) (*fleet.Host, error) {
+		return &fleet.Host{ID: id, Platform: "windows", TeamID: ptr.Uint(1)}, nil
+	}
+	ds.ListHostOperatingSystemVulnerabilitiesFunc = func(hostID uint) ([]fleet.OperatingSystemVulnerability, error) {
+		return []fleet.OperatingSystemVulnerability{{CVE: "CVE-2021-36936", ResolvedIn: "KB5005033"}}, nil
+	}
+
+	vulnerabilities, err := svc.ListHostOperatingSystemVulnerabilities(test.UserContext(test.UserObserver), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []fleet.OperatingSystemVulnerability{{CVE: "CVE-2021-36936", ResolvedIn: "KB5005033"}}, vulnerabilities)
+
+	// Team users can only read the vulnerabilities of the hosts of their
+	// teams.
+	ds.ListHostOperatingSystemVulnerabilitiesFuncInvoked = false
+	teamObserver := &fleet.User{Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 2}, Role: fleet.RoleObserver}}}
+	_, err = svc.ListHostOperatingSystemVulnerabilities(test.UserContext(teamObserver), 3)
+	require.Error(t, err)
+	assert.False(t, ds.ListHostOperatingSystemVulnerabilitiesFuncInvoked)
+}
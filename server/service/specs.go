@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/pkg/errors"
+	"gopkg.in/guregu/null.v3"
+)
+
+type validateSpecsRequest struct {
+	Specs *fleet.Specs `json:"specs"`
+}
+
+type validateSpecsResponse struct {
+	Changes []*fleet.SpecChange `json:"changes"`
+	Err     error               `json:"error,omitempty"`
+}
+
+func (r validateSpecsResponse) error() error { return r.Err }
+
+func makeValidateSpecsEndpoint(svc fleet.Service, opts []kithttp.ServerOption) http.Handler {
+	return newServer(
+		makeAuthenticatedServiceEndpoint(svc, validateSpecsEndpoint),
+		makeDecoderForType(validateSpecsRequest{}),
+		opts,
+	)
+}
+
+func validateSpecsEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*validateSpecsRequest)
+	if req.Specs == nil {
+		req.Specs = &fleet.Specs{}
+	}
+	changes, err := svc.ValidateSpecs(ctx, req.Specs)
+	if err != nil {
+		return validateSpecsResponse{Err: err}, nil
+	}
+	return validateSpecsResponse{Changes: changes}, nil
+}
+
+// specChanges accumulates the changes found by ValidateSpecs.
+type specChanges []*fleet.SpecChange
+
+func (c *specChanges) add(kind, name, action string) {
+	*c = append(*c, &fleet.SpecChange{Kind: kind, Name: name, Action: action})
+}
+
+func (svc Service) ValidateSpecs(ctx context.Context, specs *fleet.Specs) ([]*fleet.SpecChange, error) {
+	if len(specs.Queries) == 0 && len(specs.Labels) == 0 && len(specs.Packs) == 0 &&
+		specs.EnrollSecret == nil && specs.UsersRoles == nil {
+		svc.authz.SkipAuthorization(ctx)
+		return []*fleet.SpecChange{}, nil
+	}
+
+	// The specs are checked in the order fleetctl apply applies them, so
+	// that packs can use the queries and labels applied with them.
+	changes := specChanges{}
+	invalid := &fleet.InvalidArgumentError{}
+	queries, err := svc.validateQuerySpecs(ctx, specs.Queries, &changes, invalid)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := svc.validateLabelSpecs(ctx, specs.Labels, &changes, invalid)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.validatePackSpecs(ctx, specs.Packs, queries, labels, &changes, invalid); err != nil {
+		return nil, err
+	}
+	if err := svc.validateEnrollSecretSpec(ctx, specs.EnrollSecret, &changes, invalid); err != nil {
+		return nil, err
+	}
+	if err := svc.validateUserRolesSpec(ctx, specs.UsersRoles, &changes, invalid); err != nil {
+		return nil, err
+	}
+
+	if invalid.HasErrors() {
+		return nil, invalid
+	}
+	return changes, nil
+}
+
+// validateQuerySpecs returns the names of the queries existing after applying
+// the specs.
+func (svc Service) validateQuerySpecs(ctx context.Context, specs []*fleet.QuerySpec, changes *specChanges, invalid *fleet.InvalidArgumentError) (map[string]bool, error) {
+	if len(specs) > 0 {
+		if err := svc.authz.Authorize(ctx, &fleet.Query{}, fleet.ActionWrite); err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := svc.ds.ListQueries(fleet.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list queries")
+	}
+	names := make(map[string]bool)
+	current := make(map[string]*fleet.QuerySpec)
+	for _, query := range existing {
+		names[query.Name] = true
+		current[query.Name] = specFromQuery(query)
+	}
+
+	for _, spec := range specs {
+		if err := queryFromSpec(spec).ValidateSQL(); err != nil {
+			invalid.Appendf("queries", "query %s: %s", spec.Name, err)
+			continue
+		}
+		existingSpec, ok := current[spec.Name]
+		switch {
+		case !ok:
+			changes.add(fleet.QueryKind, spec.Name, fleet.SpecChangeCreate)
+		case !reflect.DeepEqual(existingSpec, specFromQuery(queryFromSpec(spec))):
+			changes.add(fleet.QueryKind, spec.Name, fleet.SpecChangeUpdate)
+		}
+		names[spec.Name] = true
+	}
+	return names, nil
+}
+
+// validateLabelSpecs returns the names of the labels existing after applying
+// the specs.
+func (svc Service) validateLabelSpecs(ctx context.Context, specs []*fleet.LabelSpec, changes *specChanges, invalid *fleet.InvalidArgumentError) (map[string]bool, error) {
+	if len(specs) > 0 {
+		if err := svc.authz.Authorize(ctx, &fleet.Label{}, fleet.ActionWrite); err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := svc.ds.GetLabelSpecs()
+	if err != nil {
+		return nil, errors.Wrap(err, "get label specs")
+	}
+	names := make(map[string]bool)
+	current := make(map[string]*fleet.LabelSpec)
+	for _, label := range existing {
+		names[label.Name] = true
+		current[label.Name] = label
+	}
+
+	for _, spec := range specs {
+		if spec.LabelMembershipType == fleet.LabelMembershipTypeDynamic && len(spec.Hosts) > 0 {
+			invalid.Appendf("labels", "label %s is declared as dynamic but contains `hosts` key", spec.Name)
+			continue
+		}
+		if spec.LabelMembershipType == fleet.LabelMembershipTypeManual && spec.Hosts == nil {
+			invalid.Appendf("labels", "label %s is declared as manual but contains no `hosts key`", spec.Name)
+			continue
+		}
+		existingSpec, ok := current[spec.Name]
+		switch {
+		case !ok:
+			changes.add(fleet.LabelKind, spec.Name, fleet.SpecChangeCreate)
+		case !labelSpecsEqual(existingSpec, spec):
+			changes.add(fleet.LabelKind, spec.Name, fleet.SpecChangeUpdate)
+		}
+		names[spec.Name] = true
+	}
+	return names, nil
+}
+
+func labelSpecsEqual(a, b *fleet.LabelSpec) bool {
+	hosts := func(spec *fleet.LabelSpec) []string {
+		sorted := append([]string{}, spec.Hosts...)
+		sort.Strings(sorted)
+		return sorted
+	}
+	return a.Description == b.Description && a.Query == b.Query && a.Platform == b.Platform &&
+		a.LabelMembershipType == b.LabelMembershipType && reflect.DeepEqual(hosts(a), hosts(b))
+}
+
+func (svc Service) validatePackSpecs(ctx context.Context, specs []*fleet.PackSpec, queries, labels map[string]bool, changes *specChanges, invalid *fleet.InvalidArgumentError) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	if err := svc.authz.Authorize(ctx, &fleet.Pack{}, fleet.ActionWrite); err != nil {
+		return err
+	}
+
+	existing, err := svc.ds.GetPackSpecs()
+	if err != nil {
+		return errors.Wrap(err, "get pack specs")
+	}
+	current := make(map[string]*fleet.PackSpec)
+	for _, pack := range existing {
+		current[pack.Name] = pack
+	}
+
+	for _, spec := range specs {
+		if spec.Name == "" {
+			invalid.Append("packs", "pack name must not be empty")
+			continue
+		}
+		valid := true
+		for _, query := range spec.Queries {
+			if !queries[query.QueryName] {
+				invalid.Appendf("packs", "pack %s cannot schedule unknown query '%s'", spec.Name, query.QueryName)
+				valid = false
+			}
+		}
+		for _, label := range spec.Targets.Labels {
+			if !labels[label] {
+				invalid.Appendf("packs", "pack %s cannot target unknown label '%s'", spec.Name, label)
+				valid = false
+			}
+		}
+		for _, team := range spec.Targets.Teams {
+			if _, err := svc.ds.TeamByName(team); err != nil {
+				if !fleet.IsNotFound(err) {
+					return errors.Wrap(err, "get team")
+				}
+				invalid.Appendf("packs", "pack %s cannot target unknown team '%s'", spec.Name, team)
+				valid = false
+			}
+		}
+		if !valid {
+			continue
+		}
+
+		existingSpec, ok := current[spec.Name]
+		if !ok {
+			changes.add(fleet.PackKind, spec.Name, fleet.SpecChangeCreate)
+			continue
+		}
+		if !reflect.DeepEqual(normalizedPackSpec(existingSpec), normalizedPackSpec(spec)) {
+			changes.add(fleet.PackKind, spec.Name, fleet.SpecChangeUpdate)
+		}
+
+		// Applying a pack replaces all its scheduled queries.
+		scheduled := make(map[string]fleet.PackSpecQuery)
+		for _, query := range existingSpec.Queries {
+			scheduled[query.Name] = query
+		}
+		for _, query := range spec.Queries {
+			if query.Name == "" {
+				query.Name = query.QueryName
+			}
+			existingQuery, ok := scheduled[query.Name]
+			switch {
+			case !ok:
+				changes.add("scheduled_query", spec.Name+"/"+query.Name, fleet.SpecChangeCreate)
+			case !reflect.DeepEqual(fleet.NormalizedPackSpecQuery(existingQuery), fleet.NormalizedPackSpecQuery(query)):
+				changes.add("scheduled_query", spec.Name+"/"+query.Name, fleet.SpecChangeUpdate)
+			}
+			delete(scheduled, query.Name)
+		}
+		var removed []string
+		for name := range scheduled {
+			removed = append(removed, name)
+		}
+		sort.Strings(removed)
+		for _, name := range removed {
+			changes.add("scheduled_query", spec.Name+"/"+name, fleet.SpecChangeDelete)
+		}
+	}
+	return nil
+}
+
+// normalizedPackSpec returns the fields of the pack stored with the pack
+// itself, with empty target lists as nil.
+func normalizedPackSpec(spec *fleet.PackSpec) fleet.PackSpec {
+	normalized := fleet.PackSpec{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Platform:    spec.Platform,
+		Disabled:    spec.Disabled,
+	}
+	if len(spec.Targets.Labels) > 0 {
+		normalized.Targets.Labels = append([]string{}, spec.Targets.Labels...)
+		sort.Strings(normalized.Targets.Labels)
+	}
+	if len(spec.Targets.Teams) > 0 {
+		normalized.Targets.Teams = append([]string{}, spec.Targets.Teams...)
+		sort.Strings(normalized.Targets.Teams)
+	}
+	return normalized
+}
+
+func (svc Service) validateEnrollSecretSpec(ctx context.Context, spec *fleet.EnrollSecretSpec, changes *specChanges, invalid *fleet.InvalidArgumentError) error {
+	if spec == nil {
+		return nil
+	}
+	if err := svc.authz.Authorize(ctx, &fleet.EnrollSecret{}, fleet.ActionWrite); err != nil {
+		return err
+	}
+
+	existing, err := svc.ds.GetEnrollSecrets(nil)
+	if err != nil {
+		return errors.Wrap(err, "get enroll secrets")
+	}
+	// Applying the spec replaces the global enroll secrets.
+	current := make(map[string]bool)
+	for _, secret := range existing {
+		current[secret.Secret] = true
+	}
+	for _, secret := range spec.Secrets {
+		if secret.Secret == "" {
+			invalid.Append("enroll_secret", "enroll secret must not be empty")
+			continue
+		}
+		if !current[secret.Secret] {
+			changes.add(fleet.EnrollSecretKind, "global", fleet.SpecChangeCreate)
+		}
+		delete(current, secret.Secret)
+	}
+	for range current {
+		changes.add(fleet.EnrollSecretKind, "global", fleet.SpecChangeDelete)
+	}
+	return nil
+}
+
+func (svc Service) validateUserRolesSpec(ctx context.Context, spec *fleet.UsersRoleSpec, changes *specChanges, invalid *fleet.InvalidArgumentError) error {
+	if spec == nil {
+		return nil
+	}
+	if err := svc.authz.Authorize(ctx, &fleet.User{}, fleet.ActionWrite); err != nil {
+		return err
+	}
+
+	emails := make([]string, 0, len(spec.Roles))
+	for email := range spec.Roles {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	for _, email := range emails {
+		roles := spec.Roles[email]
+		user, err := svc.ds.UserByEmail(email)
+		if err != nil {
+			if !fleet.IsNotFound(err) {
+				return errors.Wrap(err, "get user")
+			}
+			invalid.Appendf("user_roles", "unknown user %s", email)
+			continue
+		}
+		if err := svc.checkAtLeastOneAdmin(user, roles, email); err != nil {
+			invalid.Appendf("user_roles", "%s: %s", email, err)
+			continue
+		}
+
+		current := make(map[string]string)
+		for _, team := range user.Teams {
+			current[team.Name] = team.Role
+		}
+		changed := null.StringFromPtr(user.GlobalRole) != null.StringFromPtr(roles.GlobalRole) || len(current) != len(roles.Teams)
+		for _, team := range roles.Teams {
+			if _, err := svc.ds.TeamByName(team.Name); err != nil {
+				if !fleet.IsNotFound(err) {
+					return errors.Wrap(err, "get team")
+				}
+				invalid.Appendf("user_roles", "%s: unknown team %s", email, team.Name)
+				continue
+			}
+			if role, ok := current[team.Name]; !ok || role != team.Role {
+				changed = true
+			}
+		}
+		if changed {
+			changes.add(fleet.UserRolesKind, email, fleet.SpecChangeUpdate)
+		}
+	}
+	return nil
+}
+
+func (mw loggingMiddleware) ValidateSpecs(ctx context.Context, specs *fleet.Specs) (changes []*fleet.SpecChange, err error) {
+	defer func(begin time.Time) {
+		_ = mw.loggerDebug(err).Log("method", "ValidateSpecs", "err", err, "took", time.Since(begin))
+	}(time.Now())
+	changes, err = mw.Service.ValidateSpecs(ctx, specs)
+	return changes, err
+}
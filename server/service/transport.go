@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 )
@@ -33,6 +34,19 @@ func encodeResponse(ctx context.Context, w http.ResponseWriter, response interfa
 		return nil
 	}
 
+	if s, ok := response.(streamer); ok {
+		sw := &startedResponseWriter{ResponseWriter: w}
+		if err := s.stream(sw); err != nil {
+			if sw.started {
+				// Too late to respond with an error, the partial body
+				// is followed by the error encoded by the caller.
+				return err
+			}
+			encodeError(ctx, err, w)
+		}
+		return nil
+	}
+
 	if e, ok := response.(statuser); ok {
 		w.WriteHeader(e.status())
 		if e.status() == http.StatusNoContent {
@@ -51,6 +65,30 @@ type statuser interface {
 	status() int
 }
 
+// streamer allows response types to write their own body rather than being
+// encoded as JSON, for large responses that are written as they are read.
+// Errors returned before anything is written are encoded as usual.
+type streamer interface {
+	stream(w http.ResponseWriter) error
+}
+
+// startedResponseWriter records whether anything was written to the
+// response.
+type startedResponseWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *startedResponseWriter) WriteHeader(statusCode int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *startedResponseWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
 // loads a html page
 type htmlPage interface {
 	html() string
@@ -178,6 +216,22 @@ func hostListOptionsFromRequest(r *http.Request) (fleet.HostListOptions, error)
 		return hopt, err
 	}
 
+	if tid := r.URL.Query().Get("team_id"); tid != "" {
+		teamID, err := strconv.ParseUint(tid, 10, 64)
+		if err != nil {
+			return hopt, errors.Wrap(err, "parse team_id as int")
+		}
+		hopt.TeamFilter = ptr.Uint(uint(teamID))
+	}
+	if lid := r.URL.Query().Get("label_id"); lid != "" {
+		labelID, err := strconv.ParseUint(lid, 10, 64)
+		if err != nil {
+			return hopt, errors.Wrap(err, "parse label_id as int")
+		}
+		hopt.LabelFilter = ptr.Uint(uint(labelID))
+	}
+	hopt.PlatformFilter = r.URL.Query().Get("platform")
+
 	additionalInfoFiltersString := r.URL.Query().Get("additional_info_filters")
 	if additionalInfoFiltersString != "" {
 		hopt.AdditionalFilters = strings.Split(additionalInfoFiltersString, ",")
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/pkg/errors"
+)
+
+func decodeListSoftwareRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	opt, err := listOptionsFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	req := listSoftwareRequest{ListOptions: fleet.SoftwareListOptions{ListOptions: opt}}
+
+	if tid := r.URL.Query().Get("team_id"); tid != "" {
+		teamID, err := strconv.ParseUint(tid, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse team_id as int")
+		}
+		req.TeamID = ptr.Uint(uint(teamID))
+	}
+	// An empty browser lists the software that isn't a browser extension.
+	if browser, ok := r.URL.Query()["browser"]; ok {
+		req.ListOptions.Browser = ptr.String(browser[0])
+	}
+	if exploit := r.URL.Query().Get("exploit"); exploit != "" {
+		knownExploit, err := strconv.ParseBool(exploit)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse exploit as bool")
+		}
+		req.ListOptions.KnownExploit = knownExploit
+	}
+
+	return req, nil
+}
+
+func decodeListSoftwareTitlesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	opt, err := listOptionsFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return listSoftwareTitlesRequest{ListOptions: opt}, nil
+}
+
+func decodeListOutdatedSoftwareHostsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return listOutdatedSoftwareHostsRequest{
+		Name:    r.URL.Query().Get("name"),
+		Version: r.URL.Query().Get("version"),
+	}, nil
+}
+
+func decodeListHostSoftwareChangesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := idFromRequest(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	opt, err := listOptionsFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return listHostSoftwareChangesRequest{HostID: id, ListOptions: opt}, nil
+}
+
+func decodeHostSBOMRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := idFromRequest(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	format := fleet.SBOMFormat(r.URL.Query().Get("format"))
+	if format == "" {
+		format = fleet.SBOMFormatCycloneDX
+	}
+	return hostSBOMRequest{HostID: id, Format: format}, nil
+}
+
+func decodeSuppressSoftwareCVERequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req suppressSoftwareCVERequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func decodeListSoftwareCVESuppressionsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	opt, err := listOptionsFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return listSoftwareCVESuppressionsRequest{ListOptions: opt}, nil
+}
+
+func decodeDeleteSoftwareCVESuppressionRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := idFromRequest(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return deleteSoftwareCVESuppressionRequest{ID: id}, nil
+}
+
+func decodeListHostOSVulnerabilitiesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := idFromRequest(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	return listHostOSVulnerabilitiesRequest{HostID: id}, nil
+}
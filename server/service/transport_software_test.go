@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeListSoftwareRequest(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/fleet/software", func(writer http.ResponseWriter, request *http.Request) {
+		r, err := decodeListSoftwareRequest(context.Background(), request)
+		require.NoError(t, err)
+
+		params := r.(listSoftwareRequest)
+		assert.Equal(t, ptr.Uint(3), params.TeamID)
+		assert.Equal(t, "hosts_count", params.ListOptions.OrderKey)
+		assert.Equal(t, "foo", params.ListOptions.MatchQuery)
+		assert.Equal(t, uint(2), params.ListOptions.Page)
+	}).Methods("GET")
+
+	router.ServeHTTP(
+		httptest.NewRecorder(),
+		httptest.NewRequest("GET", "/api/v1/fleet/software?team_id=3&order_key=hosts_count&query=foo&page=2", nil),
+	)
+
+	r, err := decodeListSoftwareRequest(context.Background(), httptest.NewRequest("GET", "/api/v1/fleet/software", nil))
+	require.NoError(t, err)
+	assert.Nil(t, r.(listSoftwareRequest).ListOptions.Browser)
+
+	r, err = decodeListSoftwareRequest(context.Background(), httptest.NewRequest("GET", "/api/v1/fleet/software?browser=edge", nil))
+	require.NoError(t, err)
+	assert.Equal(t, ptr.String("edge"), r.(listSoftwareRequest).ListOptions.Browser)
+
+	r, err = decodeListSoftwareRequest(context.Background(), httptest.NewRequest("GET", "/api/v1/fleet/software?browser=", nil))
+	require.NoError(t, err)
+	assert.Equal(t, ptr.String(""), r.(listSoftwareRequest).ListOptions.Browser)
+
+	assert.False(t, r.(listSoftwareRequest).ListOptions.KnownExploit)
+
+	r, err = decodeListSoftwareRequest(context.Background(), httptest.NewRequest("GET", "/api/v1/fleet/software?exploit=true", nil))
+	require.NoError(t, err)
+	assert.True(t, r.(listSoftwareRequest).ListOptions.KnownExploit)
+
+	_, err = decodeListSoftwareRequest(context.Background(), httptest.NewRequest("GET", "/api/v1/fleet/software?team_id=foo", nil))
+	assert.Error(t, err)
+	_, err = decodeListSoftwareRequest(context.Background(), httptest.NewRequest("GET", "/api/v1/fleet/software?exploit=maybe", nil))
+	assert.Error(t, err)
+}
+
+func TestDecodeListOutdatedSoftwareHostsRequest(t *testing.T) {
+	r, err := decodeListOutdatedSoftwareHostsRequest(context.Background(), httptest.NewRequest("GET", "/api/v1/fleet/software/outdated?name=openssl&version=1.1.1k", nil))
+	require.NoError(t, err)
+
+	params := r.(listOutdatedSoftwareHostsRequest)
+	assert.Equal(t, "openssl", params.Name)
+	assert.Equal(t, "1.1.1k", params.Version)
+}
+
+func TestDecodeListHostSoftwareChangesRequest(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/fleet/hosts/{id}/software/changes", func(writer http.ResponseWriter, request *http.Request) {
+		r, err := decodeListHostSoftwareChangesRequest(context.Background(), request)
+		require.NoError(t, err)
+
+		params := r.(listHostSoftwareChangesRequest)
+		assert.Equal(t, uint(7), params.HostID)
+		assert.Equal(t, "openssl", params.ListOptions.MatchQuery)
+		assert.Equal(t, uint(50), params.ListOptions.PerPage)
+	}).Methods("GET")
+
+	router.ServeHTTP(
+		httptest.NewRecorder(),
+		httptest.NewRequest("GET", "/api/v1/fleet/hosts/7/software/changes?query=openssl&per_page=50", nil),
+	)
+}
+
+func TestDecodeHostSBOMRequest(t *testing.T) {
+	var params hostSBOMRequest
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/fleet/hosts/{id}/sbom", func(writer http.ResponseWriter, request *http.Request) {
+		r, err := decodeHostSBOMRequest(context.Background(), request)
+		require.NoError(t, err)
+		params = r.(hostSBOMRequest)
+	}).Methods("GET")
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/fleet/hosts/7/sbom?format=spdx", nil))
+	assert.Equal(t, uint(7), params.HostID)
+	assert.Equal(t, fleet.SBOMFormatSPDX, params.Format)
+
+	// The format defaults to CycloneDX.
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/fleet/hosts/7/sbom", nil))
+	assert.Equal(t, fleet.SBOMFormatCycloneDX, params.Format)
+}
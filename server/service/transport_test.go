@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -111,3 +112,53 @@ func TestListOptionsFromRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestHostListOptionsFromRequest(t *testing.T) {
+	var hostListOptionsTests = []struct {
+		url             string
+		hostListOptions fleet.HostListOptions
+		shouldErr       bool
+	}{
+		{
+			url:             "/foo",
+			hostListOptions: fleet.HostListOptions{},
+		},
+		{
+			url: "/foo?status=online&team_id=2&label_id=3&platform=ubuntu",
+			hostListOptions: fleet.HostListOptions{
+				StatusFilter:   fleet.StatusOnline,
+				TeamFilter:     ptr.Uint(2),
+				LabelFilter:    ptr.Uint(3),
+				PlatformFilter: "ubuntu",
+			},
+		},
+		{
+			url:       "/foo?status=gone",
+			shouldErr: true,
+		},
+		{
+			url:       "/foo?team_id=foo",
+			shouldErr: true,
+		},
+		{
+			url:       "/foo?label_id=-1",
+			shouldErr: true,
+		},
+	}
+
+	for _, tt := range hostListOptionsTests {
+		t.Run(tt.url, func(t *testing.T) {
+			url, _ := url.Parse(tt.url)
+			req := &http.Request{URL: url}
+			opt, err := hostListOptionsFromRequest(req)
+
+			if tt.shouldErr {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.hostListOptions, opt)
+		})
+	}
+}
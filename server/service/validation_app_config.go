@@ -2,8 +2,12 @@ package service
 
 import (
 	"context"
+	"net/url"
+	"regexp"
+	"strings"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/vulnerabilities"
 	"github.com/pkg/errors"
 )
 
@@ -14,6 +18,11 @@ func (mw validationMiddleware) ModifyAppConfig(ctx context.Context, p fleet.AppC
 	}
 	invalid := &fleet.InvalidArgumentError{}
 	validateSSOSettings(p, existing, invalid)
+	validateSoftwareInstalledWebhookSettings(p, existing, invalid)
+	validateVulnerabilitiesWebhookSettings(p, existing, invalid)
+	validateJiraIntegration(p, existing, invalid)
+	validateVulnerabilityAutomations(p, existing, invalid)
+	validateSoftwareSettings(p, invalid)
 	if invalid.HasErrors() {
 		return nil, invalid
 	}
@@ -59,3 +68,145 @@ func validateSSOSettings(p fleet.AppConfigPayload, existing *fleet.AppConfig, in
 		}
 	}
 }
+
+func validateSoftwareInstalledWebhookSettings(p fleet.AppConfigPayload, existing *fleet.AppConfig, invalid *fleet.InvalidArgumentError) {
+	if p.WebhookSettings == nil || p.WebhookSettings.SoftwareInstalledWebhook == nil {
+		return
+	}
+	settings := p.WebhookSettings.SoftwareInstalledWebhook
+
+	enabled := existing.SoftwareInstalledWebhookEnabled
+	if settings.Enable != nil {
+		enabled = *settings.Enable
+	}
+	destinationURL := existing.SoftwareInstalledWebhookURL
+	if settings.DestinationURL != nil {
+		destinationURL = strings.TrimSpace(*settings.DestinationURL)
+	}
+	validateWebhookDestinationURL(enabled, destinationURL, invalid)
+
+	if settings.Watchlist != nil {
+		for _, pattern := range settings.Watchlist.NamePatterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				invalid.Append("name_patterns", "invalid regular expression "+pattern)
+			}
+		}
+		for _, cpe := range settings.Watchlist.CPEs {
+			if err := vulnerabilities.ValidateCPE(cpe); err != nil {
+				invalid.Append("cpes", "invalid CPE 2.3 application name "+cpe)
+			}
+		}
+	}
+}
+
+// validateWebhookDestinationURL checks that an enabled webhook has a
+// destination, and that the destination is an http or https URL.
+func validateWebhookDestinationURL(enabled bool, destinationURL string, invalid *fleet.InvalidArgumentError) {
+	if enabled && destinationURL == "" {
+		invalid.Append("destination_url", "required when the webhook is enabled")
+	}
+	if destinationURL != "" {
+		if u, err := url.Parse(destinationURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid.Append("destination_url", "must be an http or https URL")
+		}
+	}
+}
+
+func validateVulnerabilitiesWebhookSettings(p fleet.AppConfigPayload, existing *fleet.AppConfig, invalid *fleet.InvalidArgumentError) {
+	if p.WebhookSettings == nil || p.WebhookSettings.VulnerabilitiesWebhook == nil {
+		return
+	}
+	settings := p.WebhookSettings.VulnerabilitiesWebhook
+
+	enabled := existing.VulnerabilitiesWebhookEnabled
+	if settings.Enable != nil {
+		enabled = *settings.Enable
+	}
+	destinationURL := existing.VulnerabilitiesWebhookURL
+	if settings.DestinationURL != nil {
+		destinationURL = strings.TrimSpace(*settings.DestinationURL)
+	}
+	validateWebhookDestinationURL(enabled, destinationURL, invalid)
+
+	if settings.HostBatchSize != nil && *settings.HostBatchSize < 0 {
+		invalid.Append("host_batch_size", "must not be negative")
+	}
+	if settings.MinCVSSScore != nil && (*settings.MinCVSSScore < 0 || *settings.MinCVSSScore > 10) {
+		invalid.Append("min_cvss_score", "must be between 0 and 10")
+	}
+}
+
+func validateJiraIntegration(p fleet.AppConfigPayload, existing *fleet.AppConfig, invalid *fleet.InvalidArgumentError) {
+	if p.Integrations == nil || p.Integrations.Jira == nil {
+		return
+	}
+	settings := p.Integrations.Jira
+
+	enabled := existing.JiraIntegrationEnabled
+	if settings.Enable != nil {
+		enabled = *settings.Enable
+	}
+	jiraURL := existing.JiraURL
+	if settings.URL != nil {
+		jiraURL = strings.TrimSpace(*settings.URL)
+	}
+	if enabled && jiraURL == "" {
+		invalid.Append("url", "required when the Jira integration is enabled")
+	}
+	if jiraURL != "" {
+		if u, err := url.Parse(jiraURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid.Append("url", "must be an http or https URL")
+		}
+	}
+	if !enabled {
+		return
+	}
+
+	projectKey := existing.JiraProjectKey
+	if settings.ProjectKey != nil {
+		projectKey = strings.TrimSpace(*settings.ProjectKey)
+	}
+	if projectKey == "" {
+		invalid.Append("project_key", "required when the Jira integration is enabled")
+	}
+	apiToken := existing.JiraAPIToken
+	if settings.APIToken != nil && *settings.APIToken != "********" {
+		apiToken = *settings.APIToken
+	}
+	if apiToken == "" {
+		invalid.Append("api_token", "required when the Jira integration is enabled")
+	}
+}
+
+// validateVulnerabilityAutomations checks that the vulnerabilities are
+// automated by either the vulnerabilities webhook or the Jira integration,
+// not both.
+func validateVulnerabilityAutomations(p fleet.AppConfigPayload, existing *fleet.AppConfig, invalid *fleet.InvalidArgumentError) {
+	webhookEnabled := existing.VulnerabilitiesWebhookEnabled
+	if p.WebhookSettings != nil && p.WebhookSettings.VulnerabilitiesWebhook != nil && p.WebhookSettings.VulnerabilitiesWebhook.Enable != nil {
+		webhookEnabled = *p.WebhookSettings.VulnerabilitiesWebhook.Enable
+	}
+	jiraEnabled := existing.JiraIntegrationEnabled
+	if p.Integrations != nil && p.Integrations.Jira != nil && p.Integrations.Jira.Enable != nil {
+		jiraEnabled = *p.Integrations.Jira.Enable
+	}
+	if webhookEnabled && jiraEnabled {
+		invalid.Append("enable_jira_integration", "cannot be enabled along with the vulnerabilities webhook")
+	}
+}
+
+func validateSoftwareSettings(p fleet.AppConfigPayload, invalid *fleet.InvalidArgumentError) {
+	if p.SoftwareSettings == nil || p.SoftwareSettings.NormalizationRules == nil {
+		return
+	}
+	for _, rule := range *p.SoftwareSettings.NormalizationRules {
+		if rule.Pattern == "" {
+			invalid.Append("pattern", "required")
+		} else if _, err := rule.Compile(); err != nil {
+			invalid.Append("pattern", "invalid regular expression "+rule.Pattern)
+		}
+		if strings.TrimSpace(rule.CanonicalName) == "" {
+			invalid.Append("canonical_name", "required")
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -70,3 +71,181 @@ func appConfigPayloadFromAppConfig(config *fleet.AppConfig) *fleet.AppConfigPayl
 		},
 	}
 }
+
+func TestSoftwareInstalledWebhookSettings(t *testing.T) {
+	payload := func(settings fleet.SoftwareInstalledWebhookSettings) fleet.AppConfigPayload {
+		return fleet.AppConfigPayload{
+			WebhookSettings: &fleet.WebhookSettings{SoftwareInstalledWebhook: &settings},
+		}
+	}
+
+	invalid := &fleet.InvalidArgumentError{}
+	validateSoftwareInstalledWebhookSettings(payload(fleet.SoftwareInstalledWebhookSettings{
+		Enable:         ptr.Bool(true),
+		DestinationURL: ptr.String("https://example.com/webhook"),
+		Watchlist: &fleet.SoftwareWatchlist{
+			NamePatterns: []string{"^bittorrent"},
+			CPEs:         []string{"cpe:2.3:a:*:utorrent:*:*:*:*:*:*:*:*"},
+		},
+	}), &fleet.AppConfig{}, invalid)
+	assert.False(t, invalid.HasErrors())
+
+	invalid = &fleet.InvalidArgumentError{}
+	validateSoftwareInstalledWebhookSettings(payload(fleet.SoftwareInstalledWebhookSettings{
+		Enable: ptr.Bool(true),
+	}), &fleet.AppConfig{}, invalid)
+	require.True(t, invalid.HasErrors())
+	assert.Contains(t, invalid.Error(), "destination_url")
+
+	// The destination URL may be already set.
+	invalid = &fleet.InvalidArgumentError{}
+	validateSoftwareInstalledWebhookSettings(payload(fleet.SoftwareInstalledWebhookSettings{
+		Enable: ptr.Bool(true),
+	}), &fleet.AppConfig{SoftwareInstalledWebhookURL: "https://example.com/webhook"}, invalid)
+	assert.False(t, invalid.HasErrors())
+
+	invalid = &fleet.InvalidArgumentError{}
+	validateSoftwareInstalledWebhookSettings(payload(fleet.SoftwareInstalledWebhookSettings{
+		DestinationURL: ptr.String("example.com"),
+		Watchlist: &fleet.SoftwareWatchlist{
+			NamePatterns: []string{"("},
+			CPEs:         []string{"utorrent"},
+		},
+	}), &fleet.AppConfig{}, invalid)
+	var names []string
+	for _, i := range invalid.Invalid() {
+		names = append(names, i["name"])
+	}
+	assert.ElementsMatch(t, []string{"destination_url", "name_patterns", "cpes"}, names)
+}
+
+func TestVulnerabilitiesWebhookSettings(t *testing.T) {
+	payload := func(settings fleet.VulnerabilitiesWebhookSettings) fleet.AppConfigPayload {
+		return fleet.AppConfigPayload{
+			WebhookSettings: &fleet.WebhookSettings{VulnerabilitiesWebhook: &settings},
+		}
+	}
+
+	invalid := &fleet.InvalidArgumentError{}
+	validateVulnerabilitiesWebhookSettings(payload(fleet.VulnerabilitiesWebhookSettings{
+		Enable:         ptr.Bool(true),
+		DestinationURL: ptr.String("https://example.com/webhook"),
+		HostBatchSize:  ptr.Int(100),
+		MinCVSSScore:   ptr.Float64(7),
+	}), &fleet.AppConfig{}, invalid)
+	assert.False(t, invalid.HasErrors())
+
+	// The destination URL may be already set.
+	invalid = &fleet.InvalidArgumentError{}
+	validateVulnerabilitiesWebhookSettings(payload(fleet.VulnerabilitiesWebhookSettings{
+		Enable: ptr.Bool(true),
+	}), &fleet.AppConfig{VulnerabilitiesWebhookURL: "https://example.com/webhook"}, invalid)
+	assert.False(t, invalid.HasErrors())
+
+	invalid = &fleet.InvalidArgumentError{}
+	validateVulnerabilitiesWebhookSettings(payload(fleet.VulnerabilitiesWebhookSettings{
+		Enable:        ptr.Bool(true),
+		HostBatchSize: ptr.Int(-1),
+		MinCVSSScore:  ptr.Float64(11),
+	}), &fleet.AppConfig{}, invalid)
+	var names []string
+	for _, i := range invalid.Invalid() {
+		names = append(names, i["name"])
+	}
+	assert.ElementsMatch(t, []string{"destination_url", "host_batch_size", "min_cvss_score"}, names)
+}
+
+func TestJiraIntegration(t *testing.T) {
+	payload := func(settings fleet.JiraIntegration) fleet.AppConfigPayload {
+		return fleet.AppConfigPayload{
+			Integrations: &fleet.Integrations{Jira: &settings},
+		}
+	}
+
+	invalid := &fleet.InvalidArgumentError{}
+	validateJiraIntegration(payload(fleet.JiraIntegration{
+		Enable:     ptr.Bool(true),
+		URL:        ptr.String("https://example.atlassian.net"),
+		Username:   ptr.String("fleet@example.com"),
+		APIToken:   ptr.String("token"),
+		ProjectKey: ptr.String("SEC"),
+	}), &fleet.AppConfig{}, invalid)
+	assert.False(t, invalid.HasErrors())
+
+	// The masked API token keeps the existing one.
+	invalid = &fleet.InvalidArgumentError{}
+	validateJiraIntegration(payload(fleet.JiraIntegration{
+		Enable:   ptr.Bool(true),
+		APIToken: ptr.String("********"),
+	}), &fleet.AppConfig{JiraURL: "https://example.atlassian.net", JiraAPIToken: "token", JiraProjectKey: "SEC"}, invalid)
+	assert.False(t, invalid.HasErrors())
+
+	invalid = &fleet.InvalidArgumentError{}
+	validateJiraIntegration(payload(fleet.JiraIntegration{
+		Enable:   ptr.Bool(true),
+		APIToken: ptr.String("********"),
+	}), &fleet.AppConfig{}, invalid)
+	var names []string
+	for _, i := range invalid.Invalid() {
+		names = append(names, i["name"])
+	}
+	assert.ElementsMatch(t, []string{"url", "project_key", "api_token"}, names)
+
+	invalid = &fleet.InvalidArgumentError{}
+	validateJiraIntegration(payload(fleet.JiraIntegration{
+		URL: ptr.String("example.atlassian.net"),
+	}), &fleet.AppConfig{}, invalid)
+	require.True(t, invalid.HasErrors())
+	assert.Contains(t, invalid.Error(), "url")
+}
+
+func TestVulnerabilityAutomations(t *testing.T) {
+	invalid := &fleet.InvalidArgumentError{}
+	validateVulnerabilityAutomations(fleet.AppConfigPayload{
+		Integrations: &fleet.Integrations{Jira: &fleet.JiraIntegration{Enable: ptr.Bool(true)}},
+	}, &fleet.AppConfig{VulnerabilitiesWebhookEnabled: true}, invalid)
+	require.True(t, invalid.HasErrors())
+	assert.Contains(t, invalid.Error(), "enable_jira_integration")
+
+	invalid = &fleet.InvalidArgumentError{}
+	validateVulnerabilityAutomations(fleet.AppConfigPayload{
+		WebhookSettings: &fleet.WebhookSettings{VulnerabilitiesWebhook: &fleet.VulnerabilitiesWebhookSettings{Enable: ptr.Bool(true)}},
+	}, &fleet.AppConfig{JiraIntegrationEnabled: true}, invalid)
+	assert.True(t, invalid.HasErrors())
+
+	// Switching from one automation to the other is allowed.
+	invalid = &fleet.InvalidArgumentError{}
+	validateVulnerabilityAutomations(fleet.AppConfigPayload{
+		WebhookSettings: &fleet.WebhookSettings{VulnerabilitiesWebhook: &fleet.VulnerabilitiesWebhookSettings{Enable: ptr.Bool(false)}},
+		Integrations:    &fleet.Integrations{Jira: &fleet.JiraIntegration{Enable: ptr.Bool(true)}},
+	}, &fleet.AppConfig{VulnerabilitiesWebhookEnabled: true}, invalid)
+	assert.False(t, invalid.HasErrors())
+}
+
+func TestSoftwareSettings(t *testing.T) {
+	payload := func(rules ...fleet.SoftwareNormalizationRule) fleet.AppConfigPayload {
+		normalizationRules := fleet.SoftwareNormalizationRules(rules)
+		return fleet.AppConfigPayload{
+			SoftwareSettings: &fleet.SoftwareSettings{NormalizationRules: &normalizationRules},
+		}
+	}
+
+	invalid := &fleet.InvalidArgumentError{}
+	validateSoftwareSettings(payload(fleet.SoftwareNormalizationRule{Pattern: "msedge", CanonicalName: "Microsoft Edge"}), invalid)
+	assert.False(t, invalid.HasErrors())
+
+	invalid = &fleet.InvalidArgumentError{}
+	validateSoftwareSettings(payload(), invalid)
+	assert.False(t, invalid.HasErrors())
+
+	invalid = &fleet.InvalidArgumentError{}
+	validateSoftwareSettings(payload(
+		fleet.SoftwareNormalizationRule{Pattern: "(", CanonicalName: "foo"},
+		fleet.SoftwareNormalizationRule{Pattern: "", CanonicalName: " "},
+	), invalid)
+	var names []string
+	for _, i := range invalid.Invalid() {
+		names = append(names, i["name"])
+	}
+	assert.Equal(t, []string{"pattern", "pattern", "canonical_name"}, names)
+}
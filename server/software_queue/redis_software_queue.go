@@ -0,0 +1,95 @@
+// Package software_queue implements a queue buffering the software reported
+// by the hosts in Redis.
+//
+// The software of every host is stored as a field of a single hash, keyed
+// by host ID, so that software reported again by a host before it is
+// dequeued replaces the previous one and a host is saved at most once per
+// batch.
+package software_queue
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/gomodule/redigo/redis"
+	"github.com/mna/redisc"
+	"github.com/pkg/errors"
+)
+
+const queueKey = "hostsoftware:queue"
+
+// dequeueScript atomically removes and returns about ARGV[1] fields of the
+// hash.
+var dequeueScript = redis.NewScript(1, `
+redis.replicate_commands()
+local res = redis.call('HSCAN', KEYS[1], 0, 'COUNT', ARGV[1])
+local fields = res[2]
+for i = 1, #fields, 2 do
+	redis.call('HDEL', KEYS[1], fields[i])
+end
+return fields
+`)
+
+// queuedHost is the data of a host buffered in the queue.
+type queuedHost struct {
+	TeamID   *uint            `json:"team_id"`
+	Software []fleet.Software `json:"software"`
+}
+
+type redisSoftwareQueue struct {
+	// connection pool
+	pool *redisc.Cluster
+}
+
+// NewRedisSoftwareQueue creates a new Redis implementation of the
+// HostSoftwareQueue interface using the provided Redis connection pool.
+func NewRedisSoftwareQueue(pool *redisc.Cluster) *redisSoftwareQueue {
+	return &redisSoftwareQueue{pool: pool}
+}
+
+func (r *redisSoftwareQueue) Enqueue(host *fleet.Host) error {
+	b, err := json.Marshal(queuedHost{TeamID: host.TeamID, Software: host.HostSoftware.Software})
+	if err != nil {
+		return errors.Wrap(err, "marshal host software")
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("HSET", queueKey, host.ID, b); err != nil {
+		return errors.Wrap(err, "hset host software")
+	}
+	return nil
+}
+
+func (r *redisSoftwareQueue) Dequeue(n int) ([]*fleet.Host, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	fields, err := redis.ByteSlices(dequeueScript.Do(conn, queueKey, n))
+	if err != nil {
+		return nil, errors.Wrap(err, "dequeue host software")
+	}
+
+	hosts := make([]*fleet.Host, 0, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		id, err := strconv.ParseUint(string(fields[i]), 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse queued host id")
+		}
+		var queued queuedHost
+		if err := json.Unmarshal(fields[i+1], &queued); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal software of host %d", id)
+		}
+		hosts = append(hosts, &fleet.Host{
+			ID:     uint(id),
+			TeamID: queued.TeamID,
+			HostSoftware: fleet.HostSoftware{
+				Software: queued.Software,
+				Modified: true,
+			},
+		})
+	}
+	return hosts, nil
+}
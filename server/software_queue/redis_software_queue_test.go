@@ -0,0 +1,71 @@
+package software_queue
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/fleetdm/fleet/v4/server/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRedisSoftwareQueue(t *testing.T) (store *redisSoftwareQueue, teardown func()) {
+	var (
+		addr     = "127.0.0.1:6379"
+		password = ""
+		database = 0
+		useTLS   = false
+	)
+
+	pool, err := pubsub.NewRedisPool(addr, password, database, useTLS)
+	require.NoError(t, err)
+	store = NewRedisSoftwareQueue(pool)
+
+	_, err = store.pool.Get().Do("PING")
+	require.NoError(t, err)
+
+	teardown = func() {
+		store.pool.Get().Do("FLUSHDB")
+		store.pool.Close()
+	}
+
+	return store, teardown
+}
+
+func TestRedisSoftwareQueue(t *testing.T) {
+	if _, ok := os.LookupEnv("REDIS_TEST"); !ok {
+		t.Skip("Redis tests not requested. Skipping.")
+	}
+
+	queue, teardown := setupRedisSoftwareQueue(t)
+	defer teardown()
+
+	hosts, err := queue.Dequeue(10)
+	require.NoError(t, err)
+	assert.Empty(t, hosts)
+
+	software := func(version string) fleet.HostSoftware {
+		return fleet.HostSoftware{
+			Modified: true,
+			Software: []fleet.Software{{Name: "curl", Version: version, Source: "deb_packages"}},
+		}
+	}
+	require.NoError(t, queue.Enqueue(&fleet.Host{ID: 1, HostSoftware: software("7.68.0")}))
+	require.NoError(t, queue.Enqueue(&fleet.Host{ID: 2, TeamID: ptr.Uint(3), HostSoftware: software("7.68.0")}))
+	// Replaces the software of host 1 not yet dequeued.
+	require.NoError(t, queue.Enqueue(&fleet.Host{ID: 1, HostSoftware: software("7.74.0")}))
+
+	hosts, err = queue.Dequeue(10)
+	require.NoError(t, err)
+	require.Len(t, hosts, 2)
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].ID < hosts[j].ID })
+	assert.Equal(t, &fleet.Host{ID: 1, HostSoftware: software("7.74.0")}, hosts[0])
+	assert.Equal(t, &fleet.Host{ID: 2, TeamID: ptr.Uint(3), HostSoftware: software("7.68.0")}, hosts[1])
+
+	hosts, err = queue.Dequeue(10)
+	require.NoError(t, err)
+	assert.Empty(t, hosts)
+}
@@ -0,0 +1,130 @@
+package vulnerabilities
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/pkg/errors"
+)
+
+// cpeAny is the CPE value matching any value.
+const cpeAny = "*"
+
+// cpe is a CPE 2.3 name for an application, with the components used for
+// matching. Values are kept in their formatted string form, escaped.
+type cpe struct {
+	Vendor  string
+	Product string
+	Version string
+}
+
+// String returns the CPE 2.3 formatted string of the name.
+func (c cpe) String() string {
+	return "cpe:2.3:a:" + c.Vendor + ":" + c.Product + ":" + c.Version + ":*:*:*:*:*:*:*"
+}
+
+// parseCPE parses a CPE 2.3 formatted string. Only application names (part
+// "a") are supported.
+func parseCPE(s string) (cpe, error) {
+	var parts []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			current.WriteRune(r)
+			escaped = true
+		case r == ':':
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	parts = append(parts, current.String())
+
+	if len(parts) != 13 || parts[0] != "cpe" || parts[1] != "2.3" {
+		return cpe{}, errors.Errorf("invalid cpe %q", s)
+	}
+	if parts[2] != "a" {
+		return cpe{}, errors.Errorf("unsupported cpe part %q", parts[2])
+	}
+	return cpe{Vendor: parts[3], Product: parts[4], Version: parts[5]}, nil
+}
+
+// escapeCPEValue escapes the characters that must be quoted in a CPE 2.3
+// formatted string value.
+func escapeCPEValue(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.' {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// CPEFromSoftware returns the CPE 2.3 name of the software, or an empty
+// string if it can't be translated. The translation is best effort: the
+// vendor is never known and is left as any value, the product is derived
+// from the software name and the version from the upstream part of the
+// software version.
+func CPEFromSoftware(s fleet.Software) string {
+	product := strings.ToLower(strings.TrimSpace(s.Name))
+	if s.Source == "apps" {
+		product = strings.TrimSuffix(product, ".app")
+	}
+	product = strings.Join(strings.Fields(product), "_")
+
+	version := strings.TrimSpace(s.Version)
+	if s.Source == "deb_packages" || s.Source == "rpm_packages" {
+		// Drop the epoch and the distribution revision, CVE data refers to
+		// the upstream versions.
+		if i := strings.Index(version, ":"); i >= 0 {
+			version = version[i+1:]
+		}
+		if i := strings.LastIndex(version, "-"); i > 0 {
+			version = version[:i]
+		}
+	}
+	version = strings.ToLower(version)
+
+	if product == "" || version == "" {
+		return ""
+	}
+	return cpe{
+		Vendor:  cpeAny,
+		Product: escapeCPEValue(product),
+		Version: escapeCPEValue(version),
+	}.String()
+}
+
+// ValidateCPE returns an error if the string is not a CPE 2.3 formatted
+// application name.
+func ValidateCPE(s string) error {
+	_, err := parseCPE(s)
+	return err
+}
+
+// MatchCPE returns whether the CPE name matches the pattern, a CPE 2.3
+// formatted application name where the vendor, product and version may be
+// the any value. Both are compared case insensitively.
+func MatchCPE(pattern, name string) (bool, error) {
+	p, err := parseCPE(strings.ToLower(pattern))
+	if err != nil {
+		return false, err
+	}
+	c, err := parseCPE(strings.ToLower(name))
+	if err != nil {
+		return false, err
+	}
+	matches := func(p, v string) bool {
+		return p == cpeAny || v == cpeAny || p == v
+	}
+	return matches(p.Vendor, c.Vendor) && matches(p.Product, c.Product) && matches(p.Version, c.Version), nil
+}
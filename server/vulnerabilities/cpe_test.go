@@ -0,0 +1,83 @@
+package vulnerabilities
+
+import (
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPEFromSoftware(t *testing.T) {
+	testCases := []struct {
+		software fleet.Software
+		cpe      string
+	}{
+		{
+			software: fleet.Software{Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+			cpe:      "cpe:2.3:a:*:openssl:1.1.1f:*:*:*:*:*:*:*",
+		},
+		{
+			software: fleet.Software{Name: "sudo", Version: "1:1.8.31-1ubuntu1.2", Source: "deb_packages"},
+			cpe:      "cpe:2.3:a:*:sudo:1.8.31:*:*:*:*:*:*:*",
+		},
+		{
+			software: fleet.Software{Name: "Google Chrome.app", Version: "92.0.4515.107", Source: "apps"},
+			cpe:      "cpe:2.3:a:*:google_chrome:92.0.4515.107:*:*:*:*:*:*:*",
+		},
+		{
+			software: fleet.Software{Name: "requests", Version: "2.25.1+dfsg", Source: "python_packages"},
+			cpe:      `cpe:2.3:a:*:requests:2.25.1\+dfsg:*:*:*:*:*:*:*`,
+		},
+		{
+			software: fleet.Software{Name: "foo", Version: "", Source: "apps"},
+			cpe:      "",
+		},
+		{
+			software: fleet.Software{Name: " ", Version: "1.0", Source: "apps"},
+			cpe:      "",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.software.Name+" "+tt.software.Version, func(t *testing.T) {
+			assert.Equal(t, tt.cpe, CPEFromSoftware(tt.software))
+		})
+	}
+}
+
+func TestParseCPE(t *testing.T) {
+	c, err := parseCPE(`cpe:2.3:a:haxx:curl\:lib:7.68.0:*:*:*:*:*:*:*`)
+	require.NoError(t, err)
+	assert.Equal(t, cpe{Vendor: "haxx", Product: `curl\:lib`, Version: "7.68.0"}, c)
+	assert.Equal(t, `cpe:2.3:a:haxx:curl\:lib:7.68.0:*:*:*:*:*:*:*`, c.String())
+
+	_, err = parseCPE("cpe:2.3:o:canonical:ubuntu_linux:20.04:*:*:*:lts:*:*:*")
+	assert.Error(t, err)
+	_, err = parseCPE("cpe:/a:haxx:curl:7.68.0")
+	assert.Error(t, err)
+}
+
+func TestMatchCPE(t *testing.T) {
+	name := "cpe:2.3:a:*:google_chrome:91.0.4472.124:*:*:*:*:*:*:*"
+	testCases := []struct {
+		pattern string
+		matches bool
+	}{
+		{"cpe:2.3:a:google:google_chrome:*:*:*:*:*:*:*:*", true},
+		{"cpe:2.3:a:*:GOOGLE_CHROME:91.0.4472.124:*:*:*:*:*:*:*", true},
+		{"cpe:2.3:a:*:google_chrome:92.0.4515.107:*:*:*:*:*:*:*", false},
+		{"cpe:2.3:a:*:chromium:*:*:*:*:*:*:*:*", false},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.pattern, func(t *testing.T) {
+			ok, err := MatchCPE(tt.pattern, name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.matches, ok)
+		})
+	}
+
+	_, err := MatchCPE("google_chrome", name)
+	assert.Error(t, err)
+	assert.Error(t, ValidateCPE("cpe:2.3:o:canonical:ubuntu_linux:20.04:*:*:*:lts:*:*:*"))
+	assert.NoError(t, ValidateCPE("cpe:2.3:a:*:google_chrome:*:*:*:*:*:*:*:*"))
+}
@@ -0,0 +1,133 @@
+package vulnerabilities
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultEPSSFeedURL is the location of the current EPSS scores.
+	DefaultEPSSFeedURL = "https://epss.cyentia.com/epss_scores-current.csv.gz"
+
+	epssFileName = "epss_scores-current.csv.gz"
+)
+
+// SyncEPSSData downloads the current EPSS scores to the directory.
+func SyncEPSSData(ctx context.Context, client *http.Client, url, dir string) error {
+	if url == "" {
+		url = DefaultEPSSFeedURL
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "create epss directory")
+	}
+
+	b, err := download(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	if _, err := parseEPSSScores(b); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, epssFileName), b, nil); err != nil {
+		return errors.Wrap(err, "write epss scores")
+	}
+	return nil
+}
+
+// LoadEPSSScores loads the EPSS probabilities stored in the directory by
+// SyncEPSSData, keyed by CVE. No scores are returned if they weren't
+// downloaded.
+func LoadEPSSScores(dir string) (map[string]float64, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, epssFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read epss scores")
+	}
+	return parseEPSSScores(b)
+}
+
+// parseEPSSScores parses the gzip compressed EPSS scores CSV, which has a
+// cve, epss and percentile header and may start with a comment line.
+func parseEPSSScores(b []byte) (map[string]float64, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "decompress epss scores")
+	}
+	defer gz.Close()
+
+	r := csv.NewReader(gz)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	scores := make(map[string]float64)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "parse epss scores")
+		}
+		if len(record) < 2 || record[0] == "cve" {
+			continue
+		}
+		score, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse epss score of %s", record[0])
+		}
+		scores[record[0]] = score
+	}
+	return scores, nil
+}
+
+// SaveCVEMeta stores the CVSS scores of the CVE database along with the
+// EPSS probabilities and the known exploits, for every CVE with at least
+// one of them.
+func SaveCVEMeta(ds fleet.Datastore, db *CVEDatabase, epss map[string]float64, knownExploits map[string]bool) error {
+	meta := make(map[string]*fleet.CVEMeta, len(db.scores))
+	get := func(cve string) *fleet.CVEMeta {
+		m, ok := meta[cve]
+		if !ok {
+			m = &fleet.CVEMeta{CVE: cve}
+			meta[cve] = m
+		}
+		return m
+	}
+	for cve, score := range db.scores {
+		score := score
+		get(cve).CVSSScore = &score
+	}
+	for cve, probability := range epss {
+		probability := probability
+		get(cve).EPSSProbability = &probability
+	}
+	for cve, known := range knownExploits {
+		if known {
+			get(cve).CISAKnownExploit = true
+		}
+	}
+
+	sorted := make([]fleet.CVEMeta, 0, len(meta))
+	for _, m := range meta {
+		sorted = append(sorted, *m)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CVE < sorted[j].CVE })
+	if err := ds.InsertCVEMeta(sorted); err != nil {
+		return errors.Wrap(err, "insert cve meta")
+	}
+	return nil
+}
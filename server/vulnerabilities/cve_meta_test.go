@@ -0,0 +1,89 @@
+package vulnerabilities
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEPSSScores = `#model_version:v2021.04.14,score_date:2021-08-12T00:00:00+0000
+cve,epss,percentile
+CVE-2021-3449,0.01372,0.71
+CVE-2021-9999,0.00043,0.05
+`
+
+func gzipEPSSScores(t *testing.T, scores string) []byte {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	_, err := gz.Write([]byte(scores))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return b.Bytes()
+}
+
+func TestSyncEPSSData(t *testing.T) {
+	scores := gzipEPSSScores(t, testEPSSScores)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/epss.csv.gz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(scores)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	loaded, err := LoadEPSSScores(dir)
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+
+	require.NoError(t, SyncEPSSData(context.Background(), server.Client(), server.URL+"/epss.csv.gz", dir))
+	loaded, err = LoadEPSSScores(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"CVE-2021-3449": 0.01372, "CVE-2021-9999": 0.00043}, loaded)
+
+	require.Error(t, SyncEPSSData(context.Background(), server.Client(), server.URL+"/missing.csv.gz", dir))
+
+	// Invalid scores are not stored.
+	scores = gzipEPSSScores(t, "cve,epss,percentile\nCVE-2021-3449,high,0.71\n")
+	require.Error(t, SyncEPSSData(context.Background(), server.Client(), server.URL+"/epss.csv.gz", dir))
+	loaded, err = LoadEPSSScores(dir)
+	require.NoError(t, err)
+	assert.Len(t, loaded, 2)
+}
+
+func TestSaveCVEMeta(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "nvdcve-1.1-2021.json.gz"), gzipFeed(t, testFeed), 0644))
+	db, err := LoadCVEDatabase(dir)
+	require.NoError(t, err)
+
+	ds := new(mock.Store)
+	var inserted []fleet.CVEMeta
+	ds.InsertCVEMetaFunc = func(meta []fleet.CVEMeta) error {
+		inserted = meta
+		return nil
+	}
+
+	epss := map[string]float64{"CVE-2021-3449": 0.01372, "CVE-2021-9999": 0.00043}
+	knownExploits := map[string]bool{"CVE-2021-3156": true, "CVE-2021-21985": true}
+	require.NoError(t, SaveCVEMeta(ds, db, epss, knownExploits))
+	assert.Equal(t, []fleet.CVEMeta{
+		{CVE: "CVE-2021-21985", CISAKnownExploit: true},
+		// v2 scores are used when there is no v3 score.
+		{CVE: "CVE-2021-3156", CVSSScore: ptr.Float64(7.2), CISAKnownExploit: true},
+		{CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9), EPSSProbability: ptr.Float64(0.01372)},
+		{CVE: "CVE-2021-9999", EPSSProbability: ptr.Float64(0.00043)},
+	}, inserted)
+}
@@ -0,0 +1,78 @@
+package vulnerabilities
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCISAKEVFeedURL is the location of the CISA Known Exploited
+	// Vulnerabilities catalog.
+	DefaultCISAKEVFeedURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+
+	kevFileName = "known_exploited_vulnerabilities.json"
+)
+
+// kevCatalog is the subset of the CISA Known Exploited Vulnerabilities
+// catalog used to flag vulnerabilities.
+type kevCatalog struct {
+	Vulnerabilities []struct {
+		CVEID string `json:"cveID"`
+	} `json:"vulnerabilities"`
+}
+
+// SyncKEVData downloads the CISA Known Exploited Vulnerabilities catalog
+// to the directory.
+func SyncKEVData(ctx context.Context, client *http.Client, url, dir string) error {
+	if url == "" {
+		url = DefaultCISAKEVFeedURL
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "create kev directory")
+	}
+
+	b, err := download(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	if _, err := parseKEVCatalog(b); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, kevFileName), b, nil); err != nil {
+		return errors.Wrap(err, "write kev catalog")
+	}
+	return nil
+}
+
+// LoadKnownExploits loads the CVEs of the CISA Known Exploited
+// Vulnerabilities catalog stored in the directory by SyncKEVData. No CVEs
+// are returned if the catalog wasn't downloaded.
+func LoadKnownExploits(dir string) (map[string]bool, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, kevFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read kev catalog")
+	}
+	return parseKEVCatalog(b)
+}
+
+func parseKEVCatalog(b []byte) (map[string]bool, error) {
+	var catalog kevCatalog
+	if err := json.Unmarshal(b, &catalog); err != nil {
+		return nil, errors.Wrap(err, "decode kev catalog")
+	}
+	cves := make(map[string]bool, len(catalog.Vulnerabilities))
+	for _, v := range catalog.Vulnerabilities {
+		cves[v.CVEID] = true
+	}
+	return cves, nil
+}
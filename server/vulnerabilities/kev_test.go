@@ -0,0 +1,52 @@
+package vulnerabilities
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKEVCatalog = `{
+  "title": "CISA Catalog of Known Exploited Vulnerabilities",
+  "catalogVersion": "2021.11.03",
+  "count": 2,
+  "vulnerabilities": [
+    {"cveID": "CVE-2021-3156", "vendorProject": "Sudo", "product": "Sudo"},
+    {"cveID": "CVE-2021-21985", "vendorProject": "VMware", "product": "vCenter Server"}
+  ]
+}`
+
+func TestSyncKEVData(t *testing.T) {
+	catalog := testKEVCatalog
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/kev.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(catalog))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	loaded, err := LoadKnownExploits(dir)
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+
+	require.NoError(t, SyncKEVData(context.Background(), server.Client(), server.URL+"/kev.json", dir))
+	loaded, err = LoadKnownExploits(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"CVE-2021-3156": true, "CVE-2021-21985": true}, loaded)
+
+	require.Error(t, SyncKEVData(context.Background(), server.Client(), server.URL+"/missing.json", dir))
+
+	// An invalid catalog is not stored.
+	catalog = "<html></html>"
+	require.Error(t, SyncKEVData(context.Background(), server.Client(), server.URL+"/kev.json", dir))
+	loaded, err = LoadKnownExploits(dir)
+	require.NoError(t, err)
+	assert.Len(t, loaded, 2)
+}
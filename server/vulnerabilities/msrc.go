@@ -0,0 +1,327 @@
+package vulnerabilities
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMSRCFeedURL is the location of the Microsoft Security Response
+	// Center CVRF API, publishing the monthly security updates.
+	DefaultMSRCFeedURL = "https://api.msrc.microsoft.com/cvrf/v2.0/"
+
+	msrcFilePrefix = "msrc-"
+	// msrcVendorFix is the type of the remediations that are security
+	// updates.
+	msrcVendorFix = 2
+)
+
+// msrcUpdates is the list of the monthly security update documents.
+type msrcUpdates struct {
+	Value []struct {
+		ID                 string `json:"ID"`
+		CurrentReleaseDate string `json:"CurrentReleaseDate"`
+	} `json:"value"`
+}
+
+// msrcDocument is the subset of a CVRF document used to find the security
+// updates of the Windows builds.
+type msrcDocument struct {
+	ProductTree struct {
+		FullProductName []struct {
+			ProductID string `json:"ProductID"`
+			Value     string `json:"Value"`
+		} `json:"FullProductName"`
+	} `json:"ProductTree"`
+	Vulnerability []struct {
+		CVE          string `json:"CVE"`
+		Remediations []struct {
+			Description struct {
+				Value string `json:"Value"`
+			} `json:"Description"`
+			Type         int      `json:"Type"`
+			ProductID    []string `json:"ProductID"`
+			FixedBuild   string   `json:"FixedBuild"`
+			Supercedence string   `json:"Supercedence"`
+		} `json:"Remediations"`
+	} `json:"Vulnerability"`
+}
+
+// msrcFix is a security update of a Windows build, stored by SyncMSRCData
+// for each monthly document.
+type msrcFix struct {
+	KBID string `json:"kb_id"`
+	// Build is the build number the update applies to, eg. 19043. Updates
+	// whose build is unknown are only used to follow the supersedence.
+	Build      string   `json:"build,omitempty"`
+	Supersedes []string `json:"supersedes,omitempty"`
+	CVEs       []string `json:"cves,omitempty"`
+}
+
+var (
+	kbNumberRegexp  = regexp.MustCompile(`^\d+$`)
+	kbNumbersRegexp = regexp.MustCompile(`\d{6,}`)
+)
+
+// SyncMSRCData downloads to the directory the security updates of the
+// Windows builds from the monthly documents of the MSRC. Documents not
+// released again since they were last downloaded are not downloaded again.
+// Nothing is downloaded if no host runs Windows.
+func SyncMSRCData(ctx context.Context, client *http.Client, url, dir string, versions []fleet.HostOSVersion) error {
+	windows := false
+	for _, v := range versions {
+		if v.Platform == "windows" {
+			windows = true
+			break
+		}
+	}
+	if !windows {
+		return nil
+	}
+
+	if url == "" {
+		url = DefaultMSRCFeedURL
+	}
+	if !strings.HasSuffix(url, "/") {
+		url += "/"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "create msrc directory")
+	}
+
+	b, err := downloadAccept(ctx, client, url+"updates", "application/json")
+	if err != nil {
+		return err
+	}
+	var updates msrcUpdates
+	if err := json.Unmarshal(b, &updates); err != nil {
+		return errors.Wrap(err, "decode msrc updates")
+	}
+
+	for _, u := range updates.Value {
+		released, err := parseMSRCTime(u.CurrentReleaseDate)
+		if err != nil {
+			return errors.Wrapf(err, "parse release date of msrc document %s", u.ID)
+		}
+		path := filepath.Join(dir, msrcFilePrefix+u.ID+".json")
+		if info, err := os.Stat(path); err == nil && !released.After(info.ModTime()) {
+			continue
+		}
+		if err := syncMSRCDocument(ctx, client, url+"cvrf/"+u.ID, path, released); err != nil {
+			return errors.Wrapf(err, "sync msrc document %s", u.ID)
+		}
+	}
+	return nil
+}
+
+func syncMSRCDocument(ctx context.Context, client *http.Client, url, path string, released time.Time) error {
+	b, err := downloadAccept(ctx, client, url, "application/json")
+	if err != nil {
+		return err
+	}
+	fixes, err := parseMSRCDocument(b)
+	if err != nil {
+		return err
+	}
+	b, err = json.Marshal(fixes)
+	if err != nil {
+		return errors.Wrap(err, "encode msrc fixes")
+	}
+
+	// The modification time is the release date of the document, to know
+	// when it is released again.
+	if err := writeFileAtomic(path, b, &released); err != nil {
+		return errors.Wrap(err, "write msrc fixes")
+	}
+	return nil
+}
+
+func parseMSRCTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02T15:04:05", s)
+}
+
+// parseMSRCDocument returns the security updates of the Windows products of
+// the document, sorted by KB ID and build.
+func parseMSRCDocument(b []byte) ([]msrcFix, error) {
+	var doc msrcDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrap(err, "decode msrc document")
+	}
+
+	windows := make(map[string]bool)
+	for _, p := range doc.ProductTree.FullProductName {
+		if strings.HasPrefix(p.Value, "Windows") {
+			windows[p.ProductID] = true
+		}
+	}
+
+	type fixKey struct{ kbID, build string }
+	cves := make(map[fixKey]map[string]bool)
+	supersedes := make(map[fixKey]map[string]bool)
+	for _, v := range doc.Vulnerability {
+		for _, r := range v.Remediations {
+			if r.Type != msrcVendorFix || !kbNumberRegexp.MatchString(r.Description.Value) {
+				continue
+			}
+			appliesToWindows := false
+			for _, id := range r.ProductID {
+				appliesToWindows = appliesToWindows || windows[id]
+			}
+			if !appliesToWindows {
+				continue
+			}
+
+			key := fixKey{kbID: "KB" + r.Description.Value, build: msrcBuildNumber(r.FixedBuild)}
+			if cves[key] == nil {
+				cves[key] = make(map[string]bool)
+				supersedes[key] = make(map[string]bool)
+			}
+			cves[key][v.CVE] = true
+			for _, n := range kbNumbersRegexp.FindAllString(r.Supercedence, -1) {
+				supersedes[key]["KB"+n] = true
+			}
+		}
+	}
+
+	fixes := make([]msrcFix, 0, len(cves))
+	for key := range cves {
+		fixes = append(fixes, msrcFix{
+			KBID:       key.kbID,
+			Build:      key.build,
+			Supersedes: sortedKeys(supersedes[key]),
+			CVEs:       sortedKeys(cves[key]),
+		})
+	}
+	sort.Slice(fixes, func(i, j int) bool {
+		if fixes[i].KBID != fixes[j].KBID {
+			return fixes[i].KBID < fixes[j].KBID
+		}
+		return fixes[i].Build < fixes[j].Build
+	})
+	return fixes, nil
+}
+
+// msrcBuildNumber returns the build number of a fixed build, eg. 19043 for
+// 10.0.19043.1165, or an empty string if the build is unknown.
+func msrcBuildNumber(fixedBuild string) string {
+	parts := strings.Split(fixedBuild, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MSRCDatabase holds the security updates of the Windows builds.
+type MSRCDatabase struct {
+	// fixes are the updates fixing vulnerabilities, by build number.
+	fixes map[string][]msrcFix
+	// supersedes are the updates superseded by each update.
+	supersedes map[string][]string
+}
+
+// LoadMSRCDatabase loads the security updates stored in the directory by
+// SyncMSRCData. It returns nil if none were downloaded.
+func LoadMSRCDatabase(dir string) (*MSRCDatabase, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, msrcFilePrefix+"*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "list msrc fixes")
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	db := &MSRCDatabase{fixes: make(map[string][]msrcFix), supersedes: make(map[string][]string)}
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "read msrc fixes")
+		}
+		var fixes []msrcFix
+		if err := json.Unmarshal(b, &fixes); err != nil {
+			return nil, errors.Wrapf(err, "decode msrc fixes %s", filepath.Base(path))
+		}
+		for _, f := range fixes {
+			db.supersedes[f.KBID] = append(db.supersedes[f.KBID], f.Supersedes...)
+			if f.Build != "" && len(f.CVEs) > 0 {
+				db.fixes[f.Build] = append(db.fixes[f.Build], f)
+			}
+		}
+	}
+	return db, nil
+}
+
+// Evaluate returns the vulnerabilities of the Windows build fixed by
+// security updates missing on the host, ordered by CVE. An update is applied
+// if it is installed or superseded by an installed update. A CVE fixed by
+// several updates is resolved in the most recent one.
+func (db *MSRCDatabase) Evaluate(build string, installed []string) []fleet.OperatingSystemVulnerability {
+	applied := make(map[string]bool)
+	pending := append([]string(nil), installed...)
+	for len(pending) > 0 {
+		kbID := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if applied[kbID] {
+			continue
+		}
+		applied[kbID] = true
+		pending = append(pending, db.supersedes[kbID]...)
+	}
+
+	patched := make(map[string]bool)
+	missing := make(map[string]string)
+	for _, f := range db.fixes[build] {
+		for _, cve := range f.CVEs {
+			if applied[f.KBID] {
+				patched[cve] = true
+				continue
+			}
+			if current, ok := missing[cve]; !ok || newerKB(f.KBID, current) {
+				missing[cve] = f.KBID
+			}
+		}
+	}
+
+	var vulnerabilities []fleet.OperatingSystemVulnerability
+	for cve, kbID := range missing {
+		if !patched[cve] {
+			vulnerabilities = append(vulnerabilities, fleet.OperatingSystemVulnerability{CVE: cve, ResolvedIn: kbID})
+		}
+	}
+	sort.Slice(vulnerabilities, func(i, j int) bool { return vulnerabilities[i].CVE < vulnerabilities[j].CVE })
+	return vulnerabilities
+}
+
+// newerKB returns whether the update a was published after b, KB numbers
+// being sequential.
+func newerKB(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
@@ -0,0 +1,158 @@
+package vulnerabilities
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMSRCUpdates = `{
+  "value": [
+    {"ID": "2021-Jul", "CurrentReleaseDate": "2021-07-13T07:00:00Z"},
+    {"ID": "2021-Aug", "CurrentReleaseDate": "2021-08-10T07:00:00"}
+  ]
+}`
+
+const testMSRCDocument = `{
+  "DocumentTitle": {"Value": "August 2021 Security Updates"},
+  "ProductTree": {
+    "FullProductName": [
+      {"ProductID": "11568", "Value": "Windows 10 Version 21H1 for x64-based Systems"},
+      {"ProductID": "11569", "Value": "Windows 10 Version 21H1 for 32-bit Systems"},
+      {"ProductID": "10049", "Value": "Windows Server 2008 for x64-based Systems Service Pack 2"},
+      {"ProductID": "11762", "Value": "Microsoft Office 2019 for 64-bit editions"}
+    ]
+  },
+  "Vulnerability": [
+    {
+      "CVE": "CVE-2021-36936",
+      "Remediations": [
+        {"Description": {"Value": "5005033"}, "Type": 2, "ProductID": ["11568", "11569"], "FixedBuild": "10.0.19043.1165", "Supercedence": "5004237"},
+        {"Description": {"Value": "5005090"}, "Type": 2, "ProductID": ["10049"], "Supercedence": "5004305"},
+        {"Description": {"Value": "Release Notes"}, "Type": 2, "ProductID": ["11568"]},
+        {"Description": {"Value": "Security Update Guide"}, "Type": 5, "ProductID": ["11568"]}
+      ]
+    },
+    {
+      "CVE": "CVE-2021-36940",
+      "Remediations": [
+        {"Description": {"Value": "5005033"}, "Type": 2, "ProductID": ["11568"], "FixedBuild": "10.0.19043.1165", "Supercedence": "5004237"}
+      ]
+    },
+    {
+      "CVE": "CVE-2021-36941",
+      "Remediations": [
+        {"Description": {"Value": "5001997"}, "Type": 2, "ProductID": ["11762"], "FixedBuild": "16.0.10376.20021"}
+      ]
+    }
+  ]
+}`
+
+func TestParseMSRCDocument(t *testing.T) {
+	fixes, err := parseMSRCDocument([]byte(testMSRCDocument))
+	require.NoError(t, err)
+	assert.Equal(t, []msrcFix{
+		{KBID: "KB5005033", Build: "19043", Supersedes: []string{"KB5004237"}, CVEs: []string{"CVE-2021-36936", "CVE-2021-36940"}},
+		{KBID: "KB5005090", Supersedes: []string{"KB5004305"}, CVEs: []string{"CVE-2021-36936"}},
+	}, fixes)
+
+	_, err = parseMSRCDocument([]byte("<cvrfdoc/>"))
+	require.Error(t, err)
+}
+
+func TestSyncMSRCData(t *testing.T) {
+	downloads := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads[r.URL.Path]++
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		switch r.URL.Path {
+		case "/msrc/updates":
+			w.Write([]byte(testMSRCUpdates))
+		case "/msrc/cvrf/2021-Jul", "/msrc/cvrf/2021-Aug":
+			w.Write([]byte(testMSRCDocument))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	windows := []fleet.HostOSVersion{{Platform: "windows", OSVersion: "Microsoft Windows 10 Pro 10.0"}}
+
+	// Nothing is downloaded without Windows hosts.
+	require.NoError(t, SyncMSRCData(context.Background(), server.Client(), server.URL+"/msrc", dir, []fleet.HostOSVersion{{Platform: "ubuntu"}}))
+	assert.Empty(t, downloads)
+
+	require.NoError(t, SyncMSRCData(context.Background(), server.Client(), server.URL+"/msrc", dir, windows))
+	b, err := ioutil.ReadFile(filepath.Join(dir, "msrc-2021-Aug.json"))
+	require.NoError(t, err)
+	var fixes []msrcFix
+	require.NoError(t, json.Unmarshal(b, &fixes))
+	assert.Len(t, fixes, 2)
+	assert.FileExists(t, filepath.Join(dir, "msrc-2021-Jul.json"))
+
+	// Documents not released again are not downloaded again.
+	require.NoError(t, SyncMSRCData(context.Background(), server.Client(), server.URL+"/msrc/", dir, windows))
+	assert.Equal(t, 2, downloads["/msrc/updates"])
+	assert.Equal(t, 1, downloads["/msrc/cvrf/2021-Aug"])
+
+	require.Error(t, SyncMSRCData(context.Background(), server.Client(), server.URL+"/missing", t.TempDir(), windows))
+}
+
+func TestMSRCDatabase(t *testing.T) {
+	dir := t.TempDir()
+	db, err := LoadMSRCDatabase(dir)
+	require.NoError(t, err)
+	assert.Nil(t, db)
+
+	writeFixes := func(name string, fixes []msrcFix) {
+		b, err := json.Marshal(fixes)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), b, 0644))
+	}
+	writeFixes("msrc-2021-Jul.json", []msrcFix{
+		{KBID: "KB5004237", Build: "19043", Supersedes: []string{"KB5003690"}, CVEs: []string{"CVE-2021-34527", "CVE-2021-33757"}},
+		{KBID: "KB5004945", Build: "19043", CVEs: []string{"CVE-2021-34527"}},
+	})
+	writeFixes("msrc-2021-Aug.json", []msrcFix{
+		{KBID: "KB5005033", Build: "19043", Supersedes: []string{"KB5004237"}, CVEs: []string{"CVE-2021-36936"}},
+		{KBID: "KB5005030", Build: "17763", Supersedes: []string{"KB5004244"}, CVEs: []string{"CVE-2021-36936"}},
+	})
+
+	db, err = LoadMSRCDatabase(dir)
+	require.NoError(t, err)
+
+	// The August update supersedes the July one.
+	assert.Empty(t, db.Evaluate("19043", []string{"KB5005033"}))
+
+	// The out of band update fixes one of the CVEs of the July update.
+	assert.Equal(t, []fleet.OperatingSystemVulnerability{
+		{CVE: "CVE-2021-33757", ResolvedIn: "KB5004237"},
+		{CVE: "CVE-2021-36936", ResolvedIn: "KB5005033"},
+	}, db.Evaluate("19043", []string{"KB5004945", "KB5003690"}))
+
+	assert.Equal(t, []fleet.OperatingSystemVulnerability{
+		{CVE: "CVE-2021-36936", ResolvedIn: "KB5005030"},
+	}, db.Evaluate("17763", []string{"KB5004244"}))
+
+	assert.Empty(t, db.Evaluate("7601", []string{"KB5004289"}))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "msrc-2021-Sep.json"), []byte("{"), 0644))
+	_, err = LoadMSRCDatabase(dir)
+	require.Error(t, err)
+}
+
+func TestNewerKB(t *testing.T) {
+	assert.True(t, newerKB("KB5005033", "KB5004237"))
+	assert.True(t, newerKB("KB5005033", "KB890830"))
+	assert.False(t, newerKB("KB4601345", "KB5004237"))
+	assert.False(t, newerKB("KB5005033", "KB5005033"))
+}
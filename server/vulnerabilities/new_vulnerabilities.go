@@ -0,0 +1,80 @@
+package vulnerabilities
+
+import (
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/pkg/errors"
+)
+
+// NewVulnerability is a vulnerability newly found in software, with the
+// software and the hosts it affects.
+type NewVulnerability struct {
+	CVE string
+	// CVSSScore is the CVSS base score of the vulnerability, nil if unknown.
+	CVSSScore *float64
+	Software  []fleet.SoftwareVulnerability
+	// Hosts are the hosts with any of the software installed, ordered by ID.
+	Hosts []AffectedHost
+}
+
+// AffectedHost is a host affected by a vulnerability.
+type AffectedHost struct {
+	ID       uint
+	Hostname string
+}
+
+// ListNewVulnerabilities returns the vulnerabilities found in software since
+// the provided time with a CVSS score of at least minCVSSScore, ordered by
+// CVE. Vulnerabilities without a known score are only returned if
+// minCVSSScore is 0, and vulnerabilities of software no longer installed on
+// any host are never returned.
+func ListNewVulnerabilities(ds fleet.Datastore, since time.Time, minCVSSScore float64) ([]NewVulnerability, error) {
+	found, err := ds.ListSoftwareVulnerabilitiesSince(since)
+	if err != nil {
+		return nil, errors.Wrap(err, "list software vulnerabilities")
+	}
+
+	// The software vulnerabilities are ordered by CVE.
+	var vulnerabilities []NewVulnerability
+	for _, v := range found {
+		if !meetsMinCVSSScore(v.CVSSScore, minCVSSScore) {
+			continue
+		}
+		if len(vulnerabilities) == 0 || vulnerabilities[len(vulnerabilities)-1].CVE != v.CVE {
+			vulnerabilities = append(vulnerabilities, NewVulnerability{CVE: v.CVE, CVSSScore: v.CVSSScore})
+		}
+		last := &vulnerabilities[len(vulnerabilities)-1]
+		last.Software = append(last.Software, v)
+	}
+
+	result := vulnerabilities[:0]
+	for _, v := range vulnerabilities {
+		softwareIDs := make([]uint, 0, len(v.Software))
+		for _, s := range v.Software {
+			softwareIDs = append(softwareIDs, s.SoftwareID)
+		}
+		versions, err := ds.ListHostSoftwareVersionsBySoftwareID(softwareIDs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "list hosts affected by %s", v.CVE)
+		}
+		for _, version := range versions {
+			// A host may have several of the affected software installed.
+			if len(v.Hosts) > 0 && v.Hosts[len(v.Hosts)-1].ID == version.HostID {
+				continue
+			}
+			v.Hosts = append(v.Hosts, AffectedHost{ID: version.HostID, Hostname: version.Hostname})
+		}
+		if len(v.Hosts) > 0 {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+func meetsMinCVSSScore(score *float64, min float64) bool {
+	if min <= 0 {
+		return true
+	}
+	return score != nil && *score >= min
+}
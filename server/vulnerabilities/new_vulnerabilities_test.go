@@ -0,0 +1,62 @@
+package vulnerabilities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListNewVulnerabilities(t *testing.T) {
+	ds := new(mock.Store)
+	ds.ListSoftwareVulnerabilitiesSinceFunc = func(since time.Time) ([]fleet.SoftwareVulnerability, error) {
+		return []fleet.SoftwareVulnerability{
+			{SoftwareID: 1, Name: "curl", CVE: "CVE-2021-0001"},
+			{SoftwareID: 2, Name: "openssl", Version: "1.1.1f", CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9)},
+			{SoftwareID: 3, Name: "openssl", Version: "1.1.1g", CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9)},
+			{SoftwareID: 4, Name: "zsh", CVE: "CVE-2021-9999", CVSSScore: ptr.Float64(9.8)},
+		}, nil
+	}
+	ds.ListHostSoftwareVersionsBySoftwareIDFunc = func(softwareIDs []uint) ([]fleet.HostSoftwareVersion, error) {
+		if softwareIDs[0] == 4 {
+			// The software is no longer installed.
+			return nil, nil
+		}
+		return []fleet.HostSoftwareVersion{
+			{HostID: 1, Hostname: "host1", SoftwareID: 2},
+			{HostID: 1, Hostname: "host1", SoftwareID: 3},
+			{HostID: 2, Hostname: "host2", SoftwareID: 2},
+		}, nil
+	}
+
+	vulnerabilities, err := ListNewVulnerabilities(ds, time.Now(), 5)
+	require.NoError(t, err)
+	require.Len(t, vulnerabilities, 1)
+	v := vulnerabilities[0]
+	assert.Equal(t, "CVE-2021-3449", v.CVE)
+	assert.Equal(t, ptr.Float64(5.9), v.CVSSScore)
+	require.Len(t, v.Software, 2)
+	assert.Equal(t, uint(2), v.Software[0].SoftwareID)
+	assert.Equal(t, uint(3), v.Software[1].SoftwareID)
+	assert.Equal(t, []AffectedHost{{ID: 1, Hostname: "host1"}, {ID: 2, Hostname: "host2"}}, v.Hosts)
+
+	// Without a minimum score, the vulnerabilities without a score are
+	// returned too.
+	vulnerabilities, err = ListNewVulnerabilities(ds, time.Now(), 0)
+	require.NoError(t, err)
+	require.Len(t, vulnerabilities, 2)
+	assert.Equal(t, "CVE-2021-0001", vulnerabilities[0].CVE)
+	assert.Equal(t, "CVE-2021-3449", vulnerabilities[1].CVE)
+}
+
+func TestMeetsMinCVSSScore(t *testing.T) {
+	assert.True(t, meetsMinCVSSScore(nil, 0))
+	assert.True(t, meetsMinCVSSScore(ptr.Float64(1), 0))
+	assert.False(t, meetsMinCVSSScore(nil, 7))
+	assert.False(t, meetsMinCVSSScore(ptr.Float64(6.9), 7))
+	assert.True(t, meetsMinCVSSScore(ptr.Float64(7), 7))
+}
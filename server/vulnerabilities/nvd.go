@@ -0,0 +1,198 @@
+package vulnerabilities
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/pkg/errors"
+)
+
+// nvdFeed is the subset of a NVD CVE JSON 1.1 feed used for matching.
+type nvdFeed struct {
+	CVEItems []nvdCVEItem `json:"CVE_Items"`
+}
+
+type nvdCVEItem struct {
+	CVE struct {
+		Meta struct {
+			ID string `json:"ID"`
+		} `json:"CVE_data_meta"`
+	} `json:"cve"`
+	Configurations struct {
+		Nodes []nvdNode `json:"nodes"`
+	} `json:"configurations"`
+	Impact struct {
+		BaseMetricV3 struct {
+			CVSSV3 struct {
+				BaseScore *float64 `json:"baseScore"`
+			} `json:"cvssV3"`
+		} `json:"baseMetricV3"`
+		BaseMetricV2 struct {
+			CVSSV2 struct {
+				BaseScore *float64 `json:"baseScore"`
+			} `json:"cvssV2"`
+		} `json:"baseMetricV2"`
+	} `json:"impact"`
+}
+
+type nvdNode struct {
+	Operator string        `json:"operator"`
+	Children []nvdNode     `json:"children"`
+	CPEMatch []nvdCPEMatch `json:"cpe_match"`
+}
+
+type nvdCPEMatch struct {
+	Vulnerable            bool   `json:"vulnerable"`
+	CPE23URI              string `json:"cpe23Uri"`
+	VersionStartIncluding string `json:"versionStartIncluding"`
+	VersionStartExcluding string `json:"versionStartExcluding"`
+	VersionEndIncluding   string `json:"versionEndIncluding"`
+	VersionEndExcluding   string `json:"versionEndExcluding"`
+}
+
+// cveMatcher matches the CPEs affected by a CVE.
+type cveMatcher struct {
+	CVE   string
+	CPE   cpe
+	Match nvdCPEMatch
+}
+
+// matches returns whether the CPE is affected.
+func (m cveMatcher) matches(c cpe) bool {
+	if m.CPE.Vendor != cpeAny && c.Vendor != cpeAny && m.CPE.Vendor != c.Vendor {
+		return false
+	}
+	if m.CPE.Product != c.Product {
+		return false
+	}
+
+	switch m.CPE.Version {
+	case "-":
+		return false
+	case cpeAny:
+	default:
+		return compareVersions(c.Version, m.CPE.Version) == 0
+	}
+
+	// Without a version range, any version is affected.
+	if v := m.Match.VersionStartIncluding; v != "" && compareVersions(c.Version, v) < 0 {
+		return false
+	}
+	if v := m.Match.VersionStartExcluding; v != "" && compareVersions(c.Version, v) <= 0 {
+		return false
+	}
+	if v := m.Match.VersionEndIncluding; v != "" && compareVersions(c.Version, v) > 0 {
+		return false
+	}
+	if v := m.Match.VersionEndExcluding; v != "" && compareVersions(c.Version, v) >= 0 {
+		return false
+	}
+	return true
+}
+
+func compareVersions(a, b string) int {
+	return fleet.CompareSoftwareVersions("", a, b)
+}
+
+// CVEDatabase indexes the vulnerable CPEs of the NVD CVE feeds by product.
+type CVEDatabase struct {
+	matchers map[string][]cveMatcher
+	// scores are the CVSS base scores of the CVEs, v3 when available.
+	scores map[string]float64
+}
+
+// add indexes the vulnerable CPEs of the feed. The configurations are
+// flattened: a CPE matching any vulnerable CPE of the CVE is affected,
+// regardless of the platform the configuration requires.
+func (db *CVEDatabase) add(feed nvdFeed) {
+	var walk func(cve string, nodes []nvdNode)
+	walk = func(cve string, nodes []nvdNode) {
+		for _, node := range nodes {
+			walk(cve, node.Children)
+			for _, match := range node.CPEMatch {
+				if !match.Vulnerable {
+					continue
+				}
+				c, err := parseCPE(match.CPE23URI)
+				if err != nil {
+					// Operating systems and hardware are not matched.
+					continue
+				}
+				db.matchers[c.Product] = append(db.matchers[c.Product], cveMatcher{CVE: cve, CPE: c, Match: match})
+			}
+		}
+	}
+	for _, item := range feed.CVEItems {
+		walk(item.CVE.Meta.ID, item.Configurations.Nodes)
+
+		if score := item.Impact.BaseMetricV3.CVSSV3.BaseScore; score != nil {
+			db.scores[item.CVE.Meta.ID] = *score
+		} else if score := item.Impact.BaseMetricV2.CVSSV2.BaseScore; score != nil {
+			db.scores[item.CVE.Meta.ID] = *score
+		}
+	}
+}
+
+// LoadCVEDatabase loads the NVD CVE feeds stored in the directory by
+// SyncCVEData.
+func LoadCVEDatabase(dir string) (*CVEDatabase, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, feedPrefix+"*.json.gz"))
+	if err != nil {
+		return nil, errors.Wrap(err, "list cve feeds")
+	}
+	db := &CVEDatabase{
+		matchers: make(map[string][]cveMatcher),
+		scores:   make(map[string]float64),
+	}
+	for _, path := range paths {
+		feed, err := readFeed(path)
+		if err != nil {
+			return nil, err
+		}
+		db.add(feed)
+	}
+	return db, nil
+}
+
+func readFeed(path string) (nvdFeed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nvdFeed{}, errors.Wrap(err, "open cve feed")
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nvdFeed{}, errors.Wrapf(err, "decompress cve feed %s", path)
+	}
+	defer gz.Close()
+
+	var feed nvdFeed
+	if err := json.NewDecoder(gz).Decode(&feed); err != nil {
+		return nvdFeed{}, errors.Wrapf(err, "decode cve feed %s", path)
+	}
+	return feed, nil
+}
+
+// Match returns the sorted IDs of the CVEs affecting the CPE.
+func (db *CVEDatabase) Match(cpeName string) ([]string, error) {
+	c, err := parseCPE(cpeName)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var cves []string
+	for _, m := range db.matchers[c.Product] {
+		if !seen[m.CVE] && m.matches(c) {
+			seen[m.CVE] = true
+			cves = append(cves, m.CVE)
+		}
+	}
+	sort.Strings(cves)
+	return cves, nil
+}
@@ -0,0 +1,122 @@
+package vulnerabilities
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFeed = `{
+  "CVE_Items": [
+    {
+      "cve": {"CVE_data_meta": {"ID": "CVE-2021-3449"}},
+      "configurations": {
+        "nodes": [
+          {
+            "operator": "OR",
+            "cpe_match": [
+              {
+                "vulnerable": true,
+                "cpe23Uri": "cpe:2.3:a:openssl:openssl:*:*:*:*:*:*:*:*",
+                "versionStartIncluding": "1.1.1",
+                "versionEndExcluding": "1.1.1k"
+              }
+            ]
+          }
+        ]
+      },
+      "impact": {
+        "baseMetricV3": {"cvssV3": {"baseScore": 5.9}},
+        "baseMetricV2": {"cvssV2": {"baseScore": 4.3}}
+      }
+    },
+    {
+      "cve": {"CVE_data_meta": {"ID": "CVE-2021-3156"}},
+      "impact": {
+        "baseMetricV2": {"cvssV2": {"baseScore": 7.2}}
+      },
+      "configurations": {
+        "nodes": [
+          {
+            "operator": "AND",
+            "children": [
+              {
+                "operator": "OR",
+                "cpe_match": [
+                  {"vulnerable": true, "cpe23Uri": "cpe:2.3:a:sudo_project:sudo:1.8.31:*:*:*:*:*:*:*"},
+                  {"vulnerable": true, "cpe23Uri": "cpe:2.3:a:sudo_project:sudo:1.9.5:*:*:*:*:*:*:*"}
+                ]
+              },
+              {
+                "operator": "OR",
+                "cpe_match": [
+                  {"vulnerable": false, "cpe23Uri": "cpe:2.3:o:canonical:ubuntu_linux:20.04:*:*:*:lts:*:*:*"}
+                ]
+              }
+            ]
+          }
+        ]
+      }
+    },
+    {
+      "cve": {"CVE_data_meta": {"ID": "CVE-2021-0001"}},
+      "configurations": {
+        "nodes": [
+          {
+            "operator": "OR",
+            "cpe_match": [
+              {"vulnerable": true, "cpe23Uri": "cpe:2.3:a:other:sudo:-:*:*:*:*:*:*:*"},
+              {"vulnerable": false, "cpe23Uri": "cpe:2.3:a:openssl:openssl:1.1.1f:*:*:*:*:*:*:*"}
+            ]
+          }
+        ]
+      }
+    }
+  ]
+}`
+
+func gzipFeed(t *testing.T, feed string) []byte {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	_, err := gz.Write([]byte(feed))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return b.Bytes()
+}
+
+func TestCVEDatabaseMatch(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "nvdcve-1.1-2021.json.gz"), gzipFeed(t, testFeed), 0644))
+
+	db, err := LoadCVEDatabase(dir)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		cpe  string
+		cves []string
+	}{
+		{"cpe:2.3:a:*:openssl:1.1.1f:*:*:*:*:*:*:*", []string{"CVE-2021-3449"}},
+		{"cpe:2.3:a:openssl:openssl:1.1.1:*:*:*:*:*:*:*", []string{"CVE-2021-3449"}},
+		{"cpe:2.3:a:*:openssl:1.1.1k:*:*:*:*:*:*:*", nil},
+		{"cpe:2.3:a:*:openssl:1.1.0l:*:*:*:*:*:*:*", nil},
+		{"cpe:2.3:a:other_vendor:openssl:1.1.1f:*:*:*:*:*:*:*", nil},
+		{"cpe:2.3:a:*:sudo:1.8.31:*:*:*:*:*:*:*", []string{"CVE-2021-3156"}},
+		{"cpe:2.3:a:*:sudo:1.8.32:*:*:*:*:*:*:*", nil},
+		{"cpe:2.3:a:*:ubuntu_linux:20.04:*:*:*:*:*:*:*", nil},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.cpe, func(t *testing.T) {
+			cves, err := db.Match(tt.cpe)
+			require.NoError(t, err)
+			assert.Equal(t, tt.cves, cves)
+		})
+	}
+
+	_, err = db.Match("not a cpe")
+	assert.Error(t, err)
+}
@@ -0,0 +1,489 @@
+package vulnerabilities
+
+import (
+	"compress/bzip2"
+	"encoding/xml"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/fleet/version"
+	"github.com/pkg/errors"
+)
+
+// ovalDocument is the subset of an OVAL definitions document used to
+// evaluate the installed packages.
+type ovalDocument struct {
+	Definitions []ovalDefinition `xml:"definitions>definition"`
+	Tests       ovalElements     `xml:"tests"`
+	Objects     ovalElements     `xml:"objects"`
+	States      ovalElements     `xml:"states"`
+	Variables   []ovalVariable   `xml:"variables>constant_variable"`
+}
+
+type ovalDefinition struct {
+	ID         string `xml:"id,attr"`
+	Class      string `xml:"class,attr"`
+	References []struct {
+		Source string `xml:"source,attr"`
+		RefID  string `xml:"ref_id,attr"`
+	} `xml:"metadata>reference"`
+	// AdvisoryCVEs are the CVEs listed in the advisory of Red Hat
+	// definitions.
+	AdvisoryCVEs []string     `xml:"metadata>advisory>cve"`
+	Criteria     ovalCriteria `xml:"criteria"`
+}
+
+type ovalCriteria struct {
+	Operator   string          `xml:"operator,attr"`
+	Negate     bool            `xml:"negate,attr"`
+	Criteria   []ovalCriteria  `xml:"criteria"`
+	Criterions []ovalCriterion `xml:"criterion"`
+	Extends    []ovalExtend    `xml:"extend_definition"`
+}
+
+type ovalCriterion struct {
+	TestRef string `xml:"test_ref,attr"`
+	Negate  bool   `xml:"negate,attr"`
+}
+
+type ovalExtend struct {
+	DefinitionRef      string `xml:"definition_ref,attr"`
+	Negate             bool   `xml:"negate,attr"`
+	ApplicabilityCheck bool   `xml:"applicability_check,attr"`
+}
+
+type ovalElements struct {
+	Elements []ovalElement `xml:",any"`
+}
+
+// ovalElement is a test, object or state. The element name tells which
+// one, and which of the fields are set.
+type ovalElement struct {
+	XMLName xml.Name
+	ID      string `xml:"id,attr"`
+
+	// Tests.
+	Check          string `xml:"check,attr"`
+	CheckExistence string `xml:"check_existence,attr"`
+	Object         struct {
+		Ref string `xml:"object_ref,attr"`
+	} `xml:"object"`
+	States []struct {
+		Ref string `xml:"state_ref,attr"`
+	} `xml:"state"`
+
+	// Objects.
+	Name ovalValue `xml:"name"`
+
+	// States.
+	EVR            *ovalValue `xml:"evr"`
+	Version        *ovalValue `xml:"version"`
+	SignatureKeyID *ovalValue `xml:"signature_keyid"`
+}
+
+type ovalValue struct {
+	Value     string `xml:",chardata"`
+	Operation string `xml:"operation,attr"`
+	VarRef    string `xml:"var_ref,attr"`
+}
+
+type ovalVariable struct {
+	ID     string   `xml:"id,attr"`
+	Values []string `xml:"value"`
+}
+
+// ovalTest is a package test, with its object and states resolved.
+type ovalTest struct {
+	// Supported is false for the tests that aren't on installed packages,
+	// which can't be evaluated.
+	Supported      bool
+	Check          string
+	CheckExistence string
+	Packages       []string
+	States         []ovalElement
+}
+
+// checksRelease returns whether the test checks the distribution release
+// rather than the version of a package: it has states, none on the
+// version of the package.
+func (t ovalTest) checksRelease() bool {
+	if len(t.States) == 0 {
+		return false
+	}
+	for _, state := range t.States {
+		if state.EVR != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ovalResult is the result of the evaluation of a criteria.
+type ovalResult int
+
+const (
+	ovalFalse ovalResult = iota
+	ovalTrue
+	ovalUnknown
+)
+
+// maxOVALExtendDepth bounds the nesting of extended definitions.
+const maxOVALExtendDepth = 8
+
+// OVALDatabase is the OVAL definitions of a distribution release, indexed
+// by the packages they test.
+type OVALDatabase struct {
+	// source is the software source of the distribution packages.
+	source      string
+	definitions map[string]*ovalDefinition
+	tests       map[string]ovalTest
+	byPackage   map[string][]*ovalDefinition
+}
+
+// LoadOVALDatabase loads the bzip2 compressed OVAL definitions stored at
+// the path by SyncOVALData, for the packages of the source.
+func LoadOVALDatabase(path, source string) (*OVALDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open oval definitions")
+	}
+	defer f.Close()
+
+	db, err := parseOVAL(bzip2.NewReader(f), source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse oval definitions %s", path)
+	}
+	return db, nil
+}
+
+// parseOVAL parses an OVAL definitions document. Only the dpkginfo and
+// rpminfo tests are supported, the other tests are unknown when evaluated.
+func parseOVAL(r io.Reader, source string) (*OVALDatabase, error) {
+	var doc ovalDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "decode xml")
+	}
+
+	variables := make(map[string][]string, len(doc.Variables))
+	for _, v := range doc.Variables {
+		variables[v.ID] = v.Values
+	}
+	objects := make(map[string]ovalElement, len(doc.Objects.Elements))
+	for _, o := range doc.Objects.Elements {
+		objects[o.ID] = o
+	}
+	states := make(map[string]ovalElement, len(doc.States.Elements))
+	for _, s := range doc.States.Elements {
+		states[s.ID] = s
+	}
+
+	db := &OVALDatabase{
+		source:      source,
+		definitions: make(map[string]*ovalDefinition, len(doc.Definitions)),
+		tests:       make(map[string]ovalTest, len(doc.Tests.Elements)),
+		byPackage:   make(map[string][]*ovalDefinition),
+	}
+	for _, t := range doc.Tests.Elements {
+		test := ovalTest{Check: t.Check, CheckExistence: t.CheckExistence}
+		if t.XMLName.Local == "dpkginfo_test" || t.XMLName.Local == "rpminfo_test" {
+			test.Supported = true
+			object := objects[t.Object.Ref]
+			if object.Name.VarRef != "" {
+				test.Packages = variables[object.Name.VarRef]
+			} else if name := strings.TrimSpace(object.Name.Value); name != "" {
+				test.Packages = []string{name}
+			}
+			for _, ref := range t.States {
+				state, ok := states[ref.Ref]
+				if !ok {
+					return nil, errors.Errorf("test %s: unknown state %s", t.ID, ref.Ref)
+				}
+				test.States = append(test.States, state)
+			}
+		}
+		db.tests[t.ID] = test
+	}
+
+	for i := range doc.Definitions {
+		def := &doc.Definitions[i]
+		db.definitions[def.ID] = def
+
+		packages := make(map[string]bool)
+		var walk func(c ovalCriteria)
+		walk = func(c ovalCriteria) {
+			for _, child := range c.Criteria {
+				walk(child)
+			}
+			for _, criterion := range c.Criterions {
+				test := db.tests[criterion.TestRef]
+				if test.checksRelease() {
+					continue
+				}
+				for _, name := range test.Packages {
+					packages[name] = true
+				}
+			}
+		}
+		walk(def.Criteria)
+		for name := range packages {
+			db.byPackage[name] = append(db.byPackage[name], def)
+		}
+	}
+	return db, nil
+}
+
+// Evaluate evaluates the software against the definitions testing its
+// package, and returns the results sorted by CVE. Definitions that can't be
+// evaluated are skipped. When several definitions refer to the same CVE,
+// the software is vulnerable if any of them says so.
+func (db *OVALDatabase) Evaluate(s fleet.Software) []fleet.SoftwareOVALResult {
+	if s.Source != db.source {
+		return nil
+	}
+
+	results := make(map[string]fleet.SoftwareOVALResult)
+	for _, def := range db.byPackage[s.Name] {
+		r := db.evalCriteria(def.Criteria, s, 0)
+		if r == ovalUnknown {
+			continue
+		}
+		for _, cve := range def.cves() {
+			if current, ok := results[cve]; ok && (current.Vulnerable || r != ovalTrue) {
+				continue
+			}
+			results[cve] = fleet.SoftwareOVALResult{CVE: cve, DefinitionID: def.ID, Vulnerable: r == ovalTrue}
+		}
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+	sorted := make([]fleet.SoftwareOVALResult, 0, len(results))
+	for _, r := range results {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CVE < sorted[j].CVE })
+	return sorted
+}
+
+// cves returns the CVEs the definition refers to.
+func (def *ovalDefinition) cves() []string {
+	seen := make(map[string]bool)
+	var cves []string
+	add := func(cve string) {
+		cve = strings.TrimSpace(cve)
+		if strings.HasPrefix(cve, "CVE-") && !seen[cve] {
+			seen[cve] = true
+			cves = append(cves, cve)
+		}
+	}
+	for _, ref := range def.References {
+		if ref.Source == "CVE" {
+			add(ref.RefID)
+		}
+	}
+	for _, cve := range def.AdvisoryCVEs {
+		add(cve)
+	}
+	return cves
+}
+
+func (db *OVALDatabase) evalCriteria(c ovalCriteria, s fleet.Software, depth int) ovalResult {
+	var results []ovalResult
+	for _, child := range c.Criteria {
+		results = append(results, db.evalCriteria(child, s, depth))
+	}
+	for _, criterion := range c.Criterions {
+		test, ok := db.tests[criterion.TestRef]
+		r := ovalUnknown
+		if ok {
+			r = db.evalTest(test, s)
+		}
+		results = append(results, negateOVAL(r, criterion.Negate))
+	}
+	for _, extend := range c.Extends {
+		results = append(results, negateOVAL(db.evalExtend(extend, s, depth), extend.Negate))
+	}
+	if len(results) == 0 {
+		return ovalUnknown
+	}
+
+	var r ovalResult
+	if strings.EqualFold(c.Operator, "OR") {
+		r = ovalFalse
+		for _, result := range results {
+			if result == ovalTrue {
+				r = ovalTrue
+				break
+			}
+			if result == ovalUnknown {
+				r = ovalUnknown
+			}
+		}
+	} else {
+		r = ovalTrue
+		for _, result := range results {
+			if result == ovalFalse {
+				r = ovalFalse
+				break
+			}
+			if result == ovalUnknown {
+				r = ovalUnknown
+			}
+		}
+	}
+	return negateOVAL(r, c.Negate)
+}
+
+// evalExtend evaluates an extended definition. The applicability checks and
+// the inventory definitions check the distribution release, which is known
+// to be the one of the host.
+func (db *OVALDatabase) evalExtend(extend ovalExtend, s fleet.Software, depth int) ovalResult {
+	if extend.ApplicabilityCheck {
+		return ovalTrue
+	}
+	def, ok := db.definitions[extend.DefinitionRef]
+	if !ok || depth >= maxOVALExtendDepth {
+		return ovalUnknown
+	}
+	if def.Class == "inventory" {
+		return ovalTrue
+	}
+	return db.evalCriteria(def.Criteria, s, depth+1)
+}
+
+// evalTest evaluates a package test against the software. Tests on other
+// packages are false, except the ones without states on the version of the
+// package, which check the distribution release and are assumed to be
+// satisfied.
+func (db *OVALDatabase) evalTest(test ovalTest, s fleet.Software) ovalResult {
+	if !test.Supported {
+		return ovalUnknown
+	}
+	noneSatisfy := test.Check == "none satisfy"
+
+	installed := false
+	for _, name := range test.Packages {
+		if name == s.Name {
+			installed = true
+			break
+		}
+	}
+	if !installed {
+		if !test.checksRelease() {
+			return ovalFalse
+		}
+		return negateOVAL(ovalTrue, noneSatisfy)
+	}
+	if test.CheckExistence == "none_exist" {
+		return ovalFalse
+	}
+
+	r := ovalTrue
+	for _, state := range test.States {
+		switch db.evalState(state, s) {
+		case ovalFalse:
+			return negateOVAL(ovalFalse, noneSatisfy)
+		case ovalUnknown:
+			r = ovalUnknown
+		}
+	}
+	return negateOVAL(r, noneSatisfy)
+}
+
+// evalState evaluates the state of the installed package. Signatures can't
+// be checked, packages are assumed to come from the distribution.
+func (db *OVALDatabase) evalState(state ovalElement, s fleet.Software) ovalResult {
+	r := ovalTrue
+	if state.EVR != nil {
+		cmp := db.compareVersions(db.installedVersion(s), strings.TrimSpace(state.EVR.Value))
+		if er := compareOVAL(cmp, state.EVR.Operation); er != ovalTrue {
+			return er
+		}
+	}
+	if state.Version != nil {
+		if state.Version.Operation != "pattern match" {
+			return ovalUnknown
+		}
+		re, err := regexp.Compile(strings.TrimSpace(state.Version.Value))
+		if err != nil {
+			return ovalUnknown
+		}
+		if !re.MatchString(upstreamVersion(s.Version)) {
+			r = ovalFalse
+		}
+	}
+	return r
+}
+
+// installedVersion returns the version of the installed package in the
+// format of the states. The epoch and release of RPM packages are stored
+// apart from their version.
+func (db *OVALDatabase) installedVersion(s fleet.Software) string {
+	if db.source != "rpm_packages" {
+		return s.Version
+	}
+	v := s.Version
+	if s.Epoch != nil {
+		v = strconv.FormatUint(uint64(*s.Epoch), 10) + ":" + v
+	}
+	if s.Release != "" {
+		v += "-" + s.Release
+	}
+	return v
+}
+
+func (db *OVALDatabase) compareVersions(a, b string) int {
+	if db.source == "rpm_packages" {
+		return version.CompareRPM(a, b)
+	}
+	return version.CompareDeb(a, b)
+}
+
+// upstreamVersion returns the version of a package version, without its
+// epoch and release.
+func upstreamVersion(v string) string {
+	_, upstream, _ := version.SplitPackageVersion(v)
+	return upstream
+}
+
+// compareOVAL returns whether the comparison of the installed version with
+// the state version satisfies the operation.
+func compareOVAL(cmp int, operation string) ovalResult {
+	var ok bool
+	switch operation {
+	case "", "equals":
+		ok = cmp == 0
+	case "not equal":
+		ok = cmp != 0
+	case "less than":
+		ok = cmp < 0
+	case "less than or equal":
+		ok = cmp <= 0
+	case "greater than":
+		ok = cmp > 0
+	case "greater than or equal":
+		ok = cmp >= 0
+	default:
+		return ovalUnknown
+	}
+	if ok {
+		return ovalTrue
+	}
+	return ovalFalse
+}
+
+func negateOVAL(r ovalResult, negate bool) ovalResult {
+	if !negate || r == ovalUnknown {
+		return r
+	}
+	if r == ovalTrue {
+		return ovalFalse
+	}
+	return ovalTrue
+}
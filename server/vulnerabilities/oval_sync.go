@@ -0,0 +1,144 @@
+package vulnerabilities
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ubuntuOVALURL is the location of the Ubuntu CVE OVAL definitions of
+	// a release, by codename.
+	ubuntuOVALURL = "https://security-metadata.canonical.com/oval/com.ubuntu.%s.cve.oval.xml.bz2"
+	// rhelOVALURL is the location of the Red Hat Enterprise Linux OVAL
+	// definitions of a major release.
+	rhelOVALURL = "https://www.redhat.com/security/data/oval/v2/RHEL%[1]s/rhel-%[1]s.oval.xml.bz2"
+)
+
+// ubuntuCodenames are the supported Ubuntu releases.
+var ubuntuCodenames = map[string]string{
+	"16.04": "xenial",
+	"18.04": "bionic",
+	"20.04": "focal",
+	"21.04": "hirsute",
+}
+
+// rhelReleases are the supported major releases of Red Hat Enterprise
+// Linux, and of CentOS which follows it.
+var rhelReleases = map[string]bool{
+	"7": true,
+	"8": true,
+}
+
+var (
+	ubuntuReleaseRegexp = regexp.MustCompile(`\d+\.\d+`)
+	rhelReleaseRegexp   = regexp.MustCompile(`\d+`)
+)
+
+// ovalPlatform is a distribution release with OVAL definitions.
+type ovalPlatform struct {
+	// FileName is the name of the definitions file.
+	FileName string
+	URL      string
+	// Source is the software source of the distribution packages.
+	Source string
+}
+
+// ovalPlatformFor returns the distribution release of the host OS version,
+// and false if it has no supported OVAL definitions.
+func ovalPlatformFor(os fleet.HostOSVersion) (ovalPlatform, bool) {
+	switch os.Platform {
+	case "ubuntu":
+		codename, ok := ubuntuCodenames[ubuntuReleaseRegexp.FindString(os.OSVersion)]
+		if !ok {
+			return ovalPlatform{}, false
+		}
+		url := fmt.Sprintf(ubuntuOVALURL, codename)
+		return ovalPlatform{FileName: filepath.Base(url), URL: url, Source: "deb_packages"}, true
+	case "rhel", "centos":
+		release := rhelReleaseRegexp.FindString(os.OSVersion)
+		if !rhelReleases[release] {
+			return ovalPlatform{}, false
+		}
+		url := fmt.Sprintf(rhelOVALURL, release)
+		return ovalPlatform{FileName: filepath.Base(url), URL: url, Source: "rpm_packages"}, true
+	}
+	return ovalPlatform{}, false
+}
+
+// ovalPlatforms groups the host OS versions by their distribution release,
+// sorted by definitions file name. OS versions without supported OVAL
+// definitions are skipped.
+func ovalPlatforms(versions []fleet.HostOSVersion) ([]ovalPlatform, map[ovalPlatform][]fleet.HostOSVersion) {
+	byPlatform := make(map[ovalPlatform][]fleet.HostOSVersion)
+	var platforms []ovalPlatform
+	for _, v := range versions {
+		p, ok := ovalPlatformFor(v)
+		if !ok {
+			continue
+		}
+		if _, ok := byPlatform[p]; !ok {
+			platforms = append(platforms, p)
+		}
+		byPlatform[p] = append(byPlatform[p], v)
+	}
+	sort.Slice(platforms, func(i, j int) bool { return platforms[i].FileName < platforms[j].FileName })
+	return platforms, byPlatform
+}
+
+// SyncOVALData downloads to the directory the OVAL definitions of the
+// distribution releases of the host OS versions. Definitions not modified
+// since they were last downloaded are not downloaded again.
+func SyncOVALData(ctx context.Context, client *http.Client, dir string, versions []fleet.HostOSVersion) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "create oval directory")
+	}
+
+	platforms, _ := ovalPlatforms(versions)
+	for _, p := range platforms {
+		if err := syncOVALFile(ctx, client, p.URL, filepath.Join(dir, p.FileName)); err != nil {
+			return errors.Wrapf(err, "sync oval definitions %s", p.FileName)
+		}
+	}
+	return nil
+}
+
+func syncOVALFile(ctx context.Context, client *http.Client, url, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	if info, err := os.Stat(path); err == nil {
+		req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "download %s", url)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil
+	case http.StatusOK:
+	default:
+		return errors.Errorf("download %s: unexpected status %s", url, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "download %s", url)
+	}
+
+	if err := writeFileAtomic(path, b, nil); err != nil {
+		return errors.Wrap(err, "write oval definitions")
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package vulnerabilities
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestOVAL(t *testing.T, name, source string) *OVALDatabase {
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+
+	db, err := parseOVAL(f, source)
+	require.NoError(t, err)
+	return db
+}
+
+func TestOVALEvaluateUbuntu(t *testing.T) {
+	db := loadTestOVAL(t, "com.ubuntu.focal.cve.oval.xml", "deb_packages")
+
+	testCases := []struct {
+		software fleet.Software
+		expected []fleet.SoftwareOVALResult
+	}{
+		{
+			fleet.Software{Name: "libssl1.1", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+			[]fleet.SoftwareOVALResult{{CVE: "CVE-2021-3449", DefinitionID: "oval:com.ubuntu.focal:def:2021344900000000", Vulnerable: true}},
+		},
+		{
+			fleet.Software{Name: "openssl", Version: "1.1.1f-1ubuntu2.3", Source: "deb_packages"},
+			[]fleet.SoftwareOVALResult{{CVE: "CVE-2021-3449", DefinitionID: "oval:com.ubuntu.focal:def:2021344900000000", Vulnerable: false}},
+		},
+		// Packages without a fix are vulnerable whatever their version.
+		{
+			fleet.Software{Name: "zsh", Version: "5.8-3ubuntu1", Source: "deb_packages"},
+			[]fleet.SoftwareOVALResult{{CVE: "CVE-2021-9999", DefinitionID: "oval:com.ubuntu.focal:def:2021999900000000", Vulnerable: true}},
+		},
+		{
+			fleet.Software{Name: "curl", Version: "7.68.0-1ubuntu2.4", Source: "deb_packages"},
+			[]fleet.SoftwareOVALResult{{CVE: "CVE-2021-0001", DefinitionID: "oval:com.ubuntu.focal:def:2021000100000000", Vulnerable: true}},
+		},
+		// The definition also depends on a test that can't be evaluated.
+		{
+			fleet.Software{Name: "curl", Version: "7.68.0-1ubuntu2.6", Source: "deb_packages"},
+			nil,
+		},
+		{
+			fleet.Software{Name: "bash", Version: "5.0-6ubuntu1.1", Source: "deb_packages"},
+			nil,
+		},
+		{
+			fleet.Software{Name: "openssl", Version: "1.1.1f", Source: "rpm_packages"},
+			nil,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.software.Name+" "+tt.software.Version, func(t *testing.T) {
+			assert.Equal(t, tt.expected, db.Evaluate(tt.software))
+		})
+	}
+}
+
+func TestOVALEvaluateRHEL(t *testing.T) {
+	db := loadTestOVAL(t, "rhel-8.oval.xml", "rpm_packages")
+
+	vulnerable := db.Evaluate(fleet.Software{Name: "openssl", Version: "1.1.1g", Source: "rpm_packages", Epoch: ptr.Uint(1), Release: "12.el8_3"})
+	assert.Equal(t, []fleet.SoftwareOVALResult{
+		{CVE: "CVE-2021-3449", DefinitionID: "oval:com.redhat.rhsa:def:20211024", Vulnerable: true},
+		{CVE: "CVE-2021-3450", DefinitionID: "oval:com.redhat.rhsa:def:20211024", Vulnerable: true},
+	}, vulnerable)
+
+	fixed := db.Evaluate(fleet.Software{Name: "openssl", Version: "1.1.1g", Source: "rpm_packages", Epoch: ptr.Uint(1), Release: "15.el8_3"})
+	assert.Equal(t, []fleet.SoftwareOVALResult{
+		{CVE: "CVE-2021-3449", DefinitionID: "oval:com.redhat.rhsa:def:20211024", Vulnerable: false},
+		{CVE: "CVE-2021-3450", DefinitionID: "oval:com.redhat.rhsa:def:20211024", Vulnerable: false},
+	}, fixed)
+
+	assert.Nil(t, db.Evaluate(fleet.Software{Name: "redhat-release", Version: "8.4", Source: "rpm_packages", Release: "0.6.el8"}))
+}
+
+func TestOVALPlatformFor(t *testing.T) {
+	testCases := []struct {
+		os       fleet.HostOSVersion
+		fileName string
+	}{
+		{fleet.HostOSVersion{Platform: "ubuntu", OSVersion: "Ubuntu 20.04.2 LTS"}, "com.ubuntu.focal.cve.oval.xml.bz2"},
+		{fleet.HostOSVersion{Platform: "ubuntu", OSVersion: "Ubuntu 18.04.5 LTS"}, "com.ubuntu.bionic.cve.oval.xml.bz2"},
+		{fleet.HostOSVersion{Platform: "ubuntu", OSVersion: "Ubuntu 14.04.6 LTS"}, ""},
+		{fleet.HostOSVersion{Platform: "rhel", OSVersion: "Red Hat Enterprise Linux 8.4.0"}, "rhel-8.oval.xml.bz2"},
+		{fleet.HostOSVersion{Platform: "centos", OSVersion: "CentOS Linux 7.9.2009"}, "rhel-7.oval.xml.bz2"},
+		{fleet.HostOSVersion{Platform: "centos", OSVersion: "CentOS 6.10.0"}, ""},
+		{fleet.HostOSVersion{Platform: "debian", OSVersion: "Debian GNU/Linux 10.0.0"}, ""},
+		{fleet.HostOSVersion{Platform: "darwin", OSVersion: "Mac OS X 10.15.7"}, ""},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.os.OSVersion, func(t *testing.T) {
+			p, ok := ovalPlatformFor(tt.os)
+			assert.Equal(t, tt.fileName != "", ok)
+			assert.Equal(t, tt.fileName, p.FileName)
+		})
+	}
+}
+
+func TestSyncOVALData(t *testing.T) {
+	definitions, err := ioutil.ReadFile(filepath.Join("testdata", "com.ubuntu.focal.cve.oval.xml.bz2"))
+	require.NoError(t, err)
+
+	downloads := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads[r.URL.Path]++
+		w.Write(definitions)
+	}))
+	defer server.Close()
+
+	defer func(url string) { ubuntuOVALURL = url }(ubuntuOVALURL)
+	ubuntuOVALURL = server.URL + "/oval/com.ubuntu.%s.cve.oval.xml.bz2"
+
+	dir := t.TempDir()
+	versions := []fleet.HostOSVersion{
+		{Platform: "ubuntu", OSVersion: "Ubuntu 20.04.1 LTS"},
+		{Platform: "ubuntu", OSVersion: "Ubuntu 20.04.2 LTS"},
+		{Platform: "darwin", OSVersion: "Mac OS X 10.15.7"},
+	}
+	require.NoError(t, SyncOVALData(context.Background(), server.Client(), dir, versions))
+	assert.Equal(t, map[string]int{"/oval/com.ubuntu.focal.cve.oval.xml.bz2": 1}, downloads)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "com.ubuntu.focal.cve.oval.xml.bz2"))
+	require.NoError(t, err)
+	assert.Equal(t, definitions, b)
+
+	// Unmodified definitions are not downloaded again.
+	require.NoError(t, SyncOVALData(context.Background(), server.Client(), dir, versions))
+	assert.Equal(t, map[string]int{"/oval/com.ubuntu.focal.cve.oval.xml.bz2": 1}, downloads)
+
+	_, err = LoadOVALDatabase(filepath.Join(dir, "com.ubuntu.focal.cve.oval.xml.bz2"), "deb_packages")
+	require.NoError(t, err)
+
+	ubuntuOVALURL = server.URL + "/missing/%s"
+	server.Config.Handler = http.NotFoundHandler()
+	err = SyncOVALData(context.Background(), server.Client(), t.TempDir(), versions)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprint(http.StatusNotFound))
+}
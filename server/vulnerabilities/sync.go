@@ -0,0 +1,158 @@
+package vulnerabilities
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCVEFeedPrefixURL is the location of the NVD CVE JSON 1.1 feeds.
+	DefaultCVEFeedPrefixURL = "https://nvd.nist.gov/feeds/json/cve/1.1/"
+
+	feedPrefix = "nvdcve-1.1-"
+	// firstFeedYear is the year of the oldest NVD CVE feed.
+	firstFeedYear = 2002
+)
+
+// SyncCVEData downloads the yearly NVD CVE feeds, from 2002 up to the year
+// of now, to the directory. Feeds whose checksum matches the one published
+// in their meta file are not downloaded again.
+func SyncCVEData(ctx context.Context, client *http.Client, prefixURL, dir string, now time.Time) error {
+	if prefixURL == "" {
+		prefixURL = DefaultCVEFeedPrefixURL
+	}
+	if !strings.HasSuffix(prefixURL, "/") {
+		prefixURL += "/"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "create cve feed directory")
+	}
+
+	for year := firstFeedYear; year <= now.Year(); year++ {
+		name := fmt.Sprintf("%s%d", feedPrefix, year)
+		if err := syncFeed(ctx, client, prefixURL, dir, name); err != nil {
+			return errors.Wrapf(err, "sync cve feed %s", name)
+		}
+	}
+	return nil
+}
+
+func syncFeed(ctx context.Context, client *http.Client, prefixURL, dir, name string) error {
+	meta, err := download(ctx, client, prefixURL+name+".meta")
+	if err != nil {
+		return err
+	}
+	checksum, err := metaChecksum(meta)
+	if err != nil {
+		return err
+	}
+
+	metaPath := filepath.Join(dir, name+".meta")
+	if current, err := ioutil.ReadFile(metaPath); err == nil {
+		if currentChecksum, err := metaChecksum(current); err == nil && currentChecksum == checksum {
+			return nil
+		}
+	}
+
+	feed, err := download(ctx, client, prefixURL+name+".json.gz")
+	if err != nil {
+		return err
+	}
+	// The meta checksum is the one of the decompressed feed.
+	gz, err := gzip.NewReader(bytes.NewReader(feed))
+	if err != nil {
+		return errors.Wrap(err, "decompress feed")
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, gz); err != nil {
+		return errors.Wrap(err, "decompress feed")
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != checksum {
+		return errors.New("feed checksum doesn't match its meta file")
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, name+".json.gz"), feed, nil); err != nil {
+		return errors.Wrap(err, "write feed")
+	}
+	if err := ioutil.WriteFile(metaPath, meta, 0644); err != nil {
+		return errors.Wrap(err, "write feed meta")
+	}
+	return nil
+}
+
+// writeFileAtomic writes the file through a temporary file renamed into
+// place, so that a failed download or write never leaves a partial file
+// behind. The modification time of the file is set to mtime if not nil.
+func writeFileAtomic(path string, b []byte, mtime *time.Time) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if mtime != nil {
+		if err := os.Chtimes(tmp, *mtime, *mtime); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func download(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	return downloadAccept(ctx, client, url, "")
+}
+
+// downloadAccept downloads the url like download, requesting the media type
+// if not empty.
+func downloadAccept(ctx context.Context, client *http.Client, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create request")
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "download %s", url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("download %s: unexpected status %s", url, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "download %s", url)
+	}
+	return b, nil
+}
+
+// metaChecksum returns the lowercase sha256 of a NVD feed meta file.
+func metaChecksum(meta []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(meta))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "sha256:") {
+			return strings.ToLower(strings.TrimPrefix(line, "sha256:")), nil
+		}
+	}
+	return "", errors.New("no sha256 in feed meta")
+}
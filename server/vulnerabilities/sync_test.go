@@ -0,0 +1,87 @@
+package vulnerabilities
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncCVEData(t *testing.T) {
+	feed := gzipFeed(t, testFeed)
+	meta := fmt.Sprintf("lastModifiedDate:2021-07-28T03:01:22-04:00\r\nsize:%d\r\nsha256:%X\r\n", len(testFeed), sha256.Sum256([]byte(testFeed)))
+
+	downloads := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads[r.URL.Path]++
+		switch filepath.Ext(r.URL.Path) {
+		case ".meta":
+			fmt.Fprint(w, meta)
+		case ".gz":
+			w.Write(feed)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	now := time.Date(2003, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, SyncCVEData(context.Background(), server.Client(), server.URL+"/feeds", dir, now))
+
+	for _, year := range []int{2002, 2003} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("nvdcve-1.1-%d.json.gz", year)))
+		require.NoError(t, err)
+		assert.Equal(t, feed, b)
+		assert.Equal(t, 1, downloads[fmt.Sprintf("/feeds/nvdcve-1.1-%d.json.gz", year)])
+	}
+
+	// Unchanged feeds are not downloaded again.
+	require.NoError(t, SyncCVEData(context.Background(), server.Client(), server.URL+"/feeds/", dir, now))
+	assert.Equal(t, 2, downloads["/feeds/nvdcve-1.1-2002.meta"])
+	assert.Equal(t, 1, downloads["/feeds/nvdcve-1.1-2002.json.gz"])
+
+	// Feeds not matching their checksum are rejected.
+	meta = "sha256:0000\r\n"
+	err := SyncCVEData(context.Background(), server.Client(), server.URL+"/feeds", t.TempDir(), now)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum")
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "feed.json")
+
+	require.NoError(t, writeFileAtomic(path, []byte("first"), nil))
+	require.NoError(t, writeFileAtomic(path, []byte("second"), nil))
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(b))
+
+	mtime := time.Date(2021, 8, 10, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, writeFileAtomic(path, []byte("third"), &mtime))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, mtime.Equal(info.ModTime()))
+
+	// Only the file is left, without its temporary file.
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "feed.json", files[0].Name())
+
+	// A failed write leaves nothing behind.
+	require.Error(t, writeFileAtomic(filepath.Join(dir, "missing", "feed.json"), []byte("x"), nil))
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,144 @@
+// Package vulnerabilities matches the software inventory of the hosts
+// against the NVD CVE data, the packages of the Ubuntu and RHEL hosts
+// against the OVAL definitions of their distribution, and the updates
+// installed on the Windows hosts against the security updates of the MSRC.
+package vulnerabilities
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/pkg/errors"
+)
+
+// TranslateSoftwareToCPE stores the CPE of the software that doesn't have
+// one yet. Software that can't be translated is skipped, and will be tried
+// again on the next run.
+func TranslateSoftwareToCPE(ds fleet.Datastore) error {
+	software, err := ds.ListSoftwareWithoutCPE()
+	if err != nil {
+		return errors.Wrap(err, "list software without cpe")
+	}
+	for _, s := range software {
+		cpe := CPEFromSoftware(s)
+		if cpe == "" {
+			continue
+		}
+		if err := ds.AddCPEForSoftware(s.ID, cpe); err != nil {
+			return errors.Wrapf(err, "add cpe for software %d", s.ID)
+		}
+	}
+	return nil
+}
+
+// TranslateCPEToCVE matches the CPE of every software against the CVE
+// database and stores the matching CVEs as the software vulnerabilities.
+func TranslateCPEToCVE(ds fleet.Datastore, db *CVEDatabase) error {
+	cpes, err := ds.ListSoftwareCPEs()
+	if err != nil {
+		return errors.Wrap(err, "list software cpes")
+	}
+	for _, c := range cpes {
+		cves, err := db.Match(c.CPE)
+		if err != nil {
+			return errors.Wrapf(err, "match cpe of software %d", c.SoftwareID)
+		}
+		if err := ds.ReplaceSoftwareCVEs(c.SoftwareID, cves); err != nil {
+			return errors.Wrapf(err, "replace cves of software %d", c.SoftwareID)
+		}
+	}
+	return nil
+}
+
+// TranslateOVALToCVE evaluates the packages installed on the hosts against
+// the OVAL definitions of their distribution release stored in the
+// directory by SyncOVALData, and stores the results. The CVEs the packages
+// are vulnerable to replace the ones matched from their CPE. Releases whose
+// definitions weren't downloaded are skipped.
+func TranslateOVALToCVE(ds fleet.Datastore, dir string) error {
+	versions, err := ds.ListHostOSVersions()
+	if err != nil {
+		return errors.Wrap(err, "list host os versions")
+	}
+
+	platforms, byPlatform := ovalPlatforms(versions)
+	for _, p := range platforms {
+		db, err := LoadOVALDatabase(filepath.Join(dir, p.FileName), p.Source)
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		evaluated := make(map[uint]bool)
+		for _, v := range byPlatform[p] {
+			software, err := ds.ListSoftwareByHostOS(v, p.Source)
+			if err != nil {
+				return errors.Wrapf(err, "list software of %s %s", v.Platform, v.OSVersion)
+			}
+			for _, s := range software {
+				if evaluated[s.ID] {
+					continue
+				}
+				evaluated[s.ID] = true
+				if err := saveOVALResults(ds, s.ID, db.Evaluate(s)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func saveOVALResults(ds fleet.Datastore, softwareID uint, results []fleet.SoftwareOVALResult) error {
+	if err := ds.ReplaceSoftwareOVALResults(softwareID, results); err != nil {
+		return errors.Wrapf(err, "replace oval results of software %d", softwareID)
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	var cves []string
+	for _, r := range results {
+		if r.Vulnerable {
+			cves = append(cves, r.CVE)
+		}
+	}
+	if err := ds.ReplaceSoftwareCVEs(softwareID, cves); err != nil {
+		return errors.Wrapf(err, "replace cves of software %d", softwareID)
+	}
+	return nil
+}
+
+// TranslateMSRCToOSVulnerabilities stores the vulnerabilities of the
+// operating system of the Windows hosts, fixed by the security updates
+// stored in the directory by SyncMSRCData that are missing on the hosts.
+// Hosts that didn't report any installed update yet are skipped.
+func TranslateMSRCToOSVulnerabilities(ds fleet.Datastore, dir string) error {
+	hosts, err := ds.ListWindowsHostUpdates()
+	if err != nil {
+		return errors.Wrap(err, "list windows host updates")
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	db, err := LoadMSRCDatabase(dir)
+	if err != nil {
+		return errors.Wrap(err, "load msrc database")
+	}
+	if db == nil {
+		return nil
+	}
+
+	for _, h := range hosts {
+		if len(h.KBIDs) == 0 {
+			continue
+		}
+		if err := ds.ReplaceHostOperatingSystemVulnerabilities(h.HostID, db.Evaluate(h.Build, h.KBIDs)); err != nil {
+			return errors.Wrapf(err, "replace operating system vulnerabilities of host %d", h.HostID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package vulnerabilities
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateSoftwareToCPE(t *testing.T) {
+	ds := new(mock.Store)
+	ds.ListSoftwareWithoutCPEFunc = func() ([]fleet.Software, error) {
+		return []fleet.Software{
+			{ID: 1, Name: "openssl", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+			{ID: 2, Name: "unversioned", Source: "apps"},
+		}, nil
+	}
+	cpes := make(map[uint]string)
+	ds.AddCPEForSoftwareFunc = func(softwareID uint, cpe string) error {
+		cpes[softwareID] = cpe
+		return nil
+	}
+
+	require.NoError(t, TranslateSoftwareToCPE(ds))
+	assert.Equal(t, map[uint]string{1: "cpe:2.3:a:*:openssl:1.1.1f:*:*:*:*:*:*:*"}, cpes)
+}
+
+func TestTranslateCPEToCVE(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "nvdcve-1.1-2021.json.gz"), gzipFeed(t, testFeed), 0644))
+	db, err := LoadCVEDatabase(dir)
+	require.NoError(t, err)
+
+	ds := new(mock.Store)
+	ds.ListSoftwareCPEsFunc = func() ([]fleet.SoftwareCPE, error) {
+		return []fleet.SoftwareCPE{
+			{SoftwareID: 1, CPE: "cpe:2.3:a:*:openssl:1.1.1f:*:*:*:*:*:*:*"},
+			{SoftwareID: 2, CPE: "cpe:2.3:a:*:zsh:5.8:*:*:*:*:*:*:*"},
+		}, nil
+	}
+	cves := make(map[uint][]string)
+	ds.ReplaceSoftwareCVEsFunc = func(softwareID uint, softwareCVEs []string) error {
+		cves[softwareID] = softwareCVEs
+		return nil
+	}
+
+	require.NoError(t, TranslateCPEToCVE(ds, db))
+	assert.Equal(t, map[uint][]string{1: {"CVE-2021-3449"}, 2: nil}, cves)
+}
+
+func TestTranslateOVALToCVE(t *testing.T) {
+	dir := t.TempDir()
+	definitions, err := ioutil.ReadFile(filepath.Join("testdata", "com.ubuntu.focal.cve.oval.xml.bz2"))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "com.ubuntu.focal.cve.oval.xml.bz2"), definitions, 0644))
+
+	ds := new(mock.Store)
+	ds.ListHostOSVersionsFunc = func() ([]fleet.HostOSVersion, error) {
+		return []fleet.HostOSVersion{
+			{Platform: "ubuntu", OSVersion: "Ubuntu 20.04.1 LTS"},
+			{Platform: "ubuntu", OSVersion: "Ubuntu 20.04.2 LTS"},
+			// Not synced, skipped.
+			{Platform: "centos", OSVersion: "CentOS Linux 8.3.2011"},
+			{Platform: "darwin", OSVersion: "Mac OS X 10.15.7"},
+		}, nil
+	}
+	ds.ListSoftwareByHostOSFunc = func(os fleet.HostOSVersion, source string) ([]fleet.Software, error) {
+		require.Equal(t, "ubuntu", os.Platform)
+		require.Equal(t, "deb_packages", source)
+		return []fleet.Software{
+			{ID: 1, Name: "libssl1.1", Version: "1.1.1f-1ubuntu2", Source: "deb_packages"},
+			{ID: 2, Name: "openssl", Version: "1.1.1f-1ubuntu2.3", Source: "deb_packages"},
+			{ID: 3, Name: "bash", Version: "5.0-6ubuntu1.1", Source: "deb_packages"},
+		}, nil
+	}
+	results := make(map[uint][]fleet.SoftwareOVALResult)
+	ds.ReplaceSoftwareOVALResultsFunc = func(softwareID uint, softwareResults []fleet.SoftwareOVALResult) error {
+		results[softwareID] = softwareResults
+		return nil
+	}
+	cves := make(map[uint][]string)
+	ds.ReplaceSoftwareCVEsFunc = func(softwareID uint, softwareCVEs []string) error {
+		cves[softwareID] = softwareCVEs
+		return nil
+	}
+
+	require.NoError(t, TranslateOVALToCVE(ds, dir))
+	assert.Equal(t, map[uint][]fleet.SoftwareOVALResult{
+		1: {{CVE: "CVE-2021-3449", DefinitionID: "oval:com.ubuntu.focal:def:2021344900000000", Vulnerable: true}},
+		2: {{CVE: "CVE-2021-3449", DefinitionID: "oval:com.ubuntu.focal:def:2021344900000000", Vulnerable: false}},
+		3: nil,
+	}, results)
+	// The CVEs of software without OVAL results are left to the NVD.
+	assert.Equal(t, map[uint][]string{1: {"CVE-2021-3449"}, 2: nil}, cves)
+}
+
+func TestTranslateMSRCToOSVulnerabilities(t *testing.T) {
+	dir := t.TempDir()
+	fixes, err := parseMSRCDocument([]byte(testMSRCDocument))
+	require.NoError(t, err)
+	b, err := json.Marshal(fixes)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "msrc-2021-Aug.json"), b, 0644))
+
+	ds := new(mock.Store)
+	ds.ListWindowsHostUpdatesFunc = func() ([]fleet.WindowsHostUpdates, error) {
+		return []fleet.WindowsHostUpdates{
+			{HostID: 1, Build: "19043", KBIDs: []string{"KB5004237"}},
+			{HostID: 2, Build: "19043", KBIDs: []string{"KB5005033"}},
+			// No updates reported yet, skipped.
+			{HostID: 3, Build: "19043"},
+		}, nil
+	}
+	vulnerabilities := make(map[uint][]fleet.OperatingSystemVulnerability)
+	ds.ReplaceHostOperatingSystemVulnerabilitiesFunc = func(hostID uint, hostVulnerabilities []fleet.OperatingSystemVulnerability) error {
+		vulnerabilities[hostID] = hostVulnerabilities
+		return nil
+	}
+
+	require.NoError(t, TranslateMSRCToOSVulnerabilities(ds, dir))
+	assert.Equal(t, map[uint][]fleet.OperatingSystemVulnerability{
+		1: {
+			{CVE: "CVE-2021-36936", ResolvedIn: "KB5005033"},
+			{CVE: "CVE-2021-36940", ResolvedIn: "KB5005033"},
+		},
+		2: nil,
+	}, vulnerabilities)
+
+	// Without downloaded updates, the vulnerabilities are left unchanged.
+	ds.ReplaceHostOperatingSystemVulnerabilitiesFuncInvoked = false
+	require.NoError(t, TranslateMSRCToOSVulnerabilities(ds, t.TempDir()))
+	assert.False(t, ds.ReplaceHostOperatingSystemVulnerabilitiesFuncInvoked)
+}
@@ -0,0 +1,112 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/vulnerabilities"
+	"github.com/pkg/errors"
+)
+
+type vulnerabilitiesWebhookPayload struct {
+	Timestamp     time.Time                   `json:"timestamp"`
+	Vulnerability vulnerabilityWebhookDetails `json:"vulnerability"`
+}
+
+type vulnerabilityWebhookDetails struct {
+	CVE              string                         `json:"cve"`
+	CVSSScore        *float64                       `json:"cvss_score"`
+	DetailsLink      string                         `json:"details_link"`
+	AffectedSoftware []vulnerabilityWebhookSoftware `json:"affected_software"`
+	HostsCount       int                            `json:"hosts_count"`
+	HostsAffected    []vulnerabilityWebhookHost     `json:"hosts_affected"`
+}
+
+type vulnerabilityWebhookSoftware struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+}
+
+type vulnerabilityWebhookHost struct {
+	ID       uint   `json:"id"`
+	Hostname string `json:"hostname"`
+	URL      string `json:"url"`
+}
+
+// TriggerVulnerabilitiesWebhook posts the vulnerabilities found in software
+// since the provided time to the vulnerabilities webhook, if it is enabled.
+// A request is posted per vulnerability with a CVSS score of at least the
+// configured minimum, and per batch of the affected hosts if a host batch
+// size is configured. Vulnerabilities without a known score are only posted
+// without a minimum.
+func TriggerVulnerabilitiesWebhook(ctx context.Context, ds fleet.Datastore, since time.Time) error {
+	config, err := ds.AppConfig()
+	if err != nil {
+		return errors.Wrap(err, "load app config")
+	}
+	if !config.VulnerabilitiesWebhookEnabled || config.VulnerabilitiesWebhookURL == "" {
+		return nil
+	}
+
+	found, err := vulnerabilities.ListNewVulnerabilities(ds, since, config.VulnerabilitiesWebhookMinCVSSScore)
+	if err != nil {
+		return errors.Wrap(err, "list new vulnerabilities")
+	}
+
+	serverURL := strings.TrimSuffix(config.ServerURL, "/")
+	now := time.Now()
+	for _, v := range found {
+		software := make([]vulnerabilityWebhookSoftware, 0, len(v.Software))
+		for _, s := range v.Software {
+			software = append(software, vulnerabilityWebhookSoftware{Name: s.Name, Version: s.Version, Source: s.Source})
+		}
+		hosts := make([]vulnerabilityWebhookHost, 0, len(v.Hosts))
+		for _, h := range v.Hosts {
+			hosts = append(hosts, vulnerabilityWebhookHost{
+				ID:       h.ID,
+				Hostname: h.Hostname,
+				URL:      fmt.Sprintf("%s/hosts/%d", serverURL, h.ID),
+			})
+		}
+
+		for _, batch := range batchHosts(hosts, config.VulnerabilitiesWebhookHostBatchSize) {
+			payload := vulnerabilitiesWebhookPayload{
+				Timestamp: now,
+				Vulnerability: vulnerabilityWebhookDetails{
+					CVE:              v.CVE,
+					CVSSScore:        v.CVSSScore,
+					DetailsLink:      "https://nvd.nist.gov/vuln/detail/" + v.CVE,
+					AffectedSoftware: software,
+					HostsCount:       len(hosts),
+					HostsAffected:    batch,
+				},
+			}
+			if err := PostJSON(ctx, config.VulnerabilitiesWebhookURL, payload); err != nil {
+				return errors.Wrapf(err, "post vulnerabilities webhook for %s", v.CVE)
+			}
+		}
+	}
+	return nil
+}
+
+// batchHosts splits the hosts in batches of the size, or returns a single
+// batch if the size is 0.
+func batchHosts(hosts []vulnerabilityWebhookHost, size int) [][]vulnerabilityWebhookHost {
+	if size <= 0 {
+		return [][]vulnerabilityWebhookHost{hosts}
+	}
+	var batches [][]vulnerabilityWebhookHost
+	for len(hosts) > 0 {
+		n := size
+		if n > len(hosts) {
+			n = len(hosts)
+		}
+		batches = append(batches, hosts[:n])
+		hosts = hosts[n:]
+	}
+	return batches
+}
@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTriggerVulnerabilitiesWebhook(t *testing.T) {
+	var received []vulnerabilitiesWebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload vulnerabilitiesWebhookPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received = append(received, payload)
+	}))
+	defer server.Close()
+
+	config := &fleet.AppConfig{
+		ServerURL:                           "https://fleet.example.com/",
+		VulnerabilitiesWebhookEnabled:       true,
+		VulnerabilitiesWebhookURL:           server.URL,
+		VulnerabilitiesWebhookHostBatchSize: 2,
+		VulnerabilitiesWebhookMinCVSSScore:  5,
+	}
+	ds := new(mock.Store)
+	ds.AppConfigFunc = func() (*fleet.AppConfig, error) {
+		return config, nil
+	}
+	ds.ListSoftwareVulnerabilitiesSinceFunc = func(since time.Time) ([]fleet.SoftwareVulnerability, error) {
+		return []fleet.SoftwareVulnerability{
+			{SoftwareID: 1, Name: "curl", Version: "7.68.0", Source: "deb_packages", CVE: "CVE-2021-0001"},
+			{SoftwareID: 2, Name: "openssl", Version: "1.1.1f", Source: "deb_packages", CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9)},
+			{SoftwareID: 3, Name: "openssl", Version: "1.1.1g", Source: "deb_packages", CVE: "CVE-2021-3449", CVSSScore: ptr.Float64(5.9)},
+			{SoftwareID: 2, Name: "openssl", Version: "1.1.1f", Source: "deb_packages", CVE: "CVE-2021-3450", CVSSScore: ptr.Float64(4.8)},
+		}, nil
+	}
+	ds.ListHostSoftwareVersionsBySoftwareIDFunc = func(softwareIDs []uint) ([]fleet.HostSoftwareVersion, error) {
+		assert.Equal(t, []uint{2, 3}, softwareIDs)
+		return []fleet.HostSoftwareVersion{
+			{HostID: 1, Hostname: "host1", SoftwareID: 2},
+			{HostID: 1, Hostname: "host1", SoftwareID: 3},
+			{HostID: 2, Hostname: "host2", SoftwareID: 2},
+			{HostID: 3, Hostname: "host3", SoftwareID: 3},
+		}, nil
+	}
+
+	require.NoError(t, TriggerVulnerabilitiesWebhook(context.Background(), ds, time.Now()))
+
+	// Only the vulnerability with a high enough score is posted, in two
+	// batches of hosts.
+	require.Len(t, received, 2)
+	for _, payload := range received {
+		v := payload.Vulnerability
+		assert.Equal(t, "CVE-2021-3449", v.CVE)
+		assert.Equal(t, ptr.Float64(5.9), v.CVSSScore)
+		assert.Equal(t, "https://nvd.nist.gov/vuln/detail/CVE-2021-3449", v.DetailsLink)
+		assert.Equal(t, []vulnerabilityWebhookSoftware{
+			{Name: "openssl", Version: "1.1.1f", Source: "deb_packages"},
+			{Name: "openssl", Version: "1.1.1g", Source: "deb_packages"},
+		}, v.AffectedSoftware)
+		assert.Equal(t, 3, v.HostsCount)
+	}
+	assert.Equal(t, []vulnerabilityWebhookHost{
+		{ID: 1, Hostname: "host1", URL: "https://fleet.example.com/hosts/1"},
+		{ID: 2, Hostname: "host2", URL: "https://fleet.example.com/hosts/2"},
+	}, received[0].Vulnerability.HostsAffected)
+	assert.Equal(t, []vulnerabilityWebhookHost{
+		{ID: 3, Hostname: "host3", URL: "https://fleet.example.com/hosts/3"},
+	}, received[1].Vulnerability.HostsAffected)
+
+	// Nothing is posted when the webhook is disabled.
+	received = nil
+	ds.ListSoftwareVulnerabilitiesSinceFuncInvoked = false
+	config.VulnerabilitiesWebhookEnabled = false
+	require.NoError(t, TriggerVulnerabilitiesWebhook(context.Background(), ds, time.Now()))
+	assert.False(t, ds.ListSoftwareVulnerabilitiesSinceFuncInvoked)
+	assert.Empty(t, received)
+}
@@ -0,0 +1,30 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostJSON(t *testing.T) {
+	var received map[string]string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	require.NoError(t, PostJSON(context.Background(), server.URL, map[string]string{"foo": "bar"}))
+	assert.Equal(t, map[string]string{"foo": "bar"}, received)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, PostJSON(context.Background(), server.URL, map[string]string{}))
+}